@@ -0,0 +1,74 @@
+// Package main implements minicoin-verify, a wallet-less chain verifier.
+// It runs only the consensus checks on an exported stage1 or stage2 chain and prints a JSON report.
+// Stage3 chains are verified by the stage3 program itself (verifysupply and the chain validation menu).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// 終了コード
+const (
+	exitValid   = 0 // チェーンは有効
+	exitInvalid = 1 // チェーンは無効
+	exitError   = 2 // ファイルを読み込めなかった
+)
+
+func main() {
+	formatFlag := flag.String("format", FormatAuto, "チェーン形式: auto, stage1, stage2")
+	minDifficultyFlag := flag.Int("min-difficulty", DefaultMinDifficulty, "stage2のジェネシスブロックに求める最小の難易度")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "使い方: minicoin-verify [-format auto|stage1|stage2] [-min-difficulty N] <chain.json>\n")
+		fmt.Fprintf(os.Stderr, "ステージ3のチェーン（署名・UTXOの検証）は go run ./stage3-transactions verifysupply で検証します\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(exitError)
+	}
+
+	os.Exit(run(flag.Arg(0), *formatFlag, *minDifficultyFlag))
+}
+
+// run はファイルを検証してレポートを標準出力に書き出し、終了コードを返します
+func run(filename, format string, minDifficulty int) int {
+	// #nosec G304 -- ファイル読み込みは教育目的のため許容
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ ファイル読み込みエラー: %v\n", err)
+		return exitError
+	}
+
+	blocks, detected, err := ParseChain(data, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ チェーン解析エラー: %v\n", err)
+		return exitError
+	}
+
+	schedule, err := ParseSchedule(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ チェーン解析エラー: %v\n", err)
+		return exitError
+	}
+	schedule.MinDifficulty = minDifficulty
+
+	report := Verify(blocks, detected, schedule)
+	report.File = filename
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ JSON変換エラー: %v\n", err)
+		return exitError
+	}
+	fmt.Println(string(output))
+
+	if !report.Valid {
+		return exitInvalid
+	}
+	return exitValid
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// サポートするチェーン形式
+const (
+	FormatAuto   = "auto"
+	FormatStage1 = "stage1"
+	FormatStage2 = "stage2"
+)
+
+// FormatStage3 はステージ3のチェーンを指定したときの形式名です（検証には対応していません）
+// ステージ3のコンセンサスの検証（署名・UTXOの会計）はステージ3のプログラムの中にあり、JSONのエクスポートもないため、
+// ステージ3のチェーンは stage3-transactions の verifysupply とメニューの「チェーン検証」で検証します
+const FormatStage3 = "stage3"
+
+// ExportedBlock はエクスポートされたチェーンのブロックを表します
+// stage1とstage2のJSON形式の両方を読み込めるよう、フィールドの和集合を持ちます
+type ExportedBlock struct {
+	Index        int64
+	Timestamp    int64
+	Data         string   // stage1
+	Entries      []string // stage2
+	MerkleRoot   string   // stage2
+	PreviousHash string
+	Hash         string
+	Nonce        int64
+	Difficulty   int
+	Algorithm    string // stage2のPoWアルゴリズム（空ならsha256）
+}
+
+// VerificationError は検証で見つかった1件の問題を表します
+type VerificationError struct {
+	Height  int64  `json:"height"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// Report は検証結果のレポートです（JSONで出力されます）
+type Report struct {
+	File    string              `json:"file,omitempty"`
+	Format  string              `json:"format"`
+	Valid   bool                `json:"valid"`
+	Blocks  int                 `json:"blocks"`
+	TipHash string              `json:"tip_hash,omitempty"`
+	Checks  []string            `json:"checks"`
+	Errors  []VerificationError `json:"errors"`
+}
+
+// exportedChain はブロック配列を包んだ形式のエクスポートファイルを表します
+type exportedChain struct {
+	Blocks []json.RawMessage `json:"blocks"`
+}
+
+// DefaultMinDifficulty はジェネシスブロックに求める難易度の既定値です
+// 難易度はブロック自身が主張する値のため、下限がないとすべて難易度0のチェーンも調整スケジュールを満たしてしまいます
+const DefaultMinDifficulty = 1
+
+// Schedule はステージ2の難易度調整のパラメータです
+// ステージ2のエクスポート（{"blocks": [...]} 形式）に記録された値を使い、記録がなければステージ2の既定値を使います
+type Schedule struct {
+	RetargetMode    string `json:"retarget_mode"`     // 難易度調整の方式（interval, per-block）
+	TargetBlockTime int    `json:"target_block_time"` // 目標ブロック生成時間（秒）
+	MinDifficulty   int    `json:"-"`                 // ジェネシスブロックに求める最小の難易度
+}
+
+// DefaultSchedule はステージ2の既定の難易度調整のパラメータを返します
+func DefaultSchedule() Schedule {
+	return Schedule{
+		RetargetMode:    common.RetargetInterval,
+		TargetBlockTime: common.DefaultTargetBlockTime,
+		MinDifficulty:   DefaultMinDifficulty,
+	}
+}
+
+// ParseSchedule はエクスポートされたチェーンから難易度調整のパラメータを読み込みます
+// ブロックの配列だけの形式や、値が記録されていない場合は既定値を使います
+func ParseSchedule(data []byte) (Schedule, error) {
+	schedule := DefaultSchedule()
+
+	var recorded Schedule
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		// ブロックの配列はパラメータを持たない
+		return schedule, nil
+	}
+	if recorded.RetargetMode != "" {
+		if recorded.RetargetMode != common.RetargetInterval && recorded.RetargetMode != common.RetargetPerBlock {
+			return Schedule{}, fmt.Errorf("unknown retarget mode: %s", recorded.RetargetMode)
+		}
+		schedule.RetargetMode = recorded.RetargetMode
+	}
+	if recorded.TargetBlockTime > 0 {
+		schedule.TargetBlockTime = recorded.TargetBlockTime
+	}
+
+	return schedule, nil
+}
+
+// ParseChain はエクスポートされたチェーンを読み込み、形式を判定します
+// ブロックの配列、または {"blocks": [...]} 形式のオブジェクトを受け付けます
+func ParseChain(data []byte, format string) ([]*ExportedBlock, string, error) {
+	var rawBlocks []json.RawMessage
+	if err := json.Unmarshal(data, &rawBlocks); err != nil {
+		var wrapped exportedChain
+		if err := json.Unmarshal(data, &wrapped); err != nil || wrapped.Blocks == nil {
+			return nil, "", fmt.Errorf("unrecognized chain format: expected a JSON block array")
+		}
+		rawBlocks = wrapped.Blocks
+	}
+
+	if len(rawBlocks) == 0 {
+		return nil, "", fmt.Errorf("chain contains no blocks")
+	}
+
+	// 形式の自動判定: NonceフィールドがあればPoW付きのstage2形式
+	if format == FormatAuto || format == "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(rawBlocks[0], &fields); err != nil {
+			return nil, "", fmt.Errorf("invalid block at height 0: %w", err)
+		}
+		format = FormatStage1
+		if _, ok := fields["Nonce"]; ok {
+			format = FormatStage2
+		}
+	}
+
+	if format == FormatStage3 {
+		return nil, "", fmt.Errorf("stage3 chains are not supported; run `go run ./stage3-transactions verifysupply` or the chain validation menu instead")
+	}
+	if format != FormatStage1 && format != FormatStage2 {
+		return nil, "", fmt.Errorf("unsupported format: %s", format)
+	}
+
+	blocks := make([]*ExportedBlock, 0, len(rawBlocks))
+	for i, raw := range rawBlocks {
+		var block ExportedBlock
+		if err := json.Unmarshal(raw, &block); err != nil {
+			return nil, "", fmt.Errorf("invalid block at height %d: %w", i, err)
+		}
+		blocks = append(blocks, &block)
+	}
+
+	return blocks, format, nil
+}
+
+// CalculateHash は形式に応じたブロックハッシュを再計算します
+// stage2ではデータ本体の代わりにヘッダーのマークルルートを使用します
+// ハッシュの対象はステージ1・2のプログラムと同じ common のレコードです
+// 未知のPoWアルゴリズムの場合は空文字列を返します
+func CalculateHash(block *ExportedBlock, format string) string {
+	if format == FormatStage2 {
+		record := common.AppendHeaderRecord(nil, block.Index, block.Timestamp, block.MerkleRoot, block.PreviousHash, block.Nonce, block.Difficulty)
+		hash, err := common.HashRecord(block.Algorithm, record)
+		if err != nil {
+			return ""
+		}
+		return hash
+	}
+
+	record := common.AppendBlockRecord(nil, block.Index, block.Timestamp, block.Data, block.PreviousHash)
+	return common.BytesToHex(common.Hash(record))
+}
+
+// Verify はチェーンのコンセンサス検証のみを実行し、レポートを返します
+// stage2では各ブロックの難易度が、それまでのブロックから調整スケジュールで計算し直した値と一致するかも検証します
+// 最初の問題で止まらず、見つかった問題をすべて記録します
+func Verify(blocks []*ExportedBlock, format string, schedule Schedule) *Report {
+	report := &Report{
+		Format: format,
+		Blocks: len(blocks),
+		Checks: []string{"genesis", "hash", "link", "index", "timestamp"},
+		Errors: []VerificationError{},
+	}
+	if format == FormatStage2 {
+		report.Checks = append(report.Checks, "merkle", "pow", "difficulty")
+	}
+
+	addError := func(height int64, check, format string, args ...interface{}) {
+		report.Errors = append(report.Errors, VerificationError{
+			Height:  height,
+			Check:   check,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	timestamps := make([]int64, 0, len(blocks))
+	for i, block := range blocks {
+		height := int64(i)
+
+		// ジェネシスブロック
+		if i == 0 {
+			if block.Index != 0 || block.PreviousHash != "" {
+				addError(height, "genesis", "genesis block must have index 0 and empty previous hash")
+			}
+		}
+
+		// ハッシュの再計算
+		if calculated := CalculateHash(block, format); calculated != block.Hash {
+			addError(height, "hash", "hash mismatch: stored %s, calculated %s", block.Hash, calculated)
+		}
+
+		// マークルルートとデータ本体の一致
+		if format == FormatStage2 {
+			if calculated := common.EntriesMerkleRoot(block.Entries); calculated != block.MerkleRoot {
+				addError(height, "merkle", "merkle root mismatch: stored %s, calculated %s", block.MerkleRoot, calculated)
+			}
+		}
+
+		// Proof of Work
+		if format == FormatStage2 {
+			if block.Difficulty < 0 || !strings.HasPrefix(block.Hash, strings.Repeat("0", block.Difficulty)) {
+				addError(height, "pow", "hash does not satisfy difficulty %d", block.Difficulty)
+			}
+		}
+
+		// 難易度の調整スケジュール（ブロックが主張する難易度をそのまま信頼しない）
+		if format == FormatStage2 {
+			if i == 0 {
+				if block.Difficulty < schedule.MinDifficulty {
+					addError(height, "difficulty", "genesis difficulty %d is below the minimum %d", block.Difficulty, schedule.MinDifficulty)
+				}
+			} else {
+				expected := common.NextDifficulty(schedule.RetargetMode, blocks[i-1].Difficulty, timestamps, schedule.TargetBlockTime)
+				if block.Difficulty != expected {
+					addError(height, "difficulty", "difficulty %d does not follow the %s retarget schedule (expected %d)", block.Difficulty, schedule.RetargetMode, expected)
+				}
+			}
+		}
+		timestamps = append(timestamps, block.Timestamp)
+
+		if i == 0 {
+			continue
+		}
+		previous := blocks[i-1]
+
+		// 前ブロックとのリンク
+		if block.PreviousHash != previous.Hash {
+			addError(height, "link", "previous hash does not match block %d", i-1)
+		}
+		if block.Index != previous.Index+1 {
+			addError(height, "index", "index %d does not follow %d", block.Index, previous.Index)
+		}
+		if block.Timestamp < previous.Timestamp {
+			addError(height, "timestamp", "timestamp goes backwards")
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	if len(blocks) > 0 {
+		report.TipHash = blocks[len(blocks)-1].Hash
+	}
+
+	return report
+}
@@ -0,0 +1,47 @@
+// Package main implements minicoin-worker, a reference client for the stage2 Stratum-like server.
+// It receives block header templates over TCP, searches for a nonce and submits it back.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	nameFlag := flag.String("name", "worker", "サーバーに通知するワーカー名")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "使い方: minicoin-worker [-name worker] <host:port>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	worker, err := Dial(flag.Arg(0), *nameFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ 接続エラー: %v\n", err)
+		os.Exit(1)
+	}
+	worker.Logf = func(format string, args ...interface{}) {
+		fmt.Printf(format+"\n", args...)
+	}
+	fmt.Printf("🔌 %s に接続しました（ワーカー名: %s）\n", flag.Arg(0), *nameFlag)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = worker.Run(ctx)
+	accepted, rejected := worker.Stats()
+	fmt.Printf("📊 受理: %d, 拒否: %d\n", accepted, rejected)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// Stratum風プロトコルのメソッド名（stage2-powのサーバーと同じ）
+const (
+	methodSubscribe = "mining.subscribe"
+	methodNotify    = "mining.notify"
+	methodSubmit    = "mining.submit"
+)
+
+// checkInterval は中断要求を確認する試行回数の間隔
+const checkInterval = 10000
+
+// Message はサーバーとやり取りする改行区切りのJSONメッセージです
+type Message struct {
+	ID     *int64          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Job はサーバーから配布されるブロックヘッダーの作業です
+type Job struct {
+	JobID        string `json:"job_id"`
+	Index        int64  `json:"index"`
+	Timestamp    int64  `json:"timestamp"`
+	MerkleRoot   string `json:"merkle_root"`
+	PreviousHash string `json:"previous_hash"`
+	Difficulty   int    `json:"difficulty"`
+	Algorithm    string `json:"algorithm"`
+}
+
+// Submission は見つけたナンスの提出内容です
+type Submission struct {
+	JobID    string `json:"job_id"`
+	Nonce    int64  `json:"nonce"`
+	Attempts int64  `json:"attempts"`
+}
+
+// HeaderRecord はナンスを含むブロックヘッダーのハッシュ対象文字列を返します
+// stage2のブロックハッシュと同じ並び順です
+func HeaderRecord(job *Job, nonce int64) string {
+	return string(common.AppendHeaderRecord(nil, job.Index, job.Timestamp, job.MerkleRoot, job.PreviousHash, nonce, job.Difficulty))
+}
+
+// HashHeader は作業のPoWアルゴリズムでヘッダーをハッシュ化します
+func HashHeader(job *Job, nonce int64) (string, error) {
+	return common.HashRecord(job.Algorithm, []byte(HeaderRecord(job, nonce)))
+}
+
+// Solve は難易度を満たすナンスをstartNonceから探索します
+// 見つかったナンスと試行回数を返します
+func Solve(ctx context.Context, job *Job, startNonce int64) (int64, int64, error) {
+	prefix := strings.Repeat("0", job.Difficulty)
+	var attempts int64
+
+	for nonce := startNonce; nonce >= 0; nonce++ {
+		hash, err := HashHeader(job, nonce)
+		if err != nil {
+			return 0, attempts, err
+		}
+		attempts++
+
+		if strings.HasPrefix(hash, prefix) {
+			return nonce, attempts, nil
+		}
+
+		if attempts%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return 0, attempts, err
+			}
+		}
+	}
+
+	return 0, attempts, fmt.Errorf("nonce overflow")
+}
+
+// randomStartNonce はワーカーごとに異なる探索開始位置を返します
+// 同じ作業を受け取ったワーカー同士が同じナンスを重複して探索しないようにします
+func randomStartNonce() int64 {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(buf[:]) >> 2)
+}
+
+// Worker はStratum風サーバーから作業を受け取ってマイニングするクライアントです
+type Worker struct {
+	Name string
+	// Logf は進捗の出力先です（nilなら出力しない）
+	Logf func(format string, args ...interface{})
+
+	conn     net.Conn
+	encoder  *json.Encoder
+	nextID   int64
+	accepted int64
+	rejected int64
+	mutex    sync.Mutex
+}
+
+// solution は探索ゴルーチンの結果です
+type solution struct {
+	job      *Job
+	nonce    int64
+	attempts int64
+}
+
+// Dial はサーバーに接続したワーカーを作成します
+func Dial(addr, name string) (*Worker, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{Name: name, conn: conn, encoder: json.NewEncoder(conn)}, nil
+}
+
+// Stats は受理・拒否されたブロック数を返します
+func (w *Worker) Stats() (accepted, rejected int64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.accepted, w.rejected
+}
+
+// Run は作業を購読し、ctxがキャンセルされるか接続が切れるまでマイニングを続けます
+func (w *Worker) Run(ctx context.Context) error {
+	defer func() { _ = w.conn.Close() }()
+
+	params, _ := json.Marshal(map[string]string{"worker": w.Name})
+	if err := w.send(methodSubscribe, params); err != nil {
+		return err
+	}
+
+	messages := make(chan Message)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(w.conn)
+		for scanner.Scan() {
+			var message Message
+			if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+				continue
+			}
+			select {
+			case messages <- message:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- fmt.Errorf("connection closed: %v", scanner.Err())
+	}()
+
+	solutions := make(chan solution)
+	pending := make(map[int64]string) // 提出のID → 作業ID
+	var current search
+	defer current.stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-readErr:
+			return err
+
+		case message := <-messages:
+			switch {
+			case message.Method == methodNotify:
+				var job Job
+				if err := json.Unmarshal(message.Params, &job); err != nil {
+					continue
+				}
+				w.logf("📥 作業 %s を受信: ブロック #%d, 難易度 %d", job.JobID, job.Index, job.Difficulty)
+
+				// 古い作業の探索を中断して新しい作業に切り替える
+				current.stop()
+				go w.solve(current.start(ctx), &job, solutions)
+
+			case message.ID != nil:
+				jobID, ok := pending[*message.ID]
+				if !ok {
+					continue
+				}
+				delete(pending, *message.ID)
+
+				w.mutex.Lock()
+				if message.Error == "" {
+					w.accepted++
+				} else {
+					w.rejected++
+				}
+				w.mutex.Unlock()
+
+				if message.Error == "" {
+					w.logf("✅ 作業 %s のブロックが受理されました", jobID)
+				} else {
+					w.logf("❌ 作業 %s のブロックが拒否されました: %s", jobID, message.Error)
+				}
+			}
+
+		case found := <-solutions:
+			w.logf("⛏️  ナンス %d を発見（%d 回試行）", found.nonce, found.attempts)
+			params, _ := json.Marshal(Submission{JobID: found.job.JobID, Nonce: found.nonce, Attempts: found.attempts})
+			if err := w.send(methodSubmit, params); err != nil {
+				return err
+			}
+			pending[w.nextID] = found.job.JobID
+		}
+	}
+}
+
+// search は実行中の探索を中断するためのものです
+type search struct {
+	cancel context.CancelFunc
+}
+
+// start は新しい探索用のコンテキストを返します
+func (s *search) start(ctx context.Context) context.Context {
+	var searchCtx context.Context
+	searchCtx, s.cancel = context.WithCancel(ctx)
+	return searchCtx
+}
+
+// stop は実行中の探索を中断します
+func (s *search) stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// solve は作業を探索し、見つかったナンスを送ります
+func (w *Worker) solve(ctx context.Context, job *Job, solutions chan<- solution) {
+	nonce, attempts, err := Solve(ctx, job, randomStartNonce())
+	if err != nil {
+		return
+	}
+	select {
+	case solutions <- solution{job: job, nonce: nonce, attempts: attempts}:
+	case <-ctx.Done():
+	}
+}
+
+// send はIDを割り当ててリクエストを送信します
+func (w *Worker) send(method string, params json.RawMessage) error {
+	w.nextID++
+	id := w.nextID
+	return w.encoder.Encode(Message{ID: &id, Method: method, Params: params})
+}
+
+// logf は進捗を出力します
+func (w *Worker) logf(format string, args ...interface{}) {
+	if w.Logf != nil {
+		w.Logf(format, args...)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashHeader(t *testing.T) {
+	t.Run("stage2のブロックハッシュと一致する", func(t *testing.T) {
+		// stage2-pow/testdata/golden/seeded_chain.json のジェネシスブロック
+		job := &Job{
+			Index:        0,
+			Timestamp:    1231006505,
+			MerkleRoot:   "89eb0ac031a63d2421cd05a2fbe41f3ea35f5c3712ca839cbf6b85c4ee07b7a3",
+			PreviousHash: "",
+			Difficulty:   2,
+		}
+
+		hash, err := HashHeader(job, 3510198754485963349)
+		require.NoError(t, err)
+		assert.Equal(t, "0054ec0d75cdd38948ac788ee1b551688ea268ac893275a440c995c6d786a0cd", hash)
+	})
+
+	t.Run("ヘッダーの並び順", func(t *testing.T) {
+		job := &Job{Index: 1, Timestamp: 2, MerkleRoot: "m", PreviousHash: "p", Difficulty: 3}
+		assert.Equal(t, "12mp43", HeaderRecord(job, 4))
+	})
+
+	t.Run("未知のアルゴリズムはエラー", func(t *testing.T) {
+		_, err := HashHeader(&Job{Algorithm: "x11"}, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestSolve(t *testing.T) {
+	t.Run("難易度を満たすナンスを見つける", func(t *testing.T) {
+		job := &Job{Index: 1, Timestamp: 1700000000, MerkleRoot: "root", PreviousHash: "prev", Difficulty: 2}
+
+		nonce, attempts, err := Solve(context.Background(), job, 0)
+		require.NoError(t, err)
+		assert.Equal(t, nonce+1, attempts)
+
+		hash, err := HashHeader(job, nonce)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "00"))
+	})
+
+	t.Run("キャンセルされると中断する", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, _, err := Solve(ctx, &Job{Difficulty: 64}, 0)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestWorkerRun(t *testing.T) {
+	t.Run("作業を受け取りナンスを提出する", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = listener.Close() }()
+
+		job := Job{JobID: "1", Index: 1, Timestamp: 1700000000, MerkleRoot: "root", PreviousHash: "prev", Difficulty: 1}
+		submitted := make(chan Submission, 1)
+
+		// 最小限のサーバー: 購読に応答して作業を送り、提出を受理する
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			reader := bufio.NewScanner(conn)
+			encoder := json.NewEncoder(conn)
+
+			for reader.Scan() {
+				var request Message
+				if json.Unmarshal(reader.Bytes(), &request) != nil {
+					return
+				}
+				switch request.Method {
+				case methodSubscribe:
+					params, _ := json.Marshal(job)
+					_ = encoder.Encode(Message{ID: request.ID, Result: true})
+					_ = encoder.Encode(Message{Method: methodNotify, Params: params})
+				case methodSubmit:
+					var submission Submission
+					_ = json.Unmarshal(request.Params, &submission)
+					_ = encoder.Encode(Message{ID: request.ID, Result: true})
+					submitted <- submission
+				}
+			}
+		}()
+
+		worker, err := Dial(listener.Addr().String(), "test")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- worker.Run(ctx) }()
+
+		var submission Submission
+		select {
+		case submission = <-submitted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no submission")
+		}
+		assert.Equal(t, "1", submission.JobID)
+		assert.Positive(t, submission.Attempts)
+
+		hash, err := HashHeader(&job, submission.Nonce)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hash, "0"))
+
+		require.Eventually(t, func() bool {
+			accepted, _ := worker.Stats()
+			return accepted == 1
+		}, time.Second, 10*time.Millisecond)
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+}
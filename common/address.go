@@ -0,0 +1,81 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// アドレスの検証エラーの種類（errors.Is で判定できます）
+var (
+	ErrAddressLength  = errors.New("invalid address length")
+	ErrAddressVersion = errors.New("unsupported address version")
+)
+
+// AddressLen はBase58Checkアドレスをデコードしたバイト数（バージョン + 公開鍵ハッシュ + チェックサム）
+const AddressLen = 1 + PubKeyHashLen + ChecksumLen
+
+// AddressError はアドレスの検証エラーです
+// Err はエラーの種類（ErrAddressLength, ErrInvalidBase58, ErrChecksumMismatch, ErrAddressVersion）、
+// Detail は期待値と実際の値などの詳細です
+type AddressError struct {
+	Address string
+	Err     error
+	Detail  string
+}
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("invalid address %q: %v (%s)", e.Address, e.Err, e.Detail)
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateAddress はアドレスの文字、長さ、チェックサム、バージョンバイトを順に検証します
+// 問題があれば *AddressError を返します
+// 公開鍵ハッシュとP2SHのどちらのアドレスも受け付けます
+// 旧形式（16進数）のアドレスはチェックサムを持たないため、形式が正しければ受け付けます
+func ValidateAddress(address string) error {
+	if IsLegacyAddress(address) {
+		return nil
+	}
+	_, _, err := decodeBase58CheckAddress(address)
+	return err
+}
+
+// decodeBase58CheckAddress はBase58Checkアドレスを検証してバージョンバイトとハッシュを返します
+func decodeBase58CheckAddress(address string) (byte, []byte, error) {
+	if address == "" {
+		return 0, nil, &AddressError{Address: address, Err: ErrAddressLength, Detail: "address is empty"}
+	}
+
+	if i := strings.IndexFunc(address, func(r rune) bool { return !strings.ContainsRune(base58Alphabet, r) }); i >= 0 {
+		detail := fmt.Sprintf("%q at position %d is not used in base58", []rune(address[i:])[0], i)
+		return 0, nil, &AddressError{Address: address, Err: ErrInvalidBase58, Detail: detail}
+	}
+
+	data, err := Base58Decode(address)
+	if err != nil {
+		return 0, nil, &AddressError{Address: address, Err: ErrInvalidBase58, Detail: err.Error()}
+	}
+	if len(data) != AddressLen {
+		detail := fmt.Sprintf("decodes to %d bytes, want %d", len(data), AddressLen)
+		return 0, nil, &AddressError{Address: address, Err: ErrAddressLength, Detail: detail}
+	}
+
+	body, sum := data[:len(data)-ChecksumLen], data[len(data)-ChecksumLen:]
+	if expected := checksum(body); !bytes.Equal(sum, expected) {
+		detail := fmt.Sprintf("checksum %x, expected %x (the address is probably mistyped)", sum, expected)
+		return 0, nil, &AddressError{Address: address, Err: ErrChecksumMismatch, Detail: detail}
+	}
+
+	version := body[0]
+	if version != AddressVersion && version != ScriptAddressVersion {
+		detail := fmt.Sprintf("version 0x%02x, want 0x%02x or 0x%02x", version, AddressVersion, ScriptAddressVersion)
+		return 0, nil, &AddressError{Address: address, Err: ErrAddressVersion, Detail: detail}
+	}
+
+	return version, body[1:], nil
+}
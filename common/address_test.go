@@ -0,0 +1,48 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAddress(t *testing.T) {
+	const valid = "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"
+
+	tests := []struct {
+		name     string
+		address  string
+		expected error // nilなら有効
+		detail   string
+	}{
+		{name: "正しいアドレス", address: valid},
+		{name: "旧形式のアドレス", address: strings.Repeat("ab", PubKeyHashLen)},
+		{name: "空文字列", address: "", expected: ErrAddressLength, detail: "empty"},
+		{name: "Base58にない文字", address: "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjv0", expected: ErrInvalidBase58, detail: "position 32"},
+		{name: "マルチバイト文字", address: "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvあ", expected: ErrInvalidBase58, detail: "'あ'"},
+		{name: "1文字足りない", address: valid[:len(valid)-1], expected: ErrAddressLength, detail: "want 25"},
+		{name: "1文字違う", address: valid[:len(valid)-1] + "N", expected: ErrChecksumMismatch, detail: "checksum"},
+		{name: "異なるバージョン", address: Base58CheckEncode(0x6f, make([]byte, PubKeyHashLen)), expected: ErrAddressVersion, detail: "version 0x6f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAddress(tt.address)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.expected)
+
+			var addrErr *AddressError
+			require.True(t, errors.As(err, &addrErr))
+			assert.Equal(t, tt.address, addrErr.Address)
+			assert.Contains(t, addrErr.Detail, tt.detail)
+		})
+	}
+}
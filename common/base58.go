@@ -0,0 +1,101 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Base58で使用する文字（紛らわしい 0, O, I, l を除いた58文字）
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ChecksumLen はBase58Checkのチェックサムの長さ（バイト）
+const ChecksumLen = 4
+
+// Base58Checkのデコードエラー
+var (
+	ErrInvalidBase58    = errors.New("invalid base58 character")
+	ErrChecksumTooShort = errors.New("base58check data too short")
+	ErrChecksumMismatch = errors.New("base58check checksum mismatch")
+)
+
+var base58Radix = big.NewInt(58)
+
+// Base58Encode はバイト列をBase58文字列にエンコードします
+// 先頭のゼロバイトは '1' として残します
+func Base58Encode(input []byte) string {
+	var result []byte
+
+	x := new(big.Int).SetBytes(input)
+	mod := new(big.Int)
+	for x.Sign() > 0 {
+		x.DivMod(x, base58Radix, mod)
+		result = append(result, base58Alphabet[mod.Int64()])
+	}
+
+	for _, b := range input {
+		if b != 0x00 {
+			break
+		}
+		result = append(result, base58Alphabet[0])
+	}
+
+	// 下の桁から積んだので反転する
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result)
+}
+
+// Base58Decode はBase58文字列をバイト列にデコードします
+func Base58Decode(input string) ([]byte, error) {
+	x := new(big.Int)
+	for i := 0; i < len(input); i++ {
+		digit := bytes.IndexByte([]byte(base58Alphabet), input[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("%w: %q at position %d", ErrInvalidBase58, input[i], i)
+		}
+		x.Mul(x, base58Radix)
+		x.Add(x, big.NewInt(int64(digit)))
+	}
+
+	zeros := 0
+	for zeros < len(input) && input[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), x.Bytes()...), nil
+}
+
+// checksum はSHA-256を2回適用した結果の先頭4バイトを返します
+func checksum(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:ChecksumLen]
+}
+
+// Base58CheckEncode はバージョンバイトとペイロードにチェックサムを付けてBase58でエンコードします
+func Base58CheckEncode(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	data = append(data, checksum(data)...)
+	return Base58Encode(data)
+}
+
+// Base58CheckDecode はBase58Check文字列を検証し、バージョンバイトとペイロードを返します
+func Base58CheckDecode(input string) (byte, []byte, error) {
+	data, err := Base58Decode(input)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 1+ChecksumLen {
+		return 0, nil, ErrChecksumTooShort
+	}
+
+	body := data[:len(data)-ChecksumLen]
+	if !bytes.Equal(checksum(body), data[len(data)-ChecksumLen:]) {
+		return 0, nil, ErrChecksumMismatch
+	}
+	return body[0], body[1:], nil
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string // 16進数
+		encoded string
+	}{
+		{name: "空のデータ", input: "", encoded: ""},
+		{name: "hello world", input: hex.EncodeToString([]byte("hello world")), encoded: "StV1DL6CwTryKyV"},
+		{name: "先頭のゼロバイトは1になる", input: "0000287fb4cd", encoded: "11233QC4"},
+		{name: "ゼロバイトのみ", input: "000000", encoded: "111"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := hex.DecodeString(tt.input)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.encoded, Base58Encode(input))
+
+			decoded, err := Base58Decode(tt.encoded)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, hex.EncodeToString(decoded))
+		})
+	}
+
+	t.Run("不正な文字", func(t *testing.T) {
+		for _, input := range []string{"0", "O", "I", "l", "abc+"} {
+			_, err := Base58Decode(input)
+			assert.ErrorIs(t, err, ErrInvalidBase58, input)
+		}
+	})
+}
+
+func TestBase58Check(t *testing.T) {
+	t.Run("エンコードとデコードの往復", func(t *testing.T) {
+		payload := []byte{0xde, 0xad, 0xbe, 0xef}
+		encoded := Base58CheckEncode(0x05, payload)
+
+		version, decoded, err := Base58CheckDecode(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, byte(0x05), version)
+		assert.Equal(t, payload, decoded)
+	})
+
+	t.Run("チェックサムの不一致", func(t *testing.T) {
+		data, err := Base58Decode(Base58CheckEncode(0x00, []byte("payload")))
+		require.NoError(t, err)
+		data[len(data)-1] ^= 0xff
+
+		_, _, err = Base58CheckDecode(Base58Encode(data))
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("短すぎるデータ", func(t *testing.T) {
+		_, _, err := Base58CheckDecode(Base58Encode([]byte{1, 2, 3}))
+		assert.ErrorIs(t, err, ErrChecksumTooShort)
+	})
+}
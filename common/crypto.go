@@ -8,8 +8,23 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+	"golang.org/x/crypto/scrypt"
+)
+
+// scryptのパラメータ（Litecoinよりメモリを多めにして違いを体感しやすくしています）
+const (
+	ScryptN      = 1024 // CPU/メモリコスト
+	ScryptR      = 8    // ブロックサイズ
+	ScryptP      = 1    // 並列度
+	ScryptKeyLen = 32   // 出力長（バイト）
+
+	// ScryptMemoryPerHash は1回のハッシュ計算で必要な作業メモリ（バイト）
+	ScryptMemoryPerHash = 128 * ScryptR * ScryptN
 )
 
 // Hash はSHA-256ハッシュを計算します
@@ -24,6 +39,25 @@ func HashString(data string) string {
 	return hex.EncodeToString(hash)
 }
 
+// ScryptHash はメモリハードなscryptハッシュを計算します
+// Litecoinと同様に、入力をパスワードとソルトの両方に使用します
+func ScryptHash(data []byte) ([]byte, error) {
+	hash, err := scrypt.Key(data, data, ScryptN, ScryptR, ScryptP, ScryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute scrypt hash: %w", err)
+	}
+	return hash, nil
+}
+
+// ScryptHashString は文字列のscryptハッシュを16進数文字列として返します
+func ScryptHashString(data string) (string, error) {
+	hash, err := ScryptHash([]byte(data))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
+
 // GenerateKeyPair はECDSA鍵ペアを生成します
 func GenerateKeyPair() (*ecdsa.PrivateKey, error) {
 	curve := elliptic.P256()
@@ -35,45 +69,178 @@ func GenerateKeyPair() (*ecdsa.PrivateKey, error) {
 }
 
 // Sign は秘密鍵を使ってデータに署名します
+// ナンスは RFC 6979 で秘密鍵とデータから決めるため、同じ鍵と同じデータからは常に同じ署名になります
 func Sign(privateKey *ecdsa.PrivateKey, data []byte) ([]byte, error) {
 	hash := Hash(data)
 
-	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash)
+	r, s, err := signDeterministic(privateKey, hash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign data: %w", err)
 	}
 
-	// rとsを結合して署名を作成
-	signature := append(r.Bytes(), s.Bytes()...)
-	return signature, nil
+	// s と n-s はどちらも有効な署名になるため、第三者が書き換えられないよう小さい方（low-S）に揃える
+	n := privateKey.Curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+
+	return fixedWidthPair(privateKey.Curve, r, s), nil
+}
+
+// fixedWidthPair は2つの値をそれぞれ曲線の鍵長に揃えて結合します
+// big.Int.Bytes は先頭の0のバイトを省くため、そのまま結合すると半分に分割して復元できなくなります
+func fixedWidthPair(curve elliptic.Curve, a, b *big.Int) []byte {
+	size := (curve.Params().BitSize + 7) / 8
+	pair := make([]byte, 2*size)
+	a.FillBytes(pair[:size])
+	b.FillBytes(pair[size:])
+	return pair
 }
 
-// Verify は公開鍵を使って署名を検証します
+// PublicKeyBytes は公開鍵をXとYを曲線の鍵長に揃えて結合したバイト列にします（P-256なら常に64バイト）
+func PublicKeyBytes(publicKey *ecdsa.PublicKey) []byte {
+	return fixedWidthPair(publicKey.Curve, publicKey.X, publicKey.Y)
+}
+
+// 署名の正規形の検証エラーの種類（errors.Is で判定できます）
+var (
+	ErrSignatureLength = errors.New("invalid signature length")
+	ErrSignatureRange  = errors.New("signature value out of range")
+	ErrSignatureHighS  = errors.New("signature S value is not low")
+)
+
+// CheckSignatureEncoding は署名が正規形かを検証します
+// 正規形は r と s を曲線の鍵長に揃えた固定長で、1 <= r, s < n かつ s <= n/2（low-S）のものです
+// 先頭に0を足した署名や s を n-s に替えた署名も数学的には検証を通るため、これらを拒否して署名を書き換えられないようにします
+func CheckSignatureEncoding(curve elliptic.Curve, signature []byte) error {
+	params := curve.Params()
+	size := (params.BitSize + 7) / 8
+	if len(signature) != 2*size {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrSignatureLength, len(signature), 2*size)
+	}
+
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(params.N) >= 0 || s.Cmp(params.N) >= 0 {
+		return ErrSignatureRange
+	}
+	if s.Cmp(new(big.Int).Rsh(params.N, 1)) > 0 {
+		return ErrSignatureHighS
+	}
+	return nil
+}
+
+// Verify は公開鍵を使って署名を検証します（正規形でない署名は無効です）
 func Verify(publicKey *ecdsa.PublicKey, data, signature []byte) bool {
+	if CheckSignatureEncoding(publicKey.Curve, signature) != nil {
+		return false
+	}
 	hash := Hash(data)
 
-	// 署名からrとsを抽出
-	sigLen := len(signature)
-	if sigLen%2 != 0 || sigLen == 0 {
+	size := len(signature) / 2
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+
+	return ecdsa.Verify(publicKey, hash, r, s)
+}
+
+// アドレスの形式
+const (
+	AddressVersion       = byte(0x00) // アドレスのバージョンバイト（Bitcoinのメインネットと同じ）
+	ScriptAddressVersion = byte(0x05) // スクリプトハッシュ（P2SH）アドレスのバージョンバイト（3で始まる）
+	PubKeyHashLen        = 20         // 公開鍵ハッシュの長さ（バイト）
+)
+
+// PublicKeyHash は公開鍵のバイト列から公開鍵ハッシュ RIPEMD160(SHA256(pubkey)) を計算します
+func PublicKeyHash(pubKeyBytes []byte) []byte {
+	sha := sha256.Sum256(pubKeyBytes)
+
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return hasher.Sum(nil)
+}
+
+// PubKeyHashToAddress は公開鍵ハッシュをBase58Checkのアドレスにエンコードします
+func PubKeyHashToAddress(pubKeyHash []byte) string {
+	return Base58CheckEncode(AddressVersion, pubKeyHash)
+}
+
+// ScriptHashToAddress はスクリプトハッシュをP2SHのBase58Checkアドレスにエンコードします
+func ScriptHashToAddress(scriptHash []byte) string {
+	return Base58CheckEncode(ScriptAddressVersion, scriptHash)
+}
+
+// AddressToPubKeyHash はBase58Check形式のアドレスを検証し、公開鍵ハッシュを取り出します
+// 検証に失敗した場合やP2SHのアドレスの場合は *AddressError を返します
+func AddressToPubKeyHash(address string) ([]byte, error) {
+	return decodeAddressVersion(address, AddressVersion)
+}
+
+// AddressToScriptHash はP2SHのアドレスを検証し、スクリプトハッシュを取り出します
+// 検証に失敗した場合や公開鍵ハッシュのアドレスの場合は *AddressError を返します
+func AddressToScriptHash(address string) ([]byte, error) {
+	return decodeAddressVersion(address, ScriptAddressVersion)
+}
+
+// IsScriptAddress はアドレスがP2SHのアドレスかを返します
+func IsScriptAddress(address string) bool {
+	_, err := AddressToScriptHash(address)
+	return err == nil
+}
+
+// decodeAddressVersion はBase58Checkアドレスを検証し、期待するバージョンならハッシュを返します
+func decodeAddressVersion(address string, want byte) ([]byte, error) {
+	version, hash, err := decodeBase58CheckAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if version != want {
+		detail := fmt.Sprintf("version 0x%02x, want 0x%02x", version, want)
+		return nil, &AddressError{Address: address, Err: ErrAddressVersion, Detail: detail}
+	}
+	return hash, nil
+}
+
+// LegacyAddressLen は旧形式のアドレス（20バイトの公開鍵ハッシュの16進数）の文字数
+const LegacyAddressLen = PubKeyHashLen * 2
+
+// IsLegacyAddress はアドレスがBase58Check導入前の16進数形式かを返します
+func IsLegacyAddress(address string) bool {
+	if len(address) != LegacyAddressLen {
 		return false
 	}
+	_, err := hex.DecodeString(address)
+	return err == nil
+}
 
-	r := new(big.Int).SetBytes(signature[:sigLen/2])
-	s := new(big.Int).SetBytes(signature[sigLen/2:])
+// DecodeAddress はBase58Check形式と旧形式（16進数）のどちらのアドレスからも公開鍵ハッシュを取り出します
+func DecodeAddress(address string) ([]byte, error) {
+	if IsLegacyAddress(address) {
+		return hex.DecodeString(address)
+	}
+	return AddressToPubKeyHash(address)
+}
 
-	return ecdsa.Verify(publicKey, hash, r, s)
+// NormalizeAddress はアドレスをBase58Check形式に揃えます（旧形式は同じ公開鍵ハッシュのBase58Checkになります）
+func NormalizeAddress(address string) (string, error) {
+	pubKeyHash, err := DecodeAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return PubKeyHashToAddress(pubKeyHash), nil
 }
 
-// PublicKeyToAddress は公開鍵からアドレス（16進数文字列）を生成します
-func PublicKeyToAddress(publicKey *ecdsa.PublicKey) string {
-	// 公開鍵をバイト列に変換
+// LegacyPublicKeyToAddress はBase58Check導入前の方式（SHA-256を2回適用した先頭20バイトの16進数）でアドレスを生成します
+// 旧形式のウォレットやアドレスを扱うために残しています
+func LegacyPublicKeyToAddress(publicKey *ecdsa.PublicKey) string {
 	pubKeyBytes := append(publicKey.X.Bytes(), publicKey.Y.Bytes()...)
 
-	// SHA-256ハッシュを2回適用（Bitcoin式）
-	hash1 := Hash(pubKeyBytes)
-	hash2 := Hash(hash1)
+	hash := Hash(Hash(pubKeyBytes))
+	return hex.EncodeToString(hash[:PubKeyHashLen])
+}
 
-	// 最初の20バイトを使用してアドレスを生成
-	address := hex.EncodeToString(hash2[:20])
-	return address
+// PublicKeyToAddress は公開鍵からBitcoin式のアドレス（Base58Check）を生成します
+// バージョンバイト + RIPEMD160(SHA256(公開鍵)) + 4バイトのチェックサム
+func PublicKeyToAddress(publicKey *ecdsa.PublicKey) string {
+	return PubKeyHashToAddress(PublicKeyHash(PublicKeyBytes(publicKey)))
 }
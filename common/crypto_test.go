@@ -1,7 +1,12 @@
 package common
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"encoding/hex"
+	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -75,6 +80,23 @@ func TestHashString(t *testing.T) {
 	}
 }
 
+func TestScryptHash(t *testing.T) {
+	t.Run("既知の入力に対する出力", func(t *testing.T) {
+		hash, err := ScryptHashString("Hello World")
+
+		require.NoError(t, err)
+		assert.Equal(t, "107bc142bdd135d75413afe89dbed0aa0ef22e81b07ca3cf38dac3e67e307353", hash)
+	})
+
+	t.Run("SHA-256とは異なるハッシュ", func(t *testing.T) {
+		hash, err := ScryptHash([]byte("Hello World"))
+
+		require.NoError(t, err)
+		assert.Len(t, hash, ScryptKeyLen)
+		assert.NotEqual(t, Hash([]byte("Hello World")), hash)
+	})
+}
+
 func TestGenerateKeyPair(t *testing.T) {
 	// 鍵ペアを生成
 	privateKey, err := GenerateKeyPair()
@@ -130,6 +152,77 @@ func TestSignAndVerify(t *testing.T) {
 	assert.False(t, valid, "Invalid signature should not verify")
 }
 
+func TestSignatureLength(t *testing.T) {
+	// rやsの先頭バイトが0でも署名は常に64バイトで、検証できることを確認
+	privateKey, err := GenerateKeyPair()
+	require.NoError(t, err)
+
+	for i := 0; i < 500; i++ {
+		data := []byte{byte(i), byte(i >> 8)}
+		signature, err := Sign(privateKey, data)
+		require.NoError(t, err)
+		require.Len(t, signature, 64)
+		require.True(t, Verify(&privateKey.PublicKey, data, signature))
+	}
+}
+
+func TestPublicKeyBytes(t *testing.T) {
+	// 座標の先頭バイトが0でも、XとYはそれぞれ32バイトに揃えて結合される
+	publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: big.NewInt(1), Y: big.NewInt(0xffff)}
+
+	pubKeyBytes := PublicKeyBytes(publicKey)
+	require.Len(t, pubKeyBytes, 64)
+	assert.Equal(t, big.NewInt(1), new(big.Int).SetBytes(pubKeyBytes[:32]))
+	assert.Equal(t, big.NewInt(0xffff), new(big.Int).SetBytes(pubKeyBytes[32:]))
+
+	// アドレスも同じバイト列のハッシュから作られる
+	assert.Equal(t, PubKeyHashToAddress(PublicKeyHash(pubKeyBytes)), PublicKeyToAddress(publicKey))
+}
+
+func TestCheckSignatureEncoding(t *testing.T) {
+	privateKey, err := GenerateKeyPair()
+	require.NoError(t, err)
+	data := []byte("Test data")
+	n := privateKey.Curve.Params().N
+
+	t.Run("署名は常にlow-Sの正規形", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			signature, err := Sign(privateKey, []byte{byte(i)})
+			require.NoError(t, err)
+			assert.NoError(t, CheckSignatureEncoding(privateKey.Curve, signature))
+		}
+	})
+
+	t.Run("sをn-sに替えた署名は数学的に正しくても拒否する", func(t *testing.T) {
+		signature, err := Sign(privateKey, data)
+		require.NoError(t, err)
+		r := new(big.Int).SetBytes(signature[:32])
+		highS := new(big.Int).Sub(n, new(big.Int).SetBytes(signature[32:]))
+		require.True(t, ecdsa.Verify(&privateKey.PublicKey, Hash(data), r, highS))
+
+		mutated := append(append([]byte{}, signature[:32]...), highS.FillBytes(make([]byte, 32))...)
+		assert.ErrorIs(t, CheckSignatureEncoding(privateKey.Curve, mutated), ErrSignatureHighS)
+		assert.False(t, Verify(&privateKey.PublicKey, data, mutated))
+	})
+
+	t.Run("先頭に0を足した署名を拒否する", func(t *testing.T) {
+		signature, err := Sign(privateKey, data)
+		require.NoError(t, err)
+		padded := append(append(append([]byte{0}, signature[:32]...), 0), signature[32:]...)
+
+		assert.ErrorIs(t, CheckSignatureEncoding(privateKey.Curve, padded), ErrSignatureLength)
+		assert.False(t, Verify(&privateKey.PublicKey, data, padded))
+	})
+
+	t.Run("範囲外のrやsを拒否する", func(t *testing.T) {
+		zero := make([]byte, 64)
+		assert.ErrorIs(t, CheckSignatureEncoding(privateKey.Curve, zero), ErrSignatureRange)
+
+		tooLarge := append(n.FillBytes(make([]byte, 32)), bytes.Repeat([]byte{0x01}, 32)...)
+		assert.ErrorIs(t, CheckSignatureEncoding(privateKey.Curve, tooLarge), ErrSignatureRange)
+	})
+}
+
 func TestVerifyWithInvalidSignature(t *testing.T) {
 	privateKey, err := GenerateKeyPair()
 	require.NoError(t, err)
@@ -170,12 +263,19 @@ func TestPublicKeyToAddress(t *testing.T) {
 	// アドレスを生成
 	address := PublicKeyToAddress(&privateKey.PublicKey)
 
-	// アドレスが16進数文字列であることを確認
-	_, err = hex.DecodeString(address)
-	assert.NoError(t, err)
+	// アドレスがBase58Checkとしてデコードでき、20バイトの公開鍵ハッシュを含むことを確認
+	pubKeyHash, err := AddressToPubKeyHash(address)
+	require.NoError(t, err)
+	assert.Len(t, pubKeyHash, PubKeyHashLen)
+
+	// バージョン0x00のアドレスは '1' で始まる
+	assert.Equal(t, "1", address[:1])
 
-	// アドレスが40文字（20バイトの16進数）であることを確認
-	assert.Equal(t, 40, len(address))
+	// 公開鍵ハッシュは RIPEMD160(SHA256(32バイトのX || 32バイトのY)) と一致する
+	pubKeyBytes := make([]byte, 64)
+	privateKey.PublicKey.X.FillBytes(pubKeyBytes[:32])
+	privateKey.PublicKey.Y.FillBytes(pubKeyBytes[32:])
+	assert.Equal(t, PublicKeyHash(pubKeyBytes), pubKeyHash)
 
 	// 同じ公開鍵から同じアドレスが生成されることを確認
 	address2 := PublicKeyToAddress(&privateKey.PublicKey)
@@ -186,6 +286,123 @@ func TestPublicKeyToAddress(t *testing.T) {
 	require.NoError(t, err)
 	otherAddress := PublicKeyToAddress(&otherPrivateKey.PublicKey)
 	assert.NotEqual(t, address, otherAddress)
+
+	// 座標の先頭バイトが0でも固定長に揃えてからハッシュする
+	small := &ecdsa.PublicKey{Curve: elliptic.P256(), X: big.NewInt(1), Y: big.NewInt(2)}
+	padded := make([]byte, 64)
+	padded[31], padded[63] = 1, 2
+	assert.Equal(t, PubKeyHashToAddress(PublicKeyHash(padded)), PublicKeyToAddress(small))
+}
+
+func TestPublicKeyHash(t *testing.T) {
+	// Bitcoin Wikiの「Technical background of version 1 Bitcoin addresses」の例
+	pubKey, err := hex.DecodeString("0450863AD64A87AE8A2FE83C1AF1A8403CB53F53E486D8511DAD8A04887E5B23522CD470243453A299FA9E77237716103ABC11A1DF38855ED6F2EE187E9C582BA6")
+	require.NoError(t, err)
+
+	pubKeyHash := PublicKeyHash(pubKey)
+	assert.Equal(t, "010966776006953d5567439e5e39f86a0d273bee", hex.EncodeToString(pubKeyHash))
+	assert.Equal(t, "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM", PubKeyHashToAddress(pubKeyHash))
+}
+
+func TestAddressToPubKeyHash(t *testing.T) {
+	t.Run("正しいアドレス", func(t *testing.T) {
+		pubKeyHash, err := AddressToPubKeyHash("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM")
+		require.NoError(t, err)
+		assert.Equal(t, "010966776006953d5567439e5e39f86a0d273bee", hex.EncodeToString(pubKeyHash))
+	})
+
+	t.Run("1文字違うとチェックサムで検出", func(t *testing.T) {
+		_, err := AddressToPubKeyHash("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvN")
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("Base58にない文字", func(t *testing.T) {
+		_, err := AddressToPubKeyHash("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjv0")
+		assert.ErrorIs(t, err, ErrInvalidBase58)
+	})
+
+	t.Run("異なるバージョン", func(t *testing.T) {
+		address := Base58CheckEncode(0x6f, make([]byte, PubKeyHashLen))
+		_, err := AddressToPubKeyHash(address)
+		assert.ErrorIs(t, err, ErrAddressVersion)
+	})
+
+	t.Run("公開鍵ハッシュの長さが不正", func(t *testing.T) {
+		address := Base58CheckEncode(AddressVersion, []byte{1, 2, 3})
+		_, err := AddressToPubKeyHash(address)
+		assert.Error(t, err)
+	})
+}
+
+func TestScriptAddress(t *testing.T) {
+	scriptHash := bytes.Repeat([]byte{0x42}, PubKeyHashLen)
+	address := ScriptHashToAddress(scriptHash)
+
+	t.Run("P2SHのアドレスは3で始まる", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(address, "3"))
+		assert.True(t, IsScriptAddress(address))
+		assert.NoError(t, ValidateAddress(address))
+
+		decoded, err := AddressToScriptHash(address)
+		require.NoError(t, err)
+		assert.Equal(t, scriptHash, decoded)
+	})
+
+	t.Run("公開鍵ハッシュのアドレスとは区別される", func(t *testing.T) {
+		_, err := AddressToPubKeyHash(address)
+		assert.ErrorIs(t, err, ErrAddressVersion)
+		_, err = DecodeAddress(address)
+		assert.ErrorIs(t, err, ErrAddressVersion)
+
+		p2pkh := PubKeyHashToAddress(scriptHash)
+		assert.False(t, IsScriptAddress(p2pkh))
+		_, err = AddressToScriptHash(p2pkh)
+		assert.ErrorIs(t, err, ErrAddressVersion)
+	})
+}
+
+func TestLegacyAddress(t *testing.T) {
+	privateKey, err := GenerateKeyPair()
+	require.NoError(t, err)
+	legacy := LegacyPublicKeyToAddress(&privateKey.PublicKey)
+
+	t.Run("旧形式は40文字の16進数", func(t *testing.T) {
+		assert.Len(t, legacy, LegacyAddressLen)
+		assert.True(t, IsLegacyAddress(legacy))
+		assert.False(t, IsLegacyAddress(PublicKeyToAddress(&privateKey.PublicKey)))
+		assert.False(t, IsLegacyAddress("abcd1234"))
+	})
+
+	t.Run("どちらの形式もデコードできる", func(t *testing.T) {
+		legacyHash, err := DecodeAddress(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, legacy, hex.EncodeToString(legacyHash))
+
+		address := PublicKeyToAddress(&privateKey.PublicKey)
+		pubKeyHash, err := DecodeAddress(address)
+		require.NoError(t, err)
+		assert.Len(t, pubKeyHash, PubKeyHashLen)
+	})
+
+	t.Run("正規化すると同じ公開鍵ハッシュのBase58Checkになる", func(t *testing.T) {
+		normalized, err := NormalizeAddress(legacy)
+		require.NoError(t, err)
+
+		legacyHash, err := hex.DecodeString(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, PubKeyHashToAddress(legacyHash), normalized)
+
+		// Base58Check形式はそのまま
+		address := PublicKeyToAddress(&privateKey.PublicKey)
+		normalized, err = NormalizeAddress(address)
+		require.NoError(t, err)
+		assert.Equal(t, address, normalized)
+	})
+
+	t.Run("どちらの形式でもないアドレスはエラー", func(t *testing.T) {
+		_, err := NormalizeAddress("not-an-address")
+		assert.Error(t, err)
+	})
 }
 
 // ベンチマーク
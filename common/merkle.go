@@ -0,0 +1,90 @@
+// Package common provides Merkle trees and inclusion proofs for the blockchain implementation.
+package common
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MerkleTree はハッシュのリストから作るマークルツリーです
+// Levels[0] が葉（元のハッシュ）、最後の段がルートです。段の要素が奇数個のときは最後の要素を自分自身と組み合わせます
+type MerkleTree struct {
+	Levels [][][]byte
+}
+
+// MerkleStep はマークル証明の1段分で、組み合わせる兄弟のハッシュとその位置です
+type MerkleStep struct {
+	Hash []byte // 兄弟のハッシュ
+	Left bool   // 兄弟が左側にあるなら true（兄弟 + 自分の順に結合する）
+}
+
+// NewMerkleTree はハッシュのリストからマークルツリーを作ります
+func NewMerkleTree(hashes [][]byte) *MerkleTree {
+	if len(hashes) == 0 {
+		return &MerkleTree{Levels: [][][]byte{{Hash([]byte{})}}}
+	}
+
+	level := make([][]byte, len(hashes))
+	copy(level, hashes)
+	tree := &MerkleTree{Levels: [][][]byte{level}}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashPair(level[i], right))
+		}
+		tree.Levels = append(tree.Levels, next)
+		level = next
+	}
+
+	return tree
+}
+
+// Root はマークルルートを返します
+func (t *MerkleTree) Root() []byte {
+	return t.Levels[len(t.Levels)-1][0]
+}
+
+// Proof は index 番目の葉からルートまでの兄弟のハッシュを返します
+// 証明には経路上の兄弟だけが含まれ、他の葉（トランザクション）そのものは含まれません
+func (t *MerkleTree) Proof(index int) ([]MerkleStep, error) {
+	if index < 0 || index >= len(t.Levels[0]) {
+		return nil, fmt.Errorf("leaf index %d out of range (%d leaves)", index, len(t.Levels[0]))
+	}
+
+	var proof []MerkleStep
+	for _, level := range t.Levels[:len(t.Levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof = append(proof, MerkleStep{Hash: level[sibling], Left: sibling < index})
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyMerkleProof は葉のハッシュと証明からルートを計算し直し、root と一致するか確認します
+func VerifyMerkleProof(root, leaf []byte, proof []MerkleStep) bool {
+	hash := leaf
+	for _, step := range proof {
+		if step.Left {
+			hash = hashPair(step.Hash, hash)
+		} else {
+			hash = hashPair(hash, step.Hash)
+		}
+	}
+	return bytes.Equal(hash, root)
+}
+
+// hashPair は2つのハッシュを連結してハッシュ化します
+func hashPair(left, right []byte) []byte {
+	combined := make([]byte, 0, len(left)+len(right))
+	combined = append(combined, left...)
+	combined = append(combined, right...)
+	return Hash(combined)
+}
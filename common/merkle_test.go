@@ -0,0 +1,90 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLeaves は n 個の葉のハッシュを作ります
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = Hash([]byte(fmt.Sprintf("tx%d", i)))
+	}
+	return leaves
+}
+
+func TestMerkleTree(t *testing.T) {
+	t.Run("ルートはMerkleRootと一致する", func(t *testing.T) {
+		for n := 0; n <= 9; n++ {
+			leaves := testLeaves(n)
+			assert.Equal(t, MerkleRoot(leaves), NewMerkleTree(leaves).Root(), "%d leaves", n)
+		}
+	})
+
+	t.Run("奇数個の段は最後の要素を複製する", func(t *testing.T) {
+		leaves := testLeaves(3)
+		left := Hash(append(append([]byte{}, leaves[0]...), leaves[1]...))
+		right := Hash(append(append([]byte{}, leaves[2]...), leaves[2]...))
+		assert.Equal(t, Hash(append(left, right...)), NewMerkleTree(leaves).Root())
+	})
+
+	t.Run("元のハッシュを書き換えない", func(t *testing.T) {
+		leaves := testLeaves(4)
+		backing := make([]byte, 32, 64)
+		copy(backing, leaves[0])
+		leaves[0] = backing
+
+		NewMerkleTree(leaves)
+		assert.Equal(t, make([]byte, 32), backing[32:64])
+	})
+}
+
+func TestMerkleProof(t *testing.T) {
+	t.Run("すべての葉の証明を検証できる", func(t *testing.T) {
+		for n := 1; n <= 9; n++ {
+			leaves := testLeaves(n)
+			tree := NewMerkleTree(leaves)
+			for i, leaf := range leaves {
+				proof, err := tree.Proof(i)
+				require.NoError(t, err)
+				assert.True(t, VerifyMerkleProof(tree.Root(), leaf, proof), "leaf %d of %d", i, n)
+			}
+		}
+	})
+
+	t.Run("証明の長さは木の高さ", func(t *testing.T) {
+		tree := NewMerkleTree(testLeaves(5))
+		proof, err := tree.Proof(4)
+		require.NoError(t, err)
+		assert.Len(t, proof, 3)
+
+		single, err := NewMerkleTree(testLeaves(1)).Proof(0)
+		require.NoError(t, err)
+		assert.Empty(t, single)
+	})
+
+	t.Run("別の葉やルート、改ざんした証明は検証に失敗する", func(t *testing.T) {
+		leaves := testLeaves(6)
+		tree := NewMerkleTree(leaves)
+		proof, err := tree.Proof(2)
+		require.NoError(t, err)
+
+		assert.False(t, VerifyMerkleProof(tree.Root(), leaves[3], proof))
+		assert.False(t, VerifyMerkleProof(NewMerkleTree(leaves[:5]).Root(), leaves[2], proof))
+
+		proof[0].Left = !proof[0].Left
+		assert.False(t, VerifyMerkleProof(tree.Root(), leaves[2], proof))
+	})
+
+	t.Run("範囲外のインデックスはエラー", func(t *testing.T) {
+		tree := NewMerkleTree(testLeaves(3))
+		_, err := tree.Proof(3)
+		assert.Error(t, err)
+		_, err = tree.Proof(-1)
+		assert.Error(t, err)
+	})
+}
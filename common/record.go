@@ -0,0 +1,57 @@
+// Package common provides the block hash records shared by the stage1 and stage2 chains.
+package common
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// ステージ2で使えるPoWアルゴリズム
+const (
+	PoWSHA256 = "sha256" // 計算量のみに依存（ASICで高速化しやすい）
+	PoWScrypt = "scrypt" // メモリハード（ASIC耐性を狙ったもの）
+)
+
+// AppendBlockRecord はステージ1のブロックハッシュの対象を dst に追記して返します
+// Index + Timestamp + Data + PreviousHash を10進数・文字列のまま連結したものです
+func AppendBlockRecord(dst []byte, index, timestamp int64, data, previousHash string) []byte {
+	dst = strconv.AppendInt(dst, index, 10)
+	dst = strconv.AppendInt(dst, timestamp, 10)
+	dst = append(dst, data...)
+	return append(dst, previousHash...)
+}
+
+// AppendHeaderRecord はステージ2のブロックヘッダーのハッシュの対象を dst に追記して返します
+// ステージ1のレコードのデータの位置にマークルルートを置き、Nonce + Difficulty を続けたものです
+func AppendHeaderRecord(dst []byte, index, timestamp int64, merkleRoot, previousHash string, nonce int64, difficulty int) []byte {
+	dst = AppendBlockRecord(dst, index, timestamp, merkleRoot, previousHash)
+	dst = strconv.AppendInt(dst, nonce, 10)
+	return strconv.AppendInt(dst, int64(difficulty), 10)
+}
+
+// EntriesMerkleRoot はステージ2のデータ一覧の各要素のハッシュからマークルルートを計算し、16進数で返します
+func EntriesMerkleRoot(entries []string) string {
+	hashes := make([][]byte, len(entries))
+	for i, entry := range entries {
+		hashes[i] = Hash([]byte(entry))
+	}
+	return BytesToHex(MerkleRoot(hashes))
+}
+
+// HashRecord はレコードをPoWアルゴリズムでハッシュ化して16進数で返します
+// 空文字列は従来のブロックとの互換性のためsha256として扱います
+func HashRecord(algorithm string, record []byte) (string, error) {
+	switch algorithm {
+	case "", PoWSHA256:
+		return hex.EncodeToString(Hash(record)), nil
+	case PoWScrypt:
+		hash, err := ScryptHash(record)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash), nil
+	default:
+		return "", fmt.Errorf("unknown PoW algorithm: %s (sha256, scrypt)", algorithm)
+	}
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendBlockRecord(t *testing.T) {
+	record := AppendBlockRecord(nil, 1, 1700000000, "Block 1", "abc")
+	assert.Equal(t, "11700000000Block 1abc", string(record))
+
+	// dst に追記する
+	assert.Equal(t, "x01data", string(AppendBlockRecord([]byte("x"), 0, 1, "data", "")))
+}
+
+func TestAppendHeaderRecord(t *testing.T) {
+	record := AppendHeaderRecord(nil, 2, 1700000010, "root", "prev", 42, 3)
+	assert.Equal(t, "21700000010rootprev423", string(record))
+
+	// ステージ1のレコードにナンスと難易度を続けたもの
+	prefix := AppendBlockRecord(nil, 2, 1700000010, "root", "prev")
+	assert.Equal(t, string(prefix)+"423", string(record))
+}
+
+func TestEntriesMerkleRoot(t *testing.T) {
+	entries := []string{"A", "B", "C"}
+	hashes := [][]byte{Hash([]byte("A")), Hash([]byte("B")), Hash([]byte("C"))}
+
+	assert.Equal(t, BytesToHex(MerkleRoot(hashes)), EntriesMerkleRoot(entries))
+	assert.NotEqual(t, EntriesMerkleRoot(entries), EntriesMerkleRoot([]string{"B", "A", "C"}))
+}
+
+func TestHashRecord(t *testing.T) {
+	record := []byte("record")
+
+	for _, algorithm := range []string{"", PoWSHA256} {
+		hash, err := HashRecord(algorithm, record)
+		require.NoError(t, err)
+		assert.Equal(t, HashString("record"), hash)
+	}
+
+	hash, err := HashRecord(PoWScrypt, record)
+	require.NoError(t, err)
+	expected, err := ScryptHashString("record")
+	require.NoError(t, err)
+	assert.Equal(t, expected, hash)
+
+	_, err = HashRecord("x11", record)
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+// Package common provides the stage2 difficulty retarget schedule shared by the miner and the verifier.
+package common
+
+import "math"
+
+// ステージ2の難易度調整のパラメータ
+const (
+	// DefaultTargetBlockTime は目標ブロック生成時間（秒）の既定値
+	DefaultTargetBlockTime = 10
+
+	// AdjustmentInterval は難易度調整を行うブロック間隔
+	AdjustmentInterval = 10
+
+	// MaxAdjustmentFactor は最大調整倍率（急激な変化を防ぐ）
+	MaxAdjustmentFactor = 2.0
+
+	// MinDifficulty は最小難易度
+	MinDifficulty = 0
+
+	// MaxDifficulty は最大難易度
+	MaxDifficulty = 10
+
+	// MaxPerBlockAdjustment は毎ブロック調整で一度に変化できる難易度の上限
+	MaxPerBlockAdjustment = 1
+)
+
+// ステージ2の難易度調整の方式
+const (
+	RetargetInterval = "interval"  // AdjustmentIntervalブロックごとに平均生成時間で調整する（Bitcoin風）
+	RetargetPerBlock = "per-block" // 親ブロックの生成時間で毎ブロック調整する（Ethereum風）
+)
+
+// AdjustDifficulty は実際の平均時間と目標時間を比較して新しい難易度を返します
+func AdjustDifficulty(currentDifficulty int, actualTime, targetTime float64) int {
+	if actualTime == 0.0 || targetTime == 0.0 {
+		return currentDifficulty
+	}
+
+	// 調整比率を計算
+	ratio := actualTime / targetTime
+
+	// 急激な変化を防ぐ
+	if ratio > MaxAdjustmentFactor {
+		ratio = MaxAdjustmentFactor
+	} else if ratio < 1.0/MaxAdjustmentFactor {
+		ratio = 1.0 / MaxAdjustmentFactor
+	}
+
+	// 難易度を調整
+	// 実際の時間が目標より長い → 難易度を下げる（マイニングを簡単に）
+	// 実際の時間が目標より短い → 難易度を上げる（マイニングを難しく）
+	var newDifficulty int
+	if ratio > 1.0 {
+		// 時間がかかりすぎている → 難易度を下げる
+		adjustment := int(math.Ceil(math.Log2(ratio)))
+		newDifficulty = currentDifficulty - adjustment
+	} else {
+		// 時間が短すぎる → 難易度を上げる
+		adjustment := int(math.Ceil(math.Log2(1.0 / ratio)))
+		newDifficulty = currentDifficulty + adjustment
+	}
+
+	return clampDifficulty(newDifficulty)
+}
+
+// AdjustDifficultyPerBlock は親ブロックの生成時間から新しい難易度を返します
+// Ethereum (Homestead) と同様に 1 - solveTime/targetTime を調整量とし、
+// MaxPerBlockAdjustment の範囲に制限します
+func AdjustDifficultyPerBlock(currentDifficulty int, solveTime, targetTime int64) int {
+	if targetTime <= 0 {
+		return currentDifficulty
+	}
+
+	// 目標より速い → +1、目標の1〜2倍 → 変化なし、2倍以上 → 下げる
+	adjustment := 1 - solveTime/targetTime
+	if adjustment > MaxPerBlockAdjustment {
+		adjustment = MaxPerBlockAdjustment
+	} else if adjustment < -MaxPerBlockAdjustment {
+		adjustment = -MaxPerBlockAdjustment
+	}
+
+	return clampDifficulty(currentDifficulty + int(adjustment))
+}
+
+// NextDifficulty は調整の方式に従って、チェーンの次のブロックの難易度を返します
+// timestamps はジェネシスから順の各ブロックのタイムスタンプ、currentDifficulty は最新ブロックの難易度です
+// 調整するブロックでなければ currentDifficulty をそのまま返します
+func NextDifficulty(mode string, currentDifficulty int, timestamps []int64, targetTime int) int {
+	n := len(timestamps)
+
+	if mode == RetargetPerBlock {
+		if n < 2 {
+			return currentDifficulty
+		}
+		return AdjustDifficultyPerBlock(currentDifficulty, timestamps[n-1]-timestamps[n-2], int64(targetTime))
+	}
+
+	// 調整間隔でのみ難易度を更新
+	if n < AdjustmentInterval || n%AdjustmentInterval != 0 {
+		return currentDifficulty
+	}
+
+	// 直近のブロックの平均生成時間（ジェネシスの前はないため、最初の調整は1つ少ない間隔で計算する）
+	blocks := AdjustmentInterval
+	if n-1 < blocks {
+		blocks = n - 1
+	}
+	avgTime := float64(timestamps[n-1]-timestamps[n-1-blocks]) / float64(blocks)
+
+	return AdjustDifficulty(currentDifficulty, avgTime, float64(targetTime))
+}
+
+// clampDifficulty は難易度を MinDifficulty 以上 MaxDifficulty 以下に制限します
+func clampDifficulty(difficulty int) int {
+	if difficulty < MinDifficulty {
+		return MinDifficulty
+	}
+	if difficulty > MaxDifficulty {
+		return MaxDifficulty
+	}
+	return difficulty
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// evenTimestamps は interval 秒間隔の n 個のタイムスタンプを返します
+func evenTimestamps(n int, interval int64) []int64 {
+	timestamps := make([]int64, n)
+	for i := range timestamps {
+		timestamps[i] = 1700000000 + int64(i)*interval
+	}
+	return timestamps
+}
+
+func TestNextDifficulty(t *testing.T) {
+	t.Run("interval: 調整間隔以外では変えない", func(t *testing.T) {
+		assert.Equal(t, 3, NextDifficulty(RetargetInterval, 3, evenTimestamps(1, 1), DefaultTargetBlockTime))
+		assert.Equal(t, 3, NextDifficulty(RetargetInterval, 3, evenTimestamps(AdjustmentInterval-1, 1), DefaultTargetBlockTime))
+		assert.Equal(t, 3, NextDifficulty(RetargetInterval, 3, evenTimestamps(AdjustmentInterval+1, 1), DefaultTargetBlockTime))
+	})
+
+	t.Run("interval: 平均生成時間で上げ下げする", func(t *testing.T) {
+		// 目標より速い → 上げる、遅い → 下げる、目標どおり → そのまま
+		assert.Equal(t, 4, NextDifficulty(RetargetInterval, 3, evenTimestamps(AdjustmentInterval, 1), DefaultTargetBlockTime))
+		assert.Equal(t, 2, NextDifficulty(RetargetInterval, 3, evenTimestamps(2*AdjustmentInterval, 3*DefaultTargetBlockTime), DefaultTargetBlockTime))
+		assert.Equal(t, 3, NextDifficulty(RetargetInterval, 3, evenTimestamps(AdjustmentInterval, DefaultTargetBlockTime), DefaultTargetBlockTime))
+	})
+
+	t.Run("per-block: 親ブロックの生成時間で毎ブロック調整する", func(t *testing.T) {
+		assert.Equal(t, 3, NextDifficulty(RetargetPerBlock, 3, evenTimestamps(1, 1), DefaultTargetBlockTime))
+		assert.Equal(t, 4, NextDifficulty(RetargetPerBlock, 3, evenTimestamps(2, 1), DefaultTargetBlockTime))
+		assert.Equal(t, 2, NextDifficulty(RetargetPerBlock, 3, evenTimestamps(2, 3*DefaultTargetBlockTime), DefaultTargetBlockTime))
+	})
+
+	t.Run("難易度の範囲に収める", func(t *testing.T) {
+		assert.Equal(t, MaxDifficulty, NextDifficulty(RetargetPerBlock, MaxDifficulty, evenTimestamps(2, 1), DefaultTargetBlockTime))
+		assert.Equal(t, MinDifficulty, NextDifficulty(RetargetPerBlock, MinDifficulty, evenTimestamps(2, 100), DefaultTargetBlockTime))
+	})
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// RFC 6979 の決定的なナンス
+// ECDSAの署名ではナンス k が漏れたり2回使われたりすると秘密鍵が計算できてしまいます
+// 乱数の代わりに秘密鍵とメッセージのハッシュから HMAC-SHA256 で k を導くことで、
+// 同じ鍵と同じデータからは常に同じ署名ができ、乱数生成器の質に依存しなくなります
+
+// nonceGenerator は RFC 6979 3.2 の HMAC_DRBG の状態です
+type nonceGenerator struct {
+	q    *big.Int // 曲線の位数
+	k, v []byte
+}
+
+// newNonceGenerator は秘密鍵とメッセージのハッシュから状態を初期化します（RFC 6979 3.2 の b〜g）
+func newNonceGenerator(privateKey *ecdsa.PrivateKey, hash []byte) *nonceGenerator {
+	q := privateKey.Curve.Params().N
+	x := int2octets(privateKey.D, q)
+	h1 := int2octets(new(big.Int).Mod(bits2int(hash, q), q), q) // bits2octets(h)
+
+	g := &nonceGenerator{
+		q: q,
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	for _, separator := range []byte{0x00, 0x01} {
+		g.k = g.mac(g.v, []byte{separator}, x, h1)
+		g.v = g.mac(g.v)
+	}
+	return g
+}
+
+// mac は現在の K を鍵としたHMAC-SHA256を計算します
+func (g *nonceGenerator) mac(parts ...[]byte) []byte {
+	m := hmac.New(sha256.New, g.k)
+	for _, part := range parts {
+		m.Write(part)
+	}
+	return m.Sum(nil)
+}
+
+// next は次の候補 k（1 <= k < q）を返します（RFC 6979 3.2 の h）
+// 呼ぶたびに状態を進めるため、r や s が0になったときは次の候補を使えます
+func (g *nonceGenerator) next() *big.Int {
+	for {
+		var t []byte
+		for len(t)*8 < g.q.BitLen() {
+			g.v = g.mac(g.v)
+			t = append(t, g.v...)
+		}
+		k := bits2int(t, g.q)
+
+		// 次の呼び出しや範囲外のときに備えて状態を進める
+		g.k = g.mac(g.v, []byte{0x00})
+		g.v = g.mac(g.v)
+		if k.Sign() > 0 && k.Cmp(g.q) < 0 {
+			return k
+		}
+	}
+}
+
+// bits2int はバイト列を整数にし、位数のビット長を超える分の下位ビットを捨てます（RFC 6979 2.3.2）
+func bits2int(data []byte, q *big.Int) *big.Int {
+	value := new(big.Int).SetBytes(data)
+	if excess := len(data)*8 - q.BitLen(); excess > 0 {
+		value.Rsh(value, uint(excess))
+	}
+	return value
+}
+
+// int2octets は整数を位数のバイト長のビッグエンディアンにします（RFC 6979 2.3.3）
+func int2octets(value, q *big.Int) []byte {
+	return value.FillBytes(make([]byte, (q.BitLen()+7)/8))
+}
+
+// signDeterministic はハッシュに RFC 6979 のナンスで署名し、r と s を返します
+func signDeterministic(privateKey *ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int, error) {
+	curve := privateKey.Curve
+	n := curve.Params().N
+	if privateKey.D == nil || privateKey.D.Sign() <= 0 || privateKey.D.Cmp(n) >= 0 {
+		return nil, nil, fmt.Errorf("invalid private key")
+	}
+
+	e := bits2int(hash, n)
+	generator := newNonceGenerator(privateKey, hash)
+	for {
+		k := generator.next()
+
+		// r = (kG).x mod n
+		x, _ := curve.ScalarBaseMult(k.Bytes())
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k^-1 (e + r*d) mod n
+		s := new(big.Int).Mul(r, privateKey.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+		return r, s, nil
+	}
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6979Key は RFC 6979 A.2.5（P-256）の秘密鍵です
+func rfc6979Key(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	d, ok := new(big.Int).SetString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721", 16)
+	require.True(t, ok)
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(d.Bytes())
+	return key
+}
+
+func TestRFC6979(t *testing.T) {
+	// RFC 6979 A.2.5 の SHA-256 の既知の答え
+	vectors := []struct {
+		message string
+		k, r, s string
+	}{
+		{
+			message: "sample",
+			k:       "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			r:       "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s:       "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			message: "test",
+			k:       "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			r:       "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s:       "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+	}
+
+	t.Run("公開鍵がテストベクタと一致する", func(t *testing.T) {
+		key := rfc6979Key(t)
+		assert.Equal(t, "60fed4ba255a9d31c961eb74c6356d68c049b8923b61fa6ce669622e60f29fb6", hex.EncodeToString(key.X.Bytes()))
+	})
+
+	for _, vector := range vectors {
+		t.Run("ナンスがテストベクタと一致する: "+vector.message, func(t *testing.T) {
+			k := newNonceGenerator(rfc6979Key(t), Hash([]byte(vector.message))).next()
+			assert.Equal(t, strings.ToLower(vector.k), hex.EncodeToString(k.Bytes()))
+		})
+
+		t.Run("署名がテストベクタと一致する: "+vector.message, func(t *testing.T) {
+			key := rfc6979Key(t)
+			r, s, err := signDeterministic(key, Hash([]byte(vector.message)))
+			require.NoError(t, err)
+			assert.Equal(t, strings.ToLower(vector.r), hex.EncodeToString(r.Bytes()))
+			assert.Equal(t, strings.ToLower(vector.s), hex.EncodeToString(int2octets(s, key.Curve.Params().N)))
+
+			// Sign は s を low-S に揃える
+			signature, err := Sign(key, []byte(vector.message))
+			require.NoError(t, err)
+			n := key.Curve.Params().N
+			if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+				s.Sub(n, s)
+			}
+			assert.Equal(t, hex.EncodeToString(append(r.Bytes(), int2octets(s, n)...)), hex.EncodeToString(signature))
+			assert.True(t, Verify(&key.PublicKey, []byte(vector.message), signature))
+		})
+	}
+
+	t.Run("同じ鍵と同じデータからは同じ署名になる", func(t *testing.T) {
+		key, err := GenerateKeyPair()
+		require.NoError(t, err)
+
+		first, err := Sign(key, []byte("transaction"))
+		require.NoError(t, err)
+		second, err := Sign(key, []byte("transaction"))
+		require.NoError(t, err)
+		other, err := Sign(key, []byte("another transaction"))
+		require.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		assert.NotEqual(t, first, other)
+	})
+
+	t.Run("不正な秘密鍵では署名しない", func(t *testing.T) {
+		key := rfc6979Key(t)
+		key.D = new(big.Int).Set(key.Curve.Params().N)
+
+		_, err := Sign(key, []byte("sample"))
+		assert.Error(t, err)
+	})
+}
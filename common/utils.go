@@ -57,33 +57,9 @@ func ValidateHash(hash string) bool {
 }
 
 // MerkleRoot はハッシュのリストからマークルルートを計算します
-// 将来的にマークルツリー実装で使用するための基本的な実装
+// 奇数個の段では最後のハッシュを自分自身と結合します（NewMerkleTree を参照）
 func MerkleRoot(hashes [][]byte) []byte {
-	if len(hashes) == 0 {
-		return Hash([]byte{})
-	}
-
-	if len(hashes) == 1 {
-		return hashes[0]
-	}
-
-	// ハッシュのペアを結合してハッシュ化
-	var newHashes [][]byte
-
-	for i := 0; i < len(hashes); i += 2 {
-		if i+1 < len(hashes) {
-			// ペアがある場合
-			combined := append(hashes[i], hashes[i+1]...)
-			newHashes = append(newHashes, Hash(combined))
-		} else {
-			// 奇数個の場合、最後のハッシュを自分自身と結合
-			combined := append(hashes[i], hashes[i]...)
-			newHashes = append(newHashes, Hash(combined))
-		}
-	}
-
-	// 再帰的にマークルルートを計算
-	return MerkleRoot(newHashes)
+	return NewMerkleTree(hashes).Root()
 }
 
 // BytesToHex はバイト列を16進数文字列に変換します
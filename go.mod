@@ -2,16 +2,21 @@ module github.com/nyasuto/minicoin
 
 go 1.24
 
-require github.com/stretchr/testify v1.11.1
+require (
+	github.com/gdamore/tcell/v2 v2.8.1
+	github.com/gorilla/websocket v1.5.3
+	github.com/rivo/tview v0.42.0
+	github.com/stretchr/testify v1.11.1
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/crypto v0.32.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gdamore/encoding v1.0.1 // indirect
-	github.com/gdamore/tcell/v2 v2.8.1 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/rivo/tview v0.42.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/term v0.28.0 // indirect
@@ -4,7 +4,6 @@ package main
 
 import (
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/nyasuto/minicoin/common"
@@ -38,14 +37,10 @@ func NewBlock(index int64, data string, previousHash string) *Block {
 // CalculateHash はブロックのSHA-256ハッシュを計算します
 // Index + Timestamp + Data + PreviousHash を結合してハッシュ化
 func (b *Block) CalculateHash() string {
-	// ブロックの内容を文字列として結合
-	record := strconv.FormatInt(b.Index, 10) +
-		strconv.FormatInt(b.Timestamp, 10) +
-		b.Data +
-		b.PreviousHash
-
-	// SHA-256ハッシュを計算して16進数文字列として返す
-	return common.HashString(record)
+	// ブロックの内容を結合し、SHA-256ハッシュを計算して16進数文字列として返す
+	// 結合の仕方は minicoin-verify と共通です（common.AppendBlockRecord）
+	record := common.AppendBlockRecord(nil, b.Index, b.Timestamp, b.Data, b.PreviousHash)
+	return common.BytesToHex(common.Hash(record))
 }
 
 // Validate はブロックの整合性を検証します
@@ -8,8 +8,9 @@ import (
 
 // Blockchain はブロックチェーン全体を管理する構造体
 type Blockchain struct {
-	Blocks []*Block     // ブロックのスライス（ジェネシスブロックから順に格納）
-	mutex  sync.RWMutex // 並行アクセス制御用のRWMutex
+	Blocks      []*Block         // ブロックのスライス（ジェネシスブロックから順に格納）
+	checkpoints map[int64]string // チェックポイント（ブロック番号 → 期待されるハッシュ）
+	mutex       sync.RWMutex     // 並行アクセス制御用のRWMutex
 }
 
 // NewBlockchain は新しいブロックチェーンを生成します
@@ -37,6 +38,11 @@ func (bc *Blockchain) AddBlock(data string) error {
 		previousBlock.Hash,
 	)
 
+	// チェックポイントに矛盾するブロックは追加しない
+	if !bc.matchesCheckpoint(newBlock) {
+		return fmt.Errorf("block %d does not match checkpoint", newBlock.Index)
+	}
+
 	// チェーンに追加
 	bc.Blocks = append(bc.Blocks, newBlock)
 
@@ -83,6 +89,7 @@ func (bc *Blockchain) GetChainLength() int {
 // 2. PreviousHashが実際に前のブロックのハッシュと一致するか
 // 3. インデックスが連続しているか
 // 4. タイムスタンプが単調増加しているか（等しいのは許容）
+// 5. チェックポイントに矛盾していないか
 func (bc *Blockchain) IsValid() bool {
 	bc.mutex.RLock()
 	defer bc.mutex.RUnlock()
@@ -103,6 +110,9 @@ func (bc *Blockchain) IsValid() bool {
 	if !genesis.Validate() {
 		return false
 	}
+	if !bc.matchesCheckpoint(genesis) {
+		return false
+	}
 
 	// 各ブロックを検証
 	for i := 1; i < len(bc.Blocks); i++ {
@@ -128,6 +138,11 @@ func (bc *Blockchain) IsValid() bool {
 		if currentBlock.Timestamp < previousBlock.Timestamp {
 			return false
 		}
+
+		// 5. チェックポイントに矛盾していないか
+		if !bc.matchesCheckpoint(currentBlock) {
+			return false
+		}
 	}
 
 	return true
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Checkpoint はブロック高さと、その高さで期待されるブロックハッシュの組です
+// チェックポイントに矛盾するチェーンは検証・インポート時に拒否されます
+type Checkpoint struct {
+	Height int64  // ブロック番号
+	Hash   string // 期待されるブロックハッシュ（16進数文字列）
+}
+
+// ParseCheckpoint は "height:hash" 形式の文字列からチェックポイントを生成します
+func ParseCheckpoint(s string) (Checkpoint, error) {
+	heightStr, hash, ok := strings.Cut(s, ":")
+	if !ok || hash == "" {
+		return Checkpoint{}, fmt.Errorf("checkpoint must be in height:hash format: %q", s)
+	}
+
+	height, err := strconv.ParseInt(heightStr, 10, 64)
+	if err != nil || height < 0 {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint height: %q", heightStr)
+	}
+
+	return Checkpoint{Height: height, Hash: hash}, nil
+}
+
+// String は "height:hash" 形式の文字列を返します
+func (c Checkpoint) String() string {
+	return fmt.Sprintf("%d:%s", c.Height, c.Hash)
+}
+
+// AddCheckpoint は実行時にチェックポイントを追加します
+// 同じ高さに異なるハッシュが登録済みの場合や、既存のブロックと矛盾する場合はエラーを返します
+func (bc *Blockchain) AddCheckpoint(height int64, hash string) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if height < 0 {
+		return fmt.Errorf("invalid checkpoint height: %d", height)
+	}
+	if hash == "" {
+		return errors.New("checkpoint hash is empty")
+	}
+
+	if existing, ok := bc.checkpoints[height]; ok && existing != hash {
+		return fmt.Errorf("conflicting checkpoint at height %d: %s", height, existing)
+	}
+
+	if height < int64(len(bc.Blocks)) && bc.Blocks[height].Hash != hash {
+		return fmt.Errorf("block %d does not match checkpoint", height)
+	}
+
+	if bc.checkpoints == nil {
+		bc.checkpoints = make(map[int64]string)
+	}
+	bc.checkpoints[height] = hash
+
+	return nil
+}
+
+// Checkpoints は登録済みのチェックポイントを高さ順に返します
+func (bc *Blockchain) Checkpoints() []Checkpoint {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	checkpoints := make([]Checkpoint, 0, len(bc.checkpoints))
+	for height, hash := range bc.checkpoints {
+		checkpoints = append(checkpoints, Checkpoint{Height: height, Hash: hash})
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Height < checkpoints[j].Height
+	})
+
+	return checkpoints
+}
+
+// matchesCheckpoint はブロックがチェックポイントと矛盾しないかを確認します
+// 呼び出し側でロックを取得していることを前提とします
+func (bc *Blockchain) matchesCheckpoint(block *Block) bool {
+	expected, ok := bc.checkpoints[block.Index]
+	return !ok || block.Hash == expected
+}
+
+// checkpointFlag は --checkpoint フラグを複数回指定するための flag.Value です
+type checkpointFlag []Checkpoint
+
+// String は flag.Value を実装します
+func (f *checkpointFlag) String() string {
+	parts := make([]string, len(*f))
+	for i, cp := range *f {
+		parts[i] = cp.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set は flag.Value を実装します
+func (f *checkpointFlag) Set(value string) error {
+	cp, err := ParseCheckpoint(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, cp)
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCheckpoint(t *testing.T) {
+	t.Run("正常な形式", func(t *testing.T) {
+		cp, err := ParseCheckpoint("3:abcdef")
+
+		require.NoError(t, err)
+		assert.Equal(t, Checkpoint{Height: 3, Hash: "abcdef"}, cp)
+		assert.Equal(t, "3:abcdef", cp.String())
+	})
+
+	t.Run("不正な形式", func(t *testing.T) {
+		for _, s := range []string{"", "3", "3:", "x:abc", "-1:abc"} {
+			_, err := ParseCheckpoint(s)
+			assert.Error(t, err, s)
+		}
+	})
+}
+
+func TestAddCheckpoint(t *testing.T) {
+	t.Run("既存ブロックと一致するチェックポイント", func(t *testing.T) {
+		bc := NewBlockchain()
+		require.NoError(t, bc.AddBlock("Block 1"))
+
+		err := bc.AddCheckpoint(1, bc.Blocks[1].Hash)
+
+		require.NoError(t, err)
+		assert.Equal(t, []Checkpoint{{Height: 1, Hash: bc.Blocks[1].Hash}}, bc.Checkpoints())
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("既存ブロックと矛盾するチェックポイントは拒否", func(t *testing.T) {
+		bc := NewBlockchain()
+
+		err := bc.AddCheckpoint(0, "deadbeef")
+
+		assert.Error(t, err)
+		assert.Empty(t, bc.Checkpoints())
+	})
+
+	t.Run("同じ高さに別のハッシュは登録できない", func(t *testing.T) {
+		bc := NewBlockchain()
+		require.NoError(t, bc.AddCheckpoint(5, "aaaa"))
+
+		assert.NoError(t, bc.AddCheckpoint(5, "aaaa"))
+		assert.Error(t, bc.AddCheckpoint(5, "bbbb"))
+	})
+
+	t.Run("高さ順に並ぶ", func(t *testing.T) {
+		bc := NewBlockchain()
+		require.NoError(t, bc.AddCheckpoint(10, "cccc"))
+		require.NoError(t, bc.AddCheckpoint(3, "bbbb"))
+		require.NoError(t, bc.AddCheckpoint(0, bc.Blocks[0].Hash))
+
+		checkpoints := bc.Checkpoints()
+
+		require.Len(t, checkpoints, 3)
+		assert.Equal(t, int64(0), checkpoints[0].Height)
+		assert.Equal(t, int64(3), checkpoints[1].Height)
+		assert.Equal(t, int64(10), checkpoints[2].Height)
+	})
+}
+
+func TestAddBlockWithCheckpoints(t *testing.T) {
+	t.Run("チェックポイントに矛盾するブロックは追加しない", func(t *testing.T) {
+		bc := NewBlockchain()
+		require.NoError(t, bc.AddCheckpoint(1, "0000expected"))
+
+		err := bc.AddBlock("Block 1")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match checkpoint")
+		assert.Equal(t, 1, bc.GetChainLength())
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("一致するチェックポイントのあるチェーンには追加できる", func(t *testing.T) {
+		bc := NewBlockchain()
+		require.NoError(t, bc.AddCheckpoint(0, bc.Blocks[0].Hash))
+
+		require.NoError(t, bc.AddBlock("Block 1"))
+		assert.Equal(t, 2, bc.GetChainLength())
+	})
+}
+
+func TestIsValidWithCheckpoints(t *testing.T) {
+	t.Run("チェックポイントに矛盾するブロックは無効", func(t *testing.T) {
+		bc := NewBlockchain()
+		require.NoError(t, bc.AddCheckpoint(1, "0000expected"))
+
+		// AddBlock を通さずに追加されたブロックも検証で見つける
+		bc.Blocks = append(bc.Blocks, NewBlock(1, "Block 1", bc.Blocks[0].Hash))
+
+		assert.False(t, bc.IsValid())
+	})
+
+	t.Run("未到達のチェックポイントは検証に影響しない", func(t *testing.T) {
+		bc := NewBlockchain()
+		require.NoError(t, bc.AddCheckpoint(100, "future"))
+
+		assert.True(t, bc.IsValid())
+	})
+}
+
+func TestImportBlockchainWithCheckpoints(t *testing.T) {
+	bc := NewBlockchain()
+	require.NoError(t, bc.AddBlock("Block 1"))
+	require.NoError(t, bc.AddBlock("Block 2"))
+
+	file := filepath.Join(t.TempDir(), "chain.json")
+	require.NoError(t, exportBlockchain(bc, file))
+
+	t.Run("一致するチェックポイントでインポート", func(t *testing.T) {
+		imported, err := importBlockchainWithCheckpoints(file, []Checkpoint{{Height: 2, Hash: bc.Blocks[2].Hash}})
+
+		require.NoError(t, err)
+		assert.Len(t, imported.Checkpoints(), 1)
+	})
+
+	t.Run("矛盾するチェックポイントでは拒否", func(t *testing.T) {
+		_, err := importBlockchainWithCheckpoints(file, []Checkpoint{{Height: 1, Hash: "other"}})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("チェーンより先のチェックポイントは保持", func(t *testing.T) {
+		imported, err := importBlockchainWithCheckpoints(file, []Checkpoint{{Height: 10, Hash: "future"}})
+
+		require.NoError(t, err)
+		assert.True(t, imported.IsValid())
+	})
+}
+
+func TestCheckpointFlag(t *testing.T) {
+	var f checkpointFlag
+
+	require.NoError(t, f.Set("1:aaaa"))
+	require.NoError(t, f.Set("2:bbbb"))
+	assert.Error(t, f.Set("bad"))
+
+	assert.Len(t, f, 2)
+	assert.Equal(t, "1:aaaa,2:bbbb", f.String())
+}
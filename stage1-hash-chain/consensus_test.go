@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consensusVectorDir はコンセンサステストベクターの配置先
+const consensusVectorDir = "testdata/consensus"
+
+// consensusVector は1件のコンセンサステストケースです
+// 書式は testdata/consensus/README.md を参照してください
+type consensusVector struct {
+	Description string   `json:"description"`
+	Blocks      []*Block `json:"blocks"`
+	Expect      struct {
+		Accept bool   `json:"accept"`
+		Tip    string `json:"tip"`
+	} `json:"expect"`
+}
+
+// loadConsensusVectors はテストベクターをファイル名ごとに読み込みます
+func loadConsensusVectors(t *testing.T) map[string]consensusVector {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(consensusVectorDir, "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "テストベクターが見つかりません")
+
+	vectors := make(map[string]consensusVector, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file) // #nosec G304 -- ファイル読み込みは教育目的のため許容
+		require.NoError(t, err)
+
+		var vector consensusVector
+		require.NoError(t, json.Unmarshal(data, &vector), file)
+		require.NotEmpty(t, vector.Blocks, file)
+
+		vectors[strings.TrimSuffix(filepath.Base(file), ".json")] = vector
+	}
+	return vectors
+}
+
+// applyConsensusVector はブロックを先頭から順に受け入れ、
+// 最初に拒否されるまでに受け入れたチェーンの先端ハッシュを返します
+func applyConsensusVector(vector consensusVector) (accepted bool, tip string) {
+	for i := range vector.Blocks {
+		bc := &Blockchain{Blocks: vector.Blocks[:i+1]}
+		if !bc.IsValid() {
+			return false, tip
+		}
+		tip = vector.Blocks[i].Hash
+	}
+	return true, tip
+}
+
+func TestConsensusVectors(t *testing.T) {
+	for name, vector := range loadConsensusVectors(t) {
+		t.Run(name, func(t *testing.T) {
+			accepted, tip := applyConsensusVector(vector)
+
+			assert.Equal(t, vector.Expect.Accept, accepted, vector.Description)
+			assert.Equal(t, vector.Expect.Tip, tip, vector.Description)
+		})
+	}
+}
@@ -18,13 +18,15 @@ func main() {
 	statsFlag := flag.Bool("stats", false, "統計情報表示のみ")
 	exportFile := flag.String("export", "", "チェーンをJSON形式でエクスポート")
 	importFile := flag.String("import", "", "JSON形式のチェーンをインポート")
+	var checkpoints checkpointFlag
+	flag.Var(&checkpoints, "checkpoint", "チェックポイントを height:hash 形式で追加（複数指定可）")
 	flag.Parse()
 
 	// ブロックチェーンの初期化
 	var bc *Blockchain
 	if *importFile != "" {
-		// インポート
-		imported, err := importBlockchain(*importFile)
+		// インポート（チェックポイントと矛盾するチェーンは拒否）
+		imported, err := importBlockchainWithCheckpoints(*importFile, checkpoints)
 		if err != nil {
 			fmt.Printf("❌ エラー: チェーンのインポートに失敗しました: %v\n", err)
 			os.Exit(1)
@@ -33,6 +35,12 @@ func main() {
 		fmt.Printf("✓ チェーンを %s からインポートしました\n", *importFile)
 	} else {
 		bc = NewBlockchain()
+		for _, cp := range checkpoints {
+			if err := bc.AddCheckpoint(cp.Height, cp.Hash); err != nil {
+				fmt.Printf("❌ エラー: チェックポイントの追加に失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+		}
 	}
 
 	// --validate フラグ: 検証のみ実行
@@ -90,10 +98,12 @@ func runInteractiveCLI(bc *Blockchain) {
 		case "5":
 			printStats(bc)
 		case "6":
+			printCheckpoints(bc)
+		case "7":
 			fmt.Println("\n👋 Minicoinをご利用いただきありがとうございました！")
 			return
 		default:
-			fmt.Println("❌ 無効な選択です。1-6の数字を入力してください。")
+			fmt.Println("❌ 無効な選択です。1-7の数字を入力してください。")
 		}
 	}
 }
@@ -116,7 +126,8 @@ func printMenu() {
 	fmt.Println("3. チェーンを検証")
 	fmt.Println("4. 特定ブロックを表示")
 	fmt.Println("5. 統計情報を表示")
-	fmt.Println("6. 終了")
+	fmt.Println("6. チェックポイントを一覧表示")
+	fmt.Println("7. 終了")
 	fmt.Println("====================================")
 }
 
@@ -238,6 +249,29 @@ func printValidationResult(bc *Blockchain) {
 	}
 }
 
+// printCheckpoints は登録済みのチェックポイントを表示します
+func printCheckpoints(bc *Blockchain) {
+	checkpoints := bc.Checkpoints()
+
+	fmt.Println("\n📍 チェックポイント一覧")
+	if len(checkpoints) == 0 {
+		fmt.Println("  (登録されていません)")
+		return
+	}
+
+	for _, cp := range checkpoints {
+		status := "⏳ 未到達"
+		if block, err := bc.GetBlock(cp.Height); err == nil {
+			if block.Hash == cp.Hash {
+				status = "✓ 一致"
+			} else {
+				status = "❌ 不一致"
+			}
+		}
+		fmt.Printf("  #%-6d %s  %s\n", cp.Height, cp.Hash, status)
+	}
+}
+
 // printStats は統計情報を表示します
 func printStats(bc *Blockchain) {
 	fmt.Println("\n╔════════════════════════════════════════════════════════╗")
@@ -285,6 +319,12 @@ func exportBlockchain(bc *Blockchain, filename string) error {
 
 // importBlockchain はJSON形式のブロックチェーンをインポートします
 func importBlockchain(filename string) (*Blockchain, error) {
+	return importBlockchainWithCheckpoints(filename, nil)
+}
+
+// importBlockchainWithCheckpoints はチェックポイントを適用してチェーンをインポートします
+// チェックポイントに矛盾するチェーンはエラーになります
+func importBlockchainWithCheckpoints(filename string, checkpoints []Checkpoint) (*Blockchain, error) {
 	// #nosec G304 -- ファイル読み込みは教育目的のため許容
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -301,6 +341,12 @@ func importBlockchain(filename string) (*Blockchain, error) {
 		Blocks: blocks,
 	}
 
+	for _, cp := range checkpoints {
+		if err := bc.AddCheckpoint(cp.Height, cp.Hash); err != nil {
+			return nil, fmt.Errorf("チェックポイント違反: %w", err)
+		}
+	}
+
 	// インポートしたチェーンの検証
 	if !bc.IsValid() {
 		return nil, fmt.Errorf("インポートされたチェーンが無効です")
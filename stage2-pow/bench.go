@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ベンチマークのデフォルト設定
+const (
+	// DefaultBenchRepetitions は難易度ごとのデフォルト繰り返し回数
+	DefaultBenchRepetitions = 5
+
+	// DefaultBenchMaxDifficulty はデフォルトで計測する最大難易度
+	DefaultBenchMaxDifficulty = 4
+)
+
+// BenchConfig はベンチマークの設定です
+type BenchConfig struct {
+	Difficulties []int  // 計測する難易度
+	Repetitions  int    // 難易度ごとの繰り返し回数
+	Data         string // ブロックデータ（繰り返しごとに連番を付加）
+	Seed         string // 指定するとナンスとタイムスタンプが決定的になり、試行回数が再現可能になる
+}
+
+// BenchResult は1つの難易度における計測結果の統計です
+type BenchResult struct {
+	Difficulty     int
+	Samples        int
+	MeanDuration   time.Duration
+	MedianDuration time.Duration
+	P95Duration    time.Duration
+	MeanAttempts   float64
+	MedianAttempts float64
+	P95Attempts    float64
+	MeanHashRate   float64
+}
+
+// RunBenchmark は各難易度で指定回数マイニングし、統計を計算します
+func RunBenchmark(config BenchConfig) ([]BenchResult, error) {
+	if config.Repetitions <= 0 {
+		return nil, fmt.Errorf("repetitions must be positive")
+	}
+	if config.Data == "" {
+		config.Data = "Benchmark Block"
+	}
+
+	results := make([]BenchResult, 0, len(config.Difficulties))
+	for _, difficulty := range config.Difficulties {
+		durations := make([]float64, 0, config.Repetitions)
+		attempts := make([]float64, 0, config.Repetitions)
+		totalHashes := 0.0
+		totalSeconds := 0.0
+
+		for i := 0; i < config.Repetitions; i++ {
+			// 繰り返しごとにデータを変えて、異なるナンス探索をさせる
+			data := fmt.Sprintf("%s #%d", config.Data, i)
+			block := NewBlock(1, data, strings.Repeat("0", 64), difficulty)
+
+			var metrics *MiningMetrics
+			var err error
+			if config.Seed != "" {
+				block.Timestamp = SeededGenesisTimestamp
+				metrics, err = MineBlockSeeded(context.Background(), block, difficulty, config.Seed)
+			} else {
+				metrics, err = MineBlock(block, difficulty)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("difficulty %d: %w", difficulty, err)
+			}
+
+			durations = append(durations, float64(metrics.Duration))
+			attempts = append(attempts, float64(metrics.AttemptsCount))
+			totalHashes += float64(metrics.AttemptsCount)
+			totalSeconds += metrics.Duration.Seconds()
+		}
+
+		result := BenchResult{
+			Difficulty:     difficulty,
+			Samples:        config.Repetitions,
+			MeanDuration:   time.Duration(mean(durations)),
+			MedianDuration: time.Duration(percentile(durations, 50)),
+			P95Duration:    time.Duration(percentile(durations, 95)),
+			MeanAttempts:   mean(attempts),
+			MedianAttempts: percentile(attempts, 50),
+			P95Attempts:    percentile(attempts, 95),
+		}
+		if totalSeconds > 0 {
+			result.MeanHashRate = totalHashes / totalSeconds
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// mean は平均値を返します
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+// percentile は最近傍順位法でパーセンタイル値を返します
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// WriteBenchCSV は計測結果をCSV形式で書き出します
+func WriteBenchCSV(w io.Writer, results []BenchResult) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{
+		"difficulty", "samples",
+		"mean_ms", "median_ms", "p95_ms",
+		"mean_attempts", "median_attempts", "p95_attempts",
+		"mean_hashrate",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		row := []string{
+			strconv.Itoa(r.Difficulty),
+			strconv.Itoa(r.Samples),
+			formatMillis(r.MeanDuration),
+			formatMillis(r.MedianDuration),
+			formatMillis(r.P95Duration),
+			strconv.FormatFloat(r.MeanAttempts, 'f', 1, 64),
+			strconv.FormatFloat(r.MedianAttempts, 'f', 0, 64),
+			strconv.FormatFloat(r.P95Attempts, 'f', 0, 64),
+			strconv.FormatFloat(r.MeanHashRate, 'f', 2, 64),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteBenchMarkdown は計測結果をMarkdownの表として書き出します
+func WriteBenchMarkdown(w io.Writer, results []BenchResult) error {
+	lines := []string{
+		"| 難易度 | 回数 | 平均時間 | 中央値 | p95 | 平均試行 | 中央値試行 | p95試行 | ハッシュレート |",
+		"|---:|---:|---:|---:|---:|---:|---:|---:|---:|",
+	}
+
+	for _, r := range results {
+		lines = append(lines, fmt.Sprintf("| %d | %d | %s ms | %s ms | %s ms | %.1f | %.0f | %.0f | %s |",
+			r.Difficulty,
+			r.Samples,
+			formatMillis(r.MeanDuration),
+			formatMillis(r.MedianDuration),
+			formatMillis(r.P95Duration),
+			r.MeanAttempts,
+			r.MedianAttempts,
+			r.P95Attempts,
+			formatHashRate(r.MeanHashRate),
+		))
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// formatMillis は時間をミリ秒の文字列に変換します
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// runBenchCommand は bench サブコマンドを実行し、終了コードを返します
+func runBenchCommand(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	repsFlag := fs.Int("reps", DefaultBenchRepetitions, "難易度ごとの繰り返し回数")
+	minFlag := fs.Int("min-difficulty", 0, "計測する最小難易度")
+	maxFlag := fs.Int("max-difficulty", DefaultBenchMaxDifficulty, "計測する最大難易度")
+	formatFlag := fs.String("format", "markdown", "レポート形式: markdown, csv")
+	outFlag := fs.String("out", "", "レポートの出力先ファイル（空なら標準出力）")
+	seedFlag := fs.String("seed", "", "再現可能なマイニングのシード（試行回数が毎回同じになる）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *minFlag < 0 || *maxFlag < *minFlag || *maxFlag > MaxDifficulty {
+		fmt.Printf("❌ 難易度の範囲が不正です: %d-%d\n", *minFlag, *maxFlag)
+		return 2
+	}
+
+	var write func(io.Writer, []BenchResult) error
+	switch *formatFlag {
+	case "markdown", "md":
+		write = WriteBenchMarkdown
+	case "csv":
+		write = WriteBenchCSV
+	default:
+		fmt.Printf("❌ 未知のレポート形式です: %s\n", *formatFlag)
+		return 2
+	}
+
+	config := BenchConfig{Repetitions: *repsFlag, Seed: *seedFlag}
+	for d := *minFlag; d <= *maxFlag; d++ {
+		config.Difficulties = append(config.Difficulties, d)
+	}
+
+	results, err := RunBenchmark(config)
+	if err != nil {
+		fmt.Printf("❌ ベンチマークエラー: %v\n", err)
+		return 1
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outFlag != "" {
+		file, err := os.Create(*outFlag)
+		if err != nil {
+			fmt.Printf("❌ ファイル作成エラー: %v\n", err)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if err := write(out, results); err != nil {
+		fmt.Printf("❌ レポート書き込みエラー: %v\n", err)
+		return 1
+	}
+
+	if *outFlag != "" {
+		fmt.Printf("✓ ベンチマークレポートを %s に書き出しました\n", *outFlag)
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBenchmark(t *testing.T) {
+	t.Run("難易度ごとに統計を計算", func(t *testing.T) {
+		results, err := RunBenchmark(BenchConfig{
+			Difficulties: []int{0, 1, 2},
+			Repetitions:  3,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		for i, r := range results {
+			assert.Equal(t, i, r.Difficulty)
+			assert.Equal(t, 3, r.Samples)
+			assert.GreaterOrEqual(t, r.MeanAttempts, 1.0)
+			assert.LessOrEqual(t, r.MedianDuration, r.P95Duration)
+			assert.LessOrEqual(t, r.MedianAttempts, r.P95Attempts)
+		}
+
+		// 難易度0は常に1回で見つかる
+		assert.Equal(t, 1.0, results[0].MeanAttempts)
+	})
+
+	t.Run("繰り返し回数が0以下ならエラー", func(t *testing.T) {
+		_, err := RunBenchmark(BenchConfig{Difficulties: []int{0}, Repetitions: 0})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+
+	tests := []struct {
+		name     string
+		p        float64
+		expected float64
+	}{
+		{"最小", 0, 1},
+		{"中央値", 50, 5},
+		{"p95", 95, 10},
+		{"最大", 100, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, percentile(values, tt.p))
+		})
+	}
+
+	t.Run("空のスライス", func(t *testing.T) {
+		assert.Equal(t, 0.0, percentile(nil, 50))
+		assert.Equal(t, 0.0, mean(nil))
+	})
+
+	t.Run("元のスライスを変更しない", func(t *testing.T) {
+		original := []float64{3, 1, 2}
+		percentile(original, 50)
+		assert.Equal(t, []float64{3, 1, 2}, original)
+	})
+}
+
+func TestWriteBenchReports(t *testing.T) {
+	results := []BenchResult{
+		{
+			Difficulty:     1,
+			Samples:        3,
+			MeanDuration:   2 * time.Millisecond,
+			MedianDuration: time.Millisecond,
+			P95Duration:    4 * time.Millisecond,
+			MeanAttempts:   16.5,
+			MedianAttempts: 15,
+			P95Attempts:    30,
+			MeanHashRate:   8000,
+		},
+	}
+
+	t.Run("CSV形式", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteBenchCSV(&buf, results))
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "difficulty", records[0][0])
+		assert.Equal(t, []string{"1", "3", "2.000", "1.000", "4.000", "16.5", "15", "30", "8000.00"}, records[1])
+	})
+
+	t.Run("Markdown形式", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteBenchMarkdown(&buf, results))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 3)
+		assert.Contains(t, lines[0], "難易度")
+		assert.Contains(t, lines[2], "| 1 | 3 | 2.000 ms |")
+		assert.Contains(t, lines[2], "8.00 KH/s")
+	})
+}
+
+func TestRunBenchCommand(t *testing.T) {
+	t.Run("CSVレポートをファイルに出力", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "bench.csv")
+
+		code := runBenchCommand([]string{"-reps", "2", "-max-difficulty", "1", "-format", "csv", "-out", out})
+
+		require.Equal(t, 0, code)
+		data, err := os.ReadFile(out)
+		require.NoError(t, err)
+		assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 3) // ヘッダー + 難易度0,1
+	})
+
+	t.Run("不正な引数", func(t *testing.T) {
+		assert.Equal(t, 2, runBenchCommand([]string{"-format", "xml"}))
+		assert.Equal(t, 2, runBenchCommand([]string{"-min-difficulty", "3", "-max-difficulty", "1"}))
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DaemonConfig はデーモンモードの設定です
+type DaemonConfig struct {
+	ExportFile string // 終了時にチェーンを書き出すファイル（空なら保存しない）
+}
+
+// DaemonSummary はデーモンのセッション結果です
+type DaemonSummary struct {
+	BlocksMined   int           // マイニングしたブロック数
+	TotalAttempts int64         // 総試行回数
+	Duration      time.Duration // 実行時間
+	Aborted       bool          // マイニング途中のブロックを破棄したか
+	ChainLength   int           // 終了時のチェーンの長さ
+	Difficulty    int           // 終了時の難易度
+	ExportFile    string        // 保存先（保存した場合）
+	ExportError   error         // 保存時のエラー
+}
+
+// Daemon は対話メニューなしで連続マイニングを行います
+type Daemon struct {
+	blockchain *Blockchain
+	config     DaemonConfig
+}
+
+// NewDaemon は新しいデーモンを作成します
+func NewDaemon(bc *Blockchain, config DaemonConfig) *Daemon {
+	return &Daemon{
+		blockchain: bc,
+		config:     config,
+	}
+}
+
+// Run はstopが閉じられるまでブロックをマイニングし続けます
+// stopが閉じられた場合はマイニング中のブロックを完了してから終了し、
+// abortCtxがキャンセルされた場合はマイニング中のブロックを破棄して直ちに終了します
+// どちらの場合も終了前にチェーンを保存します
+func (d *Daemon) Run(stop <-chan struct{}, abortCtx context.Context) *DaemonSummary {
+	summary := &DaemonSummary{}
+	startTime := time.Now()
+
+	for running := true; running; {
+		select {
+		case <-stop:
+			running = false
+			continue
+		default:
+		}
+
+		data := fmt.Sprintf("Daemon block #%d", d.blockchain.GetChainLength())
+		metrics, err := d.blockchain.AddBlockContext(abortCtx, data)
+		if err != nil {
+			if abortCtx.Err() != nil {
+				summary.Aborted = true
+				break
+			}
+			fmt.Printf("❌ マイニングエラー: %v\n", err)
+			break
+		}
+
+		summary.BlocksMined++
+		summary.TotalAttempts += metrics.AttemptsCount
+
+		latest := d.blockchain.GetLatestBlock()
+		fmt.Printf("⛏️  Block #%d mined: hash=%s nonce=%d attempts=%d time=%v\n",
+			latest.Index, latest.Hash, latest.Nonce, metrics.AttemptsCount, metrics.Duration)
+	}
+
+	summary.Duration = time.Since(startTime)
+	summary.ChainLength = d.blockchain.GetChainLength()
+	summary.Difficulty = d.blockchain.Difficulty
+
+	// 永続化のフラッシュ
+	if d.config.ExportFile != "" {
+		summary.ExportFile = d.config.ExportFile
+		summary.ExportError = exportBlockchain(d.blockchain, d.config.ExportFile)
+	}
+
+	return summary
+}
+
+// runDaemon はシグナルを監視しながらデーモンを実行します
+// 1回目のSIGINT/SIGTERMでマイニング中のブロックを完了して終了し、
+// 2回目でマイニング中のブロックを破棄して終了します
+func runDaemon(bc *Blockchain, config DaemonConfig) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	stop := make(chan struct{})
+	abortCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+
+	go func() {
+		sig := <-signals
+		fmt.Printf("\n🛑 %v を受信: マイニング中のブロックを完了して終了します（もう一度で即時中断）\n", sig)
+		close(stop)
+
+		sig = <-signals
+		fmt.Printf("\n🛑 %v を受信: マイニング中のブロックを破棄します\n", sig)
+		abort()
+	}()
+
+	fmt.Printf("🤖 デーモンモードで起動しました (難易度: %d)\n", bc.Difficulty)
+	summary := NewDaemon(bc, config).Run(stop, abortCtx)
+	printDaemonSummary(summary)
+}
+
+// printDaemonSummary はセッションの概要を表示します
+func printDaemonSummary(summary *DaemonSummary) {
+	fmt.Println("\n📋 セッション概要")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("マイニングしたブロック: %d\n", summary.BlocksMined)
+	fmt.Printf("総試行回数:             %d\n", summary.TotalAttempts)
+	fmt.Printf("実行時間:               %v\n", summary.Duration.Round(time.Millisecond))
+	if summary.Duration.Seconds() > 0 {
+		fmt.Printf("平均ハッシュレート:     %.2f hashes/sec\n", float64(summary.TotalAttempts)/summary.Duration.Seconds())
+	}
+	fmt.Printf("推定消費電力:           %s\n", GetEnergyProfile().Estimate(summary.TotalAttempts, summary.Duration))
+	fmt.Printf("チェーンの長さ:         %d\n", summary.ChainLength)
+	fmt.Printf("現在の難易度:           %d\n", summary.Difficulty)
+	if summary.Aborted {
+		fmt.Println("途中のブロック:         破棄しました")
+	}
+	if summary.ExportFile != "" {
+		if summary.ExportError != nil {
+			fmt.Printf("❌ チェーンの保存に失敗しました: %v\n", summary.ExportError)
+		} else {
+			fmt.Printf("💾 チェーンを %s に保存しました\n", summary.ExportFile)
+		}
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+}
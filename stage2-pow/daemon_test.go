@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMineBlockContext(t *testing.T) {
+	t.Run("キャンセル済みのコンテキストでは中断", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// 難易度が高く、チェック間隔内には見つからない
+		block := NewBlock(1, "Abort", "previous_hash", 10)
+		metrics, err := MineBlockContext(ctx, block, 10)
+
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, metrics)
+		assert.Empty(t, block.Hash)
+	})
+
+	t.Run("中断されたブロックはチェーンに追加されない", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.Difficulty = 10
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := bc.AddBlockContext(ctx, "Abort")
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, bc.GetChainLength())
+		assert.True(t, bc.IsValid())
+	})
+}
+
+func TestDaemonRun(t *testing.T) {
+	t.Run("停止要求でマイニング中のブロックを完了して終了", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		stop := make(chan struct{})
+
+		go func() {
+			for bc.GetChainLength() < 3 {
+				time.Sleep(time.Millisecond)
+			}
+			close(stop)
+		}()
+
+		summary := NewDaemon(bc, DaemonConfig{}).Run(stop, context.Background())
+
+		assert.GreaterOrEqual(t, summary.BlocksMined, 2)
+		assert.False(t, summary.Aborted)
+		assert.Equal(t, bc.GetChainLength(), summary.ChainLength)
+		assert.Equal(t, summary.BlocksMined+1, summary.ChainLength)
+		assert.Greater(t, summary.TotalAttempts, int64(0))
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("中断要求でマイニング中のブロックを破棄", func(t *testing.T) {
+		bc := NewBlockchain(0)
+		bc.Difficulty = 10
+		stop := make(chan struct{})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		summary := NewDaemon(bc, DaemonConfig{}).Run(stop, ctx)
+
+		assert.True(t, summary.Aborted)
+		assert.Equal(t, 0, summary.BlocksMined)
+		assert.Equal(t, 1, summary.ChainLength)
+	})
+
+	t.Run("終了時にチェーンを保存", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		stop := make(chan struct{})
+		close(stop)
+
+		exportFile := filepath.Join(t.TempDir(), "daemon.json")
+		summary := NewDaemon(bc, DaemonConfig{ExportFile: exportFile}).Run(stop, context.Background())
+
+		require.NoError(t, summary.ExportError)
+		assert.Equal(t, exportFile, summary.ExportFile)
+
+		data, err := os.ReadFile(exportFile)
+		require.NoError(t, err)
+
+		var export ChainExport
+		require.NoError(t, json.Unmarshal(data, &export))
+		assert.Len(t, export.Blocks, 1)
+		assert.Equal(t, bc.Blocks[0].Hash, export.Blocks[0].Hash)
+		assert.Equal(t, bc.Difficulty, export.Difficulty)
+	})
+}
+
+func TestPrintDaemonSummary(t *testing.T) {
+	t.Run("概要表示がパニックしない", func(t *testing.T) {
+		summary := &DaemonSummary{
+			BlocksMined:   3,
+			TotalAttempts: 1000,
+			Duration:      time.Second,
+			Aborted:       true,
+			ChainLength:   4,
+			Difficulty:    2,
+			ExportFile:    "chain.json",
+		}
+
+		assert.NotPanics(t, func() {
+			printDaemonSummary(summary)
+		})
+	})
+}
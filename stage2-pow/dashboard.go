@@ -33,8 +33,23 @@ type Dashboard struct {
 	isMining       bool
 	miningStopChan chan bool
 	miningCounter  int
+
+	// スロットル制御（+/- キーで調整）
+	throttle *MiningGovernor
 }
 
+// スロットル調整のパラメータ
+const (
+	// ThrottleDutyStep は +/- キー1回あたりの使用率の変化量
+	ThrottleDutyStep = 0.1
+
+	// MinThrottleDutyCycle は調整可能な最小使用率
+	MinThrottleDutyCycle = 0.1
+
+	// MinThrottleHashRate は調整可能な最小の目標ハッシュレート
+	MinThrottleHashRate = 100.0
+)
+
 // NewDashboard は新しいダッシュボードを作成します
 func NewDashboard(bc *Blockchain) *Dashboard {
 	app := tview.NewApplication()
@@ -46,6 +61,12 @@ func NewDashboard(bc *Blockchain) *Dashboard {
 		stopChan:       make(chan bool),
 		miningStopChan: make(chan bool),
 		isMining:       false,
+		throttle:       GetMiningGovernor(),
+	}
+
+	// 起動時にスロットルが未設定なら無制限（使用率100%）で用意する
+	if d.throttle == nil {
+		d.throttle = &MiningGovernor{dutyCycle: 1.0}
 	}
 
 	// パネルの作成
@@ -57,7 +78,7 @@ func NewDashboard(bc *Blockchain) *Dashboard {
 
 	// グリッドレイアウトの作成
 	d.grid = tview.NewGrid().
-		SetRows(8, 10, 8, 8, 3).
+		SetRows(8, 10, 10, 8, 3).
 		SetColumns(0).
 		SetBorders(false)
 
@@ -95,7 +116,7 @@ func (d *Dashboard) createHelpPanel() *tview.TextView {
 	panel := tview.NewTextView().
 		SetDynamicColors(true).
 		SetTextAlign(tview.AlignCenter).
-		SetText("[yellow]Keys:[white] [green]q[white] Quit | [green]r[white] Refresh | [green]m[white] Mining Start/Stop | [green]Ctrl+C[white] Exit")
+		SetText("[yellow]Keys:[white] [green]q[white] Quit | [green]r[white] Refresh | [green]m[white] Mining Start/Stop | [green]+/-[white] Throttle | [green]Ctrl+C[white] Exit")
 
 	panel.SetBorder(false)
 
@@ -114,6 +135,14 @@ func (d *Dashboard) handleKeyPress(event *tcell.EventKey) *tcell.EventKey {
 	case 'm', 'M':
 		d.toggleMining()
 		return nil
+	case '+', '=':
+		d.adjustThrottle(1)
+		d.update()
+		return nil
+	case '-', '_':
+		d.adjustThrottle(-1)
+		d.update()
+		return nil
 	}
 
 	// Ctrl+Cの処理
@@ -297,15 +326,29 @@ func (d *Dashboard) updateMiningPanel() {
 		miningInfo = fmt.Sprintf("\nAuto-mined:         [cyan]%d blocks[white]", d.miningCounter)
 	}
 
+	throttleStats := d.throttle.Stats()
+	throttleInfo := throttleStats.String()
+	if throttleStats.MeasuredDutyCycle > 0 {
+		throttleInfo += fmt.Sprintf(" (measured %.0f%%)", throttleStats.MeasuredDutyCycle*100)
+	}
+
+	// これまでにマイニングしたブロックの消費エネルギー（概算）
+	session := CalculateMiningStats(bc.history)
+	energy := GetEnergyProfile().Estimate(session.TotalHashes, session.TotalDuration)
+
 	content := fmt.Sprintf(
 		"[white]Mining Status:      %s"+
 			"%s\n"+
+			"Throttle:           [yellow]%s[white]\n"+
+			"Energy (est):       [yellow]%s[white]\n"+
 			"Hash Rate (est):    [cyan]%s[white]\n"+
 			"Avg Block Time:     [yellow]%.2f s[white]\n"+
 			"Target Block Time:  [green]%d s[white]\n"+
 			"Total Blocks:       [cyan]%d[white]",
 		miningStatus,
 		miningInfo,
+		throttleInfo,
+		energy,
 		hashRate,
 		avgBlockTime,
 		bc.TargetBlockTime,
@@ -347,11 +390,11 @@ func (d *Dashboard) updateDifficultyPanel() {
 		"[white]Current Difficulty: [yellow]%d[white]\n"+
 			"Next Adjustment:    [cyan]%d blocks[white]\n"+
 			"Status:             [%s]%s[white]\n"+
-			"Adjustment Interval: [cyan]%d blocks[white]",
+			"Adjustment Interval: [cyan]%d blocks (%s)[white]",
 		stats.CurrentDifficulty,
 		stats.NextAdjustment,
 		statusColor, status,
-		AdjustmentInterval,
+		stats.AdjustmentEvery, stats.RetargetMode,
 	)
 
 	d.difficultyPanel.SetText(content)
@@ -403,6 +446,7 @@ func (d *Dashboard) startMining() {
 
 	d.isMining = true
 	d.miningStopChan = make(chan bool)
+	SetMiningGovernor(d.throttle)
 	go d.miningLoop()
 }
 
@@ -416,6 +460,31 @@ func (d *Dashboard) stopMining() {
 	d.miningStopChan <- true
 }
 
+// adjustThrottle はスロットルを1段階強める（direction > 0 で速く、< 0 で遅く）
+// 目標ハッシュレートが設定されていればそれを倍/半分に、なければ使用率を増減します
+func (d *Dashboard) adjustThrottle(direction int) {
+	if hashRate := d.throttle.TargetHashRate(); hashRate > 0 {
+		if direction > 0 {
+			hashRate *= 2
+		} else {
+			hashRate /= 2
+		}
+		if hashRate < MinThrottleHashRate {
+			hashRate = MinThrottleHashRate
+		}
+		_ = d.throttle.SetTargetHashRate(hashRate)
+		return
+	}
+
+	duty := d.throttle.DutyCycle() + float64(direction)*ThrottleDutyStep
+	if duty < MinThrottleDutyCycle {
+		duty = MinThrottleDutyCycle
+	} else if duty > 1.0 {
+		duty = 1.0
+	}
+	_ = d.throttle.SetDutyCycle(duty)
+}
+
 // miningLoop はバックグラウンドでブロックをマイニングし続けます
 func (d *Dashboard) miningLoop() {
 	for d.isMining {
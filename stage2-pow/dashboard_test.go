@@ -191,3 +191,64 @@ func TestDashboardStop(t *testing.T) {
 		})
 	})
 }
+
+func TestDashboardThrottle(t *testing.T) {
+	t.Run("スロットル未設定なら無制限で開始", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		dashboard := NewDashboard(bc)
+
+		require.NotNil(t, dashboard.throttle)
+		assert.Equal(t, 1.0, dashboard.throttle.DutyCycle())
+		assert.Equal(t, "Unlimited", dashboard.throttle.Stats().String())
+	})
+
+	t.Run("使用率を+/-で調整", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		dashboard := NewDashboard(bc)
+
+		dashboard.adjustThrottle(-1)
+		assert.InDelta(t, 0.9, dashboard.throttle.DutyCycle(), 0.001)
+
+		// 下限で止まる
+		for i := 0; i < 20; i++ {
+			dashboard.adjustThrottle(-1)
+		}
+		assert.InDelta(t, MinThrottleDutyCycle, dashboard.throttle.DutyCycle(), 0.001)
+
+		// 上限で止まる
+		for i := 0; i < 20; i++ {
+			dashboard.adjustThrottle(1)
+		}
+		assert.InDelta(t, 1.0, dashboard.throttle.DutyCycle(), 0.001)
+	})
+
+	t.Run("目標ハッシュレートを倍/半分に調整", func(t *testing.T) {
+		governor, err := NewMiningGovernor(1.0)
+		require.NoError(t, err)
+		require.NoError(t, governor.SetTargetHashRate(1000))
+		SetMiningGovernor(governor)
+		defer SetMiningGovernor(nil)
+
+		dashboard := NewDashboard(NewBlockchain(1))
+		assert.Equal(t, governor, dashboard.throttle)
+
+		dashboard.adjustThrottle(1)
+		assert.Equal(t, 2000.0, dashboard.throttle.TargetHashRate())
+
+		// 下限で止まる
+		for i := 0; i < 10; i++ {
+			dashboard.adjustThrottle(-1)
+		}
+		assert.Equal(t, MinThrottleHashRate, dashboard.throttle.TargetHashRate())
+	})
+
+	t.Run("Mining Statsパネルにスロットルを表示", func(t *testing.T) {
+		dashboard := NewDashboard(NewBlockchain(1))
+		dashboard.adjustThrottle(-1)
+
+		dashboard.updateMiningPanel()
+
+		assert.Contains(t, dashboard.miningPanel.GetText(true), "Throttle:")
+		assert.Contains(t, dashboard.miningPanel.GetText(true), "90% duty")
+	})
+}
@@ -1,27 +1,61 @@
 package main
 
 import (
-	"math"
+	"fmt"
+
+	"github.com/nyasuto/minicoin/common"
 )
 
-// 難易度調整のパラメータ
+// 難易度調整のパラメータ（minicoin-verify と共通の common の値）
 const (
 	// TargetBlockTime は目標ブロック生成時間（秒）
-	TargetBlockTime = 10
+	TargetBlockTime = common.DefaultTargetBlockTime
 
 	// AdjustmentInterval は難易度調整を行うブロック間隔
-	AdjustmentInterval = 10
+	AdjustmentInterval = common.AdjustmentInterval
 
 	// MaxAdjustmentFactor は最大調整倍率（急激な変化を防ぐ）
-	MaxAdjustmentFactor = 2.0
+	MaxAdjustmentFactor = common.MaxAdjustmentFactor
 
 	// MinDifficulty は最小難易度
-	MinDifficulty = 0
+	MinDifficulty = common.MinDifficulty
 
 	// MaxDifficulty は最大難易度
-	MaxDifficulty = 10
+	MaxDifficulty = common.MaxDifficulty
+
+	// MaxPerBlockAdjustment は毎ブロック調整で一度に変化できる難易度の上限
+	MaxPerBlockAdjustment = common.MaxPerBlockAdjustment
+)
+
+// RetargetMode は難易度調整の方式です
+type RetargetMode string
+
+const (
+	// RetargetInterval はAdjustmentIntervalブロックごとに平均生成時間で調整する方式（Bitcoin風）
+	RetargetInterval RetargetMode = common.RetargetInterval
+
+	// RetargetPerBlock は親ブロックの生成時間で毎ブロック調整する方式（Ethereum風）
+	RetargetPerBlock RetargetMode = common.RetargetPerBlock
 )
 
+// ParseRetargetMode は文字列から難易度調整方式を返します
+func ParseRetargetMode(s string) (RetargetMode, error) {
+	switch RetargetMode(s) {
+	case RetargetInterval, RetargetPerBlock:
+		return RetargetMode(s), nil
+	default:
+		return "", fmt.Errorf("unknown retarget mode: %s (interval, per-block)", s)
+	}
+}
+
+// retargetInterval は調整方式ごとの調整間隔（ブロック数）を返します
+func retargetInterval(mode RetargetMode) int {
+	if mode == RetargetPerBlock {
+		return 1
+	}
+	return AdjustmentInterval
+}
+
 // GetAverageBlockTime は直近lastNBlocks個のブロックの平均生成時間を返します（秒）
 func GetAverageBlockTime(blockchain *Blockchain, lastNBlocks int) float64 {
 	if len(blockchain.Blocks) <= 1 {
@@ -51,75 +85,56 @@ func GetAverageBlockTime(blockchain *Blockchain, lastNBlocks int) float64 {
 
 // AdjustDifficulty は実際の平均時間と目標時間を比較して新しい難易度を返します
 func AdjustDifficulty(currentDifficulty int, actualTime, targetTime float64) int {
-	if actualTime == 0.0 || targetTime == 0.0 {
-		return currentDifficulty
-	}
-
-	// 調整比率を計算
-	ratio := actualTime / targetTime
+	return common.AdjustDifficulty(currentDifficulty, actualTime, targetTime)
+}
 
-	// 急激な変化を防ぐ
-	if ratio > MaxAdjustmentFactor {
-		ratio = MaxAdjustmentFactor
-	} else if ratio < 1.0/MaxAdjustmentFactor {
-		ratio = 1.0 / MaxAdjustmentFactor
-	}
+// AdjustDifficultyPerBlock は親ブロックの生成時間から新しい難易度を返します
+// Ethereum (Homestead) と同様に 1 - solveTime/targetTime を調整量とし、
+// MaxPerBlockAdjustment の範囲に制限します
+func AdjustDifficultyPerBlock(currentDifficulty int, solveTime, targetTime int64) int {
+	return common.AdjustDifficultyPerBlock(currentDifficulty, solveTime, targetTime)
+}
 
-	// 難易度を調整
-	// 実際の時間が目標より長い → 難易度を下げる（マイニングを簡単に）
-	// 実際の時間が目標より短い → 難易度を上げる（マイニングを難しく）
-	var newDifficulty int
-	if ratio > 1.0 {
-		// 時間がかかりすぎている → 難易度を下げる
-		adjustment := int(math.Ceil(math.Log2(ratio)))
-		newDifficulty = currentDifficulty - adjustment
-	} else {
-		// 時間が短すぎる → 難易度を上げる
-		adjustment := int(math.Ceil(math.Log2(1.0 / ratio)))
-		newDifficulty = currentDifficulty + adjustment
+// CalculatePerBlockDifficulty は最新ブロックとその親の生成時間から次の難易度を計算します
+func CalculatePerBlockDifficulty(blockchain *Blockchain, targetTime int) int {
+	if len(blockchain.Blocks) < 2 {
+		return blockchain.Difficulty
 	}
 
-	// 難易度の範囲を制限
-	if newDifficulty < MinDifficulty {
-		newDifficulty = MinDifficulty
-	} else if newDifficulty > MaxDifficulty {
-		newDifficulty = MaxDifficulty
-	}
+	latest := blockchain.Blocks[len(blockchain.Blocks)-1]
+	parent := blockchain.Blocks[len(blockchain.Blocks)-2]
+	solveTime := latest.Timestamp - parent.Timestamp
 
-	return newDifficulty
+	return AdjustDifficultyPerBlock(blockchain.Difficulty, solveTime, int64(targetTime))
 }
 
 // CalculateDifficulty はブロックチェーン全体から次の難易度を計算します
+// 計算は minicoin-verify が難易度の検証に使うものと共通です（common.NextDifficulty）
 func CalculateDifficulty(blockchain *Blockchain, targetTime int) int {
-	// ブロックが少ない場合は現在の難易度を維持
-	if len(blockchain.Blocks) < AdjustmentInterval {
-		return blockchain.Difficulty
+	timestamps := make([]int64, len(blockchain.Blocks))
+	for i, block := range blockchain.Blocks {
+		timestamps[i] = block.Timestamp
 	}
-
-	// 調整間隔でのみ難易度を更新
-	if len(blockchain.Blocks)%AdjustmentInterval != 0 {
-		return blockchain.Difficulty
-	}
-
-	// 直近のブロックの平均生成時間を取得
-	avgTime := GetAverageBlockTime(blockchain, AdjustmentInterval)
-
-	// 難易度を調整
-	return AdjustDifficulty(blockchain.Difficulty, avgTime, float64(targetTime))
+	return common.NextDifficulty(string(blockchain.RetargetMode), blockchain.Difficulty, timestamps, targetTime)
 }
 
 // ShouldAdjustDifficulty は難易度調整が必要かどうかを判定します
 func ShouldAdjustDifficulty(blockchain *Blockchain) bool {
+	if blockchain.RetargetMode == RetargetPerBlock {
+		return len(blockchain.Blocks) >= 2
+	}
 	return len(blockchain.Blocks) >= AdjustmentInterval &&
 		len(blockchain.Blocks)%AdjustmentInterval == 0
 }
 
 // GetDifficultyStats は難易度に関する統計情報を返します
 type DifficultyStats struct {
-	CurrentDifficulty int     // 現在の難易度
-	AverageBlockTime  float64 // 平均ブロック生成時間
-	TargetBlockTime   int     // 目標ブロック生成時間
-	NextAdjustment    int     // 次の調整までのブロック数
+	CurrentDifficulty int          // 現在の難易度
+	AverageBlockTime  float64      // 平均ブロック生成時間
+	TargetBlockTime   int          // 目標ブロック生成時間
+	NextAdjustment    int          // 次の調整までのブロック数
+	RetargetMode      RetargetMode // 難易度調整の方式
+	AdjustmentEvery   int          // 調整間隔（ブロック数）
 }
 
 // GetDifficultyStats は難易度統計を取得します
@@ -127,6 +142,11 @@ func GetDifficultyStatsFromChain(blockchain *Blockchain) *DifficultyStats {
 	stats := &DifficultyStats{
 		CurrentDifficulty: blockchain.Difficulty,
 		TargetBlockTime:   TargetBlockTime,
+		RetargetMode:      blockchain.RetargetMode,
+		AdjustmentEvery:   retargetInterval(blockchain.RetargetMode),
+	}
+	if stats.RetargetMode == "" {
+		stats.RetargetMode = RetargetInterval
 	}
 
 	// 平均ブロック生成時間を計算
@@ -135,7 +155,9 @@ func GetDifficultyStatsFromChain(blockchain *Blockchain) *DifficultyStats {
 	}
 
 	// 次の調整までのブロック数
-	if len(blockchain.Blocks) < AdjustmentInterval {
+	if blockchain.RetargetMode == RetargetPerBlock {
+		stats.NextAdjustment = 1
+	} else if len(blockchain.Blocks) < AdjustmentInterval {
 		stats.NextAdjustment = AdjustmentInterval - len(blockchain.Blocks)
 	} else {
 		stats.NextAdjustment = AdjustmentInterval - (len(blockchain.Blocks) % AdjustmentInterval)
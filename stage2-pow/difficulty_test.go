@@ -21,7 +21,7 @@ func TestGetAverageBlockTime(t *testing.T) {
 		bc.Blocks[0].Timestamp = 0
 
 		// 手動でタイムスタンプを設定
-		block1 := &Block{Index: 1, Timestamp: 10, Data: "Block 1", PreviousHash: bc.Blocks[0].Hash, Difficulty: 1}
+		block1 := &Block{Index: 1, Timestamp: 10, Entries: []string{"Block 1"}, PreviousHash: bc.Blocks[0].Hash, Difficulty: 1}
 		_, _ = MineBlock(block1, 1)
 		bc.Blocks = append(bc.Blocks, block1)
 
@@ -41,15 +41,15 @@ func TestGetAverageBlockTime(t *testing.T) {
 		// ブロック3: 30秒
 		bc.Blocks[0].Timestamp = 0
 
-		block1 := &Block{Index: 1, Timestamp: 10, Data: "Block 1", PreviousHash: bc.Blocks[0].Hash, Difficulty: 1}
+		block1 := &Block{Index: 1, Timestamp: 10, Entries: []string{"Block 1"}, PreviousHash: bc.Blocks[0].Hash, Difficulty: 1}
 		_, _ = MineBlock(block1, 1)
 		bc.Blocks = append(bc.Blocks, block1)
 
-		block2 := &Block{Index: 2, Timestamp: 20, Data: "Block 2", PreviousHash: block1.Hash, Difficulty: 1}
+		block2 := &Block{Index: 2, Timestamp: 20, Entries: []string{"Block 2"}, PreviousHash: block1.Hash, Difficulty: 1}
 		_, _ = MineBlock(block2, 1)
 		bc.Blocks = append(bc.Blocks, block2)
 
-		block3 := &Block{Index: 3, Timestamp: 30, Data: "Block 3", PreviousHash: block2.Hash, Difficulty: 1}
+		block3 := &Block{Index: 3, Timestamp: 30, Entries: []string{"Block 3"}, PreviousHash: block2.Hash, Difficulty: 1}
 		_, _ = MineBlock(block3, 1)
 		bc.Blocks = append(bc.Blocks, block3)
 
@@ -63,7 +63,7 @@ func TestGetAverageBlockTime(t *testing.T) {
 		bc := NewBlockchain(1)
 		bc.Blocks[0].Timestamp = 0
 
-		block1 := &Block{Index: 1, Timestamp: 10, Data: "Block 1", PreviousHash: bc.Blocks[0].Hash, Difficulty: 1}
+		block1 := &Block{Index: 1, Timestamp: 10, Entries: []string{"Block 1"}, PreviousHash: bc.Blocks[0].Hash, Difficulty: 1}
 		_, _ = MineBlock(block1, 1)
 		bc.Blocks = append(bc.Blocks, block1)
 
@@ -184,7 +184,7 @@ func TestCalculateDifficulty(t *testing.T) {
 			block := &Block{
 				Index:        int64(i),
 				Timestamp:    int64(i * 20), // 20秒間隔
-				Data:         "Block " + string(rune(i+'0')),
+				Entries:      []string{"Block " + string(rune(i+'0'))},
 				PreviousHash: bc.Blocks[i-1].Hash,
 				Difficulty:   2,
 			}
@@ -263,7 +263,7 @@ func TestGetDifficultyStats(t *testing.T) {
 			block := &Block{
 				Index:        int64(i),
 				Timestamp:    int64(i * 10), // 10秒間隔
-				Data:         "Block " + string(rune(i+'0')),
+				Entries:      []string{"Block " + string(rune(i+'0'))},
 				PreviousHash: bc.Blocks[i-1].Hash,
 				Difficulty:   2,
 			}
@@ -307,3 +307,83 @@ func TestGetDifficultyStats(t *testing.T) {
 		assert.Equal(t, 1, stats.NextAdjustment)
 	})
 }
+
+func TestParseRetargetMode(t *testing.T) {
+	t.Run("有効な方式", func(t *testing.T) {
+		mode, err := ParseRetargetMode("per-block")
+		require.NoError(t, err)
+		assert.Equal(t, RetargetPerBlock, mode)
+
+		mode, err = ParseRetargetMode("interval")
+		require.NoError(t, err)
+		assert.Equal(t, RetargetInterval, mode)
+	})
+
+	t.Run("未知の方式", func(t *testing.T) {
+		_, err := ParseRetargetMode("weekly")
+		assert.Error(t, err)
+	})
+}
+
+func TestAdjustDifficultyPerBlock(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   int
+		solveTime int64
+		expected  int
+	}{
+		{"目標より速い場合は上げる", 2, 3, 3},
+		{"目標ちょうどは維持", 2, 10, 2},
+		{"目標の2倍未満は維持", 2, 19, 2},
+		{"目標の2倍以上は下げる", 2, 20, 1},
+		{"大幅に遅くても1段階まで", 2, 500, 1},
+		{"最小難易度を下回らない", MinDifficulty, 500, MinDifficulty},
+		{"最大難易度を超えない", MaxDifficulty, 0, MaxDifficulty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AdjustDifficultyPerBlock(tt.current, tt.solveTime, TargetBlockTime))
+		})
+	}
+}
+
+func TestPerBlockRetargeting(t *testing.T) {
+	t.Run("親ブロックの生成時間で毎ブロック調整", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.RetargetMode = RetargetPerBlock
+		bc.Blocks[0].Timestamp = 0
+
+		block := &Block{
+			Index:        1,
+			Timestamp:    2, // 目標10秒に対して2秒
+			Entries:      []string{"Block 1"},
+			PreviousHash: bc.Blocks[0].Hash,
+			Difficulty:   1,
+		}
+		_, _ = MineBlock(block, 1)
+		bc.Blocks = append(bc.Blocks, block)
+
+		assert.True(t, ShouldAdjustDifficulty(bc))
+		assert.Equal(t, 2, CalculateDifficulty(bc, TargetBlockTime))
+	})
+
+	t.Run("ジェネシスブロックのみでは調整しない", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.RetargetMode = RetargetPerBlock
+
+		assert.False(t, ShouldAdjustDifficulty(bc))
+		assert.Equal(t, 1, CalculateDifficulty(bc, TargetBlockTime))
+	})
+
+	t.Run("統計情報は毎ブロック調整を示す", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.RetargetMode = RetargetPerBlock
+
+		stats := GetDifficultyStatsFromChain(bc)
+
+		assert.Equal(t, RetargetPerBlock, stats.RetargetMode)
+		assert.Equal(t, 1, stats.AdjustmentEvery)
+		assert.Equal(t, 1, stats.NextAdjustment)
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// JoulesPerKWh は1kWhあたりのジュール数
+const JoulesPerKWh = 3.6e6
+
+// EnergyProfile はマイニング機器の消費電力と電力の前提条件です
+// 消費エネルギーは「ハッシュ1回あたりのエネルギー × 試行回数 + 待機電力 × 経過時間」で概算します
+type EnergyProfile struct {
+	Name          string  // プロファイル名
+	JoulesPerHash float64 // ハッシュ1回あたりの消費エネルギー（J/hash = W/(hash/s)）
+	BaseWatts     float64 // マイニング中の待機電力（W）
+	CO2PerKWh     float64 // 1kWhあたりのCO2排出量（g）
+	CostPerKWh    float64 // 1kWhあたりの電気料金
+	Currency      string  // 電気料金の通貨
+}
+
+// EnergyEstimate は消費エネルギーの概算結果です
+type EnergyEstimate struct {
+	Joules   float64 // 消費エネルギー（J）
+	KWh      float64 // 消費電力量（kWh）
+	CO2Grams float64 // CO2排出量（g）
+	Cost     float64 // 電気料金
+	Currency string  // 電気料金の通貨
+}
+
+// 日本の平均的な電力の前提（CO2排出係数と電気料金）
+const (
+	defaultCO2PerKWh  = 450.0
+	defaultCostPerKWh = 31.0
+	defaultCurrency   = "JPY"
+)
+
+// EnergyProfiles は組み込みのプロファイルです
+// 値は教育目的のおおよその目安です
+var EnergyProfiles = map[string]EnergyProfile{
+	// ノートPCのCPUでこの実装を動かした場合（約15Wで1.5MH/s程度）
+	"cpu": {Name: "cpu", JoulesPerHash: 1e-5, BaseWatts: 5, CO2PerKWh: defaultCO2PerKWh, CostPerKWh: defaultCostPerKWh, Currency: defaultCurrency},
+	// ゲーミングGPU（約200Wで1GH/s程度）
+	"gpu": {Name: "gpu", JoulesPerHash: 2e-7, BaseWatts: 30, CO2PerKWh: defaultCO2PerKWh, CostPerKWh: defaultCostPerKWh, Currency: defaultCurrency},
+	// SHA-256 ASIC（約30J/TH）
+	"asic": {Name: "asic", JoulesPerHash: 3e-11, BaseWatts: 100, CO2PerKWh: defaultCO2PerKWh, CostPerKWh: defaultCostPerKWh, Currency: defaultCurrency},
+}
+
+// DefaultEnergyProfileName はデフォルトのプロファイル名
+const DefaultEnergyProfileName = "cpu"
+
+// energyProfile はマイニング出力やダッシュボードが参照する現在のプロファイル
+var energyProfile atomic.Pointer[EnergyProfile]
+
+// SetEnergyProfile は現在のプロファイルを設定します
+func SetEnergyProfile(profile EnergyProfile) {
+	energyProfile.Store(&profile)
+}
+
+// GetEnergyProfile は現在のプロファイルを返します（未設定ならデフォルト）
+func GetEnergyProfile() EnergyProfile {
+	if profile := energyProfile.Load(); profile != nil {
+		return *profile
+	}
+	return EnergyProfiles[DefaultEnergyProfileName]
+}
+
+// LookupEnergyProfile は名前から組み込みのプロファイルを返します
+func LookupEnergyProfile(name string) (EnergyProfile, error) {
+	profile, ok := EnergyProfiles[name]
+	if !ok {
+		names := make([]string, 0, len(EnergyProfiles))
+		for n := range EnergyProfiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return EnergyProfile{}, fmt.Errorf("unknown energy profile: %s (%s)", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// Estimate は試行回数と経過時間から消費エネルギーを概算します
+func (p EnergyProfile) Estimate(attempts int64, duration time.Duration) EnergyEstimate {
+	joules := float64(attempts)*p.JoulesPerHash + p.BaseWatts*duration.Seconds()
+	kwh := joules / JoulesPerKWh
+
+	return EnergyEstimate{
+		Joules:   joules,
+		KWh:      kwh,
+		CO2Grams: kwh * p.CO2PerKWh,
+		Cost:     kwh * p.CostPerKWh,
+		Currency: p.Currency,
+	}
+}
+
+// EstimateMiningEnergy はマイニング結果の消費エネルギーを現在のプロファイルで概算します
+func EstimateMiningEnergy(metrics *MiningMetrics) EnergyEstimate {
+	return GetEnergyProfile().Estimate(metrics.AttemptsCount, metrics.Duration)
+}
+
+// String は概算結果を人間が読みやすい形式で返します
+func (e EnergyEstimate) String() string {
+	return fmt.Sprintf("%s, CO2 %s, %.6f %s", formatJoules(e.Joules), formatGrams(e.CO2Grams), e.Cost, e.Currency)
+}
+
+// formatJoules はエネルギーを適切な単位で表示します
+func formatJoules(j float64) string {
+	switch {
+	case j >= 1e6:
+		return fmt.Sprintf("%.2f MJ", j/1e6)
+	case j >= 1e3:
+		return fmt.Sprintf("%.2f kJ", j/1e3)
+	case j >= 1:
+		return fmt.Sprintf("%.2f J", j)
+	default:
+		return fmt.Sprintf("%.2f mJ", j*1e3)
+	}
+}
+
+// formatGrams は重さを適切な単位で表示します
+func formatGrams(g float64) string {
+	switch {
+	case g >= 1e3:
+		return fmt.Sprintf("%.2f kg", g/1e3)
+	case g >= 1:
+		return fmt.Sprintf("%.2f g", g)
+	default:
+		return fmt.Sprintf("%.3f mg", g*1e3)
+	}
+}
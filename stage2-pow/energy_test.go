@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnergyProfileEstimate(t *testing.T) {
+	profile := EnergyProfile{
+		JoulesPerHash: 0.001,
+		BaseWatts:     10,
+		CO2PerKWh:     500,
+		CostPerKWh:    30,
+		Currency:      "JPY",
+	}
+
+	t.Run("試行回数と経過時間から概算", func(t *testing.T) {
+		// 1,000,000回 × 0.001J + 10W × 260s = 1000J + 2600J = 3600J = 0.001kWh
+		estimate := profile.Estimate(1_000_000, 260*time.Second)
+
+		assert.InDelta(t, 3600.0, estimate.Joules, 1e-9)
+		assert.InDelta(t, 0.001, estimate.KWh, 1e-12)
+		assert.InDelta(t, 0.5, estimate.CO2Grams, 1e-9)
+		assert.InDelta(t, 0.03, estimate.Cost, 1e-9)
+		assert.Equal(t, "JPY", estimate.Currency)
+	})
+
+	t.Run("何もしなければゼロ", func(t *testing.T) {
+		estimate := profile.Estimate(0, 0)
+
+		assert.Equal(t, 0.0, estimate.Joules)
+		assert.Equal(t, 0.0, estimate.Cost)
+	})
+}
+
+func TestLookupEnergyProfile(t *testing.T) {
+	t.Run("組み込みプロファイル", func(t *testing.T) {
+		cpu, err := LookupEnergyProfile("cpu")
+		require.NoError(t, err)
+		asic, err := LookupEnergyProfile("asic")
+		require.NoError(t, err)
+
+		// ASICはハッシュあたりの効率が桁違いに良い
+		assert.Less(t, asic.JoulesPerHash*1000, cpu.JoulesPerHash)
+	})
+
+	t.Run("未知のプロファイル", func(t *testing.T) {
+		_, err := LookupEnergyProfile("quantum")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "asic, cpu, gpu")
+	})
+}
+
+func TestEnergyProfileSetting(t *testing.T) {
+	defer SetEnergyProfile(EnergyProfiles[DefaultEnergyProfileName])
+
+	SetEnergyProfile(EnergyProfile{Name: "custom", JoulesPerHash: 1})
+
+	estimate := EstimateMiningEnergy(&MiningMetrics{AttemptsCount: 5})
+
+	assert.Equal(t, "custom", GetEnergyProfile().Name)
+	assert.Equal(t, 5.0, estimate.Joules)
+}
+
+func TestEnergyEstimateString(t *testing.T) {
+	estimate := EnergyEstimate{Joules: 2500, CO2Grams: 0.0005, Cost: 0.01, Currency: "JPY"}
+
+	assert.Equal(t, "2.50 kJ, CO2 0.500 mg, 0.010000 JPY", estimate.String())
+	assert.Equal(t, "12.00 mJ", formatJoules(0.012))
+	assert.Equal(t, "1.50 kg", formatGrams(1500))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// チェーンイベントの種類
+const (
+	EventBlockMined       = "block"      // ブロックがマイニングされた
+	EventDifficultyChange = "difficulty" // 難易度が調整された
+)
+
+// DefaultEventBuffer は購読者ごとのイベントバッファのサイズ
+const DefaultEventBuffer = 64
+
+// ChainEvent はチェーンで発生したイベントです（JSONで配信されます）
+type ChainEvent struct {
+	Type          string `json:"type"`
+	Height        int64  `json:"height"`
+	Difficulty    int    `json:"difficulty"`
+	OldDifficulty int    `json:"old_difficulty,omitempty"`
+	Block         *Block `json:"block,omitempty"`
+	Time          int64  `json:"time"`
+}
+
+// EventBus はチェーンイベントを購読者に配信します
+// 受信が追いつかない購読者へのイベントは破棄され、マイニングを止めることはありません
+type EventBus struct {
+	subscribers map[chan ChainEvent]struct{}
+	mutex       sync.Mutex
+}
+
+// Subscribe はイベントの購読を開始し、受信チャネルと購読解除関数を返します
+func (b *EventBus) Subscribe(buffer int) (<-chan ChainEvent, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan ChainEvent]struct{})
+	}
+	ch := make(chan ChainEvent, buffer)
+	b.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			delete(b.subscribers, ch)
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish はすべての購読者にイベントを配信します
+func (b *EventBus) Publish(event ChainEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// 受信が追いつかない購読者には配信しない
+		}
+	}
+}
+
+// SubscriberCount は現在の購読者数を返します
+func (b *EventBus) SubscriberCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.subscribers)
+}
+
+// Subscribe はチェーンイベントの購読を開始します
+func (bc *Blockchain) Subscribe() (<-chan ChainEvent, func()) {
+	return bc.events.Subscribe(DefaultEventBuffer)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	t.Run("購読者にイベントが配信される", func(t *testing.T) {
+		var bus EventBus
+		events, unsubscribe := bus.Subscribe(1)
+		defer unsubscribe()
+
+		bus.Publish(ChainEvent{Type: EventBlockMined, Height: 1})
+
+		event := <-events
+		assert.Equal(t, EventBlockMined, event.Type)
+		assert.Equal(t, int64(1), event.Height)
+		assert.NotZero(t, event.Time)
+	})
+
+	t.Run("バッファが満杯の購読者へのイベントは破棄される", func(t *testing.T) {
+		var bus EventBus
+		events, unsubscribe := bus.Subscribe(1)
+		defer unsubscribe()
+
+		bus.Publish(ChainEvent{Type: EventBlockMined, Height: 1})
+		bus.Publish(ChainEvent{Type: EventBlockMined, Height: 2})
+
+		assert.Equal(t, int64(1), (<-events).Height)
+		assert.Empty(t, events)
+	})
+
+	t.Run("購読解除でチャネルが閉じられる", func(t *testing.T) {
+		var bus EventBus
+		events, unsubscribe := bus.Subscribe(1)
+		require.Equal(t, 1, bus.SubscriberCount())
+
+		unsubscribe()
+		unsubscribe() // 2回呼んでも安全
+
+		_, ok := <-events
+		assert.False(t, ok)
+		assert.Equal(t, 0, bus.SubscriberCount())
+	})
+}
+
+func TestBlockchainEvents(t *testing.T) {
+	t.Run("ブロック追加でblockイベントが発行される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		events, unsubscribe := bc.Subscribe()
+		defer unsubscribe()
+
+		_, err := bc.AddBlock("Block 1")
+		require.NoError(t, err)
+		block := bc.GetLatestBlock()
+
+		event := <-events
+		assert.Equal(t, EventBlockMined, event.Type)
+		assert.Equal(t, block.Index, event.Height)
+		assert.Equal(t, block.Hash, event.Block.Hash)
+	})
+
+	t.Run("難易度が変わるとdifficultyイベントが発行される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.RetargetMode = RetargetPerBlock
+		bc.TargetBlockTime = 1000 // 即座に採掘されるため難易度が上がる
+		events, unsubscribe := bc.Subscribe()
+		defer unsubscribe()
+
+		_, err := bc.AddBlock("Block 1")
+		require.NoError(t, err)
+
+		assert.Equal(t, EventBlockMined, (<-events).Type)
+		event := <-events
+		assert.Equal(t, EventDifficultyChange, event.Type)
+		assert.Equal(t, 1, event.OldDifficulty)
+		assert.Equal(t, bc.Difficulty, event.Difficulty)
+	})
+}
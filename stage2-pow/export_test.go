@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainExport(t *testing.T) {
+	t.Run("ブロックと難易度の状態を往復できる", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.RetargetMode = RetargetPerBlock
+		_, err := bc.AddBlock("Block 1")
+		require.NoError(t, err)
+		bc.Difficulty = 2
+
+		data, err := json.Marshal(bc.Export())
+		require.NoError(t, err)
+
+		imported, err := ParseChainExport(data)
+		require.NoError(t, err)
+		assert.Equal(t, bc.GetChainLength(), imported.GetChainLength())
+		assert.Equal(t, bc.GetLatestBlock().Hash, imported.GetLatestBlock().Hash)
+		assert.Equal(t, 2, imported.Difficulty)
+		assert.Equal(t, RetargetPerBlock, imported.RetargetMode)
+		assert.Equal(t, TargetBlockTime, imported.TargetBlockTime)
+		assert.True(t, imported.IsValid())
+	})
+
+	t.Run("状態を持たないブロック配列は先端の難易度を引き継ぐ", func(t *testing.T) {
+		bc := NewBlockchain(2)
+		_, err := bc.AddBlock("Block 1")
+		require.NoError(t, err)
+
+		data, err := json.Marshal(bc.Blocks)
+		require.NoError(t, err)
+
+		imported, err := ParseChainExport(data)
+		require.NoError(t, err)
+		assert.Equal(t, 2, imported.Difficulty)
+		assert.Equal(t, RetargetInterval, imported.RetargetMode)
+	})
+
+	t.Run("PoWを満たさないブロックは拒否される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		for i := 0; i < 3; i++ {
+			_, err := bc.AddBlock("Block")
+			require.NoError(t, err)
+		}
+		export := bc.Export()
+		tampered := *export.Blocks[2]
+		tampered.Nonce++
+		export.Blocks[2] = &tampered
+
+		data, err := json.Marshal(export)
+		require.NoError(t, err)
+
+		_, err = ParseChainExport(data)
+		assert.ErrorContains(t, err, "block 2: invalid proof of work")
+	})
+
+	t.Run("不正な難易度調整方式は拒否される", func(t *testing.T) {
+		export := NewBlockchain(1).Export()
+		export.RetargetMode = "weekly"
+		data, err := json.Marshal(export)
+		require.NoError(t, err)
+
+		_, err = ParseChainExport(data)
+		assert.Error(t, err)
+	})
+
+	t.Run("空のチェーンは拒否される", func(t *testing.T) {
+		_, err := ParseChainExport([]byte(`{"blocks": []}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestImportBlockchain(t *testing.T) {
+	t.Run("エクスポートしたファイルをインポートしてマイニングを再開できる", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		_, err := bc.AddBlock("Block 1")
+		require.NoError(t, err)
+
+		filename := filepath.Join(t.TempDir(), "chain.json")
+		require.NoError(t, exportBlockchain(bc, filename))
+
+		imported, err := importBlockchain(filename)
+		require.NoError(t, err)
+
+		_, err = imported.AddBlock("Block 2")
+		require.NoError(t, err)
+		assert.Equal(t, 3, imported.GetChainLength())
+		assert.Equal(t, bc.GetLatestBlock().Hash, imported.Blocks[1].Hash)
+		assert.True(t, imported.IsValid())
+	})
+
+	t.Run("シード付きのチェーンは再開しても同じブロックになる", func(t *testing.T) {
+		full, err := NewSeededBlockchain(1, PoWSHA256, "resume")
+		require.NoError(t, err)
+		partial, err := NewSeededBlockchain(1, PoWSHA256, "resume")
+		require.NoError(t, err)
+
+		for i := 1; i <= 3; i++ {
+			_, err := full.AddBlock("Block")
+			require.NoError(t, err)
+		}
+		_, err = partial.AddBlock("Block")
+		require.NoError(t, err)
+
+		filename := filepath.Join(t.TempDir(), "seeded.json")
+		require.NoError(t, exportBlockchain(partial, filename))
+		resumed, err := importBlockchain(filename)
+		require.NoError(t, err)
+		assert.Equal(t, "resume", resumed.Seed)
+
+		for i := 2; i <= 3; i++ {
+			_, err := resumed.AddBlock("Block")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, full.GetLatestBlock().Hash, resumed.GetLatestBlock().Hash)
+	})
+
+	t.Run("存在しないファイルはエラー", func(t *testing.T) {
+		_, err := importBlockchain(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("JSONでないファイルはエラー", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "broken.json")
+		require.NoError(t, os.WriteFile(filename, []byte("not json"), 0600))
+
+		_, err := importBlockchain(filename)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// フォークデモのデフォルト設定
+const (
+	DefaultForkBaseBlocks = 2 // 分岐前の共通ブロック数（ジェネシスを除く）
+	DefaultForkBranchA    = 3 // 枝Aの長さ
+	DefaultForkBranchB    = 2 // 枝Bの長さ
+)
+
+// BlockWork はブロック1つに含まれる期待仕事量（必要なハッシュ計算回数の期待値）を返します
+// 先頭difficulty桁の16進数がゼロになる確率は 1/16^difficulty です
+func BlockWork(difficulty int) float64 {
+	return math.Pow(16, float64(difficulty))
+}
+
+// TreeNode はブロックツリーの1ブロックです
+type TreeNode struct {
+	Block          *Block
+	Parent         *TreeNode
+	Children       []*TreeNode
+	CumulativeWork float64 // ジェネシスからこのブロックまでの累積仕事量
+}
+
+// BlockTree は競合するブロックをすべて保持するツリーです
+// 1本のチェーンと違い、同じ親を持つ複数のブロック（フォーク）を記録できます
+type BlockTree struct {
+	Root  *TreeNode
+	nodes map[string]*TreeNode
+}
+
+// NewBlockTree はジェネシスブロックを根とするツリーを作成します
+func NewBlockTree(genesis *Block) (*BlockTree, error) {
+	if genesis.Index != 0 || genesis.PreviousHash != "" {
+		return nil, fmt.Errorf("invalid genesis block")
+	}
+	if !ValidateProofOfWork(genesis) {
+		return nil, fmt.Errorf("block 0: invalid proof of work")
+	}
+
+	root := &TreeNode{Block: genesis, CumulativeWork: BlockWork(genesis.Difficulty)}
+	return &BlockTree{
+		Root:  root,
+		nodes: map[string]*TreeNode{genesis.Hash: root},
+	}, nil
+}
+
+// AddBlock は既知の親を持つブロックをツリーに追加します
+func (t *BlockTree) AddBlock(block *Block) (*TreeNode, error) {
+	parent, ok := t.nodes[block.PreviousHash]
+	if !ok {
+		return nil, fmt.Errorf("block %d: unknown parent %s", block.Index, block.PreviousHash)
+	}
+	if _, exists := t.nodes[block.Hash]; exists {
+		return nil, fmt.Errorf("block %d: already in tree", block.Index)
+	}
+	if block.Index != parent.Block.Index+1 {
+		return nil, fmt.Errorf("block %d: index does not follow parent %d", block.Index, parent.Block.Index)
+	}
+	if !ValidateProofOfWork(block) {
+		return nil, fmt.Errorf("block %d: invalid proof of work", block.Index)
+	}
+
+	node := &TreeNode{
+		Block:          block,
+		Parent:         parent,
+		CumulativeWork: parent.CumulativeWork + BlockWork(block.Difficulty),
+	}
+	parent.Children = append(parent.Children, node)
+	t.nodes[block.Hash] = node
+	return node, nil
+}
+
+// Get はハッシュからノードを返します
+func (t *BlockTree) Get(hash string) (*TreeNode, bool) {
+	node, ok := t.nodes[hash]
+	return node, ok
+}
+
+// Tips は子を持たないノード（各枝の先端）を累積仕事量の多い順に返します
+func (t *BlockTree) Tips() []*TreeNode {
+	var tips []*TreeNode
+	for _, node := range t.nodes {
+		if len(node.Children) == 0 {
+			tips = append(tips, node)
+		}
+	}
+	sort.Slice(tips, func(i, j int) bool {
+		if tips[i].CumulativeWork != tips[j].CumulativeWork {
+			return tips[i].CumulativeWork > tips[j].CumulativeWork
+		}
+		return tips[i].Block.Hash < tips[j].Block.Hash
+	})
+	return tips
+}
+
+// BestTip は累積仕事量が最も多い先端を返します
+func (t *BlockTree) BestTip() *TreeNode {
+	return t.Tips()[0]
+}
+
+// Branch はジェネシスからnodeまでのブロックを順に返します
+func (n *TreeNode) Branch() []*Block {
+	var blocks []*Block
+	for node := n; node != nil; node = node.Parent {
+		blocks = append(blocks, node.Block)
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	return blocks
+}
+
+// ForkConfig はフォークデモの設定です
+type ForkConfig struct {
+	BaseBlocks  int // 分岐前の共通ブロック数（ジェネシスを除く）
+	BranchA     int // 枝Aのブロック数（競合ブロックを含む）
+	BranchB     int // 枝Bのブロック数（競合ブロックを含む）
+	Difficulty  int // 共通部分と枝Aの難易度
+	DifficultyB int // 枝Bの難易度（仕事量と長さの違いを比べるため）
+}
+
+// ForkDemo はフォークデモの結果です
+type ForkDemo struct {
+	Tree   *BlockTree
+	Parent *TreeNode // 2つの枝の共通の親
+	TipA   *TreeNode
+	TipB   *TreeNode
+}
+
+// RunForkDemo は同じ親の上に競合する2つのブロックをマイニングし、それぞれの枝を伸ばします
+func RunForkDemo(config ForkConfig) (*ForkDemo, error) {
+	if config.BaseBlocks < 0 || config.BranchA < 1 || config.BranchB < 1 {
+		return nil, fmt.Errorf("each branch must have at least one block")
+	}
+	if config.Difficulty < 0 || config.DifficultyB < 0 {
+		return nil, fmt.Errorf("difficulty must be non-negative")
+	}
+
+	genesis := NewGenesisBlock(config.Difficulty)
+	tree, err := NewBlockTree(genesis)
+	if err != nil {
+		return nil, err
+	}
+
+	// 共通部分
+	parent := tree.Root
+	for i := 0; i < config.BaseBlocks; i++ {
+		if parent, err = mineOnto(tree, parent, "Base", config.Difficulty); err != nil {
+			return nil, err
+		}
+	}
+
+	// 同じ親の上に競合するブロックをマイニングして、それぞれの枝を伸ばす
+	tipA, tipB := parent, parent
+	for i := 0; i < config.BranchA; i++ {
+		if tipA, err = mineOnto(tree, tipA, "Branch A", config.Difficulty); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < config.BranchB; i++ {
+		if tipB, err = mineOnto(tree, tipB, "Branch B", config.DifficultyB); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ForkDemo{Tree: tree, Parent: parent, TipA: tipA, TipB: tipB}, nil
+}
+
+// mineOnto は親ノードの上にブロックをマイニングしてツリーに追加します
+func mineOnto(tree *BlockTree, parent *TreeNode, label string, difficulty int) (*TreeNode, error) {
+	index := parent.Block.Index + 1
+	block := NewBlock(index, fmt.Sprintf("%s #%d", label, index), parent.Block.Hash, difficulty)
+	if _, err := MineBlock(block, difficulty); err != nil {
+		return nil, err
+	}
+	return tree.AddBlock(block)
+}
+
+// WriteBlockTree はツリーをASCIIアートで書き出します
+// 各枝の先端には累積仕事量を、最も仕事量の多い先端には印を付けます
+func WriteBlockTree(w io.Writer, tree *BlockTree) {
+	best := tree.BestTip()
+	writeTreeNode(w, tree.Root, "", "", best)
+}
+
+// writeTreeNode はノードとその子孫を再帰的に書き出します
+func writeTreeNode(w io.Writer, node *TreeNode, prefix, childPrefix string, best *TreeNode) {
+	line := fmt.Sprintf("%s#%d %s (難易度 %d) %s", prefix, node.Block.Index, shortHash(node.Block.Hash), node.Block.Difficulty, node.Block.Data())
+	if len(node.Children) == 0 {
+		line += fmt.Sprintf("  ← 先端 累積仕事量 %.0f", node.CumulativeWork)
+		if node == best {
+			line += " ⭐"
+		}
+	}
+	_, _ = fmt.Fprintln(w, line)
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			writeTreeNode(w, child, childPrefix+"└─ ", childPrefix+"   ", best)
+		} else {
+			writeTreeNode(w, child, childPrefix+"├─ ", childPrefix+"│  ", best)
+		}
+	}
+}
+
+// shortHash はハッシュの先頭を表示用に切り出します
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12] + "…"
+	}
+	return hash
+}
+
+// printForkDemo はフォークデモの結果を表示します
+func printForkDemo(w io.Writer, demo *ForkDemo) {
+	_, _ = fmt.Fprintln(w, "\n🌳 ブロックツリー")
+	_, _ = fmt.Fprintln(w, strings.Repeat("─", 72))
+	WriteBlockTree(w, demo.Tree)
+	_, _ = fmt.Fprintln(w, strings.Repeat("─", 72))
+
+	_, _ = fmt.Fprintf(w, "分岐点: ブロック #%d %s\n", demo.Parent.Block.Index, shortHash(demo.Parent.Block.Hash))
+	for _, branch := range []struct {
+		name string
+		tip  *TreeNode
+	}{{"A", demo.TipA}, {"B", demo.TipB}} {
+		length := branch.tip.Block.Index - demo.Parent.Block.Index
+		_, _ = fmt.Fprintf(w, "枝%s: 高さ %d（分岐後 %d ブロック）, 累積仕事量 %.0f\n",
+			branch.name, branch.tip.Block.Index, length, branch.tip.CumulativeWork)
+	}
+
+	best := demo.Tree.BestTip()
+	winner := "A"
+	if best == demo.TipB {
+		winner = "B"
+	}
+	_, _ = fmt.Fprintf(w, "\n⭐ 累積仕事量が最も多いのは枝%sです（ブロック数ではなく仕事量で比べます）\n", winner)
+	_, _ = fmt.Fprintln(w, "   ノード間でこの選択を行うフォーク解決はステージ4で扱います")
+}
+
+// runForkCommand は fork サブコマンドを実行します
+func runForkCommand(args []string) int {
+	fs := flag.NewFlagSet("fork", flag.ContinueOnError)
+	baseFlag := fs.Int("base", DefaultForkBaseBlocks, "分岐前の共通ブロック数")
+	branchAFlag := fs.Int("a", DefaultForkBranchA, "枝Aのブロック数")
+	branchBFlag := fs.Int("b", DefaultForkBranchB, "枝Bのブロック数")
+	difficultyFlag := fs.Int("difficulty", 2, "共通部分と枝Aの難易度")
+	difficultyBFlag := fs.Int("difficulty-b", -1, "枝Bの難易度（省略時は -difficulty と同じ）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *difficultyFlag > MaxDifficulty || *difficultyBFlag > MaxDifficulty {
+		fmt.Printf("❌ 難易度は %d 以下にしてください\n", MaxDifficulty)
+		return 2
+	}
+	difficultyB := *difficultyBFlag
+	if difficultyB < 0 {
+		difficultyB = *difficultyFlag
+	}
+
+	fmt.Printf("⛏️  同じ親の上に競合する2つの枝をマイニングしています (A: %d ブロック, B: %d ブロック)...\n", *branchAFlag, *branchBFlag)
+	demo, err := RunForkDemo(ForkConfig{
+		BaseBlocks:  *baseFlag,
+		BranchA:     *branchAFlag,
+		BranchB:     *branchBFlag,
+		Difficulty:  *difficultyFlag,
+		DifficultyB: difficultyB,
+	})
+	if err != nil {
+		fmt.Printf("❌ エラー: %v\n", err)
+		return 1
+	}
+
+	printForkDemo(os.Stdout, demo)
+	return 0
+}
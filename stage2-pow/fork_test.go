@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockWork(t *testing.T) {
+	t.Run("難易度が1上がると仕事量は16倍", func(t *testing.T) {
+		assert.Equal(t, 1.0, BlockWork(0))
+		assert.Equal(t, 16.0, BlockWork(1))
+		assert.Equal(t, 4096.0, BlockWork(3))
+	})
+}
+
+func TestBlockTree(t *testing.T) {
+	t.Run("同じ親に2つのブロックを保持できる", func(t *testing.T) {
+		genesis := NewGenesisBlock(1)
+		tree, err := NewBlockTree(genesis)
+		require.NoError(t, err)
+
+		a, err := mineOnto(tree, tree.Root, "A", 1)
+		require.NoError(t, err)
+		b, err := mineOnto(tree, tree.Root, "B", 2)
+		require.NoError(t, err)
+
+		assert.Len(t, tree.Root.Children, 2)
+		assert.Equal(t, a.Block.Index, b.Block.Index)
+		assert.Len(t, tree.Tips(), 2)
+		assert.Equal(t, BlockWork(1)+BlockWork(1), a.CumulativeWork)
+		assert.Equal(t, BlockWork(1)+BlockWork(2), b.CumulativeWork)
+		assert.Equal(t, b, tree.BestTip())
+
+		node, ok := tree.Get(a.Block.Hash)
+		assert.True(t, ok)
+		assert.Equal(t, a, node)
+	})
+
+	t.Run("親が未知のブロックは拒否される", func(t *testing.T) {
+		tree, err := NewBlockTree(NewGenesisBlock(1))
+		require.NoError(t, err)
+
+		orphan := NewBlock(1, "orphan", "unknown", 1)
+		_, err = MineBlock(orphan, 1)
+		require.NoError(t, err)
+
+		_, err = tree.AddBlock(orphan)
+		assert.ErrorContains(t, err, "unknown parent")
+	})
+
+	t.Run("PoWを満たさないブロックは拒否される", func(t *testing.T) {
+		tree, err := NewBlockTree(NewGenesisBlock(1))
+		require.NoError(t, err)
+
+		block := NewBlock(1, "tampered", tree.Root.Block.Hash, 1)
+		_, err = MineBlock(block, 1)
+		require.NoError(t, err)
+		block.Entries = []string{"changed"}
+
+		_, err = tree.AddBlock(block)
+		assert.ErrorContains(t, err, "invalid proof of work")
+	})
+
+	t.Run("同じブロックは二重に追加できない", func(t *testing.T) {
+		tree, err := NewBlockTree(NewGenesisBlock(1))
+		require.NoError(t, err)
+
+		node, err := mineOnto(tree, tree.Root, "A", 1)
+		require.NoError(t, err)
+
+		_, err = tree.AddBlock(node.Block)
+		assert.ErrorContains(t, err, "already in tree")
+	})
+}
+
+func TestRunForkDemo(t *testing.T) {
+	t.Run("共通の親から2つの枝が伸びる", func(t *testing.T) {
+		demo, err := RunForkDemo(ForkConfig{BaseBlocks: 1, BranchA: 3, BranchB: 2, Difficulty: 1, DifficultyB: 1})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(1), demo.Parent.Block.Index)
+		assert.Equal(t, int64(4), demo.TipA.Block.Index)
+		assert.Equal(t, int64(3), demo.TipB.Block.Index)
+		assert.Len(t, demo.Parent.Children, 2)
+
+		// 同じ難易度なら長い枝の方が仕事量が多い
+		assert.Equal(t, demo.TipA, demo.Tree.BestTip())
+
+		// それぞれの枝は単独のチェーンとしても有効
+		for _, tip := range []*TreeNode{demo.TipA, demo.TipB} {
+			branch := tip.Branch()
+			assert.Equal(t, int64(0), branch[0].Index)
+			assert.NoError(t, validateBlocks(branch))
+		}
+	})
+
+	t.Run("短くても難易度の高い枝が選ばれる", func(t *testing.T) {
+		demo, err := RunForkDemo(ForkConfig{BaseBlocks: 0, BranchA: 2, BranchB: 1, Difficulty: 0, DifficultyB: 2})
+		require.NoError(t, err)
+
+		assert.Greater(t, demo.TipA.Block.Index, demo.TipB.Block.Index)
+		assert.Equal(t, demo.TipB, demo.Tree.BestTip())
+	})
+
+	t.Run("枝が空ならエラー", func(t *testing.T) {
+		_, err := RunForkDemo(ForkConfig{BranchA: 0, BranchB: 1})
+		assert.Error(t, err)
+	})
+}
+
+func TestWriteBlockTree(t *testing.T) {
+	t.Run("分岐と先端を描画する", func(t *testing.T) {
+		demo, err := RunForkDemo(ForkConfig{BaseBlocks: 1, BranchA: 1, BranchB: 1, Difficulty: 1, DifficultyB: 1})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		WriteBlockTree(&buf, demo.Tree)
+		output := buf.String()
+
+		assert.Contains(t, output, "├─ #2")
+		assert.Contains(t, output, "└─ #2")
+		assert.Equal(t, 2, strings.Count(output, "先端"))
+		assert.Equal(t, 1, strings.Count(output, "⭐"))
+	})
+
+	t.Run("結果の表示がパニックしない", func(t *testing.T) {
+		demo, err := RunForkDemo(ForkConfig{BranchA: 1, BranchB: 1, Difficulty: 1, DifficultyB: 1})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		assert.NotPanics(t, func() { printForkDemo(&buf, demo) })
+		assert.Contains(t, buf.String(), "枝A")
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// エコモードのパラメータ
+const (
+	// GovernorCheckInterval はガバナーが作業時間を確認するハッシュ試行の間隔
+	GovernorCheckInterval = 1024
+
+	// DefaultEcoDutyCycle はエコモードのデフォルトCPU使用率（1コアに対する割合）
+	DefaultEcoDutyCycle = 0.25
+)
+
+// MiningGovernor はマイニングのCPU使用率を制限するガバナーです（エコモード）
+// 一定回数ハッシュを計算するごとに作業時間を受け取り、
+// 使用率（デューティ比）や目標ハッシュレートを超えないようにスリープを挿入します
+type MiningGovernor struct {
+	dutyCycle      float64       // 1コアに対する最大使用率（0より大きく1以下）
+	targetHashRate float64       // 目標ハッシュレートの上限（0なら無制限）
+	workTime       time.Duration // 累計作業時間
+	sleepTime      time.Duration // 累計スリープ時間
+	hashes         int64         // 累計ハッシュ数
+	mutex          sync.Mutex
+}
+
+// GovernorStats はガバナーの計測結果を表します
+type GovernorStats struct {
+	DutyCycle         float64 // 設定された最大使用率
+	TargetHashRate    float64 // 設定された目標ハッシュレート（0なら無制限）
+	MeasuredDutyCycle float64 // 実測の使用率（作業時間 / 経過時間）
+	HashRate          float64 // 実測ハッシュレート（スリープを含む経過時間あたり）
+	SleepTime         time.Duration
+}
+
+// miningGovernor はMineBlockが参照するグローバルなガバナー（nilなら無制限）
+var miningGovernor atomic.Pointer[MiningGovernor]
+
+// NewMiningGovernor は指定された使用率で新しいガバナーを作成します
+func NewMiningGovernor(dutyCycle float64) (*MiningGovernor, error) {
+	g := &MiningGovernor{}
+	if err := g.SetDutyCycle(dutyCycle); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// SetMiningGovernor はグローバルなガバナーを設定します（nilで解除）
+func SetMiningGovernor(g *MiningGovernor) {
+	miningGovernor.Store(g)
+}
+
+// GetMiningGovernor は現在のグローバルなガバナーを返します
+func GetMiningGovernor() *MiningGovernor {
+	return miningGovernor.Load()
+}
+
+// SetDutyCycle は最大使用率を変更します
+func (g *MiningGovernor) SetDutyCycle(dutyCycle float64) error {
+	if dutyCycle <= 0 || dutyCycle > 1 {
+		return fmt.Errorf("duty cycle must be in (0, 1], got %.2f", dutyCycle)
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.dutyCycle = dutyCycle
+	return nil
+}
+
+// DutyCycle は現在の最大使用率を返します
+func (g *MiningGovernor) DutyCycle() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.dutyCycle
+}
+
+// SetTargetHashRate は目標ハッシュレートの上限を変更します（0で無制限）
+func (g *MiningGovernor) SetTargetHashRate(hashRate float64) error {
+	if hashRate < 0 {
+		return fmt.Errorf("target hash rate must be non-negative, got %.2f", hashRate)
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.targetHashRate = hashRate
+	return nil
+}
+
+// TargetHashRate は現在の目標ハッシュレートの上限を返します
+func (g *MiningGovernor) TargetHashRate() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.targetHashRate
+}
+
+// Pace は直前の区間の作業時間とハッシュ数を記録し、必要なだけスリープします
+// 作業時間 work に対して work*(1-d)/d だけ休むことで使用率を d に保ちます
+// 目標ハッシュレートが設定されている場合は、区間の所要時間が
+// hashes/目標ハッシュレート 以上になるまでさらに休みます
+func (g *MiningGovernor) Pace(work time.Duration, hashes int64) {
+	g.mutex.Lock()
+	g.workTime += work
+	g.hashes += hashes
+	sleep := time.Duration(float64(work) * (1 - g.dutyCycle) / g.dutyCycle)
+	if g.targetHashRate > 0 {
+		minElapsed := time.Duration(float64(hashes) / g.targetHashRate * float64(time.Second))
+		if work+sleep < minElapsed {
+			sleep = minElapsed - work
+		}
+	}
+	g.sleepTime += sleep
+	g.mutex.Unlock()
+
+	if sleep > 0 {
+		time.Sleep(sleep)
+	}
+}
+
+// Stats はガバナーの計測結果を返します
+func (g *MiningGovernor) Stats() GovernorStats {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stats := GovernorStats{
+		DutyCycle:      g.dutyCycle,
+		TargetHashRate: g.targetHashRate,
+		SleepTime:      g.sleepTime,
+	}
+
+	elapsed := g.workTime + g.sleepTime
+	if elapsed > 0 {
+		stats.MeasuredDutyCycle = float64(g.workTime) / float64(elapsed)
+		stats.HashRate = float64(g.hashes) / elapsed.Seconds()
+	}
+
+	return stats
+}
+
+// String は適用中のスロットル設定を人間が読みやすい形式で返します
+func (s GovernorStats) String() string {
+	if s.TargetHashRate > 0 {
+		return fmt.Sprintf("%.0f%% duty, cap %s", s.DutyCycle*100, formatHashRate(s.TargetHashRate))
+	}
+	if s.DutyCycle >= 1 {
+		return "Unlimited"
+	}
+	return fmt.Sprintf("%.0f%% duty", s.DutyCycle*100)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMiningGovernor(t *testing.T) {
+	t.Run("有効な使用率でガバナーを作成", func(t *testing.T) {
+		governor, err := NewMiningGovernor(0.5)
+
+		require.NoError(t, err)
+		require.NotNil(t, governor)
+		assert.Equal(t, 0.5, governor.DutyCycle())
+	})
+
+	t.Run("範囲外の使用率はエラー", func(t *testing.T) {
+		invalid := []float64{0, -0.1, 1.5}
+
+		for _, duty := range invalid {
+			governor, err := NewMiningGovernor(duty)
+			assert.Error(t, err)
+			assert.Nil(t, governor)
+		}
+	})
+
+	t.Run("使用率1.0（制限なし）は有効", func(t *testing.T) {
+		governor, err := NewMiningGovernor(1.0)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, governor.DutyCycle())
+	})
+}
+
+func TestMiningGovernorPace(t *testing.T) {
+	t.Run("使用率に応じたスリープを挿入", func(t *testing.T) {
+		governor, err := NewMiningGovernor(0.5)
+		require.NoError(t, err)
+
+		start := time.Now()
+		governor.Pace(20*time.Millisecond, 1000)
+		elapsed := time.Since(start)
+
+		// 作業時間と同じだけ休む
+		assert.GreaterOrEqual(t, elapsed, 20*time.Millisecond)
+
+		stats := governor.Stats()
+		assert.InDelta(t, 0.5, stats.MeasuredDutyCycle, 0.01)
+		assert.Equal(t, 20*time.Millisecond, stats.SleepTime)
+		assert.InDelta(t, 25000.0, stats.HashRate, 1.0)
+	})
+
+	t.Run("使用率1.0ではスリープしない", func(t *testing.T) {
+		governor, err := NewMiningGovernor(1.0)
+		require.NoError(t, err)
+
+		governor.Pace(10*time.Millisecond, 100)
+
+		stats := governor.Stats()
+		assert.Equal(t, time.Duration(0), stats.SleepTime)
+		assert.InDelta(t, 1.0, stats.MeasuredDutyCycle, 0.001)
+	})
+
+	t.Run("計測前の統計はゼロ", func(t *testing.T) {
+		governor, err := NewMiningGovernor(0.25)
+		require.NoError(t, err)
+
+		stats := governor.Stats()
+		assert.Equal(t, 0.25, stats.DutyCycle)
+		assert.Equal(t, 0.0, stats.MeasuredDutyCycle)
+		assert.Equal(t, 0.0, stats.HashRate)
+	})
+}
+
+func TestMineBlockWithGovernor(t *testing.T) {
+	t.Run("エコモードでもマイニング結果は有効", func(t *testing.T) {
+		governor, err := NewMiningGovernor(0.5)
+		require.NoError(t, err)
+
+		SetMiningGovernor(governor)
+		defer SetMiningGovernor(nil)
+
+		block := NewBlock(1, "Eco Block", "previous_hash", 2)
+		metrics, err := MineBlock(block, 2)
+
+		require.NoError(t, err)
+		require.NotNil(t, metrics)
+		assert.True(t, ValidateProofOfWork(block))
+
+		// 試行回数がチェック間隔を超えた分だけガバナーに報告される
+		stats := governor.Stats()
+		if metrics.AttemptsCount >= GovernorCheckInterval {
+			assert.Greater(t, stats.SleepTime, time.Duration(0))
+		}
+	})
+
+	t.Run("グローバルガバナーの設定と解除", func(t *testing.T) {
+		governor, err := NewMiningGovernor(0.25)
+		require.NoError(t, err)
+
+		SetMiningGovernor(governor)
+		assert.Equal(t, governor, GetMiningGovernor())
+
+		SetMiningGovernor(nil)
+		assert.Nil(t, GetMiningGovernor())
+	})
+}
+
+func TestMiningGovernorTargetHashRate(t *testing.T) {
+	t.Run("目標ハッシュレートに合わせてスリープ", func(t *testing.T) {
+		governor, err := NewMiningGovernor(1.0)
+		require.NoError(t, err)
+		require.NoError(t, governor.SetTargetHashRate(10000))
+
+		// 100ハッシュを1msで計算 → 10000 H/sなら10msかかるべき
+		governor.Pace(1*time.Millisecond, 100)
+
+		stats := governor.Stats()
+		assert.Equal(t, 9*time.Millisecond, stats.SleepTime)
+		assert.InDelta(t, 10000.0, stats.HashRate, 1.0)
+	})
+
+	t.Run("負の目標ハッシュレートはエラー", func(t *testing.T) {
+		governor, err := NewMiningGovernor(1.0)
+		require.NoError(t, err)
+
+		assert.Error(t, governor.SetTargetHashRate(-1))
+		assert.Equal(t, 0.0, governor.TargetHashRate())
+	})
+}
+
+func TestGovernorStatsString(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    GovernorStats
+		expected string
+	}{
+		{"無制限", GovernorStats{DutyCycle: 1.0}, "Unlimited"},
+		{"使用率のみ", GovernorStats{DutyCycle: 0.3}, "30% duty"},
+		{"ハッシュレート上限", GovernorStats{DutyCycle: 1.0, TargetHashRate: 5000}, "100% duty, cap 5.00 KH/s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.stats.String())
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"time"
+)
+
+// MiningRecord は1ブロック分のマイニング記録です
+type MiningRecord struct {
+	BlockIndex int64         // ブロック番号
+	Difficulty int           // マイニング時の難易度
+	Metrics    MiningMetrics // マイニングのパフォーマンス情報
+}
+
+// MiningHistoryStats はマイニング履歴の集計結果です
+type MiningHistoryStats struct {
+	Blocks          int           // 記録されたブロック数
+	TotalHashes     int64         // 総ハッシュ計算回数
+	AverageAttempts float64       // 1ブロックあたりの平均試行回数
+	TotalDuration   time.Duration // マイニングに費やした合計時間
+	AverageHashRate float64       // 全体の平均ハッシュレート(hashes/sec)
+	BestSolveTime   time.Duration // 最短のマイニング時間
+	BestBlock       int64         // 最短だったブロック番号
+	WorstSolveTime  time.Duration // 最長のマイニング時間
+	WorstBlock      int64         // 最長だったブロック番号
+}
+
+// recordMining はマイニング結果を履歴に追加します
+// 呼び出し側で書き込みロックを取得していることを前提とします
+func (bc *Blockchain) recordMining(block *Block, metrics *MiningMetrics) {
+	bc.history = append(bc.history, MiningRecord{
+		BlockIndex: block.Index,
+		Difficulty: block.Difficulty,
+		Metrics:    *metrics,
+	})
+}
+
+// GetMiningHistory はこのチェーンでマイニングしたブロックの記録を古い順に返します
+func (bc *Blockchain) GetMiningHistory() []MiningRecord {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	history := make([]MiningRecord, len(bc.history))
+	copy(history, bc.history)
+	return history
+}
+
+// GetMiningStats はマイニング履歴の集計結果を返します
+func (bc *Blockchain) GetMiningStats() MiningHistoryStats {
+	return CalculateMiningStats(bc.GetMiningHistory())
+}
+
+// CalculateMiningStats はマイニング記録から集計結果を計算します
+func CalculateMiningStats(history []MiningRecord) MiningHistoryStats {
+	stats := MiningHistoryStats{Blocks: len(history)}
+	if len(history) == 0 {
+		return stats
+	}
+
+	for i, record := range history {
+		duration := record.Metrics.Duration
+		stats.TotalHashes += record.Metrics.AttemptsCount
+		stats.TotalDuration += duration
+
+		if i == 0 || duration < stats.BestSolveTime {
+			stats.BestSolveTime = duration
+			stats.BestBlock = record.BlockIndex
+		}
+		if i == 0 || duration > stats.WorstSolveTime {
+			stats.WorstSolveTime = duration
+			stats.WorstBlock = record.BlockIndex
+		}
+	}
+
+	stats.AverageAttempts = float64(stats.TotalHashes) / float64(len(history))
+	if stats.TotalDuration > 0 {
+		stats.AverageHashRate = float64(stats.TotalHashes) / stats.TotalDuration.Seconds()
+	}
+
+	return stats
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMiningHistory(t *testing.T) {
+	t.Run("マイニングしたブロックごとに記録", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		_, _ = bc.AddBlock("Block 1")
+		_, _ = bc.AddBlock("Block 2")
+
+		history := bc.GetMiningHistory()
+
+		require.Len(t, history, 2)
+		assert.Equal(t, int64(1), history[0].BlockIndex)
+		assert.Equal(t, int64(2), history[1].BlockIndex)
+		assert.Equal(t, 1, history[0].Difficulty)
+		assert.GreaterOrEqual(t, history[0].Metrics.AttemptsCount, int64(1))
+	})
+
+	t.Run("中断されたマイニングは記録しない", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.Difficulty = MaxDifficulty
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := bc.AddBlockContext(ctx, "Aborted")
+
+		require.Error(t, err)
+		assert.Empty(t, bc.GetMiningHistory())
+	})
+
+	t.Run("返された履歴を変更してもチェーンに影響しない", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		_, _ = bc.AddBlock("Block 1")
+
+		history := bc.GetMiningHistory()
+		history[0].BlockIndex = 99
+
+		assert.Equal(t, int64(1), bc.GetMiningHistory()[0].BlockIndex)
+	})
+}
+
+func TestCalculateMiningStats(t *testing.T) {
+	t.Run("集計結果", func(t *testing.T) {
+		history := []MiningRecord{
+			{BlockIndex: 1, Metrics: MiningMetrics{AttemptsCount: 10, Duration: 2 * time.Second}},
+			{BlockIndex: 2, Metrics: MiningMetrics{AttemptsCount: 30, Duration: 1 * time.Second}},
+			{BlockIndex: 3, Metrics: MiningMetrics{AttemptsCount: 20, Duration: 3 * time.Second}},
+		}
+
+		stats := CalculateMiningStats(history)
+
+		assert.Equal(t, 3, stats.Blocks)
+		assert.Equal(t, int64(60), stats.TotalHashes)
+		assert.Equal(t, 20.0, stats.AverageAttempts)
+		assert.Equal(t, 6*time.Second, stats.TotalDuration)
+		assert.Equal(t, 10.0, stats.AverageHashRate)
+		assert.Equal(t, time.Second, stats.BestSolveTime)
+		assert.Equal(t, int64(2), stats.BestBlock)
+		assert.Equal(t, 3*time.Second, stats.WorstSolveTime)
+		assert.Equal(t, int64(3), stats.WorstBlock)
+	})
+
+	t.Run("履歴が空", func(t *testing.T) {
+		stats := CalculateMiningStats(nil)
+
+		assert.Equal(t, MiningHistoryStats{}, stats)
+	})
+}
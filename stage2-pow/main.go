@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -16,8 +18,14 @@ import (
 // Blockchain はPoWマイニング対応のブロックチェーン
 type Blockchain struct {
 	Blocks          []*Block
-	Difficulty      int // 現在の難易度
-	TargetBlockTime int // 目標ブロック生成時間（秒）
+	Difficulty      int                   // 現在の難易度
+	TargetBlockTime int                   // 目標ブロック生成時間（秒）
+	RetargetMode    RetargetMode          // 難易度調整の方式
+	Algorithm       string                // PoWアルゴリズム（空ならsha256）
+	Seed            string                // 再現可能なマイニングのシード（空なら非決定的）
+	history         []MiningRecord        // マイニング記録（ブロックごと）
+	events          EventBus              // ブロック追加・難易度変更のイベント
+	progress        chan<- HashRateSample // マイニング中の1秒ごとのハッシュレートの送信先
 	mutex           sync.RWMutex
 }
 
@@ -27,42 +35,141 @@ func NewBlockchain(difficulty int) *Blockchain {
 		Blocks:          []*Block{NewGenesisBlock(difficulty)},
 		Difficulty:      difficulty,
 		TargetBlockTime: TargetBlockTime, // difficulty.goの定数を使用
+		RetargetMode:    RetargetInterval,
 	}
 }
 
+// NewBlockchainWithPoW は指定したPoWアルゴリズムでブロックチェーンを生成します
+func NewBlockchainWithPoW(difficulty int, algorithm string) (*Blockchain, error) {
+	return newBlockchain(difficulty, algorithm, "")
+}
+
+// newBlockchain はPoWアルゴリズムとシード（空なら非決定的）を指定してチェーンを生成します
+func newBlockchain(difficulty int, algorithm string, seed string) (*Blockchain, error) {
+	if _, err := GetPoWAlgorithm(algorithm); err != nil {
+		return nil, err
+	}
+	if algorithm == PoWSHA256 {
+		algorithm = "" // 従来のブロックと同じ形式で保存する
+	}
+
+	bc := &Blockchain{
+		Difficulty:      difficulty,
+		TargetBlockTime: TargetBlockTime,
+		RetargetMode:    RetargetInterval,
+		Algorithm:       algorithm,
+		Seed:            seed,
+	}
+
+	genesis := NewBlock(0, "Genesis Block", "", difficulty)
+	genesis.Algorithm = algorithm
+	if seed != "" {
+		genesis.Timestamp = SeededGenesisTimestamp
+	}
+	if _, err := bc.mine(context.Background(), genesis); err != nil {
+		return nil, fmt.Errorf("failed to mine genesis block: %w", err)
+	}
+	bc.Blocks = []*Block{genesis}
+
+	return bc, nil
+}
+
+// mine はチェーンの設定（シードの有無）に従ってブロックをマイニングします
+func (bc *Blockchain) mine(ctx context.Context, block *Block) (*MiningMetrics, error) {
+	startNonce := int64(0)
+	if bc.Seed != "" {
+		startNonce = SeededStartNonce(bc.Seed, block.Index)
+	}
+	return mineBlock(ctx, block, bc.Difficulty, startNonce, bc.progress)
+}
+
+// SetMiningProgress はマイニング中の1秒ごとのハッシュレートの送信先を設定します（nilで解除）
+func (bc *Blockchain) SetMiningProgress(progress chan<- HashRateSample) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	bc.progress = progress
+}
+
 // AddBlock はチェーンに新しいブロックを追加します（マイニング実行）
 func (bc *Blockchain) AddBlock(data string) (*MiningMetrics, error) {
+	return bc.AddBlockContext(context.Background(), data)
+}
+
+// AddBlockContext はキャンセル可能なマイニングでブロックを追加します
+// マイニングが中断された場合、チェーンは変更されません
+func (bc *Blockchain) AddBlockContext(ctx context.Context, data string) (*MiningMetrics, error) {
+	return bc.AddBlockWithEntries(ctx, []string{data})
+}
+
+// AddBlockWithEntries は複数のデータを持つブロックをマイニングして追加します
+// データはマークルルートとしてブロックヘッダーに含まれます
+func (bc *Blockchain) AddBlockWithEntries(ctx context.Context, entries []string) (*MiningMetrics, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("block must contain at least one entry")
+	}
+
 	bc.mutex.Lock()
 	defer bc.mutex.Unlock()
 
+	newBlock := bc.newBlockTemplate(entries)
+
+	// マイニング実行
+	metrics, err := bc.mine(ctx, newBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	bc.appendMinedBlock(newBlock, metrics)
+
+	return metrics, nil
+}
+
+// newBlockTemplate はチェーンの先端に続く未マイニングのブロックを作成します
+// 呼び出し側でロックを取得していることを前提とします
+func (bc *Blockchain) newBlockTemplate(entries []string) *Block {
 	previousBlock := bc.Blocks[len(bc.Blocks)-1]
 
-	newBlock := NewBlock(
+	block := NewBlockWithEntries(
 		previousBlock.Index+1,
-		data,
+		entries,
 		previousBlock.Hash,
 		bc.Difficulty,
 	)
-
-	// マイニング実行
-	metrics, err := MineBlock(newBlock, bc.Difficulty)
-	if err != nil {
-		return nil, err
+	block.Algorithm = bc.Algorithm
+	if bc.Seed != "" {
+		// 再現性のため、タイムスタンプは目標ブロック時間ごとに進める
+		block.Timestamp = previousBlock.Timestamp + int64(bc.TargetBlockTime)
 	}
+	return block
+}
 
-	bc.Blocks = append(bc.Blocks, newBlock)
+// appendMinedBlock はマイニング済みのブロックをチェーンに追加し、難易度を調整します
+// 呼び出し側で書き込みロックを取得していることを前提とします
+func (bc *Blockchain) appendMinedBlock(block *Block, metrics *MiningMetrics) {
+	bc.Blocks = append(bc.Blocks, block)
+	bc.recordMining(block, metrics)
+
+	bc.events.Publish(ChainEvent{
+		Type:       EventBlockMined,
+		Height:     block.Index,
+		Difficulty: block.Difficulty,
+		Block:      block,
+	})
 
 	// 難易度の自動調整
 	if ShouldAdjustDifficulty(bc) {
 		oldDifficulty := bc.Difficulty
 		bc.Difficulty = CalculateDifficulty(bc, bc.TargetBlockTime)
 		if oldDifficulty != bc.Difficulty {
-			// 難易度が変更された場合のログ（オプション）
-			_ = oldDifficulty // 将来のログ用に残す
+			bc.events.Publish(ChainEvent{
+				Type:          EventDifficultyChange,
+				Height:        block.Index,
+				Difficulty:    bc.Difficulty,
+				OldDifficulty: oldDifficulty,
+			})
 		}
 	}
-
-	return metrics, nil
 }
 
 // GetLatestBlock はチェーンの最新ブロックを返します
@@ -86,58 +193,225 @@ func (bc *Blockchain) GetChainLength() int {
 
 // IsValid はチェーン全体の整合性を検証します（PoW検証を含む）
 func (bc *Blockchain) IsValid() bool {
+	return bc.Validate() == nil
+}
+
+// Validate はチェーン全体を検証し、最初に見つかった問題を返します
+func (bc *Blockchain) Validate() error {
 	bc.mutex.RLock()
 	defer bc.mutex.RUnlock()
 
-	if len(bc.Blocks) == 0 {
-		return false
+	return validateBlocks(bc.Blocks)
+}
+
+// validateBlocks はジェネシスから順にブロックを検証します（PoW検証を含む）
+func validateBlocks(blocks []*Block) error {
+	if len(blocks) == 0 {
+		return fmt.Errorf("chain contains no blocks")
 	}
 
 	// ジェネシスブロックの検証
-	genesis := bc.Blocks[0]
+	genesis := blocks[0]
 	if genesis.Index != 0 || genesis.PreviousHash != "" {
-		return false
+		return fmt.Errorf("block 0: invalid genesis block")
 	}
 	if !ValidateProofOfWork(genesis) {
-		return false
+		return fmt.Errorf("block 0: invalid proof of work")
 	}
 
 	// 各ブロックを検証
-	for i := 1; i < len(bc.Blocks); i++ {
-		currentBlock := bc.Blocks[i]
-		previousBlock := bc.Blocks[i-1]
+	for i := 1; i < len(blocks); i++ {
+		currentBlock := blocks[i]
+		previousBlock := blocks[i-1]
 
 		// PoW検証
 		if !ValidateProofOfWork(currentBlock) {
-			return false
+			return fmt.Errorf("block %d: invalid proof of work", i)
 		}
 
 		// PreviousHashの一致確認
 		if currentBlock.PreviousHash != previousBlock.Hash {
-			return false
+			return fmt.Errorf("block %d: previous hash does not match", i)
 		}
 
 		// インデックスの連続性確認
 		if currentBlock.Index != previousBlock.Index+1 {
-			return false
+			return fmt.Errorf("block %d: index %d does not follow %d", i, currentBlock.Index, previousBlock.Index)
 		}
 
 		// タイムスタンプの単調増加確認
 		if currentBlock.Timestamp < previousBlock.Timestamp {
-			return false
+			return fmt.Errorf("block %d: timestamp goes backwards", i)
 		}
 	}
 
-	return true
+	return nil
 }
 
 func main() {
+	// サブコマンドの処理
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			os.Exit(runBenchCommand(os.Args[2:]))
+		case "fork":
+			os.Exit(runForkCommand(os.Args[2:]))
+		}
+	}
+
 	// コマンドラインフラグの定義
 	difficultyFlag := flag.Int("difficulty", 2, "デフォルトのマイニング難易度")
+	ecoFlag := flag.Bool("eco", false, "エコモード: マイニングのCPU使用率を制限")
+	ecoDutyFlag := flag.Float64("eco-duty", DefaultEcoDutyCycle, "エコモードのCPU使用率上限（1コアに対する割合 0-1）")
+	maxHashRateFlag := flag.Float64("max-hashrate", 0, "マイニングの目標ハッシュレート上限（hashes/sec, 0で無制限）")
+	daemonFlag := flag.Bool("daemon", false, "対話メニューなしで連続マイニングする（SIGINT/SIGTERMで終了）")
+	exportFile := flag.String("export", "", "デーモン・--mine-blocks の終了時にチェーンをJSON形式でエクスポート")
+	importFile := flag.String("import", "", "エクスポートしたチェーンをPoWを再検証してインポート（難易度などの状態も復元）")
+	resumeFlag := flag.Bool("resume", false, "--import したチェーンの先端からデーモンとしてマイニングを再開（終了時は --export またはインポート元に保存）")
+	rpcAddr := flag.String("rpc", "", "JSON-RPCサーバーの待ち受けアドレス（例: :8332）")
+	stratumAddr := flag.String("stratum", "", "外部ワーカーに作業を配布するStratum風サーバーの待ち受けアドレス（例: :3333）")
+	retargetFlag := flag.String("retarget", string(RetargetInterval), "難易度調整の方式: interval（10ブロックごと）, per-block（毎ブロック）")
+	powFlag := flag.String("pow", PoWSHA256, "PoWアルゴリズム: sha256, scrypt（メモリハード）")
+	seedFlag := flag.String("seed", "", "再現可能なマイニングのシード（ナンスとタイムスタンプを決定的にする）")
+	energyProfileFlag := flag.String("energy-profile", DefaultEnergyProfileName, "消費電力の概算に使う機器: cpu, gpu, asic")
+	joulesPerHashFlag := flag.Float64("joules-per-hash", 0, "ハッシュ1回あたりの消費エネルギー（J, 0ならプロファイルの値）")
+	co2Flag := flag.Float64("co2-per-kwh", 0, "1kWhあたりのCO2排出量（g, 0ならプロファイルの値）")
+	costFlag := flag.Float64("cost-per-kwh", 0, "1kWhあたりの電気料金（0ならプロファイルの値）")
+	mineBlocksFlag := flag.Int("mine-blocks", 0, "対話メニューなしで指定数のブロックをマイニングして終了（CIやベンチマーク用）")
+	dataPrefixFlag := flag.String("data-prefix", DefaultDataPrefix, "--mine-blocks で各ブロックのデータに付ける接頭辞（後ろにブロック番号を付加）")
+	jsonFlag := flag.Bool("json", false, "--mine-blocks の結果を1ブロックごとにJSON Linesで出力（その他のメッセージは標準エラー出力へ）")
 	flag.Parse()
 
+	// JSON出力時は標準出力をJSON Linesだけにするため、案内メッセージを標準エラー出力に書く
+	info := io.Writer(os.Stdout)
+	if *jsonFlag {
+		info = os.Stderr
+	}
+
+	retargetMode, err := ParseRetargetMode(*retargetFlag)
+	if err != nil {
+		fmt.Printf("❌ エラー: %v\n", err)
+		os.Exit(1)
+	}
+	if *resumeFlag && *importFile == "" {
+		fmt.Println("❌ エラー: --resume には --import が必要です")
+		os.Exit(1)
+	}
+
+	// 消費エネルギー概算のプロファイル
+	profile, err := LookupEnergyProfile(*energyProfileFlag)
+	if err != nil {
+		fmt.Printf("❌ エラー: %v\n", err)
+		os.Exit(1)
+	}
+	if *joulesPerHashFlag > 0 {
+		profile.JoulesPerHash = *joulesPerHashFlag
+	}
+	if *co2Flag > 0 {
+		profile.CO2PerKWh = *co2Flag
+	}
+	if *costFlag > 0 {
+		profile.CostPerKWh = *costFlag
+	}
+	SetEnergyProfile(profile)
+
+	// エコモード・スロットルの設定
+	if *ecoFlag || *maxHashRateFlag > 0 {
+		dutyCycle := 1.0
+		if *ecoFlag {
+			dutyCycle = *ecoDutyFlag
+		}
+
+		governor, err := NewMiningGovernor(dutyCycle)
+		if err == nil {
+			err = governor.SetTargetHashRate(*maxHashRateFlag)
+		}
+		if err != nil {
+			fmt.Printf("❌ エラー: エコモードの設定に失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		SetMiningGovernor(governor)
+		fmt.Fprintf(info, "🌱 エコモード有効: %s\n", governor.Stats())
+	}
+
 	// ブロックチェーンの初期化
-	bc := NewBlockchain(*difficultyFlag)
+	// インポートした場合は難易度・PoWアルゴリズム・シードなどもファイルの状態を引き継ぐ
+	var bc *Blockchain
+	if *importFile != "" {
+		bc, err = importBlockchain(*importFile)
+		if err != nil {
+			fmt.Printf("❌ エラー: チェーンのインポートに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(info, "✓ チェーンを %s からインポートしました（%d ブロック, 全ブロックのPoWを検証済み, 難易度: %d）\n",
+			*importFile, bc.GetChainLength(), bc.Difficulty)
+	} else {
+		bc, err = newBlockchain(*difficultyFlag, *powFlag, *seedFlag)
+		if err != nil {
+			fmt.Printf("❌ エラー: %v\n", err)
+			os.Exit(1)
+		}
+		bc.RetargetMode = retargetMode
+	}
+	if bc.Seed != "" {
+		fmt.Fprintf(info, "🎲 シード %q で再現可能なマイニングを行います\n", bc.Seed)
+	}
+	if algorithm, _ := GetPoWAlgorithm(bc.Algorithm); algorithm.Name() != PoWSHA256 {
+		fmt.Fprintf(info, "🧠 PoWアルゴリズム: %s (1ハッシュあたり %s のメモリ)\n", algorithm.Name(), formatBytes(algorithm.MemoryPerHash()))
+	}
+
+	// --rpc フラグ: JSON-RPCサーバーを起動
+	if *rpcAddr != "" {
+		server := startHTTPServer(*rpcAddr, bc)
+		defer func() { _ = server.Close() }()
+		fmt.Fprintf(info, "🌐 JSON-RPCサーバーを %s で起動しました（ライブフィード: ws://%s/ws）\n", *rpcAddr, *rpcAddr)
+		fmt.Fprintf(info, "📈 メトリクス: http://%s/metrics\n", *rpcAddr)
+	}
+
+	// --stratum フラグ: 外部ワーカー向けの作業配布サーバーを起動
+	if *stratumAddr != "" {
+		stratum := NewStratumServer(bc)
+		if err := stratum.ListenAndServe(*stratumAddr); err != nil {
+			fmt.Printf("❌ エラー: Stratumサーバーを起動できません: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = stratum.Close() }()
+		fmt.Fprintf(info, "⛏️  Stratumサーバーを %s で起動しました（ワーカー: go run ./cmd/minicoin-worker %s）\n", *stratumAddr, *stratumAddr)
+	}
+
+	// --mine-blocks フラグ: 指定数のブロックをマイニングして終了
+	if *mineBlocksFlag > 0 {
+		config := ScriptedConfig{Blocks: *mineBlocksFlag, DataPrefix: *dataPrefixFlag, JSON: *jsonFlag}
+		if err := RunScripted(bc, config, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ マイニングエラー: %v\n", err)
+			os.Exit(1)
+		}
+		if *exportFile != "" {
+			if err := exportBlockchain(bc, *exportFile); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ チェーンの保存に失敗しました: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(info, "💾 チェーンを %s に保存しました\n", *exportFile)
+		}
+		return
+	}
+
+	// --resume フラグ: インポートしたチェーンの先端からマイニングを再開
+	if *resumeFlag {
+		saveTo := *exportFile
+		if saveTo == "" {
+			saveTo = *importFile
+		}
+		fmt.Printf("⏯️  ブロック #%d の上からマイニングを再開します\n", bc.GetLatestBlock().Index)
+		runDaemon(bc, DaemonConfig{ExportFile: saveTo})
+		return
+	}
+
+	// --daemon フラグ: ヘッドレスで連続マイニング
+	if *daemonFlag {
+		runDaemon(bc, DaemonConfig{ExportFile: *exportFile})
+		return
+	}
 
 	// 対話型CLI
 	runInteractiveCLI(bc)
@@ -147,6 +421,17 @@ func main() {
 func runInteractiveCLI(bc *Blockchain) {
 	reader := bufio.NewReader(os.Stdin)
 
+	// 追加したデータはバックグラウンドのマイナーが順番にブロックにする
+	queue := NewWorkQueue(bc)
+	queue.OnMined = func(result WorkResult) {
+		printMinedBlock(bc, result)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = queue.Run(ctx)
+	}()
+
 	printHeader()
 
 	for {
@@ -164,7 +449,7 @@ func runInteractiveCLI(bc *Blockchain) {
 		case "1":
 			miningDemo(reader)
 		case "2":
-			addBlockInteractive(bc, reader)
+			enqueueBlockInteractive(queue, reader)
 		case "3":
 			displayChain(bc)
 		case "4":
@@ -178,10 +463,15 @@ func runInteractiveCLI(bc *Blockchain) {
 		case "8":
 			runDashboard(bc)
 		case "9":
+			displayQueue(queue)
+		case "10":
+			if pending := queue.Len(); pending > 0 {
+				fmt.Printf("\n⚠️  キューに残っている %d 件はマイニングされずに破棄されます\n", pending)
+			}
 			fmt.Println("\n👋 Minicoinをご利用いただきありがとうございました！")
 			return
 		default:
-			fmt.Println("❌ 無効な選択です。1-9の数字を入力してください。")
+			fmt.Println("❌ 無効な選択です。1-10の数字を入力してください。")
 		}
 	}
 }
@@ -200,14 +490,15 @@ func printMenu() {
 	fmt.Println("  メニュー")
 	fmt.Println("====================================")
 	fmt.Println("1. マイニングデモを実行")
-	fmt.Println("2. ブロックをマイニングして追加")
+	fmt.Println("2. ブロックをマイニングキューに追加")
 	fmt.Println("3. チェーン全体を表示")
 	fmt.Println("4. チェーンを検証")
 	fmt.Println("5. パフォーマンス比較")
 	fmt.Println("6. 難易度を変更")
-	fmt.Println("7. 難易度統計を表示")
+	fmt.Println("7. 難易度・マイニング統計を表示")
 	fmt.Println("8. ダッシュボードを起動")
-	fmt.Println("9. 終了")
+	fmt.Println("9. マイニングキューを表示")
+	fmt.Println("10. 終了")
 	fmt.Println("====================================")
 }
 
@@ -255,6 +546,8 @@ func miningDemo(reader *bufio.Reader) {
 	fmt.Printf("⏱️  所要時間:     %v\n", metrics.Duration)
 	fmt.Printf("🔢 試行回数:     %d 回\n", metrics.AttemptsCount)
 	fmt.Printf("⚡ ハッシュレート: %.2f hashes/sec\n", metrics.HashRate)
+	fmt.Printf("🧠 メモリ:       %s/hash (%s)\n", formatBytes(metrics.MemoryPerHash), metrics.Algorithm)
+	fmt.Printf("🔌 消費電力:     %s [%s, 概算]\n", EstimateMiningEnergy(metrics), GetEnergyProfile().Name)
 	fmt.Printf("🎲 Nonce:        %d\n", block.Nonce)
 	fmt.Printf("🔐 Hash:         %s\n", block.Hash)
 	fmt.Printf("✓  Difficulty:   %s%s\n", GetDifficultyPrefix(difficulty), strings.Repeat("x", 64-difficulty))
@@ -266,43 +559,107 @@ func miningDemo(reader *bufio.Reader) {
 	}
 }
 
-// addBlockInteractive はユーザー入力からブロックをマイニングして追加します
-func addBlockInteractive(bc *Blockchain, reader *bufio.Reader) {
-	fmt.Print("\nブロックに含めるデータを入力してください: ")
+// enqueueBlockInteractive はユーザー入力のデータをマイニングキューに追加します
+// マイニングはバックグラウンドで行われ、完了するとprintMinedBlockで結果を表示します
+func enqueueBlockInteractive(queue *WorkQueue, reader *bufio.Reader) {
+	fmt.Print("\nブロックに含めるデータを入力してください（複数は | で区切る）: ")
 	data, err := reader.ReadString('\n')
 	if err != nil {
 		fmt.Printf("❌ エラー: 入力の読み取りに失敗しました: %v\n", err)
 		return
 	}
-	data = strings.TrimSpace(data)
 
-	if data == "" {
+	entries := parseEntries(data)
+	if len(entries) == 0 {
 		fmt.Println("❌ データが空です。ブロックは追加されませんでした。")
 		return
 	}
 
-	// 難易度変更を検出するため、現在の難易度を保存
-	oldDifficulty := bc.Difficulty
-
-	fmt.Printf("\n⛏️  難易度 %d でマイニング中...\n", bc.Difficulty)
+	fmt.Print("優先度を入力してください（大きいほど先にマイニング、空なら0）: ")
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Printf("❌ エラー: 入力の読み取りに失敗しました: %v\n", err)
+		return
+	}
+	priority := 0
+	if input = strings.TrimSpace(input); input != "" {
+		if priority, err = strconv.Atoi(input); err != nil {
+			fmt.Println("❌ 優先度は整数で指定してください")
+			return
+		}
+	}
 
-	metrics, err := bc.AddBlock(data)
+	item, err := queue.Enqueue(entries, priority)
 	if err != nil {
-		fmt.Printf("❌ エラー: ブロックの追加に失敗しました: %v\n", err)
+		fmt.Printf("❌ エラー: キューに追加できませんでした: %v\n", err)
+		return
+	}
+	fmt.Printf("\n📥 キューに追加しました (#%d, 優先度 %d, 待ち %d 件, 完了予定 %s)\n",
+		item.ID, item.Priority, queue.Len(), formatQueueETA(queue))
+	fmt.Println("   マイニングはバックグラウンドで行われ、完了すると結果を表示します")
+}
+
+// formatQueueETA はキューが空になるまでの推定時間を表示用に返します
+func formatQueueETA(queue *WorkQueue) string {
+	eta, ok := queue.ETA()
+	if !ok {
+		return "計測中"
+	}
+	return "約 " + eta.Round(time.Second).String()
+}
+
+// displayQueue はマイニングキューの状態を表示します
+func displayQueue(queue *WorkQueue) {
+	fmt.Println("\n📋 マイニングキュー")
+	fmt.Println("────────────────────────────────────────────────────────")
+	if current, ok := queue.Current(); ok {
+		fmt.Printf("⛏️  マイニング中: #%d (優先度 %d) %s\n", current.ID, current.Priority, strings.Join(current.Entries, " | "))
+	} else {
+		fmt.Println("⛏️  マイニング中: なし")
+	}
+
+	pending := queue.Pending()
+	fmt.Printf("📥 待ち:         %d 件\n", len(pending))
+	for i, item := range pending {
+		fmt.Printf("   %2d. #%d (優先度 %d) %s\n", i+1, item.ID, item.Priority, strings.Join(item.Entries, " | "))
+	}
+	fmt.Printf("✅ 完了:         %d 件\n", queue.Processed())
+	fmt.Printf("⏳ 完了予定:     %s\n", formatQueueETA(queue))
+	fmt.Println("────────────────────────────────────────────────────────")
+}
+
+// printMinedBlock はキューのマイニング結果を表示します
+func printMinedBlock(bc *Blockchain, result WorkResult) {
+	if result.Err != nil {
+		fmt.Printf("\n❌ エラー: #%d のブロックの追加に失敗しました: %v\n", result.Item.ID, result.Err)
 		return
 	}
 
-	latestBlock := bc.GetLatestBlock()
-	fmt.Println("\n✅ ブロックをマイニングしてチェーンに追加しました！")
+	latestBlock, metrics := result.Block, result.Metrics
+	// マイニングに使った難易度と現在の難易度を比べて難易度調整を検出する
+	oldDifficulty := latestBlock.Difficulty
+
+	fmt.Printf("\n\n✅ キューの #%d をマイニングしてチェーンに追加しました！\n", result.Item.ID)
 	fmt.Println("────────────────────────────────────────────────────────")
 	fmt.Printf("📦 Block #%d\n", latestBlock.Index)
-	fmt.Printf("   Data:         %s\n", latestBlock.Data)
+	fmt.Printf("   Entries:      %d 件 (%s)\n", len(latestBlock.Entries), latestBlock.Data())
+	fmt.Printf("   Merkle Root:  %s\n", latestBlock.MerkleRoot)
 	fmt.Printf("   Hash:         %s\n", latestBlock.Hash)
 	fmt.Printf("   Nonce:        %d\n", latestBlock.Nonce)
 	fmt.Printf("   Difficulty:   %d\n", latestBlock.Difficulty)
 	fmt.Printf("   ⏱️  所要時間:     %v\n", metrics.Duration)
 	fmt.Printf("   🔢 試行回数:     %d 回\n", metrics.AttemptsCount)
 	fmt.Printf("   ⚡ ハッシュレート: %.2f hashes/sec\n", metrics.HashRate)
+	if len(metrics.Samples) > 1 {
+		minRate, maxRate := HashRateRange(metrics.Samples)
+		fmt.Printf("   📉 毎秒の変動:     %s 〜 %s（%d 秒分）\n", formatHashRate(minRate), formatHashRate(maxRate), len(metrics.Samples))
+	}
+	fmt.Printf("   🧠 メモリ:         %s/hash (%s), 確保量 %s\n", formatBytes(metrics.MemoryPerHash), metrics.Algorithm, formatBytes(int64(metrics.AllocatedBytes)))
+	fmt.Printf("   🔌 消費電力(概算): %s [%s]\n", EstimateMiningEnergy(metrics), GetEnergyProfile().Name)
+	if governor := GetMiningGovernor(); governor != nil {
+		stats := governor.Stats()
+		fmt.Printf("   🌱 エコモード:     上限 %.0f%% / 実測 %.1f%%\n", stats.DutyCycle*100, stats.MeasuredDutyCycle*100)
+	}
 	fmt.Println("────────────────────────────────────────────────────────")
 
 	// 難易度が変更された場合に通知
@@ -322,6 +679,30 @@ func addBlockInteractive(bc *Blockchain, reader *bufio.Reader) {
 	}
 }
 
+// formatBytes はバイト数を人間が読みやすい形式に変換します
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// parseEntries は | 区切りの入力をブロックのデータ一覧に分割します
+// 空のデータは取り除かれます
+func parseEntries(input string) []string {
+	var entries []string
+	for _, entry := range strings.Split(input, "|") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
 // displayChain はチェーン全体を表示します
 func displayChain(bc *Blockchain) {
 	fmt.Println("\n╔════════════════════════════════════════════════════════╗")
@@ -337,7 +718,8 @@ func displayChain(bc *Blockchain) {
 		}
 		fmt.Println("────────────────────────────────────────────────────────")
 		fmt.Printf("Timestamp:     %s\n", common.FormatTimestamp(block.Timestamp))
-		fmt.Printf("Data:          %s\n", block.Data)
+		fmt.Printf("Entries:       %s\n", block.Data())
+		fmt.Printf("Merkle Root:   %s\n", block.MerkleRoot)
 		if block.PreviousHash == "" {
 			fmt.Printf("Previous Hash: (none)\n")
 		} else {
@@ -376,42 +758,29 @@ func validateChain(bc *Blockchain) {
 }
 
 // performanceComparison は異なる難易度でのパフォーマンス比較を実行します
+// 詳細な設定は bench サブコマンドで指定できます
 func performanceComparison(_ *bufio.Reader) {
 	fmt.Println("\n⚡ パフォーマンス比較")
 	fmt.Println("異なる難易度でのマイニング性能を比較します")
+	fmt.Printf("(難易度ごとに %d 回計測, 詳細は `bench` サブコマンドを使用)\n", DefaultBenchRepetitions)
 	fmt.Println()
 
-	difficulties := []int{0, 1, 2, 3, 4}
-	data := "Performance Test Block"
-
-	fmt.Println("難易度 | 所要時間    | 試行回数  | ハッシュレート")
-	fmt.Println("──────────────────────────────────────────────")
-
-	for _, diff := range difficulties {
-		block := NewBlock(1, data, "0000000000000000000000000000000000000000000000000000000000000000", diff)
-
-		startTime := time.Now()
-		metrics, err := MineBlock(block, diff)
-		if err != nil {
-			fmt.Printf("  %d    | エラー\n", diff)
-			continue
-		}
+	results, err := RunBenchmark(BenchConfig{
+		Difficulties: []int{0, 1, 2, 3, 4},
+		Repetitions:  DefaultBenchRepetitions,
+		Data:         "Performance Test Block",
+	})
+	if err != nil {
+		fmt.Printf("❌ ベンチマークエラー: %v\n", err)
+		return
+	}
 
-		fmt.Printf("  %d    | %10v | %8d | %10.2f h/s\n",
-			diff,
-			metrics.Duration,
-			metrics.AttemptsCount,
-			metrics.HashRate,
-		)
-
-		// 難易度4以上は時間がかかるので、デモではスキップ
-		if diff >= 3 && time.Since(startTime) > 5*time.Second {
-			fmt.Println("\n⚠️  難易度が高いため、これ以上の比較を中止します")
-			break
-		}
+	if err := WriteBenchMarkdown(os.Stdout, results); err != nil {
+		fmt.Printf("❌ レポート表示エラー: %v\n", err)
+		return
 	}
 
-	fmt.Println("──────────────────────────────────────────────")
+	fmt.Println()
 	fmt.Println("難易度が1増えるごとに、平均で約16倍の時間がかかります")
 }
 
@@ -468,18 +837,44 @@ func displayDifficultyStats(bc *Blockchain) {
 	fmt.Println()
 	fmt.Println("📈 調整情報")
 	fmt.Println("────────────────────────────────────────────────────────")
-	fmt.Printf("調整間隔:           %d ブロックごと\n", AdjustmentInterval)
+	fmt.Printf("調整方式:           %s\n", stats.RetargetMode)
+	fmt.Printf("調整間隔:           %d ブロックごと\n", stats.AdjustmentEvery)
 	fmt.Printf("次回調整まで:       %d ブロック\n", stats.NextAdjustment)
 	fmt.Printf("チェーンの長さ:     %d ブロック\n", bc.GetChainLength())
 	fmt.Println("────────────────────────────────────────────────────────")
 	fmt.Println()
+	displayMiningHistoryStats(bc.GetMiningStats())
+	fmt.Println()
 	fmt.Println("💡 ヒント:")
 	fmt.Println("  - 難易度は自動調整されます")
-	fmt.Println("  - 調整は10ブロックごとに行われます")
+	if stats.RetargetMode == RetargetPerBlock {
+		fmt.Println("  - 調整は親ブロックの生成時間をもとに毎ブロック行われます（±1まで）")
+	} else {
+		fmt.Println("  - 調整は10ブロックごとに行われます")
+	}
 	fmt.Println("  - 平均時間が目標より長い場合、難易度は下がります")
 	fmt.Println("  - 平均時間が目標より短い場合、難易度は上がります")
 }
 
+// displayMiningHistoryStats はマイニング履歴の集計結果を表示します
+func displayMiningHistoryStats(stats MiningHistoryStats) {
+	fmt.Println("⛏️  マイニング履歴")
+	fmt.Println("────────────────────────────────────────────────────────")
+	if stats.Blocks == 0 {
+		fmt.Println("(まだブロックをマイニングしていません)")
+		fmt.Println("────────────────────────────────────────────────────────")
+		return
+	}
+	fmt.Printf("マイニング済み:     %d ブロック\n", stats.Blocks)
+	fmt.Printf("総ハッシュ数:       %d\n", stats.TotalHashes)
+	fmt.Printf("平均試行回数:       %.1f 回\n", stats.AverageAttempts)
+	fmt.Printf("合計時間:           %v\n", stats.TotalDuration)
+	fmt.Printf("平均ハッシュレート: %.2f hashes/sec\n", stats.AverageHashRate)
+	fmt.Printf("最短:               %v (Block #%d)\n", stats.BestSolveTime, stats.BestBlock)
+	fmt.Printf("最長:               %v (Block #%d)\n", stats.WorstSolveTime, stats.WorstBlock)
+	fmt.Println("────────────────────────────────────────────────────────")
+}
+
 // runDashboard はダッシュボードを起動します
 func runDashboard(bc *Blockchain) {
 	fmt.Println("\n🖥️  ダッシュボードを起動しています...")
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,7 +16,7 @@ func TestNewBlockchain(t *testing.T) {
 		assert.Equal(t, 1, len(bc.Blocks))
 		assert.Equal(t, 2, bc.Difficulty)
 		assert.Equal(t, int64(0), bc.Blocks[0].Index)
-		assert.Equal(t, "Genesis Block", bc.Blocks[0].Data)
+		assert.Equal(t, "Genesis Block", bc.Blocks[0].Data())
 	})
 
 	t.Run("異なる難易度でのブロックチェーン生成", func(t *testing.T) {
@@ -46,7 +47,7 @@ func TestAddBlock(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, metrics)
 		assert.Equal(t, 2, len(bc.Blocks))
-		assert.Equal(t, "Block 1", bc.Blocks[1].Data)
+		assert.Equal(t, "Block 1", bc.Blocks[1].Data())
 		assert.Equal(t, int64(1), bc.Blocks[1].Index)
 		assert.Greater(t, metrics.AttemptsCount, int64(0))
 	})
@@ -99,6 +100,34 @@ func TestAddBlock(t *testing.T) {
 	})
 }
 
+func TestAddBlockWithEntries(t *testing.T) {
+	t.Run("複数データのブロックを追加", func(t *testing.T) {
+		bc := NewBlockchain(1)
+
+		_, err := bc.AddBlockWithEntries(context.Background(), []string{"A", "B"})
+
+		require.NoError(t, err)
+		latest := bc.GetLatestBlock()
+		assert.Equal(t, []string{"A", "B"}, latest.Entries)
+		assert.Equal(t, CalculateMerkleRoot([]string{"A", "B"}), latest.MerkleRoot)
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("空のデータはエラー", func(t *testing.T) {
+		bc := NewBlockchain(1)
+
+		_, err := bc.AddBlockWithEntries(context.Background(), nil)
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, bc.GetChainLength())
+	})
+}
+
+func TestParseEntries(t *testing.T) {
+	assert.Equal(t, []string{"A", "B C", "D"}, parseEntries(" A | B C ||D\n"))
+	assert.Empty(t, parseEntries(" | \n"))
+}
+
 func TestGetLatestBlock(t *testing.T) {
 	t.Run("ジェネシスブロックのみの場合", func(t *testing.T) {
 		bc := NewBlockchain(1)
@@ -107,7 +136,7 @@ func TestGetLatestBlock(t *testing.T) {
 
 		require.NotNil(t, latest)
 		assert.Equal(t, int64(0), latest.Index)
-		assert.Equal(t, "Genesis Block", latest.Data)
+		assert.Equal(t, "Genesis Block", latest.Data())
 	})
 
 	t.Run("ブロック追加後の最新ブロック", func(t *testing.T) {
@@ -121,7 +150,7 @@ func TestGetLatestBlock(t *testing.T) {
 
 		require.NotNil(t, latest)
 		assert.Equal(t, int64(3), latest.Index)
-		assert.Equal(t, "Block 3", latest.Data)
+		assert.Equal(t, "Block 3", latest.Data())
 	})
 
 	t.Run("空のブロックチェーン", func(t *testing.T) {
@@ -197,7 +226,7 @@ func TestIsValid(t *testing.T) {
 		bc.AddBlock("Block 2")
 
 		// ブロック1のデータを改ざん
-		bc.Blocks[1].Data = "Tampered Data"
+		bc.Blocks[1].Entries[0] = "Tampered Data"
 
 		assert.False(t, bc.IsValid())
 	})
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// MetricsContentType はPrometheusのテキスト形式（v0.0.4）のContent-Type
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// activeMiners はナンス探索中のゴルーチン数
+var activeMiners atomic.Int64
+
+// ActiveMiningGoroutines は現在マイニング中のゴルーチン数を返します
+func ActiveMiningGoroutines() int64 {
+	return activeMiners.Load()
+}
+
+// MetricsHandler はマイナーの状態をPrometheus形式で公開します
+// 長時間のマイニングをGrafanaなどでグラフ化するためのものです
+type MetricsHandler struct {
+	blockchain *Blockchain
+}
+
+// NewMetricsHandler は新しい /metrics ハンドラーを作成します
+func NewMetricsHandler(bc *Blockchain) *MetricsHandler {
+	return &MetricsHandler{blockchain: bc}
+}
+
+// ServeHTTP は現在のメトリクスを返します
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "metrics requires GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", MetricsContentType)
+	WriteMetrics(w, h.blockchain)
+}
+
+// WriteMetrics はブロックチェーンのメトリクスをPrometheusのテキスト形式で書き出します
+func WriteMetrics(w io.Writer, bc *Blockchain) {
+	stats := bc.GetMiningStats()
+
+	bc.mutex.RLock()
+	height := len(bc.Blocks) - 1
+	difficulty := bc.Difficulty
+	avgBlockTime := GetAverageBlockTime(bc, AdjustmentInterval)
+	bc.mutex.RUnlock()
+
+	writeMetric(w, "minicoin_blocks_mined_total", "counter",
+		"Number of blocks mined by this node.", float64(stats.Blocks))
+	writeMetric(w, "minicoin_hash_attempts_total", "counter",
+		"Total number of hash attempts spent on mined blocks.", float64(stats.TotalHashes))
+	writeMetric(w, "minicoin_chain_height", "gauge",
+		"Index of the latest block in the chain.", float64(height))
+	writeMetric(w, "minicoin_difficulty", "gauge",
+		"Current mining difficulty (leading zero hex digits).", float64(difficulty))
+	writeMetric(w, "minicoin_average_block_time_seconds", "gauge",
+		"Average block time over the last retarget interval.", avgBlockTime)
+	writeMetric(w, "minicoin_mining_goroutines", "gauge",
+		"Number of goroutines currently searching for a nonce.", float64(ActiveMiningGoroutines()))
+}
+
+// writeMetric は1つのメトリクスをHELP・TYPE行付きで書き出します
+func writeMetric(w io.Writer, name, metricType, help string, value float64) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, metricType, name, value)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	t.Run("ブロック数・試行回数・難易度を出力する", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		metrics1, err := bc.AddBlock("Block 1")
+		require.NoError(t, err)
+		metrics2, err := bc.AddBlock("Block 2")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		WriteMetrics(&buf, bc)
+		output := buf.String()
+
+		assert.Contains(t, output, "# TYPE minicoin_blocks_mined_total counter\nminicoin_blocks_mined_total 2\n")
+		assert.Contains(t, output, "# TYPE minicoin_hash_attempts_total counter\n")
+		assert.Contains(t, output, fmt.Sprintf("minicoin_hash_attempts_total %g\n", float64(metrics1.AttemptsCount+metrics2.AttemptsCount)))
+		assert.Contains(t, output, "minicoin_chain_height 2\n")
+		assert.Contains(t, output, "# TYPE minicoin_difficulty gauge\nminicoin_difficulty 1\n")
+		assert.Contains(t, output, "# TYPE minicoin_average_block_time_seconds gauge\n")
+		assert.Contains(t, output, "minicoin_mining_goroutines 0\n")
+	})
+
+	t.Run("マイニング中のゴルーチン数を数える", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// 難易度が高いため中断されるまで探索を続ける
+			_, _ = MineBlockContext(ctx, NewBlock(1, "busy", "", 0), 64)
+		}()
+
+		require.Eventually(t, func() bool { return ActiveMiningGoroutines() == 1 }, time.Second, time.Millisecond)
+
+		var buf bytes.Buffer
+		WriteMetrics(&buf, NewBlockchain(1))
+		assert.Contains(t, buf.String(), "minicoin_mining_goroutines 1\n")
+
+		cancel()
+		<-done
+		assert.Equal(t, int64(0), ActiveMiningGoroutines())
+	})
+}
+
+func TestMetricsHandler(t *testing.T) {
+	t.Run("GETでPrometheus形式を返す", func(t *testing.T) {
+		handler := NewMetricsHandler(NewBlockchain(1))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MetricsContentType, rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), "minicoin_blocks_mined_total 0\n")
+	})
+
+	t.Run("GET以外は拒否する", func(t *testing.T) {
+		handler := NewMetricsHandler(NewBlockchain(1))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
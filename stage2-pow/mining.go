@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -11,28 +15,40 @@ import (
 
 // Block はProof of Workを含むブロックを表します
 type Block struct {
-	Index        int64  // ブロック番号
-	Timestamp    int64  // タイムスタンプ(Unix時間)
-	Data         string // ブロックに含まれるデータ
-	PreviousHash string // 前のブロックのハッシュ
-	Hash         string // このブロックのハッシュ
-	Nonce        int64  // マイニングで使用するナンス
-	Difficulty   int    // マイニング難易度
+	Index        int64    // ブロック番号
+	Timestamp    int64    // タイムスタンプ(Unix時間)
+	Entries      []string // ブロックに含まれるデータ（本体）
+	MerkleRoot   string   // Entriesのマークルルート（ヘッダーに含まれる）
+	PreviousHash string   // 前のブロックのハッシュ
+	Hash         string   // このブロックのハッシュ
+	Nonce        int64    // マイニングで使用するナンス
+	Difficulty   int      // マイニング難易度
+	Algorithm    string   `json:",omitempty"` // PoWアルゴリズム（空ならsha256）
 }
 
 // MiningMetrics はマイニングのパフォーマンス情報を記録します
 type MiningMetrics struct {
-	AttemptsCount int64         // 試行回数
-	Duration      time.Duration // マイニング時間
-	HashRate      float64       // ハッシュレート(hashes/sec)
+	AttemptsCount  int64            // 試行回数
+	Duration       time.Duration    // マイニング時間
+	HashRate       float64          // ハッシュレート(hashes/sec)
+	Algorithm      string           // 使用したPoWアルゴリズム
+	MemoryPerHash  int64            // 1回のハッシュ計算に必要な作業メモリ（バイト）
+	AllocatedBytes uint64           // マイニング中に確保されたメモリの合計（バイト）
+	Samples        []HashRateSample // 1秒ごとのハッシュレート
 }
 
-// NewBlock は新しいブロックを生成します（マイニングは未実施）
+// NewBlock は1件のデータを持つ新しいブロックを生成します（マイニングは未実施）
 func NewBlock(index int64, data string, previousHash string, difficulty int) *Block {
+	return NewBlockWithEntries(index, []string{data}, previousHash, difficulty)
+}
+
+// NewBlockWithEntries は複数のデータを持つ新しいブロックを生成します（マイニングは未実施）
+func NewBlockWithEntries(index int64, entries []string, previousHash string, difficulty int) *Block {
 	return &Block{
 		Index:        index,
 		Timestamp:    time.Now().Unix(),
-		Data:         data,
+		Entries:      entries,
+		MerkleRoot:   CalculateMerkleRoot(entries),
 		PreviousHash: previousHash,
 		Nonce:        0,
 		Difficulty:   difficulty,
@@ -53,16 +69,82 @@ func NewGenesisBlock(difficulty int) *Block {
 	return block
 }
 
-// CalculateHashWithNonce はナンスを含むハッシュを計算します
+// CalculateMerkleRoot は各データのハッシュからマークルルートを計算します
+func CalculateMerkleRoot(entries []string) string {
+	return common.EntriesMerkleRoot(entries)
+}
+
+// Data はブロックのデータを表示用に1つの文字列として返します
+func (b *Block) Data() string {
+	return strings.Join(b.Entries, " | ")
+}
+
+// CalculateHashWithNonce はナンスを含むヘッダーのハッシュを計算します
+// データ本体はマークルルートを通じてハッシュに含まれます
+// 未知のPoWアルゴリズムの場合は空文字列を返します
 func CalculateHashWithNonce(block *Block) string {
-	record := strconv.FormatInt(block.Index, 10) +
-		strconv.FormatInt(block.Timestamp, 10) +
-		block.Data +
-		block.PreviousHash +
-		strconv.FormatInt(block.Nonce, 10) +
-		strconv.Itoa(block.Difficulty)
+	algorithm, err := GetPoWAlgorithm(block.Algorithm)
+	if err != nil {
+		return ""
+	}
+
+	hash, err := hashHeader(algorithm, headerRecord(block))
+	if err != nil {
+		return ""
+	}
+	return hash
+}
+
+// headerRecord はハッシュ対象となるブロックヘッダーのバイト列を返します
+// Index + Timestamp + MerkleRoot + PreviousHash + Nonce + Difficulty を10進数・文字列のまま連結したもので、
+// minicoin-verify と共通です（common.AppendHeaderRecord）
+func headerRecord(block *Block) []byte {
+	return common.AppendHeaderRecord(nil, block.Index, block.Timestamp, block.MerkleRoot, block.PreviousHash, block.Nonce, block.Difficulty)
+}
 
-	return common.HashString(record)
+// headerBuffer はナンス以外のヘッダーを一度だけシリアライズしたバッファです
+// マイニングでは試行ごとにナンスの部分だけを書き換えるため、文字列の生成が不要になります
+type headerBuffer struct {
+	buf    []byte // ナンスより前の部分 + 書き換え領域
+	prefix int    // ナンスより前の部分の長さ
+	suffix []byte // ナンスより後ろの部分（難易度）
+}
+
+// newHeaderBuffer はブロックの静的なヘッダー部分をシリアライズします
+func newHeaderBuffer(block *Block) *headerBuffer {
+	suffix := strconv.AppendInt(nil, int64(block.Difficulty), 10)
+
+	// int64の10進数表記は符号込みで最大20文字
+	const maxInt64Digits = 20
+	buf := make([]byte, 0, 3*maxInt64Digits+len(block.MerkleRoot)+len(block.PreviousHash)+len(suffix))
+	buf = common.AppendBlockRecord(buf, block.Index, block.Timestamp, block.MerkleRoot, block.PreviousHash)
+
+	return &headerBuffer{buf: buf, prefix: len(buf), suffix: suffix}
+}
+
+// withNonce はナンスを書き込んだヘッダーを返します
+// 返されるスライスは次の呼び出しで上書きされます
+func (h *headerBuffer) withNonce(nonce int64) []byte {
+	h.buf = strconv.AppendInt(h.buf[:h.prefix], nonce, 10)
+	h.buf = append(h.buf, h.suffix...)
+	return h.buf
+}
+
+// hasLeadingZeroNibbles はハッシュ値が16進数表記で先頭にdifficulty個のゼロを持つか確認します
+// CheckHashDifficulty と同じ判定を、16進数文字列に変換せずに行います
+func hasLeadingZeroNibbles(sum []byte, difficulty int) bool {
+	if difficulty > 2*len(sum) {
+		return false
+	}
+	for i := 0; i < difficulty/2; i++ {
+		if sum[i] != 0 {
+			return false
+		}
+	}
+	if difficulty%2 == 1 {
+		return sum[difficulty/2]>>4 == 0
+	}
+	return true
 }
 
 // CheckHashDifficulty はハッシュが指定された難易度を満たすか確認します
@@ -75,31 +157,81 @@ func CheckHashDifficulty(hash string, difficulty int) bool {
 // MineBlock はブロックをマイニングします
 // ハッシュが難易度条件を満たすまでナンスをインクリメントします
 func MineBlock(block *Block, difficulty int) (*MiningMetrics, error) {
+	return MineBlockContext(context.Background(), block, difficulty)
+}
+
+// MineBlockContext はキャンセル可能なマイニングを行います
+// ctxがキャンセルされると、一定間隔のチェック時にマイニングを中断してctxのエラーを返します
+func MineBlockContext(ctx context.Context, block *Block, difficulty int) (*MiningMetrics, error) {
+	return mineBlock(ctx, block, difficulty, 0, nil)
+}
+
+// MineBlockWithProgress はマイニング中の1秒ごとのハッシュレートをprogressに送信します
+// 受信が追いつかない場合、そのサンプルは送信されません（MiningMetrics.Samplesには残ります）
+func MineBlockWithProgress(ctx context.Context, block *Block, difficulty int, progress chan<- HashRateSample) (*MiningMetrics, error) {
+	return mineBlock(ctx, block, difficulty, 0, progress)
+}
+
+// mineBlock はstartNonceからナンスを探索してマイニングします
+func mineBlock(ctx context.Context, block *Block, difficulty int, startNonce int64, progress chan<- HashRateSample) (*MiningMetrics, error) {
 	if difficulty < 0 {
 		return nil, fmt.Errorf("difficulty must be non-negative")
 	}
 
+	algorithm, err := GetPoWAlgorithm(block.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	activeMiners.Add(1)
+	defer activeMiners.Add(-1)
+
 	block.Difficulty = difficulty
+	block.MerkleRoot = CalculateMerkleRoot(block.Entries)
 	startTime := time.Now()
 	attempts := int64(0)
+	sampler := newHashRateSampler(startTime, progress)
+
+	// マイニング中のメモリ確保量を計測する
+	var memBefore runtime.MemStats
+	runtime.ReadMemStats(&memBefore)
+
+	// エコモードが有効な場合は一定間隔で作業時間をガバナーに報告する
+	governor := GetMiningGovernor()
+	segmentStart := startTime
 
-	// ナンスを0から開始
-	block.Nonce = 0
+	// ナンスを開始値から探索
+	block.Nonce = startNonce
+
+	// ナンス以外のヘッダーは探索中に変わらないため、一度だけシリアライズする
+	header := newHeaderBuffer(block)
+	sum := make([]byte, 0, sha256.Size)
 
 	for {
 		// ハッシュを計算
-		hash := CalculateHashWithNonce(block)
+		sum, err = algorithm.Sum(sum[:0], header.withNonce(block.Nonce))
+		if err != nil {
+			return nil, err
+		}
 		attempts++
 
 		// 難易度条件を満たすか確認
-		if CheckHashDifficulty(hash, difficulty) {
-			block.Hash = hash
-			duration := time.Since(startTime)
+		if hasLeadingZeroNibbles(sum, difficulty) {
+			block.Hash = hex.EncodeToString(sum)
+			finishedAt := time.Now()
+			duration := finishedAt.Sub(startTime)
+
+			var memAfter runtime.MemStats
+			runtime.ReadMemStats(&memAfter)
 
 			// メトリクスを計算
 			metrics := &MiningMetrics{
-				AttemptsCount: attempts,
-				Duration:      duration,
+				AttemptsCount:  attempts,
+				Duration:       duration,
+				Algorithm:      algorithm.Name(),
+				MemoryPerHash:  algorithm.MemoryPerHash(),
+				AllocatedBytes: memAfter.TotalAlloc - memBefore.TotalAlloc,
+				Samples:        sampler.finish(finishedAt, attempts),
 			}
 
 			if duration.Seconds() > 0 {
@@ -109,6 +241,22 @@ func MineBlock(block *Block, difficulty int) (*MiningMetrics, error) {
 			return metrics, nil
 		}
 
+		if attempts%SampleCheckInterval == 0 {
+			sampler.observe(time.Now(), attempts)
+		}
+
+		if attempts%GovernorCheckInterval == 0 {
+			// 中断要求の確認
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("mining aborted: %w", err)
+			}
+
+			if governor != nil {
+				governor.Pace(time.Since(segmentStart), GovernorCheckInterval)
+				segmentStart = time.Now()
+			}
+		}
+
 		// ナンスをインクリメント
 		block.Nonce++
 
@@ -120,7 +268,12 @@ func MineBlock(block *Block, difficulty int) (*MiningMetrics, error) {
 }
 
 // ValidateProofOfWork はブロックのProof of Workを検証します
+// ヘッダーのマークルルートがデータ本体と一致することも確認します
 func ValidateProofOfWork(block *Block) bool {
+	if CalculateMerkleRoot(block.Entries) != block.MerkleRoot {
+		return false
+	}
+
 	// ハッシュを再計算
 	calculatedHash := CalculateHashWithNonce(block)
 
@@ -143,19 +296,23 @@ func (b *Block) String() string {
 	return fmt.Sprintf(
 		"Block #%d [%s]\n"+
 			"  Timestamp: %s\n"+
-			"  Data: %s\n"+
+			"  Entries: %s\n"+
+			"  Merkle Root: %s\n"+
 			"  Previous Hash: %s\n"+
 			"  Hash: %s\n"+
 			"  Nonce: %d\n"+
-			"  Difficulty: %d",
+			"  Difficulty: %d\n"+
+			"  Algorithm: %s",
 		b.Index,
 		common.FormatTimestamp(b.Timestamp),
 		common.FormatTimestamp(b.Timestamp),
-		b.Data,
+		b.Data(),
+		b.MerkleRoot,
 		b.PreviousHash,
 		b.Hash,
 		b.Nonce,
 		b.Difficulty,
+		b.algorithmName(),
 	)
 }
 
@@ -163,3 +320,11 @@ func (b *Block) String() string {
 func GetDifficultyPrefix(difficulty int) string {
 	return strings.Repeat("0", difficulty)
 }
+
+// algorithmName はブロックのPoWアルゴリズム名を返します（空ならsha256）
+func (b *Block) algorithmName() string {
+	if b.Algorithm == "" {
+		return PoWSHA256
+	}
+	return b.Algorithm
+}
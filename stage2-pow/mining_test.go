@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/hex"
+	"math"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/nyasuto/minicoin/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -14,7 +18,7 @@ func TestNewBlock(t *testing.T) {
 		block := NewBlock(1, "Test Data", "previous_hash", 2)
 
 		assert.Equal(t, int64(1), block.Index)
-		assert.Equal(t, "Test Data", block.Data)
+		assert.Equal(t, "Test Data", block.Data())
 		assert.Equal(t, "previous_hash", block.PreviousHash)
 		assert.Equal(t, int64(0), block.Nonce)
 		assert.Equal(t, 2, block.Difficulty)
@@ -38,7 +42,7 @@ func TestNewGenesisBlock(t *testing.T) {
 
 		require.NotNil(t, genesis)
 		assert.Equal(t, int64(0), genesis.Index)
-		assert.Equal(t, "Genesis Block", genesis.Data)
+		assert.Equal(t, "Genesis Block", genesis.Data())
 		assert.Equal(t, "", genesis.PreviousHash)
 		assert.Equal(t, 0, genesis.Difficulty)
 		assert.NotEmpty(t, genesis.Hash) // マイニング済み
@@ -121,6 +125,104 @@ func TestCalculateHashWithNonce(t *testing.T) {
 	})
 }
 
+func TestCalculateMerkleRoot(t *testing.T) {
+	t.Run("1件のデータはそのハッシュがルートになる", func(t *testing.T) {
+		root := CalculateMerkleRoot([]string{"A"})
+
+		assert.Equal(t, common.HashString("A"), root)
+	})
+
+	t.Run("データの順序と内容に依存する", func(t *testing.T) {
+		root := CalculateMerkleRoot([]string{"A", "B", "C"})
+
+		assert.Len(t, root, 64)
+		assert.NotEqual(t, root, CalculateMerkleRoot([]string{"B", "A", "C"}))
+		assert.NotEqual(t, root, CalculateMerkleRoot([]string{"A", "B"}))
+		assert.Equal(t, root, CalculateMerkleRoot([]string{"A", "B", "C"}))
+	})
+}
+
+func TestBlockEntries(t *testing.T) {
+	t.Run("複数データのブロックをマイニング", func(t *testing.T) {
+		block := NewBlockWithEntries(1, []string{"tx1", "tx2", "tx3"}, "previous_hash", 1)
+		_, err := MineBlock(block, 1)
+
+		require.NoError(t, err)
+		assert.Equal(t, CalculateMerkleRoot(block.Entries), block.MerkleRoot)
+		assert.Equal(t, "tx1 | tx2 | tx3", block.Data())
+		assert.True(t, ValidateProofOfWork(block))
+	})
+
+	t.Run("本体のデータだけを改ざんすると無効", func(t *testing.T) {
+		block := NewBlockWithEntries(1, []string{"tx1", "tx2"}, "previous_hash", 1)
+		_, _ = MineBlock(block, 1)
+
+		block.Entries[1] = "tampered"
+
+		assert.False(t, ValidateProofOfWork(block))
+	})
+
+	t.Run("データを追加すると無効", func(t *testing.T) {
+		block := NewBlockWithEntries(1, []string{"tx1"}, "previous_hash", 1)
+		_, _ = MineBlock(block, 1)
+
+		block.Entries = append(block.Entries, "injected")
+
+		assert.False(t, ValidateProofOfWork(block))
+	})
+}
+
+// concatHeaderRecord は文字列連結による従来のヘッダー生成です（比較用）
+func concatHeaderRecord(block *Block) string {
+	return strconv.FormatInt(block.Index, 10) +
+		strconv.FormatInt(block.Timestamp, 10) +
+		block.MerkleRoot +
+		block.PreviousHash +
+		strconv.FormatInt(block.Nonce, 10) +
+		strconv.Itoa(block.Difficulty)
+}
+
+func TestHeaderBuffer(t *testing.T) {
+	t.Run("文字列連結と同じヘッダーを生成する", func(t *testing.T) {
+		block := NewBlock(12, "Header Block", "previous_hash", 3)
+		header := newHeaderBuffer(block)
+
+		// 桁数が増減してもナンスより後ろの部分が正しく書き直される
+		for _, nonce := range []int64{0, 9, 10, 999999999, 1000000000, 7, math.MaxInt64, 42} {
+			block.Nonce = nonce
+			assert.Equal(t, concatHeaderRecord(block), string(header.withNonce(nonce)), "nonce %d", nonce)
+		}
+	})
+
+	t.Run("headerRecordも同じ結果", func(t *testing.T) {
+		block := NewBlock(1, "Header Block", "previous_hash", 2)
+		block.Nonce = 12345
+
+		assert.Equal(t, concatHeaderRecord(block), string(headerRecord(block)))
+	})
+}
+
+func TestHasLeadingZeroNibbles(t *testing.T) {
+	sums := [][]byte{
+		common.Hash([]byte("a")),
+		{0x00, 0x00, 0x0f, 0xff},
+		{0x00, 0x01, 0xff, 0xff},
+		{0x0f, 0xff, 0xff, 0xff},
+		{0x00, 0x00, 0x00, 0x00},
+	}
+
+	for _, sum := range sums {
+		for difficulty := 0; difficulty <= 2*len(sum); difficulty++ {
+			expected := CheckHashDifficulty(hex.EncodeToString(sum), difficulty)
+			assert.Equal(t, expected, hasLeadingZeroNibbles(sum, difficulty), "%x difficulty %d", sum, difficulty)
+		}
+	}
+
+	t.Run("ハッシュ長を超える難易度は満たさない", func(t *testing.T) {
+		assert.False(t, hasLeadingZeroNibbles(make([]byte, 32), 65))
+	})
+}
+
 func TestCheckHashDifficulty(t *testing.T) {
 	t.Run("難易度0（制約なし）", func(t *testing.T) {
 		hash := "abcdef1234567890"
@@ -236,7 +338,7 @@ func TestMineBlock(t *testing.T) {
 
 	t.Run("マイニング後のブロック状態", func(t *testing.T) {
 		block := NewBlock(1, "Test Block", "previous_hash", 2)
-		originalData := block.Data
+		originalData := block.Data()
 		originalIndex := block.Index
 		originalPreviousHash := block.PreviousHash
 
@@ -245,7 +347,7 @@ func TestMineBlock(t *testing.T) {
 		require.NoError(t, err)
 
 		// データが変更されていないことを確認
-		assert.Equal(t, originalData, block.Data)
+		assert.Equal(t, originalData, block.Data())
 		assert.Equal(t, originalIndex, block.Index)
 		assert.Equal(t, originalPreviousHash, block.PreviousHash)
 
@@ -289,7 +391,7 @@ func TestValidateProofOfWork(t *testing.T) {
 		_, _ = MineBlock(block, 2)
 
 		// データを改ざん
-		block.Data = "Tampered Data"
+		block.Entries[0] = "Tampered Data"
 
 		assert.False(t, ValidateProofOfWork(block))
 	})
@@ -337,7 +439,7 @@ func TestBlockValidate(t *testing.T) {
 		block := NewBlock(1, "Test Block", "previous_hash", 2)
 		_, _ = MineBlock(block, 2)
 
-		block.Data = "Tampered"
+		block.Entries[0] = "Tampered"
 
 		assert.False(t, block.Validate())
 	})
@@ -460,7 +562,7 @@ func TestEdgeCases(t *testing.T) {
 
 		require.NoError(t, err)
 		assert.NotNil(t, metrics)
-		assert.Equal(t, longData, block.Data)
+		assert.Equal(t, longData, block.Data())
 	})
 
 	t.Run("空のPreviousHashでマイニング", func(t *testing.T) {
@@ -510,6 +612,31 @@ func BenchmarkCalculateHashWithNonce(b *testing.B) {
 	}
 }
 
+// BenchmarkHashAttemptConcat は従来の1試行（文字列連結 + 16進数文字列での判定）を計測します
+func BenchmarkHashAttemptConcat(b *testing.B) {
+	block := NewBlock(1, "Benchmark Block", "previous_hash", 8)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		block.Nonce = int64(i)
+		CheckHashDifficulty(common.HashString(concatHeaderRecord(block)), block.Difficulty)
+	}
+}
+
+// BenchmarkHashAttemptBuffer はマイニングループの1試行（ナンスの書き換え + バイト列での判定）を計測します
+func BenchmarkHashAttemptBuffer(b *testing.B) {
+	block := NewBlock(1, "Benchmark Block", "previous_hash", 8)
+	algorithm, _ := GetPoWAlgorithm(PoWSHA256)
+	header := newHeaderBuffer(block)
+	sum := make([]byte, 0, 32)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sum, _ = algorithm.Sum(sum[:0], header.withNonce(int64(i)))
+		hasLeadingZeroNibbles(sum, block.Difficulty)
+	}
+}
+
 func BenchmarkCheckHashDifficulty(b *testing.B) {
 	hash := "000abcdef1234567890"
 	b.ResetTimer()
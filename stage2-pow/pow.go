@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// サポートするPoWアルゴリズム
+const (
+	PoWSHA256 = common.PoWSHA256 // 計算量のみに依存（ASICで高速化しやすい）
+	PoWScrypt = common.PoWScrypt // メモリハード（ASIC耐性を狙ったもの）
+)
+
+// PoWAlgorithm はProof of Workに使用するハッシュ関数です
+// どのアルゴリズムも16進数のハッシュを返すため、難易度判定は共通です
+type PoWAlgorithm interface {
+	// Name はアルゴリズム名を返します
+	Name() string
+
+	// Sum はブロックヘッダーのレコードをハッシュ化し、dstに追記して返します
+	// マイニングの試行ごとのメモリ割り当てを避けるため、文字列ではなくバイト列を扱います
+	Sum(dst, record []byte) ([]byte, error)
+
+	// MemoryPerHash は1回のハッシュ計算に必要な作業メモリ（バイト）を返します
+	MemoryPerHash() int64
+}
+
+// sha256PoW はSHA-256によるPoWです
+type sha256PoW struct{}
+
+func (sha256PoW) Name() string { return PoWSHA256 }
+
+func (sha256PoW) Sum(dst, record []byte) ([]byte, error) {
+	sum := sha256.Sum256(record)
+	return append(dst, sum[:]...), nil
+}
+
+func (sha256PoW) MemoryPerHash() int64 {
+	// 内部状態と1ブロック分のバッファ程度
+	return sha256.Size + sha256.BlockSize
+}
+
+// scryptPoW はscryptによるメモリハードなPoWです
+type scryptPoW struct{}
+
+func (scryptPoW) Name() string { return PoWScrypt }
+
+func (scryptPoW) Sum(dst, record []byte) ([]byte, error) {
+	key, err := common.ScryptHash(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, key...), nil
+}
+
+func (scryptPoW) MemoryPerHash() int64 {
+	return common.ScryptMemoryPerHash
+}
+
+// hashHeader はヘッダーのレコードをハッシュ化して16進数の文字列で返します
+func hashHeader(algorithm PoWAlgorithm, record []byte) (string, error) {
+	sum, err := algorithm.Sum(nil, record)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// GetPoWAlgorithm は名前からPoWアルゴリズムを返します
+// 空文字列は従来のブロックとの互換性のためsha256として扱います
+func GetPoWAlgorithm(name string) (PoWAlgorithm, error) {
+	switch name {
+	case "", PoWSHA256:
+		return sha256PoW{}, nil
+	case PoWScrypt:
+		return scryptPoW{}, nil
+	default:
+		return nil, fmt.Errorf("unknown PoW algorithm: %s (sha256, scrypt)", name)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPoWAlgorithm(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"空文字列はsha256", "", PoWSHA256},
+		{"sha256", PoWSHA256, PoWSHA256},
+		{"scrypt", PoWScrypt, PoWScrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm, err := GetPoWAlgorithm(tt.input)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, algorithm.Name())
+		})
+	}
+
+	t.Run("未知のアルゴリズム", func(t *testing.T) {
+		_, err := GetPoWAlgorithm("argon2")
+		assert.Error(t, err)
+	})
+
+	t.Run("scryptはsha256より多くのメモリを使う", func(t *testing.T) {
+		sha, _ := GetPoWAlgorithm(PoWSHA256)
+		scrypt, _ := GetPoWAlgorithm(PoWScrypt)
+
+		assert.Equal(t, int64(common.ScryptMemoryPerHash), scrypt.MemoryPerHash())
+		assert.Greater(t, scrypt.MemoryPerHash(), sha.MemoryPerHash()*1000)
+	})
+}
+
+func TestScryptMining(t *testing.T) {
+	t.Run("scryptでマイニングして検証", func(t *testing.T) {
+		block := NewBlock(1, "Scrypt Block", "previous_hash", 1)
+		block.Algorithm = PoWScrypt
+
+		metrics, err := MineBlock(block, 1)
+
+		require.NoError(t, err)
+		assert.True(t, CheckHashDifficulty(block.Hash, 1))
+		assert.True(t, ValidateProofOfWork(block))
+		assert.Equal(t, PoWScrypt, metrics.Algorithm)
+		assert.Equal(t, int64(common.ScryptMemoryPerHash), metrics.MemoryPerHash)
+		assert.Greater(t, metrics.AllocatedBytes, uint64(0))
+
+		// 同じヘッダーでもsha256では一致しない
+		block.Algorithm = PoWSHA256
+		assert.False(t, ValidateProofOfWork(block))
+	})
+
+	t.Run("未知のアルゴリズムではマイニングできない", func(t *testing.T) {
+		block := NewBlock(1, "Block", "previous_hash", 1)
+		block.Algorithm = "unknown"
+
+		_, err := MineBlock(block, 1)
+
+		assert.Error(t, err)
+		assert.False(t, ValidateProofOfWork(block))
+	})
+
+	t.Run("scryptのチェーン", func(t *testing.T) {
+		bc, err := NewBlockchainWithPoW(1, PoWScrypt)
+		require.NoError(t, err)
+
+		_, err = bc.AddBlock("Block 1")
+
+		require.NoError(t, err)
+		assert.Equal(t, PoWScrypt, bc.GetLatestBlock().Algorithm)
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("sha256のチェーンは従来の形式で保存", func(t *testing.T) {
+		bc, err := NewBlockchainWithPoW(1, PoWSHA256)
+		require.NoError(t, err)
+
+		assert.Empty(t, bc.Blocks[0].Algorithm)
+		assert.Contains(t, bc.Blocks[0].String(), "Algorithm: sha256")
+	})
+
+	t.Run("未知のアルゴリズムのチェーンはエラー", func(t *testing.T) {
+		_, err := NewBlockchainWithPoW(1, "unknown")
+		assert.Error(t, err)
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "2.0 KiB", formatBytes(2048))
+	assert.Equal(t, "1.0 MiB", formatBytes(common.ScryptMemoryPerHash))
+}
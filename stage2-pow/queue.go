@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WorkItem はマイニング待ちのデータです
+type WorkItem struct {
+	ID         int64     // 追加順の番号
+	Entries    []string  // ブロックに含めるデータ
+	Priority   int       // 優先度（大きいほど先にマイニングする）
+	EnqueuedAt time.Time // キューに追加した時刻
+}
+
+// WorkResult はキューから取り出したデータのマイニング結果です
+type WorkResult struct {
+	Item    WorkItem
+	Block   *Block         // 追加されたブロック（失敗時はnil）
+	Metrics *MiningMetrics // マイニングのパフォーマンス情報（失敗時はnil）
+	Err     error
+}
+
+// WorkQueue はマイニング待ちのデータを保持し、バックグラウンドで1件ずつブロックにします
+// 優先度の高いものから、同じ優先度なら追加した順にマイニングします
+type WorkQueue struct {
+	blockchain *Blockchain
+	// OnMined は1件のマイニングが終わるたびに呼ばれます（nilなら何もしない）
+	OnMined func(WorkResult)
+
+	items         []WorkItem
+	current       *WorkItem
+	currentStart  time.Time
+	nextID        int64
+	difficulty    int // 次のブロックの難易度（マイニング中にチェーンのロックを待たないよう保持する）
+	processed     int
+	totalAttempts int64
+	totalDuration time.Duration
+	mutex         sync.Mutex
+	notify        chan struct{}
+}
+
+// NewWorkQueue は新しいマイニングキューを作成します
+func NewWorkQueue(bc *Blockchain) *WorkQueue {
+	return &WorkQueue{
+		blockchain: bc,
+		nextID:     1,
+		difficulty: bc.Difficulty,
+		notify:     make(chan struct{}, 1),
+	}
+}
+
+// Enqueue はデータをキューに追加します
+func (q *WorkQueue) Enqueue(entries []string, priority int) (WorkItem, error) {
+	if len(entries) == 0 {
+		return WorkItem{}, fmt.Errorf("block must contain at least one entry")
+	}
+
+	q.mutex.Lock()
+	item := WorkItem{
+		ID:         q.nextID,
+		Entries:    entries,
+		Priority:   priority,
+		EnqueuedAt: time.Now(),
+	}
+	q.nextID++
+	q.insertLocked(item)
+	q.mutex.Unlock()
+
+	// 待機中のマイナーを起こす
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return item, nil
+}
+
+// insertLocked は優先度の順序を保ったまま追加します
+func (q *WorkQueue) insertLocked(item WorkItem) {
+	i := sort.Search(len(q.items), func(i int) bool {
+		other := q.items[i]
+		if other.Priority != item.Priority {
+			return other.Priority < item.Priority
+		}
+		return other.ID > item.ID
+	})
+	q.items = append(q.items, WorkItem{})
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = item
+}
+
+// Len はマイニング待ちの件数を返します（マイニング中のものは含みません）
+func (q *WorkQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.items)
+}
+
+// Pending はマイニング待ちのデータをマイニングする順に返します
+func (q *WorkQueue) Pending() []WorkItem {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	items := make([]WorkItem, len(q.items))
+	copy(items, q.items)
+	return items
+}
+
+// Current はマイニング中のデータを返します
+func (q *WorkQueue) Current() (WorkItem, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.current == nil {
+		return WorkItem{}, false
+	}
+	return *q.current, true
+}
+
+// Processed はマイニングを終えた件数を返します
+func (q *WorkQueue) Processed() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.processed
+}
+
+// ETA はキューが空になるまでの推定時間を返します
+// 1ブロックの期待試行回数（16^難易度）をこのキューで計測したハッシュレートで割って見積もるため、
+// まだ1件もマイニングしていない場合は推定できません（falseを返します）
+func (q *WorkQueue) ETA() (time.Duration, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.totalAttempts == 0 || q.totalDuration <= 0 {
+		return 0, false
+	}
+	hashRate := float64(q.totalAttempts) / q.totalDuration.Seconds()
+	perBlock := time.Duration(BlockWork(q.difficulty) / hashRate * float64(time.Second))
+
+	eta := perBlock * time.Duration(len(q.items))
+	if q.current != nil {
+		if remaining := perBlock - time.Since(q.currentStart); remaining > 0 {
+			eta += remaining
+		}
+	}
+	return eta, true
+}
+
+// Run はctxがキャンセルされるまでキューのデータを1件ずつマイニングします
+// マイニング中にキャンセルされたデータはキューに戻されます
+func (q *WorkQueue) Run(ctx context.Context) error {
+	for {
+		item, ok := q.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-q.notify:
+			}
+			continue
+		}
+
+		metrics, err := q.blockchain.AddBlockWithEntries(ctx, item.Entries)
+		if err != nil && ctx.Err() != nil {
+			q.requeue(item)
+			return ctx.Err()
+		}
+
+		result := WorkResult{Item: item, Metrics: metrics, Err: err}
+		if err == nil {
+			result.Block = q.blockchain.GetLatestBlock()
+		}
+		q.finish(result, q.blockchain.Difficulty)
+		if q.OnMined != nil {
+			q.OnMined(result)
+		}
+	}
+}
+
+// next は次にマイニングするデータを取り出します
+func (q *WorkQueue) next() (WorkItem, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.items) == 0 {
+		return WorkItem{}, false
+	}
+	item := q.items[0]
+	q.items = q.items[1:]
+	q.current = &item
+	q.currentStart = time.Now()
+	return item, true
+}
+
+// requeue は中断されたデータを元の順序でキューに戻します
+func (q *WorkQueue) requeue(item WorkItem) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.current = nil
+	q.insertLocked(item)
+}
+
+// finish はマイニング結果をキューの統計に反映します
+func (q *WorkQueue) finish(result WorkResult, difficulty int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.current = nil
+	q.difficulty = difficulty
+	q.processed++
+	if result.Metrics != nil {
+		q.totalAttempts += result.Metrics.AttemptsCount
+		q.totalDuration += result.Metrics.Duration
+	}
+}
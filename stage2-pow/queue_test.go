@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkQueueEnqueue(t *testing.T) {
+	t.Run("優先度の高い順、同じ優先度なら追加順に並ぶ", func(t *testing.T) {
+		queue := NewWorkQueue(NewBlockchain(1))
+
+		_, err := queue.Enqueue([]string{"a"}, 0)
+		require.NoError(t, err)
+		_, err = queue.Enqueue([]string{"b"}, 5)
+		require.NoError(t, err)
+		_, err = queue.Enqueue([]string{"c"}, 0)
+		require.NoError(t, err)
+		_, err = queue.Enqueue([]string{"d"}, 5)
+		require.NoError(t, err)
+
+		var order []string
+		for _, item := range queue.Pending() {
+			order = append(order, item.Entries[0])
+		}
+		assert.Equal(t, []string{"b", "d", "a", "c"}, order)
+		assert.Equal(t, 4, queue.Len())
+	})
+
+	t.Run("空のデータはエラー", func(t *testing.T) {
+		queue := NewWorkQueue(NewBlockchain(1))
+
+		_, err := queue.Enqueue(nil, 0)
+		assert.Error(t, err)
+		assert.Equal(t, 0, queue.Len())
+	})
+
+	t.Run("マイニング前はETAを推定できない", func(t *testing.T) {
+		queue := NewWorkQueue(NewBlockchain(1))
+		_, err := queue.Enqueue([]string{"a"}, 0)
+		require.NoError(t, err)
+
+		_, ok := queue.ETA()
+		assert.False(t, ok)
+	})
+}
+
+func TestWorkQueueRun(t *testing.T) {
+	t.Run("キューのデータを順番にブロックにする", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		queue := NewWorkQueue(bc)
+		results := make(chan WorkResult, 3)
+		queue.OnMined = func(result WorkResult) {
+			results <- result
+		}
+
+		for _, data := range []string{"first", "second", "third"} {
+			_, err := queue.Enqueue([]string{data}, 0)
+			require.NoError(t, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			_ = queue.Run(ctx)
+		}()
+
+		for i, data := range []string{"first", "second", "third"} {
+			select {
+			case result := <-results:
+				require.NoError(t, result.Err)
+				assert.Equal(t, int64(i+1), result.Block.Index)
+				assert.Equal(t, []string{data}, result.Block.Entries)
+				assert.NotNil(t, result.Metrics)
+			case <-time.After(5 * time.Second):
+				t.Fatal("マイニングが完了しませんでした")
+			}
+		}
+
+		assert.Equal(t, 4, bc.GetChainLength())
+		assert.True(t, bc.IsValid())
+		assert.Equal(t, 3, queue.Processed())
+		assert.Equal(t, 0, queue.Len())
+
+		eta, ok := queue.ETA()
+		assert.True(t, ok)
+		assert.Equal(t, time.Duration(0), eta)
+	})
+
+	t.Run("キャンセルされたデータはキューに戻る", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		bc.Difficulty = 10 // 完了しない難易度
+		queue := NewWorkQueue(bc)
+		_, err := queue.Enqueue([]string{"never"}, 0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- queue.Run(ctx)
+		}()
+
+		require.Eventually(t, func() bool {
+			_, ok := queue.Current()
+			return ok
+		}, time.Second, time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Runが終了しませんでした")
+		}
+
+		assert.Equal(t, 1, queue.Len())
+		_, ok := queue.Current()
+		assert.False(t, ok)
+		assert.Equal(t, 1, bc.GetChainLength())
+	})
+}
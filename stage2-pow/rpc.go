@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// JSON-RPC 2.0 のエラーコード
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+)
+
+// MaxGenerateBlocks は generate で一度にマイニングできる最大ブロック数
+const MaxGenerateBlocks = 100
+
+// RPCRequest はJSON-RPC 2.0のリクエストです
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// RPCResponse はJSON-RPC 2.0のレスポンスです
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// RPCError はJSON-RPC 2.0のエラーオブジェクトです
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error はerrorインターフェースを実装します
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// RPCServer はマイナーを操作するJSON-RPCサーバーです
+type RPCServer struct {
+	blockchain *Blockchain
+}
+
+// NewRPCServer は新しいJSON-RPCサーバーを作成します
+func NewRPCServer(bc *Blockchain) *RPCServer {
+	return &RPCServer{blockchain: bc}
+}
+
+// ServeHTTP はHTTP POSTで受け取ったJSON-RPCリクエストを処理します
+func (s *RPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var response RPCResponse
+	var request RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		response = RPCResponse{
+			JSONRPC: "2.0",
+			Error:   &RPCError{Code: RPCParseError, Message: "parse error"},
+			ID:      json.RawMessage("null"),
+		}
+	} else {
+		response = s.Handle(&request)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Handle は1件のJSON-RPCリクエストを処理してレスポンスを返します
+func (s *RPCServer) Handle(request *RPCRequest) RPCResponse {
+	response := RPCResponse{JSONRPC: "2.0", ID: request.ID}
+	if response.ID == nil {
+		response.ID = json.RawMessage("null")
+	}
+
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		response.Error = &RPCError{Code: RPCInvalidRequest, Message: "invalid request"}
+		return response
+	}
+
+	var result interface{}
+	var err *RPCError
+
+	switch request.Method {
+	case "getblockcount":
+		result = s.blockchain.GetChainLength() - 1
+	case "getdifficulty":
+		result = s.blockchain.Difficulty
+	case "getblock":
+		result, err = s.getBlock(request.Params)
+	case "generate":
+		result, err = s.generate(request.Params)
+	default:
+		err = &RPCError{Code: RPCMethodNotFound, Message: fmt.Sprintf("method not found: %s", request.Method)}
+	}
+
+	if err != nil {
+		response.Error = err
+	} else {
+		response.Result = result
+	}
+
+	return response
+}
+
+// getBlock は [height] で指定されたブロックを返します
+func (s *RPCServer) getBlock(params json.RawMessage) (interface{}, *RPCError) {
+	var args []int64
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+		return nil, &RPCError{Code: RPCInvalidParams, Message: "getblock expects [height]"}
+	}
+
+	s.blockchain.mutex.RLock()
+	defer s.blockchain.mutex.RUnlock()
+
+	height := args[0]
+	if height < 0 || height >= int64(len(s.blockchain.Blocks)) {
+		return nil, &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("block height out of range: %d", height)}
+	}
+
+	return s.blockchain.Blocks[height], nil
+}
+
+// generate は [n] 個のブロックをマイニングし、そのハッシュ一覧を返します
+func (s *RPCServer) generate(params json.RawMessage) (interface{}, *RPCError) {
+	var args []int
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+		return nil, &RPCError{Code: RPCInvalidParams, Message: "generate expects [n]"}
+	}
+
+	count := args[0]
+	if count <= 0 || count > MaxGenerateBlocks {
+		return nil, &RPCError{Code: RPCInvalidParams, Message: fmt.Sprintf("n must be between 1 and %d", MaxGenerateBlocks)}
+	}
+
+	hashes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		data := fmt.Sprintf("RPC block #%d", s.blockchain.GetChainLength())
+		if _, err := s.blockchain.AddBlock(data); err != nil {
+			return nil, &RPCError{Code: RPCInternalError, Message: err.Error()}
+		}
+		hashes = append(hashes, s.blockchain.GetLatestBlock().Hash)
+	}
+
+	return hashes, nil
+}
+
+// startHTTPServer はJSON-RPCサーバーをバックグラウンドで起動します
+// /ws ではマイニングイベントをWebSocketで配信し、/metrics ではPrometheus形式のメトリクスを公開します
+func startHTTPServer(addr string, bc *Blockchain) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/", NewRPCServer(bc))
+	mux.Handle("/ws", NewBlockFeed(bc))
+	mux.Handle("/metrics", NewMetricsHandler(bc))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("❌ RPCサーバーエラー: %v\n", err)
+		}
+	}()
+
+	return server
+}
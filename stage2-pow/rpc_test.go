@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callRPC はテスト用にJSON-RPCリクエストを送信してレスポンスを返します
+func callRPC(t *testing.T, server http.Handler, body string) RPCResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var response RPCResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func TestRPCServer(t *testing.T) {
+	t.Run("getblockcount", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		_, _ = bc.AddBlock("Block 1")
+		server := NewRPCServer(bc)
+
+		response := callRPC(t, server, `{"jsonrpc":"2.0","method":"getblockcount","id":1}`)
+
+		assert.Nil(t, response.Error)
+		assert.Equal(t, float64(1), response.Result)
+		assert.Equal(t, json.RawMessage("1"), response.ID)
+	})
+
+	t.Run("getdifficulty", func(t *testing.T) {
+		bc := NewBlockchain(2)
+		server := NewRPCServer(bc)
+
+		response := callRPC(t, server, `{"jsonrpc":"2.0","method":"getdifficulty","id":"a"}`)
+
+		assert.Nil(t, response.Error)
+		assert.Equal(t, float64(2), response.Result)
+	})
+
+	t.Run("getblock", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := NewRPCServer(bc)
+
+		response := callRPC(t, server, `{"jsonrpc":"2.0","method":"getblock","params":[0],"id":1}`)
+
+		require.Nil(t, response.Error)
+		block, ok := response.Result.(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, bc.Blocks[0].Hash, block["Hash"])
+	})
+
+	t.Run("getblockの範囲外はエラー", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := NewRPCServer(bc)
+
+		response := callRPC(t, server, `{"jsonrpc":"2.0","method":"getblock","params":[5],"id":1}`)
+
+		require.NotNil(t, response.Error)
+		assert.Equal(t, RPCInvalidParams, response.Error.Code)
+	})
+
+	t.Run("generate", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := NewRPCServer(bc)
+
+		response := callRPC(t, server, `{"jsonrpc":"2.0","method":"generate","params":[3],"id":1}`)
+
+		require.Nil(t, response.Error)
+		hashes, ok := response.Result.([]interface{})
+		require.True(t, ok)
+		assert.Len(t, hashes, 3)
+		assert.Equal(t, 4, bc.GetChainLength())
+		assert.Equal(t, bc.GetLatestBlock().Hash, hashes[2])
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("generateの不正なパラメータ", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := NewRPCServer(bc)
+
+		invalid := []string{
+			`{"jsonrpc":"2.0","method":"generate","params":[0],"id":1}`,
+			`{"jsonrpc":"2.0","method":"generate","params":[1000],"id":1}`,
+			`{"jsonrpc":"2.0","method":"generate","params":"x","id":1}`,
+		}
+
+		for _, body := range invalid {
+			response := callRPC(t, server, body)
+			require.NotNil(t, response.Error, body)
+			assert.Equal(t, RPCInvalidParams, response.Error.Code)
+		}
+		assert.Equal(t, 1, bc.GetChainLength())
+	})
+
+	t.Run("未知のメソッド", func(t *testing.T) {
+		server := NewRPCServer(NewBlockchain(1))
+
+		response := callRPC(t, server, `{"jsonrpc":"2.0","method":"unknown","id":1}`)
+
+		require.NotNil(t, response.Error)
+		assert.Equal(t, RPCMethodNotFound, response.Error.Code)
+	})
+
+	t.Run("不正なJSON", func(t *testing.T) {
+		server := NewRPCServer(NewBlockchain(1))
+
+		response := callRPC(t, server, `{not json`)
+
+		require.NotNil(t, response.Error)
+		assert.Equal(t, RPCParseError, response.Error.Code)
+	})
+
+	t.Run("jsonrpcバージョンが不正", func(t *testing.T) {
+		server := NewRPCServer(NewBlockchain(1))
+
+		response := callRPC(t, server, `{"jsonrpc":"1.0","method":"getblockcount","id":1}`)
+
+		require.NotNil(t, response.Error)
+		assert.Equal(t, RPCInvalidRequest, response.Error.Code)
+	})
+
+	t.Run("POST以外は拒否", func(t *testing.T) {
+		server := NewRPCServer(NewBlockchain(1))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"time"
+)
+
+// サンプリングの設定
+const (
+	// SampleInterval はハッシュレートを記録するバケットの長さ
+	SampleInterval = time.Second
+
+	// SampleCheckInterval は経過時間を確認するハッシュ試行の間隔
+	// scryptのような遅いハッシュでもバケットの境界がずれすぎない程度に小さくしています
+	SampleCheckInterval = 64
+)
+
+// HashRateSample は1バケット（約1秒）の間のハッシュレートです
+// GCやサーマルスロットリングによる一時的な低下を平均値に埋もれさせずに確認できます
+type HashRateSample struct {
+	Elapsed  time.Duration // マイニング開始からバケットの終わりまでの経過時間
+	Duration time.Duration // バケットの実際の長さ（停止があると1秒より長くなる）
+	Attempts int64         // バケット内の試行回数
+	HashRate float64       // バケット内のハッシュレート(hashes/sec)
+}
+
+// hashRateSampler はマイニング中の試行回数を1秒ごとのバケットに集計します
+type hashRateSampler struct {
+	start       time.Time
+	bucketStart time.Time
+	attempts    int64 // 現在のバケットの試行回数
+	total       int64 // 前回の記録時点の累計試行回数
+	samples     []HashRateSample
+	progress    chan<- HashRateSample
+}
+
+// newHashRateSampler は新しいサンプラーを作成します
+// progressがnilでなければ、確定したバケットを送信します（受信側が遅い場合は破棄）
+func newHashRateSampler(start time.Time, progress chan<- HashRateSample) *hashRateSampler {
+	return &hashRateSampler{start: start, bucketStart: start, progress: progress}
+}
+
+// observe は累計試行回数を記録し、1秒経過していればバケットを確定します
+func (s *hashRateSampler) observe(now time.Time, total int64) {
+	s.add(total)
+	if now.Sub(s.bucketStart) < SampleInterval {
+		return
+	}
+
+	sample := s.close(now)
+	if s.progress != nil {
+		select {
+		case s.progress <- sample:
+		default:
+			// 受信が追いつかない場合はマイニングを止めない
+		}
+	}
+}
+
+// finish は累計試行回数を記録して途中のバケットを確定し、すべてのサンプルを返します
+func (s *hashRateSampler) finish(now time.Time, total int64) []HashRateSample {
+	s.add(total)
+	if s.attempts > 0 {
+		s.close(now)
+	}
+	return s.samples
+}
+
+// add は前回の記録以降の試行回数を現在のバケットに加えます
+func (s *hashRateSampler) add(total int64) {
+	s.attempts += total - s.total
+	s.total = total
+}
+
+// close は現在のバケットを確定して次のバケットを開始します
+func (s *hashRateSampler) close(now time.Time) HashRateSample {
+	duration := now.Sub(s.bucketStart)
+	sample := HashRateSample{
+		Elapsed:  now.Sub(s.start),
+		Duration: duration,
+		Attempts: s.attempts,
+	}
+	if duration > 0 {
+		sample.HashRate = float64(s.attempts) / duration.Seconds()
+	}
+
+	s.samples = append(s.samples, sample)
+	s.bucketStart = now
+	s.attempts = 0
+	return sample
+}
+
+// HashRateRange はサンプルの最小・最大ハッシュレートを返します
+// 最後の途中のバケットは短く誤差が大きいため、2つ以上ある場合は除外します
+func HashRateRange(samples []HashRateSample) (minRate, maxRate float64) {
+	if len(samples) > 1 {
+		samples = samples[:len(samples)-1]
+	}
+	for i, sample := range samples {
+		if i == 0 || sample.HashRate < minRate {
+			minRate = sample.HashRate
+		}
+		if i == 0 || sample.HashRate > maxRate {
+			maxRate = sample.HashRate
+		}
+	}
+	return minRate, maxRate
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashRateSampler(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	t.Run("1秒ごとにバケットを確定して送信する", func(t *testing.T) {
+		progress := make(chan HashRateSample, 4)
+		sampler := newHashRateSampler(start, progress)
+
+		sampler.observe(start.Add(500*time.Millisecond), 100)
+		assert.Empty(t, progress)
+
+		sampler.observe(start.Add(time.Second), 200)
+		require.Len(t, progress, 1)
+		sample := <-progress
+		assert.Equal(t, time.Second, sample.Elapsed)
+		assert.Equal(t, int64(200), sample.Attempts)
+		assert.Equal(t, 200.0, sample.HashRate)
+	})
+
+	t.Run("停止があったバケットはハッシュレートが下がる", func(t *testing.T) {
+		sampler := newHashRateSampler(start, nil)
+
+		sampler.observe(start.Add(time.Second), 1000)
+		sampler.observe(start.Add(3500*time.Millisecond), 1500) // 2.5秒間で500回
+
+		samples := sampler.finish(start.Add(3500*time.Millisecond), 1500)
+		require.Len(t, samples, 2)
+		assert.Equal(t, 1000.0, samples[0].HashRate)
+		assert.Equal(t, 2500*time.Millisecond, samples[1].Duration)
+		assert.Equal(t, 200.0, samples[1].HashRate)
+	})
+
+	t.Run("finishは途中のバケットも含める", func(t *testing.T) {
+		sampler := newHashRateSampler(start, nil)
+
+		sampler.observe(start.Add(time.Second), 100)
+		samples := sampler.finish(start.Add(1250*time.Millisecond), 150)
+
+		require.Len(t, samples, 2)
+		assert.Equal(t, int64(50), samples[1].Attempts)
+		assert.Equal(t, 250*time.Millisecond, samples[1].Duration)
+	})
+
+	t.Run("受信側が詰まっていてもブロックしない", func(t *testing.T) {
+		progress := make(chan HashRateSample) // バッファなし・受信者なし
+		sampler := newHashRateSampler(start, progress)
+
+		assert.NotPanics(t, func() {
+			sampler.observe(start.Add(time.Second), 100)
+			sampler.observe(start.Add(2*time.Second), 200)
+		})
+		assert.Len(t, sampler.finish(start.Add(2*time.Second), 200), 2)
+	})
+}
+
+func TestHashRateRange(t *testing.T) {
+	t.Run("最後の途中のバケットを除いて最小・最大を返す", func(t *testing.T) {
+		samples := []HashRateSample{{HashRate: 300}, {HashRate: 100}, {HashRate: 200}, {HashRate: 1}}
+
+		minRate, maxRate := HashRateRange(samples)
+		assert.Equal(t, 100.0, minRate)
+		assert.Equal(t, 300.0, maxRate)
+	})
+
+	t.Run("サンプルが1つならそれを使う", func(t *testing.T) {
+		minRate, maxRate := HashRateRange([]HashRateSample{{HashRate: 50}})
+		assert.Equal(t, 50.0, minRate)
+		assert.Equal(t, 50.0, maxRate)
+	})
+}
+
+func TestMineBlockWithProgress(t *testing.T) {
+	t.Run("サンプルの試行回数の合計は全体と一致する", func(t *testing.T) {
+		block := NewBlock(1, "Sampled Block", "previous_hash", 3)
+		progress := make(chan HashRateSample, 16)
+
+		metrics, err := MineBlockWithProgress(context.Background(), block, 3, progress)
+		require.NoError(t, err)
+
+		require.NotEmpty(t, metrics.Samples)
+		var total int64
+		for _, sample := range metrics.Samples {
+			total += sample.Attempts
+		}
+		assert.Equal(t, metrics.AttemptsCount, total)
+	})
+
+	t.Run("チェーン経由のマイニングでも送信先を設定できる", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		progress := make(chan HashRateSample, 1)
+		bc.SetMiningProgress(progress)
+
+		metrics, err := bc.AddBlock("Block 1")
+		require.NoError(t, err)
+		assert.NotEmpty(t, metrics.Samples)
+
+		bc.SetMiningProgress(nil)
+		assert.Nil(t, bc.progress)
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DefaultDataPrefix はスクリプトモードで各ブロックのデータに付ける接頭辞のデフォルト
+const DefaultDataPrefix = "Block #"
+
+// ScriptedConfig は対話メニューなしで指定数のブロックをマイニングする設定です
+type ScriptedConfig struct {
+	Blocks     int    // マイニングするブロック数
+	DataPrefix string // ブロックデータの接頭辞（後ろにブロック番号を付加）
+	JSON       bool   // 1ブロックごとにJSON Linesで出力する
+}
+
+// BlockReport はスクリプトモードで1ブロックごとに出力する結果です
+type BlockReport struct {
+	Height     int64   `json:"height"`
+	Hash       string  `json:"hash"`
+	Nonce      int64   `json:"nonce"`
+	Difficulty int     `json:"difficulty"`
+	Algorithm  string  `json:"algorithm"`
+	Attempts   int64   `json:"attempts"`
+	DurationMs float64 `json:"duration_ms"`
+	HashRate   float64 `json:"hash_rate"`
+	Joules     float64 `json:"joules"`
+}
+
+// newBlockReport はマイニング結果から出力用のレポートを作成します
+func newBlockReport(block *Block, metrics *MiningMetrics) BlockReport {
+	return BlockReport{
+		Height:     block.Index,
+		Hash:       block.Hash,
+		Nonce:      block.Nonce,
+		Difficulty: block.Difficulty,
+		Algorithm:  metrics.Algorithm,
+		Attempts:   metrics.AttemptsCount,
+		DurationMs: float64(metrics.Duration.Microseconds()) / 1000,
+		HashRate:   metrics.HashRate,
+		Joules:     EstimateMiningEnergy(metrics).Joules,
+	}
+}
+
+// RunScripted は設定されたブロック数をマイニングし、1ブロックごとに結果を書き出します
+func RunScripted(bc *Blockchain, config ScriptedConfig, out io.Writer) error {
+	if config.Blocks < 1 {
+		return fmt.Errorf("number of blocks must be positive")
+	}
+
+	encoder := json.NewEncoder(out)
+	for i := 0; i < config.Blocks; i++ {
+		data := fmt.Sprintf("%s%d", config.DataPrefix, bc.GetChainLength())
+		metrics, err := bc.AddBlock(data)
+		if err != nil {
+			return err
+		}
+
+		report := newBlockReport(bc.GetLatestBlock(), metrics)
+		if config.JSON {
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "⛏️  Block #%d mined: hash=%s nonce=%d attempts=%d time=%v\n",
+			report.Height, report.Hash, report.Nonce, report.Attempts, metrics.Duration)
+	}
+
+	return nil
+}
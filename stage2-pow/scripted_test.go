@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunScripted(t *testing.T) {
+	t.Run("JSON Linesで1ブロックごとに出力する", func(t *testing.T) {
+		bc := NewBlockchain(1)
+
+		var buf bytes.Buffer
+		err := RunScripted(bc, ScriptedConfig{Blocks: 3, DataPrefix: "CI #", JSON: true}, &buf)
+		require.NoError(t, err)
+
+		var reports []BlockReport
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var report BlockReport
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &report))
+			reports = append(reports, report)
+		}
+
+		require.Len(t, reports, 3)
+		for i, report := range reports {
+			block := bc.Blocks[i+1]
+			assert.Equal(t, block.Index, report.Height)
+			assert.Equal(t, block.Hash, report.Hash)
+			assert.Equal(t, block.Nonce, report.Nonce)
+			assert.Equal(t, PoWSHA256, report.Algorithm)
+			assert.Positive(t, report.Attempts)
+		}
+		assert.Equal(t, []string{"CI #1"}, bc.Blocks[1].Entries)
+		assert.Equal(t, []string{"CI #3"}, bc.Blocks[3].Entries)
+	})
+
+	t.Run("テキスト形式で出力する", func(t *testing.T) {
+		bc := NewBlockchain(1)
+
+		var buf bytes.Buffer
+		require.NoError(t, RunScripted(bc, ScriptedConfig{Blocks: 2, DataPrefix: DefaultDataPrefix}, &buf))
+
+		assert.Equal(t, 2, strings.Count(buf.String(), "mined"))
+		assert.Equal(t, 3, bc.GetChainLength())
+		assert.Equal(t, []string{"Block #2"}, bc.Blocks[2].Entries)
+	})
+
+	t.Run("ブロック数が0以下ならエラー", func(t *testing.T) {
+		err := RunScripted(NewBlockchain(1), ScriptedConfig{Blocks: 0}, &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
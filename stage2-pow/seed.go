@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// SeededGenesisTimestamp はシード付きチェーンのジェネシスブロックのタイムスタンプ
+// （Bitcoinのジェネシスブロックと同じ時刻）
+const SeededGenesisTimestamp = 1231006505
+
+// SeededStartNonce はシードとブロック番号からナンス探索の開始値を決定します
+// start = H(seed || height) の先頭8バイトを、オーバーフローしないよう62ビットに収めたもの
+func SeededStartNonce(seed string, height int64) int64 {
+	hash := common.Hash([]byte(seed + strconv.FormatInt(height, 10)))
+	return int64(binary.BigEndian.Uint64(hash[:8]) >> 2)
+}
+
+// MineBlockSeeded はシードから決めた開始ナンスでマイニングします
+// 同じシード・同じブロック内容なら、何度実行しても同じナンスとハッシュになります
+func MineBlockSeeded(ctx context.Context, block *Block, difficulty int, seed string) (*MiningMetrics, error) {
+	return mineBlock(ctx, block, difficulty, SeededStartNonce(seed, block.Index), nil)
+}
+
+// NewSeededBlockchain は再現可能なブロックチェーンを生成します
+// ナンス探索の開始値はシードから、タイムスタンプは目標ブロック時間ごとに決定されるため、
+// 同じシードと同じ操作からは常に同じブロックが生成されます
+func NewSeededBlockchain(difficulty int, algorithm string, seed string) (*Blockchain, error) {
+	return newBlockchain(difficulty, algorithm, seed)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateGolden はゴールデンファイルを再生成するためのフラグ
+// ブロック形式を意図的に変更した場合は go test -run TestSeededChainGolden -update で更新します
+var updateGolden = flag.Bool("update", false, "ゴールデンファイルを更新する")
+
+// seededChainGoldenFile はシード付きチェーンのゴールデンファイル
+const seededChainGoldenFile = "testdata/golden/seeded_chain.json"
+
+// buildSeededChain はテスト用にシード付きチェーンを構築します
+func buildSeededChain(t *testing.T, seed string, blocks int) *Blockchain {
+	t.Helper()
+
+	bc, err := NewSeededBlockchain(2, PoWSHA256, seed)
+	require.NoError(t, err)
+
+	for i := 1; i <= blocks; i++ {
+		_, err := bc.AddBlock(fmt.Sprintf("Block %d", i))
+		require.NoError(t, err)
+	}
+	return bc
+}
+
+func TestSeededStartNonce(t *testing.T) {
+	t.Run("同じシードと高さなら同じ開始値", func(t *testing.T) {
+		assert.Equal(t, SeededStartNonce("seed", 3), SeededStartNonce("seed", 3))
+	})
+
+	t.Run("シードや高さが違えば異なる開始値", func(t *testing.T) {
+		assert.NotEqual(t, SeededStartNonce("seed", 3), SeededStartNonce("seed", 4))
+		assert.NotEqual(t, SeededStartNonce("seed", 3), SeededStartNonce("other", 3))
+	})
+
+	t.Run("開始値は負にならない", func(t *testing.T) {
+		for height := int64(0); height < 100; height++ {
+			assert.GreaterOrEqual(t, SeededStartNonce("seed", height), int64(0))
+		}
+	})
+}
+
+func TestMineBlockSeeded(t *testing.T) {
+	t.Run("同じブロックは同じナンスで見つかる", func(t *testing.T) {
+		block1 := NewBlock(1, "Data", "prev", 2)
+		block2 := NewBlock(1, "Data", "prev", 2)
+		block2.Timestamp = block1.Timestamp
+
+		_, err := MineBlockSeeded(context.Background(), block1, 2, "seed")
+		require.NoError(t, err)
+		_, err = MineBlockSeeded(context.Background(), block2, 2, "seed")
+		require.NoError(t, err)
+
+		assert.Equal(t, block1.Nonce, block2.Nonce)
+		assert.Equal(t, block1.Hash, block2.Hash)
+		assert.GreaterOrEqual(t, block1.Nonce, SeededStartNonce("seed", 1))
+		assert.True(t, ValidateProofOfWork(block1))
+	})
+}
+
+func TestSeededBlockchain(t *testing.T) {
+	t.Run("同じシードからは同じチェーン", func(t *testing.T) {
+		bc1 := buildSeededChain(t, "minicoin", 3)
+		bc2 := buildSeededChain(t, "minicoin", 3)
+
+		assert.Equal(t, bc1.Blocks, bc2.Blocks)
+		assert.True(t, bc1.IsValid())
+	})
+
+	t.Run("異なるシードからは異なるチェーン", func(t *testing.T) {
+		bc1 := buildSeededChain(t, "minicoin", 1)
+		bc2 := buildSeededChain(t, "other", 1)
+
+		assert.NotEqual(t, bc1.GetLatestBlock().Hash, bc2.GetLatestBlock().Hash)
+	})
+
+	t.Run("タイムスタンプは目標ブロック時間ごとに進む", func(t *testing.T) {
+		bc := buildSeededChain(t, "minicoin", 2)
+
+		assert.Equal(t, int64(SeededGenesisTimestamp), bc.Blocks[0].Timestamp)
+		assert.Equal(t, int64(SeededGenesisTimestamp+TargetBlockTime), bc.Blocks[1].Timestamp)
+		assert.Equal(t, int64(SeededGenesisTimestamp+2*TargetBlockTime), bc.Blocks[2].Timestamp)
+	})
+}
+
+func TestSeededChainGolden(t *testing.T) {
+	bc := buildSeededChain(t, "minicoin", 5)
+
+	actual, err := json.MarshalIndent(bc.Blocks, "", "  ")
+	require.NoError(t, err)
+
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(seededChainGoldenFile), 0750))
+		require.NoError(t, os.WriteFile(seededChainGoldenFile, append(actual, '\n'), 0600))
+	}
+
+	expected, err := os.ReadFile(seededChainGoldenFile)
+	require.NoError(t, err, "ゴールデンファイルがありません（-update で生成してください）")
+
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestSeededBenchmark(t *testing.T) {
+	config := BenchConfig{Difficulties: []int{1, 2}, Repetitions: 3, Seed: "bench"}
+
+	first, err := RunBenchmark(config)
+	require.NoError(t, err)
+	second, err := RunBenchmark(config)
+	require.NoError(t, err)
+
+	for i := range first {
+		assert.Equal(t, first[i].MeanAttempts, second[i].MeanAttempts)
+		assert.Equal(t, first[i].P95Attempts, second[i].P95Attempts)
+	}
+}
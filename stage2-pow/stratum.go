@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stratum風プロトコルのメソッド名
+// 改行区切りのJSONメッセージをTCPでやり取りします
+const (
+	StratumSubscribe = "mining.subscribe" // ワーカー → サーバー: 作業の購読
+	StratumNotify    = "mining.notify"    // サーバー → ワーカー: 新しい作業（ブロックテンプレート）
+	StratumSubmit    = "mining.submit"    // ワーカー → サーバー: 見つけたナンスの提出
+)
+
+// StratumWriteTimeout はワーカーへの1メッセージの送信タイムアウト
+const StratumWriteTimeout = 5 * time.Second
+
+// ErrStaleBlock はチェーンの先端が進んだ後に古いブロックが提出された場合のエラー
+var ErrStaleBlock = errors.New("stale block: chain tip has moved")
+
+// StratumMessage はStratum風プロトコルのメッセージです
+// IDのあるメッセージはリクエスト/レスポンス、IDのないものは通知です
+type StratumMessage struct {
+	ID     *int64          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// StratumJob はワーカーに配布する作業です
+// ブロック本体（エントリ）は含まず、ヘッダーの情報だけを渡します
+type StratumJob struct {
+	JobID        string `json:"job_id"`
+	Index        int64  `json:"index"`
+	Timestamp    int64  `json:"timestamp"`
+	MerkleRoot   string `json:"merkle_root"`
+	PreviousHash string `json:"previous_hash"`
+	Difficulty   int    `json:"difficulty"`
+	Algorithm    string `json:"algorithm"`
+}
+
+// StratumSubscribeParams は mining.subscribe のパラメータです（ワーカー名は情報提供のみ）
+type StratumSubscribeParams struct {
+	Worker string `json:"worker"`
+}
+
+// StratumSubmitParams は mining.submit のパラメータです
+type StratumSubmitParams struct {
+	JobID    string `json:"job_id"`
+	Nonce    int64  `json:"nonce"`
+	Attempts int64  `json:"attempts,omitempty"` // ワーカーが申告する試行回数（統計用）
+}
+
+// stratumWorker は接続中のワーカーです
+type stratumWorker struct {
+	conn       net.Conn
+	encoder    *json.Encoder
+	subscribed bool
+	writeMutex sync.Mutex
+}
+
+// send はワーカーにメッセージを送信します
+func (w *stratumWorker) send(message StratumMessage) error {
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	_ = w.conn.SetWriteDeadline(time.Now().Add(StratumWriteTimeout))
+	return w.encoder.Encode(message)
+}
+
+// StratumServer は外部のワーカーにブロックテンプレートを配布し、提出されたナンスを受け付けます
+// 実際のマイニングプールのプロトコル構造を学ぶための最小限の実装です
+type StratumServer struct {
+	blockchain *Blockchain
+	// Entries はブロックに含めるデータを返します（nilなら既定の文字列）
+	Entries func(height int64) []string
+
+	listener  net.Listener
+	workers   map[*stratumWorker]struct{}
+	job       *Block
+	jobID     string
+	jobIssued time.Time
+	jobCount  int64
+	accepted  int64
+	rejected  int64
+	mutex     sync.Mutex
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewStratumServer は新しいStratumサーバーを作成します
+func NewStratumServer(bc *Blockchain) *StratumServer {
+	return &StratumServer{
+		blockchain: bc,
+		workers:    make(map[*stratumWorker]struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// ListenAndServe は指定アドレスで待ち受けを開始します
+func (s *StratumServer) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve は受け付けたリスナーで接続の処理を開始します（バックグラウンドで動作）
+func (s *StratumServer) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	s.listener = listener
+	s.refreshJobLocked()
+	s.mutex.Unlock()
+
+	// チェーンが伸びたら新しい作業を配布する（ローカルのマイニングも含む）
+	events, unsubscribe := s.blockchain.Subscribe()
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		defer unsubscribe()
+		s.watchChain(events)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.acceptLoop(listener)
+	}()
+
+	return nil
+}
+
+// Addr は待ち受けアドレスを返します
+func (s *StratumServer) Addr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close はサーバーを停止し、すべてのワーカーを切断します
+func (s *StratumServer) Close() error {
+	s.mutex.Lock()
+	select {
+	case <-s.done:
+		s.mutex.Unlock()
+		return nil
+	default:
+	}
+	close(s.done)
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for worker := range s.workers {
+		_ = worker.conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Stats は受理・拒否されたブロック数を返します
+func (s *StratumServer) Stats() (accepted, rejected int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.accepted, s.rejected
+}
+
+// CurrentJob は現在配布中の作業を返します
+func (s *StratumServer) CurrentJob() StratumJob {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.currentJobLocked()
+}
+
+// acceptLoop はワーカーの接続を受け付けます
+func (s *StratumServer) acceptLoop(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return // Closeされた
+		}
+
+		worker := &stratumWorker{conn: conn, encoder: json.NewEncoder(conn)}
+		s.mutex.Lock()
+		s.workers[worker] = struct{}{}
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleWorker(worker)
+		}()
+	}
+}
+
+// watchChain はチェーンのイベントを監視し、作業を更新します
+func (s *StratumServer) watchChain(events <-chan ChainEvent) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			s.mutex.Lock()
+			stale := event.Type == EventBlockMined && event.Height >= s.job.Index ||
+				event.Type == EventDifficultyChange && event.Difficulty != s.job.Difficulty
+			if stale {
+				s.refreshJobLocked()
+			}
+			job := s.currentJobLocked()
+			s.mutex.Unlock()
+
+			if stale {
+				s.broadcast(job)
+			}
+		}
+	}
+}
+
+// handleWorker は1つのワーカー接続のメッセージを処理します
+func (s *StratumServer) handleWorker(worker *stratumWorker) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.workers, worker)
+		s.mutex.Unlock()
+		_ = worker.conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(worker.conn)
+	for scanner.Scan() {
+		var request StratumMessage
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			_ = worker.send(StratumMessage{Error: "parse error"})
+			continue
+		}
+
+		response := StratumMessage{ID: request.ID}
+		switch request.Method {
+		case StratumSubscribe:
+			s.mutex.Lock()
+			worker.subscribed = true
+			job := s.currentJobLocked()
+			s.mutex.Unlock()
+
+			response.Result = true
+			if err := worker.send(response); err != nil {
+				return
+			}
+			if err := worker.send(notifyMessage(job)); err != nil {
+				return
+			}
+			continue
+
+		case StratumSubmit:
+			var params StratumSubmitParams
+			if err := json.Unmarshal(request.Params, &params); err != nil {
+				response.Error = "invalid params"
+			} else if err := s.Submit(params); err != nil {
+				response.Error = err.Error()
+			} else {
+				response.Result = true
+			}
+
+		default:
+			response.Error = fmt.Sprintf("unknown method: %s", request.Method)
+		}
+
+		if err := worker.send(response); err != nil {
+			return
+		}
+	}
+}
+
+// Submit はワーカーが見つけたナンスを検証し、正しければブロックをチェーンに追加します
+func (s *StratumServer) Submit(params StratumSubmitParams) error {
+	s.mutex.Lock()
+	if params.JobID != s.jobID {
+		s.rejected++
+		s.mutex.Unlock()
+		return fmt.Errorf("stale job: %s", params.JobID)
+	}
+	block := *s.job
+	issued := s.jobIssued
+	s.mutex.Unlock()
+
+	block.Nonce = params.Nonce
+	algorithm, err := GetPoWAlgorithm(block.Algorithm)
+	if err == nil {
+		block.Hash, err = hashHeader(algorithm, headerRecord(&block))
+	}
+	if err == nil {
+		metrics := &MiningMetrics{
+			AttemptsCount: params.Attempts,
+			Duration:      time.Since(issued),
+			Algorithm:     algorithm.Name(),
+			MemoryPerHash: algorithm.MemoryPerHash(),
+		}
+		if metrics.Duration.Seconds() > 0 {
+			metrics.HashRate = float64(metrics.AttemptsCount) / metrics.Duration.Seconds()
+		}
+		err = s.blockchain.SubmitBlock(&block, metrics)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err != nil {
+		s.rejected++
+		return err
+	}
+	s.accepted++
+	return nil
+}
+
+// broadcast は購読中のすべてのワーカーに作業を通知します
+func (s *StratumServer) broadcast(job StratumJob) {
+	s.mutex.Lock()
+	workers := make([]*stratumWorker, 0, len(s.workers))
+	for worker := range s.workers {
+		if worker.subscribed {
+			workers = append(workers, worker)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, worker := range workers {
+		if err := worker.send(notifyMessage(job)); err != nil {
+			_ = worker.conn.Close()
+		}
+	}
+}
+
+// refreshJobLocked はチェーンの先端から新しい作業を作成します
+// 呼び出し側でロックを取得していることを前提とします
+func (s *StratumServer) refreshJobLocked() {
+	job := s.blockchain.NewBlockTemplate(nil)
+	job.Entries = []string{fmt.Sprintf("Stratum block #%d", job.Index)}
+	if s.Entries != nil {
+		job.Entries = s.Entries(job.Index)
+	}
+	job.MerkleRoot = CalculateMerkleRoot(job.Entries)
+
+	s.jobCount++
+	s.jobID = strconv.FormatInt(s.jobCount, 16)
+	s.job = job
+	s.jobIssued = time.Now()
+}
+
+// currentJobLocked は現在の作業を返します
+// 呼び出し側でロックを取得していることを前提とします
+func (s *StratumServer) currentJobLocked() StratumJob {
+	return StratumJob{
+		JobID:        s.jobID,
+		Index:        s.job.Index,
+		Timestamp:    s.job.Timestamp,
+		MerkleRoot:   s.job.MerkleRoot,
+		PreviousHash: s.job.PreviousHash,
+		Difficulty:   s.job.Difficulty,
+		Algorithm:    s.job.algorithmName(),
+	}
+}
+
+// notifyMessage は作業の通知メッセージを作成します
+func notifyMessage(job StratumJob) StratumMessage {
+	params, _ := json.Marshal(job)
+	return StratumMessage{Method: StratumNotify, Params: params}
+}
+
+// NewBlockTemplate はチェーンの先端に続く未マイニングのブロックを返します
+func (bc *Blockchain) NewBlockTemplate(entries []string) *Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return bc.newBlockTemplate(entries)
+}
+
+// SubmitBlock は外部でマイニングされたブロックを検証してチェーンに追加します
+func (bc *Blockchain) SubmitBlock(block *Block, metrics *MiningMetrics) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	previousBlock := bc.Blocks[len(bc.Blocks)-1]
+	if block.PreviousHash != previousBlock.Hash || block.Index != previousBlock.Index+1 {
+		return ErrStaleBlock
+	}
+	if block.Difficulty != bc.Difficulty {
+		return fmt.Errorf("difficulty mismatch: expected %d, got %d", bc.Difficulty, block.Difficulty)
+	}
+	if block.Algorithm != bc.Algorithm {
+		return fmt.Errorf("algorithm mismatch: expected %s, got %s", bc.Algorithm, block.Algorithm)
+	}
+	if block.Timestamp < previousBlock.Timestamp {
+		return fmt.Errorf("timestamp goes backwards")
+	}
+	if !ValidateProofOfWork(block) {
+		return fmt.Errorf("invalid proof of work")
+	}
+
+	bc.appendMinedBlock(block, metrics)
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestStratumServer はテスト用にループバックでStratumサーバーを起動します
+func startTestStratumServer(t *testing.T, bc *Blockchain) *StratumServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := NewStratumServer(bc)
+	require.NoError(t, server.Serve(listener))
+	t.Cleanup(func() { _ = server.Close() })
+	return server
+}
+
+// jobHeader は作業からハッシュ計算用のブロックヘッダーを組み立てます
+func jobHeader(job StratumJob) *Block {
+	return &Block{
+		Index:        job.Index,
+		Timestamp:    job.Timestamp,
+		MerkleRoot:   job.MerkleRoot,
+		PreviousHash: job.PreviousHash,
+		Difficulty:   job.Difficulty,
+		Algorithm:    job.Algorithm,
+	}
+}
+
+// findNonce は作業に対して難易度を満たす（validがfalseなら満たさない）ナンスを探します
+func findNonce(t *testing.T, job StratumJob, valid bool) int64 {
+	t.Helper()
+
+	header := jobHeader(job)
+	for nonce := int64(0); nonce < 1_000_000; nonce++ {
+		header.Nonce = nonce
+		if CheckHashDifficulty(CalculateHashWithNonce(header), job.Difficulty) == valid {
+			return nonce
+		}
+	}
+	t.Fatal("nonce not found")
+	return 0
+}
+
+// readStratumMessage はサーバーからのメッセージを1件読み込みます
+func readStratumMessage(t *testing.T, conn net.Conn, reader *bufio.Reader) StratumMessage {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var message StratumMessage
+	require.NoError(t, json.Unmarshal(line, &message))
+	return message
+}
+
+func TestStratumServer(t *testing.T) {
+	t.Run("ワーカーに作業を配布し、提出されたナンスでブロックを追加する", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := startTestStratumServer(t, bc)
+
+		conn, err := net.Dial("tcp", server.Addr().String())
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		reader := bufio.NewReader(conn)
+		encoder := json.NewEncoder(conn)
+
+		// 購読
+		id := int64(1)
+		require.NoError(t, encoder.Encode(StratumMessage{ID: &id, Method: StratumSubscribe, Params: json.RawMessage(`{"worker":"test"}`)}))
+		response := readStratumMessage(t, conn, reader)
+		assert.Equal(t, true, response.Result)
+
+		notify := readStratumMessage(t, conn, reader)
+		require.Equal(t, StratumNotify, notify.Method)
+		var job StratumJob
+		require.NoError(t, json.Unmarshal(notify.Params, &job))
+		assert.Equal(t, int64(1), job.Index)
+		assert.Equal(t, bc.GetLatestBlock().Hash, job.PreviousHash)
+		assert.Equal(t, PoWSHA256, job.Algorithm)
+
+		// ナンスの提出
+		id = 2
+		params, _ := json.Marshal(StratumSubmitParams{JobID: job.JobID, Nonce: findNonce(t, job, true), Attempts: 10})
+		require.NoError(t, encoder.Encode(StratumMessage{ID: &id, Method: StratumSubmit, Params: params}))
+
+		// 受理の応答と新しい作業の通知（順不同）
+		var accepted bool
+		var next StratumJob
+		for i := 0; i < 2; i++ {
+			message := readStratumMessage(t, conn, reader)
+			if message.Method == StratumNotify {
+				require.NoError(t, json.Unmarshal(message.Params, &next))
+			} else {
+				assert.Empty(t, message.Error)
+				accepted = message.Result == true
+			}
+		}
+		assert.True(t, accepted)
+		assert.Equal(t, int64(2), next.Index)
+
+		assert.Equal(t, 2, bc.GetChainLength())
+		assert.True(t, bc.IsValid())
+		assert.Equal(t, []string{"Stratum block #1"}, bc.GetLatestBlock().Entries)
+		assert.Equal(t, int64(10), bc.GetMiningStats().TotalHashes)
+	})
+
+	t.Run("未知のメソッドはエラーを返す", func(t *testing.T) {
+		server := startTestStratumServer(t, NewBlockchain(1))
+
+		conn, err := net.Dial("tcp", server.Addr().String())
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		id := int64(7)
+		require.NoError(t, json.NewEncoder(conn).Encode(StratumMessage{ID: &id, Method: "mining.unknown"}))
+		response := readStratumMessage(t, conn, bufio.NewReader(conn))
+		assert.Equal(t, int64(7), *response.ID)
+		assert.Contains(t, response.Error, "unknown method")
+	})
+
+	t.Run("古い作業IDの提出は拒否される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := startTestStratumServer(t, bc)
+		job := server.CurrentJob()
+
+		err := server.Submit(StratumSubmitParams{JobID: "stale", Nonce: findNonce(t, job, true)})
+		assert.ErrorContains(t, err, "stale job")
+		assert.Equal(t, 1, bc.GetChainLength())
+
+		accepted, rejected := server.Stats()
+		assert.Equal(t, int64(0), accepted)
+		assert.Equal(t, int64(1), rejected)
+	})
+
+	t.Run("難易度を満たさないナンスは拒否される", func(t *testing.T) {
+		bc := NewBlockchain(2)
+		server := startTestStratumServer(t, bc)
+		job := server.CurrentJob()
+
+		err := server.Submit(StratumSubmitParams{JobID: job.JobID, Nonce: findNonce(t, job, false)})
+		assert.ErrorContains(t, err, "invalid proof of work")
+		assert.Equal(t, 1, bc.GetChainLength())
+	})
+
+	t.Run("ローカルでブロックがマイニングされると作業が更新される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := startTestStratumServer(t, bc)
+		oldJob := server.CurrentJob()
+
+		_, err := bc.AddBlock("Local block")
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool { return server.CurrentJob().Index == 2 }, time.Second, 10*time.Millisecond)
+		assert.NotEqual(t, oldJob.JobID, server.CurrentJob().JobID)
+
+		// 古い作業での提出は拒否される
+		err = server.Submit(StratumSubmitParams{JobID: oldJob.JobID, Nonce: findNonce(t, oldJob, true)})
+		assert.Error(t, err)
+	})
+}
+
+func TestSubmitBlock(t *testing.T) {
+	t.Run("先端に続かないブロックはErrStaleBlock", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		block := bc.NewBlockTemplate([]string{"late"})
+		_, err := MineBlock(block, bc.Difficulty)
+		require.NoError(t, err)
+
+		_, err = bc.AddBlock("first")
+		require.NoError(t, err)
+
+		err = bc.SubmitBlock(block, &MiningMetrics{})
+		assert.True(t, errors.Is(err, ErrStaleBlock))
+	})
+
+	t.Run("難易度が異なるブロックは拒否される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		block := bc.NewBlockTemplate([]string{"easy"})
+		_, err := MineBlock(block, 0)
+		require.NoError(t, err)
+
+		err = bc.SubmitBlock(block, &MiningMetrics{})
+		assert.ErrorContains(t, err, "difficulty mismatch")
+	})
+
+	t.Run("正しいブロックは追加される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		block := bc.NewBlockTemplate([]string{"external"})
+		metrics, err := MineBlock(block, bc.Difficulty)
+		require.NoError(t, err)
+
+		require.NoError(t, bc.SubmitBlock(block, metrics))
+		assert.Equal(t, block.Hash, bc.GetLatestBlock().Hash)
+		assert.True(t, bc.IsValid())
+	})
+}
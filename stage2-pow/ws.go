@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketWriteTimeout はイベント1件の送信タイムアウト
+const WebSocketWriteTimeout = 5 * time.Second
+
+// BlockFeed はマイニングされたブロックと難易度変更をWebSocketで配信します
+type BlockFeed struct {
+	blockchain *Blockchain
+	upgrader   websocket.Upgrader
+}
+
+// NewBlockFeed は新しいWebSocketフィードを作成します
+func NewBlockFeed(bc *Blockchain) *BlockFeed {
+	return &BlockFeed{
+		blockchain: bc,
+		upgrader: websocket.Upgrader{
+			// ローカルのブラウザページや外部ダッシュボードから接続できるようにする
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+	}
+}
+
+// ServeHTTP は接続をWebSocketにアップグレードし、切断までイベントを送信し続けます
+func (f *BlockFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := f.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgradeがエラーレスポンスを返している
+	}
+	defer func() { _ = conn.Close() }()
+
+	events, unsubscribe := f.blockchain.Subscribe()
+	defer unsubscribe()
+
+	// クライアントからの切断を検出する（受信したメッセージは無視）
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(WebSocketWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
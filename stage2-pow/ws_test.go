@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockFeed(t *testing.T) {
+	t.Run("マイニングされたブロックがJSONで配信される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := httptest.NewServer(NewBlockFeed(bc))
+		defer server.Close()
+
+		url := "ws" + strings.TrimPrefix(server.URL, "http")
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		// 購読が登録されるまで待つ
+		require.Eventually(t, func() bool { return bc.events.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+		_, err = bc.AddBlock("Block 1")
+		require.NoError(t, err)
+		block := bc.GetLatestBlock()
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		var event ChainEvent
+		require.NoError(t, conn.ReadJSON(&event))
+		assert.Equal(t, EventBlockMined, event.Type)
+		assert.Equal(t, block.Hash, event.Block.Hash)
+		assert.Equal(t, []string{"Block 1"}, event.Block.Entries)
+	})
+
+	t.Run("切断すると購読が解除される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := httptest.NewServer(NewBlockFeed(bc))
+		defer server.Close()
+
+		url := "ws" + strings.TrimPrefix(server.URL, "http")
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return bc.events.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, conn.Close())
+
+		assert.Eventually(t, func() bool { return bc.events.SubscriberCount() == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("WebSocket以外のリクエストは拒否される", func(t *testing.T) {
+		bc := NewBlockchain(1)
+		server := httptest.NewServer(NewBlockFeed(bc))
+		defer server.Close()
+
+		resp, err := server.Client().Get(server.URL)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		assert.Equal(t, 400, resp.StatusCode)
+	})
+}
@@ -0,0 +1,324 @@
+// Package main implements an educational account-model view over the UTXO set for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Account はアカウントモデル（Ethereum など）で見たアドレスの状態です
+type Account struct {
+	Address string
+	Balance int // 残高（UTXOモデルではこのアドレスのUTXOの合計）
+	Nonce   int // このアドレスから送ったトランザクションの数（アカウントモデルの nonce に相当）
+}
+
+// AccountView はUTXOセットから導いたアカウントの表です（教育用）
+// UTXOモデルには残高や nonce という状態はなく、ブロックを接続するたびに使用した出力と作った出力から計算し直します
+type AccountView struct {
+	accounts map[string]*Account // アドレス -> アカウント
+	mutex    sync.RWMutex
+}
+
+// NewAccountView はブロックチェーン全体をたどってアカウントの表を作ります
+func NewAccountView(blockchain *Blockchain) *AccountView {
+	av := &AccountView{}
+	av.Rebuild(blockchain)
+	return av
+}
+
+// Rebuild はブロックチェーン全体をたどってアカウントの表を作り直します
+func (av *AccountView) Rebuild(blockchain *Blockchain) {
+	av.mutex.Lock()
+	defer av.mutex.Unlock()
+
+	av.accounts = make(map[string]*Account)
+	unspent := make(map[string]UTXO) // outpointKey -> 未使用の出力
+	for _, tx := range blockchain.GetAllTransactions() {
+		var spent []UTXO
+		if !tx.IsCoinbase() {
+			for _, input := range tx.Inputs {
+				key := outpointKey(input.TxID, input.OutIndex)
+				if utxo, ok := unspent[key]; ok {
+					spent = append(spent, utxo)
+					delete(unspent, key)
+				}
+			}
+		}
+		av.applyLocked(tx, spent, 1)
+		for index, output := range tx.Outputs {
+			unspent[outpointKey(tx.ID, index)] = UTXO{TxID: tx.ID, OutIndex: index, Output: output}
+		}
+	}
+}
+
+// ConnectBlock はUTXOセットに接続したブロックを、使用した出力（取り消し用データ）とともに反映します
+func (av *AccountView) ConnectBlock(block *Block, undo BlockUndo) {
+	av.mutex.Lock()
+	defer av.mutex.Unlock()
+
+	for i, tx := range block.Transactions {
+		av.applyLocked(tx, spentAt(undo, i), 1)
+	}
+}
+
+// DisconnectBlock はUTXOセットから取り消したブロックの反映を元に戻します
+func (av *AccountView) DisconnectBlock(block *Block, undo BlockUndo) {
+	av.mutex.Lock()
+	defer av.mutex.Unlock()
+
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		av.applyLocked(block.Transactions[i], spentAt(undo, i), -1)
+	}
+}
+
+// spentAt は取り消し用データから i 番目のトランザクションが使用した出力を返します
+func spentAt(undo BlockUndo, i int) []UTXO {
+	if i < len(undo.Spent) {
+		return undo.Spent[i]
+	}
+	return nil
+}
+
+// applyLocked は1つのトランザクションを表に反映します（sign が -1 なら取り消します）
+// 使用した出力の受取先から残高を引いて nonce を1つ進め、出力の受取先の残高を増やします
+// 呼び出し側でロックを取得していることを前提とします
+func (av *AccountView) applyLocked(tx *Transaction, spent []UTXO, sign int) {
+	senders := make(map[string]bool)
+	for _, utxo := range spent {
+		address := utxo.Output.Address()
+		av.accountLocked(address).Balance -= sign * utxo.Output.Value
+		senders[address] = true
+	}
+	for address := range senders {
+		av.accountLocked(address).Nonce += sign
+	}
+	for _, output := range tx.Outputs {
+		av.accountLocked(output.Address()).Balance += sign * output.Value
+	}
+}
+
+// accountLocked はアドレスのアカウントを返します（なければ作ります）
+func (av *AccountView) accountLocked(address string) *Account {
+	account, ok := av.accounts[address]
+	if !ok {
+		account = &Account{Address: address}
+		av.accounts[address] = account
+	}
+	return account
+}
+
+// Account はアドレスのアカウントを返します（トランザクションのないアドレスは残高0、nonce 0）
+func (av *AccountView) Account(address string) Account {
+	av.mutex.RLock()
+	defer av.mutex.RUnlock()
+
+	if account, ok := av.accounts[utxoKey(address)]; ok {
+		return *account
+	}
+	return Account{Address: address}
+}
+
+// Accounts は残高のあるアカウントか送金したことのあるアカウントを、残高の多い順に返します
+func (av *AccountView) Accounts() []Account {
+	av.mutex.RLock()
+	defer av.mutex.RUnlock()
+
+	var accounts []Account
+	for _, account := range av.accounts {
+		if account.Balance != 0 || account.Nonce != 0 {
+			accounts = append(accounts, *account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Balance != accounts[j].Balance {
+			return accounts[i].Balance > accounts[j].Balance
+		}
+		return accounts[i].Address < accounts[j].Address
+	})
+	return accounts
+}
+
+// AttachAccountView はアカウントの表をUTXOセットに連動させ、ブロックの接続・取り消し・再構築のたびに更新します
+// 表はUTXOセットと同じブロックまで反映している必要があります
+func (us *UTXOSet) AttachAccountView(av *AccountView) {
+	us.mutex.Lock()
+	defer us.mutex.Unlock()
+
+	us.accounts = av
+}
+
+// AccountChange はアカウントモデルで見た、送金によるアドレスの残高と nonce の変化です
+type AccountChange struct {
+	Address       string
+	Before, After int // 残高
+	Nonce         int // 送金前の nonce
+	Sender        bool
+}
+
+// PaymentView は1つの送金をUTXOモデルとアカウントモデルで解釈したものです
+type PaymentView struct {
+	Spent   []UTXO          // UTXOモデル: 使用する出力
+	Created []TxOutput      // UTXOモデル: 作る出力
+	Changes []AccountChange // アカウントモデル: アドレスごとの残高の増減（おつりは相殺される）
+	Fee     int
+}
+
+// ExplainPayment は送金を、使用する出力と作る出力（UTXOモデル）と、アドレスごとの残高の増減（アカウントモデル）に分けて説明します
+// 使用する出力は finder（UTXOセットやメモリプール）で探し、送金前の残高と nonce はアカウントの表から取ります
+func (av *AccountView) ExplainPayment(tx *Transaction, finder OutputFinder) (PaymentView, error) {
+	if tx.IsCoinbase() {
+		return PaymentView{}, fmt.Errorf("coinbase transaction is not a payment")
+	}
+
+	view := PaymentView{Created: tx.Outputs}
+	deltas := make(map[string]int)
+	var order []string
+	addDelta := func(address string, delta int) {
+		if _, ok := deltas[address]; !ok {
+			order = append(order, address)
+		}
+		deltas[address] += delta
+	}
+
+	senders := make(map[string]bool)
+	for i, input := range tx.Inputs {
+		output, ok := finder.FindOutput(input.TxID, input.OutIndex)
+		if !ok {
+			return PaymentView{}, fmt.Errorf("input %d: %s is not an unspent output", i, outpointKey(input.TxID, input.OutIndex))
+		}
+		view.Spent = append(view.Spent, UTXO{TxID: input.TxID, OutIndex: input.OutIndex, Output: output})
+		addDelta(output.Address(), -output.Value)
+		senders[output.Address()] = true
+		view.Fee += output.Value
+	}
+	for _, output := range tx.Outputs {
+		addDelta(output.Address(), output.Value)
+		view.Fee -= output.Value
+	}
+
+	for _, address := range order {
+		account := av.Account(address)
+		view.Changes = append(view.Changes, AccountChange{
+			Address: address,
+			Before:  account.Balance,
+			After:   account.Balance + deltas[address],
+			Nonce:   account.Nonce,
+			Sender:  senders[address],
+		})
+	}
+	return view, nil
+}
+
+// UTXOLines はUTXOモデルでの説明を1行ずつ返します
+func (v PaymentView) UTXOLines() []string {
+	spentBy := make(map[string]bool)
+	lines := []string{"UTXO model"}
+	for _, utxo := range v.Spent {
+		spentBy[utxo.Output.Address()] = true
+		lines = append(lines, fmt.Sprintf("- spend  %s:%d  %d (%s)", truncateHash(hex.EncodeToString(utxo.TxID)), utxo.OutIndex, utxo.Output.Value, truncateHash(utxo.Output.Address())))
+	}
+	for i, output := range v.Created {
+		line := fmt.Sprintf("+ create [%d] %d to %s", i, output.Value, truncateHash(output.Address()))
+		if spentBy[output.Address()] {
+			line += " (change)"
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, fmt.Sprintf("  fee    %d (inputs - outputs)", v.Fee))
+}
+
+// AccountLines はアカウントモデルでの説明を1行ずつ返します
+func (v PaymentView) AccountLines() []string {
+	lines := []string{"Account model"}
+	for _, change := range v.Changes {
+		line := fmt.Sprintf("%s  %d -> %d (%+d)", truncateHash(change.Address), change.Before, change.After, change.After-change.Before)
+		if change.Sender {
+			line += fmt.Sprintf(", nonce %d -> %d", change.Nonce, change.Nonce+1)
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, fmt.Sprintf("fee %d to the miner", v.Fee))
+}
+
+// printPaymentView は送金のUTXOモデルとアカウントモデルでの解釈を左右に並べて表示します
+func printPaymentView(view PaymentView) {
+	left, right := view.UTXOLines(), view.AccountLines()
+	width := 0
+	for _, line := range left {
+		width = max(width, len(line))
+	}
+
+	fmt.Println("\n🔍 UTXO model vs account model")
+	fmt.Println("────────────────────────────────────────────────────────")
+	for i := 0; i < max(len(left), len(right)); i++ {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		fmt.Println(strings.TrimRight(fmt.Sprintf("%-*s │ %s", width, l, r), " "))
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Println("UTXO: coins are consumed whole and change comes back as a new output.")
+	fmt.Println("Account: only the balances change, and the sender's nonce orders its payments.")
+}
+
+// printAccountView は送金の説明を作って表示します（作れなければその理由を表示します）
+func printAccountView(accounts *AccountView, tx *Transaction, finder OutputFinder) {
+	view, err := accounts.ExplainPayment(tx, finder)
+	if err != nil {
+		fmt.Printf("⚠️  Account view unavailable: %v\n", err)
+		return
+	}
+	printPaymentView(view)
+}
+
+// runAccountsCommand はUTXOセットから導いたアカウントの表（アドレスごとの残高と nonce）を表示します
+func runAccountsCommand(args []string) int {
+	fs := flag.NewFlagSet("accounts", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(blocks) == 0 {
+		fmt.Printf("❌ No blocks in %s\n", chainFile)
+		return 1
+	}
+	bc, err := OpenBlockchain(store, 2, "")
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	accounts := NewAccountView(bc).Accounts()
+	fmt.Printf("\n📒 Accounts derived from the UTXO set at block #%d\n", bc.GetLatestBlock().Index)
+	fmt.Println("────────────────────────────────────────────────────────")
+	total := 0
+	for _, account := range accounts {
+		fmt.Printf("%-36s %8d coins  nonce %d\n", account.Address, account.Balance, account.Nonce)
+		total += account.Balance
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("%d account(s), %d coins in total\n", len(accounts), total)
+	fmt.Println("Balances are sums of UTXOs and nonces count spending transactions; neither is stored on chain.")
+	return 0
+}
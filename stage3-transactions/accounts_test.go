@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountView(t *testing.T) {
+	t.Run("チェーンから残高と nonce を導く", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		_, _, err = SendCoins(mempool, wallet, testAddressB, 5, 1)
+		require.NoError(t, err)
+
+		accounts := NewAccountView(bc)
+		sender := accounts.Account(wallet.GetAddress())
+		assert.Equal(t, utxoSet.GetBalance(wallet.GetAddress()), sender.Balance)
+		assert.Equal(t, 2, sender.Nonce)
+		assert.Equal(t, Account{Address: testAddressA, Balance: 20}, accounts.Account(testAddressA))
+		assert.Equal(t, 0, accounts.Account(testAddressB).Nonce)
+		assert.Equal(t, Account{Address: "1unknown"}, accounts.Account("1unknown"))
+
+		total := 0
+		for _, account := range accounts.Accounts() {
+			total += account.Balance
+		}
+		assert.Equal(t, utxoSet.Stats().Supply, total)
+		assert.Equal(t, wallet.GetAddress(), accounts.Accounts()[0].Address)
+	})
+
+	t.Run("UTXOセットに連動してブロックの接続と取り消しで更新される", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		accounts := NewAccountView(bc)
+		utxoSet.AttachAccountView(accounts)
+
+		block, _, err := SendCoins(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		assert.Equal(t, NewAccountView(bc).Accounts(), accounts.Accounts())
+		assert.Equal(t, 1, accounts.Account(wallet.GetAddress()).Nonce)
+		assert.Equal(t, 20, accounts.Account(testAddressA).Balance)
+
+		require.NoError(t, utxoSet.Disconnect(block))
+		assert.Equal(t, 0, accounts.Account(wallet.GetAddress()).Nonce)
+		assert.Equal(t, 50, accounts.Account(wallet.GetAddress()).Balance)
+		assert.Equal(t, 0, accounts.Account(testAddressA).Balance)
+
+		require.NoError(t, utxoSet.Reindex(bc))
+		assert.Equal(t, 1, accounts.Account(wallet.GetAddress()).Nonce)
+	})
+}
+
+func TestExplainPayment(t *testing.T) {
+	t.Run("同じ送金をUTXOモデルとアカウントモデルで説明する", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+		tx, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+
+		view, err := NewAccountView(bc).ExplainPayment(tx, mempool)
+		require.NoError(t, err)
+
+		// UTXOモデル: 50の出力を使い、20の送金と29のおつりを作る
+		require.Len(t, view.Spent, 1)
+		assert.Equal(t, 50, view.Spent[0].Output.Value)
+		require.Len(t, view.Created, 2)
+		assert.Equal(t, 1, view.Fee)
+
+		// アカウントモデル: 送金元は21減って nonce が進み、送金先は20増える
+		assert.Equal(t, []AccountChange{
+			{Address: wallet.GetAddress(), Before: 50, After: 29, Nonce: 0, Sender: true},
+			{Address: testAddressA, Before: 0, After: 20},
+		}, view.Changes)
+
+		utxoLines := strings.Join(view.UTXOLines(), "\n")
+		assert.Contains(t, utxoLines, "- spend")
+		assert.Contains(t, utxoLines, "(change)")
+		accountLines := strings.Join(view.AccountLines(), "\n")
+		assert.Contains(t, accountLines, "50 -> 29 (-21), nonce 0 -> 1")
+		assert.Contains(t, accountLines, "0 -> 20 (+20)")
+	})
+
+	t.Run("使用済みの出力やコインベースは説明できない", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		accounts := NewAccountView(bc)
+
+		_, err := accounts.ExplainPayment(bc.Blocks[0].Transactions[0], utxoSet)
+		assert.Error(t, err)
+
+		block, _, err := SendCoins(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		_, err = accounts.ExplainPayment(block.Transactions[1], utxoSet)
+		assert.ErrorContains(t, err, "not an unspent output")
+	})
+}
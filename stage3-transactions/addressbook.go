@@ -0,0 +1,99 @@
+// Package main implements the wallet address book for Stage 3.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// SetLabel はアドレスにラベル（"Alice" や "Exchange" など）を付けてアドレス帳に登録します
+// ラベルは送金先の指定に使うため、他のアドレスのラベル・数字だけのもの・アドレスとして読めるものは使えません
+func (ws *Wallets) SetLabel(address, label string) error {
+	label = strings.TrimSpace(label)
+	if err := common.ValidateAddress(address); err != nil {
+		return err
+	}
+	if label == "" {
+		return fmt.Errorf("label must not be empty")
+	}
+	if _, err := strconv.Atoi(label); err == nil {
+		return fmt.Errorf("label %q must not be a number (numbers select local wallets)", label)
+	}
+	if common.ValidateAddress(label) == nil {
+		return fmt.Errorf("label %q must not be an address", label)
+	}
+	if other, ok := ws.LookupLabel(label); ok && other != address {
+		return fmt.Errorf("label %q is already used for %s", label, other)
+	}
+
+	if ws.Labels == nil {
+		ws.Labels = make(map[string]string)
+	}
+	ws.Labels[address] = label
+	return nil
+}
+
+// RemoveLabel はラベルまたはアドレスを指定してアドレス帳から削除し、削除したアドレスを返します
+func (ws *Wallets) RemoveLabel(input string) (string, error) {
+	address, ok := ws.LookupLabel(input)
+	if !ok {
+		if _, labeled := ws.Labels[input]; !labeled {
+			return "", fmt.Errorf("no address book entry for %q", input)
+		}
+		address = input
+	}
+	delete(ws.Labels, address)
+	return address, nil
+}
+
+// Label はアドレスのラベルを返します（登録されていなければ空文字列）
+func (ws *Wallets) Label(address string) string {
+	return ws.Labels[address]
+}
+
+// LookupLabel はラベルのアドレスを返します（大文字と小文字は区別しません）
+func (ws *Wallets) LookupLabel(label string) (string, bool) {
+	for address, name := range ws.Labels {
+		if strings.EqualFold(name, strings.TrimSpace(label)) {
+			return address, true
+		}
+	}
+	return "", false
+}
+
+// LabeledAddresses はアドレス帳のアドレスをラベル順に返します
+func (ws *Wallets) LabeledAddresses() []string {
+	addresses := make([]string, 0, len(ws.Labels))
+	for address := range ws.Labels {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return strings.ToLower(ws.Labels[addresses[i]]) < strings.ToLower(ws.Labels[addresses[j]])
+	})
+	return addresses
+}
+
+// ResolveRecipient は送金先の指定（ローカルのウォレットの一覧の番号、アドレス帳のラベル、アドレス）をアドレスに解決します
+// アドレスの検証は呼び出し側で行います
+func (ws *Wallets) ResolveRecipient(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if _, err := strconv.Atoi(input); err == nil {
+		return ws.Resolve(input)
+	}
+	if address, ok := ws.LookupLabel(input); ok {
+		return address, nil
+	}
+	return input, nil
+}
+
+// DisplayAddress はアドレスを、ラベルがあれば "ラベル (アドレス)" の形で返します
+func (ws *Wallets) DisplayAddress(address string) string {
+	if label := ws.Label(address); label != "" {
+		return fmt.Sprintf("%s (%s)", label, address)
+	}
+	return address
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressBook(t *testing.T) {
+	t.Run("ラベルで送金先を指定できる", func(t *testing.T) {
+		wallets := NewWallets()
+		require.NoError(t, wallets.SetLabel(testAddressA, "Alice"))
+
+		address, err := wallets.ResolveRecipient("alice")
+		require.NoError(t, err)
+		assert.Equal(t, testAddressA, address)
+		assert.Equal(t, "Alice ("+testAddressA+")", wallets.DisplayAddress(testAddressA))
+
+		// ラベルのないアドレスはそのまま返す
+		address, err = wallets.ResolveRecipient(testAddressB)
+		require.NoError(t, err)
+		assert.Equal(t, testAddressB, address)
+		assert.Equal(t, testAddressB, wallets.DisplayAddress(testAddressB))
+	})
+
+	t.Run("番号はローカルのウォレットを指す", func(t *testing.T) {
+		wallets := NewWallets()
+		local, err := wallets.CreateWallet()
+		require.NoError(t, err)
+
+		address, err := wallets.ResolveRecipient("1")
+		require.NoError(t, err)
+		assert.Equal(t, local, address)
+		_, err = wallets.ResolveRecipient("2")
+		assert.Error(t, err)
+	})
+
+	t.Run("紛らわしいラベルや重複したラベルは拒否する", func(t *testing.T) {
+		wallets := NewWallets()
+		require.NoError(t, wallets.SetLabel(testAddressA, "Exchange"))
+
+		assert.Error(t, wallets.SetLabel(testAddressB, "exchange"), "他のアドレスのラベル")
+		assert.Error(t, wallets.SetLabel(testAddressB, "42"), "数字だけ")
+		assert.Error(t, wallets.SetLabel(testAddressB, testAddressA), "アドレスとして読める")
+		assert.Error(t, wallets.SetLabel(testAddressB, " "), "空")
+		assert.Error(t, wallets.SetLabel("1BDJ2bwCrFgkDyKLDzQJr6jkVb2tQBBzrx", "Bob"), "チェックサムが合わない")
+
+		// 同じアドレスのラベルは付け替えられる
+		require.NoError(t, wallets.SetLabel(testAddressA, "Exchange (cold)"))
+		assert.Equal(t, "Exchange (cold)", wallets.Label(testAddressA))
+	})
+
+	t.Run("ラベルまたはアドレスで削除する", func(t *testing.T) {
+		wallets := NewWallets()
+		require.NoError(t, wallets.SetLabel(testAddressA, "Alice"))
+		require.NoError(t, wallets.SetLabel(testAddressB, "Bob"))
+
+		address, err := wallets.RemoveLabel("Alice")
+		require.NoError(t, err)
+		assert.Equal(t, testAddressA, address)
+		_, err = wallets.RemoveLabel(testAddressB)
+		require.NoError(t, err)
+		_, err = wallets.RemoveLabel("Carol")
+		assert.Error(t, err)
+		assert.Empty(t, wallets.Labels)
+	})
+
+	t.Run("アドレス帳はウォレットのファイルに保存する", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "wallets.dat")
+		wallets := NewWallets()
+		_, err := wallets.CreateWallet()
+		require.NoError(t, err)
+		require.NoError(t, wallets.SetLabel(testAddressB, "Bob"))
+		require.NoError(t, wallets.SetLabel(testAddressA, "Alice"))
+		require.NoError(t, wallets.SaveToFile(filename))
+
+		loaded, err := LoadWalletsFromFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, []string{testAddressA, testAddressB}, loaded.LabeledAddresses())
+	})
+}
@@ -0,0 +1,102 @@
+// Package main implements value-conservation checks for Stage 3 transactions.
+package main
+
+import (
+	"fmt"
+)
+
+// MaxMoney は1つの金額と、トランザクションの入力・出力の合計に許される上限です（Bitcoinの MAX_MONEY に相当）
+// 発行量の上限より十分に大きなサニティチェックの値で、金額の足し算があふれるのを防ぎます
+const MaxMoney = 21_000_000
+
+// CheckAmounts は前の出力を参照せずにできる金額の検証です
+// 各出力の金額が0以上 MaxMoney 以下で、出力の合計も MaxMoney 以下である必要があります
+func (tx *Transaction) CheckAmounts() error {
+	total := 0
+	for i, output := range tx.Outputs {
+		if output.Value < 0 {
+			return fmt.Errorf("output %d: negative value %d", i, output.Value)
+		}
+		if output.Value > MaxMoney {
+			return fmt.Errorf("output %d: value %d exceeds the maximum of %d", i, output.Value, MaxMoney)
+		}
+		total += output.Value
+		if total > MaxMoney {
+			return fmt.Errorf("outputs total exceeds the maximum of %d", MaxMoney)
+		}
+	}
+	return nil
+}
+
+// CheckInputs は入力が参照する出力を view（UTXOセットなど）で探し、価値が保存されているかを検証して手数料を返します
+// 入力は未使用の出力を参照し、同じ出力を二重に使わず、入力の合計が出力の合計以上である必要があります
+// 戻り値の出力は入力と同じ順で、署名の検証に使えます
+func (tx *Transaction) CheckInputs(view OutputFinder) ([]TxOutput, int, error) {
+	if tx.IsCoinbase() {
+		return nil, 0, fmt.Errorf("coinbase transaction has no inputs to check")
+	}
+	if err := tx.CheckAmounts(); err != nil {
+		return nil, 0, err
+	}
+
+	prevOutputs := make([]TxOutput, len(tx.Inputs))
+	seen := make(map[string]bool)
+	in := 0
+	for i, input := range tx.Inputs {
+		key := outpointKey(input.TxID, input.OutIndex)
+		if seen[key] {
+			return nil, 0, fmt.Errorf("double spend: %s is used twice in the transaction", key)
+		}
+		seen[key] = true
+
+		output, ok := view.FindOutput(input.TxID, input.OutIndex)
+		if !ok {
+			return nil, 0, fmt.Errorf("input %s is missing or already spent", key)
+		}
+		if output.Value < 0 || output.Value > MaxMoney {
+			return nil, 0, fmt.Errorf("input %s: value %d is out of range", key, output.Value)
+		}
+		in += output.Value
+		if in > MaxMoney {
+			return nil, 0, fmt.Errorf("inputs total exceeds the maximum of %d", MaxMoney)
+		}
+		prevOutputs[i] = output
+	}
+
+	fee := in
+	for _, output := range tx.Outputs {
+		fee -= output.Value
+	}
+	if fee < 0 {
+		return nil, 0, fmt.Errorf("outputs exceed inputs by %d", -fee)
+	}
+	return prevOutputs, fee, nil
+}
+
+// VerifyWithView は view（UTXOセットなど）に対して金額・入力の価値の保存・署名をまとめて検証し、手数料を返します
+// ロック時刻とバージョンは検証しないため、必要なら Blockchain.CheckLockHeight と CheckVersion を使います
+func (tx *Transaction) VerifyWithView(view OutputFinder) (int, error) {
+	prevOutputs, fee, err := tx.CheckInputs(view)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.verifyScriptsWith(prevOutputs); err != nil {
+		return 0, err
+	}
+	return fee, nil
+}
+
+// prevTxsView は前トランザクションのマップを OutputFinder として使えるようにします
+// 出力が存在するかは分かりますが、使用済みかどうかは分かりません
+type prevTxsView map[string]*Transaction
+
+// FindOutput は前トランザクションの出力を返します
+func (v prevTxsView) FindOutput(txID []byte, outIndex int) (TxOutput, bool) {
+	return previousOutput(TxInput{TxID: txID, OutIndex: outIndex}, v)
+}
+
+// FindOutput は未使用の出力を返します（chainState を OutputFinder として使えるようにします）
+func (s *chainState) FindOutput(txID []byte, outIndex int) (TxOutput, bool) {
+	output, ok := s.unspent[outpointKey(txID, outIndex)]
+	return output, ok
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAmounts(t *testing.T) {
+	t.Run("範囲内の金額を受け付ける", func(t *testing.T) {
+		tx := &Transaction{Outputs: []TxOutput{{Value: 0}, {Value: 10}, {Value: MaxMoney - 10}}}
+		assert.NoError(t, tx.CheckAmounts())
+	})
+
+	t.Run("負の金額を拒否", func(t *testing.T) {
+		tx := &Transaction{Outputs: []TxOutput{{Value: 10}, {Value: -1}}}
+		err := tx.CheckAmounts()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "output 1: negative value -1")
+	})
+
+	t.Run("上限を超える金額を拒否", func(t *testing.T) {
+		tx := &Transaction{Outputs: []TxOutput{{Value: MaxMoney + 1}}}
+		err := tx.CheckAmounts()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds the maximum")
+	})
+
+	t.Run("合計が上限を超える出力を拒否", func(t *testing.T) {
+		tx := &Transaction{Outputs: []TxOutput{{Value: MaxMoney}, {Value: 1}}}
+		err := tx.CheckAmounts()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outputs total exceeds the maximum")
+	})
+}
+
+func TestCheckInputs(t *testing.T) {
+	t.Run("手数料と参照先の出力を返す", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 3, utxoSet, bc)
+		require.NoError(t, err)
+
+		prevOutputs, fee, err := tx.CheckInputs(utxoSet)
+		require.NoError(t, err)
+		assert.Equal(t, 3, fee)
+		require.Len(t, prevOutputs, 1)
+		assert.Equal(t, 50, prevOutputs[0].Value)
+	})
+
+	t.Run("存在しないか使用済みの出力を拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Inputs[0].OutIndex = 5
+
+		_, _, err = tx.CheckInputs(utxoSet)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing or already spent")
+	})
+
+	t.Run("同じ出力を二重に使う入力を拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Inputs = append(tx.Inputs, tx.Inputs[0])
+
+		_, _, err = tx.CheckInputs(utxoSet)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "used twice")
+	})
+
+	t.Run("出力が入力を超えるトランザクションを拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Outputs[0].Value = 60
+
+		_, _, err = tx.CheckInputs(utxoSet)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outputs exceed inputs by")
+	})
+
+	t.Run("コインベースは対象外", func(t *testing.T) {
+		_, _, err := NewCoinbaseTx(testAddressA, "coinbase").CheckInputs(prevTxsView{})
+		assert.Error(t, err)
+	})
+}
+
+func TestValueConservation(t *testing.T) {
+	// 負の出力で別の出力を水増しすると、合計は入力以下のまま価値を作り出せてしまう
+	newInflatingTx := func(t *testing.T) (*Wallet, *Blockchain, *UTXOSet, *Mempool, *Transaction) {
+		t.Helper()
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Outputs[0].Value += 100
+		tx.Outputs[1].Value = -100
+		tx.ID = tx.Hash()
+		require.NoError(t, bc.SignTransaction(tx, wallet))
+		return wallet, bc, utxoSet, mempool, tx
+	}
+
+	t.Run("負の出力を含むトランザクションは署名が正しくても無効", func(t *testing.T) {
+		_, bc, utxoSet, _, tx := newInflatingTx(t)
+
+		assert.False(t, bc.VerifyTransaction(tx))
+		_, err := tx.VerifyWithView(utxoSet)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "negative value")
+	})
+
+	t.Run("負の出力を含むトランザクションはメモリプールに入らない", func(t *testing.T) {
+		_, _, _, mempool, tx := newInflatingTx(t)
+
+		err := mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "negative value")
+	})
+
+	t.Run("負の出力を含むブロックは無効", func(t *testing.T) {
+		wallet, bc, _, _, tx := newInflatingTx(t)
+
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "inflate"), tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "negative value")
+	})
+
+	t.Run("前トランザクションに対する検証でも出力が入力を超えれば無効", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Outputs[0].Value = 60
+		require.NoError(t, bc.SignTransaction(tx, wallet))
+
+		prevTxs, err := bc.previousTransactions(tx)
+		require.NoError(t, err)
+		assert.NoError(t, tx.VerifyScripts(prevTxs))
+		assert.False(t, tx.Verify(prevTxs))
+	})
+
+	t.Run("正しいトランザクションは手数料とともに検証を通る", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 2, utxoSet, bc)
+		require.NoError(t, err)
+
+		fee, err := tx.VerifyWithView(utxoSet)
+		require.NoError(t, err)
+		assert.Equal(t, 2, fee)
+		assert.True(t, bc.VerifyTransaction(tx))
+	})
+}
@@ -0,0 +1,135 @@
+// Package main implements aggregated wallet balances for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// CoinbaseMaturity はコインベースの出力が成熟したとみなす確認数です
+// このステージでは未成熟でも使えますが、フォークで消える可能性があるため残高では分けて表示します
+const CoinbaseMaturity = 10
+
+// WalletBalance は所有するアドレス全体の残高の内訳です
+type WalletBalance struct {
+	Addresses  int // 集計したアドレスの数
+	Confirmed  int // ブロックに取り込まれ、成熟したUTXOの合計
+	Immature   int // 確認数が CoinbaseMaturity に満たないコインベースの出力の合計
+	PendingIn  int // メモリプールの送金で受け取る予定の額（おつりを含む）
+	PendingOut int // メモリプールの送金が使用する自分のUTXOの合計
+}
+
+// Total は保留中の送金がすべて取り込まれた後の残高を返します
+func (b WalletBalance) Total() int {
+	return b.Confirmed + b.Immature + b.PendingIn - b.PendingOut
+}
+
+// String は残高の内訳を1行で返します
+func (b WalletBalance) String() string {
+	return fmt.Sprintf("%d coins (confirmed %d, immature %d, pending +%d/-%d, %d address(es))",
+		b.Total(), b.Confirmed, b.Immature, b.PendingIn, b.PendingOut, b.Addresses)
+}
+
+// Addresses はウォレットの鍵で使えるアドレス（Base58Check と旧形式の16進数）を返します
+// 旧形式のアドレスは公開鍵ハッシュの計算方法が異なるため、別のアドレスとしてUTXOを持ちます
+func (w *Wallet) Addresses() []string {
+	addresses := []string{w.GetAddress()}
+	if w.PublicKey == nil {
+		return addresses
+	}
+	for _, address := range []string{common.PublicKeyToAddress(w.PublicKey), common.LegacyPublicKeyToAddress(w.PublicKey)} {
+		if address != w.GetAddress() {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// GetTotalBalance はウォレットが所有するすべてのアドレスの残高を、確定・未成熟・保留中に分けて集計します
+func (w *Wallet) GetTotalBalance(mempool *Mempool) WalletBalance {
+	return totalBalance(w.Addresses(), mempool)
+}
+
+// GetTotalBalance はコレクション内のすべてのウォレットと keypool のおつりのアドレスの残高を集計します
+func (ws *Wallets) GetTotalBalance(mempool *Mempool) WalletBalance {
+	var addresses []string
+	for _, address := range ws.GetAddresses() {
+		addresses = append(addresses, ws.Wallets[address].Addresses()...)
+	}
+	if ws.KeyPool != nil {
+		for _, wallet := range ws.KeyPool.Used() {
+			addresses = append(addresses, wallet.GetAddress())
+		}
+	}
+	return totalBalance(addresses, mempool)
+}
+
+// GetTotalBalance は払い出し済み（NextIndex未満）のすべてのアドレスの残高を集計します
+func (hw *HDWallet) GetTotalBalance(mempool *Mempool) (WalletBalance, error) {
+	addresses := make([]string, 0, hw.NextIndex)
+	for index := uint32(0); index < hw.NextIndex; index++ {
+		address, err := hw.DeriveAddress(index)
+		if err != nil {
+			return WalletBalance{}, err
+		}
+		addresses = append(addresses, address)
+	}
+	return totalBalance(addresses, mempool), nil
+}
+
+// totalBalance はアドレスの一覧の残高を集計します
+// 同じUTXOセットのキーになるアドレスは1つとして数えます
+func totalBalance(addresses []string, mempool *Mempool) WalletBalance {
+	owned := make(map[string]bool)
+	for _, address := range addresses {
+		owned[utxoKey(address)] = true
+	}
+
+	balance := WalletBalance{Addresses: len(owned)}
+	heights := mempool.blockchain.coinbaseHeights()
+	tip := int64(mempool.blockchain.GetChainLength() - 1)
+
+	for key := range owned {
+		for _, utxo := range mempool.utxoSet.FindUTXO(key) {
+			height, coinbase := heights[hex.EncodeToString(utxo.TxID)]
+			if coinbase && tip-height+1 < CoinbaseMaturity {
+				balance.Immature += utxo.Output.Value
+			} else {
+				balance.Confirmed += utxo.Output.Value
+			}
+		}
+	}
+
+	for _, entry := range mempool.Entries() {
+		for _, input := range entry.Tx.Inputs {
+			if output, ok := mempool.FindOutput(input.TxID, input.OutIndex); ok && owned[output.Address()] {
+				balance.PendingOut += output.Value
+			}
+		}
+		for _, output := range entry.Tx.Outputs {
+			if owned[output.Address()] {
+				balance.PendingIn += output.Value
+			}
+		}
+	}
+
+	return balance
+}
+
+// coinbaseHeights はコインベーストランザクションのID(hex)と、それを含むブロックの高さを返します
+func (bc *Blockchain) coinbaseHeights() map[string]int64 {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	heights := make(map[string]int64, len(bc.Blocks))
+	for _, block := range bc.Blocks {
+		for _, tx := range block.Transactions {
+			if tx.IsCoinbase() {
+				heights[hex.EncodeToString(tx.ID)] = block.Index
+			}
+		}
+	}
+	return heights
+}
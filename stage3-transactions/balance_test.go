@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTotalBalance(t *testing.T) {
+	t.Run("確認数の足りないコインベースは未成熟", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		balance := wallet.GetTotalBalance(mempool)
+		assert.Equal(t, WalletBalance{Addresses: 2, Immature: 50}, balance)
+
+		// ジェネシスが CoinbaseMaturity 回確認されるまでブロックを追加する
+		fundWallet(t, bc, utxoSet, wallet, CoinbaseMaturity-1)
+		balance = wallet.GetTotalBalance(mempool)
+		assert.Equal(t, 50, balance.Confirmed)
+		assert.Equal(t, 50*(CoinbaseMaturity-1), balance.Immature)
+		assert.Equal(t, 50*CoinbaseMaturity, balance.Total())
+	})
+
+	t.Run("メモリプールの送金を保留中として数える", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+
+		_, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+
+		balance := wallet.GetTotalBalance(mempool)
+		assert.Equal(t, 50, balance.Immature, "確定するまではUTXOセットに残る")
+		assert.Equal(t, 29, balance.PendingIn, "おつり")
+		assert.Equal(t, 50, balance.PendingOut)
+		assert.Equal(t, 29, balance.Total())
+
+		received := (&Wallet{Address: testAddressA}).GetTotalBalance(mempool)
+		assert.Equal(t, WalletBalance{Addresses: 1, PendingIn: 20}, received)
+	})
+
+	t.Run("旧形式のアドレスのUTXOも同じウォレットの残高", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, &Wallet{Address: common.LegacyPublicKeyToAddress(wallet.PublicKey)}, 1)
+
+		assert.Equal(t, 50, utxoSet.GetBalance(wallet.GetAddress()))
+		balance := wallet.GetTotalBalance(mempool)
+		assert.Equal(t, 2, balance.Addresses)
+		assert.Equal(t, 100, balance.Total())
+	})
+
+	t.Run("コレクション内のすべてのウォレットを集計する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		other, err := NewWallet()
+		require.NoError(t, err)
+		fundWallet(t, bc, utxoSet, other, 2)
+
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		wallets.AddWallet(other)
+
+		balance := wallets.GetTotalBalance(mempool)
+		assert.Equal(t, 4, balance.Addresses)
+		assert.Equal(t, 150, balance.Total())
+	})
+
+	t.Run("HDウォレットは払い出したアドレスをすべて集計する", func(t *testing.T) {
+		hw := testHDWallet(t)
+		first, err := hw.NewAddress()
+		require.NoError(t, err)
+		second, err := hw.NewAddress()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, first)
+		utxoSet := NewUTXOSet(bc)
+		fundWallet(t, bc, utxoSet, &Wallet{Address: second}, 1)
+		mempool := NewMempool(bc, utxoSet)
+
+		balance, err := hw.GetTotalBalance(mempool)
+		require.NoError(t, err)
+		assert.Equal(t, 2, balance.Addresses)
+		assert.Equal(t, 100, balance.Total())
+	})
+}
@@ -0,0 +1,277 @@
+// Package main implements batched payments and batch signing for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// Payment は1つの送金先への支払いです
+type Payment struct {
+	To     string
+	Amount int
+}
+
+// PrevTxLookup はトランザクションIDから前トランザクションを探します（Blockchain.FindTransaction など）
+type PrevTxLookup func(txID []byte) (*Transaction, error)
+
+// SignAll は複数のトランザクションにまとめて署名します
+// 前トランザクションは同じバッチの中から探し、なければ prevTxLookup で探すため、バッチの中で連鎖する送金にも署名できます
+// 署名できないトランザクションがあれば、その番号を含めたエラーを返します（それより前の署名は残ります）
+func (w *Wallet) SignAll(txs []*Transaction, prevTxLookup PrevTxLookup) error {
+	batch := make(map[string]*Transaction, len(txs))
+	for _, tx := range txs {
+		batch[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	for i, tx := range txs {
+		if tx.IsCoinbase() {
+			continue
+		}
+		prevTxs := make(map[string]*Transaction)
+		for _, input := range tx.Inputs {
+			id := hex.EncodeToString(input.TxID)
+			if prevTx, ok := batch[id]; ok {
+				prevTxs[id] = prevTx
+				continue
+			}
+			prevTx, err := prevTxLookup(input.TxID)
+			if err != nil {
+				return fmt.Errorf("transaction %d: prev transaction not found: %w", i, err)
+			}
+			prevTxs[id] = prevTx
+		}
+		if err := tx.Sign(w, prevTxs); err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// NewUnsignedBatchTransaction は from のUTXOから複数の送金先に支払う、未署名のトランザクションを1つ作成します
+// 出力は支払いの順に並べ、おつりは最後に1つだけ from に戻します
+func NewUnsignedBatchTransaction(from string, payments []Payment, fee int, strategy CoinSelectionStrategy, utxoSet SpendableOutputFinder) (*Transaction, *CoinSelection, error) {
+	// おつりは送金元に戻す（旧形式（16進数）のアドレスも受け付ける）
+	if _, err := newOutput(from, 0); err != nil {
+		return nil, nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	return newPaymentTransaction(utxoSet.SpendableUTXOs(from), payments, fee, strategy, func() (string, error) {
+		return from, nil
+	})
+}
+
+// newPaymentTransaction は utxos から支払いの合計と手数料を満たすものを選び、未署名のトランザクションを作成します
+// おつりがあるときだけ changeAddress でおつりの送り先を決めます（keypool のアドレスを無駄に使わないため）
+func newPaymentTransaction(utxos []UTXO, payments []Payment, fee int, strategy CoinSelectionStrategy, changeAddress func() (string, error)) (*Transaction, *CoinSelection, error) {
+	if len(payments) == 0 {
+		return nil, nil, fmt.Errorf("no payments")
+	}
+	if fee < 0 {
+		return nil, nil, fmt.Errorf("fee must not be negative")
+	}
+
+	// 送金先はP2SHのアドレスでもよい。同じ送金先への支払いは1つの出力にまとめるべきなので拒否する
+	outputs := make([]TxOutput, 0, len(payments)+1)
+	recipients := make(map[string]bool, len(payments))
+	total := 0
+	for _, payment := range payments {
+		if payment.Amount <= 0 {
+			return nil, nil, fmt.Errorf("amount must be positive")
+		}
+		output, err := newOutput(payment.To, payment.Amount)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := utxoKey(payment.To)
+		if recipients[key] {
+			return nil, nil, fmt.Errorf("duplicate recipient %s", payment.To)
+		}
+		recipients[key] = true
+		outputs = append(outputs, output)
+		total += payment.Amount
+	}
+
+	// 支払いの合計と手数料を満たすUTXOを選ぶ
+	selection, err := SelectCoins(utxos, total+fee, strategy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inputs, err := inputsFromSpendable(selection.spendable())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if selection.Change() > 0 {
+		address, err := changeAddress()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get a change address: %w", err)
+		}
+		change, err := newOutput(address, selection.Change())
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid change address: %w", err)
+		}
+		outputs = append(outputs, change)
+	}
+
+	tx := &Transaction{
+		Version:   CurrentTxVersion,
+		Inputs:    inputs,
+		Outputs:   outputs,
+		Timestamp: time.Now().Unix(),
+	}
+	tx.ID = tx.Hash()
+
+	return tx, selection, nil
+}
+
+// NewBatchTransaction はUTXOを選んで複数の送金先に支払うトランザクションを作成し、ウォレットで署名します
+func NewBatchTransaction(wallet *Wallet, payments []Payment, fee int, strategy CoinSelectionStrategy, utxoSet SpendableOutputFinder, bc *Blockchain) (*Transaction, *CoinSelection, error) {
+	tx, selection, err := NewUnsignedBatchTransaction(wallet.GetAddress(), payments, fee, strategy, utxoSet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := wallet.SignAll([]*Transaction{tx}, bc.FindTransaction); err != nil {
+		return nil, nil, err
+	}
+
+	return tx, selection, nil
+}
+
+// SubmitBatchTransaction は複数の送金先に支払うトランザクションを作成してメモリプールに追加します
+func SubmitBatchTransaction(mempool *Mempool, wallet *Wallet, payments []Payment, fee int, strategy CoinSelectionStrategy) (*Transaction, *CoinSelection, error) {
+	tx, selection, err := NewBatchTransaction(wallet, payments, fee, strategy, mempool, mempool.blockchain)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := mempool.Add(tx); err != nil {
+		return nil, nil, err
+	}
+	return tx, selection, nil
+}
+
+// parsePayment は "<address|label>=<amount>" 形式の支払いを読み取り、送金先をアドレス帳で解決します
+func parsePayment(arg string, book *Wallets) (Payment, error) {
+	recipient, amountText, ok := strings.Cut(arg, "=")
+	if !ok {
+		return Payment{}, fmt.Errorf("payment %q must be <address|label>=<amount>", arg)
+	}
+	amount, err := strconv.Atoi(amountText)
+	if err != nil || amount <= 0 {
+		return Payment{}, fmt.Errorf("payment %q: amount must be a positive whole number", arg)
+	}
+	to, err := book.ResolveRecipient(recipient)
+	if err != nil {
+		return Payment{}, err
+	}
+	if err := common.ValidateAddress(to); err != nil {
+		return Payment{}, err
+	}
+	return Payment{To: to, Amount: amount}, nil
+}
+
+const sendManyUsage = "❌ Usage: sendmany [--fee <coins>] [--coin-selection <strategy>] <address|label>=<amount>..."
+
+// runSendManyCommand は sendmany サブコマンドを実行します
+// 複数の送金先への支払いを1つのトランザクションにまとめ、おつりの出力を1つにしてUTXOが細かく分かれるのを防ぎます
+func runSendManyCommand(args []string) int {
+	fs := flag.NewFlagSet("sendmany", flag.ContinueOnError)
+	feeFlag := fs.Int("fee", DefaultTransactionFee, "手数料（マイナーが受け取る）")
+	selectionFlag := fs.String("coin-selection", string(DefaultCoinSelection), "UTXOの選び方（in-order, largest-first, smallest-first, branch-and-bound）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	strategy, err := ParseCoinSelectionStrategy(*selectionFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Println(sendManyUsage)
+		return 2
+	}
+
+	// 送金先はウォレットを作成する前に解決・検証する（ファイルがなければアドレス帳は空）
+	book, err := LoadWalletsFromFile(walletsFile)
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+	payments := make([]Payment, 0, fs.NArg())
+	total := 0
+	for _, arg := range fs.Args() {
+		payment, err := parsePayment(arg, book)
+		var addressErr *common.AddressError
+		switch {
+		case errors.As(err, &addressErr):
+			printAddressError(err)
+			return 2
+		case err != nil:
+			fmt.Printf("❌ %v\n", err)
+			return 2
+		}
+		payments = append(payments, payment)
+		total += payment.Amount
+	}
+
+	wallets, err := loadOrCreateWallets()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+	wallet, err := wallets.ActiveWallet()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+
+	store, bc, utxoSet, err := openChain(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+	mempool := NewMempool(bc, utxoSet)
+
+	fmt.Printf("\n⛏️  Paying %d coins to %d recipients in one transaction and mining it...\n", total, len(payments))
+	// おつりは keypool の新しいアドレスに送る
+	tx, _, err := wallets.SubmitPayments(mempool, payments, *feeFlag, strategy)
+	if err == nil {
+		err = wallets.SaveToFile(walletsFile)
+	}
+	if err != nil {
+		printSendError(err)
+		return 1
+	}
+	block, metrics, err := mempool.MineBlock(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ Send failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("\n✅ Batch payment sent!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	for i, payment := range payments {
+		fmt.Printf("%2d. %-40s %8d coins\n", i+1, wallets.DisplayAddress(payment.To), payment.Amount)
+	}
+	fmt.Printf("Total:      %d coins\n", total)
+	fmt.Printf("Fee:        %d coins (%d bytes)\n", *feeFlag, tx.Size())
+	fmt.Printf("TxID:       %s\n", truncateHash(hex.EncodeToString(tx.ID)))
+	fmt.Printf("Inputs:     %d UTXO(s)\n", len(tx.Inputs))
+	if len(tx.Outputs) > len(payments) {
+		fmt.Printf("Change:     %d coins\n", tx.Outputs[len(tx.Outputs)-1].Value)
+	}
+	fmt.Printf("Outputs:    %d (%d separate sends would create up to %d)\n", len(tx.Outputs), len(payments), 2*len(payments))
+	fmt.Printf("Block #%d:  %s (%d attempts)\n", block.Index, truncateHash(block.Hash), metrics.Attempts)
+	fmt.Printf("Balance:    %s\n", wallets.GetTotalBalance(mempool))
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitBatchTransaction(t *testing.T) {
+	t.Run("複数の送金先に1つのトランザクションで支払い、おつりは1つにまとめる", func(t *testing.T) {
+		wallet, _, utxoSet, mempool := newTestChain(t)
+		other, err := NewWallet()
+		require.NoError(t, err)
+		payments := []Payment{{To: testAddressA, Amount: 10}, {To: testAddressB, Amount: 5}, {To: other.GetAddress(), Amount: 7}}
+
+		tx, _, err := SubmitBatchTransaction(mempool, wallet, payments, 2, DefaultCoinSelection)
+		require.NoError(t, err)
+		require.Len(t, tx.Outputs, 4)
+		for i, payment := range payments {
+			assert.Equal(t, payment.To, tx.Outputs[i].Address())
+			assert.Equal(t, payment.Amount, tx.Outputs[i].Value)
+		}
+		assert.Equal(t, wallet.GetAddress(), tx.Outputs[3].Address())
+		assert.Equal(t, 50-22-2, tx.Outputs[3].Value)
+
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+		assert.Equal(t, 10, utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, 7, utxoSet.GetBalance(other.GetAddress()))
+		assert.Len(t, utxoSet.FindUTXO(wallet.GetAddress()), 1)
+	})
+
+	t.Run("ちょうど使い切ればおつりの出力を作らない", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+
+		tx, _, err := SubmitBatchTransaction(mempool, wallet, []Payment{{To: testAddressA, Amount: 30}, {To: testAddressB, Amount: 19}}, 1, DefaultCoinSelection)
+		require.NoError(t, err)
+		assert.Len(t, tx.Outputs, 2)
+	})
+
+	t.Run("不正な支払いを拒否する", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+
+		cases := map[string][]Payment{
+			"no payments":             nil,
+			"amount must be positive": {{To: testAddressA, Amount: 10}, {To: testAddressB, Amount: 0}},
+			"duplicate recipient":     {{To: testAddressA, Amount: 10}, {To: testAddressA, Amount: 5}},
+			"insufficient funds":      {{To: testAddressA, Amount: 30}, {To: testAddressB, Amount: 30}},
+		}
+		for message, payments := range cases {
+			_, _, err := SubmitBatchTransaction(mempool, wallet, payments, 1, DefaultCoinSelection)
+			assert.ErrorContains(t, err, message)
+		}
+		assert.Equal(t, 0, mempool.Size())
+	})
+}
+
+func TestWalletSignAll(t *testing.T) {
+	t.Run("バッチの中で連鎖するトランザクションにまとめて署名する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+
+		first, _, err := NewUnsignedTransaction(wallet.GetAddress(), testAddressA, 20, 1, DefaultCoinSelection, utxoSet)
+		require.NoError(t, err)
+		// 1つ目のおつり（チェーンにはまだない出力）を使う2つ目の送金
+		change, err := newOutput(testAddressB, first.Outputs[1].Value-1)
+		require.NoError(t, err)
+		second := &Transaction{
+			Version:   CurrentTxVersion,
+			Inputs:    []TxInput{{TxID: first.ID, OutIndex: 1}},
+			Outputs:   []TxOutput{change},
+			Timestamp: first.Timestamp,
+		}
+		second.ID = second.Hash()
+
+		require.NoError(t, wallet.SignAll([]*Transaction{first, second}, bc.FindTransaction))
+		require.NoError(t, mempool.Add(first))
+		require.NoError(t, mempool.Add(second))
+	})
+
+	t.Run("署名できないトランザクションの番号を返す", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		other, err := NewWallet()
+		require.NoError(t, err)
+
+		mine, _, err := NewUnsignedTransaction(wallet.GetAddress(), testAddressA, 10, 1, DefaultCoinSelection, utxoSet)
+		require.NoError(t, err)
+		theirs, _, err := NewUnsignedTransaction(wallet.GetAddress(), testAddressA, 10, 1, DefaultCoinSelection, utxoSet)
+		require.NoError(t, err)
+
+		assert.NoError(t, wallet.SignAll([]*Transaction{mine}, bc.FindTransaction))
+		assert.ErrorContains(t, other.SignAll([]*Transaction{theirs}, bc.FindTransaction), "transaction 0: wallet")
+
+		unknown := &Transaction{Inputs: []TxInput{{TxID: []byte{1, 2, 3}, OutIndex: 0}}}
+		assert.ErrorContains(t, wallet.SignAll([]*Transaction{mine, unknown}, bc.FindTransaction), "transaction 1: prev transaction not found")
+	})
+}
@@ -3,7 +3,6 @@ package main
 
 import (
 	"bytes"
-	"encoding/gob"
 	"fmt"
 	"time"
 
@@ -41,7 +40,7 @@ func NewBlock(index int64, transactions []*Transaction, previousHash string, dif
 // NewGenesisBlock はジェネシスブロックを作成します
 func NewGenesisBlock(difficulty int, minerAddress string) *Block {
 	// コインベーストランザクションを作成
-	coinbaseTx := NewCoinbaseTx(minerAddress, "Genesis Block")
+	coinbaseTx := NewCoinbaseTxAtHeight(minerAddress, "Genesis Block", InitialBlockReward, 0)
 
 	block := &Block{
 		Index:        0,
@@ -64,62 +63,116 @@ func NewGenesisBlock(difficulty int, minerAddress string) *Block {
 
 // CalculateHashWithNonce はナンスを含めたブロックのハッシュを計算します
 func (b *Block) CalculateHashWithNonce() string {
-	data := b.prepareData()
-	hash := common.Hash(data)
-	return common.BytesToHex(hash)
+	return b.Header().CalculateHash()
 }
 
-// prepareData はハッシュ計算用のデータを準備します
-func (b *Block) prepareData() []byte {
-	var buffer bytes.Buffer
-
-	// ブロックデータをシリアライズ
-	encoder := gob.NewEncoder(&buffer)
-
-	// トランザクションのハッシュリストを作成
-	txHashes := make([][]byte, 0, len(b.Transactions))
-	for _, tx := range b.Transactions {
-		txHashes = append(txHashes, tx.ID)
-	}
-
-	// ハッシュ計算用のデータ構造
-	type hashData struct {
-		Index        int64
-		Timestamp    int64
-		TxHashes     [][]byte
-		PreviousHash string
-		Nonce        int64
-		Difficulty   int
-	}
+// BlockHeader はブロックのヘッダーで、トランザクションはマークルルートとしてだけ持ちます
+// ブロックハッシュはヘッダーだけから計算できるため、SPVではブロック本体の代わりにヘッダーを保持します
+// トランザクションIDは署名を含まないため、署名は WitnessRoot で別にコミットします
+type BlockHeader struct {
+	Index        int64
+	Timestamp    int64
+	MerkleRoot   []byte
+	WitnessRoot  []byte
+	PreviousHash string
+	Hash         string
+	Nonce        int64
+	Difficulty   int
+}
 
-	data := hashData{
+// Header はブロックのヘッダーを返します
+func (b *Block) Header() BlockHeader {
+	return BlockHeader{
 		Index:        b.Index,
 		Timestamp:    b.Timestamp,
-		TxHashes:     txHashes,
+		MerkleRoot:   b.HashTransactions(),
+		WitnessRoot:  b.HashWitnesses(),
 		PreviousHash: b.PreviousHash,
+		Hash:         b.Hash,
 		Nonce:        b.Nonce,
 		Difficulty:   b.Difficulty,
 	}
+}
 
-	err := encoder.Encode(data)
-	if err != nil {
-		return []byte{}
-	}
+// CalculateHash はヘッダーからブロックハッシュを計算します
+func (h BlockHeader) CalculateHash() string {
+	return common.BytesToHex(common.Hash(h.prepareData()))
+}
+
+// Validate はヘッダーのハッシュとProof of Workを検証します
+func (h BlockHeader) Validate() bool {
+	return h.CalculateHash() == h.Hash && CheckHashDifficulty(h.Hash, h.Difficulty)
+}
 
-	return buffer.Bytes()
+// prepareData はハッシュ計算用に、ヘッダーを正規のバイナリ形式にします（Hash 自身は含めません）
+// トランザクションはマークルルートでまとめるため、個々のトランザクションを見せずに含まれていることを証明できます
+// gobと違って型の登録順に依存しないため、同じヘッダーは常に同じバイト列（同じハッシュ）になります
+//
+//	高さ            int64
+//	タイムスタンプ  int64
+//	マークルルート  varint長 + バイト列
+//	署名のルート    varint長 + バイト列
+//	前ブロック      varint長 + ハッシュ（16進数の文字列）
+//	ナンス          int64
+//	難易度          int64
+//
+// 整数はすべてリトルエンディアンで、書き込みはトランザクションの Serialize と共通です
+func (h BlockHeader) prepareData() []byte {
+	var buf bytes.Buffer
+
+	writeUint64(&buf, uint64(h.Index))     // #nosec G115 -- int64 をそのままのビット列で書く
+	writeUint64(&buf, uint64(h.Timestamp)) // #nosec G115 -- int64 をそのままのビット列で書く
+	writeVarBytes(&buf, h.MerkleRoot)
+	writeVarBytes(&buf, h.WitnessRoot)
+	writeVarBytes(&buf, []byte(h.PreviousHash))
+	writeUint64(&buf, uint64(h.Nonce))      // #nosec G115 -- int64 をそのままのビット列で書く
+	writeUint64(&buf, uint64(h.Difficulty)) // #nosec G115 -- int をそのままのビット列で書く
+
+	return buf.Bytes()
 }
 
 // HashTransactions はブロック内の全トランザクションのマークルルートを計算します
+// 保存された ID ではなく内容から計算し直したIDを使います
 func (b *Block) HashTransactions() []byte {
 	txHashes := make([][]byte, 0, len(b.Transactions))
 
 	for _, tx := range b.Transactions {
-		txHashes = append(txHashes, tx.ID)
+		txHashes = append(txHashes, tx.Hash())
 	}
 
 	return common.MerkleRoot(txHashes)
 }
 
+// HashWitnesses はブロック内の全トランザクションの、署名を含めたハッシュ（WitnessHash）のマークルルートを計算します
+// トランザクションIDは署名を除いて計算するため、これもブロックハッシュに含めて署名の書き換えを検出します
+func (b *Block) HashWitnesses() []byte {
+	witnessHashes := make([][]byte, 0, len(b.Transactions))
+
+	for _, tx := range b.Transactions {
+		witnessHashes = append(witnessHashes, tx.WitnessHash())
+	}
+
+	return common.MerkleRoot(witnessHashes)
+}
+
+// GenerateMerkleProof はトランザクションがブロックに含まれることの証明（ルートまでの兄弟のハッシュ）を返します
+// common.VerifyMerkleProof にマークルルート・TxID・証明を渡すと、他のトランザクションなしで検証できます
+func (b *Block) GenerateMerkleProof(txID []byte) ([]common.MerkleStep, error) {
+	txHashes := make([][]byte, 0, len(b.Transactions))
+	index := -1
+	for i, tx := range b.Transactions {
+		txHashes = append(txHashes, tx.ID)
+		if index < 0 && bytes.Equal(tx.ID, txID) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("transaction %x is not in block %d", txID, b.Index)
+	}
+
+	return common.NewMerkleTree(txHashes).Proof(index)
+}
+
 // Validate はブロックの整合性を検証します
 func (b *Block) Validate() bool {
 	// ハッシュの再計算
@@ -143,6 +196,8 @@ func (b *Block) String() string {
 	result := fmt.Sprintf("Block #%d\n", b.Index)
 	result += fmt.Sprintf("Timestamp: %s\n", time.Unix(b.Timestamp, 0).Format("2006-01-02 15:04:05"))
 	result += fmt.Sprintf("Transactions: %d\n", len(b.Transactions))
+	result += fmt.Sprintf("Merkle Root: %x\n", b.HashTransactions())
+	result += fmt.Sprintf("Witness Root: %x\n", b.HashWitnesses())
 	result += fmt.Sprintf("Previous Hash: %s\n", b.PreviousHash)
 	result += fmt.Sprintf("Hash: %s\n", b.Hash)
 	result += fmt.Sprintf("Nonce: %d\n", b.Nonce)
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProofBlock は n 個のトランザクションを含むブロックを作ります
+func newProofBlock(t *testing.T, n int) *Block {
+	t.Helper()
+
+	wallet, bc, utxoSet, mempool := newTestChain(t)
+	fundWallet(t, bc, utxoSet, wallet, n)
+	for i := 1; i < n; i++ {
+		_, err := SubmitTransaction(mempool, wallet, testAddressA, i, 1)
+		require.NoError(t, err)
+	}
+	block, _, err := mempool.MineBlock(wallet.GetAddress())
+	require.NoError(t, err)
+	require.Len(t, block.Transactions, n)
+	return block
+}
+
+func TestGenerateMerkleProof(t *testing.T) {
+	t.Run("ブロック内のすべてのトランザクションを証明できる", func(t *testing.T) {
+		block := newProofBlock(t, 5)
+		root := block.HashTransactions()
+
+		for _, tx := range block.Transactions {
+			proof, err := block.GenerateMerkleProof(tx.ID)
+			require.NoError(t, err)
+			assert.Len(t, proof, 3)
+			assert.True(t, common.VerifyMerkleProof(root, tx.ID, proof))
+		}
+	})
+
+	t.Run("ブロックにないトランザクションはエラー", func(t *testing.T) {
+		block := newProofBlock(t, 2)
+		_, err := block.GenerateMerkleProof([]byte("missing"))
+		assert.Error(t, err)
+	})
+
+	t.Run("ブロックハッシュはマークルルートを通じてトランザクションに依存する", func(t *testing.T) {
+		block := newProofBlock(t, 3)
+		require.True(t, block.Validate())
+
+		block.Transactions[1], block.Transactions[2] = block.Transactions[2], block.Transactions[1]
+		assert.False(t, block.Validate())
+	})
+}
+
+func TestFindBlockTransaction(t *testing.T) {
+	block := newProofBlock(t, 3)
+	id := common.BytesToHex(block.Transactions[1].ID)
+
+	t.Run("IDの先頭の一部で探せる", func(t *testing.T) {
+		tx, err := findBlockTransaction(block, id[:12])
+		require.NoError(t, err)
+		assert.Equal(t, block.Transactions[1], tx)
+	})
+
+	t.Run("見つからない・一意に決まらないときはエラー", func(t *testing.T) {
+		_, err := findBlockTransaction(block, "zz")
+		assert.Error(t, err)
+		_, err = findBlockTransaction(block, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("引数が足りなければ使い方を表示", func(t *testing.T) {
+		assert.Equal(t, 2, runProveCommand(nil))
+		assert.Equal(t, 2, runProveCommand([]string{"-block", "1"}))
+	})
+}
+
+func TestBlockHeaderPrepareData(t *testing.T) {
+	header := BlockHeader{
+		Index:        1,
+		Timestamp:    0x0102030405060708,
+		MerkleRoot:   []byte{0xaa, 0xbb},
+		WitnessRoot:  []byte{0xcc},
+		PreviousHash: "00ff",
+		Hash:         "ignored",
+		Nonce:        -1,
+		Difficulty:   4,
+	}
+
+	expected := "0100000000000000" + // 高さ
+		"0807060504030201" + // タイムスタンプ
+		"02aabb" + // マークルルート
+		"01cc" + // 署名のルート
+		"0430306666" + // 前ブロックのハッシュ（文字列 "00ff"）
+		"ffffffffffffffff" + // ナンス
+		"0400000000000000" // 難易度
+	assert.Equal(t, expected, common.BytesToHex(header.prepareData()))
+
+	// Hash はハッシュの計算に含まれない
+	other := header
+	other.Hash = ""
+	assert.Equal(t, header.CalculateHash(), other.CalculateHash())
+}
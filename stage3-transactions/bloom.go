@@ -0,0 +1,239 @@
+// Package main implements BIP37-style bloom filters for Stage 3 light clients.
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// BIP37 と同じブルームフィルターの上限
+const (
+	MaxBloomFilterSize = 36000 // ビット配列の最大バイト数
+	MaxBloomHashFuncs  = 50    // ハッシュ関数の最大数
+)
+
+// bloomSeedMultiplier は i 番目のハッシュ関数のシードを i*bloomSeedMultiplier + tweak にする係数（BIP37と同じ）
+const bloomSeedMultiplier = 0xFBA4C795
+
+// BloomFilter は要素が「含まれるかもしれない」ことを判定する確率的なデータ構造です
+// 含まれない要素を含むと判定することはありますが（偽陽性）、含まれる要素を見落とすことはありません
+// SPVクライアントは自分のアドレスそのものではなくフィルターを渡すため、どれが自分のトランザクションかをノードに隠せます
+type BloomFilter struct {
+	Bits      []byte
+	HashFuncs uint32
+	Tweak     uint32 // ハッシュ関数のシードに加える値（同じ要素でもフィルターごとに異なるビットになる）
+}
+
+// NewBloomFilter は elements 個の要素を偽陽性率 falsePositiveRate で判定できる大きさのフィルターを作ります
+// 大きさとハッシュ関数の数はBIP37の式で決め、上限を超える場合は上限に揃えます
+func NewBloomFilter(elements int, falsePositiveRate float64, tweak uint32) (*BloomFilter, error) {
+	if elements <= 0 {
+		return nil, fmt.Errorf("bloom filter needs at least one element")
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("false positive rate must be between 0 and 1, got %g", falsePositiveRate)
+	}
+
+	size := -1 / (math.Ln2 * math.Ln2) * float64(elements) * math.Log(falsePositiveRate) / 8
+	bytes := int(math.Max(1, math.Min(size, MaxBloomFilterSize)))
+	hashFuncs := float64(bytes*8) / float64(elements) * math.Ln2
+	hashFuncs = math.Max(1, math.Min(hashFuncs, MaxBloomHashFuncs))
+
+	return &BloomFilter{
+		Bits:      make([]byte, bytes),
+		HashFuncs: uint32(hashFuncs),
+		Tweak:     tweak,
+	}, nil
+}
+
+// bitIndex は i 番目のハッシュ関数で data が対応するビットの位置を返します
+func (f *BloomFilter) bitIndex(i uint32, data []byte) uint32 {
+	return murmur3(i*bloomSeedMultiplier+f.Tweak, data) % uint32(len(f.Bits)*8) // #nosec G115 -- 大きさは MaxBloomFilterSize 以下
+}
+
+// Add は要素を追加します
+func (f *BloomFilter) Add(data []byte) {
+	for i := uint32(0); i < f.HashFuncs; i++ {
+		index := f.bitIndex(i, data)
+		f.Bits[index/8] |= 1 << (index % 8)
+	}
+}
+
+// Contains は要素が含まれるかもしれないなら true を返します
+func (f *BloomFilter) Contains(data []byte) bool {
+	for i := uint32(0); i < f.HashFuncs; i++ {
+		index := f.bitIndex(i, data)
+		if f.Bits[index/8]&(1<<(index%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchTransaction はトランザクションがフィルターに一致するか判定します（BIP37と同じ順序で調べます）
+//   - TxID
+//   - 出力のロックスクリプトに含まれるデータ（公開鍵ハッシュやスクリプトハッシュ）
+//   - 入力が使う出力（アウトポイント）と、scriptSig に含まれるデータ（公開鍵など）
+//
+// 一致した出力のアウトポイントはフィルターに追加するため、その出力を後で使うトランザクションも一致します
+func (f *BloomFilter) MatchTransaction(tx *Transaction) bool {
+	matched := f.Contains(tx.ID)
+	for index, output := range tx.Outputs {
+		for _, data := range scriptData(output.ScriptPubKey) {
+			if f.Contains(data) {
+				matched = true
+				f.Add(outpointBytes(tx.ID, index))
+				break
+			}
+		}
+	}
+	if matched || tx.IsCoinbase() {
+		return matched
+	}
+
+	for _, input := range tx.Inputs {
+		if f.Contains(outpointBytes(input.TxID, input.OutIndex)) {
+			return true
+		}
+		for _, data := range scriptData(input.ScriptSig) {
+			if f.Contains(data) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scriptData はスクリプトが積むデータ（空でないもの）を返します。解釈できないスクリプトは何も返しません
+func scriptData(script Script) [][]byte {
+	ops, err := script.parse()
+	if err != nil {
+		return nil
+	}
+
+	var data [][]byte
+	for _, op := range ops {
+		if len(op.data) > 0 {
+			data = append(data, op.data)
+		}
+	}
+	return data
+}
+
+// FilteredBlock はフィルターに一致したトランザクションだけを、ヘッダーとマークル証明とともに持つブロックです
+type FilteredBlock struct {
+	Header       BlockHeader
+	Transactions []*Transaction
+	Proofs       []*TxProof
+	Total        int // ブロック内のトランザクション数
+}
+
+// FilterBlock はブロックからフィルターに一致するトランザクションを選び、それぞれのマークル証明を付けて返します（ノード側の処理）
+// 一致した出力はフィルターに追加されるため、ブロックは高さ順に渡します
+func FilterBlock(block *Block, filter *BloomFilter) (*FilteredBlock, error) {
+	filtered := &FilteredBlock{Header: block.Header(), Total: len(block.Transactions)}
+	for _, tx := range block.Transactions {
+		if !filter.MatchTransaction(tx) {
+			continue
+		}
+		proof, err := newTxProof(block, tx.ID)
+		if err != nil {
+			return nil, err
+		}
+		filtered.Transactions = append(filtered.Transactions, tx)
+		filtered.Proofs = append(filtered.Proofs, proof)
+	}
+	return filtered, nil
+}
+
+// bloomElements はウォレットの出力と入力を見分けるための要素（各アドレスの公開鍵ハッシュと公開鍵）を返します
+func (w *Wallet) bloomElements() [][]byte {
+	var elements [][]byte
+	for _, address := range w.Addresses() {
+		if pubKeyHash, err := common.DecodeAddress(address); err == nil {
+			elements = append(elements, pubKeyHash)
+		}
+	}
+	if w.PublicKey != nil {
+		elements = append(elements, publicKeyToBytes(w.PublicKey))
+	}
+	return elements
+}
+
+// NewBloomFilter はウォレットの公開鍵ハッシュと公開鍵を入れたフィルターを作ります
+func (w *Wallet) NewBloomFilter(falsePositiveRate float64, tweak uint32) (*BloomFilter, error) {
+	return newBloomFilterOf(w.bloomElements(), falsePositiveRate, tweak)
+}
+
+// NewBloomFilter はすべてのウォレットの公開鍵ハッシュと公開鍵、P2SHのスクリプトハッシュを入れたフィルターを作ります
+func (ws *Wallets) NewBloomFilter(falsePositiveRate float64, tweak uint32) (*BloomFilter, error) {
+	var elements [][]byte
+	for _, address := range ws.GetAddresses() {
+		elements = append(elements, ws.Wallets[address].bloomElements()...)
+	}
+	for _, address := range ws.GetScriptAddresses() {
+		elements = append(elements, ws.Scripts[address].Hash())
+	}
+	return newBloomFilterOf(elements, falsePositiveRate, tweak)
+}
+
+// newBloomFilterOf は要素をすべて入れたフィルターを作ります
+func newBloomFilterOf(elements [][]byte, falsePositiveRate float64, tweak uint32) (*BloomFilter, error) {
+	filter, err := NewBloomFilter(len(elements), falsePositiveRate, tweak)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		filter.Add(element)
+	}
+	return filter, nil
+}
+
+// murmur3 は MurmurHash3（x86, 32ビット）でハッシュを計算します（BIP37が使うハッシュ関数）
+func murmur3(seed uint32, data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h := seed
+	blocks := len(data) / 4
+	for i := 0; i < blocks; i++ {
+		k := uint32(data[i*4]) | uint32(data[i*4+1])<<8 | uint32(data[i*4+2])<<16 | uint32(data[i*4+3])<<24
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[blocks*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data)) // #nosec G115 -- ハッシュする長さは32ビットに収まる
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
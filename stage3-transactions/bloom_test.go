@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mustDecodeAddress はアドレスの公開鍵ハッシュを返します
+func mustDecodeAddress(t *testing.T, address string) []byte {
+	t.Helper()
+
+	pubKeyHash, err := common.DecodeAddress(address)
+	require.NoError(t, err)
+	return pubKeyHash
+}
+
+func TestMurmur3(t *testing.T) {
+	t.Run("既知のテストベクターと一致する", func(t *testing.T) {
+		assert.Equal(t, uint32(0x00000000), murmur3(0, nil))
+		assert.Equal(t, uint32(0x514e28b7), murmur3(1, nil))
+		assert.Equal(t, uint32(0x81f16f39), murmur3(0xffffffff, nil))
+		assert.Equal(t, uint32(0x76293b50), murmur3(0, []byte{0xff, 0xff, 0xff, 0xff}))
+		assert.Equal(t, uint32(0xf55b516b), murmur3(0, []byte{0x21, 0x43, 0x65, 0x87}))
+		assert.Equal(t, uint32(0x7e4a8634), murmur3(0, []byte{0x21, 0x43, 0x65}))
+		assert.Equal(t, uint32(0xa0f7b07a), murmur3(0, []byte{0x21, 0x43}))
+		assert.Equal(t, uint32(0x72661cf4), murmur3(0, []byte{0x21}))
+	})
+}
+
+func TestBloomFilter(t *testing.T) {
+	t.Run("BIP37の式で大きさを決め、上限で止める", func(t *testing.T) {
+		filter, err := NewBloomFilter(3, 0.01, 0)
+		require.NoError(t, err)
+		assert.Len(t, filter.Bits, 3)
+		assert.Equal(t, uint32(5), filter.HashFuncs)
+
+		huge, err := NewBloomFilter(1000000, 0.0001, 0)
+		require.NoError(t, err)
+		assert.Len(t, huge.Bits, MaxBloomFilterSize)
+		assert.LessOrEqual(t, huge.HashFuncs, uint32(MaxBloomHashFuncs))
+	})
+
+	t.Run("不正な引数は拒否する", func(t *testing.T) {
+		_, err := NewBloomFilter(0, 0.01, 0)
+		assert.Error(t, err)
+		_, err = NewBloomFilter(1, 0, 0)
+		assert.Error(t, err)
+		_, err = NewBloomFilter(1, 1, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("追加した要素は必ず含まれ、偽陽性は指定した率程度", func(t *testing.T) {
+		filter, err := NewBloomFilter(100, 0.01, 12345)
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			filter.Add([]byte(fmt.Sprintf("element-%d", i)))
+		}
+		for i := 0; i < 100; i++ {
+			assert.True(t, filter.Contains([]byte(fmt.Sprintf("element-%d", i))))
+		}
+
+		falsePositives := 0
+		for i := 0; i < 10000; i++ {
+			if filter.Contains([]byte(fmt.Sprintf("other-%d", i))) {
+				falsePositives++
+			}
+		}
+		assert.Less(t, falsePositives, 300)
+	})
+
+	t.Run("tweakが違えば同じ要素でも立つビットが変わる", func(t *testing.T) {
+		a, err := NewBloomFilter(1, 0.01, 1)
+		require.NoError(t, err)
+		b, err := NewBloomFilter(1, 0.01, 2)
+		require.NoError(t, err)
+		a.Add([]byte("element"))
+		b.Add([]byte("element"))
+		assert.NotEqual(t, a.Bits, b.Bits)
+	})
+}
+
+func TestFilterBlock(t *testing.T) {
+	// 送金を2回含むチェーン（サブテストは読むだけ）
+	wallet, bc, _, mempool := newTestChain(t)
+	var blocks []*Block
+	for _, amount := range []int{5, 7} {
+		block, _, err := SendCoins(mempool, wallet, testAddressA, amount, 1)
+		require.NoError(t, err)
+		blocks = append(blocks, block)
+	}
+
+	t.Run("受け取りとその出力を使った送金を証明付きで返す", func(t *testing.T) {
+		client := NewSPVClient()
+		_, err := client.SyncHeaders(bc)
+		require.NoError(t, err)
+
+		// 送金先（testAddressA）の公開鍵ハッシュだけを入れたフィルター（一致した出力が追加される分の余裕を持たせる）
+		filter, err := NewBloomFilter(10, 0.0001, 0)
+		require.NoError(t, err)
+		filter.Add(mustDecodeAddress(t, testAddressA))
+
+		var matched []*Transaction
+		for _, block := range bc.Blocks {
+			filtered, err := FilterBlock(block, filter)
+			require.NoError(t, err)
+			assert.Equal(t, block.Hash, filtered.Header.Hash)
+			assert.Equal(t, len(block.Transactions), filtered.Total)
+			require.Len(t, filtered.Proofs, len(filtered.Transactions))
+			for _, proof := range filtered.Proofs {
+				require.NoError(t, client.AddProof(proof))
+			}
+			matched = append(matched, filtered.Transactions...)
+		}
+
+		require.Len(t, matched, 2)
+		assert.Equal(t, blocks[0].Transactions[1].ID, matched[0].ID)
+		assert.Equal(t, blocks[1].Transactions[1].ID, matched[1].ID)
+	})
+
+	t.Run("ウォレットのフィルターは自分の送金とおつりを使う送金に一致する", func(t *testing.T) {
+		filter, err := wallet.NewBloomFilter(0.000001, 42)
+		require.NoError(t, err)
+
+		// ジェネシスのコインベース、2回の送金、2つのブロックの報酬
+		count := 0
+		for _, block := range bc.Blocks {
+			filtered, err := FilterBlock(block, filter)
+			require.NoError(t, err)
+			count += len(filtered.Transactions)
+		}
+		assert.Equal(t, 5, count)
+	})
+
+	t.Run("一致した出力を使う送金は、公開鍵がなくてもアウトポイントで一致する", func(t *testing.T) {
+		received := blocks[0].Transactions[1]
+		filter, err := newBloomFilterOf([][]byte{mustDecodeAddress(t, testAddressA)}, 0.000001, 0)
+		require.NoError(t, err)
+
+		spend := &Transaction{ID: []byte("spend"), Inputs: []TxInput{{TxID: received.ID, OutIndex: 0}}}
+		assert.False(t, filter.MatchTransaction(spend), "受け取りを見る前は一致しない")
+
+		require.True(t, filter.MatchTransaction(received))
+		assert.True(t, filter.MatchTransaction(spend))
+	})
+
+	t.Run("関係のないブロックからは何も返さない", func(t *testing.T) {
+		filter, err := newBloomFilterOf([][]byte{mustDecodeAddress(t, testAddressB)}, 0.000001, 0)
+		require.NoError(t, err)
+
+		filtered, err := FilterBlock(bc.Blocks[1], filter)
+		require.NoError(t, err)
+		assert.Empty(t, filtered.Transactions)
+		assert.Empty(t, filtered.Proofs)
+	})
+}
@@ -9,24 +9,125 @@ import (
 
 // Blockchain represents the blockchain
 type Blockchain struct {
-	Blocks     []*Block // ブロックのリスト
-	Difficulty int      // マイニング難易度
-	mutex      sync.RWMutex
+	Blocks          []*Block              // ブロックのリスト
+	Difficulty      int                   // マイニング難易度（自動調整が有効なら次のブロックの難易度）
+	TargetBlockTime int                   // 目標ブロック生成時間（秒）。0なら難易度を自動調整しない
+	Emission        EmissionSchedule      // ブロック報酬の発行スケジュール
+	store           *ChainStore           // ブロックの保存先（nilならメモリ上のみ）
+	txIndex         map[string]TxLocation // TxID(hex) -> トランザクションの位置
+	mutex           sync.RWMutex
 }
 
 // NewBlockchain は新しいブロックチェーンを作成します
 func NewBlockchain(difficulty int, minerAddress string) *Blockchain {
 	// ジェネシスブロックを作成
-	genesis := NewGenesisBlock(difficulty, minerAddress)
+	return NewBlockchainFromGenesis(NewGenesisBlock(difficulty, minerAddress), difficulty)
+}
 
+// NewBlockchainFromGenesis は指定したジェネシスブロック（ジェネシス仕様から作ったものなど）から新しいブロックチェーンを作成します
+func NewBlockchainFromGenesis(genesis *Block, difficulty int) *Blockchain {
 	bc := &Blockchain{
 		Blocks:     []*Block{genesis},
 		Difficulty: difficulty,
+		Emission:   DefaultEmissionSchedule(),
 	}
+	bc.Emission.Premine = genesisPremine(genesis, bc.Emission)
+	bc.indexBlockLocked(genesis)
 
 	return bc
 }
 
+// OpenBlockchain は保存されたブロックからチェーンを復元します
+// 保存されたブロックがなければジェネシスブロックを作成して保存します。追加したブロックも保存されます
+func OpenBlockchain(store *ChainStore, difficulty int, minerAddress string) (*Blockchain, error) {
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blocks: %w", err)
+	}
+
+	if len(blocks) == 0 {
+		return createBlockchain(store, NewBlockchain(difficulty, minerAddress))
+	}
+	return restoreBlockchain(store, blocks, difficulty)
+}
+
+// OpenBlockchainWithGenesis はジェネシス仕様から作ったブロックを使ってチェーンを開きます
+// 保存されたブロックがなければそのジェネシスブロックから始め、あればジェネシスブロックが一致するか確かめます
+func OpenBlockchainWithGenesis(store *ChainStore, difficulty int, genesis *Block) (*Blockchain, error) {
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blocks: %w", err)
+	}
+
+	if len(blocks) == 0 {
+		return createBlockchain(store, NewBlockchainFromGenesis(genesis, difficulty))
+	}
+	if blocks[0].Hash != genesis.Hash {
+		return nil, fmt.Errorf("stored chain starts from genesis %s, but the genesis spec produces %s", truncateHash(blocks[0].Hash), truncateHash(genesis.Hash))
+	}
+	return restoreBlockchain(store, blocks, difficulty)
+}
+
+// createBlockchain は新しく作ったチェーンのジェネシスブロックを保存し、以降のブロックも保存するようにします
+func createBlockchain(store *ChainStore, bc *Blockchain) (*Blockchain, error) {
+	if err := bc.loadHalvingInterval(store); err != nil {
+		return nil, err
+	}
+	if err := store.SaveBlock(bc.Blocks[0]); err != nil {
+		return nil, fmt.Errorf("failed to save genesis block: %w", err)
+	}
+	bc.store = store
+	return bc, nil
+}
+
+// restoreBlockchain は保存されたブロックからチェーンを組み立てます
+func restoreBlockchain(store *ChainStore, blocks []*Block, difficulty int) (*Blockchain, error) {
+	bc := &Blockchain{
+		Blocks:     blocks,
+		Difficulty: difficulty,
+		Emission:   DefaultEmissionSchedule(),
+		store:      store,
+	}
+	bc.Emission.Premine = genesisPremine(blocks[0], bc.Emission)
+	if err := bc.loadHalvingInterval(store); err != nil {
+		return nil, err
+	}
+
+	// 保存されたインデックスが最新ブロックまで反映していないか、すべてのトランザクションを含んでいなければ作り直す
+	index, err := store.LoadTxIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load txindex: %w", err)
+	}
+	tip, err := store.TxIndexTip()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read txindex tip: %w", err)
+	}
+	bc.txIndex = index
+	if tip != blocks[len(blocks)-1].Hash || len(index) != bc.transactionCount() {
+		if _, err := bc.ReindexTransactions(); err != nil {
+			return nil, err
+		}
+	}
+	return bc, nil
+}
+
+// loadHalvingInterval は保存された半減の間隔を発行スケジュールに反映します
+// まだ保存されていなければ（新しいチェーン）、現在の発行スケジュールの間隔を保存します
+func (bc *Blockchain) loadHalvingInterval(store *ChainStore) error {
+	interval, err := store.HalvingInterval()
+	if err != nil {
+		return fmt.Errorf("failed to read halving interval: %w", err)
+	}
+	if interval == 0 {
+		if err := store.SaveHalvingInterval(bc.Emission.HalvingInterval); err != nil {
+			return fmt.Errorf("failed to save halving interval: %w", err)
+		}
+		return nil
+	}
+	bc.Emission.HalvingInterval = interval
+	return nil
+}
+
 // MineBlock はトランザクションを含むブロックをマイニングして追加します
 func (bc *Blockchain) MineBlock(transactions []*Transaction) (*Block, *MiningMetrics, error) {
 	bc.mutex.Lock()
@@ -39,17 +140,29 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) (*Block, *MiningMet
 		lastBlock.Index+1,
 		transactions,
 		lastBlock.Hash,
-		bc.Difficulty,
+		bc.nextDifficultyLocked(),
 	)
 
+	// マイニングの前に、これまでのチェーンに対してトランザクションを検証する
+	if err := bc.stateLocked().connectBlock(newBlock, bc.Emission); err != nil {
+		return nil, nil, fmt.Errorf("invalid block: %w", err)
+	}
+
 	// マイニング
 	metrics, err := MineBlock(newBlock)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to mine block: %w", err)
 	}
 
-	// ブロックをチェーンに追加
+	// ブロックを保存してチェーンに追加
+	if bc.store != nil {
+		if err := bc.store.SaveBlock(newBlock); err != nil {
+			return nil, nil, fmt.Errorf("failed to save block: %w", err)
+		}
+	}
 	bc.Blocks = append(bc.Blocks, newBlock)
+	bc.indexBlockLocked(newBlock)
+	bc.Difficulty = bc.nextDifficultyLocked()
 
 	return newBlock, metrics, nil
 }
@@ -74,110 +187,110 @@ func (bc *Blockchain) GetChainLength() int {
 	return len(bc.Blocks)
 }
 
-// IsValid はブロックチェーン全体の整合性を検証します
+// IsValid はブロックチェーン全体の整合性（リンク・ハッシュ・すべてのトランザクション）を検証します
+// 無効な理由を知りたい場合は Validate を使います
 func (bc *Blockchain) IsValid() bool {
+	return bc.Validate() == nil
+}
+
+// FindTransaction はトランザクションIDからトランザクションを検索します
+// トランザクションインデックスで位置を引くため、チェーンの長さによらず一定の時間で見つかります
+func (bc *Blockchain) FindTransaction(ID []byte) (*Transaction, error) {
 	bc.mutex.RLock()
 	defer bc.mutex.RUnlock()
 
-	if len(bc.Blocks) == 0 {
-		return false
+	if tx, ok := bc.findTransactionLocked(ID); ok {
+		return tx, nil
 	}
+	return nil, fmt.Errorf("transaction not found")
+}
 
-	// ジェネシスブロックの検証
-	if bc.Blocks[0].Index != 0 {
-		return false
-	}
-	if bc.Blocks[0].PreviousHash != "" {
-		return false
+// SignTransaction はトランザクションに署名します
+func (bc *Blockchain) SignTransaction(tx *Transaction, signer Signer) error {
+	prevTxs, err := bc.previousTransactions(tx)
+	if err != nil {
+		return err
 	}
 
-	// 各ブロックを検証
-	for i := 0; i < len(bc.Blocks); i++ {
-		block := bc.Blocks[i]
-
-		// ブロック自体の整合性
-		if !block.Validate() {
-			return false
-		}
-
-		// 前ブロックとのリンク検証（ジェネシス以外）
-		if i > 0 {
-			prevBlock := bc.Blocks[i-1]
+	// 署名
+	return tx.Sign(signer, prevTxs)
+}
 
-			// インデックスの連続性
-			if block.Index != prevBlock.Index+1 {
-				return false
-			}
+// VerifyTransaction はトランザクションを検証します
+func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
 
-			// 前ブロックのハッシュ
-			if block.PreviousHash != prevBlock.Hash {
-				return false
-			}
+	prevTxs, err := bc.previousTransactions(tx)
+	if err != nil {
+		return false
+	}
 
-			// タイムスタンプの順序
-			if block.Timestamp < prevBlock.Timestamp {
-				return false
-			}
-		}
+	// ロック時刻のあるトランザクションは、その高さに達するまでブロックに含められない
+	if err := bc.CheckLockHeight(tx); err != nil {
+		return false
+	}
+	if err := tx.CheckVersion(prevTxs); err != nil {
+		return false
 	}
 
-	return true
+	// 検証
+	return tx.Verify(prevTxs)
 }
 
-// FindTransaction はトランザクションIDからトランザクションを検索します
-func (bc *Blockchain) FindTransaction(ID []byte) (*Transaction, error) {
-	bc.mutex.RLock()
-	defer bc.mutex.RUnlock()
-
-	idStr := hex.EncodeToString(ID)
-
-	for _, block := range bc.Blocks {
-		for _, tx := range block.Transactions {
-			if hex.EncodeToString(tx.ID) == idStr {
-				return tx, nil
-			}
-		}
+// CheckLockHeight はトランザクションが次のブロックに含められるか（ロック時刻に達しているか）を検証します
+func (bc *Blockchain) CheckLockHeight(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
 	}
 
-	return nil, fmt.Errorf("transaction not found")
+	// 次のブロックの高さ（ジェネシスが0なのでチェーンの長さと同じ）
+	if next := bc.GetChainLength(); tx.LockTime > int64(next) {
+		return fmt.Errorf("transaction is timelocked until block %d (next block is %d)", tx.LockTime, next)
+	}
+	return nil
 }
 
-// SignTransaction はトランザクションに署名します
-func (bc *Blockchain) SignTransaction(tx *Transaction, wallet *Wallet) error {
-	// 前トランザクションを取得
+// previousTransactions はトランザクションの入力が参照する前トランザクションを取得します
+// 戻り値: TxID(hex) -> Transaction のマップ
+func (bc *Blockchain) previousTransactions(tx *Transaction) (map[string]*Transaction, error) {
 	prevTxs := make(map[string]*Transaction)
 
 	for _, input := range tx.Inputs {
 		prevTx, err := bc.FindTransaction(input.TxID)
 		if err != nil {
-			return fmt.Errorf("prev transaction not found: %w", err)
+			return nil, fmt.Errorf("prev transaction not found: %w", err)
 		}
 		prevTxs[hex.EncodeToString(prevTx.ID)] = prevTx
 	}
 
-	// 署名
-	return tx.Sign(wallet, prevTxs)
+	return prevTxs, nil
 }
 
-// VerifyTransaction はトランザクションを検証します
-func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+// TransactionFee はトランザクションの手数料（入力の合計 - 出力の合計）を返します
+// コインベーストランザクションの手数料は0です
+func (bc *Blockchain) TransactionFee(tx *Transaction) (int, error) {
 	if tx.IsCoinbase() {
-		return true
+		return 0, nil
 	}
 
-	// 前トランザクションを取得
-	prevTxs := make(map[string]*Transaction)
-
+	fee := 0
 	for _, input := range tx.Inputs {
 		prevTx, err := bc.FindTransaction(input.TxID)
 		if err != nil {
-			return false
+			return 0, fmt.Errorf("prev transaction not found: %w", err)
 		}
-		prevTxs[hex.EncodeToString(prevTx.ID)] = prevTx
+		if input.OutIndex < 0 || input.OutIndex >= len(prevTx.Outputs) {
+			return 0, fmt.Errorf("output index %d out of range", input.OutIndex)
+		}
+		fee += prevTx.Outputs[input.OutIndex].Value
+	}
+	for _, output := range tx.Outputs {
+		fee -= output.Value
 	}
 
-	// 検証
-	return tx.Verify(prevTxs)
+	return fee, nil
 }
 
 // GetAllTransactions はブロックチェーン内の全トランザクションを返します
@@ -0,0 +1,95 @@
+// Package main implements coinbase height commitments for Stage 3.
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// CoinbaseExtraNonceSize はコインベースの scriptSig に入れる乱数（extranonce）のバイト数です
+const CoinbaseExtraNonceSize = 8
+
+// CoinbaseData はコインベースの scriptSig の内容です
+// scriptSig は <高さ> <extranonce> <データ> の3つを積むスクリプトで（高さは省略できます）、
+// 同じ受取先・データ・時刻のコインベースでもトランザクションIDが重ならないようにします
+// どちらの形式でもない scriptSig（以前のコインベースやジェネシス仕様のメッセージ）はデータとして扱います
+type CoinbaseData struct {
+	Height     int64  // コミットしたブロックの高さ
+	HasHeight  bool   // 高さをコミットしているか
+	ExtraNonce []byte // 乱数（なければ nil）
+	Message    string // 任意のデータ
+}
+
+// NewCoinbaseTxAtHeight は指定した高さのブロックの報酬（ブロック報酬 + 手数料）を受け取るコインベーストランザクションを作成します
+// scriptSig にはブロックの高さと乱数を入れるため、同じ内容でも毎回異なるトランザクションIDになります
+func NewCoinbaseTxAtHeight(to string, data string, reward int, height int64) *Transaction {
+	if data == "" {
+		data = fmt.Sprintf("Reward to '%s'", to)
+	}
+	scriptSig := Script{}.AddInt(height).AddData(newExtraNonce()).AddData([]byte(data))
+	return newCoinbaseTx(to, scriptSig, reward)
+}
+
+// newCoinbaseTx は scriptSig と報酬からコインベーストランザクションを作成します
+func newCoinbaseTx(to string, scriptSig Script, reward int) *Transaction {
+	// コインベーストランザクションは入力なし（scriptSigには任意のデータを入れる）
+	txIn := TxInput{
+		TxID:      []byte{},
+		OutIndex:  -1,
+		ScriptSig: scriptSig,
+	}
+
+	// アドレスから出力を作成（アドレスでなければ文字列をそのまま公開鍵ハッシュに使う）
+	txOut, err := newOutput(to, reward) // マイニング報酬 + 手数料
+	if err != nil {
+		txOut = TxOutput{Value: reward, ScriptPubKey: NewP2PKHScript([]byte(to))}
+	}
+
+	tx := &Transaction{
+		Version:   CurrentTxVersion,
+		Inputs:    []TxInput{txIn},
+		Outputs:   []TxOutput{txOut},
+		Timestamp: time.Now().Unix(),
+	}
+
+	tx.ID = tx.Hash()
+
+	return tx
+}
+
+// newExtraNonce はコインベースに入れる乱数を作ります
+func newExtraNonce() []byte {
+	extraNonce := make([]byte, CoinbaseExtraNonceSize)
+	_, _ = rand.Read(extraNonce) // crypto/rand.Read は失敗するとプログラムを止めるため、エラーは返らない
+	return extraNonce
+}
+
+// CoinbaseData はコインベースの scriptSig から高さ・extranonce・データを取り出します
+// コインベースでなければ空の CoinbaseData を返します
+func (tx *Transaction) CoinbaseData() CoinbaseData {
+	if !tx.IsCoinbase() {
+		return CoinbaseData{}
+	}
+	scriptSig := tx.Inputs[0].ScriptSig
+
+	pushes, err := scriptSig.pushes()
+	switch {
+	case err == nil && len(pushes) == 2 && len(pushes[0]) == CoinbaseExtraNonceSize:
+		return CoinbaseData{ExtraNonce: pushes[0], Message: string(pushes[1])}
+	case err == nil && len(pushes) == 3 && len(pushes[1]) == CoinbaseExtraNonceSize:
+		if height, err := decodeScriptNum(pushes[0], 8); err == nil && height >= 0 {
+			return CoinbaseData{Height: height, HasHeight: true, ExtraNonce: pushes[1], Message: string(pushes[2])}
+		}
+	}
+	return CoinbaseData{Message: string(scriptSig)}
+}
+
+// checkCoinbaseHeight はコインベースが高さをコミットしていれば、ブロックの高さと一致するか検証します
+func checkCoinbaseHeight(block *Block) error {
+	data := block.Transactions[0].CoinbaseData()
+	if data.HasHeight && data.Height != block.Index {
+		return fmt.Errorf("block %d: coinbase commits to height %d", block.Index, data.Height)
+	}
+	return nil
+}
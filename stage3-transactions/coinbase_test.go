@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoinbaseData(t *testing.T) {
+	t.Run("高さと乱数とデータを取り出せる", func(t *testing.T) {
+		tx := NewCoinbaseTxAtHeight(testAddressA, "hello", 50, 300)
+
+		data := tx.CoinbaseData()
+		assert.True(t, data.HasHeight)
+		assert.Equal(t, int64(300), data.Height)
+		assert.Len(t, data.ExtraNonce, CoinbaseExtraNonceSize)
+		assert.Equal(t, "hello", data.Message)
+	})
+
+	t.Run("高さ0もコミットできる", func(t *testing.T) {
+		data := NewCoinbaseTxAtHeight(testAddressA, "genesis", 50, 0).CoinbaseData()
+		assert.True(t, data.HasHeight)
+		assert.Equal(t, int64(0), data.Height)
+	})
+
+	t.Run("高さのないコインベースは乱数とデータだけ", func(t *testing.T) {
+		data := NewCoinbaseTx(testAddressA, "no height").CoinbaseData()
+		assert.False(t, data.HasHeight)
+		assert.Len(t, data.ExtraNonce, CoinbaseExtraNonceSize)
+		assert.Equal(t, "no height", data.Message)
+	})
+
+	t.Run("以前の形式の scriptSig はそのままデータとして扱う", func(t *testing.T) {
+		tx := newCoinbaseTx(testAddressA, Script("Genesis Block"), 50)
+
+		data := tx.CoinbaseData()
+		assert.False(t, data.HasHeight)
+		assert.Nil(t, data.ExtraNonce)
+		assert.Equal(t, "Genesis Block", data.Message)
+	})
+
+	t.Run("同じ内容のコインベースでもIDが異なる", func(t *testing.T) {
+		a := NewCoinbaseTxAtHeight(testAddressA, "same", 50, 1)
+		b := NewCoinbaseTxAtHeight(testAddressA, "same", 50, 1)
+		assert.NotEqual(t, a.ID, b.ID)
+
+		c := NewCoinbaseTx(testAddressA, "same")
+		d := NewCoinbaseTx(testAddressA, "same")
+		assert.NotEqual(t, c.ID, d.ID)
+	})
+}
+
+func TestCoinbaseHeightCommitment(t *testing.T) {
+	t.Run("マイニングしたブロックのコインベースは高さをコミットする", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+
+		data := bc.Blocks[0].Transactions[0].CoinbaseData()
+		assert.True(t, data.HasHeight)
+		assert.Equal(t, int64(0), data.Height)
+
+		block, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		data = block.Transactions[0].CoinbaseData()
+		assert.True(t, data.HasHeight)
+		assert.Equal(t, block.Index, data.Height)
+		assert.Equal(t, "Block 1 reward", data.Message)
+		assert.Contains(t, block.Transactions[0].String(), "Coinbase height: 1")
+	})
+
+	t.Run("ブロックの高さと異なる高さをコミットしたコインベースは無効", func(t *testing.T) {
+		wallet, bc, _, _ := newTestChain(t)
+
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTxAtHeight(wallet.GetAddress(), "wrong", InitialBlockReward, 5)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "coinbase commits to height 5")
+
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTxAtHeight(wallet.GetAddress(), "right", InitialBlockReward, 1)})
+		assert.NoError(t, err)
+	})
+}
+
+func TestDuplicateTransactions(t *testing.T) {
+	t.Run("チェーンにあるトランザクションと同じIDのコインベースは無効", func(t *testing.T) {
+		wallet, bc, _, _ := newTestChain(t)
+
+		// 以前の形式では、同じ受取先・データ・時刻のコインベースが同じIDになった
+		first := newCoinbaseTx(wallet.GetAddress(), Script("same reward"), InitialBlockReward)
+		second := newCoinbaseTx(wallet.GetAddress(), Script("same reward"), InitialBlockReward)
+		second.Timestamp = first.Timestamp
+		second.ID = second.Hash()
+		require.Equal(t, first.ID, second.ID)
+
+		_, _, err := bc.MineBlock([]*Transaction{first})
+		require.NoError(t, err)
+		_, _, err = bc.MineBlock([]*Transaction{second})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate transaction")
+	})
+
+	t.Run("ブロック内で同じトランザクションを2回含めると無効", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "dup"), tx, tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate transaction")
+	})
+
+	t.Run("保存されたチェーンの検証でも重複を見つける", func(t *testing.T) {
+		wallet, bc, _, _ := newTestChain(t)
+		coinbase := NewCoinbaseTx(wallet.GetAddress(), "again")
+		appendUnchecked(t, bc, []*Transaction{coinbase})
+		appendUnchecked(t, bc, []*Transaction{coinbase})
+
+		err := bc.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "block 2: duplicate transaction")
+	})
+}
@@ -0,0 +1,201 @@
+// Package main implements coin selection strategies for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CoinSelectionStrategy は送金に使うUTXOの選び方です
+type CoinSelectionStrategy string
+
+// コイン選択の方式
+const (
+	CoinSelectInOrder        CoinSelectionStrategy = "in-order"         // UTXOセットに並んだ順（従来の動作）
+	CoinSelectLargestFirst   CoinSelectionStrategy = "largest-first"    // 金額の大きい順。入力の数が少なくなる
+	CoinSelectSmallestFirst  CoinSelectionStrategy = "smallest-first"   // 金額の小さい順。細かいUTXOを整理できるが入力が増える
+	CoinSelectBranchAndBound CoinSelectionStrategy = "branch-and-bound" // 分枝限定法でおつりが最小になる組み合わせを探す
+)
+
+// DefaultCoinSelection は方式を指定しないときのコイン選択です
+const DefaultCoinSelection = CoinSelectInOrder
+
+// branchAndBoundMaxTries は分枝限定法で調べる組み合わせの上限です（UTXOが多いときに探索を打ち切る）
+const branchAndBoundMaxTries = 100000
+
+// CoinSelectionStrategies は選べるすべての方式です
+var CoinSelectionStrategies = []CoinSelectionStrategy{
+	CoinSelectInOrder,
+	CoinSelectLargestFirst,
+	CoinSelectSmallestFirst,
+	CoinSelectBranchAndBound,
+}
+
+// ParseCoinSelectionStrategy は方式の名前を解釈します（空文字列なら既定の方式）
+func ParseCoinSelectionStrategy(name string) (CoinSelectionStrategy, error) {
+	if name == "" {
+		return DefaultCoinSelection, nil
+	}
+	for _, strategy := range CoinSelectionStrategies {
+		if string(strategy) == name {
+			return strategy, nil
+		}
+	}
+
+	names := make([]string, len(CoinSelectionStrategies))
+	for i, strategy := range CoinSelectionStrategies {
+		names[i] = string(strategy)
+	}
+	return "", fmt.Errorf("unknown coin selection %q (choose from %s)", name, strings.Join(names, ", "))
+}
+
+// CoinSelection はコイン選択の結果です
+type CoinSelection struct {
+	Strategy CoinSelectionStrategy
+	UTXOs    []UTXO // 選んだUTXO
+	Total    int    // 選んだUTXOの合計
+	Target   int    // 必要な金額（送金額 + 手数料）
+}
+
+// Change はおつりの額を返します
+func (cs *CoinSelection) Change() int {
+	return cs.Total - cs.Target
+}
+
+// spendable は選んだUTXOを inputsFromSpendable に渡す形（TxID -> 出力インデックス）にします
+func (cs *CoinSelection) spendable() map[string][]int {
+	spendable := make(map[string][]int)
+	for _, utxo := range cs.UTXOs {
+		txID := hex.EncodeToString(utxo.TxID)
+		spendable[txID] = append(spendable[txID], utxo.OutIndex)
+	}
+	return spendable
+}
+
+// SelectCoins は候補のUTXOから、指定した方式で target 以上になる組み合わせを選びます
+// 候補の合計が足りなければエラーを返します
+func SelectCoins(utxos []UTXO, target int, strategy CoinSelectionStrategy) (*CoinSelection, error) {
+	available := 0
+	for _, utxo := range utxos {
+		available += utxo.Output.Value
+	}
+	if available < target {
+		return nil, fmt.Errorf("insufficient funds: have %d, need %d", available, target)
+	}
+
+	var selected []UTXO
+	switch strategy {
+	case CoinSelectInOrder:
+		selected = accumulateCoins(utxos, target)
+	case CoinSelectLargestFirst:
+		selected = accumulateCoins(sortedByValue(utxos, true), target)
+	case CoinSelectSmallestFirst:
+		selected = accumulateCoins(sortedByValue(utxos, false), target)
+	case CoinSelectBranchAndBound:
+		selected = branchAndBound(utxos, target)
+	default:
+		return nil, fmt.Errorf("unknown coin selection %q", strategy)
+	}
+
+	selection := &CoinSelection{Strategy: strategy, UTXOs: selected, Target: target}
+	for _, utxo := range selected {
+		selection.Total += utxo.Output.Value
+	}
+	return selection, nil
+}
+
+// CompareCoinSelection はすべての方式で選んだ結果を返します（入力の数やおつりを比べるため）
+// 候補の合計が足りなければエラーを返します
+func CompareCoinSelection(utxos []UTXO, target int) ([]*CoinSelection, error) {
+	selections := make([]*CoinSelection, 0, len(CoinSelectionStrategies))
+	for _, strategy := range CoinSelectionStrategies {
+		selection, err := SelectCoins(utxos, target, strategy)
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	return selections, nil
+}
+
+// accumulateCoins は先頭から順に target に届くまでUTXOを選びます
+func accumulateCoins(utxos []UTXO, target int) []UTXO {
+	var selected []UTXO
+	total := 0
+	for _, utxo := range utxos {
+		if total >= target && len(selected) > 0 {
+			break
+		}
+		selected = append(selected, utxo)
+		total += utxo.Output.Value
+	}
+	return selected
+}
+
+// sortedByValue は金額順に並べたコピーを返します（同じ金額は元の順序を保つ）
+func sortedByValue(utxos []UTXO, descending bool) []UTXO {
+	sorted := make([]UTXO, len(utxos))
+	copy(sorted, utxos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if descending {
+			return sorted[i].Output.Value > sorted[j].Output.Value
+		}
+		return sorted[i].Output.Value < sorted[j].Output.Value
+	})
+	return sorted
+}
+
+// branchAndBound は合計が target 以上で最も小さくなる（おつりが最小になる）組み合わせを探します
+// 金額の大きい順に「使う・使わない」を深さ優先で試し、それまでの最良より合計が大きくなる枝と、
+// 残りをすべて使っても target に届かない枝を打ち切ります。おつりのない組み合わせが見つかれば終了します
+// 上限まで試しても見つからなければ金額の大きい順に選びます
+func branchAndBound(utxos []UTXO, target int) []UTXO {
+	sorted := sortedByValue(utxos, true)
+
+	// remaining[i] は i 番目以降の合計
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Output.Value
+	}
+
+	var best []int
+	bestTotal := remaining[0] + 1
+	tries := 0
+	chosen := make([]int, 0, len(sorted))
+
+	var search func(i, total int)
+	search = func(i, total int) {
+		if total >= bestTotal || tries >= branchAndBoundMaxTries {
+			return
+		}
+		if total >= target && (total > 0 || len(chosen) > 0) {
+			best = append(best[:0], chosen...)
+			bestTotal = total
+			return
+		}
+		if i == len(sorted) || total+remaining[i] < target {
+			return
+		}
+		tries++
+
+		chosen = append(chosen, i)
+		search(i+1, total+sorted[i].Output.Value)
+		chosen = chosen[:len(chosen)-1]
+		if bestTotal == target {
+			return
+		}
+		search(i+1, total)
+	}
+	search(0, 0)
+
+	if best == nil {
+		return accumulateCoins(sorted, target)
+	}
+	selected := make([]UTXO, len(best))
+	for i, index := range best {
+		selected[i] = sorted[index]
+	}
+	return selected
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testUTXOs は指定した金額のUTXOを作ります（TxIDは並び順）
+func testUTXOs(values ...int) []UTXO {
+	utxos := make([]UTXO, len(values))
+	for i, value := range values {
+		utxos[i] = UTXO{TxID: []byte{byte(i + 1)}, OutIndex: 0, Output: TxOutput{Value: value}}
+	}
+	return utxos
+}
+
+// selectedValues は選ばれたUTXOの金額を返します
+func selectedValues(selection *CoinSelection) []int {
+	values := make([]int, len(selection.UTXOs))
+	for i, utxo := range selection.UTXOs {
+		values[i] = utxo.Output.Value
+	}
+	return values
+}
+
+func TestSelectCoins(t *testing.T) {
+	utxos := testUTXOs(5, 30, 12, 8, 50)
+
+	t.Run("方式ごとに選ぶUTXOが変わる", func(t *testing.T) {
+		tests := []struct {
+			strategy CoinSelectionStrategy
+			values   []int
+			change   int
+		}{
+			{CoinSelectInOrder, []int{5, 30}, 15},
+			{CoinSelectLargestFirst, []int{50}, 30},
+			{CoinSelectSmallestFirst, []int{5, 8, 12}, 5},
+			{CoinSelectBranchAndBound, []int{12, 8}, 0},
+		}
+		for _, tt := range tests {
+			selection, err := SelectCoins(utxos, 20, tt.strategy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.strategy, selection.Strategy)
+			assert.Equal(t, tt.values, selectedValues(selection), tt.strategy)
+			assert.Equal(t, tt.change, selection.Change(), tt.strategy)
+		}
+	})
+
+	t.Run("分枝限定法はぴったりの組み合わせがなければおつりを最小にする", func(t *testing.T) {
+		selection, err := SelectCoins(testUTXOs(10, 7, 6), 12, CoinSelectBranchAndBound)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{7, 6}, selectedValues(selection))
+		assert.Equal(t, 1, selection.Change())
+	})
+
+	t.Run("残高が足りなければエラー", func(t *testing.T) {
+		for _, strategy := range CoinSelectionStrategies {
+			_, err := SelectCoins(utxos, 106, strategy)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "insufficient funds: have 105, need 106")
+		}
+	})
+
+	t.Run("すべての方式で比較できる", func(t *testing.T) {
+		selections, err := CompareCoinSelection(utxos, 20)
+		require.NoError(t, err)
+		require.Len(t, selections, len(CoinSelectionStrategies))
+		for i, selection := range selections {
+			assert.Equal(t, CoinSelectionStrategies[i], selection.Strategy)
+			assert.GreaterOrEqual(t, selection.Total, 20)
+		}
+	})
+}
+
+func TestParseCoinSelectionStrategy(t *testing.T) {
+	t.Run("名前から方式を得る", func(t *testing.T) {
+		for _, strategy := range CoinSelectionStrategies {
+			parsed, err := ParseCoinSelectionStrategy(string(strategy))
+			require.NoError(t, err)
+			assert.Equal(t, strategy, parsed)
+		}
+
+		parsed, err := ParseCoinSelectionStrategy("")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultCoinSelection, parsed)
+	})
+
+	t.Run("知らない名前はエラー", func(t *testing.T) {
+		_, err := ParseCoinSelectionStrategy("random")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "branch-and-bound")
+	})
+}
+
+func TestNewTransactionWithStrategy(t *testing.T) {
+	wallet, bc, _, mempool := newTestChain(t)
+
+	// 自分への送金で 51（報酬と手数料）・20・29 のUTXOにする
+	_, _, err := SendCoins(mempool, wallet, wallet.GetAddress(), 20, 1)
+	require.NoError(t, err)
+
+	t.Run("分枝限定法はおつりのないトランザクションを作る", func(t *testing.T) {
+		tx, selection, err := NewTransactionWithStrategy(wallet, testAddressA, 48, 1, CoinSelectBranchAndBound, mempool, bc)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{20, 29}, selectedValues(selection))
+		assert.Len(t, tx.Inputs, 2)
+		assert.Len(t, tx.Outputs, 1, "おつりの出力なし")
+		assert.NoError(t, mempool.Add(tx))
+	})
+
+	t.Run("メモリプールが使用中のUTXOは選ばない", func(t *testing.T) {
+		tx, selection, err := NewTransactionWithStrategy(wallet, testAddressA, 10, 1, CoinSelectLargestFirst, mempool, bc)
+		require.NoError(t, err)
+		assert.Equal(t, []int{51}, selectedValues(selection))
+		require.Len(t, tx.Outputs, 2)
+		assert.Equal(t, 40, tx.Outputs[1].Value)
+	})
+}
@@ -0,0 +1,389 @@
+// Package main implements a TUI dashboard for Stage 3.
+// This includes wallet balances, the mempool, latest blocks and UTXO statistics.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/nyasuto/minicoin/common"
+	"github.com/rivo/tview"
+)
+
+// ダッシュボードの各パネルに表示する件数
+const (
+	dashboardBlocks  = 5  // 最新ブロックの件数
+	dashboardMempool = 10 // メモリプールのトランザクションの件数
+)
+
+// Dashboard はトランザクションのステージのターミナルUIダッシュボード
+type Dashboard struct {
+	app     *tview.Application
+	pages   *tview.Pages
+	grid    *tview.Grid
+	mempool *Mempool
+	wallets *Wallets
+	wallet  *Wallet // マイニングの報酬の受取先と送金元
+
+	// パネル
+	walletsPanel *tview.TextView
+	mempoolPanel *tview.TextView
+	blocksPanel  *tview.TextView
+	utxoPanel    *tview.TextView
+	statusPanel  *tview.TextView
+	helpPanel    *tview.TextView
+
+	// 更新制御
+	updateInterval time.Duration
+	stopChan       chan bool
+
+	// マイニング中なら true（ホットキーで二重にマイニングしない）
+	mining atomic.Bool
+}
+
+// NewDashboard は新しいダッシュボードを作成します
+func NewDashboard(mempool *Mempool, wallets *Wallets, wallet *Wallet) *Dashboard {
+	d := &Dashboard{
+		app:            tview.NewApplication(),
+		pages:          tview.NewPages(),
+		mempool:        mempool,
+		wallets:        wallets,
+		wallet:         wallet,
+		updateInterval: 1 * time.Second,
+		stopChan:       make(chan bool),
+	}
+
+	// パネルの作成
+	d.walletsPanel = d.createPanel("Wallets")
+	d.mempoolPanel = d.createPanel("Mempool (by fee rate)")
+	d.blocksPanel = d.createPanel("Latest Blocks")
+	d.utxoPanel = d.createPanel("UTXO Set")
+	d.statusPanel = tview.NewTextView().SetDynamicColors(true)
+	d.helpPanel = d.createHelpPanel()
+
+	// グリッドレイアウトの作成（左右2列）
+	d.grid = tview.NewGrid().
+		SetRows(0, 0, 1, 1).
+		SetColumns(0, 0).
+		SetBorders(false)
+
+	// パネルの配置
+	d.grid.AddItem(d.walletsPanel, 0, 0, 1, 1, 0, 0, false)
+	d.grid.AddItem(d.utxoPanel, 0, 1, 1, 1, 0, 0, false)
+	d.grid.AddItem(d.mempoolPanel, 1, 0, 1, 1, 0, 0, false)
+	d.grid.AddItem(d.blocksPanel, 1, 1, 1, 1, 0, 0, false)
+	d.grid.AddItem(d.statusPanel, 2, 0, 1, 2, 0, 0, false)
+	d.grid.AddItem(d.helpPanel, 3, 0, 1, 2, 0, 0, false)
+
+	// キーボード入力処理
+	d.grid.SetInputCapture(d.handleKeyPress)
+
+	d.pages.AddPage("main", d.grid, true, true)
+	d.app.SetRoot(d.pages, true)
+
+	return d
+}
+
+// createPanel は基本的なパネルを作成します
+func (d *Dashboard) createPanel(title string) *tview.TextView {
+	panel := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(false)
+
+	panel.SetBorder(true).
+		SetTitle(fmt.Sprintf(" %s ", title)).
+		SetTitleAlign(tview.AlignLeft).
+		SetBorderPadding(0, 0, 1, 1)
+
+	return panel
+}
+
+// createHelpPanel はヘルプパネルを作成します
+func (d *Dashboard) createHelpPanel() *tview.TextView {
+	panel := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignCenter).
+		SetText("[yellow]Keys:[white] [green]q[white] Quit | [green]r[white] Refresh | [green]m[white] Mine Block | [green]s[white] Send Payment | [green]Ctrl+C[white] Exit")
+
+	panel.SetBorder(false)
+
+	return panel
+}
+
+// handleKeyPress はキーボード入力を処理します
+func (d *Dashboard) handleKeyPress(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Rune() {
+	case 'q', 'Q':
+		d.Stop()
+		return nil
+	case 'r', 'R':
+		d.update()
+		return nil
+	case 'm', 'M':
+		d.startMining()
+		return nil
+	case 's', 'S':
+		d.showSendForm()
+		return nil
+	}
+
+	// Ctrl+Cの処理
+	if event.Key() == tcell.KeyCtrlC {
+		d.Stop()
+		return nil
+	}
+
+	return event
+}
+
+// Run はダッシュボードを起動します
+func (d *Dashboard) Run() error {
+	// 初期更新
+	d.update()
+
+	// 自動更新ゴルーチンを開始
+	go d.autoUpdate()
+
+	// アプリケーションを実行
+	return d.app.Run()
+}
+
+// Stop はダッシュボードを停止します
+func (d *Dashboard) Stop() {
+	d.stopChan <- true
+	d.app.Stop()
+}
+
+// autoUpdate は定期的にダッシュボードを更新します
+func (d *Dashboard) autoUpdate() {
+	ticker := time.NewTicker(d.updateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.app.QueueUpdateDraw(func() {
+				d.update()
+			})
+		case <-d.stopChan:
+			return
+		}
+	}
+}
+
+// update は全パネルを更新します
+func (d *Dashboard) update() {
+	d.updateWalletsPanel()
+	d.updateMempoolPanel()
+	d.updateBlocksPanel()
+	d.updateUTXOPanel()
+}
+
+// setStatus はステータス行にメッセージを表示します
+func (d *Dashboard) setStatus(format string, args ...any) {
+	d.statusPanel.SetText(fmt.Sprintf(format, args...))
+}
+
+// updateWalletsPanel はウォレットの残高パネルを更新します
+func (d *Dashboard) updateWalletsPanel() {
+	var lines []string
+	for i, address := range d.wallets.GetAddresses() {
+		marker := " "
+		if address == d.wallet.GetAddress() {
+			marker = "[yellow]*[white]"
+		}
+		balance := d.wallets.Wallets[address].GetTotalBalance(d.mempool)
+		line := fmt.Sprintf("%s %2d. [green]%s[white]  [cyan]%d[white] coins", marker, i+1, address, balance.Total())
+		if balance.PendingIn > 0 || balance.PendingOut > 0 {
+			line += fmt.Sprintf(" [yellow](pending +%d/-%d)[white]", balance.PendingIn, balance.PendingOut)
+		}
+		if label := d.wallets.Label(address); label != "" {
+			line += fmt.Sprintf("  %s", label)
+		}
+		lines = append(lines, line)
+	}
+
+	total := d.wallets.GetTotalBalance(d.mempool)
+	lines = append(lines, "",
+		fmt.Sprintf("Total:     [cyan]%d[white] coins (* = active)", total.Total()),
+		fmt.Sprintf("Confirmed: [cyan]%d[white]  Immature: [yellow]%d[white]", total.Confirmed, total.Immature))
+
+	d.walletsPanel.SetText(strings.Join(lines, "\n"))
+}
+
+// updateMempoolPanel はメモリプールのパネルを手数料率の高い順に更新します
+func (d *Dashboard) updateMempoolPanel() {
+	entries := d.mempool.Entries()
+	sortByFeeRate(entries)
+
+	var lines []string
+	if len(entries) == 0 {
+		lines = append(lines, "[gray]No pending transactions.[white]")
+	}
+	for i, entry := range entries {
+		if i == dashboardMempool {
+			lines = append(lines, fmt.Sprintf("[gray]... and %d more[white]", len(entries)-dashboardMempool))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("[green]%s[white]  fee [cyan]%d[white]  %d bytes  [yellow]%.4f[white]/byte",
+			truncateHash(fmt.Sprintf("%x", entry.Tx.ID)), entry.Fee, entry.Size, entry.FeeRate()))
+	}
+
+	stats := d.mempool.Stats()
+	lines = append(lines, "", fmt.Sprintf("Pending: [cyan]%d[white] tx(s), [cyan]%d[white] coins in fees, %d bytes", stats.Count, stats.Fees, stats.Size))
+
+	d.mempoolPanel.SetText(strings.Join(lines, "\n"))
+}
+
+// sortByFeeRate はエントリーを手数料率の高い順に並べます（同じ手数料率なら受け付けた順）
+func sortByFeeRate(entries []MempoolEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		// 浮動小数点の誤差を避けるため、fee/size を掛け算で比較する
+		return entries[i].Fee*entries[j].Size > entries[j].Fee*entries[i].Size
+	})
+}
+
+// updateBlocksPanel は最新ブロックのパネルを更新します
+func (d *Dashboard) updateBlocksPanel() {
+	bc := d.mempool.blockchain
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	var lines []string
+	for i := len(bc.Blocks) - 1; i >= 0 && i >= len(bc.Blocks)-dashboardBlocks; i-- {
+		block := bc.Blocks[i]
+
+		timeStr := common.FormatTimestamp(block.Timestamp)
+		// 時刻部分のみ抽出
+		if len(timeStr) > 11 {
+			timeStr = timeStr[11:]
+		}
+
+		blockType := ""
+		if block.Index == 0 {
+			blockType = " [yellow](Genesis)[white]"
+		}
+
+		lines = append(lines, fmt.Sprintf("[cyan]#%-4d[white] [green]%s[white]  %d tx(s)  %d wu  [yellow]%s[white]%s",
+			block.Index, truncateHash(block.Hash), len(block.Transactions), block.Weight(), timeStr, blockType))
+	}
+
+	d.blocksPanel.SetText(strings.Join(lines, "\n"))
+}
+
+// updateUTXOPanel はUTXOセットの統計のパネルを更新します
+func (d *Dashboard) updateUTXOPanel() {
+	stats := d.mempool.utxoSet.Stats()
+	stats.Height = d.mempool.blockchain.GetLatestBlock().Index
+
+	content := fmt.Sprintf(
+		"[white]Height:     [cyan]%d[white]\n"+
+			"Supply:     [cyan]%d[white] coins\n"+
+			"UTXOs:      [cyan]%d[white] (%d address(es))\n"+
+			"Mean:       [yellow]%.2f[white]  Median: [yellow]%.1f[white]\n"+
+			"Min / Max:  [yellow]%d[white] / [yellow]%d[white]",
+		stats.Height,
+		stats.Supply,
+		stats.Count, stats.Addresses,
+		stats.Mean, stats.Median,
+		stats.Min, stats.Max,
+	)
+
+	d.utxoPanel.SetText(content)
+}
+
+// startMining はバックグラウンドでメモリプールのトランザクションを1ブロックにマイニングします
+func (d *Dashboard) startMining() {
+	if !d.mining.CompareAndSwap(false, true) {
+		d.setStatus("[yellow]⛏️  Already mining...[white]")
+		return
+	}
+	d.setStatus("[yellow]⛏️  Mining a block with %d pending transaction(s)...[white]", d.mempool.Size())
+
+	go func() {
+		defer d.mining.Store(false)
+		block, err := d.mineBlock()
+		d.app.QueueUpdateDraw(func() {
+			if err != nil {
+				d.setStatus("[red]❌ Mining failed: %v[white]", err)
+			} else {
+				d.setStatus("[green]✅ Mined block #%d (%s) with %d transaction(s)[white]", block.Index, truncateHash(block.Hash), len(block.Transactions))
+			}
+			d.update()
+		})
+	}()
+}
+
+// mineBlock はメモリプールのトランザクションをマイニングし、報酬をアクティブなウォレットに支払います
+func (d *Dashboard) mineBlock() (*Block, error) {
+	block, _, err := d.mempool.MineBlock(d.wallet.GetAddress())
+	return block, err
+}
+
+// showSendForm は送金先・送金額・手数料を入力するフォームを表示します
+func (d *Dashboard) showSendForm() {
+	form := tview.NewForm()
+	form.AddInputField("To", "", 40, nil, nil).
+		AddInputField("Amount", "", 10, tview.InputFieldInteger, nil).
+		AddInputField("Fee", strconv.Itoa(DefaultTransactionFee), 10, tview.InputFieldInteger, nil)
+
+	closeForm := func() {
+		d.pages.RemovePage("send")
+		d.app.SetFocus(d.grid)
+	}
+	form.AddButton("Send", func() {
+		to := form.GetFormItemByLabel("To").(*tview.InputField).GetText()
+		amount := form.GetFormItemByLabel("Amount").(*tview.InputField).GetText()
+		fee := form.GetFormItemByLabel("Fee").(*tview.InputField).GetText()
+		tx, err := d.sendPayment(to, amount, fee)
+		if err != nil {
+			d.setStatus("[red]❌ Send failed: %v[white]", err)
+		} else {
+			d.setStatus("[green]✅ Added %s to the mempool (mine with m)[white]", truncateHash(fmt.Sprintf("%x", tx.ID)))
+		}
+		closeForm()
+		d.update()
+	})
+	form.AddButton("Cancel", closeForm)
+	form.SetCancelFunc(closeForm)
+	form.SetBorder(true).SetTitle(" Send Payment ").SetTitleAlign(tview.AlignLeft)
+
+	// 画面の中央に表示する
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(form, 11, 0, true).
+			AddItem(nil, 0, 1, false), 60, 0, true).
+		AddItem(nil, 0, 1, false)
+	d.pages.AddPage("send", modal, true, true)
+	d.app.SetFocus(form)
+}
+
+// sendPayment はアクティブなウォレットから送金するトランザクションを作ってメモリプールに追加します
+// 送金先はアドレスのほか、ローカルのウォレットの番号やアドレス帳のラベルでも指定できます
+func (d *Dashboard) sendPayment(to, amount, fee string) (*Transaction, error) {
+	address, err := d.wallets.ResolveRecipient(to)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.ValidateAddress(address); err != nil {
+		return nil, err
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(amount))
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	feeValue := DefaultTransactionFee
+	if fee = strings.TrimSpace(fee); fee != "" {
+		if feeValue, err = strconv.Atoi(fee); err != nil {
+			return nil, fmt.Errorf("invalid fee %q", fee)
+		}
+	}
+	return SubmitTransaction(d.mempool, d.wallet, address, value, feeValue)
+}
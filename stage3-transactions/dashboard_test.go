@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDashboard(t *testing.T) {
+	t.Run("ダッシュボードの生成", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		dashboard := NewDashboard(mempool, wallets, wallet)
+
+		require.NotNil(t, dashboard)
+		assert.NotNil(t, dashboard.app)
+		assert.NotNil(t, dashboard.pages)
+		assert.NotNil(t, dashboard.grid)
+		assert.NotNil(t, dashboard.walletsPanel)
+		assert.NotNil(t, dashboard.mempoolPanel)
+		assert.NotNil(t, dashboard.blocksPanel)
+		assert.NotNil(t, dashboard.utxoPanel)
+		assert.NotNil(t, dashboard.helpPanel)
+		assert.NotNil(t, dashboard.stopChan)
+		assert.Equal(t, wallet, dashboard.wallet)
+	})
+}
+
+func TestDashboardPanels(t *testing.T) {
+	t.Run("ウォレットの残高とUTXOセットの統計を表示する", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		dashboard := NewDashboard(mempool, wallets, wallet)
+		dashboard.update()
+
+		walletsText := dashboard.walletsPanel.GetText(true)
+		assert.Contains(t, walletsText, wallet.GetAddress())
+		assert.Contains(t, walletsText, "Total:     50 coins")
+
+		utxoText := dashboard.utxoPanel.GetText(true)
+		assert.Contains(t, utxoText, "Height:     0")
+		assert.Contains(t, utxoText, "Supply:     50 coins")
+		assert.Contains(t, utxoText, "UTXOs:      1 (1 address(es))")
+	})
+
+	t.Run("メモリプールを手数料率の高い順に表示する", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		dashboard := NewDashboard(mempool, wallets, wallet)
+		fundWallet(t, dashboard.mempool.blockchain, dashboard.mempool.utxoSet, wallet, 2)
+
+		low, err := SubmitTransaction(dashboard.mempool, wallet, testAddressA, 10, 1)
+		require.NoError(t, err)
+		high, err := SubmitTransaction(dashboard.mempool, wallet, testAddressB, 10, 5)
+		require.NoError(t, err)
+
+		dashboard.updateMempoolPanel()
+		text := dashboard.mempoolPanel.GetText(true)
+		highAt := strings.Index(text, truncateHash(fmt.Sprintf("%x", high.ID)))
+		lowAt := strings.Index(text, truncateHash(fmt.Sprintf("%x", low.ID)))
+		require.GreaterOrEqual(t, highAt, 0)
+		require.GreaterOrEqual(t, lowAt, 0)
+		assert.Less(t, highAt, lowAt)
+		assert.Contains(t, text, "Pending: 2 tx(s), 6 coins in fees")
+	})
+
+	t.Run("最新ブロックとトランザクションの数を表示する", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		dashboard := NewDashboard(mempool, wallets, wallet)
+		dashboard.updateBlocksPanel()
+
+		text := dashboard.blocksPanel.GetText(true)
+		assert.Contains(t, text, "#0")
+		assert.Contains(t, text, "1 tx(s)")
+		assert.Contains(t, text, "(Genesis)")
+	})
+}
+
+func TestDashboardHotkeys(t *testing.T) {
+	t.Run("送金をメモリプールに追加してマイニングする", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		dashboard := NewDashboard(mempool, wallets, wallet)
+
+		tx, err := dashboard.sendPayment(testAddressA, "20", "")
+		require.NoError(t, err)
+		assert.True(t, dashboard.mempool.Contains(tx.ID))
+
+		block, err := dashboard.mineBlock()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), block.Index)
+		assert.Len(t, block.Transactions, 2)
+		assert.Equal(t, 0, dashboard.mempool.Size())
+		assert.Equal(t, 20, dashboard.mempool.utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, block.Transactions[0].Outputs[0].Address(), wallet.GetAddress())
+	})
+
+	t.Run("ローカルのウォレットの番号で送金先を指定できる", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		dashboard := NewDashboard(mempool, wallets, wallet)
+		other, err := NewWallet()
+		require.NoError(t, err)
+		dashboard.wallets.AddWallet(other)
+
+		number := 1
+		if dashboard.wallets.GetAddresses()[0] == wallet.GetAddress() {
+			number = 2
+		}
+		tx, err := dashboard.sendPayment(fmt.Sprint(number), "5", "1")
+		require.NoError(t, err)
+		assert.Equal(t, other.GetAddress(), tx.Outputs[0].Address())
+	})
+
+	t.Run("不正な入力を拒否する", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		dashboard := NewDashboard(mempool, wallets, wallet)
+
+		_, err := dashboard.sendPayment("not-an-address", "5", "")
+		assert.Error(t, err)
+		_, err = dashboard.sendPayment(testAddressA, "five", "")
+		assert.ErrorContains(t, err, "invalid amount")
+		_, err = dashboard.sendPayment(testAddressA, "5", "x")
+		assert.ErrorContains(t, err, "invalid fee")
+		assert.Equal(t, 0, dashboard.mempool.Size())
+	})
+}
+
+func TestSortByFeeRate(t *testing.T) {
+	t.Run("手数料率の高い順、同じなら受け付けた順", func(t *testing.T) {
+		entries := []MempoolEntry{
+			{Fee: 1, Size: 100},
+			{Fee: 4, Size: 100},
+			{Fee: 2, Size: 200},
+			{Fee: 2, Size: 100},
+		}
+		sortByFeeRate(entries)
+
+		assert.Equal(t, []MempoolEntry{
+			{Fee: 4, Size: 100},
+			{Fee: 2, Size: 100},
+			{Fee: 1, Size: 100},
+			{Fee: 2, Size: 200},
+		}, entries)
+	})
+}
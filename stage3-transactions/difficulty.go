@@ -0,0 +1,131 @@
+// Package main implements difficulty adjustment for Stage 3.
+package main
+
+import (
+	"math"
+)
+
+// 難易度調整のパラメータ（ステージ2と同じ値）
+const (
+	// TargetBlockTime は目標ブロック生成時間（秒）
+	TargetBlockTime = 10
+
+	// AdjustmentInterval は難易度調整を行うブロック間隔
+	AdjustmentInterval = 10
+
+	// MaxAdjustmentFactor は最大調整倍率（急激な変化を防ぐ）
+	MaxAdjustmentFactor = 2.0
+
+	// MinDifficulty は最小難易度
+	MinDifficulty = 0
+
+	// MaxDifficulty は最大難易度
+	MaxDifficulty = 10
+)
+
+// AdjustDifficulty は実際の平均時間と目標時間を比較して新しい難易度を返します
+func AdjustDifficulty(currentDifficulty int, actualTime, targetTime float64) int {
+	if actualTime == 0.0 || targetTime == 0.0 {
+		return currentDifficulty
+	}
+
+	// 調整比率を計算し、急激な変化を防ぐ
+	ratio := actualTime / targetTime
+	if ratio > MaxAdjustmentFactor {
+		ratio = MaxAdjustmentFactor
+	} else if ratio < 1.0/MaxAdjustmentFactor {
+		ratio = 1.0 / MaxAdjustmentFactor
+	}
+
+	// 実際の時間が目標より長い → 難易度を下げる、短い → 難易度を上げる
+	var newDifficulty int
+	if ratio > 1.0 {
+		newDifficulty = currentDifficulty - int(math.Ceil(math.Log2(ratio)))
+	} else {
+		newDifficulty = currentDifficulty + int(math.Ceil(math.Log2(1.0/ratio)))
+	}
+
+	// 難易度の範囲を制限
+	if newDifficulty < MinDifficulty {
+		newDifficulty = MinDifficulty
+	} else if newDifficulty > MaxDifficulty {
+		newDifficulty = MaxDifficulty
+	}
+	return newDifficulty
+}
+
+// averageBlockTime は blocks の直近 lastNBlocks 個のブロックの平均生成時間を返します（秒）
+func averageBlockTime(blocks []*Block, lastNBlocks int) float64 {
+	blocksToCheck := min(lastNBlocks, len(blocks)-1)
+	if blocksToCheck <= 0 {
+		return 0.0
+	}
+
+	latest := blocks[len(blocks)-1]
+	first := blocks[len(blocks)-1-blocksToCheck]
+	return float64(latest.Timestamp-first.Timestamp) / float64(blocksToCheck)
+}
+
+// expectedDifficulty は blocks に続く次のブロックに求める難易度を返します
+// AdjustmentInterval ブロックごとに直近の平均生成時間で調整し、それ以外は最新ブロックの難易度を引き継ぎます
+// ブロックの内容だけから決まるため、保存したチェーンを開き直しても、検証するときも同じ値になります
+func expectedDifficulty(blocks []*Block, targetBlockTime int) int {
+	latest := blocks[len(blocks)-1]
+	height := len(blocks) // 次のブロックの高さ
+	if height < AdjustmentInterval || height%AdjustmentInterval != 0 {
+		return latest.Difficulty
+	}
+	return AdjustDifficulty(latest.Difficulty, averageBlockTime(blocks, AdjustmentInterval), float64(targetBlockTime))
+}
+
+// SetTargetBlockTime は目標ブロック生成時間（秒）を設定し、難易度の自動調整を有効にします（0なら固定の難易度に戻します）
+// Difficulty はチェーンのブロックから求めた次のブロックの難易度になります
+func (bc *Blockchain) SetTargetBlockTime(seconds int) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	bc.TargetBlockTime = max(seconds, 0)
+	bc.Difficulty = bc.nextDifficultyLocked()
+}
+
+// NextDifficulty は次にマイニングするブロックの難易度を返します
+func (bc *Blockchain) NextDifficulty() int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	return bc.nextDifficultyLocked()
+}
+
+// nextDifficultyLocked は次のブロックの難易度を返します（呼び出し側でロックを取得していることを前提とします）
+func (bc *Blockchain) nextDifficultyLocked() int {
+	if bc.TargetBlockTime <= 0 {
+		return bc.Difficulty
+	}
+	return expectedDifficulty(bc.Blocks, bc.TargetBlockTime)
+}
+
+// DifficultyStats は難易度に関する統計情報です
+type DifficultyStats struct {
+	CurrentDifficulty int     // 次のブロックの難易度
+	AverageBlockTime  float64 // 直近 AdjustmentInterval ブロックの平均生成時間（秒）
+	TargetBlockTime   int     // 目標ブロック生成時間（秒。0なら調整しない）
+	NextAdjustment    int     // 次の調整までのブロック数（調整しないなら0）
+	AdjustmentEvery   int     // 調整間隔（ブロック数）
+}
+
+// GetDifficultyStats は難易度統計を取得します
+func (bc *Blockchain) GetDifficultyStats() *DifficultyStats {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	stats := &DifficultyStats{
+		CurrentDifficulty: bc.nextDifficultyLocked(),
+		AverageBlockTime:  averageBlockTime(bc.Blocks, AdjustmentInterval),
+		TargetBlockTime:   bc.TargetBlockTime,
+		AdjustmentEvery:   AdjustmentInterval,
+	}
+	if bc.TargetBlockTime > 0 {
+		stats.NextAdjustment = AdjustmentInterval - len(bc.Blocks)%AdjustmentInterval
+	}
+	return stats
+}
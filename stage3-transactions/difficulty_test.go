@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendBlocksEvery は前のブロックから interval 秒ずつ空けたブロックを、チェーンが求める難易度で n 個追加します
+func appendBlocksEvery(t *testing.T, bc *Blockchain, n int, interval int64) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		last := bc.GetLatestBlock()
+		height := last.Index + 1
+		coinbase := NewCoinbaseTxAtHeight(testAddressA, "", bc.Emission.RewardAt(height), height)
+		block := NewBlock(height, []*Transaction{coinbase}, last.Hash, bc.NextDifficulty())
+		block.Timestamp = last.Timestamp + interval
+		_, err := MineBlock(block)
+		require.NoError(t, err)
+		bc.Blocks = append(bc.Blocks, block)
+	}
+}
+
+func TestAdjustDifficulty(t *testing.T) {
+	t.Run("目標より速ければ上げ、遅ければ下げる", func(t *testing.T) {
+		assert.Equal(t, 3, AdjustDifficulty(2, 5, 10))
+		assert.Equal(t, 1, AdjustDifficulty(2, 20, 10))
+		assert.Equal(t, 2, AdjustDifficulty(2, 10, 10))
+	})
+
+	t.Run("一度の調整は MaxAdjustmentFactor 倍までに抑える", func(t *testing.T) {
+		assert.Equal(t, 3, AdjustDifficulty(2, 0.1, 10))
+		assert.Equal(t, 1, AdjustDifficulty(2, 1000, 10))
+	})
+
+	t.Run("難易度の範囲と計算できない時間", func(t *testing.T) {
+		assert.Equal(t, MinDifficulty, AdjustDifficulty(MinDifficulty, 1000, 10))
+		assert.Equal(t, MaxDifficulty, AdjustDifficulty(MaxDifficulty, 1, 10))
+		assert.Equal(t, 4, AdjustDifficulty(4, 0, 10))
+	})
+}
+
+func TestBlockchainRetarget(t *testing.T) {
+	// 各サブテストは難易度1で始まり、難易度を自動調整するチェーンを使う
+	// ジェネシスブロックは1時間前の時刻でマイニングし直し、後から一定の間隔のブロックを追加できるようにする
+	t.Run("調整の間隔に達するまでは難易度を引き継ぐ", func(t *testing.T) {
+		bc := NewBlockchain(1, testAddressA)
+		bc.Blocks[0].Timestamp -= 3600
+		bc.Blocks[0].Nonce = 0
+		_, err := MineBlock(bc.Blocks[0])
+		require.NoError(t, err)
+		bc.SetTargetBlockTime(TargetBlockTime)
+		appendBlocksEvery(t, bc, AdjustmentInterval-2, 1)
+
+		assert.Equal(t, 1, bc.NextDifficulty())
+		assert.Equal(t, 1, bc.GetDifficultyStats().NextAdjustment)
+	})
+
+	t.Run("ブロックが速すぎれば次の間隔で難易度を上げてマイニングする", func(t *testing.T) {
+		bc := NewBlockchain(1, testAddressA)
+		bc.Blocks[0].Timestamp -= 3600
+		bc.Blocks[0].Nonce = 0
+		_, err := MineBlock(bc.Blocks[0])
+		require.NoError(t, err)
+		bc.SetTargetBlockTime(TargetBlockTime)
+		appendBlocksEvery(t, bc, AdjustmentInterval-1, 2)
+		assert.Equal(t, 2, bc.NextDifficulty())
+
+		stats := bc.GetDifficultyStats()
+		assert.Equal(t, 2, stats.CurrentDifficulty)
+		assert.InDelta(t, 2.0, stats.AverageBlockTime, 0.001)
+		assert.Equal(t, TargetBlockTime, stats.TargetBlockTime)
+		assert.Equal(t, AdjustmentInterval, stats.NextAdjustment)
+
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTxAtHeight(testAddressA, "", bc.Emission.RewardAt(10), 10)})
+		require.NoError(t, err)
+		assert.Equal(t, 2, block.Difficulty)
+		assert.Equal(t, 2, bc.Difficulty)
+	})
+
+	t.Run("ブロックが遅すぎれば難易度を下げる", func(t *testing.T) {
+		bc := NewBlockchain(1, testAddressA)
+		bc.Blocks[0].Timestamp -= 3600
+		bc.Blocks[0].Nonce = 0
+		_, err := MineBlock(bc.Blocks[0])
+		require.NoError(t, err)
+		bc.SetTargetBlockTime(TargetBlockTime)
+		appendBlocksEvery(t, bc, AdjustmentInterval-1, 30)
+		assert.Equal(t, 0, bc.NextDifficulty())
+
+		appendBlocksEvery(t, bc, AdjustmentInterval, 30)
+		assert.Equal(t, MinDifficulty, bc.NextDifficulty())
+		assert.NoError(t, bc.Validate())
+	})
+
+	t.Run("調整後の難易度でないブロックを拒否する", func(t *testing.T) {
+		bc := NewBlockchain(1, testAddressA)
+		bc.Blocks[0].Timestamp -= 3600
+		bc.Blocks[0].Nonce = 0
+		_, err := MineBlock(bc.Blocks[0])
+		require.NoError(t, err)
+		bc.SetTargetBlockTime(TargetBlockTime)
+		appendBlocksEvery(t, bc, AdjustmentInterval-1, 2)
+		require.NoError(t, bc.Validate())
+
+		// 調整前の難易度のままマイニングしたブロック
+		last := bc.GetLatestBlock()
+		block := NewBlock(last.Index+1, []*Transaction{NewCoinbaseTxAtHeight(testAddressA, "", bc.Emission.RewardAt(10), 10)}, last.Hash, 1)
+		block.Timestamp = last.Timestamp + 2
+		_, err = MineBlock(block)
+		require.NoError(t, err)
+		bc.Blocks = append(bc.Blocks, block)
+
+		assert.ErrorContains(t, bc.Validate(), "block 10: difficulty 1 does not match the adjusted difficulty 2")
+	})
+
+	t.Run("目標ブロック生成時間が0なら難易度を固定する", func(t *testing.T) {
+		bc := NewBlockchain(1, testAddressA)
+		bc.Blocks[0].Timestamp -= 3600
+		bc.Blocks[0].Nonce = 0
+		_, err := MineBlock(bc.Blocks[0])
+		require.NoError(t, err)
+		bc.SetTargetBlockTime(TargetBlockTime)
+		appendBlocksEvery(t, bc, AdjustmentInterval-1, 2)
+		bc.SetTargetBlockTime(0)
+
+		assert.Equal(t, 1, bc.NextDifficulty())
+		assert.Equal(t, 0, bc.GetDifficultyStats().NextAdjustment)
+
+		// 有効に戻すとチェーンのブロックから難易度を求め直す
+		bc.SetTargetBlockTime(TargetBlockTime)
+		assert.Equal(t, 2, bc.Difficulty)
+	})
+}
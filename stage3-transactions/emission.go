@@ -0,0 +1,88 @@
+// Package main implements the block reward emission schedule for Stage 3.
+package main
+
+// 発行スケジュールのデフォルト
+const (
+	InitialBlockReward     = 50 // 最初のブロック報酬
+	DefaultHalvingInterval = 20 // 報酬が半減するブロック間隔（教育用に短く設定）
+	maxHalvings            = 63 // これ以上シフトすると報酬は必ず0
+)
+
+// EmissionSchedule はブロック報酬の発行スケジュールです
+// 報酬はHalvingIntervalブロックごとに半分になり、いずれ0になるため総発行量には上限があります
+type EmissionSchedule struct {
+	InitialReward   int   // 高さ0のブロック報酬
+	HalvingInterval int64 // 報酬が半減するブロック間隔
+	Premine         int   // ジェネシスブロックで報酬に加えて配分する額
+}
+
+// DefaultEmissionSchedule はデフォルトの発行スケジュールを返します
+func DefaultEmissionSchedule() EmissionSchedule {
+	return EmissionSchedule{
+		InitialReward:   InitialBlockReward,
+		HalvingInterval: DefaultHalvingInterval,
+	}
+}
+
+// RewardAt は指定した高さのブロック報酬を返します（高さ0はプレマインを含みます）
+func (s EmissionSchedule) RewardAt(height int64) int {
+	if height == 0 && s.HalvingInterval > 0 {
+		return s.subsidyAt(height) + s.Premine
+	}
+	return s.subsidyAt(height)
+}
+
+// subsidyAt は指定した高さの半減期による報酬を返します（プレマインを含みません）
+func (s EmissionSchedule) subsidyAt(height int64) int {
+	if height < 0 || s.HalvingInterval <= 0 {
+		return 0
+	}
+
+	halvings := height / s.HalvingInterval
+	if halvings > maxHalvings {
+		return 0
+	}
+	return s.InitialReward >> halvings
+}
+
+// TotalEmitted は高さ0からheightまでのブロック報酬（プレマインを含む）の合計を返します
+func (s EmissionSchedule) TotalEmitted(height int64) int {
+	if height < 0 || s.HalvingInterval <= 0 {
+		return 0
+	}
+
+	total := s.Premine
+	for start := int64(0); start <= height; start += s.HalvingInterval {
+		reward := s.subsidyAt(start)
+		if reward == 0 {
+			break
+		}
+		end := start + s.HalvingInterval - 1
+		if end > height {
+			end = height
+		}
+		total += reward * int(end-start+1)
+	}
+	return total
+}
+
+// MaxSupply は報酬が0になるまでに発行される総量（プレマインを含む）を返します
+func (s EmissionSchedule) MaxSupply() int {
+	if s.HalvingInterval <= 0 {
+		return 0
+	}
+
+	total := s.Premine
+	for reward := s.InitialReward; reward > 0; reward >>= 1 {
+		total += reward * int(s.HalvingInterval)
+	}
+	return total
+}
+
+// NextHalving は高さheightの次に報酬が半減するブロックの高さを返します
+func (s EmissionSchedule) NextHalving(height int64) int64 {
+	if height < 0 || s.HalvingInterval <= 0 {
+		return 0
+	}
+	return (height/s.HalvingInterval + 1) * s.HalvingInterval
+}
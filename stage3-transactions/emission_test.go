@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmissionSchedule(t *testing.T) {
+	schedule := EmissionSchedule{InitialReward: 50, HalvingInterval: 10}
+
+	t.Run("間隔ごとに報酬が半減する", func(t *testing.T) {
+		assert.Equal(t, 50, schedule.RewardAt(0))
+		assert.Equal(t, 50, schedule.RewardAt(9))
+		assert.Equal(t, 25, schedule.RewardAt(10))
+		assert.Equal(t, 12, schedule.RewardAt(20))
+		assert.Equal(t, 1, schedule.RewardAt(50))
+		assert.Equal(t, 0, schedule.RewardAt(60))
+		assert.Equal(t, 0, schedule.RewardAt(1_000_000))
+	})
+
+	t.Run("総発行量は報酬の合計", func(t *testing.T) {
+		assert.Equal(t, 50, schedule.TotalEmitted(0))
+		assert.Equal(t, 500, schedule.TotalEmitted(9))
+		assert.Equal(t, 525, schedule.TotalEmitted(10))
+		assert.Equal(t, 0, schedule.TotalEmitted(-1))
+	})
+
+	t.Run("最大供給量に収束する", func(t *testing.T) {
+		// 10 * (50 + 25 + 12 + 6 + 3 + 1)
+		assert.Equal(t, 970, schedule.MaxSupply())
+		assert.Equal(t, schedule.MaxSupply(), schedule.TotalEmitted(1_000_000))
+	})
+
+	t.Run("プレマインは高さ0の報酬と総発行量に含める", func(t *testing.T) {
+		premined := schedule
+		premined.Premine = 1000
+		assert.Equal(t, 1050, premined.RewardAt(0))
+		assert.Equal(t, 50, premined.RewardAt(1))
+		assert.Equal(t, 1500, premined.TotalEmitted(9))
+		assert.Equal(t, 1970, premined.MaxSupply())
+		assert.Equal(t, premined.MaxSupply(), premined.TotalEmitted(1_000_000))
+	})
+
+	t.Run("次の半減期", func(t *testing.T) {
+		assert.Equal(t, int64(10), schedule.NextHalving(0))
+		assert.Equal(t, int64(10), schedule.NextHalving(9))
+		assert.Equal(t, int64(20), schedule.NextHalving(10))
+	})
+
+	t.Run("不正な間隔では報酬なし", func(t *testing.T) {
+		invalid := EmissionSchedule{InitialReward: 50}
+		assert.Equal(t, 0, invalid.RewardAt(0))
+		assert.Equal(t, 0, invalid.MaxSupply())
+		assert.Equal(t, int64(0), invalid.NextHalving(0))
+	})
+}
+
+func TestEmissionValidation(t *testing.T) {
+	t.Run("半減後のブロックは減った報酬を受け取る", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, wallet.GetAddress())
+		bc.Emission.HalvingInterval = 2
+		mempool := NewMempool(bc, NewUTXOSet(bc))
+
+		var rewards []int
+		for i := 0; i < 4; i++ {
+			block, _, err := mempool.MineBlock(wallet.GetAddress())
+			require.NoError(t, err)
+			rewards = append(rewards, block.Transactions[0].Outputs[0].Value)
+		}
+
+		assert.Equal(t, []int{50, 25, 25, 12}, rewards)
+		assert.True(t, bc.IsValid())
+		assert.Equal(t, bc.Emission.TotalEmitted(4), mempool.utxoSet.GetBalance(wallet.GetAddress()))
+	})
+
+	t.Run("報酬を超えるコインベースはチェーンを無効にする", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, wallet.GetAddress())
+		greedy := []*Transaction{NewCoinbaseTxWithReward(wallet.GetAddress(), "greedy", InitialBlockReward+1)}
+		_, _, err = bc.MineBlock(greedy)
+		assert.Error(t, err)
+
+		appendUnchecked(t, bc, greedy)
+		assert.False(t, bc.IsValid())
+	})
+
+	t.Run("手数料の分だけ報酬を上乗せできる", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, wallet.GetAddress())
+		tx, err := NewTransaction(wallet, testAddressA, 10, 5, NewUTXOSet(bc), bc)
+		require.NoError(t, err)
+
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTxWithReward(wallet.GetAddress(), "fees", InitialBlockReward+5), tx})
+		require.NoError(t, err)
+		assert.True(t, bc.IsValid())
+
+		// 手数料のないブロックでは上乗せできない
+		noFees := []*Transaction{NewCoinbaseTxWithReward(wallet.GetAddress(), "block 2", InitialBlockReward+5)}
+		_, _, err = bc.MineBlock(noFees)
+		assert.Error(t, err)
+
+		appendUnchecked(t, bc, noFees)
+		assert.False(t, bc.IsValid())
+	})
+}
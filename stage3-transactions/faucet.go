@@ -0,0 +1,405 @@
+// Package main implements a faucet that hands out test coins for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// フォーセットの既定値
+const (
+	DefaultFaucetAmount       = 10               // 1回に払い出す額
+	DefaultFaucetInterval     = time.Hour        // 同じアドレスに再び払い出すまでの間隔
+	DefaultFaucetMineInterval = 10 * time.Second // サーバーが払い出しをブロックに取り込む間隔
+)
+
+// FaucetRateLimitError はアドレスが払い出しの間隔を空けずに再び要求したことを表します
+type FaucetRateLimitError struct {
+	Address    string
+	RetryAfter time.Duration
+}
+
+// Error はerrorインターフェースを実装します
+func (e *FaucetRateLimitError) Error() string {
+	return fmt.Sprintf("%s already received coins, try again in %s", e.Address, e.RetryAfter.Round(time.Second))
+}
+
+// Faucet は指定したウォレット（フォーセットのウォレット）から、要求されたアドレスに少額のコインを払い出します
+// 教室などで複数人が使うテストネット向けで、同じアドレスへの払い出しは Interval に1回までです
+type Faucet struct {
+	Amount   int           // 1回に払い出す額
+	Fee      int           // 払い出しの手数料
+	Interval time.Duration // 同じアドレスに再び払い出すまでの間隔
+
+	mempool *Mempool
+	wallet  *Wallet
+	last    map[string]time.Time // utxoKey(アドレス) -> 最後に払い出した時刻
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+// NewFaucet はフォーセットを作成します
+// 最後に払い出した時刻はチェーンにあるフォーセットのウォレットからの送金から求めるため、起動し直しても制限は続きます
+func NewFaucet(mempool *Mempool, wallet *Wallet, amount, fee int, interval time.Duration) *Faucet {
+	f := &Faucet{
+		Amount:   amount,
+		Fee:      fee,
+		Interval: interval,
+		mempool:  mempool,
+		wallet:   wallet,
+		last:     make(map[string]time.Time),
+		now:      time.Now,
+	}
+	f.loadPayouts(mempool.blockchain)
+	return f
+}
+
+// loadPayouts はチェーンをたどり、フォーセットのウォレットが払い出したアドレスごとに最後の時刻を記録します
+func (f *Faucet) loadPayouts(blockchain *Blockchain) {
+	faucet := utxoKey(f.wallet.GetAddress())
+	outputs := make(map[string]TxOutput) // outpointKey -> 出力
+	for _, tx := range blockchain.GetAllTransactions() {
+		fromFaucet := false
+		if !tx.IsCoinbase() {
+			for _, input := range tx.Inputs {
+				if output, ok := outputs[outpointKey(input.TxID, input.OutIndex)]; ok && output.Address() == faucet {
+					fromFaucet = true
+				}
+			}
+		}
+		for index, output := range tx.Outputs {
+			outputs[outpointKey(tx.ID, index)] = output
+			address := output.Address()
+			if !fromFaucet || address == faucet || address == "" {
+				continue
+			}
+			if paid := time.Unix(tx.Timestamp, 0); paid.After(f.last[address]) {
+				f.last[address] = paid
+			}
+		}
+	}
+}
+
+// Address はフォーセットのウォレットのアドレスを返します
+func (f *Faucet) Address() string {
+	return f.wallet.GetAddress()
+}
+
+// Balance はフォーセットのウォレットの残高を返します
+func (f *Faucet) Balance() int {
+	return f.mempool.utxoSet.GetBalance(f.wallet.GetAddress())
+}
+
+// Request は address に Amount のコインを払い出す送金を作り、メモリプールに追加します
+// 前回の払い出しから Interval が経っていなければ FaucetRateLimitError を返します
+func (f *Faucet) Request(address string) (*Transaction, error) {
+	if err := common.ValidateAddress(address); err != nil {
+		return nil, err
+	}
+	key := utxoKey(address)
+	if key == utxoKey(f.wallet.GetAddress()) {
+		return nil, fmt.Errorf("the faucet cannot pay itself")
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	if last, ok := f.last[key]; ok {
+		if wait := f.Interval - now.Sub(last); wait > 0 {
+			return nil, &FaucetRateLimitError{Address: address, RetryAfter: wait}
+		}
+	}
+
+	tx, err := SubmitTransaction(f.mempool, f.wallet, address, f.Amount, f.Fee)
+	if err != nil {
+		return nil, fmt.Errorf("faucet payment failed: %w", err)
+	}
+	f.last[key] = now
+	return tx, nil
+}
+
+// FaucetRequest は払い出しを要求するHTTPリクエストの本文です
+type FaucetRequest struct {
+	Address string `json:"address"`
+}
+
+// FaucetResponse は払い出しの結果です（失敗したときは Error だけが入ります）
+type FaucetResponse struct {
+	TxID    string `json:"txid,omitempty"`
+	Address string `json:"address,omitempty"`
+	Amount  int    `json:"amount,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// FaucetInfo はフォーセットの設定と残高です
+type FaucetInfo struct {
+	Address         string `json:"address"`
+	Amount          int    `json:"amount"`
+	Fee             int    `json:"fee"`
+	IntervalSeconds int64  `json:"interval_seconds"`
+	Balance         int    `json:"balance"`
+}
+
+// ServeHTTP は GET でフォーセットの情報を返し、POST {"address": "..."} で払い出します
+// 不正なアドレスは 400、間隔を空けない要求は 429（Retry-After 付き）、払い出せないときは 503 を返します
+func (f *Faucet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, FaucetInfo{
+			Address:         f.Address(),
+			Amount:          f.Amount,
+			Fee:             f.Fee,
+			IntervalSeconds: int64(f.Interval / time.Second),
+			Balance:         f.Balance(),
+		})
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "faucet accepts GET and POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request FaucetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, FaucetResponse{Error: "request body must be {\"address\": \"...\"}"})
+		return
+	}
+
+	tx, err := f.Request(request.Address)
+	var rateLimited *FaucetRateLimitError
+	var addressErr *common.AddressError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, FaucetResponse{TxID: hex.EncodeToString(tx.ID), Address: request.Address, Amount: f.Amount})
+	case errors.As(err, &rateLimited):
+		w.Header().Set("Retry-After", fmt.Sprint(int64(rateLimited.RetryAfter.Round(time.Second)/time.Second)))
+		writeJSON(w, http.StatusTooManyRequests, FaucetResponse{Error: err.Error()})
+	case errors.As(err, &addressErr):
+		writeJSON(w, http.StatusBadRequest, FaucetResponse{Error: err.Error()})
+	default:
+		writeJSON(w, http.StatusServiceUnavailable, FaucetResponse{Error: err.Error()})
+	}
+}
+
+// writeJSON はステータスコードとJSONの本文を書き込みます
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// mineFaucetPayouts は stop が閉じられるまで、interval ごとにメモリプールの払い出しをブロックに取り込みます
+// マイニングの報酬はフォーセットのウォレットが受け取るため、払い出しながら補充されます
+func (f *Faucet) mineFaucetPayouts(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if f.mempool.Size() == 0 {
+				continue
+			}
+			block, _, err := f.mempool.MineBlock(f.wallet.GetAddress())
+			if err != nil {
+				fmt.Printf("❌ Mining failed: %v\n", err)
+				continue
+			}
+			fmt.Printf("⛏️  Mined block #%d with %d payout(s)\n", block.Index, len(block.Transactions)-1)
+		}
+	}
+}
+
+const faucetUsage = `❌ Usage:
+  faucet send --to <address> [--amount <coins>] [--fee <coins>] [--interval <duration>] [--wallet <address|number>]
+  faucet serve [--addr :8080] [--amount <coins>] [--fee <coins>] [--interval <duration>] [--mine-interval <duration>] [--wallet <address|number>]`
+
+// runFaucetCommand は faucet サブコマンドを実行します
+func runFaucetCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(faucetUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "send":
+		return runFaucetSend(args[1:])
+	case "serve":
+		return runFaucetServe(args[1:])
+	default:
+		fmt.Println(faucetUsage)
+		return 2
+	}
+}
+
+// faucetFlags は send と serve に共通するフォーセットの設定です
+type faucetFlags struct {
+	amount   *int
+	fee      *int
+	interval *time.Duration
+	wallet   *string
+}
+
+// addFaucetFlags は共通のフラグを登録します
+func addFaucetFlags(fs *flag.FlagSet) faucetFlags {
+	return faucetFlags{
+		amount:   fs.Int("amount", DefaultFaucetAmount, "1回に払い出す額"),
+		fee:      fs.Int("fee", DefaultTransactionFee, "払い出しの手数料"),
+		interval: fs.Duration("interval", DefaultFaucetInterval, "同じアドレスに再び払い出すまでの間隔"),
+		wallet:   fs.String("wallet", "", "フォーセットのウォレット（アドレスか一覧の番号。省略時は使用中のウォレット）"),
+	}
+}
+
+// openFaucet はフォーセットのウォレットとチェーンを開いてフォーセットを作ります
+func openFaucet(flags faucetFlags) (*ChainStore, *Faucet, error) {
+	if *flags.amount <= 0 || *flags.fee < 0 || *flags.interval < 0 {
+		return nil, nil, fmt.Errorf("--amount must be positive, --fee and --interval must not be negative")
+	}
+
+	wallets, err := loadOrCreateWallets()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load wallet: %w", err)
+	}
+	var wallet *Wallet
+	if *flags.wallet == "" {
+		wallet, err = wallets.ActiveWallet()
+	} else {
+		var address string
+		if address, err = wallets.Resolve(*flags.wallet); err == nil {
+			wallet, err = wallets.GetWallet(address)
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load wallet: %w", err)
+	}
+
+	store, bc, utxoSet, err := openChain(wallet.GetAddress())
+	if err != nil {
+		return nil, nil, err
+	}
+	faucet := NewFaucet(NewMempool(bc, utxoSet), wallet, *flags.amount, *flags.fee, *flags.interval)
+	return store, faucet, nil
+}
+
+// runFaucetSend はフォーセットから1つのアドレスに払い出し、ブロックをマイニングして取り込みます
+func runFaucetSend(args []string) int {
+	fs := flag.NewFlagSet("faucet send", flag.ContinueOnError)
+	toFlag := fs.String("to", "", "払い出し先のアドレス")
+	flags := addFaucetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *toFlag == "" {
+		fmt.Println(faucetUsage)
+		return 2
+	}
+
+	store, faucet, err := openFaucet(flags)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	tx, err := faucet.Request(*toFlag)
+	var addressErr *common.AddressError
+	switch {
+	case errors.As(err, &addressErr):
+		printAddressError(err)
+		return 2
+	case err != nil:
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	block, _, err := faucet.mempool.MineBlock(faucet.Address())
+	if err != nil {
+		fmt.Printf("❌ Mining failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("\n🚰 Faucet payout sent!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("To:         %s\n", *toFlag)
+	fmt.Printf("Amount:     %d coins\n", faucet.Amount)
+	fmt.Printf("TxID:       %s\n", truncateHash(hex.EncodeToString(tx.ID)))
+	fmt.Printf("Block #%d:  %s\n", block.Index, truncateHash(block.Hash))
+	fmt.Printf("Faucet:     %d coins left (next payout to this address in %s)\n", faucet.Balance(), faucet.Interval)
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
+
+// runFaucetServe はフォーセットをHTTPで公開し、払い出しを一定間隔でブロックに取り込みます
+// Ctrl+C で停止します
+func runFaucetServe(args []string) int {
+	fs := flag.NewFlagSet("faucet serve", flag.ContinueOnError)
+	addrFlag := fs.String("addr", ":8080", "HTTPサーバーの待ち受けアドレス")
+	mineIntervalFlag := fs.Duration("mine-interval", DefaultFaucetMineInterval, "払い出しをブロックに取り込む間隔")
+	flags := addFaucetFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *mineIntervalFlag <= 0 {
+		fmt.Println("❌ --mine-interval must be positive")
+		return 2
+	}
+
+	store, faucet, err := openFaucet(flags)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	mux := http.NewServeMux()
+	mux.Handle("/faucet", faucet)
+	server := &http.Server{
+		Addr:              *addrFlag,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		faucet.mineFaucetPayouts(*mineIntervalFlag, stop)
+		close(done)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	fmt.Printf("🚰 Faucet %s is paying %d coins per request on http://%s/faucet\n", faucet.Address(), faucet.Amount, *addrFlag)
+	fmt.Printf("   Balance %d coins, one payout per address every %s, mining every %s (Ctrl+C to stop)\n", faucet.Balance(), faucet.Interval, *mineIntervalFlag)
+
+	status := 0
+	select {
+	case err := <-errs:
+		fmt.Printf("❌ Faucet server error: %v\n", err)
+		status = 1
+	case <-signals:
+		fmt.Println("\n🛑 Stopping the faucet")
+		_ = server.Close()
+	}
+	close(stop)
+	<-done
+	return status
+}
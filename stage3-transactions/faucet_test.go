@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callFaucet はテスト用にフォーセットへHTTPリクエストを送り、レスポンスを返します
+func callFaucet(t *testing.T, faucet *Faucet, method, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/faucet", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	faucet.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFaucetRequest(t *testing.T) {
+	t.Run("要求されたアドレスに払い出す", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+
+		tx, err := faucet.Request(testAddressA)
+		require.NoError(t, err)
+		assert.True(t, mempool.Contains(tx.ID))
+		assert.Equal(t, testAddressA, tx.Outputs[0].Address())
+		assert.Equal(t, 5, tx.Outputs[0].Value)
+	})
+
+	t.Run("同じアドレスへの払い出しは間隔を空ける", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 2)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+		now := time.Now()
+		faucet.now = func() time.Time { return now }
+
+		_, err := faucet.Request(testAddressA)
+		require.NoError(t, err)
+
+		now = now.Add(20 * time.Minute)
+		_, err = faucet.Request(testAddressA)
+		var rateLimited *FaucetRateLimitError
+		require.ErrorAs(t, err, &rateLimited)
+		assert.Equal(t, 40*time.Minute, rateLimited.RetryAfter)
+
+		// 別のアドレスは制限されない
+		_, err = faucet.Request(testAddressB)
+		require.NoError(t, err)
+
+		now = now.Add(40 * time.Minute)
+		_, err = faucet.Request(testAddressA)
+		assert.NoError(t, err)
+	})
+
+	t.Run("起動し直してもチェーンの払い出しから制限を続ける", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+		_, err := faucet.Request(testAddressA)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(faucet.Address())
+		require.NoError(t, err)
+
+		restarted := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+		_, err = restarted.Request(testAddressA)
+		var rateLimited *FaucetRateLimitError
+		assert.ErrorAs(t, err, &rateLimited)
+		_, err = restarted.Request(testAddressB)
+		assert.NoError(t, err)
+	})
+
+	t.Run("不正なアドレスとフォーセット自身には払い出さない", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+
+		_, err := faucet.Request("not-an-address")
+		assert.Error(t, err)
+		_, err = faucet.Request(faucet.Address())
+		assert.ErrorContains(t, err, "cannot pay itself")
+		assert.Equal(t, 0, mempool.Size())
+	})
+
+	t.Run("残高が足りなければ失敗し、制限は記録しない", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+		faucet.Amount = 1000
+
+		_, err := faucet.Request(testAddressA)
+		assert.ErrorContains(t, err, "insufficient funds")
+
+		faucet.Amount = 5
+		_, err = faucet.Request(testAddressA)
+		assert.NoError(t, err)
+	})
+}
+
+func TestFaucetHTTP(t *testing.T) {
+	t.Run("POSTで払い出しIDを返す", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+
+		rec := callFaucet(t, faucet, http.MethodPost, `{"address":"`+testAddressA+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var response FaucetResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, testAddressA, response.Address)
+		assert.Equal(t, 5, response.Amount)
+		txID, err := hex.DecodeString(response.TxID)
+		require.NoError(t, err)
+		assert.True(t, mempool.Contains(txID))
+	})
+
+	t.Run("間隔を空けない要求は429とRetry-Afterを返す", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+		body := `{"address":"` + testAddressA + `"}`
+		require.Equal(t, http.StatusOK, callFaucet(t, faucet, http.MethodPost, body).Code)
+
+		rec := callFaucet(t, faucet, http.MethodPost, body)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "3600", rec.Header().Get("Retry-After"))
+		var response FaucetResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Contains(t, response.Error, "try again in 1h0m0s")
+	})
+
+	t.Run("不正な要求を区別して返す", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+
+		assert.Equal(t, http.StatusBadRequest, callFaucet(t, faucet, http.MethodPost, `{not json`).Code)
+		assert.Equal(t, http.StatusBadRequest, callFaucet(t, faucet, http.MethodPost, `{"address":"1invalid"}`).Code)
+		assert.Equal(t, http.StatusMethodNotAllowed, callFaucet(t, faucet, http.MethodPut, "").Code)
+
+		faucet.Amount = 1000
+		assert.Equal(t, http.StatusServiceUnavailable, callFaucet(t, faucet, http.MethodPost, `{"address":"`+testAddressA+`"}`).Code)
+	})
+
+	t.Run("GETで設定と残高を返す", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		faucet := NewFaucet(mempool, wallet, 5, 1, time.Hour)
+
+		rec := callFaucet(t, faucet, http.MethodGet, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var info FaucetInfo
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+		assert.Equal(t, FaucetInfo{Address: faucet.Address(), Amount: 5, Fee: 1, IntervalSeconds: 3600, Balance: 50}, info)
+	})
+}
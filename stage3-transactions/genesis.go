@@ -0,0 +1,152 @@
+// Package main implements genesis premine allocations for Stage 3.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// genesisTxVersion はジェネシス仕様から作るコインベースのバージョンです
+// CurrentTxVersion が上がっても同じ仕様から同じジェネシスブロックができるよう固定します
+const genesisTxVersion = TxVersion2
+
+// defaultGenesisDifficulty はジェネシス仕様で難易度を省略したときの値です（CLIのチェーンと同じ）
+const defaultGenesisDifficulty = 2
+
+// GenesisAllocation はジェネシスブロックで配分する1件（アドレスと金額）です
+type GenesisAllocation struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// GenesisSpec はジェネシスブロックの仕様です（プレマイン・エアドロップの配分）
+// 時刻やメッセージもすべて仕様から決めるため、同じ仕様からはどのノードでも同じジェネシスブロックができます
+type GenesisSpec struct {
+	Timestamp   int64               `json:"timestamp"`            // ブロックとコインベースの時刻（Unix秒）
+	Message     string              `json:"message"`              // コインベースの scriptSig に入れるメッセージ
+	Difficulty  int                 `json:"difficulty,omitempty"` // マイニング難易度（省略時は2）
+	Allocations []GenesisAllocation `json:"allocations"`          // 配分（この順に出力を作る）
+	Hash        string              `json:"hash,omitempty"`       // 期待するジェネシスブロックのハッシュ（指定すれば照合する）
+}
+
+// LoadGenesisSpec はJSONのジェネシス仕様を読み込んで検証します
+func LoadGenesisSpec(path string) (*GenesisSpec, error) {
+	// #nosec G304 -- ファイル読み込みは教育目的のため許容
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis spec: %w", err)
+	}
+
+	var spec GenesisSpec
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to decode genesis spec %s: %w", path, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid genesis spec %s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// Validate はジェネシス仕様を検証します
+// 配分は1件以上で、アドレスは正しく重複せず、金額は正の数で合計が MaxMoney 以下である必要があります
+func (s *GenesisSpec) Validate() error {
+	if s.Timestamp <= 0 {
+		return fmt.Errorf("timestamp must be positive")
+	}
+	if s.Difficulty < 0 {
+		return fmt.Errorf("difficulty must not be negative")
+	}
+	if len(s.Allocations) == 0 {
+		return fmt.Errorf("at least one allocation is required")
+	}
+
+	seen := make(map[string]bool)
+	for i, allocation := range s.Allocations {
+		if err := common.ValidateAddress(allocation.Address); err != nil {
+			return fmt.Errorf("allocation %d: %w", i, err)
+		}
+		if allocation.Amount <= 0 {
+			return fmt.Errorf("allocation %d: amount must be positive, got %d", i, allocation.Amount)
+		}
+		key := utxoKey(allocation.Address)
+		if seen[key] {
+			return fmt.Errorf("allocation %d: duplicate address %s", i, allocation.Address)
+		}
+		seen[key] = true
+	}
+	if total := s.Total(); total > MaxMoney {
+		return fmt.Errorf("allocations total %d exceeds the maximum of %d", total, MaxMoney)
+	}
+	return nil
+}
+
+// Total は配分の合計を返します
+func (s *GenesisSpec) Total() int {
+	total := 0
+	for _, allocation := range s.Allocations {
+		total += allocation.Amount
+	}
+	return total
+}
+
+// Block は仕様からジェネシスブロックを作ってマイニングします
+// 仕様にハッシュがあれば、できたブロックのハッシュと一致するか確かめます
+func (s *GenesisSpec) Block() (*Block, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	coinbase := &Transaction{
+		Version:   genesisTxVersion,
+		Inputs:    []TxInput{{TxID: []byte{}, OutIndex: -1, ScriptSig: Script(s.Message)}},
+		Timestamp: s.Timestamp,
+	}
+	for _, allocation := range s.Allocations {
+		output, err := newOutput(allocation.Address, allocation.Amount)
+		if err != nil {
+			return nil, err
+		}
+		coinbase.Outputs = append(coinbase.Outputs, output)
+	}
+	coinbase.ID = coinbase.Hash()
+
+	difficulty := s.Difficulty
+	if difficulty == 0 {
+		difficulty = defaultGenesisDifficulty
+	}
+	block := &Block{
+		Index:        0,
+		Timestamp:    s.Timestamp,
+		Transactions: []*Transaction{coinbase},
+		Difficulty:   difficulty,
+	}
+	if err := checkBlockLimits(block); err != nil {
+		return nil, err
+	}
+	if _, err := MineBlock(block); err != nil {
+		return nil, fmt.Errorf("failed to mine genesis block: %w", err)
+	}
+
+	if s.Hash != "" && s.Hash != block.Hash {
+		return nil, fmt.Errorf("genesis hash %s does not match the spec's %s", block.Hash, s.Hash)
+	}
+	return block, nil
+}
+
+// genesisPremine はジェネシスブロックが報酬を超えて配分した額（プレマイン）を返します
+// ジェネシスブロックはハッシュで識別される信頼された出発点のため、配分の額はブロックから決めます
+func genesisPremine(genesis *Block, emission EmissionSchedule) int {
+	minted := 0
+	for _, tx := range genesis.Transactions {
+		for _, output := range tx.Outputs {
+			minted += output.Value
+		}
+	}
+	return max(0, minted-emission.InitialReward)
+}
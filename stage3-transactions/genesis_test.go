@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGenesisSpec は2つのアドレスに配分するジェネシス仕様を返します
+func testGenesisSpec() *GenesisSpec {
+	return &GenesisSpec{
+		Timestamp: 1700000000,
+		Message:   "minicoin premine",
+		Allocations: []GenesisAllocation{
+			{Address: testAddressA, Amount: 1000},
+			{Address: testAddressB, Amount: 250},
+		},
+	}
+}
+
+func TestGenesisSpec(t *testing.T) {
+	t.Run("同じ仕様からは同じジェネシスブロックができる", func(t *testing.T) {
+		first, err := testGenesisSpec().Block()
+		require.NoError(t, err)
+		second, err := testGenesisSpec().Block()
+		require.NoError(t, err)
+
+		assert.Equal(t, first.Hash, second.Hash)
+		assert.True(t, first.Validate())
+		require.Len(t, first.Transactions, 1)
+		assert.True(t, first.Transactions[0].IsCoinbase())
+		assert.Len(t, first.Transactions[0].Outputs, 2)
+		assert.Equal(t, int64(1700000000), first.Timestamp)
+		assert.Equal(t, defaultGenesisDifficulty, first.Difficulty)
+	})
+
+	t.Run("仕様が違えばハッシュも違う", func(t *testing.T) {
+		base, err := testGenesisSpec().Block()
+		require.NoError(t, err)
+
+		spec := testGenesisSpec()
+		spec.Allocations[1].Amount++
+		changed, err := spec.Block()
+		require.NoError(t, err)
+		assert.NotEqual(t, base.Hash, changed.Hash)
+	})
+
+	t.Run("期待するハッシュと照合する", func(t *testing.T) {
+		block, err := testGenesisSpec().Block()
+		require.NoError(t, err)
+
+		spec := testGenesisSpec()
+		spec.Hash = block.Hash
+		_, err = spec.Block()
+		require.NoError(t, err)
+
+		spec.Message = "another premine"
+		_, err = spec.Block()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match")
+	})
+
+	t.Run("不正な仕様を拒否する", func(t *testing.T) {
+		cases := map[string]func(*GenesisSpec){
+			"timestamp must be positive":      func(s *GenesisSpec) { s.Timestamp = 0 },
+			"at least one allocation":         func(s *GenesisSpec) { s.Allocations = nil },
+			"amount must be positive":         func(s *GenesisSpec) { s.Allocations[0].Amount = 0 },
+			"invalid address":                 func(s *GenesisSpec) { s.Allocations[0].Address = "not-an-address" },
+			"difficulty must not be negative": func(s *GenesisSpec) { s.Difficulty = -1 },
+			"allocation 1: duplicate address": func(s *GenesisSpec) { s.Allocations[1].Address = testAddressA },
+			"exceeds the maximum":             func(s *GenesisSpec) { s.Allocations[0].Amount = MaxMoney },
+		}
+		for message, mutate := range cases {
+			spec := testGenesisSpec()
+			mutate(spec)
+			err := spec.Validate()
+			require.Error(t, err, message)
+			assert.Contains(t, err.Error(), message)
+		}
+	})
+
+	t.Run("旧形式と新形式のアドレスは同じ宛先として重複を見つける", func(t *testing.T) {
+		pubKeyHash, err := common.AddressToPubKeyHash(testAddressA)
+		require.NoError(t, err)
+		spec := testGenesisSpec()
+		spec.Allocations[1].Address = common.BytesToHex(pubKeyHash)
+
+		err = spec.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate address")
+	})
+
+	t.Run("JSONファイルから読み込む", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "genesis.json")
+		data := `{"timestamp": 1700000000, "message": "minicoin premine", "allocations": [` +
+			`{"address": "` + testAddressA + `", "amount": 1000}, {"address": "` + testAddressB + `", "amount": 250}]}`
+		require.NoError(t, os.WriteFile(path, []byte(data), 0600))
+
+		spec, err := LoadGenesisSpec(path)
+		require.NoError(t, err)
+		assert.Equal(t, testGenesisSpec(), spec)
+		assert.Equal(t, 1250, spec.Total())
+	})
+
+	t.Run("知らない項目は拒否する", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "genesis.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"timestamp": 1, "alocations": []}`), 0600))
+
+		_, err := LoadGenesisSpec(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown field")
+	})
+}
+
+func TestGenesisPremine(t *testing.T) {
+	t.Run("配分したチェーンは有効で発行量にプレマインを含む", func(t *testing.T) {
+		genesis, err := testGenesisSpec().Block()
+		require.NoError(t, err)
+		bc := NewBlockchainFromGenesis(genesis, 1)
+		utxoSet := NewUTXOSet(bc)
+
+		assert.Equal(t, 1200, bc.Emission.Premine)
+		assert.Equal(t, 1000, utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, 250, utxoSet.GetBalance(testAddressB))
+
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(testAddressA, "next")})
+		require.NoError(t, err)
+		assert.NoError(t, bc.Validate())
+
+		audit, err := bc.VerifySupply()
+		require.NoError(t, err)
+		assert.Equal(t, 1300, audit.Scheduled)
+		assert.Equal(t, 1300, audit.Unspent)
+	})
+
+	t.Run("報酬以下の配分はプレマインにならない", func(t *testing.T) {
+		spec := testGenesisSpec()
+		spec.Allocations = spec.Allocations[1:]
+		spec.Allocations[0].Amount = 30
+		genesis, err := spec.Block()
+		require.NoError(t, err)
+
+		bc := NewBlockchainFromGenesis(genesis, 1)
+		assert.Zero(t, bc.Emission.Premine)
+		audit, err := bc.VerifySupply()
+		require.NoError(t, err)
+		assert.Equal(t, 20, audit.Unclaimed)
+	})
+
+	t.Run("保存したチェーンのジェネシスブロックと照合する", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		genesis, err := testGenesisSpec().Block()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		bc, err := OpenBlockchainWithGenesis(store, 1, genesis)
+		require.NoError(t, err)
+		assert.Equal(t, genesis.Hash, bc.Blocks[0].Hash)
+
+		reopened, err := OpenBlockchainWithGenesis(store, 1, genesis)
+		require.NoError(t, err)
+		assert.Equal(t, 1200, reopened.Emission.Premine)
+		withoutSpec, err := OpenBlockchain(store, 1, "")
+		require.NoError(t, err)
+		assert.Equal(t, 1200, withoutSpec.Emission.Premine)
+
+		spec := testGenesisSpec()
+		spec.Message = "another premine"
+		other, err := spec.Block()
+		require.NoError(t, err)
+		_, err = OpenBlockchainWithGenesis(store, 1, other)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "genesis spec produces")
+	})
+}
@@ -0,0 +1,289 @@
+// Package main implements hierarchical deterministic (HD) key derivation for Stage 3.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// HD鍵導出の定数
+const (
+	HardenedOffset  uint32 = 0x80000000 // これ以上のインデックスは強化導出（親の秘密鍵が必要）
+	HDEntropyLen           = 32         // NewHDWalletが生成するエントロピーの長さ（バイト、24語）
+	DefaultGapLimit        = 20         // 未使用アドレスがこれだけ続いたら探索をやめる（BIP44と同じ）
+
+	// DefaultHDPath は受け取り用アドレスの親の鍵のパス（m/44'/コイン種別'/アカウント'/受け取り）
+	// コイン種別は教育用のためテストネットと同じ1を使用します
+	DefaultHDPath = "m/44'/1'/0'/0"
+	// ChangeHDPath はおつり用アドレスの親の鍵のパス（受け取り用と同じアカウントの内部チェーン）
+	ChangeHDPath = "m/44'/1'/0'/1"
+
+	// P-256用のマスター鍵のHMACキー（SLIP-0010）
+	hdMasterKeyHMAC = "Nist256p1 seed"
+)
+
+// ExtendedKey は秘密鍵とチェーンコードの組です
+// チェーンコードがあることで、同じ秘密鍵から決定的に子の鍵を導出できます
+type ExtendedKey struct {
+	PrivateKey *ecdsa.PrivateKey
+	ChainCode  []byte
+	Depth      uint8  // マスター鍵からの深さ
+	Index      uint32 // 親から見た子のインデックス
+}
+
+// NewMasterKey はシードからマスター鍵を生成します
+// BIP32の鍵導出をP-256に適用したSLIP-0010に従います
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed must be 16 to 64 bytes, got %d", len(seed))
+	}
+
+	data := seed
+	for {
+		sum := hmacSHA512([]byte(hdMasterKeyHMAC), data)
+		if key, ok := privateKeyFromBytes(sum[:32]); ok {
+			return &ExtendedKey{PrivateKey: key, ChainCode: sum[32:]}, nil
+		}
+		// 鍵として無効な値（0または位数以上）なら結果を入力にしてやり直す
+		data = sum
+	}
+}
+
+// Child はインデックスindexの子の鍵を導出します
+// HardenedOffset以上のインデックスは親の秘密鍵から、それ未満は親の公開鍵から導出します
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if k.Depth == 255 {
+		return nil, fmt.Errorf("derivation depth exceeds 255")
+	}
+
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, k.PrivateKey.D.FillBytes(make([]byte, 32))...)
+	} else {
+		data = elliptic.MarshalCompressed(k.PrivateKey.Curve, k.PrivateKey.X, k.PrivateKey.Y)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	n := k.PrivateKey.Curve.Params().N
+	for {
+		sum := hmacSHA512(k.ChainCode, data)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(n) < 0 {
+			d := tweak.Add(tweak, k.PrivateKey.D)
+			d.Mod(d, n)
+			if key, ok := privateKeyFromBytes(d.FillBytes(make([]byte, 32))); ok {
+				return &ExtendedKey{PrivateKey: key, ChainCode: sum[32:], Depth: k.Depth + 1, Index: index}, nil
+			}
+		}
+
+		// 無効な鍵になった場合は 0x01 || IR || index でやり直す
+		data = append([]byte{0x01}, sum[32:]...)
+		data = binary.BigEndian.AppendUint32(data, index)
+	}
+}
+
+// DerivePath は "m/44'/1'/0'/0/5" のようなパスをたどって子孫の鍵を導出します
+// ' または h が付いた要素は強化導出です
+func (k *ExtendedKey) DerivePath(path string) (*ExtendedKey, error) {
+	indexes, err := ParseHDPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, index := range indexes {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// ParseHDPath は導出パスをインデックスの列に変換します
+func ParseHDPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: must start with m", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedOffset {
+			return nil, fmt.Errorf("invalid derivation path %q: bad index %q", path, part)
+		}
+		if hardened {
+			index += uint64(HardenedOffset)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// hmacSHA512 はHMAC-SHA512を計算します
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// privateKeyFromBytes は32バイトの値をP-256の秘密鍵にします（0または位数以上なら無効）
+func privateKeyFromBytes(b []byte) (*ecdsa.PrivateKey, bool) {
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(b)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, false
+	}
+
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+	return key, true
+}
+
+// HDWallet は1つのシードから受け取り用のアドレスを必要なだけ導出するウォレットです
+// シードさえバックアップしておけば、導出したすべての鍵を復元できます
+type HDWallet struct {
+	Mnemonic  string // シードの元になったニーモニック（シードから直接作成した場合は空）
+	Seed      []byte
+	Path      string       // 受け取り用アドレスの親の鍵のパス
+	NextIndex uint32       // 次に払い出すアドレスのインデックス
+	account   *ExtendedKey // Pathの鍵（子の導出を毎回マスター鍵からやり直さないため）
+}
+
+// NewHDWallet はランダムなエントロピーからニーモニックを作成し、それをシードにしたHDウォレットを作成します
+func NewHDWallet() (*HDWallet, error) {
+	entropy := make([]byte, HDEntropyLen)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, fmt.Errorf("failed to generate entropy: %w", err)
+	}
+
+	mnemonic, err := NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	return NewHDWalletFromMnemonic(mnemonic, "")
+}
+
+// NewHDWalletFromMnemonic はニーモニックとパスフレーズからHDウォレットを復元します
+func NewHDWalletFromMnemonic(mnemonic, passphrase string) (*HDWallet, error) {
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+
+	hw, err := NewHDWalletFromSeed(MnemonicToSeed(mnemonic, passphrase))
+	if err != nil {
+		return nil, err
+	}
+	hw.Mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return hw, nil
+}
+
+// NewHDWalletFromSeed はシードからHDウォレットを作成します（同じシードからは同じアドレスが導出されます）
+func NewHDWalletFromSeed(seed []byte) (*HDWallet, error) {
+	return NewHDWalletAtPath(seed, DefaultHDPath)
+}
+
+// NewHDWalletAtPath はシードから、path の鍵の子のアドレスを導出するHDウォレットを作成します
+func NewHDWalletAtPath(seed []byte, path string) (*HDWallet, error) {
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	account, err := master.DerivePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HDWallet{
+		Seed:    append([]byte(nil), seed...),
+		Path:    path,
+		account: account,
+	}, nil
+}
+
+// DeriveWallet はインデックスindexの鍵を署名に使えるWalletとして返します
+func (hw *HDWallet) DeriveWallet(index uint32) (*Wallet, error) {
+	key, err := hw.account.Child(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{
+		PrivateKey: key.PrivateKey,
+		PublicKey:  &key.PrivateKey.PublicKey,
+		Address:    common.PublicKeyToAddress(&key.PrivateKey.PublicKey),
+	}, nil
+}
+
+// DeriveAddress はインデックスindexのアドレスを返します
+func (hw *HDWallet) DeriveAddress(index uint32) (string, error) {
+	wallet, err := hw.DeriveWallet(index)
+	if err != nil {
+		return "", err
+	}
+	return wallet.GetAddress(), nil
+}
+
+// NewAddress は未使用のインデックスのアドレスを払い出します
+func (hw *HDWallet) NewAddress() (string, error) {
+	address, err := hw.DeriveAddress(hw.NextIndex)
+	if err != nil {
+		return "", err
+	}
+	hw.NextIndex++
+	return address, nil
+}
+
+// HDAddress は探索で見つかった使用済みアドレスです
+type HDAddress struct {
+	Index   uint32
+	Address string
+	Balance int
+}
+
+// Scan はインデックス0から順にアドレスを導出し、UTXOセットにUTXOがあるものを返します
+// UTXOのないアドレスがgapLimit個続いたら探索をやめ、NextIndexを最後に見つかったアドレスの次に進めます
+// UTXOセットを調べるため、すでに全額を使ったアドレスは未使用とみなされます
+func (hw *HDWallet) Scan(utxoSet *UTXOSet, gapLimit int) ([]HDAddress, error) {
+	if gapLimit <= 0 {
+		return nil, fmt.Errorf("gap limit must be positive")
+	}
+
+	var found []HDAddress
+	gap := 0
+	for index := uint32(0); gap < gapLimit && index < HardenedOffset; index++ {
+		address, err := hw.DeriveAddress(index)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(utxoSet.FindUTXO(address)) == 0 {
+			gap++
+			continue
+		}
+
+		gap = 0
+		found = append(found, HDAddress{Index: index, Address: address, Balance: utxoSet.GetBalance(address)})
+		if index >= hw.NextIndex {
+			hw.NextIndex = index + 1
+		}
+	}
+	return found, nil
+}
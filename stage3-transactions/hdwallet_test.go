@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMasterKey(t *testing.T) {
+	// SLIP-0010 のテストベクター1（nist256p1）
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	master, err := NewMasterKey(seed)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path       string
+		chainCode  string
+		privateKey string
+	}{
+		{
+			path:       "m",
+			chainCode:  "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			privateKey: "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+		},
+		{
+			path:       "m/0'",
+			chainCode:  "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			privateKey: "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+		},
+		{
+			path:       "m/0'/1",
+			chainCode:  "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			privateKey: "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			key, err := master.DerivePath(tt.path)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.chainCode, hex.EncodeToString(key.ChainCode))
+			assert.Equal(t, tt.privateKey, hex.EncodeToString(key.PrivateKey.D.FillBytes(make([]byte, 32))))
+		})
+	}
+
+	t.Run("短すぎるシードはエラー", func(t *testing.T) {
+		_, err := NewMasterKey([]byte{1, 2, 3})
+		assert.Error(t, err)
+	})
+}
+
+func TestParseHDPath(t *testing.T) {
+	t.Run("強化導出の記号", func(t *testing.T) {
+		indexes, err := ParseHDPath("m/44'/1h/0'/0/5")
+		require.NoError(t, err)
+		assert.Equal(t, []uint32{HardenedOffset + 44, HardenedOffset + 1, HardenedOffset, 0, 5}, indexes)
+	})
+
+	t.Run("マスター鍵のみ", func(t *testing.T) {
+		indexes, err := ParseHDPath("m")
+		require.NoError(t, err)
+		assert.Empty(t, indexes)
+	})
+
+	t.Run("不正なパス", func(t *testing.T) {
+		for _, path := range []string{"", "44'/0'", "m/", "m/abc", "m/-1", "m/2147483648"} {
+			_, err := ParseHDPath(path)
+			assert.Error(t, err, path)
+		}
+	})
+}
+
+// testHDWallet は固定のシードからHDウォレットを作成します
+func testHDWallet(t *testing.T) *HDWallet {
+	t.Helper()
+
+	seed, err := hex.DecodeString("fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a2")
+	require.NoError(t, err)
+	hw, err := NewHDWalletFromSeed(seed)
+	require.NoError(t, err)
+	return hw
+}
+
+func TestHDWalletDeriveAddress(t *testing.T) {
+	t.Run("同じシードとインデックスからは同じアドレス", func(t *testing.T) {
+		address1, err := testHDWallet(t).DeriveAddress(7)
+		require.NoError(t, err)
+		address2, err := testHDWallet(t).DeriveAddress(7)
+		require.NoError(t, err)
+
+		assert.Equal(t, address1, address2)
+	})
+
+	t.Run("インデックスごとに異なるアドレス", func(t *testing.T) {
+		hw := testHDWallet(t)
+		seen := make(map[string]bool)
+		for i := uint32(0); i < 10; i++ {
+			address, err := hw.DeriveAddress(i)
+			require.NoError(t, err)
+			assert.False(t, seen[address])
+			seen[address] = true
+		}
+	})
+
+	t.Run("NewAddressは順にアドレスを払い出す", func(t *testing.T) {
+		hw := testHDWallet(t)
+		first, err := hw.NewAddress()
+		require.NoError(t, err)
+		second, err := hw.NewAddress()
+		require.NoError(t, err)
+
+		expected, err := hw.DeriveAddress(1)
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, expected, second)
+		assert.Equal(t, uint32(2), hw.NextIndex)
+	})
+
+	t.Run("導出した鍵で送金に署名できる", func(t *testing.T) {
+		sender, err := testHDWallet(t).DeriveWallet(3)
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		tx, err := NewTransaction(sender, testAddressA, 10, 0, NewUTXOSet(bc), bc)
+		require.NoError(t, err)
+		assert.True(t, bc.VerifyTransaction(tx))
+	})
+}
+
+func TestHDWalletMnemonic(t *testing.T) {
+	t.Run("新しいHDウォレットはニーモニックから復元できる", func(t *testing.T) {
+		hw, err := NewHDWallet()
+		require.NoError(t, err)
+		assert.Len(t, strings.Fields(hw.Mnemonic), 24)
+
+		restored, err := NewHDWalletFromMnemonic(hw.Mnemonic, "")
+		require.NoError(t, err)
+		assert.Equal(t, hw.Seed, restored.Seed)
+
+		for i := uint32(0); i < 3; i++ {
+			expected, err := hw.DeriveAddress(i)
+			require.NoError(t, err)
+			actual, err := restored.DeriveAddress(i)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+	})
+
+	t.Run("パスフレーズが違うと別のウォレット", func(t *testing.T) {
+		mnemonic := "legal winner thank year wave sausage worth useful legal winner thank yellow"
+		plain, err := NewHDWalletFromMnemonic(mnemonic, "")
+		require.NoError(t, err)
+		protected, err := NewHDWalletFromMnemonic(mnemonic, "TREZOR")
+		require.NoError(t, err)
+
+		plainAddress, err := plain.DeriveAddress(0)
+		require.NoError(t, err)
+		protectedAddress, err := protected.DeriveAddress(0)
+		require.NoError(t, err)
+		assert.NotEqual(t, plainAddress, protectedAddress)
+	})
+
+	t.Run("チェックサムが合わないニーモニックはエラー", func(t *testing.T) {
+		_, err := NewHDWalletFromMnemonic("zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo", "")
+		assert.Error(t, err)
+	})
+}
+
+func TestHDWalletScan(t *testing.T) {
+	hw := testHDWallet(t)
+	funder, err := hw.DeriveWallet(0)
+	require.NoError(t, err)
+
+	bc := NewBlockchain(1, funder.GetAddress())
+	utxoSet := NewUTXOSet(bc)
+	mempool := NewMempool(bc, utxoSet)
+
+	// インデックス4には送金し、インデックス30はギャップの外に置く
+	for _, index := range []uint32{4, 30} {
+		address, err := hw.DeriveAddress(index)
+		require.NoError(t, err)
+		_, _, err = SendCoins(mempool, funder, address, 5, 0)
+		require.NoError(t, err)
+	}
+
+	t.Run("ギャップ内の使用済みアドレスを見つける", func(t *testing.T) {
+		found, err := hw.Scan(utxoSet, DefaultGapLimit)
+		require.NoError(t, err)
+
+		require.Len(t, found, 2)
+		assert.Equal(t, uint32(0), found[0].Index)
+		assert.Equal(t, utxoSet.GetBalance(funder.GetAddress()), found[0].Balance)
+		assert.Equal(t, uint32(4), found[1].Index)
+		assert.Equal(t, 5, found[1].Balance)
+		assert.Equal(t, uint32(5), hw.NextIndex)
+	})
+
+	t.Run("ギャップを広げると離れたアドレスも見つかる", func(t *testing.T) {
+		found, err := hw.Scan(utxoSet, 30)
+		require.NoError(t, err)
+
+		require.Len(t, found, 3)
+		assert.Equal(t, uint32(30), found[2].Index)
+		assert.Equal(t, uint32(31), hw.NextIndex)
+	})
+
+	t.Run("ギャップは正の値", func(t *testing.T) {
+		_, err := hw.Scan(utxoSet, 0)
+		assert.Error(t, err)
+	})
+}
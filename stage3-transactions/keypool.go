@@ -0,0 +1,303 @@
+// Package main implements the change-address keypool for Stage 3.
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// DefaultKeyPoolSize は keypool が先に導出しておく未使用のおつり用アドレスの数です
+const DefaultKeyPoolSize = 20
+
+// KeyPool は ChangeHDPath の鍵から先に導出しておいた、おつり用のアドレスの集まりです
+// 送金のたびに未使用のアドレスを1つ払い出して使用済みにし、同じアドレスにおつりを2度送りません
+// 鍵はすべてシードから導出するため、保存するのはシードと使用済みの数（HD.NextIndex）だけです
+type KeyPool struct {
+	HD   *HDWallet // おつり用の鍵を導出するHDウォレット（NextIndex 未満のインデックスが使用済み）
+	Size int       // 先に導出しておく未使用のアドレスの数
+
+	wallets []*Wallet         // インデックス順に導出した鍵（使用済みと未使用）
+	index   map[string]uint32 // utxoKey(アドレス) -> インデックス
+}
+
+// NewKeyPool はランダムなシードから、size 個の未使用のアドレスを持つ keypool を作成します
+func NewKeyPool(size int) (*KeyPool, error) {
+	entropy := make([]byte, HDEntropyLen)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, fmt.Errorf("failed to generate entropy: %w", err)
+	}
+	mnemonic, err := NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	hw, err := NewHDWalletAtPath(MnemonicToSeed(mnemonic, ""), ChangeHDPath)
+	if err != nil {
+		return nil, err
+	}
+	hw.Mnemonic = mnemonic
+	return newKeyPool(hw, size)
+}
+
+// newKeyPool は HD ウォレットの NextIndex から size 個のアドレスを導出した keypool を作成します
+func newKeyPool(hw *HDWallet, size int) (*KeyPool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("keypool size must be positive")
+	}
+	kp := &KeyPool{HD: hw, Size: size, index: make(map[string]uint32)}
+	if err := kp.refill(); err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
+
+// refill は未使用のアドレスが Size 個になるまで導出します
+func (kp *KeyPool) refill() error {
+	for uint32(len(kp.wallets)) < kp.HD.NextIndex+uint32(kp.Size) {
+		index := uint32(len(kp.wallets))
+		wallet, err := kp.HD.DeriveWallet(index)
+		if err != nil {
+			return fmt.Errorf("failed to derive keypool key %d: %w", index, err)
+		}
+		kp.wallets = append(kp.wallets, wallet)
+		kp.index[utxoKey(wallet.GetAddress())] = index
+	}
+	return nil
+}
+
+// ChangeAddress は未使用のアドレスを1つ払い出して使用済みにし、減った分を補充します
+func (kp *KeyPool) ChangeAddress() (string, error) {
+	address := kp.wallets[kp.HD.NextIndex].GetAddress()
+	kp.HD.NextIndex++
+	if err := kp.refill(); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// MarkUsed は keypool の未使用のアドレスを、それより前のものも含めて使用済みにします
+// keypool のアドレスでなければ、またはすでに使用済みなら false を返します
+func (kp *KeyPool) MarkUsed(address string) bool {
+	index, ok := kp.index[utxoKey(address)]
+	if !ok || index < kp.HD.NextIndex {
+		return false
+	}
+	kp.HD.NextIndex = index + 1
+	if err := kp.refill(); err != nil {
+		return false
+	}
+	return true
+}
+
+// Sync は未使用のはずのアドレスにUTXOがあれば使用済みにし、使用済みにした数を返します
+// 送金した後にウォレットを保存できなかった場合でも、同じアドレスをおつりに使い直さないためです
+func (kp *KeyPool) Sync(utxoSet *UTXOSet) int {
+	marked := 0
+	for _, address := range kp.Unused() {
+		if len(utxoSet.FindUTXO(address)) > 0 && kp.MarkUsed(address) {
+			marked++
+		}
+	}
+	return marked
+}
+
+// Used は払い出し済みのおつり用の鍵をインデックス順に返します
+func (kp *KeyPool) Used() []*Wallet {
+	return kp.wallets[:kp.HD.NextIndex]
+}
+
+// Unused は先に導出しておいた未使用のアドレスをインデックス順に返します
+func (kp *KeyPool) Unused() []string {
+	addresses := make([]string, 0, kp.Size)
+	for _, wallet := range kp.wallets[kp.HD.NextIndex:] {
+		addresses = append(addresses, wallet.GetAddress())
+	}
+	return addresses
+}
+
+// keyPoolData は keypool の保存用の構造体です（鍵はシードから導出し直します）
+type keyPoolData struct {
+	Mnemonic  string
+	Seed      []byte
+	NextIndex uint32
+	Size      int
+}
+
+// data は保存用の構造体を返します
+func (kp *KeyPool) data() *keyPoolData {
+	return &keyPoolData{Mnemonic: kp.HD.Mnemonic, Seed: kp.HD.Seed, NextIndex: kp.HD.NextIndex, Size: kp.Size}
+}
+
+// restoreKeyPool は保存した keypool をシードから導出し直します
+func restoreKeyPool(data *keyPoolData) (*KeyPool, error) {
+	hw, err := NewHDWalletAtPath(data.Seed, ChangeHDPath)
+	if err != nil {
+		return nil, err
+	}
+	hw.Mnemonic = data.Mnemonic
+	hw.NextIndex = data.NextIndex
+	return newKeyPool(hw, data.Size)
+}
+
+// ChangeAddress は keypool から新しいおつり用のアドレスを払い出します（keypool がなければ作成します）
+func (ws *Wallets) ChangeAddress() (string, error) {
+	if ws.KeyPool == nil {
+		kp, err := NewKeyPool(DefaultKeyPoolSize)
+		if err != nil {
+			return "", err
+		}
+		ws.KeyPool = kp
+	}
+	return ws.KeyPool.ChangeAddress()
+}
+
+// spenders は送金に使う鍵（使用中のウォレットと、おつりを受け取った keypool の鍵）を返します
+func (ws *Wallets) spenders() ([]*Wallet, error) {
+	wallet, err := ws.ActiveWallet()
+	if err != nil {
+		return nil, err
+	}
+	spenders := []*Wallet{wallet}
+	if ws.KeyPool != nil {
+		spenders = append(spenders, ws.KeyPool.Used()...)
+	}
+	return spenders, nil
+}
+
+// SpendableUTXOs は使用中のウォレットと keypool のおつりのアドレスの、送金に使えるUTXOを返します
+func (ws *Wallets) SpendableUTXOs(finder SpendableOutputFinder) ([]UTXO, error) {
+	spenders, err := ws.spenders()
+	if err != nil {
+		return nil, err
+	}
+	var utxos []UTXO
+	for _, wallet := range spenders {
+		utxos = append(utxos, finder.SpendableUTXOs(wallet.GetAddress())...)
+	}
+	return utxos, nil
+}
+
+// NewPaymentTransaction は使用中のウォレットと keypool のおつりのUTXOから支払うトランザクションを作成し、署名します
+// おつりは送金元に戻さず、keypool の新しいアドレスに送ります。入力はそれぞれのアドレスの鍵で署名します
+func (ws *Wallets) NewPaymentTransaction(payments []Payment, fee int, strategy CoinSelectionStrategy, finder SpendableOutputFinder, bc *Blockchain) (*Transaction, *CoinSelection, error) {
+	utxos, err := ws.SpendableUTXOs(finder)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, selection, err := newPaymentTransaction(utxos, payments, fee, strategy, ws.ChangeAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prevTxs, err := bc.previousTransactions(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	spenders, err := ws.spenders()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, wallet := range spenders {
+		if _, err := tx.signInputs(wallet, prevTxs); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, input := range tx.Inputs {
+		if len(input.ScriptSig) == 0 {
+			return nil, nil, fmt.Errorf("input %d: no key in the wallet can sign it", i)
+		}
+	}
+	return tx, selection, nil
+}
+
+// SubmitPayments は NewPaymentTransaction で作成した送金をメモリプールに追加します
+// 先に keypool をUTXOセットと突き合わせ、使用済みのアドレスをおつりに使い直さないようにします
+func (ws *Wallets) SubmitPayments(mempool *Mempool, payments []Payment, fee int, strategy CoinSelectionStrategy) (*Transaction, *CoinSelection, error) {
+	if ws.KeyPool != nil {
+		ws.KeyPool.Sync(mempool.utxoSet)
+	}
+	tx, selection, err := ws.NewPaymentTransaction(payments, fee, strategy, mempool, mempool.blockchain)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := mempool.Add(tx); err != nil {
+		return nil, nil, err
+	}
+	return tx, selection, nil
+}
+
+// runWalletKeyPool は keypool のおつり用アドレスを一覧表示します（keypool がなければ作成します）
+func runWalletKeyPool(args []string) int {
+	fs := flag.NewFlagSet("wallet keypool", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	sizeFlag := fs.Int("size", 0, "先に導出しておく未使用のアドレスの数を変更する")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *sizeFlag < 0 {
+		fmt.Println("❌ --size must be positive")
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	changed := false
+	if wallets.KeyPool == nil {
+		if wallets.KeyPool, err = NewKeyPool(DefaultKeyPoolSize); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		changed = true
+	}
+	kp := wallets.KeyPool
+	if *sizeFlag > 0 && *sizeFlag != kp.Size {
+		kp.Size = *sizeFlag
+		if err := kp.refill(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		changed = true
+	}
+
+	// チェーンがあれば残高を表示し、UTXOのある未使用のアドレスを使用済みにする
+	var utxoSet *UTXOSet
+	if _, err := os.Stat(chainFile); err == nil {
+		store, saved, err := openSavedUTXOSet()
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		defer func() { _ = store.Close() }()
+		utxoSet = saved
+		if kp.Sync(utxoSet) > 0 {
+			changed = true
+		}
+	}
+	if changed {
+		if err := wallets.SaveToFile(*fileFlag); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Printf("🔑 Keypool (%s)\n", kp.HD.Path)
+	fmt.Println("────────────────────────────────────────────────────────")
+	used := kp.Used()
+	fmt.Printf("Used:       %d change address(es)\n", len(used))
+	for i, wallet := range used {
+		if utxoSet != nil {
+			fmt.Printf("  %3d. %s  %d coins\n", i, wallet.GetAddress(), utxoSet.GetBalance(wallet.GetAddress()))
+		} else {
+			fmt.Printf("  %3d. %s\n", i, wallet.GetAddress())
+		}
+	}
+	unused := kp.Unused()
+	fmt.Printf("Unused:     %d pre-generated address(es)\n", len(unused))
+	fmt.Printf("Next:       %s\n", unused[0])
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
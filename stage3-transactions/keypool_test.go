@@ -0,0 +1,135 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPool(t *testing.T) {
+	t.Run("先に導出したアドレスを順に払い出して補充する", func(t *testing.T) {
+		kp, err := NewKeyPool(3)
+		require.NoError(t, err)
+		unused := kp.Unused()
+		require.Len(t, unused, 3)
+
+		first, err := kp.ChangeAddress()
+		require.NoError(t, err)
+		second, err := kp.ChangeAddress()
+		require.NoError(t, err)
+		assert.Equal(t, unused[:2], []string{first, second})
+		assert.Len(t, kp.Unused(), 3)
+		assert.Len(t, kp.Used(), 2)
+		assert.Equal(t, unused[2], kp.Unused()[0])
+
+		// おつりのアドレスは ChangeHDPath から導出される
+		expected, err := NewHDWalletAtPath(kp.HD.Seed, ChangeHDPath)
+		require.NoError(t, err)
+		address, err := expected.DeriveAddress(1)
+		require.NoError(t, err)
+		assert.Equal(t, address, second)
+	})
+
+	t.Run("使用済みにするとそれより前のアドレスも使用済みになる", func(t *testing.T) {
+		kp, err := NewKeyPool(5)
+		require.NoError(t, err)
+		unused := kp.Unused()
+
+		assert.True(t, kp.MarkUsed(unused[2]))
+		assert.Len(t, kp.Used(), 3)
+		assert.False(t, kp.MarkUsed(unused[1]))
+		assert.False(t, kp.MarkUsed(testAddressA))
+
+		next, err := kp.ChangeAddress()
+		require.NoError(t, err)
+		assert.Equal(t, unused[3], next)
+	})
+
+	t.Run("保存したkeypoolを同じアドレスで復元する", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		used, err := wallets.ChangeAddress()
+		require.NoError(t, err)
+
+		filename := filepath.Join(t.TempDir(), "wallets.dat")
+		require.NoError(t, wallets.SaveToFile(filename))
+		loaded, err := LoadWalletsFromFile(filename)
+		require.NoError(t, err)
+
+		require.NotNil(t, loaded.KeyPool)
+		require.Len(t, loaded.KeyPool.Used(), 1)
+		assert.Equal(t, used, loaded.KeyPool.Used()[0].GetAddress())
+		assert.Equal(t, wallets.KeyPool.Unused(), loaded.KeyPool.Unused())
+		assert.Equal(t, wallets.KeyPool.HD.Mnemonic, loaded.KeyPool.HD.Mnemonic)
+	})
+}
+
+func TestSubmitPayments(t *testing.T) {
+	t.Run("送金ごとにおつりを新しいアドレスに送る", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+
+		first, _, err := wallets.SubmitPayments(mempool, []Payment{{To: testAddressA, Amount: 10}}, 1, DefaultCoinSelection)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+		second, _, err := wallets.SubmitPayments(mempool, []Payment{{To: testAddressA, Amount: 30}}, 1, DefaultCoinSelection)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+
+		used := wallets.KeyPool.Used()
+		require.Len(t, used, 2)
+		assert.Equal(t, used[0].GetAddress(), first.Outputs[1].Address())
+		assert.Equal(t, used[1].GetAddress(), second.Outputs[1].Address())
+		assert.NotEqual(t, wallet.GetAddress(), first.Outputs[1].Address())
+
+		// 2回目は1回目のおつり（keypool の鍵）を使って署名した
+		assert.Equal(t, first.ID, second.Inputs[0].TxID)
+		assert.Equal(t, 0, mempool.utxoSet.GetBalance(wallet.GetAddress()))
+		assert.Equal(t, 8, mempool.utxoSet.GetBalance(used[1].GetAddress()))
+		assert.Equal(t, 8, wallets.GetTotalBalance(mempool).Total())
+	})
+
+	t.Run("使用中のウォレットとおつりのUTXOをまとめて使う", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+
+		_, _, err := wallets.SubmitPayments(mempool, []Payment{{To: testAddressA, Amount: 10}}, 1, DefaultCoinSelection)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+
+		// おつり39コインと報酬（50 + 手数料1）を両方使う
+		tx, _, err := wallets.SubmitPayments(mempool, []Payment{{To: testAddressB, Amount: 80}}, 1, DefaultCoinSelection)
+		require.NoError(t, err)
+		assert.Len(t, tx.Inputs, 2)
+		assert.Equal(t, 9, tx.Outputs[1].Value)
+	})
+
+	t.Run("保存し損ねても使用済みのアドレスをおつりに使い直さない", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		kp, err := NewKeyPool(DefaultKeyPoolSize)
+		require.NoError(t, err)
+		wallets.KeyPool = kp
+
+		tx, _, err := wallets.SubmitPayments(mempool, []Payment{{To: testAddressA, Amount: 10}}, 1, DefaultCoinSelection)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+
+		// 保存前の状態（何も払い出していない keypool）に戻す
+		kp.HD.NextIndex = 0
+		next, _, err := wallets.SubmitPayments(mempool, []Payment{{To: testAddressA, Amount: 10}}, 1, DefaultCoinSelection)
+		require.NoError(t, err)
+		assert.NotEqual(t, tx.Outputs[1].Address(), next.Outputs[1].Address())
+	})
+}
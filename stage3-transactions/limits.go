@@ -0,0 +1,118 @@
+// Package main implements block size limits and mempool eviction for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// ブロックの大きさの上限（コンセンサスのルール。超えるブロックは無効）
+// 手数料による選別が見えるよう、教育用に小さな値にしています
+// 以前のブロックの組み立ての上限（コインベース以外で4096バイト）で作ったブロックも収まります
+const (
+	WitnessScaleFactor   = 4     // 重さ = サイズ × 4（minicoin には witness がないため、BitcoinのSegWit以前のトランザクションと同じ）
+	MaxBlockWeight       = 20000 // ブロックのトランザクション（コインベースを含む）の重さの合計の上限（5000バイト）
+	MaxBlockTransactions = 20    // ブロックのトランザクション（コインベースを含む）の数の上限
+)
+
+// DefaultMempoolMaxSize はメモリプールに保持するトランザクションの合計サイズの既定の上限（バイト。ブロック4つ分）
+const DefaultMempoolMaxSize = 4 * MaxBlockWeight / WitnessScaleFactor
+
+// EventTxEvicted はメモリプールが上限を超えたため、手数料率の低いトランザクションを取り除いたことを表します
+const EventTxEvicted = "evicted"
+
+// Weight はトランザクションの重さを返します
+func (tx *Transaction) Weight() int {
+	return tx.Size() * WitnessScaleFactor
+}
+
+// Weight はブロックのトランザクションの重さの合計を返します
+func (b *Block) Weight() int {
+	weight := 0
+	for _, tx := range b.Transactions {
+		weight += tx.Weight()
+	}
+	return weight
+}
+
+// checkBlockLimits はブロックがトランザクションの数と重さの上限を超えていないか検証します
+func checkBlockLimits(block *Block) error {
+	if n := len(block.Transactions); n > MaxBlockTransactions {
+		return fmt.Errorf("block %d: %d transactions exceed the limit of %d", block.Index, n, MaxBlockTransactions)
+	}
+	if weight := block.Weight(); weight > MaxBlockWeight {
+		return fmt.Errorf("block %d: weight %d exceeds the limit of %d", block.Index, weight, MaxBlockWeight)
+	}
+	return nil
+}
+
+// MempoolStats はメモリプールの使用状況です
+type MempoolStats struct {
+	Count   int // トランザクションの数
+	Size    int // トランザクションの合計サイズ（バイト）
+	MaxSize int // 合計サイズの上限（0なら制限しない）
+	Fees    int // 手数料の合計
+	Evicted int // 上限を超えたため取り除いたトランザクションの数（累計）
+}
+
+// Stats はメモリプールの使用状況を返します
+func (mp *Mempool) Stats() MempoolStats {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	stats := MempoolStats{Count: len(mp.txs), MaxSize: mp.MaxSize, Evicted: mp.evicted}
+	for _, entry := range mp.txs {
+		stats.Size += entry.Size
+		stats.Fees += entry.Fee
+	}
+	return stats
+}
+
+// trimLocked は合計サイズが MaxSize を超えている間、子孫を含めた手数料率が最も低いトランザクションを
+// 子孫と一緒に取り除き、取り除いたTxID(hex)を返します（子は親がなければブロックに含められないため）
+// 同じ手数料率なら後から受け付けたものを先に取り除きます
+// 呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) trimLocked() []string {
+	var evicted []string
+	for mp.MaxSize > 0 && mp.sizeLocked() > mp.MaxSize {
+		var worst []string
+		worstFee, worstSize := 0, 0
+		for _, id := range mp.order {
+			descendants := mp.descendantsLocked(id)
+			fee, size := 0, 0
+			for _, other := range descendants {
+				fee += mp.txs[other].Fee
+				size += mp.txs[other].Size
+			}
+			// 浮動小数点の誤差を避けるため、fee/size <= worstFee/worstSize を掛け算で比較する
+			if worst == nil || fee*worstSize <= worstFee*size {
+				worst, worstFee, worstSize = descendants, fee, size
+			}
+		}
+
+		rate := float64(worstFee) / float64(worstSize)
+		for _, id := range worst {
+			mp.logLocked(EventTxEvicted, id, fmt.Sprintf("mempool full (%d bytes), package fee rate %.4f coins/byte", mp.MaxSize, rate))
+			mp.removeLocked(id)
+			mp.evicted++
+			evicted = append(evicted, id)
+		}
+	}
+	return evicted
+}
+
+// sizeLocked はメモリプールのトランザクションの合計サイズを返します
+// 呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) sizeLocked() int {
+	size := 0
+	for _, entry := range mp.txs {
+		size += entry.Size
+	}
+	return size
+}
+
+// mempoolFullError は受け付けたトランザクションが上限を超えたため、すぐに取り除かれたことを表すエラーを返します
+func mempoolFullError(tx *Transaction, fee, maxSize int) error {
+	return &PolicyError{Reason: fmt.Sprintf("mempool full (%d bytes): transaction %s has the lowest fee rate (%.4f coins/byte)",
+		maxSize, truncateHash(hex.EncodeToString(tx.ID)), float64(fee)/float64(tx.Size()))}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockLimits(t *testing.T) {
+	t.Run("トランザクションが多すぎるブロックは無効", func(t *testing.T) {
+		wallet, bc, _, _ := newTestChain(t)
+		var txs []*Transaction
+		for i := 0; i <= MaxBlockTransactions; i++ {
+			txs = append(txs, NewCoinbaseTx(wallet.GetAddress(), fmt.Sprintf("tx %d", i)))
+		}
+
+		_, _, err := bc.MineBlock(txs)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceed the limit of 20")
+	})
+
+	t.Run("重すぎるブロックは無効", func(t *testing.T) {
+		wallet, bc, _, _ := newTestChain(t)
+		heavy := NewCoinbaseTx(wallet.GetAddress(), strings.Repeat("x", MaxBlockWeight/WitnessScaleFactor))
+		assert.Greater(t, heavy.Weight(), MaxBlockWeight)
+
+		_, _, err := bc.MineBlock([]*Transaction{heavy})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds the limit of 20000")
+
+		// 他のノードから受け取ったブロックとしてチェーンに入っていても検証で見つかる
+		appendUnchecked(t, bc, []*Transaction{heavy})
+		assert.ErrorContains(t, bc.Validate(), "weight")
+	})
+
+	t.Run("マイニングするブロックは上限に収まる", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, MaxBlockTransactions-1)
+		for mempool.Size() < MaxBlockTransactions {
+			_, err := SubmitTransaction(mempool, wallet, testAddressA, 1, 1)
+			require.NoError(t, err)
+		}
+
+		block, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(block.Transactions), MaxBlockTransactions)
+		assert.LessOrEqual(t, block.Weight(), MaxBlockWeight)
+		assert.Equal(t, MaxBlockTransactions-len(block.Transactions)+1, mempool.Size())
+		assert.NoError(t, bc.Validate())
+	})
+
+	t.Run("数の上限で選ぶトランザクションを打ち切る", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 2)
+		for _, fee := range []int{1, 9, 4} {
+			_, err := SubmitTransaction(mempool, wallet, testAddressA, 10, fee)
+			require.NoError(t, err)
+		}
+
+		selected, fees := mempool.SelectTransactions(MaxBlockWeight, 2)
+		assert.Len(t, selected, 2)
+		assert.Equal(t, 13, fees)
+	})
+}
+
+func TestMempoolEviction(t *testing.T) {
+	t.Run("上限を超えたら手数料率の低いものから取り除く", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 2)
+
+		low, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 1)
+		require.NoError(t, err)
+		high, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 9)
+		require.NoError(t, err)
+		mempool.MaxSize = low.Size() + high.Size()
+
+		mid, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 4)
+		require.NoError(t, err)
+		assert.False(t, mempool.Contains(low.ID))
+		assert.True(t, mempool.Contains(high.ID))
+		assert.True(t, mempool.Contains(mid.ID))
+
+		stats := mempool.Stats()
+		assert.Equal(t, 2, stats.Count)
+		assert.Equal(t, high.Size()+mid.Size(), stats.Size)
+		assert.Equal(t, 13, stats.Fees)
+		assert.Equal(t, 1, stats.Evicted)
+		events := mempool.Events()
+		assert.Equal(t, EventTxEvicted, events[len(events)-1].Type)
+	})
+
+	t.Run("手数料率が最も低い送金は受け付けない", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+
+		first, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 5)
+		require.NoError(t, err)
+		mempool.MaxSize = first.Size()
+
+		tx, err := NewTransaction(wallet, testAddressA, 10, 1, mempool, bc)
+		require.NoError(t, err)
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.True(t, IsPolicyError(err))
+		assert.Contains(t, err.Error(), "mempool full")
+		assert.False(t, mempool.Contains(tx.ID))
+		assert.True(t, mempool.Contains(first.ID))
+	})
+
+	t.Run("親を取り除くときは子孫も取り除く", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+
+		// 親子の手数料の合計は1、無関係な送金の手数料は9
+		parent, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 0)
+		require.NoError(t, err)
+		child := newChildTx(t, wallet, parent, 1, testAddressB, 39)
+		require.NoError(t, mempool.Add(child))
+		mempool.MaxSize = parent.Size() + child.Size()
+
+		unrelated, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 9)
+		require.NoError(t, err)
+		assert.Equal(t, 1, mempool.Size())
+		assert.True(t, mempool.Contains(unrelated.ID))
+		assert.Equal(t, 2, mempool.Stats().Evicted)
+	})
+
+	t.Run("上限が0なら取り除かない", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+		mempool.MaxSize = 0
+
+		for i := 0; i < 2; i++ {
+			_, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 0)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 0, mempool.Stats().Evicted)
+	})
+}
@@ -3,18 +3,114 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// ウォレットの保存先
+const (
+	walletFile  = "wallet.dat"  // 単一のウォレット（以前の形式。起動時にwallets.datへ取り込む）
+	walletsFile = "wallets.dat" // ローカルのすべてのウォレットと使用中のウォレット
 )
 
-const walletFile = "wallet.dat"
+// chainFile はブロックとUTXOセットの保存先
+const chainFile = "chain.db"
+
+// recentMempoolEvents はメモリプールの表示に含める直近のイベント数
+const recentMempoolEvents = 10
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "send":
+			os.Exit(runSendCommand(os.Args[2:]))
+		case "sendmany":
+			os.Exit(runSendManyCommand(os.Args[2:]))
+		case "device":
+			os.Exit(runDeviceCommand(os.Args[2:]))
+		case "wallet":
+			os.Exit(runWalletCommand(os.Args[2:]))
+		case "prove":
+			os.Exit(runProveCommand(os.Args[2:]))
+		case "spv":
+			os.Exit(runSPVCommand(os.Args[2:]))
+		case "txindex":
+			os.Exit(runTxIndexCommand(os.Args[2:]))
+		case "psbt":
+			os.Exit(runPSBTCommand(os.Args[2:]))
+		case "verifysupply":
+			os.Exit(runVerifySupplyCommand(os.Args[2:]))
+		case "accounts":
+			os.Exit(runAccountsCommand(os.Args[2:]))
+		case "tokens":
+			os.Exit(runTokensCommand(os.Args[2:]))
+		case "faucet":
+			os.Exit(runFaucetCommand(os.Args[2:]))
+		case "utxostats":
+			os.Exit(runUTXOStatsCommand(os.Args[2:]))
+		case "listunspent":
+			os.Exit(runListUnspent(os.Args[2:]))
+		case "createrawtransaction":
+			os.Exit(runCreateRawTransaction(os.Args[2:]))
+		case "signrawtransaction":
+			os.Exit(runSignRawTransaction(os.Args[2:]))
+		case "decoderawtransaction":
+			os.Exit(runDecodeRawTransaction(os.Args[2:]))
+		case "sendrawtransaction":
+			os.Exit(runSendRawTransaction(os.Args[2:]))
+		case "script":
+			os.Exit(runScriptCommand(os.Args[2:]))
+		}
+	}
+
+	halvingFlag := flag.Int64("halving-interval", 0, fmt.Sprintf("新しいチェーンでブロック報酬が半減する間隔（ブロック数。既定%d）。chain.db に保存され、既存のチェーンでは保存された値と一致する必要がある", DefaultHalvingInterval))
+	expiryBlocksFlag := flag.Int64("mempool-expiry-blocks", DefaultMempoolExpiryBlocks, "この数のブロックで取り込まれない送金を期限切れにする（0なら無期限）")
+	expiryFlag := flag.Duration("mempool-expiry", DefaultMempoolExpiry, "この時間で取り込まれない送金を期限切れにする（0なら無期限）")
+	dustLimitFlag := flag.Int("dust-limit", DefaultDustLimit, "この額未満の出力を含む送金をメモリプールに受け入れない")
+	minRelayFeeFlag := flag.Int("min-relay-fee", DefaultMinRelayFee, "メモリプールに受け入れる最低手数料（1000バイトあたり。0なら制限しない）")
+	mempoolMaxSizeFlag := flag.Int("mempool-max-size", DefaultMempoolMaxSize, "メモリプールに保持するトランザクションの合計サイズの上限（バイト。超えたら手数料率の低いものから取り除く。0なら制限しない）")
+	reindexFlag := flag.Bool("reindex", false, "起動時に保存されたブロックのデータからUTXOセットとトランザクションインデックスを作り直す")
+	genesisFlag := flag.String("genesis", "", "新しいチェーンのジェネシスブロックを作るジェネシス仕様（JSON）。既存のチェーンはジェネシスブロックが一致するか確認する")
+	accountViewFlag := flag.Bool("account-view", false, "送金のたびに、UTXOモデルとアカウントモデルでの解釈を並べて表示する")
+	flag.Parse()
+	if *halvingFlag < 0 {
+		fmt.Println("❌ --halving-interval must be positive")
+		os.Exit(2)
+	}
+	if *expiryBlocksFlag < 0 || *expiryFlag < 0 {
+		fmt.Println("❌ --mempool-expiry-blocks and --mempool-expiry must not be negative")
+		os.Exit(2)
+	}
+	if *dustLimitFlag < 0 || *minRelayFeeFlag < 0 || *mempoolMaxSizeFlag < 0 {
+		fmt.Println("❌ --dust-limit, --min-relay-fee and --mempool-max-size must not be negative")
+		os.Exit(2)
+	}
+
+	var genesis *Block
+	if *genesisFlag != "" {
+		spec, err := LoadGenesisSpec(*genesisFlag)
+		if err == nil {
+			genesis, err = spec.Block()
+		}
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(2)
+		}
+	}
+
 	printHeader()
 
 	// ウォレットの読み込みまたは作成
-	wallet, err := loadOrCreateWallet()
+	wallets, err := loadOrCreateWallets()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return
+	}
+	wallet, err := wallets.ActiveWallet()
 	if err != nil {
 		fmt.Printf("❌ Failed to load wallet: %v\n", err)
 		return
@@ -22,9 +118,35 @@ func main() {
 
 	fmt.Printf("📱 Your Address: %s\n\n", wallet.GetAddress())
 
-	// ブロックチェーン初期化
-	bc := NewBlockchain(2, wallet.GetAddress())
-	utxoSet := NewUTXOSet(bc)
+	// ブロックチェーン初期化（保存されたチェーンとUTXOセットを読み込む）
+	store, bc, utxoSet, err := openChainWithGenesis(wallet.GetAddress(), genesis, *halvingFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	defer func() { _ = store.Close() }()
+	if genesis != nil {
+		fmt.Printf("🌱 Genesis %s allocates %d coin(s) to %d address(es)\n", truncateHash(genesis.Hash), bc.Emission.RewardAt(0), len(genesis.Transactions[0].Outputs))
+	}
+	if *reindexFlag {
+		result, err := Reindex(bc, utxoSet)
+		if err != nil {
+			fmt.Printf("❌ Reindex failed: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Reindexed %d block(s): %d transaction(s), %d UTXO(s)\n", result.Blocks, result.Transactions, result.UTXOs)
+	}
+	var accounts *AccountView
+	if *accountViewFlag {
+		accounts = NewAccountView(bc)
+		utxoSet.AttachAccountView(accounts)
+	}
+	mempool := NewMempool(bc, utxoSet)
+	mempool.ExpiryBlocks = *expiryBlocksFlag
+	mempool.Expiry = *expiryFlag
+	mempool.DustLimit = *dustLimitFlag
+	mempool.MinRelayFee = *minRelayFeeFlag
+	mempool.MaxSize = *mempoolMaxSizeFlag
 
 	scanner := bufio.NewScanner(os.Stdin)
 
@@ -40,20 +162,44 @@ func main() {
 
 		switch choice {
 		case "1":
-			displayBalance(wallet, utxoSet)
+			displayBalance(wallet, wallets, mempool)
 		case "2":
-			createWallet()
+			createWallet(wallets)
 		case "3":
 			displayChain(bc)
 		case "4":
-			displayTransactions(bc)
+			displayTransactions(bc, wallets)
 		case "5":
-			mineBlock(bc, utxoSet, wallet)
+			mineBlock(mempool, wallet)
 		case "6":
 			displayUTXOs(wallet, utxoSet)
 		case "7":
-			validateChain(bc)
+			validateChain(bc, utxoSet)
 		case "8":
+			sendCoins(mempool, wallets, accounts, scanner)
+		case "9":
+			displayMempool(mempool)
+		case "10":
+			displaySupply(bc)
+		case "11":
+			displayWallets(wallets, utxoSet)
+		case "12":
+			if switched := switchWallet(wallets, scanner); switched != nil {
+				wallet = switched
+			}
+		case "13":
+			fundScript(mempool, wallets, wallet, scanner)
+		case "14":
+			spendScript(mempool, utxoSet, wallets, scanner)
+		case "15":
+			resendExpired(mempool, wallets, scanner)
+		case "16":
+			bumpFee(mempool, wallets, scanner)
+		case "17":
+			runDashboard(mempool, wallets, wallet)
+		case "18":
+			displayDifficultyStats(bc)
+		case "19":
 			fmt.Println("\n👋 Goodbye!")
 			return
 		default:
@@ -62,6 +208,94 @@ func main() {
 	}
 }
 
+// runDashboard はダッシュボードを起動します
+func runDashboard(mempool *Mempool, wallets *Wallets, wallet *Wallet) {
+	fmt.Println("\n🖥️  ダッシュボードを起動しています...")
+	fmt.Println("   (終了するには 'q' を押してください)")
+
+	dashboard := NewDashboard(mempool, wallets, wallet)
+	if err := dashboard.Run(); err != nil {
+		fmt.Printf("❌ ダッシュボードエラー: %v\n", err)
+	}
+
+	fmt.Println("\n✓ ダッシュボードを終了しました")
+}
+
+// openChain は chain.db からチェーンとUTXOセットを開きます（なければジェネシスから作成します）
+// UTXOセットとトランザクションインデックスは保存されたものを使い、チェーンの最新ブロックと食い違うときだけ再構築します
+func openChain(minerAddress string) (*ChainStore, *Blockchain, *UTXOSet, error) {
+	return openChainWithGenesis(minerAddress, nil, 0)
+}
+
+// openChainWithGenesis は openChain と同じですが、genesis があれば新しいチェーンをそのジェネシスブロックから始め、
+// 既存のチェーンのジェネシスブロックと一致するか確かめます
+// halvingInterval が正なら新しいチェーンの半減の間隔として保存し、既存のチェーンでは保存された値と一致するか確かめます
+// （0なら保存された値を使い、新しいチェーンは DefaultHalvingInterval で始めます）
+func openChainWithGenesis(minerAddress string, genesis *Block, halvingInterval int64) (*ChainStore, *Blockchain, *UTXOSet, error) {
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := checkHalvingInterval(store, halvingInterval); err != nil {
+		_ = store.Close()
+		return nil, nil, nil, err
+	}
+
+	// 開く前に、保存されたインデックスが最新ブロックを反映しているか確認する（食い違いは開くときに作り直される）
+	check, err := store.CheckIndexes()
+	if err != nil {
+		_ = store.Close()
+		return nil, nil, nil, fmt.Errorf("startup check failed: %w", err)
+	}
+	for _, problem := range check.Problems() {
+		fmt.Printf("⚠️  Startup check: %s; rebuilding from block data\n", problem)
+	}
+
+	var bc *Blockchain
+	if genesis != nil {
+		bc, err = OpenBlockchainWithGenesis(store, 2, genesis)
+	} else {
+		bc, err = OpenBlockchain(store, 2, minerAddress)
+	}
+	if err != nil {
+		_ = store.Close()
+		return nil, nil, nil, err
+	}
+	// マイニングするブロックの難易度は、ステージ2と同じように目標ブロック生成時間に合わせて調整する
+	bc.SetTargetBlockTime(TargetBlockTime)
+	utxoSet, reindexed, err := OpenUTXOSet(store, bc)
+	if err != nil {
+		_ = store.Close()
+		return nil, nil, nil, err
+	}
+
+	if reindexed {
+		fmt.Printf("🗂️  Rebuilt the UTXO set from %d block(s) in %s\n", bc.GetChainLength(), chainFile)
+	} else {
+		fmt.Printf("🗂️  Loaded %d block(s) and the UTXO set from %s\n", bc.GetChainLength(), chainFile)
+	}
+	return store, bc, utxoSet, nil
+}
+
+// checkHalvingInterval は --halving-interval で指定した半減の間隔を chain.db と照合します
+// まだ保存されていなければ（新しいチェーン）保存し、別の値で作られたチェーンは開きません
+func checkHalvingInterval(store *ChainStore, interval int64) error {
+	if interval == 0 {
+		return nil
+	}
+	stored, err := store.HalvingInterval()
+	if err != nil {
+		return fmt.Errorf("failed to read halving interval: %w", err)
+	}
+	if stored == 0 {
+		return store.SaveHalvingInterval(interval)
+	}
+	if stored != interval {
+		return fmt.Errorf("%s was created with --halving-interval %d, not %d", chainFile, stored, interval)
+	}
+	return nil
+}
+
 func printHeader() {
 	fmt.Println("╔════════════════════════════════════════════════════════╗")
 	fmt.Println("║  Minicoin Blockchain (Stage 3: Transactions + UTXO)   ║")
@@ -80,73 +314,171 @@ func printMenu() {
 	fmt.Println("5. ブロックをマイニング")
 	fmt.Println("6. UTXOセット表示")
 	fmt.Println("7. チェーン検証")
-	fmt.Println("8. 終了")
+	fmt.Println("8. コインを送金")
+	fmt.Println("9. メモリプール表示")
+	fmt.Println("10. 報酬・発行量を表示")
+	fmt.Println("11. ウォレット一覧と残高")
+	fmt.Println("12. 使用するウォレットを切り替え")
+	fmt.Println("13. マルチシグ・タイムロックのアドレスを作成して入金")
+	fmt.Println("14. マルチシグ・タイムロックのアドレスから送金")
+	fmt.Println("15. 期限切れの送金を再送信")
+	fmt.Println("16. 手数料を上げて送金を置き換え (bumpfee)")
+	fmt.Println("17. ダッシュボード")
+	fmt.Println("18. 難易度調整の統計")
+	fmt.Println("19. 終了")
 	fmt.Println("====================================")
 }
 
-func loadOrCreateWallet() (*Wallet, error) {
-	// ウォレットファイルが存在するか確認
+// loadOrCreateWallets はwallets.datを読み込みます
+// 以前の形式のwallet.datがあれば取り込み、ウォレットが1つもなければ新しく作成して保存します
+func loadOrCreateWallets() (*Wallets, error) {
+	wallets, err := LoadWalletsFromFile(walletsFile)
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+
 	if _, err := os.Stat(walletFile); err == nil {
-		fmt.Println("📂 Loading existing wallet...")
 		wallet, err := LoadWalletFromFile(walletFile)
 		if err != nil {
-			fmt.Println("⚠️  Failed to load wallet, creating new one...")
-			return createAndSaveWallet()
+			fmt.Printf("⚠️  Failed to import %s: %v\n", walletFile, err)
+		} else if _, exists := wallets.Wallets[wallet.GetAddress()]; !exists {
+			wallets.AddWallet(wallet)
+			changed = true
+			fmt.Printf("📥 Imported %s into %s\n", walletFile, walletsFile)
 		}
-		fmt.Println("✅ Wallet loaded successfully!")
-		return wallet, nil
 	}
 
-	// 新しいウォレットを作成
-	fmt.Println("🆕 Creating new wallet...")
-	return createAndSaveWallet()
-}
+	if len(wallets.Wallets) == 0 {
+		fmt.Println("🆕 Creating new wallet...")
+		if _, err := wallets.CreateWallet(); err != nil {
+			return nil, err
+		}
+		changed = true
+	} else {
+		fmt.Printf("📂 Loaded %d wallet(s)\n", len(wallets.Wallets))
+	}
 
-func createAndSaveWallet() (*Wallet, error) {
-	wallet, err := NewWallet()
-	if err != nil {
-		return nil, err
+	if _, err := wallets.ActiveWallet(); err != nil {
+		wallets.Active = wallets.GetAddresses()[0]
+		changed = true
 	}
 
-	err = wallet.SaveToFile(walletFile)
-	if err != nil {
-		fmt.Printf("⚠️  Warning: Could not save wallet: %v\n", err)
-	} else {
-		fmt.Println("✅ Wallet created and saved!")
+	if changed {
+		if err := wallets.SaveToFile(walletsFile); err != nil {
+			fmt.Printf("⚠️  Warning: Could not save wallets: %v\n", err)
+		} else {
+			fmt.Println("✅ Wallets saved!")
+		}
+	}
+
+	if common.IsLegacyAddress(wallets.Active) {
+		fmt.Println("⚠️  This wallet uses a legacy hex address. Run `wallet migrate` to switch to Base58Check.")
 	}
+	return wallets, nil
+}
 
-	return wallet, nil
+// loadOrCreateWallet は使用中のウォレットを返します
+func loadOrCreateWallet() (*Wallet, error) {
+	wallets, err := loadOrCreateWallets()
+	if err != nil {
+		return nil, err
+	}
+	return wallets.ActiveWallet()
 }
 
-func displayBalance(wallet *Wallet, utxoSet *UTXOSet) {
-	balance := utxoSet.GetBalance(wallet.GetAddress())
+func displayBalance(wallet *Wallet, wallets *Wallets, mempool *Mempool) {
+	balance := wallet.GetTotalBalance(mempool)
 
 	fmt.Println("\n💰 Current Balance")
 	fmt.Println("────────────────────────────────────────────────────────")
-	fmt.Printf("Address: %s\n", wallet.GetAddress())
-	fmt.Printf("Balance: %d coins\n", balance)
+	fmt.Printf("Address:   %s\n", wallet.GetAddress())
+	fmt.Printf("Confirmed: %d coins\n", balance.Confirmed)
+	fmt.Printf("Immature:  %d coins (coinbase with fewer than %d confirmations)\n", balance.Immature, CoinbaseMaturity)
+	fmt.Printf("Pending:   +%d / -%d coins (mempool)\n", balance.PendingIn, balance.PendingOut)
+	fmt.Printf("Balance:   %d coins\n", balance.Total())
+	if len(wallets.Wallets) > 1 || wallets.KeyPool != nil {
+		fmt.Printf("All wallets: %s\n", wallets.GetTotalBalance(mempool))
+	}
 	fmt.Println("────────────────────────────────────────────────────────")
 }
 
-func createWallet() {
-	wallet, err := NewWallet()
+func createWallet(wallets *Wallets) {
+	address, err := wallets.CreateWallet()
 	if err != nil {
 		fmt.Printf("❌ Failed to create wallet: %v\n", err)
 		return
 	}
 
-	filename := fmt.Sprintf("wallet_%s.dat", wallet.GetAddress()[:8])
-	err = wallet.SaveToFile(filename)
-	if err != nil {
+	if err := wallets.SaveToFile(walletsFile); err != nil {
 		fmt.Printf("❌ Failed to save wallet: %v\n", err)
 		return
 	}
 
 	fmt.Println("\n✅ New Wallet Created!")
 	fmt.Println("────────────────────────────────────────────────────────")
-	fmt.Printf("Address: %s\n", wallet.GetAddress())
-	fmt.Printf("Saved to: %s\n", filename)
+	fmt.Printf("Address: %s\n", address)
+	fmt.Printf("Saved to: %s (%d wallets)\n", walletsFile, len(wallets.Wallets))
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Println("Switch to it with 12, or send coins to it with 8.")
+}
+
+func displayWallets(wallets *Wallets, utxoSet *UTXOSet) {
+	fmt.Println("\n👛 Local Wallets")
+	fmt.Println("────────────────────────────────────────────────────────")
+
+	total := 0
+	for i, address := range wallets.GetAddresses() {
+		marker := " "
+		if address == wallets.Active {
+			marker = "*"
+		}
+		balance := utxoSet.GetBalance(address)
+		total += balance
+		fmt.Printf("%s %2d. %s  %d coins\n", marker, i+1, address, balance)
+	}
+
 	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("Total: %d coins (* = active)\n", total)
+
+	if len(wallets.Labels) > 0 {
+		fmt.Println("\n📇 Address Book")
+		for _, address := range wallets.LabeledAddresses() {
+			fmt.Printf("   %-16s %s\n", wallets.Label(address), address)
+		}
+	}
+}
+
+// switchWallet は使用するウォレットを切り替えて保存し、切り替えたウォレットを返します
+func switchWallet(wallets *Wallets, scanner *bufio.Scanner) *Wallet {
+	for i, address := range wallets.GetAddresses() {
+		fmt.Printf("%2d. %s\n", i+1, address)
+	}
+	fmt.Print("ウォレット番号またはアドレス: ")
+	if !scanner.Scan() {
+		return nil
+	}
+
+	address, err := wallets.Resolve(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return nil
+	}
+	if err := wallets.SetActive(address); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return nil
+	}
+	if err := wallets.SaveToFile(walletsFile); err != nil {
+		fmt.Printf("⚠️  Warning: Could not save wallets: %v\n", err)
+	}
+
+	wallet, err := wallets.ActiveWallet()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return nil
+	}
+	fmt.Printf("✅ Now using %s\n", wallet.GetAddress())
+	return wallet
 }
 
 func displayChain(bc *Blockchain) {
@@ -167,17 +499,27 @@ func displayChain(bc *Blockchain) {
 	}
 }
 
-func displayTransactions(bc *Blockchain) {
+// displayTransactions はブロックごとのトランザクションと、各出力の受取先（アドレス帳のラベルがあればラベル）を表示します
+func displayTransactions(bc *Blockchain, wallets *Wallets) {
 	fmt.Println("\n📝 Transaction History")
 	fmt.Println("════════════════════════════════════════════════════════")
 
 	for _, block := range bc.Blocks {
 		fmt.Printf("\nBlock #%d:\n", block.Index)
 		for i, tx := range block.Transactions {
-			fmt.Printf("  [%d] TxID: %s\n", i, truncateHash(string(tx.ID)))
+			fmt.Printf("  [%d] TxID: %s\n", i, truncateHash(fmt.Sprintf("%x", tx.ID)))
 			fmt.Printf("      Inputs: %d, Outputs: %d\n", len(tx.Inputs), len(tx.Outputs))
 			if tx.IsCoinbase() {
 				fmt.Println("      Type: Coinbase (Mining Reward)")
+				reward := bc.Emission.RewardAt(block.Index)
+				fmt.Printf("      Reward: %d coins (%d + fees %d) to %s\n", tx.Outputs[0].Value, reward, tx.Outputs[0].Value-reward, historyAddress(wallets, tx.Outputs[0].Address()))
+				continue
+			}
+			if fee, err := bc.TransactionFee(tx); err == nil {
+				fmt.Printf("      Fee: %d coins (%d bytes)\n", fee, tx.Size())
+			}
+			for _, output := range tx.Outputs {
+				fmt.Printf("      → %d coins to %s\n", output.Value, historyAddress(wallets, output.Address()))
 			}
 		}
 	}
@@ -185,34 +527,135 @@ func displayTransactions(bc *Blockchain) {
 	fmt.Println("════════════════════════════════════════════════════════")
 }
 
-func mineBlock(bc *Blockchain, utxoSet *UTXOSet, wallet *Wallet) {
-	fmt.Println("\n⛏️  Mining new block...")
+// historyAddress は履歴に表示する受取先です。アドレス帳のラベルがあればアドレスの代わりにラベルを返します
+func historyAddress(wallets *Wallets, address string) string {
+	if address == "" {
+		return "(non-standard script)"
+	}
+	if label := wallets.Label(address); label != "" {
+		return label
+	}
+	if _, ok := wallets.Wallets[address]; ok {
+		return address + " (local wallet)"
+	}
+	return address
+}
 
-	// コインベーストランザクションを作成
-	coinbaseTx := NewCoinbaseTx(wallet.GetAddress(), fmt.Sprintf("Block %d reward", bc.GetChainLength()))
+func mineBlock(mempool *Mempool, wallet *Wallet) {
+	pending := mempool.Size()
+	expiredBefore := len(mempool.Expired())
+	difficulty := mempool.blockchain.NextDifficulty()
+	fmt.Printf("\n⛏️  Mining new block with %d pending transaction(s)...\n", pending)
 
-	// ブロックをマイニング
-	block, metrics, err := bc.MineBlock([]*Transaction{coinbaseTx})
+	// コインベーストランザクションとメモリプールのトランザクションをマイニング
+	block, metrics, err := mempool.MineBlock(wallet.GetAddress())
 	if err != nil {
 		fmt.Printf("❌ Mining failed: %v\n", err)
 		return
 	}
 
-	// UTXO更新
-	err = utxoSet.Update(block)
-	if err != nil {
-		fmt.Printf("⚠️  Warning: UTXO update failed: %v\n", err)
-	}
-
 	fmt.Println("\n✅ Block mined successfully!")
 	fmt.Println("────────────────────────────────────────────────────────")
 	fmt.Printf("Block #%d\n", block.Index)
 	fmt.Printf("Hash:       %s\n", truncateHash(block.Hash))
+	fmt.Printf("Txs:        %d (coinbase + %d, limit %d), %d left in mempool\n", len(block.Transactions), len(block.Transactions)-1, MaxBlockTransactions, mempool.Size())
+	fmt.Printf("Weight:     %d / %d (%.1f%%)\n", block.Weight(), MaxBlockWeight, float64(block.Weight())/MaxBlockWeight*100)
+	reward := mempool.blockchain.Emission.RewardAt(block.Index)
+	fmt.Printf("Reward:     %d coins (block reward %d + fees %d)\n", block.Transactions[0].Outputs[0].Value, reward, block.Transactions[0].Outputs[0].Value-reward)
+	fmt.Printf("Difficulty: %d\n", block.Difficulty)
 	fmt.Printf("Nonce:      %d\n", metrics.Nonce)
 	fmt.Printf("Attempts:   %d\n", metrics.Attempts)
 	fmt.Printf("Duration:   %s\n", metrics.Duration)
 	fmt.Printf("Hash Rate:  %.2f H/s\n", metrics.HashRate)
 	fmt.Println("────────────────────────────────────────────────────────")
+
+	// 難易度が調整された場合に通知
+	if next := mempool.blockchain.NextDifficulty(); next != difficulty {
+		stats := mempool.blockchain.GetDifficultyStats()
+		direction := "⬆️  blocks came faster than the target"
+		if next < difficulty {
+			direction = "⬇️  blocks came slower than the target"
+		}
+		fmt.Printf("🔧 Difficulty adjusted: %d → %d (%s: average %.1fs, target %ds)\n", difficulty, next, direction, stats.AverageBlockTime, stats.TargetBlockTime)
+	}
+
+	if expired := mempool.Expired(); len(expired) > expiredBefore {
+		fmt.Printf("⌛ %d transaction(s) expired from the mempool:\n", len(expired)-expiredBefore)
+		for _, entry := range expired[expiredBefore:] {
+			fmt.Printf("   %s\n", truncateHash(fmt.Sprintf("%x", entry.Tx.ID)))
+		}
+		fmt.Println("Resend them with 15.")
+	}
+}
+
+func displayMempool(mempool *Mempool) {
+	entries := mempool.Entries()
+
+	fmt.Println("\n📥 Mempool")
+	fmt.Println("════════════════════════════════════════════════════════")
+
+	if len(entries) == 0 {
+		fmt.Println("  No pending transactions.")
+	} else {
+		for i, entry := range entries {
+			total := 0
+			for _, output := range entry.Tx.Outputs {
+				total += output.Value
+			}
+			fmt.Printf("[%d] TxID: %s\n", i+1, truncateHash(fmt.Sprintf("%x", entry.Tx.ID)))
+			fmt.Printf("    Inputs: %d, Outputs: %d, Value: %d coins\n", len(entry.Tx.Inputs), len(entry.Tx.Outputs), total)
+			fmt.Printf("    Fee: %d coins, Size: %d bytes, Fee rate: %.4f coins/byte\n", entry.Fee, entry.Size, entry.FeeRate())
+			if pkg, ok := mempool.Package(entry.Tx.ID); ok && len(pkg.TxIDs) > 1 {
+				fmt.Printf("    Package: %d transaction(s) with unconfirmed ancestors, fee %d, rate %.4f coins/byte\n", len(pkg.TxIDs), pkg.Fee, pkg.FeeRate())
+			}
+		}
+	}
+
+	fmt.Println("────────────────────────────────────────────────────────")
+	stats := mempool.Stats()
+	fmt.Printf("Pending: %d transaction(s), %d coins in fees\n", stats.Count, stats.Fees)
+	if stats.MaxSize > 0 {
+		fmt.Printf("Usage:   %d / %d bytes (%.1f%%), %d evicted\n", stats.Size, stats.MaxSize, float64(stats.Size)/float64(stats.MaxSize)*100, stats.Evicted)
+	} else {
+		fmt.Printf("Usage:   %d bytes (no limit)\n", stats.Size)
+	}
+	fmt.Printf("Block:   up to %d transaction(s), weight %d (%d bytes)\n", MaxBlockTransactions, MaxBlockWeight, MaxBlockWeight/WitnessScaleFactor)
+	fmt.Printf("Expired: %d transaction(s) (after %d blocks or %s)\n", len(mempool.Expired()), mempool.ExpiryBlocks, mempool.Expiry)
+
+	// 直近のイベントログ
+	events := mempool.Events()
+	if len(events) > recentMempoolEvents {
+		events = events[len(events)-recentMempoolEvents:]
+	}
+	if len(events) > 0 {
+		fmt.Println("────────────────────────────────────────────────────────")
+		fmt.Println("Recent events:")
+		for _, event := range events {
+			fmt.Printf("  %s %-8s %s %s\n", event.Time.Format("15:04:05"), event.Type, truncateHash(event.TxID), event.Detail)
+		}
+	}
+	fmt.Println("════════════════════════════════════════════════════════")
+}
+
+func displaySupply(bc *Blockchain) {
+	schedule := bc.Emission
+	height := bc.GetLatestBlock().Index
+	emitted := schedule.TotalEmitted(height)
+	maxSupply := schedule.MaxSupply()
+
+	fmt.Println("\n🪙 Block Reward & Supply")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("Height:           %d\n", height)
+	fmt.Printf("Current reward:   %d coins\n", schedule.RewardAt(height))
+	fmt.Printf("Next block:       %d coins\n", schedule.RewardAt(height+1))
+	if next := schedule.NextHalving(height); schedule.RewardAt(next) < schedule.RewardAt(height) {
+		fmt.Printf("Next halving:     block #%d (in %d blocks) → %d coins\n", next, next-height, schedule.RewardAt(next))
+	}
+	fmt.Printf("Halving interval: every %d blocks\n", schedule.HalvingInterval)
+	fmt.Printf("Total emitted:    %d coins\n", emitted)
+	fmt.Printf("Remaining supply: %d coins\n", maxSupply-emitted)
+	fmt.Printf("Max supply:       %d coins (%.1f%% emitted)\n", maxSupply, float64(emitted)/float64(maxSupply)*100)
+	fmt.Println("────────────────────────────────────────────────────────")
 }
 
 func displayUTXOs(wallet *Wallet, utxoSet *UTXOSet) {
@@ -226,7 +669,7 @@ func displayUTXOs(wallet *Wallet, utxoSet *UTXOSet) {
 	} else {
 		total := 0
 		for i, utxo := range utxos {
-			fmt.Printf("[%d] TxID: %s\n", i+1, truncateHash(string(utxo.TxID)))
+			fmt.Printf("[%d] TxID: %s\n", i+1, truncateHash(fmt.Sprintf("%x", utxo.TxID)))
 			fmt.Printf("    Index: %d, Value: %d coins\n", utxo.OutIndex, utxo.Output.Value)
 			total += utxo.Output.Value
 		}
@@ -237,16 +680,57 @@ func displayUTXOs(wallet *Wallet, utxoSet *UTXOSet) {
 	fmt.Println("════════════════════════════════════════════════════════")
 }
 
-func validateChain(bc *Blockchain) {
+// displayDifficultyStats は難易度調整の統計を表示します
+func displayDifficultyStats(bc *Blockchain) {
+	stats := bc.GetDifficultyStats()
+
+	fmt.Println("\n📊 Difficulty Adjustment")
+	fmt.Println("════════════════════════════════════════════════════════")
+	fmt.Printf("Next Difficulty:    %d\n", stats.CurrentDifficulty)
+	if stats.TargetBlockTime == 0 {
+		fmt.Println("Retargeting:        disabled (fixed difficulty)")
+		fmt.Println("════════════════════════════════════════════════════════")
+		return
+	}
+	fmt.Printf("Target Block Time:  %d s\n", stats.TargetBlockTime)
+	if stats.AverageBlockTime > 0 {
+		ratio := stats.AverageBlockTime / float64(stats.TargetBlockTime)
+		status := "✓ on target"
+		if ratio > 1.2 {
+			status = "⚠️  slow"
+		} else if ratio < 0.8 {
+			status = "⚡ fast"
+		}
+		fmt.Printf("Average Block Time: %.2f s (%s, %.1fx the target)\n", stats.AverageBlockTime, status, ratio)
+	} else {
+		fmt.Println("Average Block Time: (not enough data)")
+	}
+	fmt.Printf("Adjustment:         every %d blocks, at most %.0fx per step (difficulty %d-%d)\n", stats.AdjustmentEvery, MaxAdjustmentFactor, MinDifficulty, MaxDifficulty)
+	fmt.Printf("Next Adjustment:    in %d block(s)\n", stats.NextAdjustment)
+	fmt.Printf("Chain Length:       %d blocks\n", bc.GetChainLength())
+	fmt.Println("════════════════════════════════════════════════════════")
+}
+
+func validateChain(bc *Blockchain, utxoSet *UTXOSet) {
 	fmt.Println("\n🔍 Validating blockchain...")
 
-	if bc.IsValid() {
+	if err := bc.Validate(); err != nil {
+		fmt.Println("❌ Blockchain is INVALID!")
+		fmt.Printf("   %v\n", err)
+	} else {
 		fmt.Println("✅ Blockchain is valid!")
-		fmt.Printf("   All %d blocks verified successfully.\n", bc.GetChainLength())
+		fmt.Printf("   All %d blocks and their transactions verified successfully.\n", bc.GetChainLength())
+	}
+
+	// 保存されたUTXOセットがチェーンから再構築したものと一致するか
+	if err := utxoSet.VerifyPersisted(bc); err != nil {
+		fmt.Printf("❌ UTXO set is inconsistent: %v\n", err)
 	} else {
-		fmt.Println("❌ Blockchain is INVALID!")
-		fmt.Println("   Chain integrity compromised.")
+		fmt.Printf("✅ Persisted UTXO set matches a fresh reindex (%s).\n", chainFile)
 	}
+
+	// 未使用の出力の合計が発行スケジュールと一致するか（インフレーションがないか）
+	printSupplyAudit(bc, utxoSet)
 }
 
 // Helper functions
@@ -0,0 +1,692 @@
+// Package main implements the transaction mempool for Stage 3.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrMissingInput は入力が参照する前トランザクションの出力が、チェーンにもメモリプールにも存在しないことを表します
+var ErrMissingInput = errors.New("missing input")
+
+// メモリプールの有効期限の既定値（どちらかを過ぎても取り込まれなければ期限切れ）
+const (
+	DefaultMempoolExpiryBlocks = 10               // 受け付けてから追加されたブロック数
+	DefaultMempoolExpiry       = 30 * time.Minute // 受け付けてからの経過時間
+)
+
+// maxMempoolEvents はイベントログに残すイベントの最大数（古いものから捨てる）
+const maxMempoolEvents = 100
+
+// メモリプールのイベントの種類
+const (
+	EventTxAdded    = "added"    // メモリプールに受け付けた
+	EventTxMined    = "mined"    // ブロックに取り込まれた
+	EventTxConflict = "conflict" // ブロック内のトランザクションと競合したため取り除いた
+	EventTxExpired  = "expired"  // 期限までに取り込まれなかったため取り除いた
+	EventTxResent   = "resent"   // 期限切れのトランザクションを作り直して再送信した
+	EventTxReplaced = "replaced" // 手数料の高い競合するトランザクションに置き換えた（RBF）
+)
+
+// MinReplacementFeeBump は置き換えるトランザクションが、置き換えられるものの手数料の合計より多く払う必要がある額です
+const MinReplacementFeeBump = 1
+
+// MempoolEvent はメモリプールで発生したイベントです
+type MempoolEvent struct {
+	Type   string
+	TxID   string // 対象のTxID(hex)
+	Detail string // 理由や関連するトランザクションなどの補足
+	Time   time.Time
+}
+
+// MempoolEntry はメモリプール内のトランザクションと手数料の情報です
+type MempoolEntry struct {
+	Tx      *Transaction
+	Fee     int       // 手数料（入力の合計 - 出力の合計）
+	Size    int       // シリアライズしたサイズ（バイト）
+	Height  int64     // 受け付けたときのチェーンの長さ（次のブロックの高さ）
+	AddedAt time.Time // 受け付けた時刻
+}
+
+// FeeRate は1バイトあたりの手数料を返します
+func (e *MempoolEntry) FeeRate() float64 {
+	return float64(e.Fee) / float64(e.Size)
+}
+
+// Mempool はブロックに取り込まれる前の検証済みトランザクションを保持します
+// 期限までに取り込まれなかったトランザクションは取り除き、再送信できるよう別に保持します
+type Mempool struct {
+	ExpiryBlocks int64         // この数のブロックが追加されても取り込まれなければ期限切れ（0なら無期限）
+	Expiry       time.Duration // この時間が経っても取り込まれなければ期限切れ（0なら無期限）
+	DustLimit    int           // この額未満の出力を含むトランザクションは受け入れない（ポリシー）
+	MinRelayFee  int           // 1000バイトあたりの最低手数料（ポリシー。0なら制限しない）
+	MaxSize      int           // 保持するトランザクションの合計サイズの上限（バイト。0なら制限しない）
+
+	blockchain *Blockchain
+	utxoSet    *UTXOSet
+	txs        map[string]*MempoolEntry // TxID(hex) -> エントリー
+	order      []string                 // 受け付けた順のTxID(hex)
+	spent      map[string]string        // 使用する出力 -> 使用するトランザクションのTxID(hex)
+	expired    []*MempoolEntry          // 期限切れで取り除いたエントリー（古い順）
+	events     []MempoolEvent           // イベントログ（古い順）
+	evicted    int                      // 上限を超えたため取り除いたトランザクションの数
+	mutex      sync.RWMutex
+}
+
+// NewMempool は既定の有効期限を持つ空のメモリプールを作成します
+func NewMempool(blockchain *Blockchain, utxoSet *UTXOSet) *Mempool {
+	return &Mempool{
+		ExpiryBlocks: DefaultMempoolExpiryBlocks,
+		Expiry:       DefaultMempoolExpiry,
+		DustLimit:    DefaultDustLimit,
+		MinRelayFee:  DefaultMinRelayFee,
+		MaxSize:      DefaultMempoolMaxSize,
+		blockchain:   blockchain,
+		utxoSet:      utxoSet,
+		txs:          make(map[string]*MempoolEntry),
+		spent:        make(map[string]string),
+	}
+}
+
+// outpointKey は出力を一意に表すキーを返します
+func outpointKey(txID []byte, outIndex int) string {
+	return hex.EncodeToString(txID) + ":" + strconv.Itoa(outIndex)
+}
+
+// Add は署名を検証し、二重支払いでなければトランザクションを受け付けます
+// 入力はUTXOセットに存在し、かつメモリプール内の他のトランザクションが使用していない必要があります
+// 出力の合計が入力の合計を超える（手数料が負になる）トランザクションも拒否します
+// コンセンサスのルールを満たしていても、ダストの出力を含むものや手数料が最低手数料に満たないものは
+// ポリシーとして拒否します（PolicyError）
+// メモリプール内のトランザクションと同じ出力を使う場合は、手数料が十分に高ければ置き換えます（RBF）
+// 入力はメモリプール内の未承認のトランザクションの出力でもかまいません（親子のトランザクション）
+// 合計サイズが上限を超えたら手数料率の低いものから取り除き、受け付けたトランザクション自身が取り除かれた場合は拒否します
+func (mp *Mempool) Add(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return fmt.Errorf("coinbase transaction cannot be added to mempool")
+	}
+	if len(tx.Inputs) == 0 {
+		return fmt.Errorf("transaction has no inputs")
+	}
+	if err := tx.CheckID(); err != nil {
+		return err
+	}
+	if err := tx.CheckAmounts(); err != nil {
+		return err
+	}
+
+	// 署名の検証はロックの外で行う（チェーンのロックを取得するため）
+	height := int64(mp.blockchain.GetChainLength())
+	if err := mp.blockchain.CheckLockHeight(tx); err != nil {
+		return err
+	}
+	// 署名を検証する前に、すべての入力の参照先が存在することを確認する
+	prevTxs, err := mp.previousTransactions(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.CheckVersion(prevTxs); err != nil {
+		return err
+	}
+	if err := tx.CheckTokens(prevTxs); err != nil {
+		return err
+	}
+	// 価値の保存はメモリプール内の未承認の出力も含めて、ロックの中で検証する
+	if err := tx.VerifyScripts(prevTxs); err != nil {
+		return fmt.Errorf("transaction signature verification failed")
+	}
+
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	id := hex.EncodeToString(tx.ID)
+	if _, exists := mp.txs[id]; exists {
+		return fmt.Errorf("transaction %s already in mempool", id)
+	}
+
+	seen := make(map[string]bool)
+	conflicts := make(map[string]string) // 競合するTxID(hex) -> 最初に競合した出力
+	fee := 0
+	for _, input := range tx.Inputs {
+		key := outpointKey(input.TxID, input.OutIndex)
+		if seen[key] {
+			return fmt.Errorf("double spend: %s is used twice in the transaction", key)
+		}
+		seen[key] = true
+
+		output, ok := mp.findOutputLocked(input.TxID, input.OutIndex)
+		if !ok {
+			return fmt.Errorf("double spend: %s is not an unspent output", key)
+		}
+		if other, ok := mp.spent[key]; ok {
+			if _, found := conflicts[other]; !found {
+				conflicts[other] = key
+			}
+		}
+		fee += output.Value
+	}
+	for _, output := range tx.Outputs {
+		fee -= output.Value
+	}
+	if fee < 0 {
+		return fmt.Errorf("outputs exceed inputs by %d", -fee)
+	}
+	if err := mp.checkReplacementLocked(tx, fee, conflicts); err != nil {
+		return err
+	}
+	if err := mp.checkPolicy(tx, fee); err != nil {
+		return err
+	}
+
+	detail := fmt.Sprintf("fee %d", fee)
+	for _, other := range sortedKeys(conflicts) {
+		if _, ok := mp.txs[other]; !ok {
+			continue // 先に置き換えた競合の子孫として取り除いた
+		}
+		for _, removed := range mp.descendantsLocked(other) {
+			mp.logLocked(EventTxReplaced, removed, fmt.Sprintf("replaced by %s (fee %d -> %d)", id, mp.txs[removed].Fee, fee))
+			mp.removeLocked(removed)
+			detail += ", replaces " + removed
+		}
+	}
+
+	mp.txs[id] = &MempoolEntry{Tx: tx, Fee: fee, Size: tx.Size(), Height: height, AddedAt: time.Now()}
+	mp.order = append(mp.order, id)
+	for key := range seen {
+		mp.spent[key] = id
+	}
+	mp.logLocked(EventTxAdded, id, detail)
+
+	for _, evicted := range mp.trimLocked() {
+		if evicted == id {
+			return mempoolFullError(tx, fee, mp.MaxSize)
+		}
+	}
+	return nil
+}
+
+// checkReplacementLocked は競合するトランザクションを置き換えられるか確認します（BIP125の手数料のルールを簡略化したもの）
+//   - 手数料が、置き換えられるトランザクション（とその子孫）の手数料の合計より MinReplacementFeeBump 以上多い
+//   - 手数料率が、置き換えられるどのトランザクションよりも高い
+//
+// 呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) checkReplacementLocked(tx *Transaction, fee int, conflicts map[string]string) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	// 置き換えられるトランザクションの子孫も一緒に取り除かれるため、その手数料も払う必要がある
+	replaced := make(map[string]bool)
+	for _, other := range sortedKeys(conflicts) {
+		for _, id := range mp.descendantsLocked(other) {
+			replaced[id] = true
+		}
+	}
+	for _, input := range tx.Inputs {
+		if replaced[hex.EncodeToString(input.TxID)] {
+			return fmt.Errorf("replacement spends an output of %x, which it replaces", input.TxID)
+		}
+	}
+
+	others := sortedKeys(conflicts)
+	replacedFees := 0
+	for id := range replaced {
+		replacedFees += mp.txs[id].Fee
+	}
+	if required := replacedFees + MinReplacementFeeBump; fee < required {
+		return fmt.Errorf("double spend: %s is already spent by %s in mempool (a replacement must pay a fee of at least %d, got %d)", conflicts[others[0]], others[0], required, fee)
+	}
+
+	size := tx.Size()
+	for _, other := range others {
+		entry := mp.txs[other]
+		if fee*entry.Size <= entry.Fee*size {
+			return fmt.Errorf("double spend: %s is already spent by %s in mempool (a replacement must pay a higher fee rate than %.4f)", conflicts[other], other, entry.FeeRate())
+		}
+	}
+	return nil
+}
+
+// sortedKeys はマップのキーを並べて返します（イベントログの順序を一定にするため）
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FindSpendableOutputs はメモリプール内のトランザクションがまだ使用していない出力から、
+// 指定金額を満たすものを検索します（ブロックを待たずに続けて送金するため）
+func (mp *Mempool) FindSpendableOutputs(address string, amount int) (int, map[string][]int) {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	unspentOutputs := make(map[string][]int)
+	accumulated := 0
+
+	for _, utxo := range mp.utxoSet.SpendableUTXOs(address) {
+		if _, pending := mp.spent[outpointKey(utxo.TxID, utxo.OutIndex)]; pending {
+			continue
+		}
+
+		txID := hex.EncodeToString(utxo.TxID)
+		unspentOutputs[txID] = append(unspentOutputs[txID], utxo.OutIndex)
+		accumulated += utxo.Output.Value
+
+		if accumulated >= amount {
+			break
+		}
+	}
+
+	return accumulated, unspentOutputs
+}
+
+// SpendableUTXOs は指定アドレスのUTXOのうち、メモリプール内のトランザクションがまだ使用していないものを返します
+func (mp *Mempool) SpendableUTXOs(address string) []UTXO {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	var utxos []UTXO
+	for _, utxo := range mp.utxoSet.SpendableUTXOs(address) {
+		if _, pending := mp.spent[outpointKey(utxo.TxID, utxo.OutIndex)]; !pending {
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos
+}
+
+// Size はメモリプール内のトランザクション数を返します
+func (mp *Mempool) Size() int {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	return len(mp.txs)
+}
+
+// Transactions はメモリプール内のトランザクションを受け付けた順に返します
+func (mp *Mempool) Transactions() []*Transaction {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	txs := make([]*Transaction, 0, len(mp.order))
+	for _, id := range mp.order {
+		txs = append(txs, mp.txs[id].Tx)
+	}
+	return txs
+}
+
+// Entries はメモリプール内のエントリーを受け付けた順に返します
+func (mp *Mempool) Entries() []MempoolEntry {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	entries := make([]MempoolEntry, 0, len(mp.order))
+	for _, id := range mp.order {
+		entries = append(entries, *mp.txs[id])
+	}
+	return entries
+}
+
+// Entry はメモリプール内のトランザクションのエントリーを返します（なければ false）
+func (mp *Mempool) Entry(txID []byte) (MempoolEntry, bool) {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	entry, ok := mp.txs[hex.EncodeToString(txID)]
+	if !ok {
+		return MempoolEntry{}, false
+	}
+	return *entry, true
+}
+
+// MempoolPackage はトランザクションと、まだ選ばれていない未承認の祖先をまとめたものです
+// 子は親がブロックに含まれなければ含められないため、親子をまとめた手数料率で選びます（CPFP）
+type MempoolPackage struct {
+	TxIDs []string // 受け付けた順（親が先）のTxID(hex)
+	Fee   int      // 手数料の合計
+	Size  int      // サイズの合計（バイト）
+}
+
+// FeeRate はパッケージ全体の1バイトあたりの手数料を返します
+func (p *MempoolPackage) FeeRate() float64 {
+	return float64(p.Fee) / float64(p.Size)
+}
+
+// Package はトランザクションと、メモリプール内のすべての未承認の祖先をまとめたパッケージを返します（なければ false）
+func (mp *Mempool) Package(txID []byte) (MempoolPackage, bool) {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	id := hex.EncodeToString(txID)
+	if _, ok := mp.txs[id]; !ok {
+		return MempoolPackage{}, false
+	}
+	return mp.packageLocked(id, nil), true
+}
+
+// SelectTransactions はパッケージ（トランザクションと未承認の祖先）の手数料率の高い順に、
+// 重さの合計が maxWeight 以下、数が maxCount 以下に収まるだけトランザクションを選び、手数料の合計とともに返します
+// 手数料の高い子は手数料の低い親を一緒に取り込みます（CPFP）。親は必ず子より前に並びます
+// 同じ手数料率なら先に受け付けたものを優先し、収まらないものは飛ばして次を試します
+func (mp *Mempool) SelectTransactions(maxWeight, maxCount int) ([]*Transaction, int) {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	included := make(map[string]bool)
+	var selected []*Transaction
+	fees, used := 0, 0
+	for {
+		var best *MempoolPackage
+		for _, id := range mp.order {
+			if included[id] {
+				continue
+			}
+			pkg := mp.packageLocked(id, included)
+			if used+pkg.Size*WitnessScaleFactor > maxWeight || len(selected)+len(pkg.TxIDs) > maxCount {
+				continue
+			}
+			// 浮動小数点の誤差を避けるため、fee_i/size_i > fee_j/size_j を掛け算で比較する
+			if best == nil || pkg.Fee*best.Size > best.Fee*pkg.Size {
+				best = &pkg
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		for _, id := range best.TxIDs {
+			included[id] = true
+			selected = append(selected, mp.txs[id].Tx)
+		}
+		fees += best.Fee
+		used += best.Size * WitnessScaleFactor
+	}
+
+	return selected, fees
+}
+
+// packageLocked はトランザクションと、excluded に含まれない未承認の祖先をまとめたパッケージを返します
+// 呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) packageLocked(id string, excluded map[string]bool) MempoolPackage {
+	members := map[string]bool{id: true}
+	stack := []string{id}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, input := range mp.txs[current].Tx.Inputs {
+			parent := hex.EncodeToString(input.TxID)
+			if _, pending := mp.txs[parent]; pending && !members[parent] && !excluded[parent] {
+				members[parent] = true
+				stack = append(stack, parent)
+			}
+		}
+	}
+
+	var pkg MempoolPackage
+	for _, other := range mp.order {
+		if members[other] {
+			pkg.TxIDs = append(pkg.TxIDs, other)
+			pkg.Fee += mp.txs[other].Fee
+			pkg.Size += mp.txs[other].Size
+		}
+	}
+	return pkg
+}
+
+// descendantsLocked はトランザクションと、その出力を（間接的に）使うメモリプール内の子孫を、親が先になる順に返します
+// 呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) descendantsLocked(id string) []string {
+	members := map[string]bool{id: true}
+	stack := []string{id}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for index := range mp.txs[current].Tx.Outputs {
+			if child, ok := mp.spent[outpointKey(mp.txs[current].Tx.ID, index)]; ok && !members[child] {
+				members[child] = true
+				stack = append(stack, child)
+			}
+		}
+	}
+
+	var descendants []string
+	for _, other := range mp.order {
+		if members[other] {
+			descendants = append(descendants, other)
+		}
+	}
+	return descendants
+}
+
+// previousTransactions は入力が参照する前トランザクションを、メモリプール内の未承認の親も含めて返します
+// 参照先のトランザクションまたは出力が見つからない入力があれば ErrMissingInput を返します
+func (mp *Mempool) previousTransactions(tx *Transaction) (map[string]*Transaction, error) {
+	prevTxs := make(map[string]*Transaction)
+
+	mp.mutex.RLock()
+	for _, input := range tx.Inputs {
+		id := hex.EncodeToString(input.TxID)
+		if entry, ok := mp.txs[id]; ok {
+			prevTxs[id] = entry.Tx
+		}
+	}
+	mp.mutex.RUnlock()
+
+	// チェーンのロックはメモリプールのロックの外で取得する
+	for _, input := range tx.Inputs {
+		id := hex.EncodeToString(input.TxID)
+		if _, ok := prevTxs[id]; ok {
+			continue
+		}
+		prevTx, err := mp.blockchain.FindTransaction(input.TxID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: previous transaction %s not found", ErrMissingInput, truncateHash(id))
+		}
+		prevTxs[id] = prevTx
+	}
+
+	for _, input := range tx.Inputs {
+		prevTx := prevTxs[hex.EncodeToString(input.TxID)]
+		if input.OutIndex < 0 || input.OutIndex >= len(prevTx.Outputs) {
+			return nil, fmt.Errorf("%w: %s has no output %d", ErrMissingInput, truncateHash(hex.EncodeToString(input.TxID)), input.OutIndex)
+		}
+	}
+	return prevTxs, nil
+}
+
+// FindOutput は未使用の出力を、UTXOセットとメモリプール内の未承認のトランザクションから探します
+// メモリプール内の別のトランザクションが使用する出力も返します
+func (mp *Mempool) FindOutput(txID []byte, outIndex int) (TxOutput, bool) {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	return mp.findOutputLocked(txID, outIndex)
+}
+
+// findOutputLocked は FindOutput と同じですが、呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) findOutputLocked(txID []byte, outIndex int) (TxOutput, bool) {
+	if output, ok := mp.utxoSet.FindOutput(txID, outIndex); ok {
+		return output, true
+	}
+	entry, ok := mp.txs[hex.EncodeToString(txID)]
+	if !ok || outIndex < 0 || outIndex >= len(entry.Tx.Outputs) {
+		return TxOutput{}, false
+	}
+	return entry.Tx.Outputs[outIndex], true
+}
+
+// Contains はトランザクションがメモリプールにあるかを返します
+func (mp *Mempool) Contains(txID []byte) bool {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	_, ok := mp.txs[hex.EncodeToString(txID)]
+	return ok
+}
+
+// RemoveBlock はブロックに取り込まれたトランザクションと、それらと競合するトランザクションを取り除きます
+func (mp *Mempool) RemoveBlock(block *Block) {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	for _, tx := range block.Transactions {
+		id := hex.EncodeToString(tx.ID)
+		if mp.removeLocked(id) {
+			mp.logLocked(EventTxMined, id, fmt.Sprintf("block %d", block.Index))
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			if other, ok := mp.spent[outpointKey(input.TxID, input.OutIndex)]; ok {
+				// 競合したトランザクションの出力を使う子孫も無効になる
+				for _, removed := range mp.descendantsLocked(other) {
+					mp.removeLocked(removed)
+					mp.logLocked(EventTxConflict, removed, fmt.Sprintf("input spent by %s in block %d", id, block.Index))
+				}
+			}
+		}
+	}
+}
+
+// Expire は期限までにブロックに取り込まれなかったトランザクションを取り除き、取り除いたエントリーを返します
+// 取り除いたエントリーは Expired で参照でき、ResendTransaction で作り直して再送信できます
+func (mp *Mempool) Expire(now time.Time) []MempoolEntry {
+	next := int64(mp.blockchain.GetChainLength())
+
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	var expired []MempoolEntry
+	for _, id := range append([]string(nil), mp.order...) {
+		entry, ok := mp.txs[id]
+		if !ok {
+			continue // 親と一緒に取り除いた
+		}
+
+		var reason string
+		switch {
+		case mp.ExpiryBlocks > 0 && next-entry.Height >= mp.ExpiryBlocks:
+			reason = fmt.Sprintf("not mined within %d blocks", mp.ExpiryBlocks)
+		case mp.Expiry > 0 && now.Sub(entry.AddedAt) >= mp.Expiry:
+			reason = fmt.Sprintf("not mined within %s", mp.Expiry)
+		default:
+			continue
+		}
+
+		// 子孫は親がなければブロックに含められないため、一緒に期限切れにする
+		for _, removed := range mp.descendantsLocked(id) {
+			removedEntry := mp.txs[removed]
+			mp.removeLocked(removed)
+			mp.expired = append(mp.expired, removedEntry)
+			if removed != id {
+				mp.logLocked(EventTxExpired, removed, fmt.Sprintf("parent %s expired", truncateHash(id)))
+			} else {
+				mp.logLocked(EventTxExpired, removed, reason)
+			}
+			expired = append(expired, *removedEntry)
+		}
+	}
+	return expired
+}
+
+// Expired は期限切れで取り除いたエントリーを古い順に返します
+func (mp *Mempool) Expired() []MempoolEntry {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	entries := make([]MempoolEntry, 0, len(mp.expired))
+	for _, entry := range mp.expired {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// forgetExpired は再送信した期限切れのエントリーを取り除き、イベントログに記録します
+func (mp *Mempool) forgetExpired(txID []byte, resent *Transaction) {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	for i, entry := range mp.expired {
+		if bytes.Equal(entry.Tx.ID, txID) {
+			mp.expired = append(mp.expired[:i], mp.expired[i+1:]...)
+			break
+		}
+	}
+	mp.logLocked(EventTxResent, hex.EncodeToString(txID), fmt.Sprintf("replaced by %s", hex.EncodeToString(resent.ID)))
+}
+
+// Events はイベントログを古い順に返します
+func (mp *Mempool) Events() []MempoolEvent {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	return append([]MempoolEvent(nil), mp.events...)
+}
+
+// logLocked はイベントログにイベントを追加します
+// 呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) logLocked(eventType, id, detail string) {
+	mp.events = append(mp.events, MempoolEvent{Type: eventType, TxID: id, Detail: detail, Time: time.Now()})
+	if len(mp.events) > maxMempoolEvents {
+		mp.events = mp.events[len(mp.events)-maxMempoolEvents:]
+	}
+}
+
+// removeLocked はトランザクションを1件取り除き、メモリプールにあったかを返します
+// 呼び出し側でロックを取得していることを前提とします
+func (mp *Mempool) removeLocked(id string) bool {
+	entry, ok := mp.txs[id]
+	if !ok {
+		return false
+	}
+
+	delete(mp.txs, id)
+	for _, input := range entry.Tx.Inputs {
+		delete(mp.spent, outpointKey(input.TxID, input.OutIndex))
+	}
+	for i, other := range mp.order {
+		if other == id {
+			mp.order = append(mp.order[:i], mp.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// MineBlock は手数料率の高いトランザクションをブロックの重さと数の上限（コインベースの分を除く）まで選び、
+// 発行スケジュールの報酬と手数料を受け取るコインベースと一緒にマイニングしてチェーンに追加し、
+// UTXOセットとメモリプールを更新します（選ばれなかったものはメモリプールに残り、期限を過ぎたものは取り除きます）
+func (mp *Mempool) MineBlock(minerAddress string) (*Block, *MiningMetrics, error) {
+	height := int64(mp.blockchain.GetChainLength())
+	data := fmt.Sprintf("Block %d reward", height)
+
+	// コインベースの大きさは報酬の額によらないため、先に重さを測ってその分を空けておく
+	reserved := NewCoinbaseTxAtHeight(minerAddress, data, 0, height).Weight()
+	selected, fees := mp.SelectTransactions(MaxBlockWeight-reserved, MaxBlockTransactions-1)
+	reward := mp.blockchain.Emission.RewardAt(height) + fees
+	coinbaseTx := NewCoinbaseTxAtHeight(minerAddress, data, reward, height)
+	transactions := append([]*Transaction{coinbaseTx}, selected...)
+
+	block, metrics, err := mp.blockchain.MineBlock(transactions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := mp.utxoSet.Update(block); err != nil {
+		return nil, nil, fmt.Errorf("failed to update utxo set: %w", err)
+	}
+	mp.RemoveBlock(block)
+	mp.Expire(time.Now())
+
+	return block, metrics, nil
+}
@@ -0,0 +1,591 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolAdd(t *testing.T) {
+	t.Run("検証済みのトランザクションを受け付ける", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		require.NoError(t, mempool.Add(tx))
+		assert.Equal(t, 1, mempool.Size())
+		assert.True(t, mempool.Contains(tx.ID))
+		assert.Equal(t, []*Transaction{tx}, mempool.Transactions())
+	})
+
+	t.Run("同じトランザクションは二重に追加できない", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		require.NoError(t, mempool.Add(tx))
+		assert.Error(t, mempool.Add(tx))
+		assert.Equal(t, 1, mempool.Size())
+	})
+
+	t.Run("メモリプール内の別トランザクションとの二重支払いを拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		// どちらもUTXOセットから同じ出力を選ぶ
+		tx1, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx2, err := NewTransaction(wallet, testAddressB, 30, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		require.NoError(t, mempool.Add(tx1))
+		err = mempool.Add(tx2)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "double spend")
+		assert.Equal(t, 1, mempool.Size())
+	})
+
+	t.Run("UTXOセットで使用済みの出力を拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		stale, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		// 同じ出力を使う送金を先にブロックに取り込む
+		_, _, err = SendCoins(mempool, wallet, testAddressB, 10, 0)
+		require.NoError(t, err)
+
+		err = mempool.Add(stale)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "double spend")
+	})
+
+	t.Run("署名が不正なトランザクションを拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Outputs[0].Value = 50 // 署名後の改ざん
+
+		assert.Error(t, mempool.Add(tx))
+		assert.Equal(t, 0, mempool.Size())
+	})
+
+	t.Run("IDが内容と一致しないトランザクションを拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.LockTime = 1 // ID を計算し直さずに内容を変える
+
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match its contents")
+		assert.Equal(t, 0, mempool.Size())
+	})
+
+	t.Run("存在しない出力を参照する入力は署名の検証より先に拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		unknown := *tx
+		input := tx.Inputs[0]
+		input.TxID = make([]byte, 32)
+		unknown.Inputs = []TxInput{input}
+		unknown.ID = unknown.Hash()
+		err = mempool.Add(&unknown)
+		require.ErrorIs(t, err, ErrMissingInput)
+		assert.NotContains(t, err.Error(), "signature")
+
+		outOfRange := *tx
+		input = tx.Inputs[0]
+		input.OutIndex = 5
+		outOfRange.Inputs = []TxInput{input}
+		outOfRange.ID = outOfRange.Hash()
+		assert.ErrorIs(t, mempool.Add(&outOfRange), ErrMissingInput)
+		assert.Equal(t, 0, mempool.Size())
+	})
+
+	t.Run("コインベーストランザクションは拒否", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+
+		assert.Error(t, mempool.Add(NewCoinbaseTx(wallet.GetAddress(), "free coins")))
+	})
+}
+
+func TestMempoolMineBlock(t *testing.T) {
+	t.Run("保留中のトランザクションをブロックに取り込む", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, mempool, bc)
+		require.NoError(t, err)
+		require.NoError(t, mempool.Add(tx))
+
+		block, metrics, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		require.NotNil(t, metrics)
+
+		require.Len(t, block.Transactions, 2)
+		assert.True(t, block.Transactions[0].IsCoinbase())
+		assert.Equal(t, tx.ID, block.Transactions[1].ID)
+		assert.Equal(t, 0, mempool.Size())
+		assert.Equal(t, 20, utxoSet.GetBalance(testAddressA))
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("空のメモリプールでもコインベースのみのブロックを作れる", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		block, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+
+		assert.Len(t, block.Transactions, 1)
+		assert.Equal(t, 2, bc.GetChainLength())
+		assert.Equal(t, 100, utxoSet.GetBalance(wallet.GetAddress()))
+	})
+}
+
+func TestMempoolRemoveBlock(t *testing.T) {
+	t.Run("ブロックと競合するトランザクションも取り除く", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		pending, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		require.NoError(t, mempool.Add(pending))
+
+		// 同じ出力を使う別のトランザクションが他のノードでブロックに入った
+		conflicting, err := NewTransaction(wallet, testAddressB, 30, 0, utxoSet, bc)
+		require.NoError(t, err)
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "other miner"), conflicting})
+		require.NoError(t, err)
+
+		mempool.RemoveBlock(block)
+		assert.Equal(t, 0, mempool.Size())
+		assert.False(t, mempool.Contains(pending.ID))
+	})
+}
+
+// fundWallet はウォレットにコインベース報酬のUTXOをn個追加します
+func fundWallet(t *testing.T, bc *Blockchain, utxoSet *UTXOSet, wallet *Wallet, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), fmt.Sprintf("funding %d", i))})
+		require.NoError(t, err)
+		require.NoError(t, utxoSet.Update(block))
+	}
+}
+
+func TestMempoolFees(t *testing.T) {
+	t.Run("受け付け時に手数料を計算する", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 3, mempool, bc)
+		require.NoError(t, err)
+		require.NoError(t, mempool.Add(tx))
+
+		entries := mempool.Entries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, 3, entries[0].Fee)
+		assert.Equal(t, tx.Size(), entries[0].Size)
+		assert.InDelta(t, 3/float64(tx.Size()), entries[0].FeeRate(), 1e-12)
+	})
+
+	t.Run("出力が入力を超えるトランザクションを拒否", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Outputs[0].Value = 100
+		tx.ID = tx.Hash()
+		require.NoError(t, bc.SignTransaction(tx, wallet))
+
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outputs exceed inputs")
+	})
+
+	t.Run("手数料率の高い順に選ぶ", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 2)
+
+		low, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 1)
+		require.NoError(t, err)
+		high, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 9)
+		require.NoError(t, err)
+		mid, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 4)
+		require.NoError(t, err)
+
+		selected, fees := mempool.SelectTransactions(MaxBlockWeight, MaxBlockTransactions)
+		assert.Equal(t, []*Transaction{high, mid, low}, selected)
+		assert.Equal(t, 14, fees)
+	})
+
+	t.Run("重さの上限に収まるだけ選ぶ", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+
+		low, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 1)
+		require.NoError(t, err)
+		high, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 9)
+		require.NoError(t, err)
+
+		selected, fees := mempool.SelectTransactions(high.Weight(), MaxBlockTransactions)
+		assert.Equal(t, []*Transaction{high}, selected)
+		assert.Equal(t, 9, fees)
+
+		selected, _ = mempool.SelectTransactions(high.Weight()+low.Weight(), MaxBlockTransactions)
+		assert.Len(t, selected, 2)
+	})
+
+	t.Run("マイナーが手数料を受け取る", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+		miner, err := NewWallet()
+		require.NoError(t, err)
+
+		_, err = SubmitTransaction(mempool, wallet, testAddressA, 20, 6)
+		require.NoError(t, err)
+
+		block, _, err := mempool.MineBlock(miner.GetAddress())
+		require.NoError(t, err)
+
+		assert.Equal(t, InitialBlockReward+6, block.Transactions[0].Outputs[0].Value)
+		assert.Equal(t, InitialBlockReward+6, mempool.utxoSet.GetBalance(miner.GetAddress()))
+		// 送金元: 50 - 20 - 6
+		assert.Equal(t, 24, mempool.utxoSet.GetBalance(wallet.GetAddress()))
+		assert.True(t, bc.IsValid())
+	})
+}
+
+func TestMempoolExpire(t *testing.T) {
+	t.Run("指定したブロック数で取り込まれなければ期限切れ", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		mempool.ExpiryBlocks = 2
+
+		tx, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+
+		// メモリプールを通さずにブロックを追加する
+		fundWallet(t, bc, utxoSet, wallet, 1)
+		assert.Empty(t, mempool.Expire(time.Now()))
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(testAddressB, "another block")})
+		require.NoError(t, err)
+		require.NoError(t, utxoSet.Update(block))
+
+		expired := mempool.Expire(time.Now())
+		require.Len(t, expired, 1)
+		assert.Equal(t, tx, expired[0].Tx)
+		assert.Equal(t, 0, mempool.Size())
+		assert.Len(t, mempool.Expired(), 1)
+
+		// 使っていた出力は再び使える
+		_, err = SubmitTransaction(mempool, wallet, testAddressB, 100, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("指定した時間で取り込まれなければ期限切れ", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		mempool.Expiry = time.Minute
+
+		_, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+
+		assert.Empty(t, mempool.Expire(time.Now()))
+		assert.Len(t, mempool.Expire(time.Now().Add(time.Minute)), 1)
+	})
+
+	t.Run("0なら期限なし", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		mempool.ExpiryBlocks = 0
+		mempool.Expiry = 0
+
+		_, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		fundWallet(t, bc, utxoSet, wallet, DefaultMempoolExpiryBlocks)
+
+		assert.Empty(t, mempool.Expire(time.Now().Add(24*time.Hour)))
+		assert.Equal(t, 1, mempool.Size())
+	})
+
+	t.Run("マイニング時に期限切れを取り除く", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		mempool.ExpiryBlocks = 1
+
+		// ブロックに収まらない送金は取り込まれずに期限切れになる
+		var txs []*Transaction
+		fundWallet(t, bc, utxoSet, wallet, MaxBlockTransactions-1) // ジェネシスと合わせて MaxBlockTransactions 個のUTXO
+		for len(txs) < MaxBlockTransactions {
+			tx, err := SubmitTransaction(mempool, wallet, testAddressA, 1, 0)
+			require.NoError(t, err)
+			txs = append(txs, tx)
+		}
+
+		block, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		require.Less(t, len(block.Transactions)-1, len(txs))
+		assert.Equal(t, 0, mempool.Size())
+		assert.Len(t, mempool.Expired(), len(txs)-(len(block.Transactions)-1))
+	})
+}
+
+func TestMempoolEvents(t *testing.T) {
+	t.Run("受け付け・取り込み・期限切れを記録する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		mempool.ExpiryBlocks = 2
+
+		mined, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+
+		stale, err := SubmitTransaction(mempool, wallet, testAddressB, 20, 1)
+		require.NoError(t, err)
+		fundWallet(t, bc, utxoSet, wallet, 2)
+		mempool.Expire(time.Now())
+
+		var types []string
+		for _, event := range mempool.Events() {
+			types = append(types, event.Type)
+		}
+		assert.Equal(t, []string{EventTxAdded, EventTxMined, EventTxAdded, EventTxExpired}, types)
+
+		events := mempool.Events()
+		assert.Equal(t, fmt.Sprintf("%x", mined.ID), events[1].TxID)
+		assert.Equal(t, fmt.Sprintf("%x", stale.ID), events[3].TxID)
+		assert.Contains(t, events[3].Detail, "2 blocks")
+	})
+
+	t.Run("イベントログは上限を超えると古いものから捨てる", func(t *testing.T) {
+		_, _, _, mempool := newTestChain(t)
+
+		for i := 0; i < maxMempoolEvents+5; i++ {
+			mempool.logLocked(EventTxAdded, fmt.Sprintf("%d", i), "")
+		}
+		events := mempool.Events()
+		require.Len(t, events, maxMempoolEvents)
+		assert.Equal(t, "5", events[0].TxID)
+	})
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	t.Run("手数料の高い競合するトランザクションで置き換える", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		original, err := NewTransaction(wallet, testAddressA, 20, 1, utxoSet, bc)
+		require.NoError(t, err)
+		replacement, err := NewTransaction(wallet, testAddressB, 20, 5, utxoSet, bc)
+		require.NoError(t, err)
+
+		require.NoError(t, mempool.Add(original))
+		require.NoError(t, mempool.Add(replacement))
+		assert.False(t, mempool.Contains(original.ID))
+		assert.True(t, mempool.Contains(replacement.ID))
+		assert.Equal(t, 1, mempool.Size())
+
+		events := mempool.Events()
+		require.Len(t, events, 3)
+		assert.Equal(t, EventTxReplaced, events[1].Type)
+		assert.Equal(t, fmt.Sprintf("%x", original.ID), events[1].TxID)
+		assert.Contains(t, events[1].Detail, "fee 1 -> 5")
+		assert.Equal(t, EventTxAdded, events[2].Type)
+		assert.Contains(t, events[2].Detail, fmt.Sprintf("replaces %x", original.ID))
+
+		_, _, err = mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, 20, utxoSet.GetBalance(testAddressB))
+		assert.Equal(t, 0, utxoSet.GetBalance(testAddressA))
+	})
+
+	t.Run("手数料の増分が足りなければ二重支払いとして拒否する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		original, err := NewTransaction(wallet, testAddressA, 20, 3, utxoSet, bc)
+		require.NoError(t, err)
+		same, err := NewTransaction(wallet, testAddressB, 20, 3, utxoSet, bc)
+		require.NoError(t, err)
+
+		require.NoError(t, mempool.Add(original))
+		err = mempool.Add(same)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "double spend")
+		assert.Contains(t, err.Error(), "at least 4")
+		assert.True(t, mempool.Contains(original.ID))
+	})
+
+	t.Run("複数のトランザクションを置き換えるには手数料の合計より多く払う", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+
+		// 50コインのUTXOを1つずつ使う2つの送金
+		first, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 3)
+		require.NoError(t, err)
+		second, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 3)
+		require.NoError(t, err)
+
+		// 両方のUTXOを使う送金は、手数料の合計6より多く払えば両方を置き換える
+		cheap, err := NewTransaction(wallet, testAddressB, 60, 6, utxoSet, bc)
+		require.NoError(t, err)
+		assert.Error(t, mempool.Add(cheap))
+
+		replacement, err := NewTransaction(wallet, testAddressB, 60, 10, utxoSet, bc)
+		require.NoError(t, err)
+		require.NoError(t, mempool.Add(replacement))
+		assert.False(t, mempool.Contains(first.ID))
+		assert.False(t, mempool.Contains(second.ID))
+		assert.Equal(t, 1, mempool.Size())
+	})
+}
+
+// newChildTx はメモリプール内の親の出力を使う（未承認の出力を使う）送金を作ります
+func newChildTx(t *testing.T, wallet *Wallet, parent *Transaction, outIndex int, to string, amount int) *Transaction {
+	t.Helper()
+
+	output, err := newOutput(to, amount)
+	require.NoError(t, err)
+	tx := &Transaction{
+		Version:   CurrentTxVersion,
+		Inputs:    []TxInput{{TxID: parent.ID, OutIndex: outIndex}},
+		Outputs:   []TxOutput{output},
+		Timestamp: time.Now().Unix(),
+	}
+	tx.ID = tx.Hash()
+	require.NoError(t, tx.Sign(wallet, map[string]*Transaction{fmt.Sprintf("%x", parent.ID): parent}))
+	return tx
+}
+
+func TestMempoolChainedSpends(t *testing.T) {
+	t.Run("未承認の親の出力を使う送金を受け付ける", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+
+		// 親: 10をAへ、おつり40を送金元へ（手数料0）
+		parent, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 0)
+		require.NoError(t, err)
+		child := newChildTx(t, wallet, parent, 1, testAddressB, 30)
+		require.NoError(t, mempool.Add(child))
+
+		entry, ok := mempool.Entry(child.ID)
+		require.True(t, ok)
+		assert.Equal(t, 10, entry.Fee)
+
+		// おつりは子が使うので、残高はAとBへの送金額と手数料だけ減る
+		balance := wallet.GetTotalBalance(mempool)
+		assert.Equal(t, 40, balance.PendingIn)
+		assert.Equal(t, 90, balance.PendingOut)
+
+		// 同じ未承認の出力は二重に使えない
+		other := newChildTx(t, wallet, parent, 1, testAddressA, 30)
+		assert.Error(t, mempool.Add(other))
+	})
+
+	t.Run("存在しない親の出力を使う送金は拒否する", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+
+		output, err := newOutput(wallet.GetAddress(), 40)
+		require.NoError(t, err)
+		missing := &Transaction{ID: []byte("missing"), Outputs: []TxOutput{output}}
+		child := newChildTx(t, wallet, missing, 0, testAddressB, 30)
+		assert.Error(t, mempool.Add(child))
+	})
+}
+
+func TestChildPaysForParent(t *testing.T) {
+	// newCPFPFixture は手数料0の親と、その出力を使う手数料10の子、手数料3の無関係な送金をメモリプールに入れます
+	newCPFPFixture := func(t *testing.T) (*Wallet, *Mempool, *Transaction, *Transaction, *Transaction) {
+		t.Helper()
+
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+
+		parent, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 0)
+		require.NoError(t, err)
+		unrelated, err := SubmitTransaction(mempool, wallet, testAddressA, 10, 3)
+		require.NoError(t, err)
+		child := newChildTx(t, wallet, parent, 1, testAddressB, 30)
+		require.NoError(t, mempool.Add(child))
+		return wallet, mempool, parent, child, unrelated
+	}
+
+	t.Run("パッケージの手数料率は親子の合計で計算する", func(t *testing.T) {
+		_, mempool, parent, child, _ := newCPFPFixture(t)
+
+		pkg, ok := mempool.Package(child.ID)
+		require.True(t, ok)
+		assert.Equal(t, []string{fmt.Sprintf("%x", parent.ID), fmt.Sprintf("%x", child.ID)}, pkg.TxIDs)
+		assert.Equal(t, 10, pkg.Fee)
+		assert.Equal(t, parent.Size()+child.Size(), pkg.Size)
+
+		pkg, ok = mempool.Package(parent.ID)
+		require.True(t, ok)
+		assert.Len(t, pkg.TxIDs, 1)
+	})
+
+	t.Run("手数料の高い子が手数料0の親を取り込む", func(t *testing.T) {
+		_, mempool, parent, child, unrelated := newCPFPFixture(t)
+
+		// 2つ分しか入らない大きさでは、単独の手数料率が高い送金より親子のパッケージを選ぶ
+		selected, fees := mempool.SelectTransactions(parent.Weight()+child.Weight(), MaxBlockTransactions)
+		require.Len(t, selected, 2)
+		assert.Equal(t, parent.ID, selected[0].ID, "親は子より前に並ぶ")
+		assert.Equal(t, child.ID, selected[1].ID)
+		assert.Equal(t, 10, fees)
+
+		// すべて入るなら、パッケージの後に無関係な送金が続く
+		selected, fees = mempool.SelectTransactions(MaxBlockWeight, MaxBlockTransactions)
+		require.Len(t, selected, 3)
+		assert.Equal(t, unrelated.ID, selected[2].ID)
+		assert.Equal(t, 13, fees)
+	})
+
+	t.Run("親子を同じブロックに取り込める", func(t *testing.T) {
+		wallet, mempool, _, _, _ := newCPFPFixture(t)
+
+		block, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		assert.Len(t, block.Transactions, 4)
+		assert.Equal(t, 0, mempool.Size())
+		assert.NoError(t, mempool.blockchain.Validate())
+
+		utxoSet := mempool.utxoSet
+		assert.Equal(t, 20, utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, 30, utxoSet.GetBalance(testAddressB))
+
+		// 同じブロック内で使った出力は、再構築したUTXOセットにも残らない
+		rebuilt := NewUTXOSet(mempool.blockchain)
+		assert.Equal(t, utxoSet.GetBalance(wallet.GetAddress()), rebuilt.GetBalance(wallet.GetAddress()))
+		assert.Equal(t, 30, rebuilt.GetBalance(testAddressB))
+	})
+
+	t.Run("親を置き換えると子も取り除き、置き換えは子の手数料も払う", func(t *testing.T) {
+		wallet, mempool, parent, child, _ := newCPFPFixture(t)
+
+		cheap, err := NewTransaction(wallet, testAddressB, 10, 5, mempool.utxoSet, mempool.blockchain)
+		require.NoError(t, err)
+		require.Equal(t, parent.Inputs[0].TxID, cheap.Inputs[0].TxID)
+		err = mempool.Add(cheap)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "at least 11")
+
+		replacement, err := NewTransaction(wallet, testAddressB, 10, 11, mempool.utxoSet, mempool.blockchain)
+		require.NoError(t, err)
+		require.NoError(t, mempool.Add(replacement))
+		assert.False(t, mempool.Contains(parent.ID))
+		assert.False(t, mempool.Contains(child.ID))
+	})
+
+	t.Run("親が期限切れになると子も期限切れにする", func(t *testing.T) {
+		_, mempool, parent, child, _ := newCPFPFixture(t)
+		mempool.Expiry = time.Minute
+
+		expired := mempool.Expire(time.Now().Add(time.Hour))
+		require.Len(t, expired, 3)
+		assert.Equal(t, parent.ID, expired[0].Tx.ID)
+		assert.Equal(t, child.ID, expired[1].Tx.ID)
+		assert.Equal(t, 0, mempool.Size())
+	})
+}
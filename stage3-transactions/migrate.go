@@ -0,0 +1,28 @@
+// Package main implements wallet file migration for Stage 3.
+package main
+
+import (
+	"github.com/nyasuto/minicoin/common"
+)
+
+// MigrateWalletFile はウォレットファイルのアドレスを公開鍵から導出し直して保存します
+// 旧形式（16進数）のアドレスはBase58Check形式になり、すでに新形式ならファイルは書き換えません
+// 戻り値: (移行前のアドレス, 移行後のアドレス, エラー)
+func MigrateWalletFile(filename string) (string, string, error) {
+	wallet, err := LoadWalletFromFile(filename)
+	if err != nil {
+		return "", "", err
+	}
+
+	oldAddress := wallet.GetAddress()
+	newAddress := common.PublicKeyToAddress(wallet.PublicKey)
+	if oldAddress == newAddress {
+		return oldAddress, newAddress, nil
+	}
+
+	wallet.Address = newAddress
+	if err := wallet.SaveToFile(filename); err != nil {
+		return "", "", err
+	}
+	return oldAddress, newAddress, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveLegacyWallet は旧形式（16進数）のアドレスを持つウォレットファイルを作成します
+func saveLegacyWallet(t *testing.T) (*Wallet, string) {
+	t.Helper()
+
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+	wallet.Address = common.LegacyPublicKeyToAddress(wallet.PublicKey)
+
+	filename := filepath.Join(t.TempDir(), "wallet.dat")
+	require.NoError(t, wallet.SaveToFile(filename))
+	return wallet, filename
+}
+
+func TestMigrateWalletFile(t *testing.T) {
+	t.Run("旧形式のアドレスをBase58Checkに書き換える", func(t *testing.T) {
+		wallet, filename := saveLegacyWallet(t)
+
+		oldAddress, newAddress, err := MigrateWalletFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, wallet.GetAddress(), oldAddress)
+		assert.Equal(t, common.PublicKeyToAddress(wallet.PublicKey), newAddress)
+
+		loaded, err := LoadWalletFromFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, newAddress, loaded.GetAddress())
+		assert.Equal(t, wallet.PrivateKey.D, loaded.PrivateKey.D)
+	})
+
+	t.Run("移行済みのウォレットは変わらない", func(t *testing.T) {
+		_, filename := saveLegacyWallet(t)
+		_, _, err := MigrateWalletFile(filename)
+		require.NoError(t, err)
+
+		oldAddress, newAddress, err := MigrateWalletFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, oldAddress, newAddress)
+	})
+
+	t.Run("存在しないファイルはエラー", func(t *testing.T) {
+		_, _, err := MigrateWalletFile(filepath.Join(t.TempDir(), "missing.dat"))
+		assert.Error(t, err)
+	})
+}
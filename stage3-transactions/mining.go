@@ -36,9 +36,13 @@ func MineBlock(block *Block) (*MiningMetrics, error) {
 	startTime := time.Now()
 	attempts := int64(0)
 
+	// マークルルートはナンスによらないため、ヘッダーを一度だけ作ってナンスだけを変える
+	header := block.Header()
+
 	// マイニング: 難易度を満たすハッシュを見つける
 	for {
-		hash := block.CalculateHashWithNonce()
+		header.Nonce = block.Nonce
+		hash := header.CalculateHash()
 		attempts++
 
 		if CheckHashDifficulty(hash, block.Difficulty) {
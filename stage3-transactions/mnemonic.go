@@ -0,0 +1,101 @@
+// Package main implements BIP39 mnemonic backup phrases for Stage 3.
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// BIP39の定数
+const (
+	MnemonicSeedLen    = 64   // ニーモニックから導くシードの長さ（バイト）
+	mnemonicIterations = 2048 // PBKDF2の繰り返し回数
+	mnemonicWordBits   = 11   // 1単語が表すビット数（2048語）
+)
+
+// bip39English はBIP39の英語の単語リスト（2048語）です
+//
+//go:embed bip39_english.txt
+var bip39English string
+
+var (
+	mnemonicWords     = strings.Fields(bip39English)
+	mnemonicWordIndex = func() map[string]int {
+		index := make(map[string]int, len(mnemonicWords))
+		for i, word := range mnemonicWords {
+			index[word] = i
+		}
+		return index
+	}()
+)
+
+// NewMnemonic はエントロピーからニーモニック（単語の並び）を作成します
+// エントロピーは16〜32バイトで4の倍数の長さが必要です（16バイトで12語、32バイトで24語）
+// 末尾にはSHA-256から取ったチェックサムのビットが入るため、単語の書き間違いを検出できます
+func NewMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("entropy must be 16 to 32 bytes in multiples of 4, got %d", len(entropy))
+	}
+
+	// エントロピーの後ろにチェックサム（エントロピーのビット数/32ビット）を付ける
+	checksumBits := len(entropy) * 8 / 32
+	hash := sha256.Sum256(entropy)
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, uint(checksumBits))
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	// 11ビットずつ単語に変換する（下位から取り出すので後ろから埋める）
+	count := (len(entropy)*8 + checksumBits) / mnemonicWordBits
+	words := make([]string, count)
+	mask := big.NewInt(1<<mnemonicWordBits - 1)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = mnemonicWords[new(big.Int).And(data, mask).Int64()]
+		data.Rsh(data, mnemonicWordBits)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy はニーモニックの単語とチェックサムを検証し、元のエントロピーを返します
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("mnemonic must be 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+
+	data := new(big.Int)
+	for i, word := range words {
+		index, ok := mnemonicWordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("word %d %q is not in the wordlist", i+1, word)
+		}
+		data.Lsh(data, mnemonicWordBits)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) * mnemonicWordBits / 33
+	entropyLen := checksumBits * 32 / 8
+	checksum := new(big.Int).And(data, big.NewInt(1<<checksumBits-1)).Int64()
+	data.Rsh(data, uint(checksumBits))
+	entropy := data.FillBytes(make([]byte, entropyLen))
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return nil, fmt.Errorf("mnemonic checksum mismatch (a word may be wrong or out of order)")
+	}
+	return entropy, nil
+}
+
+// MnemonicToSeed はニーモニックとパスフレーズからHDウォレット用の64バイトのシードを導きます
+// PBKDF2-HMAC-SHA512（ソルトは "mnemonic" + パスフレーズ、2048回）を使用します
+// 教育用のため、Unicodeの正規化（NFKD）は行わずASCIIの入力を前提とします
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), mnemonicIterations, MnemonicSeedLen, sha512.New)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMnemonicWordlist(t *testing.T) {
+	// BIP39の english.txt のCRC32
+	assert.Equal(t, "c1dbd296", fmt.Sprintf("%x", crc32.ChecksumIEEE([]byte(bip39English))))
+	assert.Len(t, mnemonicWords, 2048)
+}
+
+func TestMnemonicVectors(t *testing.T) {
+	// BIP39のテストベクター（パスフレーズは "TREZOR"）
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			seed:     "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			entropy:  "808080808080808080808080808080808080808080808080",
+			mnemonic: "letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always",
+			seed:     "107d7c02a5aa6f38c58083ff74f04c607c2d2c0ecc55501dadd72d025b751bc27fe913ffb796f841c49b1d33b610cf0e91d3aa239027f5e99fe4ce9e5088cd65",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title",
+			seed:     "bc09fca1804f7e69da93c2f2028eb238c227f2e9dda30cd63699232578480a4021b146ad717fbb7e451ce9eb835f43620bf5c514db0f8add49f5d121449d3e87",
+		},
+		{
+			entropy:  "77c2b00716cec7213839159e404db50d",
+			mnemonic: "jelly better achieve collect unaware mountain thought cargo oxygen act hood bridge",
+			seed:     "b5b6d0127db1a9d2226af0c3346031d77af31e918dba64287a1b44b8ebf63cdd52676f672a290aae502472cf2d602c051f3e6f18055e84e4c43897fc4e51a6ff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entropy, func(t *testing.T) {
+			entropy, err := hex.DecodeString(tt.entropy)
+			require.NoError(t, err)
+
+			mnemonic, err := NewMnemonic(entropy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.mnemonic, mnemonic)
+
+			restored, err := MnemonicToEntropy(mnemonic)
+			require.NoError(t, err)
+			assert.Equal(t, entropy, restored)
+
+			assert.Equal(t, tt.seed, hex.EncodeToString(MnemonicToSeed(mnemonic, "TREZOR")))
+		})
+	}
+}
+
+func TestMnemonicToEntropy(t *testing.T) {
+	valid := "legal winner thank year wave sausage worth useful legal winner thank yellow"
+
+	t.Run("余分な空白は無視", func(t *testing.T) {
+		_, err := MnemonicToEntropy("  legal winner thank year wave sausage\tworth useful legal winner thank yellow ")
+		assert.NoError(t, err)
+	})
+
+	t.Run("単語リストにない単語", func(t *testing.T) {
+		_, err := MnemonicToEntropy(strings.Replace(valid, "thank", "thanks", 1))
+		assert.ErrorContains(t, err, "word 3")
+	})
+
+	t.Run("単語の入れ替えはチェックサムで検出", func(t *testing.T) {
+		_, err := MnemonicToEntropy("winner legal thank year wave sausage worth useful legal winner thank yellow")
+		assert.ErrorContains(t, err, "checksum")
+	})
+
+	t.Run("単語数が不正", func(t *testing.T) {
+		_, err := MnemonicToEntropy("legal winner thank")
+		assert.Error(t, err)
+	})
+
+	t.Run("エントロピーの長さが不正", func(t *testing.T) {
+		_, err := NewMnemonic(make([]byte, 15))
+		assert.Error(t, err)
+	})
+}
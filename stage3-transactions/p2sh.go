@@ -0,0 +1,523 @@
+// Package main implements Pay-to-Script-Hash (P2SH) outputs for Stage 3.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/elliptic"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// P2SHの定数
+const (
+	MaxMultisigKeys = 15 // 1つのスクリプトに含められる公開鍵の最大数
+	scriptPubKeyLen = 64 // スクリプト内の公開鍵の長さ（publicKeyToBytes形式）
+	maxLockHeight   = 1<<32 - 1
+)
+
+// RedeemScript はP2SHの出力を使うための条件（償還スクリプト）です
+// 出力にはこのスクリプトのハッシュだけを記録し、使うときに入力でスクリプト本体と署名を示します
+type RedeemScript struct {
+	Required   int      // 必要な署名数（m）
+	PubKeys    [][]byte // 署名できる公開鍵（n個、publicKeyToBytes形式）
+	LockHeight int      // このブロック高さ以降でないと使えない（0なら制限なし）
+}
+
+// NewMultisigScript はn個の公開鍵のうちm個の署名を要求するスクリプトを作成します
+func NewMultisigScript(required int, pubKeys [][]byte) (*RedeemScript, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxMultisigKeys {
+		return nil, fmt.Errorf("multisig needs 1 to %d public keys, got %d", MaxMultisigKeys, len(pubKeys))
+	}
+	if required < 1 || required > len(pubKeys) {
+		return nil, fmt.Errorf("required signatures must be 1 to %d, got %d", len(pubKeys), required)
+	}
+
+	keys := make([][]byte, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		if len(pubKey) != scriptPubKeyLen {
+			return nil, fmt.Errorf("public key %d must be %d bytes, got %d", i+1, scriptPubKeyLen, len(pubKey))
+		}
+		key, err := bytesToPublicKey(pubKey)
+		if err != nil {
+			return nil, fmt.Errorf("public key %d: %w", i+1, err)
+		}
+		if !elliptic.P256().IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("public key %d is not on the curve", i+1)
+		}
+		for _, other := range keys[:i] {
+			if bytes.Equal(other, pubKey) {
+				return nil, fmt.Errorf("public key %d is duplicated", i+1)
+			}
+		}
+		keys[i] = append([]byte(nil), pubKey...)
+	}
+
+	return &RedeemScript{Required: required, PubKeys: keys}, nil
+}
+
+// NewTimelockScript は指定したブロック高さ以降に1つの公開鍵の署名で使えるスクリプトを作成します
+func NewTimelockScript(pubKey []byte, lockHeight int) (*RedeemScript, error) {
+	script, err := NewMultisigScript(1, [][]byte{pubKey})
+	if err != nil {
+		return nil, err
+	}
+	return script.WithLockHeight(lockHeight)
+}
+
+// WithLockHeight はスクリプトに使用可能になるブロック高さを設定したコピーを返します
+func (s *RedeemScript) WithLockHeight(lockHeight int) (*RedeemScript, error) {
+	if lockHeight < 0 || lockHeight > maxLockHeight {
+		return nil, fmt.Errorf("lock height must be 0 to %d, got %d", maxLockHeight, lockHeight)
+	}
+	locked := *s
+	locked.LockHeight = lockHeight
+	return &locked, nil
+}
+
+// Serialize はスクリプトを実行できる形式にします
+// [<ロック高さ> OP_CHECKLOCKTIMEVERIFY OP_DROP] OP_m <公開鍵1>...<公開鍵n> OP_n OP_CHECKMULTISIG
+// スクリプトハッシュの計算に使うため、同じスクリプトからは常に同じバイト列になります
+func (s *RedeemScript) Serialize() []byte {
+	var script Script
+	if s.LockHeight > 0 {
+		script = script.AddInt(int64(s.LockHeight)).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop)
+	}
+	script = script.AddInt(int64(s.Required))
+	for _, pubKey := range s.PubKeys {
+		script = script.AddData(pubKey)
+	}
+	return script.AddInt(int64(len(s.PubKeys))).AddOp(OpCheckMultisig)
+}
+
+// ParseRedeemScript は入力に含まれるバイト列からスクリプトを復元し、内容を検証します
+// Serialize が作る形式以外のスクリプトはエラーになります
+func ParseRedeemScript(data []byte) (*RedeemScript, error) {
+	ops, err := Script(data).parse()
+	if err != nil {
+		return nil, fmt.Errorf("redeem script: %w", err)
+	}
+
+	lockHeight := int64(0)
+	if len(ops) >= 3 && ops[1].opcode == OpCheckLockTimeVerify && ops[2].opcode == OpDrop {
+		if !ops[0].isPush() {
+			return nil, fmt.Errorf("redeem script lock height is not a number")
+		}
+		if lockHeight, err = decodeScriptNum(ops[0].pushValue(), maxLockTimeNumLen); err != nil {
+			return nil, fmt.Errorf("redeem script lock height: %w", err)
+		}
+		if lockHeight <= 0 {
+			return nil, fmt.Errorf("redeem script lock height must be positive, got %d", lockHeight)
+		}
+		ops = ops[3:]
+	}
+
+	// OP_m <公開鍵>... OP_n OP_CHECKMULTISIG
+	if len(ops) < 4 || ops[len(ops)-1].opcode != OpCheckMultisig ||
+		!isSmallInt(ops[0].opcode) || !isSmallInt(ops[len(ops)-2].opcode) {
+		return nil, fmt.Errorf("redeem script is not a multisig script")
+	}
+	count := int(ops[len(ops)-2].opcode-Op1) + 1
+	keyOps := ops[1 : len(ops)-2]
+	if len(keyOps) != count {
+		return nil, fmt.Errorf("redeem script has %d keys, want %d", len(keyOps), count)
+	}
+
+	pubKeys := make([][]byte, count)
+	for i, op := range keyOps {
+		if op.data == nil {
+			return nil, fmt.Errorf("redeem script key %d is not data", i+1)
+		}
+		pubKeys[i] = op.data
+	}
+	script, err := NewMultisigScript(int(ops[0].opcode-Op1)+1, pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	return script.WithLockHeight(int(lockHeight))
+}
+
+// isSmallInt はオペコードが OP_1〜OP_16 かを返します
+func isSmallInt(opcode byte) bool {
+	return opcode >= Op1 && opcode <= Op16
+}
+
+// Hash はスクリプトハッシュ RIPEMD160(SHA256(スクリプト)) を返します
+func (s *RedeemScript) Hash() []byte {
+	return common.PublicKeyHash(s.Serialize())
+}
+
+// Address はスクリプトのP2SHアドレス（3で始まる）を返します
+func (s *RedeemScript) Address() string {
+	return common.ScriptHashToAddress(s.Hash())
+}
+
+// String は "2-of-3 multisig" や "timelock until block 10" のような表記を返します
+func (s *RedeemScript) String() string {
+	if len(s.PubKeys) == 1 && s.LockHeight > 0 {
+		return fmt.Sprintf("timelock until block %d", s.LockHeight)
+	}
+	desc := fmt.Sprintf("%d-of-%d multisig", s.Required, len(s.PubKeys))
+	if s.LockHeight > 0 {
+		desc += fmt.Sprintf(", locked until block %d", s.LockHeight)
+	}
+	return desc
+}
+
+// keyIndex は公開鍵がスクリプトの何番目かを返します（含まれなければ-1）
+func (s *RedeemScript) keyIndex(pubKey []byte) int {
+	for i, key := range s.PubKeys {
+		if bytes.Equal(key, pubKey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// redeemScriptFor は入力の scriptSig（OP_0 <署名>... <償還スクリプト>）から償還スクリプトと署名を取り出し、
+// スクリプトが出力のスクリプトハッシュと一致するか検証します
+func redeemScriptFor(input TxInput, prevOutput TxOutput) (*RedeemScript, [][]byte, error) {
+	class, scriptHash := prevOutput.ScriptPubKey.classify()
+	if class != p2shScript {
+		return nil, nil, fmt.Errorf("output is not a P2SH output")
+	}
+
+	pushes, err := input.ScriptSig.pushes()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pushes) < 2 || len(pushes[0]) != 0 {
+		return nil, nil, fmt.Errorf("scriptSig must be OP_0 <signatures>... <redeem script>")
+	}
+	redeemScript := pushes[len(pushes)-1]
+	if !bytes.Equal(common.PublicKeyHash(redeemScript), scriptHash) {
+		return nil, nil, fmt.Errorf("redeem script does not match script hash %x", scriptHash)
+	}
+
+	script, err := ParseRedeemScript(redeemScript)
+	if err != nil {
+		return nil, nil, err
+	}
+	return script, pushes[1 : len(pushes)-1], nil
+}
+
+// scriptSigFor は署名を公開鍵の順に並べたP2SHの scriptSig を作成します（未署名の公開鍵は飛ばします）
+// OP_CHECKMULTISIG が余分に取り出す値のため、先頭に OP_0 を置きます
+func scriptSigFor(script *RedeemScript, signatures [][]byte) Script {
+	scriptSig := Script{}.AddOp(Op0)
+	for _, signature := range signatures {
+		if signature != nil {
+			scriptSig = scriptSig.AddData(signature)
+		}
+	}
+	return scriptSig.AddData(script.Serialize())
+}
+
+// signScriptInput はP2SHのi番目の入力に、ウォレットの署名を公開鍵の位置に合わせて追加します
+// ウォレットが署名者でなければ false を返します。必要数の署名が揃っていれば何もしません
+func (tx *Transaction) signScriptInput(i int, prevOutput TxOutput, signer Signer) (bool, error) {
+	script, signatures, err := redeemScriptFor(tx.Inputs[i], prevOutput)
+	if err != nil {
+		return false, err
+	}
+	keyIndex := script.keyIndex(publicKeyToBytes(signer.Public()))
+	if keyIndex < 0 {
+		return false, nil // このウォレットは署名者ではない
+	}
+
+	// 既存の署名がどの公開鍵のものかを調べ、公開鍵の順に並べる
+	checker := newTxSigChecker(tx, i, prevOutput)
+	slots := make([][]byte, len(script.PubKeys))
+	for _, signature := range signatures {
+		matched := false
+		for k, pubKey := range script.PubKeys {
+			if slots[k] == nil && checker.CheckSig(signature, pubKey) {
+				slots[k], matched = signature, true
+				break
+			}
+		}
+		if !matched {
+			return false, fmt.Errorf("scriptSig has a signature that matches no key of the script")
+		}
+	}
+	if slots[keyIndex] != nil || len(signatures) >= script.Required {
+		return true, nil
+	}
+
+	signature, err := signer.Sign(checker.hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	slots[keyIndex] = signature
+	tx.Inputs[i].ScriptSig = scriptSigFor(script, slots)
+	return true, nil
+}
+
+// NewScriptSpend はP2SHの出力から送金する未署名のトランザクションを作成します
+// スクリプトにロック高さがあれば、トランザクションのロック時刻をその高さにします
+// おつりは同じスクリプトのアドレスに戻します。署名はスクリプトの署名者がそれぞれ Blockchain.SignTransaction で追加します
+func NewScriptSpend(script *RedeemScript, to string, amount, fee int, utxoSet SpendableOutputFinder) (*Transaction, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if fee < 0 {
+		return nil, fmt.Errorf("fee must not be negative")
+	}
+	output, err := newOutput(to, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	selection, err := SelectCoins(utxoSet.SpendableUTXOs(script.Address()), amount+fee, DefaultCoinSelection)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, err := inputsFromSpendable(selection.spendable())
+	if err != nil {
+		return nil, err
+	}
+	// 署名がまだない scriptSig（OP_0 <償還スクリプト>）を入れておく
+	for i := range inputs {
+		inputs[i].ScriptSig = scriptSigFor(script, nil)
+	}
+
+	outputs := []TxOutput{output}
+	if selection.Change() > 0 {
+		outputs = append(outputs, TxOutput{Value: selection.Change(), ScriptPubKey: NewP2SHScript(script.Hash())})
+	}
+
+	// タイムロックのスクリプトは、ロック高さ以降のブロックにしか含められないトランザクションでしか使えない
+	tx := &Transaction{
+		Version:   CurrentTxVersion,
+		Inputs:    inputs,
+		Outputs:   outputs,
+		Timestamp: time.Now().Unix(),
+		LockTime:  int64(script.LockHeight),
+	}
+	tx.ID = tx.Hash()
+	return tx, nil
+}
+
+// SignatureProgress はP2SHの入力に集まった署名の数と必要な数を返します
+// 複数の入力がある場合は、最も署名が足りない入力の値を返します
+func (bc *Blockchain) SignatureProgress(tx *Transaction) (int, int, error) {
+	prevTxs, err := bc.previousTransactions(tx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	signed, required := -1, 0
+	for _, input := range tx.Inputs {
+		output, ok := previousOutput(input, prevTxs)
+		if !ok {
+			return 0, 0, fmt.Errorf("previous output not found")
+		}
+		if class, _ := output.ScriptPubKey.classify(); class != p2shScript {
+			continue
+		}
+		script, signatures, err := redeemScriptFor(input, output)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		count := len(signatures)
+		if signed < 0 || script.Required-count > required-signed {
+			signed, required = count, script.Required
+		}
+	}
+	if signed < 0 {
+		return 0, 0, fmt.Errorf("transaction has no script inputs")
+	}
+	return signed, required, nil
+}
+
+// fundScript はローカルのウォレットからマルチシグやタイムロックのスクリプトを作成し、使用中のウォレットから入金します
+func fundScript(mempool *Mempool, wallets *Wallets, wallet *Wallet, scanner *bufio.Scanner) {
+	for i, address := range wallets.GetAddresses() {
+		fmt.Printf("%2d. %s\n", i+1, address)
+	}
+	fmt.Print("署名者のウォレット（番号またはアドレスをカンマ区切り）: ")
+	if !scanner.Scan() {
+		return
+	}
+	var signers []string
+	for _, field := range strings.Split(scanner.Text(), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			signers = append(signers, field)
+		}
+	}
+
+	fmt.Print("必要な署名数: ")
+	if !scanner.Scan() {
+		return
+	}
+	required, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		fmt.Println("❌ Invalid number. Please enter a whole number.")
+		return
+	}
+
+	fmt.Print("使用可能になるブロック高さ（空ならロックなし）: ")
+	if !scanner.Scan() {
+		return
+	}
+	lockHeight := 0
+	if input := strings.TrimSpace(scanner.Text()); input != "" {
+		if lockHeight, err = strconv.Atoi(input); err != nil {
+			fmt.Println("❌ Invalid height. Please enter a whole number.")
+			return
+		}
+	}
+
+	address, err := wallets.CreateScript(required, signers, lockHeight)
+	if err != nil {
+		fmt.Printf("❌ Failed to create script: %v\n", err)
+		return
+	}
+	if err := wallets.SaveToFile(walletsFile); err != nil {
+		fmt.Printf("⚠️  Warning: Could not save wallets: %v\n", err)
+	}
+	script, _ := wallets.GetScript(address)
+
+	fmt.Print("入金額: ")
+	if !scanner.Scan() {
+		return
+	}
+	amount, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		fmt.Println("❌ Invalid amount. Please enter a whole number.")
+		return
+	}
+
+	// P2SHのアドレスは通常の送金先として使える
+	tx, err := SubmitTransaction(mempool, wallet, address, amount, DefaultTransactionFee)
+	if err != nil {
+		fmt.Printf("❌ Deposit failed: %v\n", err)
+		return
+	}
+
+	fmt.Printf("\n🔐 %s created and funded!\n", script)
+	fmt.Println("────────────────────────────────────────────────────────")
+	printSentTransaction(tx, address, amount, DefaultTransactionFee)
+	fmt.Printf("Mempool:    %d pending transaction(s)\n", mempool.Size())
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Println("Anyone can send to this address; spend it with 14 once it is confirmed.")
+}
+
+// spendScript はP2SHのアドレスから送金するトランザクションを作成し、ローカルのウォレットで署名を集めます
+// 必要な数の署名が集まったらメモリプールに追加します
+func spendScript(mempool *Mempool, utxoSet *UTXOSet, wallets *Wallets, scanner *bufio.Scanner) {
+	addresses := wallets.GetScriptAddresses()
+	if len(addresses) == 0 {
+		fmt.Println("❌ No script addresses yet. Create one with 13.")
+		return
+	}
+	for i, address := range addresses {
+		script := wallets.Scripts[address]
+		fmt.Printf("%2d. %s  %s  %d coins\n", i+1, address, script, utxoSet.GetBalance(address))
+	}
+	fmt.Print("スクリプトの番号またはアドレス: ")
+	if !scanner.Scan() {
+		return
+	}
+	from := strings.TrimSpace(scanner.Text())
+	if n, err := strconv.Atoi(from); err == nil {
+		if n < 1 || n > len(addresses) {
+			fmt.Printf("❌ script number must be 1 to %d, got %d\n", len(addresses), n)
+			return
+		}
+		from = addresses[n-1]
+	}
+	script, err := wallets.GetScript(from)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+
+	fmt.Print("送金先アドレス（ローカルのウォレットは一覧の番号）: ")
+	if !scanner.Scan() {
+		return
+	}
+	to := strings.TrimSpace(scanner.Text())
+	if _, err := strconv.Atoi(to); err == nil {
+		if to, err = wallets.Resolve(to); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+	}
+	if err := common.ValidateAddress(to); err != nil {
+		printAddressError(err)
+		return
+	}
+
+	fmt.Print("送金額: ")
+	if !scanner.Scan() {
+		return
+	}
+	amount, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		fmt.Println("❌ Invalid amount. Please enter a whole number.")
+		return
+	}
+
+	tx, err := NewScriptSpend(script, to, amount, DefaultTransactionFee, mempool)
+	if err != nil {
+		fmt.Printf("❌ Send failed: %v\n", err)
+		return
+	}
+
+	// ローカルの署名者に順に署名してもらう
+	signers := wallets.Signers(script)
+	fmt.Printf("\n✍️  Collecting signatures for %s\n", script)
+	for i, signer := range signers {
+		fmt.Printf("%2d. %s\n", i+1, signer.GetAddress())
+	}
+	for {
+		signed, required, err := mempool.blockchain.SignatureProgress(tx)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+		fmt.Printf("Signatures: %d/%d\n", signed, required)
+		if signed >= required {
+			break
+		}
+
+		fmt.Print("署名するウォレットの番号（空で中止）: ")
+		if !scanner.Scan() {
+			return
+		}
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			fmt.Println("❌ Not enough signatures. Transaction discarded.")
+			return
+		}
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 1 || n > len(signers) {
+			fmt.Printf("❌ Signer number must be 1 to %d\n", len(signers))
+			continue
+		}
+		if err := mempool.blockchain.SignTransaction(tx, signers[n-1]); err != nil {
+			fmt.Printf("❌ Signing failed: %v\n", err)
+			continue
+		}
+		fmt.Printf("✅ Signed by %s\n", signers[n-1].GetAddress())
+	}
+
+	if err := mempool.Add(tx); err != nil {
+		fmt.Printf("❌ Send failed: %v\n", err)
+		return
+	}
+
+	fmt.Println("\n📥 Transaction added to mempool!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	printSentTransaction(tx, to, amount, DefaultTransactionFee)
+	fmt.Printf("Mempool:    %d pending transaction(s)\n", mempool.Size())
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Println("Mine a block (5) to confirm it.")
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPubKeys(t *testing.T, n int) [][]byte {
+	t.Helper()
+
+	var pubKeys [][]byte
+	for i := 0; i < n; i++ {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		pubKeys = append(pubKeys, publicKeyToBytes(wallet.PublicKey))
+	}
+	return pubKeys
+}
+
+func TestRedeemScript(t *testing.T) {
+	pubKeys := testPubKeys(t, 3)
+
+	t.Run("m-of-nのスクリプトとP2SHアドレス", func(t *testing.T) {
+		script, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+
+		assert.Equal(t, "2-of-3 multisig", script.String())
+		assert.True(t, strings.HasPrefix(script.Address(), "3"))
+		assert.True(t, common.IsScriptAddress(script.Address()))
+		assert.NoError(t, common.ValidateAddress(script.Address()))
+		assert.Equal(t, 1, script.keyIndex(pubKeys[1]))
+
+		// 同じ鍵と署名数なら同じアドレス、署名数が違えば別のアドレス
+		same, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		assert.Equal(t, script.Address(), same.Address())
+		other, err := NewMultisigScript(3, pubKeys)
+		require.NoError(t, err)
+		assert.NotEqual(t, script.Address(), other.Address())
+	})
+
+	t.Run("タイムロックのスクリプト", func(t *testing.T) {
+		script, err := NewTimelockScript(pubKeys[0], 10)
+		require.NoError(t, err)
+		assert.Equal(t, "timelock until block 10", script.String())
+
+		unlocked, err := NewTimelockScript(pubKeys[0], 0)
+		require.NoError(t, err)
+		assert.NotEqual(t, script.Address(), unlocked.Address())
+
+		locked, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		locked, err = locked.WithLockHeight(5)
+		require.NoError(t, err)
+		assert.Equal(t, "2-of-3 multisig, locked until block 5", locked.String())
+
+		_, err = NewTimelockScript(pubKeys[0], -1)
+		assert.Error(t, err)
+	})
+
+	t.Run("シリアライズして復元できる", func(t *testing.T) {
+		script, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		script, err = script.WithLockHeight(300)
+		require.NoError(t, err)
+
+		parsed, err := ParseRedeemScript(script.Serialize())
+		require.NoError(t, err)
+		assert.Equal(t, script, parsed)
+
+		_, err = ParseRedeemScript(script.Serialize()[:10])
+		assert.Error(t, err)
+		_, err = ParseRedeemScript(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("不正な組み合わせはエラー", func(t *testing.T) {
+		_, err := NewMultisigScript(0, pubKeys)
+		assert.Error(t, err)
+		_, err = NewMultisigScript(4, pubKeys)
+		assert.Error(t, err)
+		_, err = NewMultisigScript(1, nil)
+		assert.Error(t, err)
+		_, err = NewMultisigScript(2, [][]byte{pubKeys[0], pubKeys[0]})
+		assert.Error(t, err)
+		_, err = NewMultisigScript(1, [][]byte{make([]byte, 64)})
+		assert.Error(t, err)
+	})
+}
+
+func TestScriptFunding(t *testing.T) {
+	t.Run("P2SHへの入金はスクリプトハッシュだけを記録する", func(t *testing.T) {
+		script, err := NewMultisigScript(2, testPubKeys(t, 3))
+		require.NoError(t, err)
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err = SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, 30, utxoSet.GetBalance(script.Address()))
+		// 入金元: おつり20 + マイニング報酬50
+		assert.Equal(t, 70, utxoSet.GetBalance(wallet.GetAddress()))
+		assert.True(t, bc.IsValid())
+
+		utxos := utxoSet.FindUTXO(script.Address())
+		require.Len(t, utxos, 1)
+		assert.Equal(t, NewP2SHScript(script.Hash()), utxos[0].Output.ScriptPubKey)
+
+		// 差分更新の結果はチェーン全体からの再構築と一致する
+		assert.Equal(t, 30, NewUTXOSet(bc).GetBalance(script.Address()))
+	})
+}
+
+func TestScriptSpend(t *testing.T) {
+	// 3つのウォレットで作る2-of-3の償還スクリプト。サブテストごとに新しいチェーンから30コイン入金する
+	var signers []*Wallet
+	var pubKeys [][]byte
+	for i := 0; i < 3; i++ {
+		signer, err := NewWallet()
+		require.NoError(t, err)
+		signers = append(signers, signer)
+		pubKeys = append(pubKeys, publicKeyToBytes(signer.PublicKey))
+	}
+	script, err := NewMultisigScript(2, pubKeys)
+	require.NoError(t, err)
+
+	t.Run("必要数の署名が集まれば送金できる", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(script, testAddressA, 10, 1, mempool)
+		require.NoError(t, err)
+
+		signed, required, err := bc.SignatureProgress(tx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, signed)
+		assert.Equal(t, 2, required)
+		assert.False(t, bc.VerifyTransaction(tx))
+
+		require.NoError(t, bc.SignTransaction(tx, signers[0]))
+		signed, _, err = bc.SignatureProgress(tx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, signed)
+		assert.False(t, bc.VerifyTransaction(tx))
+		assert.Error(t, mempool.Add(tx))
+
+		require.NoError(t, bc.SignTransaction(tx, signers[2]))
+		signed, _, err = bc.SignatureProgress(tx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, signed)
+		assert.True(t, bc.VerifyTransaction(tx))
+
+		require.NoError(t, mempool.Add(tx))
+		_, _, err = mempool.MineBlock(signers[1].GetAddress())
+		require.NoError(t, err)
+
+		assert.Equal(t, 10, utxoSet.GetBalance(testAddressA))
+		// おつりは同じP2SHアドレスに戻る: 30 - 10 - 手数料1
+		assert.Equal(t, 19, utxoSet.GetBalance(script.Address()))
+		assert.True(t, bc.IsValid())
+	})
+
+	t.Run("署名者でないウォレットは署名できない", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+		outsider, err := NewWallet()
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(script, testAddressA, 10, 0, mempool)
+		require.NoError(t, err)
+		assert.Error(t, bc.SignTransaction(tx, outsider))
+	})
+
+	t.Run("スクリプトハッシュと一致しない償還スクリプトは無効", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(script, testAddressA, 10, 0, mempool)
+		require.NoError(t, err)
+		require.NoError(t, bc.SignTransaction(tx, signers[0]))
+		require.NoError(t, bc.SignTransaction(tx, signers[1]))
+		require.True(t, bc.VerifyTransaction(tx))
+
+		// 1人の署名で使える別のスクリプトにすり替える
+		weaker, err := NewMultisigScript(1, script.PubKeys)
+		require.NoError(t, err)
+		pushes, err := tx.Inputs[0].ScriptSig.pushes()
+		require.NoError(t, err)
+		tx.Inputs[0].ScriptSig = scriptSigFor(weaker, pushes[1:len(pushes)-1])
+		assert.False(t, bc.VerifyTransaction(tx))
+		assert.Error(t, bc.SignTransaction(tx, signers[0]))
+	})
+
+	t.Run("署名後に改ざんすると検証に失敗", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(script, testAddressA, 10, 0, mempool)
+		require.NoError(t, err)
+		require.NoError(t, bc.SignTransaction(tx, signers[0]))
+		require.NoError(t, bc.SignTransaction(tx, signers[1]))
+		require.True(t, bc.VerifyTransaction(tx))
+
+		tx.Outputs[0].Value = 25
+		assert.False(t, bc.VerifyTransaction(tx))
+	})
+
+	t.Run("公開鍵と異なる順に並べた署名は無効", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(script, testAddressA, 10, 0, mempool)
+		require.NoError(t, err)
+		require.NoError(t, bc.SignTransaction(tx, signers[0]))
+		require.NoError(t, bc.SignTransaction(tx, signers[1]))
+
+		require.True(t, bc.VerifyTransaction(tx))
+
+		// OP_CHECKMULTISIG は署名を公開鍵の順にしか照合しない
+		pushes, err := tx.Inputs[0].ScriptSig.pushes()
+		require.NoError(t, err)
+		require.Len(t, pushes, 4)
+		tx.Inputs[0].ScriptSig = Script{}.AddOp(Op0).AddData(pushes[2]).AddData(pushes[1]).AddData(pushes[3])
+		assert.False(t, bc.VerifyTransaction(tx))
+	})
+
+	t.Run("P2SHのアドレスへ送金できる", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+		other, err := NewMultisigScript(1, script.PubKeys[:1])
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(script, other.Address(), 30, 0, mempool)
+		require.NoError(t, err)
+		require.NoError(t, bc.SignTransaction(tx, signers[0]))
+		require.NoError(t, bc.SignTransaction(tx, signers[1]))
+		require.NoError(t, mempool.Add(tx))
+		_, _, err = mempool.MineBlock(signers[0].GetAddress())
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, utxoSet.GetBalance(script.Address()))
+		assert.Equal(t, 30, utxoSet.GetBalance(other.Address()))
+	})
+
+	t.Run("残高不足はエラー", func(t *testing.T) {
+		wallet, _, _, mempool := newTestChain(t)
+		_, _, err := SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		_, err = NewScriptSpend(script, testAddressA, 30, 1, mempool)
+		assert.Error(t, err)
+	})
+}
+
+func TestScriptTimelock(t *testing.T) {
+	t.Run("ロック高さに達するまで使えない", func(t *testing.T) {
+		// ジェネシスと入金のブロックで高さ1まで進む。ロックは高さ4
+		var signers []*Wallet
+		var pubKeys [][]byte
+		for i := 0; i < 3; i++ {
+			signer, err := NewWallet()
+			require.NoError(t, err)
+			signers = append(signers, signer)
+			pubKeys = append(pubKeys, publicKeyToBytes(signer.PublicKey))
+		}
+		script, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		script, err = script.WithLockHeight(4)
+		require.NoError(t, err)
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err = SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(script, testAddressA, 10, 0, mempool)
+		require.NoError(t, err)
+		require.NoError(t, bc.SignTransaction(tx, signers[0]))
+		require.NoError(t, bc.SignTransaction(tx, signers[1]))
+
+		assert.Equal(t, int64(4), tx.LockTime)
+
+		assert.Error(t, bc.CheckLockHeight(tx))
+		assert.False(t, bc.VerifyTransaction(tx))
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timelocked until block 4")
+
+		// 高さ3までマイニングすると、次のブロック（高さ4）に含められる
+		fundWallet(t, bc, utxoSet, signers[2], 2)
+		assert.NoError(t, bc.CheckLockHeight(tx))
+		require.NoError(t, mempool.Add(tx))
+
+		block, _, err := mempool.MineBlock(signers[2].GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), block.Index)
+		assert.Equal(t, 10, utxoSet.GetBalance(testAddressA))
+	})
+}
+
+func TestScriptLockTime(t *testing.T) {
+	t.Run("ロック時刻がスクリプトのロック高さより低いと無効", func(t *testing.T) {
+		var signers []*Wallet
+		var pubKeys [][]byte
+		for i := 0; i < 3; i++ {
+			signer, err := NewWallet()
+			require.NoError(t, err)
+			signers = append(signers, signer)
+			pubKeys = append(pubKeys, publicKeyToBytes(signer.PublicKey))
+		}
+		script, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		script, err = script.WithLockHeight(2)
+		require.NoError(t, err)
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err = SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+		fundWallet(t, bc, utxoSet, signers[2], 2)
+
+		tx, err := NewScriptSpend(script, testAddressA, 10, 0, mempool)
+		require.NoError(t, err)
+		tx.LockTime = 1
+		require.NoError(t, bc.SignTransaction(tx, signers[0]))
+		require.NoError(t, bc.SignTransaction(tx, signers[1]))
+
+		// ブロック高さの条件は満たすが、OP_CHECKLOCKTIMEVERIFY が失敗する
+		assert.NoError(t, bc.CheckLockHeight(tx))
+		assert.False(t, bc.VerifyTransaction(tx))
+	})
+}
+
+func TestWalletsScripts(t *testing.T) {
+	t.Run("一覧の番号で署名者を指定できる", func(t *testing.T) {
+		wallets := NewWallets()
+		for i := 0; i < 3; i++ {
+			_, err := wallets.CreateWallet()
+			require.NoError(t, err)
+		}
+
+		address, err := wallets.CreateScript(2, []string{"1", "3"}, 0)
+		require.NoError(t, err)
+		script, err := wallets.GetScript(address)
+		require.NoError(t, err)
+
+		signers := wallets.Signers(script)
+		require.Len(t, signers, 2)
+		addresses := wallets.GetAddresses()
+		assert.Equal(t, addresses[0], signers[0].GetAddress())
+		assert.Equal(t, addresses[2], signers[1].GetAddress())
+		assert.Equal(t, []string{address}, wallets.GetScriptAddresses())
+	})
+
+	t.Run("存在しないウォレットはエラー", func(t *testing.T) {
+		wallets := NewWallets()
+		_, err := wallets.CreateScript(1, []string{testAddressA}, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("償還スクリプトも保存して読み込める", func(t *testing.T) {
+		wallets := NewWallets()
+		for i := 0; i < 3; i++ {
+			_, err := wallets.CreateWallet()
+			require.NoError(t, err)
+		}
+		address, err := wallets.CreateScript(2, []string{"1", "2", "3"}, 7)
+		require.NoError(t, err)
+		script, err := wallets.GetScript(address)
+		require.NoError(t, err)
+		filename := filepath.Join(t.TempDir(), "wallets.dat")
+		require.NoError(t, wallets.SaveToFile(filename))
+
+		loaded, err := LoadWalletsFromFile(filename)
+		require.NoError(t, err)
+		loadedScript, err := loaded.GetScript(script.Address())
+		require.NoError(t, err)
+		assert.Equal(t, script.Serialize(), loadedScript.Serialize())
+		assert.Len(t, loaded.Signers(loadedScript), 3)
+	})
+}
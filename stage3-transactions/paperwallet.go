@@ -0,0 +1,149 @@
+// Package main implements encrypted key export and paper wallets for Stage 3.
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+
+	"github.com/nyasuto/minicoin/common"
+	"golang.org/x/crypto/scrypt"
+)
+
+// 暗号化した秘密鍵の形式（BIP38 のEC乗算を使わない形式と同じ構成で、Base58Check にすると "6P" で始まります）
+// 0x01 0x42 <フラグ> <アドレスのハッシュ4バイト> <暗号化した秘密鍵32バイト>
+const (
+	encryptedKeyVersion = 0x01
+	encryptedKeyPrefix  = 0x42
+	encryptedKeyFlag    = 0xc0
+	encryptedKeyLen     = 1 + 1 + 4 + 32 // バージョンバイトを除いたペイロードの長さ
+
+	// scrypt のパラメータ（BIP38 と同じ。総当たりで passphrase を試しにくくする）
+	encryptedKeyScryptN = 16384
+	encryptedKeyScryptR = 8
+	encryptedKeyScryptP = 8
+)
+
+// PaperWalletScale は紙のウォレットの画像で1モジュールを描くピクセル数です
+const PaperWalletScale = 8
+
+// EncryptPrivateKey はウォレットの秘密鍵を passphrase で暗号化し、印刷できる文字列にします
+// 鍵は passphrase から scrypt で導いた鍵で AES-256 暗号化し、アドレスのハッシュで passphrase の誤りを検出できるようにします
+func EncryptPrivateKey(wallet *Wallet, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+
+	addressHash := encryptedKeyAddressHash(wallet.GetAddress())
+	derived, err := scrypt.Key([]byte(passphrase), addressHash, encryptedKeyScryptN, encryptedKeyScryptR, encryptedKeyScryptP, 64)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return "", fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	key := wallet.PrivateKey.D.FillBytes(make([]byte, 32))
+	encrypted := make([]byte, 32)
+	for i := range key {
+		key[i] ^= derived[i]
+	}
+	block.Encrypt(encrypted[:16], key[:16])
+	block.Encrypt(encrypted[16:], key[16:])
+
+	payload := append([]byte{encryptedKeyPrefix, encryptedKeyFlag}, addressHash...)
+	return common.Base58CheckEncode(encryptedKeyVersion, append(payload, encrypted...)), nil
+}
+
+// DecryptPrivateKey は EncryptPrivateKey で暗号化した秘密鍵を passphrase で復号し、ウォレットにします
+func DecryptPrivateKey(encoded, passphrase string) (*Wallet, error) {
+	version, payload, err := common.Base58CheckDecode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted key: %w", err)
+	}
+	if version != encryptedKeyVersion || len(payload) != encryptedKeyLen || payload[0] != encryptedKeyPrefix || payload[1] != encryptedKeyFlag {
+		return nil, fmt.Errorf("invalid encrypted key: not a minicoin encrypted private key")
+	}
+	addressHash, encrypted := payload[2:6], payload[6:]
+
+	derived, err := scrypt.Key([]byte(passphrase), addressHash, encryptedKeyScryptN, encryptedKeyScryptR, encryptedKeyScryptP, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	key := make([]byte, 32)
+	block.Decrypt(key[:16], encrypted[:16])
+	block.Decrypt(key[16:], encrypted[16:])
+	for i := range key {
+		key[i] ^= derived[i]
+	}
+
+	privateKey, ok := privateKeyFromBytes(key)
+	if !ok {
+		return nil, fmt.Errorf("wrong passphrase")
+	}
+	wallet := &Wallet{
+		PrivateKey: privateKey,
+		PublicKey:  &privateKey.PublicKey,
+		Address:    common.PublicKeyToAddress(&privateKey.PublicKey),
+	}
+	if !bytes.Equal(encryptedKeyAddressHash(wallet.Address), addressHash) {
+		return nil, fmt.Errorf("wrong passphrase")
+	}
+	return wallet, nil
+}
+
+// encryptedKeyAddressHash はアドレスの SHA-256 を2回適用した先頭4バイトを返します（scrypt のソルトと passphrase の確認に使います）
+func encryptedKeyAddressHash(address string) []byte {
+	first := sha256.Sum256([]byte(address))
+	second := sha256.Sum256(first[:])
+	return second[:4]
+}
+
+// WritePaperWallet はアドレス（と暗号化した秘密鍵があればそれも）のQRコードを左右に並べたPNG画像を書き出します
+func WritePaperWallet(filename, address, encryptedKey string) error {
+	codes := []*QRCode{}
+	for _, text := range []string{address, encryptedKey} {
+		if text == "" {
+			continue
+		}
+		qr, err := EncodeQR([]byte(text))
+		if err != nil {
+			return err
+		}
+		codes = append(codes, qr)
+	}
+
+	width, height := 0, 0
+	for _, qr := range codes {
+		side := (qr.Size + 2*QRQuietZone) * PaperWalletScale
+		width += side
+		height = max(height, side)
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	left := 0
+	for _, qr := range codes {
+		qr.drawTo(img, left, 0, PaperWalletScale)
+		left += (qr.Size + 2*QRQuietZone) * PaperWalletScale
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return fmt.Errorf("failed to encode paper wallet: %w", err)
+	}
+	if err := os.WriteFile(filename, buffer.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write paper wallet: %w", err)
+	}
+	return nil
+}
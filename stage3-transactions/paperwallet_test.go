@@ -0,0 +1,95 @@
+package main
+
+import (
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptPrivateKey(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+
+	t.Run("暗号化した秘密鍵を同じpassphraseで復号する", func(t *testing.T) {
+		encrypted, err := EncryptPrivateKey(wallet, "correct horse")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(encrypted, "6P"), encrypted)
+
+		restored, err := DecryptPrivateKey(encrypted, "correct horse")
+		require.NoError(t, err)
+		assert.Equal(t, wallet.GetAddress(), restored.GetAddress())
+		assert.Equal(t, 0, wallet.PrivateKey.D.Cmp(restored.PrivateKey.D))
+
+		_, err = EncodeQR([]byte(encrypted))
+		assert.NoError(t, err)
+	})
+
+	t.Run("passphraseが違えば復号できない", func(t *testing.T) {
+		encrypted, err := EncryptPrivateKey(wallet, "correct horse")
+		require.NoError(t, err)
+
+		_, err = DecryptPrivateKey(encrypted, "battery staple")
+		assert.ErrorContains(t, err, "wrong passphrase")
+	})
+
+	t.Run("不正な入力を拒否する", func(t *testing.T) {
+		_, err := EncryptPrivateKey(wallet, "")
+		assert.Error(t, err)
+
+		_, err = DecryptPrivateKey(wallet.GetAddress(), "correct horse")
+		assert.ErrorContains(t, err, "not a minicoin encrypted private key")
+
+		encrypted, err := EncryptPrivateKey(wallet, "correct horse")
+		require.NoError(t, err)
+		_, err = DecryptPrivateKey(encrypted[:len(encrypted)-1]+"x", "correct horse")
+		assert.ErrorContains(t, err, "invalid encrypted key")
+	})
+}
+
+func TestWritePaperWallet(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+	address := wallet.GetAddress()
+
+	readPNG := func(t *testing.T, filename string) (int, int) {
+		t.Helper()
+		file, err := os.Open(filename)
+		require.NoError(t, err)
+		defer func() { _ = file.Close() }()
+		img, err := png.Decode(file)
+		require.NoError(t, err)
+		return img.Bounds().Dx(), img.Bounds().Dy()
+	}
+
+	t.Run("アドレスだけのQRコードを書き出す", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "paper.png")
+		require.NoError(t, WritePaperWallet(filename, address, ""))
+
+		qr, err := EncodeQR([]byte(address))
+		require.NoError(t, err)
+		side := (qr.Size + 2*QRQuietZone) * PaperWalletScale
+		width, height := readPNG(t, filename)
+		assert.Equal(t, side, width)
+		assert.Equal(t, side, height)
+	})
+
+	t.Run("暗号化した秘密鍵のQRコードを横に並べる", func(t *testing.T) {
+		encrypted, err := EncryptPrivateKey(wallet, "correct horse")
+		require.NoError(t, err)
+		filename := filepath.Join(t.TempDir(), "paper.png")
+		require.NoError(t, WritePaperWallet(filename, address, encrypted))
+
+		addressQR, err := EncodeQR([]byte(address))
+		require.NoError(t, err)
+		keyQR, err := EncodeQR([]byte(encrypted))
+		require.NoError(t, err)
+		width, height := readPNG(t, filename)
+		assert.Equal(t, (addressQR.Size+keyQR.Size+4*QRQuietZone)*PaperWalletScale, width)
+		assert.Equal(t, (keyQR.Size+2*QRQuietZone)*PaperWalletScale, height)
+	})
+}
@@ -0,0 +1,64 @@
+// Package main implements mempool admission policy (dust and minimum relay fee) for Stage 3.
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// メモリプールの受け入れポリシーの既定値
+const (
+	DefaultDustLimit   = 1 // この額未満の出力はダストとして受け入れない
+	DefaultMinRelayFee = 0 // 1000バイトあたりの最低手数料（0なら制限しない）
+)
+
+// PolicyError はノードのポリシーでメモリプールへの受け入れを拒否したことを表します
+// コンセンサスのルール（署名・二重支払い・金額）には違反していないため、ブロックに含まれていれば有効です
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy: %s (valid by consensus rules, but not accepted into the mempool)", e.Reason)
+}
+
+// IsPolicyError はエラーがポリシーによる拒否かを返します
+func IsPolicyError(err error) bool {
+	var policyErr *PolicyError
+	return errors.As(err, &policyErr)
+}
+
+// MinRelayFeeFor は size バイトのトランザクションが最低手数料を満たすのに必要な手数料を返します
+func (mp *Mempool) MinRelayFeeFor(size int) int {
+	if mp.MinRelayFee <= 0 {
+		return 0
+	}
+	return (mp.MinRelayFee*size + 999) / 1000
+}
+
+// checkPolicy はトランザクションがダストの出力を含まず、最低手数料を満たしているか確認します
+// 解除できない出力（OP_RETURN のデータ出力）はUTXOとして使われることがないため、ダストとみなしません
+func (mp *Mempool) checkPolicy(tx *Transaction, fee int) error {
+	for index, output := range tx.Outputs {
+		if output.Value < mp.DustLimit && !output.ScriptPubKey.IsUnspendable() {
+			return &PolicyError{Reason: fmt.Sprintf("output %d is dust (%d coins, dust limit %d)", index, output.Value, mp.DustLimit)}
+		}
+	}
+
+	size := tx.Size()
+	if minFee := mp.MinRelayFeeFor(size); fee < minFee {
+		return &PolicyError{Reason: fmt.Sprintf("fee %d is below the minimum relay fee (%d per 1000 bytes, %d for %d bytes)", fee, mp.MinRelayFee, minFee, size)}
+	}
+	return nil
+}
+
+// printSendError は送金の失敗を、ポリシーによる拒否とそれ以外（コンセンサスのルール違反など）に分けて表示します
+func printSendError(err error) {
+	var policyErr *PolicyError
+	if errors.As(err, &policyErr) {
+		fmt.Printf("🚫 Rejected by mempool policy: %s\n", policyErr.Reason)
+		fmt.Println("   The transaction is valid by consensus rules; raise the fee or the amount to relay it.")
+		return
+	}
+	fmt.Printf("❌ Send failed: %v\n", err)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolPolicy(t *testing.T) {
+	t.Run("ダストの出力を含むトランザクションはポリシーで拒否する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		mempool.DustLimit = 5
+
+		tx, err := NewTransaction(wallet, testAddressA, 3, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.True(t, IsPolicyError(err))
+		assert.Contains(t, err.Error(), "dust")
+		assert.Equal(t, 0, mempool.Size())
+	})
+
+	t.Run("最低手数料に満たないトランザクションはポリシーで拒否する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		mempool.MinRelayFee = 10
+
+		cheap, err := NewTransaction(wallet, testAddressA, 10, 1, utxoSet, bc)
+		require.NoError(t, err)
+		minFee := mempool.MinRelayFeeFor(cheap.Size())
+		require.Greater(t, minFee, 1)
+
+		err = mempool.Add(cheap)
+		require.Error(t, err)
+		assert.True(t, IsPolicyError(err))
+		assert.Contains(t, err.Error(), "minimum relay fee")
+
+		paid, err := NewTransaction(wallet, testAddressA, 10, minFee+1, utxoSet, bc)
+		require.NoError(t, err)
+		assert.NoError(t, mempool.Add(paid))
+	})
+
+	t.Run("コンセンサスのルール違反はポリシーによる拒否と区別する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+
+		tx, err := NewTransaction(wallet, testAddressA, 10, 0, utxoSet, bc)
+		require.NoError(t, err)
+		tx.Outputs[0].Value = 100
+
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.False(t, IsPolicyError(err))
+	})
+
+	t.Run("ポリシーで拒否したトランザクションもブロックに含めれば有効", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		mempool.DustLimit = 5
+
+		tx, err := NewTransaction(wallet, testAddressA, 3, 0, utxoSet, bc)
+		require.NoError(t, err)
+		require.True(t, IsPolicyError(mempool.Add(tx)))
+
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "direct"), tx})
+		require.NoError(t, err)
+		require.NoError(t, utxoSet.Update(block))
+		assert.NoError(t, bc.Validate())
+		assert.Equal(t, 3, utxoSet.GetBalance(testAddressA))
+	})
+
+	t.Run("最低手数料が0なら手数料を求めない", func(t *testing.T) {
+		_, _, _, mempool := newTestChain(t)
+		assert.Equal(t, 0, mempool.MinRelayFeeFor(1000))
+
+		mempool.MinRelayFee = 3
+		assert.Equal(t, 3, mempool.MinRelayFeeFor(1000))
+		assert.Equal(t, 2, mempool.MinRelayFeeFor(334))
+	})
+}
@@ -0,0 +1,270 @@
+// Package main implements the Merkle proof and SPV subcommands for Stage 3.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// runProveCommand は prove サブコマンドを実行します
+// chain.db のブロックから、トランザクションが含まれることのマークル証明を作って検証します
+func runProveCommand(args []string) int {
+	fs := flag.NewFlagSet("prove", flag.ContinueOnError)
+	blockFlag := fs.Int64("block", -1, "ブロックの高さ")
+	txFlag := fs.String("tx", "", "トランザクションID（16進数。先頭の一部でもよい）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *blockFlag < 0 || *txFlag == "" {
+		fmt.Println("❌ Usage: prove --block <height> --tx <txid>")
+		return 2
+	}
+
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if *blockFlag >= int64(len(blocks)) {
+		fmt.Printf("❌ Block %d not found (%d block(s) in %s)\n", *blockFlag, len(blocks), chainFile)
+		return 1
+	}
+	block := blocks[*blockFlag]
+
+	tx, err := findBlockTransaction(block, *txFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	proof, err := block.GenerateMerkleProof(tx.ID)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	root := block.HashTransactions()
+
+	fmt.Println("\n🌳 Merkle Proof")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("Block #%d:   %s\n", block.Index, block.Hash)
+	fmt.Printf("Merkle Root: %x\n", root)
+	fmt.Printf("TxID:        %x\n", tx.ID)
+	fmt.Printf("Proof:       %d hash(es)\n", len(proof))
+	for i, step := range proof {
+		side := "R"
+		if step.Left {
+			side = "L"
+		}
+		fmt.Printf("  %d. %s %x\n", i+1, side, step.Hash)
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+
+	if !common.VerifyMerkleProof(root, tx.ID, proof) {
+		fmt.Println("❌ Proof does not match the Merkle root!")
+		return 1
+	}
+	fmt.Println("✅ Proof verified against the Merkle root.")
+	fmt.Printf("   Revealed 1 of %d transaction(s); the other %d stay hidden behind %d hash(es).\n",
+		len(block.Transactions), len(block.Transactions)-1, len(proof))
+	return 0
+}
+
+// findBlockTransaction はIDが prefix で始まるブロック内のトランザクションを返します（一意に決まる必要があります）
+func findBlockTransaction(block *Block, prefix string) (*Transaction, error) {
+	prefix = strings.ToLower(prefix)
+	var found *Transaction
+	for _, tx := range block.Transactions {
+		if strings.HasPrefix(hex.EncodeToString(tx.ID), prefix) {
+			if found != nil {
+				return nil, fmt.Errorf("transaction id %q is ambiguous in block %d", prefix, block.Index)
+			}
+			found = tx
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("transaction %s is not in block %d", prefix, block.Index)
+	}
+	return found, nil
+}
+
+// runSPVCommand は spv サブコマンドを実行します
+// chain.db をフルノードに見立て、SPVクライアントにはヘッダーとウォレットに関係するトランザクションの証明だけを渡して承認を確認します
+func runSPVCommand(args []string) int {
+	fs := flag.NewFlagSet("spv", flag.ContinueOnError)
+	addressFlag := fs.String("address", "", "監視するアドレス（空なら wallets.dat のすべてのウォレット）")
+	txFlag := fs.String("tx", "", "承認を確認するトランザクションID（16進数。先頭の一部でもよい）")
+	heightFlag := fs.Int64("height", -1, "トランザクションが含まれているはずのブロックの高さ（-1なら確認しない）")
+	confirmationsFlag := fs.Int64("confirmations", 1, "必要な承認数")
+	bloomFlag := fs.Float64("bloom", 0, "ブルームフィルターの偽陽性率（0より大きければアドレスの代わりにフィルターをフルノードに渡す）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	addresses := []string{*addressFlag}
+	var wallets *Wallets
+	if *addressFlag == "" {
+		var err error
+		wallets, err = LoadWalletsFromFile(walletsFile)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		addresses = nil
+		for _, address := range wallets.GetAddresses() {
+			addresses = append(addresses, wallets.Wallets[address].Addresses()...)
+		}
+		if len(addresses) == 0 {
+			fmt.Printf("❌ No wallets in %s. Use --address <address>.\n", walletsFile)
+			return 2
+		}
+	}
+
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	node := &Blockchain{Blocks: blocks}
+
+	// SPVクライアントはヘッダーと証明だけを受け取り、それぞれ検証する
+	client := NewSPVClient()
+	if _, err := client.SyncHeaders(node); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	var proofs []*TxProof
+	if *bloomFlag > 0 {
+		proofs, err = bloomFilterProofs(blocks, addresses, wallets, *bloomFlag)
+	} else {
+		proofs, err = node.ProofsForAddresses(addresses)
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	for _, proof := range proofs {
+		if err := client.AddProof(proof); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+	}
+
+	headerBytes, _ := encodeGob(node.Headers(0))
+	proofBytes, _ := encodeGob(proofs)
+	blockBytes, _ := encodeGob(blocks)
+	fmt.Println("\n📡 SPV Client")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("Headers:   %d (tip #%d), %d bytes\n", client.Height()+1, client.Height(), len(headerBytes))
+	fmt.Printf("Proofs:    %d transaction(s), %d bytes\n", len(proofs), len(proofBytes))
+	fmt.Printf("Full node: %d bytes of blocks\n", len(blockBytes))
+	for _, proof := range client.Proofs() {
+		_, confirmations, _ := client.Confirmations(proof.TxID)
+		fmt.Printf("  #%-4d %s  %d confirmation(s)\n", proof.Height, truncateHash(hex.EncodeToString(proof.TxID)), confirmations)
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+
+	if *txFlag == "" {
+		return 0
+	}
+
+	// 監視していないトランザクションは、フルノードに証明を求めてから検証する
+	var tx *Transaction
+	for i := len(blocks) - 1; i >= 0 && tx == nil; i-- {
+		tx, _ = findBlockTransaction(blocks[i], *txFlag)
+	}
+	if tx == nil {
+		fmt.Printf("❌ Transaction %s not found\n", *txFlag)
+		return 1
+	}
+	if _, _, err := client.Confirmations(tx.ID); err != nil {
+		proof, err := node.ProveTransaction(tx.ID)
+		if err == nil {
+			err = client.AddProof(proof)
+		}
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+	}
+
+	height, confirmations, _ := client.Confirmations(tx.ID)
+	if *heightFlag >= 0 {
+		if err := client.VerifyConfirmed(tx.ID, *heightFlag, *confirmationsFlag); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+	} else if confirmations < *confirmationsFlag {
+		fmt.Printf("❌ Transaction %s has %d confirmation(s), need %d\n", truncateHash(hex.EncodeToString(tx.ID)), confirmations, *confirmationsFlag)
+		return 1
+	}
+	fmt.Printf("✅ Transaction %s is confirmed at height %d with %d confirmation(s).\n", truncateHash(hex.EncodeToString(tx.ID)), height, confirmations)
+	return 0
+}
+
+// bloomFilterProofs はウォレット（またはアドレス）のブルームフィルターで各ブロックを絞り込み、一致したトランザクションの証明を返します
+// フィルターは偽陽性を含むため、自分と関係のないトランザクションも混ざります
+func bloomFilterProofs(blocks []*Block, addresses []string, wallets *Wallets, falsePositiveRate float64) ([]*TxProof, error) {
+	tweak, err := randomTweak()
+	if err != nil {
+		return nil, err
+	}
+
+	var filter *BloomFilter
+	if wallets != nil {
+		filter, err = wallets.NewBloomFilter(falsePositiveRate, tweak)
+	} else {
+		var elements [][]byte
+		for _, address := range addresses {
+			pubKeyHash, err := common.DecodeAddress(address)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, pubKeyHash)
+		}
+		filter, err = newBloomFilterOf(elements, falsePositiveRate, tweak)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var proofs []*TxProof
+	total := 0
+	for _, block := range blocks {
+		filtered, err := FilterBlock(block, filter)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, filtered.Proofs...)
+		total += filtered.Total
+	}
+	fmt.Printf("🌸 Bloom filter: %d bytes, %d hash function(s), tweak %d\n", len(filter.Bits), filter.HashFuncs, filter.Tweak)
+	fmt.Printf("   %d of %d transaction(s) matched (may include false positives)\n", len(proofs), total)
+	return proofs, nil
+}
+
+// randomTweak はブルームフィルターのtweakを乱数で作ります
+func randomTweak() (uint32, error) {
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
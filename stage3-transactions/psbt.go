@@ -0,0 +1,257 @@
+// Package main implements partially signed transactions (PSBT-like) for Stage 3.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// psbtMagic はエンコードしたPSBTの先頭に付ける目印です（BIP174と同じ "psbt" + 0xff）
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+// PSBT は未署名または署名途中のトランザクションを、署名と組み立てに必要な情報とともにウォレット間で受け渡す形式です
+// 作成者が送金を組み立て、署名者（マルチシグの共同署名者やオフラインのウォレット）がそれぞれ署名を追加し、
+// 必要な署名が揃ったら最終的なトランザクションを組み立てます
+// 使用する出力（金額とロックスクリプト）を含むため、署名者はチェーンを持っていなくても署名できます
+type PSBT struct {
+	Tx     *Transaction // 署名前のトランザクション（scriptSig は Finalize で作る）
+	Inputs []PSBTInput  // 入力ごとの情報（Tx.Inputs と同じ順）
+}
+
+// PSBTInput はPSBTの入力1つ分の情報です
+type PSBTInput struct {
+	UTXO         TxOutput          // 使用する出力
+	RedeemScript *RedeemScript     // P2SHの出力なら償還スクリプト
+	Signatures   map[string][]byte // 公開鍵(hex) -> 署名
+}
+
+// OutputFinder は入力が使う出力を探します（UTXOSet と Mempool が実装します）
+type OutputFinder interface {
+	FindOutput(txID []byte, outIndex int) (TxOutput, bool)
+}
+
+// NewPSBT は未署名のトランザクションから、入力が使う出力を finder で探してPSBTを作ります
+// P2SHの入力の償還スクリプトは、NewScriptSpend が scriptSig に入れたものを使います
+func NewPSBT(tx *Transaction, finder OutputFinder) (*PSBT, error) {
+	if tx.IsCoinbase() {
+		return nil, fmt.Errorf("coinbase transaction cannot be a PSBT")
+	}
+
+	psbt := &PSBT{Tx: tx, Inputs: make([]PSBTInput, len(tx.Inputs))}
+	for i, input := range tx.Inputs {
+		output, ok := finder.FindOutput(input.TxID, input.OutIndex)
+		if !ok {
+			return nil, fmt.Errorf("input %d: %s is not an unspent output", i, outpointKey(input.TxID, input.OutIndex))
+		}
+		psbt.Inputs[i] = PSBTInput{UTXO: output, Signatures: make(map[string][]byte)}
+
+		if class, _ := output.ScriptPubKey.classify(); class == p2shScript {
+			script, _, err := redeemScriptFor(input, output)
+			if err != nil {
+				return nil, fmt.Errorf("input %d: %w", i, err)
+			}
+			psbt.Inputs[i].RedeemScript = script
+		}
+	}
+	return psbt, nil
+}
+
+// Fee は入力の合計と出力の合計の差（手数料）を返します
+func (p *PSBT) Fee() int {
+	fee := 0
+	for _, input := range p.Inputs {
+		fee += input.UTXO.Value
+	}
+	for _, output := range p.Tx.Outputs {
+		fee -= output.Value
+	}
+	return fee
+}
+
+// signers は入力に署名できる公開鍵と、必要な署名の数を返します
+func (p *PSBT) signers(i int) ([][]byte, int, error) {
+	input := p.Inputs[i]
+	class, hash := input.UTXO.ScriptPubKey.classify()
+	switch class {
+	case p2pkhScript, legacyP2PKHScript:
+		// P2PKHの公開鍵は署名とともに示されるので、ハッシュが一致する公開鍵を署名から探す
+		for key := range input.Signatures {
+			pubKey, err := hex.DecodeString(key)
+			if err == nil && bytes.Equal(pubKeyHashFor(pubKey, class), hash) {
+				return [][]byte{pubKey}, 1, nil
+			}
+		}
+		return nil, 1, nil
+	case p2shScript:
+		if input.RedeemScript == nil {
+			return nil, 0, fmt.Errorf("input %d: P2SH input has no redeem script", i)
+		}
+		return input.RedeemScript.PubKeys, input.RedeemScript.Required, nil
+	default:
+		return nil, 0, fmt.Errorf("input %d: unsupported locking script", i)
+	}
+}
+
+// pubKeyHashFor はロックスクリプトの種類に応じた公開鍵ハッシュを計算します
+func pubKeyHashFor(pubKey []byte, class scriptClass) []byte {
+	if class == legacyP2PKHScript {
+		return common.Hash(common.Hash(pubKey))[:common.PubKeyHashLen] // OP_LEGACYHASH と同じ
+	}
+	return common.PublicKeyHash(pubKey)
+}
+
+// Sign はウォレットの鍵で署名できる入力に署名を追加し、追加した署名の数を返します
+// トランザクション自体は変更しないので、署名者は互いに独立して署名できます
+func (p *PSBT) Sign(signer Signer) (int, error) {
+	pubKey := publicKeyToBytes(signer.Public())
+	key := hex.EncodeToString(pubKey)
+
+	added := 0
+	for i, input := range p.Inputs {
+		if _, signed := input.Signatures[key]; signed {
+			continue
+		}
+
+		class, hash := input.UTXO.ScriptPubKey.classify()
+		switch class {
+		case p2pkhScript, legacyP2PKHScript:
+			if !bytes.Equal(hash, walletHash(signer, class)) {
+				continue // このウォレット宛ての出力ではない
+			}
+		case p2shScript:
+			if input.RedeemScript == nil || input.RedeemScript.keyIndex(pubKey) < 0 {
+				continue // このウォレットは署名者ではない
+			}
+		default:
+			continue
+		}
+
+		signature, err := signer.Sign(p.Tx.sigHash(i, input.UTXO))
+		if err != nil {
+			return added, fmt.Errorf("input %d: failed to sign: %w", i, err)
+		}
+		input.Signatures[key] = signature
+		added++
+	}
+	return added, nil
+}
+
+// Combine は同じトランザクションの別のPSBTに集まった署名を取り込みます
+// 取り込む署名は、入力の使う出力に対して検証します
+func (p *PSBT) Combine(other *PSBT) error {
+	if !bytes.Equal(p.Tx.ID, other.Tx.ID) || len(p.Inputs) != len(other.Inputs) {
+		return fmt.Errorf("cannot combine PSBTs of different transactions (%x and %x)", p.Tx.ID, other.Tx.ID)
+	}
+
+	for i, input := range other.Inputs {
+		checker := newTxSigChecker(p.Tx, i, p.Inputs[i].UTXO)
+		for key, signature := range input.Signatures {
+			pubKey, err := hex.DecodeString(key)
+			if err != nil || !checker.CheckSig(signature, pubKey) {
+				return fmt.Errorf("input %d: invalid signature for key %s", i, truncateHash(key))
+			}
+			p.Inputs[i].Signatures[key] = signature
+		}
+	}
+	return nil
+}
+
+// Progress は集まった署名の数と必要な数を返します
+// 複数の入力がある場合は、最も署名が足りない入力の値を返します
+func (p *PSBT) Progress() (int, int, error) {
+	signed, required := -1, 0
+	for i := range p.Inputs {
+		pubKeys, need, err := p.signers(i)
+		if err != nil {
+			return 0, 0, err
+		}
+		have := 0
+		for _, pubKey := range pubKeys {
+			if _, ok := p.Inputs[i].Signatures[hex.EncodeToString(pubKey)]; ok {
+				have++
+			}
+		}
+		if signed < 0 || need-have > required-signed {
+			signed, required = have, need
+		}
+	}
+	return signed, required, nil
+}
+
+// Finalize は集まった署名から各入力の scriptSig を組み立て、検証した署名済みのトランザクションを返します
+// 署名が足りない入力があればエラーを返します
+func (p *PSBT) Finalize() (*Transaction, error) {
+	tx := *p.Tx
+	tx.Inputs = make([]TxInput, len(p.Tx.Inputs))
+	copy(tx.Inputs, p.Tx.Inputs)
+
+	for i, input := range p.Inputs {
+		pubKeys, required, err := p.signers(i)
+		if err != nil {
+			return nil, err
+		}
+
+		// 公開鍵の順に、必要な数だけ署名を並べる
+		signatures := make([][]byte, len(pubKeys))
+		have := 0
+		for k, pubKey := range pubKeys {
+			if signature, ok := input.Signatures[hex.EncodeToString(pubKey)]; ok && have < required {
+				signatures[k] = signature
+				have++
+			}
+		}
+		if have < required {
+			return nil, fmt.Errorf("input %d: %d of %d signature(s)", i, have, required)
+		}
+
+		if input.RedeemScript != nil {
+			tx.Inputs[i].ScriptSig = scriptSigFor(input.RedeemScript, signatures)
+		} else {
+			tx.Inputs[i].ScriptSig = Script{}.AddData(signatures[0]).AddData(pubKeys[0])
+		}
+
+		if err := VerifyScript(tx.Inputs[i].ScriptSig, input.UTXO.ScriptPubKey, newTxSigChecker(&tx, i, input.UTXO)); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	return &tx, nil
+}
+
+// Encode はPSBTを受け渡し用の文字列（目印 + gob をBase64にしたもの）にします
+func (p *PSBT) Encode() (string, error) {
+	data, err := encodeGob(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode PSBT: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(append(append([]byte(nil), psbtMagic...), data...)), nil
+}
+
+// DecodePSBT は Encode で作った文字列からPSBTを復元します
+func DecodePSBT(text string) (*PSBT, error) {
+	data, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace([]byte(text))))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PSBT encoding: %w", err)
+	}
+	if !bytes.HasPrefix(data, psbtMagic) {
+		return nil, fmt.Errorf("not a PSBT (missing magic bytes)")
+	}
+
+	var psbt PSBT
+	if err := gob.NewDecoder(bytes.NewReader(data[len(psbtMagic):])).Decode(&psbt); err != nil {
+		return nil, fmt.Errorf("failed to decode PSBT: %w", err)
+	}
+	if psbt.Tx == nil || len(psbt.Inputs) != len(psbt.Tx.Inputs) {
+		return nil, fmt.Errorf("PSBT inputs do not match its transaction")
+	}
+	for i := range psbt.Inputs {
+		if psbt.Inputs[i].Signatures == nil {
+			psbt.Inputs[i].Signatures = make(map[string][]byte)
+		}
+	}
+	return &psbt, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newScriptPSBT はマルチシグのアドレスから送金する未署名のPSBTを作ります
+func newScriptPSBT(t *testing.T) ([]*Wallet, *UTXOSet, *Mempool, *PSBT) {
+	t.Helper()
+
+	var signers []*Wallet
+	var pubKeys [][]byte
+	for i := 0; i < 3; i++ {
+		signer, err := NewWallet()
+		require.NoError(t, err)
+		signers = append(signers, signer)
+		pubKeys = append(pubKeys, publicKeyToBytes(signer.PublicKey))
+	}
+	script, err := NewMultisigScript(2, pubKeys)
+	require.NoError(t, err)
+	wallet, _, utxoSet, mempool := newTestChain(t)
+	_, _, err = SendCoins(mempool, wallet, script.Address(), 30, 0)
+	require.NoError(t, err)
+	tx, err := NewScriptSpend(script, testAddressA, 10, 1, mempool)
+	require.NoError(t, err)
+	psbt, err := NewPSBT(tx, mempool)
+	require.NoError(t, err)
+	return signers, utxoSet, mempool, psbt
+}
+
+func TestPSBT(t *testing.T) {
+	t.Run("未署名のトランザクションに出力と償還スクリプトを付ける", func(t *testing.T) {
+		_, _, _, psbt := newScriptPSBT(t)
+		require.Len(t, psbt.Inputs, 1)
+		assert.Equal(t, 30, psbt.Inputs[0].UTXO.Value)
+		require.NotNil(t, psbt.Inputs[0].RedeemScript)
+		assert.Equal(t, 1, psbt.Fee())
+
+		signed, required, err := psbt.Progress()
+		require.NoError(t, err)
+		assert.Equal(t, 0, signed)
+		assert.Equal(t, 2, required)
+	})
+
+	t.Run("署名者が別々に署名したPSBTをまとめて送金できる", func(t *testing.T) {
+		signers, utxoSet, mempool, psbt := newScriptPSBT(t)
+
+		// 受け渡し用の文字列にして、それぞれの署名者に渡す
+		encoded, err := psbt.Encode()
+		require.NoError(t, err)
+		first, err := DecodePSBT(encoded)
+		require.NoError(t, err)
+		second, err := DecodePSBT(encoded)
+		require.NoError(t, err)
+
+		added, err := first.Sign(signers[0])
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+		added, err = second.Sign(signers[2])
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+
+		_, err = first.Finalize()
+		assert.Error(t, err, "署名が1つ足りない")
+
+		require.NoError(t, first.Combine(second))
+		signed, required, err := first.Progress()
+		require.NoError(t, err)
+		assert.Equal(t, 2, signed)
+		assert.Equal(t, 2, required)
+
+		tx, err := first.Finalize()
+		require.NoError(t, err)
+		assert.Equal(t, psbt.Tx.ID, tx.ID)
+		require.NoError(t, mempool.Add(tx))
+		_, _, err = mempool.MineBlock(signers[1].GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, 10, utxoSet.GetBalance(testAddressA))
+	})
+
+	t.Run("署名者でないウォレットは署名を追加しない", func(t *testing.T) {
+		_, _, _, psbt := newScriptPSBT(t)
+		outsider, err := NewWallet()
+		require.NoError(t, err)
+
+		added, err := psbt.Sign(outsider)
+		require.NoError(t, err)
+		assert.Equal(t, 0, added)
+	})
+
+	t.Run("別のトランザクションや不正な署名はまとめない", func(t *testing.T) {
+		signers, _, mempool, psbt := newScriptPSBT(t)
+		_, err := psbt.Sign(signers[0])
+		require.NoError(t, err)
+
+		tx, err := NewScriptSpend(psbt.Inputs[0].RedeemScript, testAddressB, 5, 1, mempool)
+		require.NoError(t, err)
+		other, err := NewPSBT(tx, mempool)
+		require.NoError(t, err)
+		assert.Error(t, psbt.Combine(other))
+
+		forged, err := DecodePSBT(mustEncodePSBT(t, psbt))
+		require.NoError(t, err)
+		for key := range forged.Inputs[0].Signatures {
+			forged.Inputs[0].Signatures[key] = []byte("forged")
+		}
+		assert.Error(t, psbt.Combine(forged))
+	})
+
+	t.Run("P2PKHの入力はオフラインのウォレットが署名する", func(t *testing.T) {
+		wallet, _, utxoSet, mempool := newTestChain(t)
+
+		// 作成者は秘密鍵を使わず、アドレスだけで送金を組み立てる
+		tx, _, err := NewUnsignedTransaction(wallet.GetAddress(), testAddressA, 20, 2, DefaultCoinSelection, mempool)
+		require.NoError(t, err)
+		psbt, err := NewPSBT(tx, utxoSet)
+		require.NoError(t, err)
+		_, err = psbt.Finalize()
+		assert.Error(t, err)
+
+		// 署名者はPSBTだけで署名できる（チェーンは不要）
+		offline, err := DecodePSBT(mustEncodePSBT(t, psbt))
+		require.NoError(t, err)
+		added, err := offline.Sign(wallet)
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+
+		signed, err := offline.Finalize()
+		require.NoError(t, err)
+		require.NoError(t, mempool.Add(signed))
+		entry, ok := mempool.Entry(signed.ID)
+		require.True(t, ok)
+		assert.Equal(t, 2, entry.Fee)
+	})
+
+	t.Run("PSBTでない文字列は読み込まない", func(t *testing.T) {
+		_, err := DecodePSBT("not base64!")
+		assert.Error(t, err)
+		_, err = DecodePSBT("aGVsbG8=")
+		assert.Error(t, err)
+	})
+}
+
+func mustEncodePSBT(t *testing.T, psbt *PSBT) string {
+	t.Helper()
+
+	encoded, err := psbt.Encode()
+	require.NoError(t, err)
+	return encoded
+}
@@ -0,0 +1,324 @@
+// Package main implements the psbt subcommands for Stage 3.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+const psbtUsage = `❌ Usage:
+  psbt create --to <address> --amount <coins> [--fee <coins>] [--from <address>] [--out tx.psbt]
+  psbt sign --in tx.psbt [--out tx.psbt] [--file wallets.dat]
+  psbt combine --out tx.psbt <a.psbt> <b.psbt> [...]
+  psbt show --in tx.psbt
+  psbt finalize --in tx.psbt`
+
+// runPSBTCommand は psbt サブコマンドを実行します
+func runPSBTCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(psbtUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "create":
+		return runPSBTCreate(args[1:])
+	case "sign":
+		return runPSBTSign(args[1:])
+	case "combine":
+		return runPSBTCombine(args[1:])
+	case "show":
+		return runPSBTShow(args[1:])
+	case "finalize":
+		return runPSBTFinalize(args[1:])
+	default:
+		fmt.Println(psbtUsage)
+		return 2
+	}
+}
+
+// runPSBTCreate は未署名の送金トランザクションを作成し、PSBTとして書き出します
+// 送金元にはローカルのウォレットのアドレスか、登録済みのマルチシグ・タイムロックのアドレスを指定できます
+func runPSBTCreate(args []string) int {
+	fs := flag.NewFlagSet("psbt create", flag.ContinueOnError)
+	toFlag := fs.String("to", "", "送金先アドレス")
+	amountFlag := fs.Int("amount", 0, "送金額")
+	feeFlag := fs.Int("fee", DefaultTransactionFee, "手数料（マイナーが受け取る）")
+	fromFlag := fs.String("from", "", "送金元アドレス（省略時は使用中のウォレット）")
+	outFlag := fs.String("out", "", "PSBTの保存先（省略時は標準出力）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *toFlag == "" || *amountFlag <= 0 {
+		fmt.Println(psbtUsage)
+		return 2
+	}
+	if err := common.ValidateAddress(*toFlag); err != nil {
+		printAddressError(err)
+		return 2
+	}
+
+	wallets, err := loadOrCreateWallets()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallets: %v\n", err)
+		return 1
+	}
+	from := *fromFlag
+	if from == "" {
+		from = wallets.Active
+	}
+
+	store, _, utxoSet, err := openChain(wallets.Active)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	var tx *Transaction
+	if script, ok := wallets.Scripts[from]; ok {
+		tx, err = NewScriptSpend(script, *toFlag, *amountFlag, *feeFlag, utxoSet)
+	} else {
+		tx, _, err = NewUnsignedTransaction(from, *toFlag, *amountFlag, *feeFlag, DefaultCoinSelection, utxoSet)
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to create transaction: %v\n", err)
+		return 1
+	}
+
+	psbt, err := NewPSBT(tx, utxoSet)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if err := writePSBT(psbt, *outFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("📝 Created unsigned transaction %s\n", truncateHash(fmt.Sprintf("%x", tx.ID)))
+	fmt.Printf("   %d coins from %s to %s (fee %d)\n", *amountFlag, from, *toFlag, psbt.Fee())
+	return 0
+}
+
+// runPSBTSign はローカルのすべてのウォレットの鍵でPSBTに署名します（チェーンは不要です）
+func runPSBTSign(args []string) int {
+	fs := flag.NewFlagSet("psbt sign", flag.ContinueOnError)
+	inFlag := fs.String("in", "", "署名するPSBT")
+	outFlag := fs.String("out", "", "署名したPSBTの保存先（省略時は --in に上書き）")
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *inFlag == "" {
+		fmt.Println(psbtUsage)
+		return 2
+	}
+	if *outFlag == "" {
+		*outFlag = *inFlag
+	}
+
+	psbt, err := readPSBT(*inFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	added := 0
+	for _, address := range wallets.GetAddresses() {
+		n, err := psbt.Sign(wallets.Wallets[address])
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		added += n
+	}
+	if added == 0 {
+		fmt.Println("⚠️  None of the local wallets can sign this transaction.")
+		return 1
+	}
+	if err := writePSBT(psbt, *outFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("✍️  Added %d signature(s) to %s\n", added, *outFlag)
+	printPSBTProgress(psbt)
+	return 0
+}
+
+// runPSBTCombine は同じトランザクションの複数のPSBTの署名を1つにまとめます
+func runPSBTCombine(args []string) int {
+	fs := flag.NewFlagSet("psbt combine", flag.ContinueOnError)
+	outFlag := fs.String("out", "", "まとめたPSBTの保存先")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *outFlag == "" || fs.NArg() < 2 {
+		fmt.Println(psbtUsage)
+		return 2
+	}
+
+	combined, err := readPSBT(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	for _, file := range fs.Args()[1:] {
+		other, err := readPSBT(file)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		if err := combined.Combine(other); err != nil {
+			fmt.Printf("❌ %s: %v\n", file, err)
+			return 1
+		}
+	}
+	if err := writePSBT(combined, *outFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("🔗 Combined %d PSBT(s) into %s\n", fs.NArg(), *outFlag)
+	printPSBTProgress(combined)
+	return 0
+}
+
+// runPSBTShow はPSBTの内容と署名の状況を表示します
+func runPSBTShow(args []string) int {
+	fs := flag.NewFlagSet("psbt show", flag.ContinueOnError)
+	inFlag := fs.String("in", "", "表示するPSBT")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *inFlag == "" {
+		fmt.Println(psbtUsage)
+		return 2
+	}
+
+	psbt, err := readPSBT(*inFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Println("\n📝 Partially Signed Transaction")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("TxID:  %x\n", psbt.Tx.ID)
+	if psbt.Tx.LockTime > 0 {
+		fmt.Printf("Lock:  block %d\n", psbt.Tx.LockTime)
+	}
+	for i, input := range psbt.Inputs {
+		fmt.Printf("In  %d: %d coins from %s (%d signature(s))\n", i, input.UTXO.Value, input.UTXO.ScriptPubKey.Address(), len(input.Signatures))
+	}
+	for i, output := range psbt.Tx.Outputs {
+		fmt.Printf("Out %d: %d coins to %s\n", i, output.Value, output.ScriptPubKey.Address())
+	}
+	fmt.Printf("Fee:   %d coins\n", psbt.Fee())
+	printPSBTProgress(psbt)
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
+
+// runPSBTFinalize は署名が揃ったPSBTから署名済みのトランザクションを組み立て、メモリプールに追加してマイニングします
+func runPSBTFinalize(args []string) int {
+	fs := flag.NewFlagSet("psbt finalize", flag.ContinueOnError)
+	inFlag := fs.String("in", "", "組み立てるPSBT")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *inFlag == "" {
+		fmt.Println(psbtUsage)
+		return 2
+	}
+
+	psbt, err := readPSBT(*inFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	tx, err := psbt.Finalize()
+	if err != nil {
+		fmt.Printf("❌ Cannot finalize: %v\n", err)
+		printPSBTProgress(psbt)
+		return 1
+	}
+	wallet, err := loadOrCreateWallet()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+	store, bc, utxoSet, err := openChain(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	mempool := NewMempool(bc, utxoSet)
+	if err := mempool.Add(tx); err != nil {
+		printSendError(err)
+		return 1
+	}
+	block, metrics, err := mempool.MineBlock(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ Mining failed: %v\n", err)
+		return 1
+	}
+	fmt.Println("\n✅ Transaction finalized and mined!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("TxID:      %x\n", tx.ID)
+	fmt.Printf("Fee:       %d coins\n", psbt.Fee())
+	fmt.Printf("Block #%d: %s (%d attempts)\n", block.Index, truncateHash(block.Hash), metrics.Attempts)
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
+
+// printPSBTProgress は署名の状況を表示します
+func printPSBTProgress(psbt *PSBT) {
+	signed, required, err := psbt.Progress()
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		return
+	}
+	if signed >= required {
+		fmt.Printf("Signatures: %d of %d (ready to finalize)\n", signed, required)
+		return
+	}
+	fmt.Printf("Signatures: %d of %d (waiting for more signers)\n", signed, required)
+}
+
+// readPSBT はファイルからPSBTを読み込みます
+func readPSBT(path string) (*PSBT, error) {
+	// #nosec G304 -- ファイル読み込みは教育目的のため許容
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PSBT: %w", err)
+	}
+	return DecodePSBT(string(data))
+}
+
+// writePSBT はPSBTをファイルに書き出します（path が空なら標準出力に表示します）
+func writePSBT(psbt *PSBT, path string) error {
+	text, err := psbt.Encode()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		fmt.Println(text)
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(text+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write PSBT: %w", err)
+	}
+	return nil
+}
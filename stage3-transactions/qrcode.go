@@ -0,0 +1,492 @@
+// Package main implements a small QR code encoder for Stage 3 paper wallets.
+// It supports byte mode at error correction level M for versions 1 to 10, which fits addresses and encrypted keys.
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// QRQuietZone はQRコードの周りに空ける余白（モジュール数）です
+const QRQuietZone = 4
+
+// qrVersion は誤り訂正レベルMでのバージョンごとの符号語の構成です
+type qrVersion struct {
+	ecPerBlock int   // ブロックごとの誤り訂正の符号語数
+	blocks     []int // ブロックごとのデータの符号語数（短いブロックが先）
+	alignment  []int // 位置合わせパターンの中心の座標
+}
+
+// qrVersions はバージョン1〜10の構成です（添字 = バージョン - 1）
+var qrVersions = []qrVersion{
+	{10, []int{16}, nil},
+	{16, []int{28}, []int{6, 18}},
+	{26, []int{44}, []int{6, 22}},
+	{18, []int{32, 32}, []int{6, 26}},
+	{24, []int{43, 43}, []int{6, 30}},
+	{16, []int{27, 27, 27, 27}, []int{6, 34}},
+	{18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	{22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	{22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	{26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+// dataCodewords はバージョンのデータの符号語数を返します
+func (v qrVersion) dataCodewords() int {
+	total := 0
+	for _, n := range v.blocks {
+		total += n
+	}
+	return total
+}
+
+// QRCode はQRコードのモジュール（黒白の升目）です
+type QRCode struct {
+	Version  int
+	Size     int
+	modules  [][]bool // [行][列] true が黒
+	function [][]bool // 位置検出パターンなどの機能パターン（データを置かない）
+}
+
+// EncodeQR はデータをバイトモード・誤り訂正レベルMのQRコードにします
+// データが収まる最小のバージョンを選び、マスクは失点が最も少ないものを選びます
+func EncodeQR(data []byte) (*QRCode, error) {
+	version := 0
+	for i, v := range qrVersions {
+		countBits := 8
+		if i+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*v.dataCodewords() {
+			version = i + 1
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("data is %d bytes, too long for a version %d QR code", len(data), len(qrVersions))
+	}
+
+	qr := newQRCode(version)
+	qr.drawCodewords(qr.codewords(data))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // XOR なのでもう一度かけると元に戻る
+	}
+	qr.applyMask(best)
+	qr.drawFormatBits(best)
+	return qr, nil
+}
+
+// newQRCode は機能パターンだけを描いたQRコードを作ります
+func newQRCode(version int) *QRCode {
+	size := 17 + 4*version
+	qr := &QRCode{Version: version, Size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range qr.modules {
+		qr.modules[i] = make([]bool, size)
+		qr.function[i] = make([]bool, size)
+	}
+
+	// タイミングパターン
+	for i := 0; i < size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+
+	// 位置検出パターン（分離パターンを含む）
+	for _, center := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x >= 0 && x < size && y >= 0 && y < size {
+					dist := max(abs(dx), abs(dy))
+					qr.setFunction(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+
+	// 位置合わせパターン（位置検出パターンと重なる3か所を除く）
+	positions := qrVersions[version-1].alignment
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// 形式情報の場所を確保し、型番情報を描く
+	qr.drawFormatBits(0)
+	qr.drawVersionBits()
+	return qr
+}
+
+// setFunction は機能パターンのモジュールを描きます（x が列、y が行）
+func (qr *QRCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+// Dark は (x, y) のモジュールが黒かを返します（x が列、y が行）
+func (qr *QRCode) Dark(x, y int) bool {
+	return qr.modules[y][x]
+}
+
+// qrFormatBits は誤り訂正レベルMとマスクの形式情報（BCH(15,5) 符号をマスクしたもの）を返します
+func qrFormatBits(mask int) int {
+	data := 0<<3 | mask // レベルMの指示子は 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// qrVersionBits はバージョン7以上に入れる型番情報（BCH(18,6) 符号）を返します
+func qrVersionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	return version<<12 | rem
+}
+
+// drawFormatBits は形式情報を2か所に描きます
+func (qr *QRCode) drawFormatBits(mask int) {
+	bits := qrFormatBits(mask)
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	// 左上
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+
+	// 右上と左下
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.Size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.Size-8, true) // 常に黒のモジュール
+}
+
+// drawVersionBits はバージョン7以上なら型番情報を2か所に描きます
+func (qr *QRCode) drawVersionBits() {
+	if qr.Version < 7 {
+		return
+	}
+	bits := qrVersionBits(qr.Version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 != 0
+		a, b := qr.Size-11+i%3, i/3
+		qr.setFunction(a, b, dark)
+		qr.setFunction(b, a, dark)
+	}
+}
+
+// codewords はデータを符号化し、誤り訂正の符号語を付けてブロックを交互に並べた符号語列を返します
+func (qr *QRCode) codewords(data []byte) []byte {
+	version := qrVersions[qr.Version-1]
+	capacity := version.dataCodewords()
+
+	var bits []bool
+	appendBits := func(value, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 != 0)
+		}
+	}
+	appendBits(0b0100, 4) // バイトモード
+	if qr.Version >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	appendBits(0, min(4, 8*capacity-len(bits))) // 終端パターン
+	appendBits(0, (8-len(bits)%8)%8)
+
+	encoded := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		encoded = append(encoded, b)
+	}
+	for pad := byte(0xec); len(encoded) < capacity; pad ^= 0xec ^ 0x11 {
+		encoded = append(encoded, pad)
+	}
+
+	// ブロックに分けて誤り訂正の符号語を計算し、交互に並べる
+	divisor := reedSolomonDivisor(version.ecPerBlock)
+	var blocks, ecBlocks [][]byte
+	for _, n := range version.blocks {
+		block := encoded[:n]
+		encoded = encoded[n:]
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, reedSolomonRemainder(block, divisor))
+	}
+	var result []byte
+	for i := 0; i < version.blocks[len(version.blocks)-1]; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < version.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			result = append(result, ec[i])
+		}
+	}
+	return result
+}
+
+// drawCodewords は符号語を右下から2列ずつジグザグに、機能パターン以外のモジュールに置きます
+func (qr *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := qr.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // 縦のタイミングパターンの列は飛ばす
+		}
+		for vert := 0; vert < qr.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.Size - 1 - vert // 上向き
+				}
+				if !qr.function[y][x] && i < len(codewords)*8 {
+					qr.modules[y][x] = (codewords[i/8]>>(7-i%8))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask はデータのモジュールをマスクのパターンで反転します
+func (qr *QRCode) applyMask(mask int) {
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.function[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty は読み取りにくさの失点（同じ色の連続、2x2の塊、位置検出パターンに似た並び、黒の割合の偏り）を返します
+func (qr *QRCode) penalty() int {
+	penalty := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	line := make([]bool, qr.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < qr.Size; i++ {
+			for j := 0; j < qr.Size; j++ {
+				if vertical {
+					line[j] = qr.modules[j][i]
+				} else {
+					line[j] = qr.modules[i][j]
+				}
+			}
+
+			run := 1
+			for j := 1; j <= qr.Size; j++ {
+				if j < qr.Size && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += 3 + run - 5
+				}
+				run = 1
+			}
+			for j := 0; j+11 <= qr.Size; j++ {
+				for _, pattern := range finderLike {
+					if equalBools(line[j:j+11], pattern) {
+						penalty += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < qr.Size; y++ {
+		for x := 0; x < qr.Size; x++ {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x+1 < qr.Size && y+1 < qr.Size {
+				c := qr.modules[y][x]
+				if qr.modules[y][x+1] == c && qr.modules[y+1][x] == c && qr.modules[y+1][x+1] == c {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := qr.Size * qr.Size
+	penalty += abs(dark*20-total*10) / total * 10 // 黒の割合が50%から5%ずれるごとに10点
+	return penalty
+}
+
+// equalBools は2つの並びが等しいかを返します
+func equalBools(a, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// reedSolomonDivisor は次数 degree のリード・ソロモン符号の生成多項式（最高次の係数1を除く）を返します
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder はデータを生成多項式で割った余り（誤り訂正の符号語）を返します
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply は GF(2^8)（既約多項式 0x11d）での積を返します
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// abs は整数の絶対値を返します
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Terminal はQRコードを端末に表示する文字列にします（上下2モジュールを1文字にまとめます）
+// 暗い背景の端末で読み取れるよう、白いモジュールをブロック文字で描きます
+func (qr *QRCode) Terminal() string {
+	light := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= qr.Size || y >= qr.Size {
+			return true // 余白
+		}
+		return !qr.modules[y][x]
+	}
+
+	var b strings.Builder
+	for y := -QRQuietZone; y < qr.Size+QRQuietZone; y += 2 {
+		for x := -QRQuietZone; x < qr.Size+QRQuietZone; x++ {
+			top, bottom := light(x, y), light(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Image はQRコードを1モジュール scale ピクセルの画像にします（周りに余白を付けます）
+func (qr *QRCode) Image(scale int) *image.Gray {
+	side := (qr.Size + 2*QRQuietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	qr.drawTo(img, 0, 0, scale)
+	return img
+}
+
+// drawTo は画像の (left, top) から余白付きのQRコードを描きます
+func (qr *QRCode) drawTo(img *image.Gray, left, top, scale int) {
+	side := (qr.Size + 2*QRQuietZone) * scale
+	for py := 0; py < side; py++ {
+		for px := 0; px < side; px++ {
+			x, y := px/scale-QRQuietZone, py/scale-QRQuietZone
+			c := color.Gray{Y: 0xff}
+			if x >= 0 && y >= 0 && x < qr.Size && y < qr.Size && qr.modules[y][x] {
+				c = color.Gray{Y: 0}
+			}
+			img.SetGray(left+px, top+py, c)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readQR はテスト用にQRコードを読み取り、データを返します
+// 形式情報からマスクを求めてデータのモジュールを元に戻し、ブロックごとに誤り訂正の符号語を確かめます
+func readQR(t *testing.T, qr *QRCode) []byte {
+	t.Helper()
+
+	// 左上の形式情報を読む
+	var positions [][2]int
+	for i := 0; i <= 5; i++ {
+		positions = append(positions, [2]int{8, i})
+	}
+	positions = append(positions, [2]int{8, 7}, [2]int{8, 8}, [2]int{7, 8})
+	for i := 9; i < 15; i++ {
+		positions = append(positions, [2]int{14 - i, 8})
+	}
+	bits := 0
+	for i, p := range positions {
+		if qr.Dark(p[0], p[1]) {
+			bits |= 1 << i
+		}
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if qrFormatBits(m) == bits {
+			mask = m
+		}
+	}
+	require.NotEqual(t, -1, mask, "format bits %015b", bits)
+
+	// マスクを外してジグザグに符号語を読む
+	unmasked := &QRCode{Version: qr.Version, Size: qr.Size, function: qr.function}
+	for _, row := range qr.modules {
+		unmasked.modules = append(unmasked.modules, append([]bool(nil), row...))
+	}
+	unmasked.applyMask(mask)
+	var codewords []byte
+	n := 0
+	for right := qr.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = qr.Size - 1 - vert
+				}
+				if unmasked.function[y][x] {
+					continue
+				}
+				if n%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+				if unmasked.modules[y][x] {
+					codewords[n/8] |= 1 << (7 - n%8)
+				}
+				n++
+			}
+		}
+	}
+
+	// ブロックに戻して誤り訂正の符号語を確かめる
+	version := qrVersions[qr.Version-1]
+	blocks := make([][]byte, len(version.blocks))
+	ecBlocks := make([][]byte, len(version.blocks))
+	for i := 0; i < version.blocks[len(version.blocks)-1]; i++ {
+		for b, size := range version.blocks {
+			if i < size {
+				blocks[b] = append(blocks[b], codewords[0])
+				codewords = codewords[1:]
+			}
+		}
+	}
+	for i := 0; i < version.ecPerBlock; i++ {
+		for b := range version.blocks {
+			ecBlocks[b] = append(ecBlocks[b], codewords[0])
+			codewords = codewords[1:]
+		}
+	}
+	divisor := reedSolomonDivisor(version.ecPerBlock)
+	var data []byte
+	for b, block := range blocks {
+		require.Equal(t, reedSolomonRemainder(block, divisor), ecBlocks[b], "block %d", b)
+		data = append(data, block...)
+	}
+
+	// バイトモードのデータを取り出す
+	stream := make([]bool, 0, 8*len(data))
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			stream = append(stream, (b>>i)&1 != 0)
+		}
+	}
+	read := func(n int) int {
+		value := 0
+		for _, bit := range stream[:n] {
+			value <<= 1
+			if bit {
+				value |= 1
+			}
+		}
+		stream = stream[n:]
+		return value
+	}
+	require.Equal(t, 0b0100, read(4))
+	countBits := 8
+	if qr.Version >= 10 {
+		countBits = 16
+	}
+	payload := make([]byte, read(countBits))
+	for i := range payload {
+		payload[i] = byte(read(8))
+	}
+	return payload
+}
+
+func TestReedSolomon(t *testing.T) {
+	t.Run("HELLO WORLD（1-M）の誤り訂正の符号語を計算する", func(t *testing.T) {
+		data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+		ec := reedSolomonRemainder(data, reedSolomonDivisor(10))
+		assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ec)
+	})
+}
+
+func TestQRFormatAndVersionBits(t *testing.T) {
+	t.Run("形式情報と型番情報が規格の値になる", func(t *testing.T) {
+		assert.Equal(t, 0b101000100100101, qrFormatBits(1))
+		assert.Equal(t, 0b100101010100000, qrFormatBits(7))
+		assert.Equal(t, 0x07C94, qrVersionBits(7))
+		assert.Equal(t, 0x0A4D3, qrVersionBits(10))
+	})
+}
+
+func TestEncodeQR(t *testing.T) {
+	t.Run("データが収まる最小のバージョンを選ぶ", func(t *testing.T) {
+		cases := map[int]int{1: 1, 14: 1, 15: 2, 26: 2, 62: 4, 100: 6, 180: 9, 181: 10, 213: 10}
+		for length, version := range cases {
+			qr, err := EncodeQR([]byte(strings.Repeat("a", length)))
+			require.NoError(t, err, length)
+			assert.Equal(t, version, qr.Version, length)
+			assert.Equal(t, 17+4*version, qr.Size, length)
+		}
+
+		_, err := EncodeQR([]byte(strings.Repeat("a", 214)))
+		assert.ErrorContains(t, err, "too long")
+	})
+
+	t.Run("読み取ると元のデータに戻る", func(t *testing.T) {
+		key := "6PYNKZ1EAgYgmQfmNVamxyXVWHzK5s6DGhwP4J5o44cvXdoY7sRzhtpUeo"
+		for _, text := range []string{"HELLO WORLD", testAddressA, key, strings.Repeat("minicoin ", 23)} {
+			qr, err := EncodeQR([]byte(text))
+			require.NoError(t, err)
+			assert.Equal(t, text, string(readQR(t, qr)))
+		}
+	})
+
+	t.Run("位置検出パターンと常に黒のモジュールを描く", func(t *testing.T) {
+		qr, err := EncodeQR([]byte(testAddressA))
+		require.NoError(t, err)
+
+		for _, corner := range [][2]int{{0, 0}, {qr.Size - 7, 0}, {0, qr.Size - 7}} {
+			assert.True(t, qr.Dark(corner[0], corner[1]))
+			assert.False(t, qr.Dark(corner[0]+1, corner[1]+1))
+			assert.True(t, qr.Dark(corner[0]+3, corner[1]+3))
+		}
+		assert.True(t, qr.Dark(8, qr.Size-8))
+	})
+
+	t.Run("端末と画像に余白付きで描く", func(t *testing.T) {
+		qr, err := EncodeQR([]byte("HELLO WORLD"))
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSuffix(qr.Terminal(), "\n"), "\n")
+		side := qr.Size + 2*QRQuietZone
+		assert.Len(t, lines, (side+1)/2)
+		assert.Equal(t, side, len([]rune(lines[0])))
+
+		img := qr.Image(3)
+		assert.Equal(t, 3*side, img.Bounds().Dx())
+		assert.Equal(t, uint8(0xff), img.GrayAt(0, 0).Y)
+		assert.Equal(t, uint8(0), img.GrayAt(3*QRQuietZone, 3*QRQuietZone).Y)
+	})
+}
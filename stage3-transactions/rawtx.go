@@ -0,0 +1,143 @@
+// Package main implements raw (hex-encoded) transactions for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RawInput は生のトランザクションの入力に指定する出力（TxID と出力番号）です
+type RawInput struct {
+	TxID     []byte
+	OutIndex int
+}
+
+// RawOutput は生のトランザクションの出力に指定する送金先と金額です
+type RawOutput struct {
+	Address string
+	Amount  int
+}
+
+// ParseRawInput は "<TxID(hex)>:<出力番号>" を入力に変換します
+func ParseRawInput(s string) (RawInput, error) {
+	txID, index, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return RawInput{}, fmt.Errorf("input %q must be <txid>:<index>", s)
+	}
+	id, err := hex.DecodeString(txID)
+	if err != nil || len(id) == 0 {
+		return RawInput{}, fmt.Errorf("input %q: invalid txid", s)
+	}
+	outIndex, err := strconv.Atoi(index)
+	if err != nil || outIndex < 0 {
+		return RawInput{}, fmt.Errorf("input %q: invalid output index", s)
+	}
+	return RawInput{TxID: id, OutIndex: outIndex}, nil
+}
+
+// ParseRawOutput は "<アドレス>=<金額>" を出力に変換します
+func ParseRawOutput(s string) (RawOutput, error) {
+	address, amount, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok {
+		return RawOutput{}, fmt.Errorf("output %q must be <address>=<coins>", s)
+	}
+	value, err := strconv.Atoi(amount)
+	if err != nil || value <= 0 {
+		return RawOutput{}, fmt.Errorf("output %q: amount must be a positive number", s)
+	}
+	return RawOutput{Address: address, Amount: value}, nil
+}
+
+// CreateRawTransaction は指定した入力と出力だけから未署名のトランザクションを作成します
+// チェーンやウォレットは参照しないため、入力が存在するか・おつりや手数料が正しいかは送信するまで分かりません
+// 入力の合計から出力の合計を引いた残りが手数料になります
+func CreateRawTransaction(inputs []RawInput, outputs []RawOutput, lockTime int64) (*Transaction, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("at least one input is required")
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("at least one output is required")
+	}
+	if lockTime < 0 {
+		return nil, fmt.Errorf("lock time must not be negative")
+	}
+
+	tx := &Transaction{
+		Version:   CurrentTxVersion,
+		Timestamp: time.Now().Unix(),
+		LockTime:  lockTime,
+	}
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		key := outpointKey(input.TxID, input.OutIndex)
+		if seen[key] {
+			return nil, fmt.Errorf("input %s is listed twice", key)
+		}
+		seen[key] = true
+		tx.Inputs = append(tx.Inputs, TxInput{TxID: input.TxID, OutIndex: input.OutIndex})
+	}
+	for _, output := range outputs {
+		out, err := newOutput(output.Address, output.Amount)
+		if err != nil {
+			return nil, err
+		}
+		tx.Outputs = append(tx.Outputs, out)
+	}
+	tx.ID = tx.Hash()
+	return tx, nil
+}
+
+// EncodeRawTransaction はトランザクションを正規の形式の16進数文字列にします
+func EncodeRawTransaction(tx *Transaction) string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+// DecodeRawTransaction は16進数文字列からトランザクションを復元します（前後の空白は無視します）
+func DecodeRawTransaction(s string) (*Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("raw transaction is not hex: %w", err)
+	}
+	return DeserializeTransaction(data)
+}
+
+// SignRawTransaction はローカルのすべてのウォレットの鍵で、署名できる入力に署名します
+// 署名に使ったウォレットの数と、すべての入力のロックを解除できる（送信できる）かを返します
+// P2SHの入力で scriptSig に償還スクリプトがなければ、wallets に登録されたスクリプトを入れてから署名します
+func (bc *Blockchain) SignRawTransaction(tx *Transaction, wallets *Wallets) (int, bool, error) {
+	if tx.IsCoinbase() {
+		return 0, false, fmt.Errorf("coinbase transaction cannot be signed")
+	}
+	prevTxs, err := bc.previousTransactions(tx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for i, input := range tx.Inputs {
+		output, ok := previousOutput(input, prevTxs)
+		if !ok {
+			return 0, false, fmt.Errorf("input %d: previous output %s not found", i, outpointKey(input.TxID, input.OutIndex))
+		}
+		if class, _ := output.ScriptPubKey.classify(); class != p2shScript || len(input.ScriptSig) > 0 {
+			continue
+		}
+		if script, ok := wallets.Scripts[output.Address()]; ok {
+			tx.Inputs[i].ScriptSig = scriptSigFor(script, nil)
+		}
+	}
+
+	signedBy := 0
+	for _, address := range wallets.GetAddresses() {
+		signed, err := tx.signInputs(wallets.Wallets[address], prevTxs)
+		if err != nil {
+			return signedBy, false, err
+		}
+		if signed {
+			signedBy++
+		}
+	}
+	return signedBy, tx.Verify(prevTxs), nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateRawTransaction(t *testing.T) {
+	t.Run("入力と出力を文字列から組み立てる", func(t *testing.T) {
+		input, err := ParseRawInput("0a0b:1")
+		require.NoError(t, err)
+		output, err := ParseRawOutput(testAddressA + "=25")
+		require.NoError(t, err)
+
+		tx, err := CreateRawTransaction([]RawInput{input}, []RawOutput{output}, 7)
+		require.NoError(t, err)
+		assert.Equal(t, CurrentTxVersion, tx.Version)
+		assert.Equal(t, []byte{0x0a, 0x0b}, tx.Inputs[0].TxID)
+		assert.Equal(t, 1, tx.Inputs[0].OutIndex)
+		assert.Empty(t, tx.Inputs[0].ScriptSig)
+		assert.Equal(t, 25, tx.Outputs[0].Value)
+		assert.Equal(t, testAddressA, tx.Outputs[0].Address())
+		assert.Equal(t, int64(7), tx.LockTime)
+	})
+
+	t.Run("不正な指定は拒否する", func(t *testing.T) {
+		for _, s := range []string{"0a0b", "zz:0", ":0", "0a0b:-1", "0a0b:x"} {
+			_, err := ParseRawInput(s)
+			assert.Error(t, err, s)
+		}
+		for _, s := range []string{testAddressA, testAddressA + "=0", testAddressA + "=x"} {
+			_, err := ParseRawOutput(s)
+			assert.Error(t, err, s)
+		}
+
+		input := RawInput{TxID: []byte{1}, OutIndex: 0}
+		output := RawOutput{Address: testAddressA, Amount: 1}
+		_, err := CreateRawTransaction(nil, []RawOutput{output}, 0)
+		assert.Error(t, err, "入力なし")
+		_, err = CreateRawTransaction([]RawInput{input}, nil, 0)
+		assert.Error(t, err, "出力なし")
+		_, err = CreateRawTransaction([]RawInput{input, input}, []RawOutput{output}, 0)
+		assert.Error(t, err, "同じ入力を2回")
+		_, err = CreateRawTransaction([]RawInput{input}, []RawOutput{{Address: "not-an-address", Amount: 1}}, 0)
+		assert.Error(t, err, "不正なアドレス")
+	})
+
+	t.Run("16進数で往復できる", func(t *testing.T) {
+		tx, err := CreateRawTransaction([]RawInput{{TxID: []byte{1, 2, 3}, OutIndex: 4}}, []RawOutput{{Address: testAddressB, Amount: 9}}, 0)
+		require.NoError(t, err)
+
+		decoded, err := DecodeRawTransaction(" " + EncodeRawTransaction(tx) + "\n")
+		require.NoError(t, err)
+		assert.Equal(t, tx, decoded)
+
+		_, err = DecodeRawTransaction("not hex")
+		assert.Error(t, err)
+		_, err = DecodeRawTransaction(EncodeRawTransaction(tx) + "00")
+		assert.Error(t, err, "余分なバイト")
+	})
+}
+
+func TestSignRawTransaction(t *testing.T) {
+	t.Run("オフラインで作った送金に署名して送信できる", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		utxo := utxoSet.FindUTXO(wallet.GetAddress())[0]
+		tx, err := CreateRawTransaction(
+			[]RawInput{{TxID: utxo.TxID, OutIndex: utxo.OutIndex}},
+			[]RawOutput{{Address: testAddressA, Amount: 30}, {Address: wallet.GetAddress(), Amount: 18}},
+			0,
+		)
+		require.NoError(t, err)
+		id := tx.ID
+
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		signedBy, complete, err := bc.SignRawTransaction(tx, wallets)
+		require.NoError(t, err)
+		assert.Equal(t, 1, signedBy)
+		assert.True(t, complete)
+		assert.Equal(t, id, tx.ID, "署名してもIDは変わらない")
+
+		// 16進数で受け渡してから送信する
+		sent, err := DecodeRawTransaction(EncodeRawTransaction(tx))
+		require.NoError(t, err)
+		require.NoError(t, mempool.Add(sent))
+		entry, ok := mempool.Entry(id)
+		require.True(t, ok)
+		assert.Equal(t, 2, entry.Fee)
+	})
+
+	t.Run("鍵を持たないウォレットは署名しない", func(t *testing.T) {
+		owner, bc, utxoSet, _ := newTestChain(t)
+		utxo := utxoSet.FindUTXO(owner.GetAddress())[0]
+		tx, err := CreateRawTransaction([]RawInput{{TxID: utxo.TxID, OutIndex: utxo.OutIndex}}, []RawOutput{{Address: testAddressA, Amount: 10}}, 0)
+		require.NoError(t, err)
+
+		wallets := NewWallets()
+		_, err = wallets.CreateWallet()
+		require.NoError(t, err)
+		signedBy, complete, err := bc.SignRawTransaction(tx, wallets)
+		require.NoError(t, err)
+		assert.Zero(t, signedBy)
+		assert.False(t, complete)
+	})
+
+	t.Run("マルチシグは署名者ごとに署名を追加する", func(t *testing.T) {
+		var signers []*Wallet
+		var pubKeys [][]byte
+		for i := 0; i < 3; i++ {
+			signer, err := NewWallet()
+			require.NoError(t, err)
+			signers = append(signers, signer)
+			pubKeys = append(pubKeys, publicKeyToBytes(signer.PublicKey))
+		}
+		script, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err = SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+		utxo := utxoSet.FindUTXO(script.Address())[0]
+		tx, err := CreateRawTransaction([]RawInput{{TxID: utxo.TxID, OutIndex: utxo.OutIndex}}, []RawOutput{{Address: testAddressA, Amount: 29}}, 0)
+		require.NoError(t, err)
+
+		// 1人目はスクリプトを登録したウォレットで署名する（scriptSig に償還スクリプトを入れる）
+		first := NewWallets()
+		first.AddWallet(signers[0])
+		first.Scripts[script.Address()] = script
+		_, complete, err := bc.SignRawTransaction(tx, first)
+		require.NoError(t, err)
+		assert.False(t, complete)
+		assert.Error(t, mempool.Add(tx), "署名が足りない")
+
+		// 2人目は16進数を受け取り、スクリプトを登録していなくても署名できる
+		partial, err := DecodeRawTransaction(EncodeRawTransaction(tx))
+		require.NoError(t, err)
+		second := NewWallets()
+		second.AddWallet(signers[1])
+		_, complete, err = bc.SignRawTransaction(partial, second)
+		require.NoError(t, err)
+		assert.True(t, complete)
+		assert.NoError(t, mempool.Add(partial))
+	})
+
+	t.Run("存在しない出力を使う送金には署名できない", func(t *testing.T) {
+		wallet, bc, _, _ := newTestChain(t)
+		tx, err := CreateRawTransaction([]RawInput{{TxID: []byte{0xde, 0xad}, OutIndex: 0}}, []RawOutput{{Address: testAddressA, Amount: 1}}, 0)
+		require.NoError(t, err)
+
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		_, _, err = bc.SignRawTransaction(tx, wallets)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,263 @@
+// Package main implements the raw transaction subcommands for Stage 3.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const rawTxUsage = `❌ Usage:
+  listunspent [--file wallets.dat]
+  createrawtransaction --in <txid>:<index> [--in ...] --out <address>=<coins> [--out ...] [--locktime <height>]
+  signrawtransaction [--file wallets.dat] <hex>
+  decoderawtransaction <hex>
+  sendrawtransaction <hex>
+  (<hex> を省略するか "-" にすると標準入力から読み込みます)`
+
+// stringsFlag は何度でも指定できるフラグです
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runListUnspent はローカルのウォレットのUTXOを createrawtransaction の --in に渡せる形で表示します
+func runListUnspent(args []string) int {
+	fs := flag.NewFlagSet("listunspent", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(wallets.Wallets) == 0 {
+		fmt.Printf("❌ No wallets in %s\n", *fileFlag)
+		return 1
+	}
+	store, _, utxoSet, err := openChain(wallets.Active)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	addresses := append(wallets.GetAddresses(), wallets.GetScriptAddresses()...)
+	for _, address := range addresses {
+		for _, utxo := range utxoSet.FindUTXO(address) {
+			fmt.Printf("%s  %d coins  %s\n", outpointKey(utxo.TxID, utxo.OutIndex), utxo.Output.Value, wallets.DisplayAddress(address))
+		}
+	}
+	return 0
+}
+
+// runCreateRawTransaction は指定した入力と出力から未署名のトランザクションを作り、16進数で表示します
+// チェーンもウォレットも読み込まないため、オフラインのマシンでも作成できます
+func runCreateRawTransaction(args []string) int {
+	fs := flag.NewFlagSet("createrawtransaction", flag.ContinueOnError)
+	var inFlags, outFlags stringsFlag
+	fs.Var(&inFlags, "in", "使用する出力 <TxID>:<出力番号>（複数指定可）")
+	fs.Var(&outFlags, "out", "送金先と金額 <アドレス>=<金額>（複数指定可）")
+	lockTimeFlag := fs.Int64("locktime", 0, "このブロックの高さまでブロックに含められないようにする")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if len(inFlags) == 0 || len(outFlags) == 0 {
+		fmt.Println(rawTxUsage)
+		return 2
+	}
+
+	inputs := make([]RawInput, 0, len(inFlags))
+	for _, s := range inFlags {
+		input, err := ParseRawInput(s)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 2
+		}
+		inputs = append(inputs, input)
+	}
+	outputs := make([]RawOutput, 0, len(outFlags))
+	for _, s := range outFlags {
+		output, err := ParseRawOutput(s)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 2
+		}
+		outputs = append(outputs, output)
+	}
+
+	tx, err := CreateRawTransaction(inputs, outputs, *lockTimeFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	fmt.Println(EncodeRawTransaction(tx))
+	return 0
+}
+
+// runSignRawTransaction はローカルのウォレットの鍵で生のトランザクションに署名し、署名後の16進数を表示します
+func runSignRawTransaction(args []string) int {
+	fs := flag.NewFlagSet("signrawtransaction", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	tx, code := readRawTransactionArg(fs)
+	if tx == nil {
+		return code
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(wallets.Wallets) == 0 {
+		fmt.Printf("❌ No wallets in %s\n", *fileFlag)
+		return 1
+	}
+
+	// 使用する出力のロックスクリプトを調べるためにチェーンを読み込む
+	store, bc, _, err := openChain(wallets.Active)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	signedBy, complete, err := bc.SignRawTransaction(tx, wallets)
+	if err != nil {
+		fmt.Printf("❌ Failed to sign: %v\n", err)
+		return 1
+	}
+	if signedBy == 0 {
+		fmt.Println("⚠️  None of the local wallets can sign this transaction.")
+		return 1
+	}
+
+	fmt.Println(EncodeRawTransaction(tx))
+	if complete {
+		fmt.Fprintf(os.Stderr, "✍️  Signed with %d wallet(s); the transaction is complete.\n", signedBy)
+	} else {
+		fmt.Fprintf(os.Stderr, "✍️  Signed with %d wallet(s); more signatures are needed.\n", signedBy)
+	}
+	return 0
+}
+
+// runDecodeRawTransaction は生のトランザクションの内容を表示します（チェーンは不要です）
+func runDecodeRawTransaction(args []string) int {
+	fs := flag.NewFlagSet("decoderawtransaction", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	tx, code := readRawTransactionArg(fs)
+	if tx == nil {
+		return code
+	}
+
+	fmt.Println("\n📄 Raw Transaction")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("TxID:     %x\n", tx.ID)
+	fmt.Printf("Version:  %d\n", tx.Version)
+	fmt.Printf("Size:     %d bytes\n", tx.Size())
+	if tx.LockTime > 0 {
+		fmt.Printf("Lock:     block %d\n", tx.LockTime)
+	}
+	for i, input := range tx.Inputs {
+		state := "unsigned"
+		if len(input.ScriptSig) > 0 {
+			state = "scriptSig " + input.ScriptSig.String()
+		}
+		if tx.IsCoinbase() {
+			state = "coinbase"
+		}
+		fmt.Printf("In  %d:    %s (%s)\n", i, outpointKey(input.TxID, input.OutIndex), state)
+	}
+	for i, output := range tx.Outputs {
+		fmt.Printf("Out %d:    %d coins to %s\n", i, output.Value, output.ScriptPubKey.Address())
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
+
+// runSendRawTransaction は署名済みの生のトランザクションをメモリプールに追加してマイニングします
+func runSendRawTransaction(args []string) int {
+	fs := flag.NewFlagSet("sendrawtransaction", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	tx, code := readRawTransactionArg(fs)
+	if tx == nil {
+		return code
+	}
+
+	wallet, err := loadOrCreateWallet()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+	store, bc, utxoSet, err := openChain(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	mempool := NewMempool(bc, utxoSet)
+	if err := mempool.Add(tx); err != nil {
+		printSendError(err)
+		return 1
+	}
+	entry, _ := mempool.Entry(tx.ID)
+	block, metrics, err := mempool.MineBlock(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ Mining failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("\n✅ Raw transaction sent and mined!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("TxID:      %x\n", tx.ID)
+	fmt.Printf("Fee:       %d coins\n", entry.Fee)
+	fmt.Printf("Block #%d: %s (%d attempts)\n", block.Index, truncateHash(block.Hash), metrics.Attempts)
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
+
+// readRawTransactionArg は引数（省略時または "-" なら標準入力）の16進数からトランザクションを復元します
+// 失敗した場合は nil と終了コードを返します
+func readRawTransactionArg(fs *flag.FlagSet) (*Transaction, int) {
+	if fs.NArg() > 1 {
+		fmt.Println(rawTxUsage)
+		return nil, 2
+	}
+
+	text := fs.Arg(0)
+	if text == "" || text == "-" {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		if !scanner.Scan() {
+			fmt.Println(rawTxUsage)
+			return nil, 2
+		}
+		text = scanner.Text()
+	}
+
+	tx, err := DecodeRawTransaction(text)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return nil, 2
+	}
+	return tx, 0
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIndexes(t *testing.T) {
+	t.Run("インデックスが最新ブロックを反映していれば問題なし", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+
+		check, err := store.CheckIndexes()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), check.Height)
+		assert.Equal(t, bc.GetLatestBlock().Hash, check.Tip)
+		assert.Empty(t, check.Problems())
+	})
+
+	t.Run("空のファイルは確認しない", func(t *testing.T) {
+		store, err := OpenChainStore(filepath.Join(t.TempDir(), "chain.db"))
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+
+		check, err := store.CheckIndexes()
+		require.NoError(t, err)
+		assert.Empty(t, check.Problems())
+	})
+
+	t.Run("食い違うインデックスは開くときに作り直す", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		genesis := bc.Blocks[0].Hash
+		index, err := store.LoadTxIndex()
+		require.NoError(t, err)
+		require.NoError(t, store.ReplaceTxIndex(index, genesis))
+		require.NoError(t, store.ReplaceUTXOs(nil, ""))
+
+		check, err := store.CheckIndexes()
+		require.NoError(t, err)
+		problems := check.Problems()
+		require.Len(t, problems, 2)
+		assert.Contains(t, problems[0], "utxo set has no recorded tip")
+		assert.Contains(t, problems[1], "txindex is at "+truncateHash(genesis))
+		require.NoError(t, store.Close())
+
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		reopened, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		reopenedSet, reindexed, err := OpenUTXOSet(store, reopened)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		assert.True(t, reindexed)
+		assert.Equal(t, 20, reopenedSet.GetBalance(testAddressA))
+
+		check, err = store.CheckIndexes()
+		require.NoError(t, err)
+		assert.Empty(t, check.Problems())
+		assert.Equal(t, reopened.GetLatestBlock().Hash, check.TxIndexTip)
+	})
+
+	t.Run("最新ブロックが壊れていればエラー", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		_, _, err = OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		corrupted := *bc.GetLatestBlock()
+		corrupted.Nonce++
+		require.NoError(t, store.SaveBlock(&corrupted))
+
+		_, err = store.CheckIndexes()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "chain tip #0 is corrupted")
+	})
+}
+
+func TestReindexChain(t *testing.T) {
+	t.Run("ブロックのデータからUTXOセットとトランザクションインデックスを作り直す", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+
+		// 最新ブロックの記録は正しいまま中身だけ失われたインデックスは、起動時の確認では見つからない
+		tip := bc.GetLatestBlock().Hash
+		require.NoError(t, store.ReplaceUTXOs(nil, tip))
+		require.NoError(t, store.ReplaceTxIndex(map[string]TxLocation{}, tip))
+		require.Error(t, utxoSet.VerifyPersisted(bc))
+
+		result, err := Reindex(bc, utxoSet)
+		require.NoError(t, err)
+		assert.Equal(t, ReindexResult{Blocks: 2, Transactions: 3, UTXOs: 3}, result)
+		assert.NoError(t, utxoSet.VerifyPersisted(bc))
+		index, err := store.LoadTxIndex()
+		require.NoError(t, err)
+		assert.Len(t, index, 3)
+	})
+}
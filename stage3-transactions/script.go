@@ -0,0 +1,636 @@
+// Package main implements a small stack-based script interpreter for Stage 3.
+// Outputs are locked by a scriptPubKey and inputs unlock them with a scriptSig, as in Bitcoin.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// Script はスタックマシンで実行するスクリプト（命令とデータのバイト列）です
+type Script []byte
+
+// オペコード（値はBitcoinと同じ。OpLegacyHash のみminicoin独自）
+const (
+	Op0                   byte = 0x00 // 空のバイト列を積む（偽）
+	OpPushData1           byte = 0x4c // 次の1バイトが長さのデータを積む
+	OpPushData2           byte = 0x4d // 次の2バイト（リトルエンディアン）が長さのデータを積む
+	Op1                   byte = 0x51 // 数値1を積む（真）。OP_2〜OP_16 は 0x52〜0x60
+	Op16                  byte = 0x60 // 数値16を積む
+	OpVerify              byte = 0x69 // 先頭が偽なら失敗
+	OpReturn              byte = 0x6a // 必ず失敗する（解除できない出力にデータを書き込むのに使う）
+	OpDrop                byte = 0x75 // 先頭を捨てる
+	OpDup                 byte = 0x76 // 先頭を複製する
+	OpEqual               byte = 0x87 // 先頭の2つが等しいかを積む
+	OpEqualVerify         byte = 0x88 // OpEqual + OpVerify
+	OpHash160             byte = 0xa9 // RIPEMD160(SHA256(x)) に置き換える
+	OpCheckSig            byte = 0xac // 署名と公開鍵を取り出し、検証結果を積む
+	OpCheckMultisig       byte = 0xae // m個の署名とn個の公開鍵を取り出し、検証結果を積む
+	OpCheckLockTimeVerify byte = 0xb1 // 先頭のブロック高さにトランザクションのロック時刻が達していなければ失敗
+	OpLegacyHash          byte = 0xc0 // 公開鍵を旧形式のアドレスのハッシュ（SHA-256を2回適用した先頭20バイト）に置き換える
+)
+
+// スクリプトの制限
+const (
+	MaxScriptSize     = 10000 // スクリプトの最大バイト数
+	maxStackSize      = 1000  // スタックに積める要素の最大数
+	maxScriptNumLen   = 4     // 算術に使う数値の最大バイト数
+	maxLockTimeNumLen = 5     // OP_CHECKLOCKTIMEVERIFY の数値の最大バイト数
+)
+
+// ErrScriptFailed はスクリプトの実行結果が偽だったことを表します
+var ErrScriptFailed = errors.New("script evaluated to false")
+
+var opcodeNames = map[byte]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckMultisig:       "OP_CHECKMULTISIG",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpLegacyHash:          "OP_LEGACYHASH",
+}
+
+// scriptOp は解析したスクリプトの1命令です（データを積む命令なら data に値が入ります）
+type scriptOp struct {
+	opcode byte
+	data   []byte
+}
+
+// isPush は命令がデータや数値を積むだけの命令かを返します
+func (op scriptOp) isPush() bool {
+	return op.opcode <= OpPushData2 || (op.opcode >= Op1 && op.opcode <= Op16)
+}
+
+// AddOp はスクリプトの末尾に命令を追加します
+func (s Script) AddOp(opcode byte) Script {
+	return append(s, opcode)
+}
+
+// AddData はスクリプトの末尾にデータを積む命令を追加します
+func (s Script) AddData(data []byte) Script {
+	switch {
+	case len(data) < int(OpPushData1):
+		s = append(s, byte(len(data)))
+	case len(data) <= 0xff:
+		s = append(s, OpPushData1, byte(len(data)))
+	default:
+		s = append(s, OpPushData2, 0, 0)
+		binary.LittleEndian.PutUint16(s[len(s)-2:], uint16(len(data))) // #nosec G115 -- MaxScriptSize 未満のデータのみ扱う
+	}
+	return append(s, data...)
+}
+
+// AddInt はスクリプトの末尾に数値を積む命令を追加します（0〜16は1バイトの命令になります）
+func (s Script) AddInt(n int64) Script {
+	switch {
+	case n == 0:
+		return s.AddOp(Op0)
+	case n >= 1 && n <= 16:
+		return s.AddOp(Op1 + byte(n-1))
+	default:
+		return s.AddData(encodeScriptNum(n))
+	}
+}
+
+// parse はスクリプトを命令の並びに分解します
+func (s Script) parse() ([]scriptOp, error) {
+	if len(s) > MaxScriptSize {
+		return nil, fmt.Errorf("script is %d bytes, limit is %d", len(s), MaxScriptSize)
+	}
+
+	var ops []scriptOp
+	for i := 0; i < len(s); {
+		opcode := s[i]
+		i++
+
+		var size int
+		switch {
+		case opcode > Op0 && opcode < OpPushData1:
+			size = int(opcode)
+		case opcode == OpPushData1:
+			if i+1 > len(s) {
+				return nil, fmt.Errorf("truncated OP_PUSHDATA1 at byte %d", i-1)
+			}
+			size = int(s[i])
+			i++
+		case opcode == OpPushData2:
+			if i+2 > len(s) {
+				return nil, fmt.Errorf("truncated OP_PUSHDATA2 at byte %d", i-1)
+			}
+			size = int(binary.LittleEndian.Uint16(s[i:]))
+			i += 2
+		default:
+			ops = append(ops, scriptOp{opcode: opcode})
+			continue
+		}
+
+		if i+size > len(s) {
+			return nil, fmt.Errorf("push of %d bytes at byte %d runs past the end of the script", size, i)
+		}
+		ops = append(ops, scriptOp{opcode: opcode, data: s[i : i+size]})
+		i += size
+	}
+	return ops, nil
+}
+
+// pushes はデータを積むだけのスクリプトから、積まれるデータを順に返します
+func (s Script) pushes() ([][]byte, error) {
+	ops, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([][]byte, len(ops))
+	for i, op := range ops {
+		if !op.isPush() {
+			return nil, fmt.Errorf("script is not push-only: %s", opcodeName(op.opcode))
+		}
+		data[i] = op.pushValue()
+	}
+	return data, nil
+}
+
+// pushValue はデータを積む命令が積む値を返します
+func (op scriptOp) pushValue() []byte {
+	if op.opcode >= Op1 && op.opcode <= Op16 {
+		return encodeScriptNum(int64(op.opcode-Op1) + 1)
+	}
+	if op.data == nil {
+		return []byte{}
+	}
+	return op.data
+}
+
+// String はスクリプトを "OP_DUP OP_HASH160 <16進数> ..." のような形式で表示します
+func (s Script) String() string {
+	ops, err := s.parse()
+	if err != nil {
+		return fmt.Sprintf("[invalid script: %v]", err)
+	}
+
+	words := make([]string, len(ops))
+	for i, op := range ops {
+		words[i] = op.String()
+	}
+	return strings.Join(words, " ")
+}
+
+// String は命令を表示します（データを積む命令はデータの16進数）
+func (op scriptOp) String() string {
+	if op.opcode > Op0 && op.opcode <= OpPushData2 {
+		return hex.EncodeToString(op.data)
+	}
+	return opcodeName(op.opcode)
+}
+
+// opcodeName はオペコードの名前を返します
+func opcodeName(opcode byte) string {
+	if name, ok := opcodeNames[opcode]; ok {
+		return name
+	}
+	if opcode >= Op1 && opcode <= Op16 {
+		return fmt.Sprintf("OP_%d", opcode-Op1+1)
+	}
+	return fmt.Sprintf("OP_UNKNOWN(0x%02x)", opcode)
+}
+
+// NewP2PKHScript は公開鍵ハッシュ宛て（P2PKH）のロックスクリプトを作成します
+// OP_DUP OP_HASH160 <公開鍵ハッシュ> OP_EQUALVERIFY OP_CHECKSIG
+func NewP2PKHScript(pubKeyHash []byte) Script {
+	return Script{}.AddOp(OpDup).AddOp(OpHash160).AddData(pubKeyHash).AddOp(OpEqualVerify).AddOp(OpCheckSig)
+}
+
+// NewLegacyP2PKHScript は旧形式（16進数）のアドレス宛てのロックスクリプトを作成します
+// OP_DUP OP_LEGACYHASH <旧形式のハッシュ> OP_EQUALVERIFY OP_CHECKSIG
+func NewLegacyP2PKHScript(legacyHash []byte) Script {
+	return Script{}.AddOp(OpDup).AddOp(OpLegacyHash).AddData(legacyHash).AddOp(OpEqualVerify).AddOp(OpCheckSig)
+}
+
+// NewP2SHScript はスクリプトハッシュ宛て（P2SH）のロックスクリプトを作成します
+// OP_HASH160 <スクリプトハッシュ> OP_EQUAL
+func NewP2SHScript(scriptHash []byte) Script {
+	return Script{}.AddOp(OpHash160).AddData(scriptHash).AddOp(OpEqual)
+}
+
+// scriptClass はロックスクリプトの種類です
+type scriptClass int
+
+const (
+	nonStandardScript scriptClass = iota
+	p2pkhScript
+	legacyP2PKHScript
+	p2shScript
+)
+
+// classify はロックスクリプトの種類と、含まれるハッシュを返します
+func (s Script) classify() (scriptClass, []byte) {
+	ops, err := s.parse()
+	if err != nil {
+		return nonStandardScript, nil
+	}
+
+	switch {
+	case len(ops) == 5 && ops[0].opcode == OpDup && ops[2].data != nil &&
+		ops[3].opcode == OpEqualVerify && ops[4].opcode == OpCheckSig:
+		switch ops[1].opcode {
+		case OpHash160:
+			return p2pkhScript, ops[2].data
+		case OpLegacyHash:
+			return legacyP2PKHScript, ops[2].data
+		}
+	case len(ops) == 3 && ops[0].opcode == OpHash160 && len(ops[1].data) == common.PubKeyHashLen &&
+		ops[2].opcode == OpEqual:
+		return p2shScript, ops[1].data
+	}
+	return nonStandardScript, nil
+}
+
+// Address はロックスクリプトの受取先のアドレスを返します（標準の形式でなければ空文字列）
+// 旧形式のアドレス宛ては、同じハッシュのBase58Checkアドレスになります
+func (s Script) Address() string {
+	switch class, hash := s.classify(); class {
+	case p2pkhScript, legacyP2PKHScript:
+		return common.PubKeyHashToAddress(hash)
+	case p2shScript:
+		return common.ScriptHashToAddress(hash)
+	default:
+		return ""
+	}
+}
+
+// sigChecker はスクリプトの署名とロック時刻の検証に必要な、トランザクションの情報を提供します
+type sigChecker interface {
+	CheckSig(signature, pubKey []byte) bool
+	CheckSignatureEncoding(signature []byte) error
+	CheckLockTime(lockTime int64) bool
+}
+
+// ScriptStep はスクリプトの命令を1つ実行した結果です（スクリプトのデバッガーに渡します）
+type ScriptStep struct {
+	Phase string   // 実行中のスクリプト（scriptSig, scriptPubKey, redeem script）
+	Index int      // スクリプト内の命令の位置（0から）
+	Op    string   // 実行した命令
+	Stack [][]byte // 命令を実行した後のスタック（底から順）
+	Err   error    // 命令が失敗した場合のエラー
+}
+
+// scriptStepHook は命令を1つ実行するごとに呼ばれます
+type scriptStepHook func(ScriptStep)
+
+// VerifyScript は scriptSig と scriptPubKey を順に実行し、入力が出力のロックを解除できるかを検証します
+// scriptPubKey がP2SHなら、scriptSig の最後に積んだ償還スクリプトを残りのスタックでさらに実行します
+func VerifyScript(scriptSig, scriptPubKey Script, checker sigChecker) error {
+	return verifyScript(scriptSig, scriptPubKey, checker, nil)
+}
+
+// verifyScript は VerifyScript と同じ検証を行い、命令を1つ実行するごとに hook を呼びます（nilなら呼びません）
+func verifyScript(scriptSig, scriptPubKey Script, checker sigChecker, hook scriptStepHook) error {
+	// scriptSig はデータを積むだけでなければならない（署名の対象外なので命令を入れさせない）
+	if _, err := scriptSig.pushes(); err != nil {
+		return fmt.Errorf("scriptSig: %w", err)
+	}
+
+	var stack scriptStack
+	if err := stack.execute("scriptSig", scriptSig, checker, hook); err != nil {
+		return fmt.Errorf("scriptSig: %w", err)
+	}
+	p2shStack := stack.clone()
+
+	if err := stack.execute("scriptPubKey", scriptPubKey, checker, hook); err != nil {
+		return fmt.Errorf("scriptPubKey: %w", err)
+	}
+	if !stack.topIsTrue() {
+		return ErrScriptFailed
+	}
+
+	if class, _ := scriptPubKey.classify(); class != p2shScript {
+		return nil
+	}
+
+	// P2SH: scriptSig の最後に積んだ償還スクリプトを実行する
+	redeemScript, err := p2shStack.pop()
+	if err != nil {
+		return fmt.Errorf("redeem script: %w", err)
+	}
+	if err := p2shStack.execute("redeem script", Script(redeemScript), checker, hook); err != nil {
+		return fmt.Errorf("redeem script: %w", err)
+	}
+	if !p2shStack.topIsTrue() {
+		return ErrScriptFailed
+	}
+	return nil
+}
+
+// scriptStack はスクリプトを実行するスタックです
+type scriptStack [][]byte
+
+func (st *scriptStack) push(data []byte) error {
+	if len(*st) >= maxStackSize {
+		return fmt.Errorf("stack size exceeds %d", maxStackSize)
+	}
+	*st = append(*st, data)
+	return nil
+}
+
+func (st *scriptStack) pop() ([]byte, error) {
+	if len(*st) == 0 {
+		return nil, fmt.Errorf("stack is empty")
+	}
+	top := (*st)[len(*st)-1]
+	*st = (*st)[:len(*st)-1]
+	return top, nil
+}
+
+func (st *scriptStack) popInt(maxLen int) (int64, error) {
+	data, err := st.pop()
+	if err != nil {
+		return 0, err
+	}
+	return decodeScriptNum(data, maxLen)
+}
+
+func (st *scriptStack) pushBool(v bool) error {
+	if v {
+		return st.push([]byte{1})
+	}
+	return st.push([]byte{})
+}
+
+func (st scriptStack) clone() scriptStack {
+	return append(scriptStack(nil), st...)
+}
+
+func (st scriptStack) topIsTrue() bool {
+	return len(st) > 0 && castToBool(st[len(st)-1])
+}
+
+// execute はスクリプトの命令を順に実行します
+// hook がnilでなければ、命令を1つ実行するごとに phase（実行中のスクリプトの名前）と実行後のスタックを渡します
+func (st *scriptStack) execute(phase string, script Script, checker sigChecker, hook scriptStepHook) error {
+	ops, err := script.parse()
+	if err != nil {
+		return err
+	}
+
+	for i, op := range ops {
+		err := st.step(op, checker)
+		if hook != nil {
+			hook(ScriptStep{Phase: phase, Index: i, Op: op.String(), Stack: st.clone(), Err: err})
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", opcodeName(op.opcode), err)
+		}
+	}
+	return nil
+}
+
+// step は1つの命令を実行します
+func (st *scriptStack) step(op scriptOp, checker sigChecker) error {
+	if op.isPush() {
+		return st.push(op.pushValue())
+	}
+
+	switch op.opcode {
+	case OpVerify:
+		top, err := st.pop()
+		if err != nil {
+			return err
+		}
+		if !castToBool(top) {
+			return ErrScriptFailed
+		}
+
+	case OpReturn:
+		return fmt.Errorf("script is unspendable")
+
+	case OpDrop:
+		_, err := st.pop()
+		return err
+
+	case OpDup:
+		if len(*st) == 0 {
+			return fmt.Errorf("stack is empty")
+		}
+		return st.push((*st)[len(*st)-1])
+
+	case OpEqual, OpEqualVerify:
+		a, err := st.pop()
+		if err != nil {
+			return err
+		}
+		b, err := st.pop()
+		if err != nil {
+			return err
+		}
+		if op.opcode == OpEqualVerify {
+			if !bytes.Equal(a, b) {
+				return ErrScriptFailed
+			}
+			return nil
+		}
+		return st.pushBool(bytes.Equal(a, b))
+
+	case OpHash160:
+		data, err := st.pop()
+		if err != nil {
+			return err
+		}
+		return st.push(common.PublicKeyHash(data))
+
+	case OpLegacyHash:
+		data, err := st.pop()
+		if err != nil {
+			return err
+		}
+		pubKey, err := bytesToPublicKey(data)
+		if err != nil {
+			return err
+		}
+		hash, err := hex.DecodeString(common.LegacyPublicKeyToAddress(pubKey))
+		if err != nil {
+			return err
+		}
+		return st.push(hash)
+
+	case OpCheckSig:
+		pubKey, err := st.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := st.pop()
+		if err != nil {
+			return err
+		}
+		if err := checkSignatureEncoding(checker, signature); err != nil {
+			return err
+		}
+		return st.pushBool(checker.CheckSig(signature, pubKey))
+
+	case OpCheckMultisig:
+		return st.checkMultisig(checker)
+
+	case OpCheckLockTimeVerify:
+		if len(*st) == 0 {
+			return fmt.Errorf("stack is empty")
+		}
+		lockTime, err := decodeScriptNum((*st)[len(*st)-1], maxLockTimeNumLen)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return fmt.Errorf("negative lock time %d", lockTime)
+		}
+		if !checker.CheckLockTime(lockTime) {
+			return fmt.Errorf("transaction lock time is below %d", lockTime)
+		}
+
+	default:
+		return fmt.Errorf("unsupported opcode")
+	}
+	return nil
+}
+
+// checkMultisig は OP_CHECKMULTISIG を実行します
+// スタック: <ダミー> <署名1>...<署名m> <m> <公開鍵1>...<公開鍵n> <n>
+// 署名は公開鍵と同じ順に並んでいる必要があります（Bitcoinと同じく、空のダミーを1つ余分に取り出します）
+func (st *scriptStack) checkMultisig(checker sigChecker) error {
+	n, err := st.popInt(maxScriptNumLen)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > MaxMultisigKeys {
+		return fmt.Errorf("key count %d out of range", n)
+	}
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pubKeys[i], err = st.pop(); err != nil {
+			return err
+		}
+	}
+
+	m, err := st.popInt(maxScriptNumLen)
+	if err != nil {
+		return err
+	}
+	if m < 0 || m > n {
+		return fmt.Errorf("signature count %d out of range", m)
+	}
+	signatures := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if signatures[i], err = st.pop(); err != nil {
+			return err
+		}
+	}
+
+	// ダミーは空でなければならない（NULLDUMMY）。任意のバイト列を許すと第三者が scriptSig を書き換えられる
+	dummy, err := st.pop()
+	if err != nil {
+		return fmt.Errorf("missing dummy element: %w", err)
+	}
+	if len(dummy) != 0 {
+		return fmt.Errorf("dummy element must be empty, got %d bytes", len(dummy))
+	}
+	for _, signature := range signatures {
+		if err := checkSignatureEncoding(checker, signature); err != nil {
+			return err
+		}
+	}
+
+	// 署名ごとに、まだ使っていない公開鍵を順に試す
+	key := 0
+	for _, signature := range signatures {
+		for key < len(pubKeys) && !checker.CheckSig(signature, pubKeys[key]) {
+			key++
+		}
+		if key == len(pubKeys) {
+			return st.pushBool(false)
+		}
+		key++
+	}
+	return st.pushBool(true)
+}
+
+// checkSignatureEncoding は空でない署名が正規形かを検証します（空の署名は検証を失敗させるだけの正しい値です）
+// 正規形でない署名は、検証を通るかどうかにかかわらずスクリプトをエラーにします（第三者による署名の書き換えを防ぐ）
+func checkSignatureEncoding(checker sigChecker, signature []byte) error {
+	if len(signature) == 0 {
+		return nil
+	}
+	if err := checker.CheckSignatureEncoding(signature); err != nil {
+		return fmt.Errorf("non-canonical signature: %w", err)
+	}
+	return nil
+}
+
+// castToBool はスタックの値を真偽値として解釈します（0と負の0は偽）
+func castToBool(data []byte) bool {
+	for i, b := range data {
+		if b != 0 {
+			return !(i == len(data)-1 && b == 0x80)
+		}
+	}
+	return false
+}
+
+// encodeScriptNum は数値をスクリプトの数値形式（最小長のリトルエンディアン、最上位ビットが符号）にします
+func encodeScriptNum(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	negative := n < 0
+	abs := uint64(n) // #nosec G115 -- 負の値は下で2の補数から絶対値に戻す
+	if negative {
+		abs = uint64(-n) // #nosec G115 -- 同上
+	}
+
+	var result []byte
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+	if result[len(result)-1]&0x80 != 0 {
+		if negative {
+			result = append(result, 0x80)
+		} else {
+			result = append(result, 0x00)
+		}
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+// decodeScriptNum はスクリプトの数値形式を数値に戻します
+func decodeScriptNum(data []byte, maxLen int) (int64, error) {
+	if len(data) > maxLen {
+		return 0, fmt.Errorf("number is %d bytes, limit is %d", len(data), maxLen)
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, b := range data {
+		n |= int64(b) << (8 * i)
+	}
+	if data[len(data)-1]&0x80 != 0 {
+		n &^= int64(0x80) << (8 * (len(data) - 1))
+		return -n, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChecker は署名が「sig:」+公開鍵のときだけ正しいとみなす検証器です
+type fakeChecker struct {
+	lockTime int64
+}
+
+func (c fakeChecker) CheckSig(signature, pubKey []byte) bool {
+	return bytes.Equal(signature, append([]byte("sig:"), pubKey...))
+}
+
+func (c fakeChecker) CheckSignatureEncoding([]byte) error {
+	return nil
+}
+
+func (c fakeChecker) CheckLockTime(lockTime int64) bool {
+	return c.lockTime >= lockTime
+}
+
+func fakeSig(pubKey []byte) []byte {
+	return append([]byte("sig:"), pubKey...)
+}
+
+func TestScriptBuild(t *testing.T) {
+	t.Run("データの長さに応じた命令で積む", func(t *testing.T) {
+		assert.Equal(t, Script{3, 1, 2, 3}, Script{}.AddData([]byte{1, 2, 3}))
+
+		long := Script{}.AddData(make([]byte, 100))
+		assert.Equal(t, []byte{OpPushData1, 100}, []byte(long[:2]))
+
+		longer := Script{}.AddData(make([]byte, 300))
+		assert.Equal(t, []byte{OpPushData2, 0x2c, 0x01}, []byte(longer[:3]))
+
+		for _, script := range []Script{long, longer} {
+			pushes, err := script.pushes()
+			require.NoError(t, err)
+			require.Len(t, pushes, 1)
+		}
+	})
+
+	t.Run("小さい数値は1バイトの命令になる", func(t *testing.T) {
+		assert.Equal(t, Script{Op0}, Script{}.AddInt(0))
+		assert.Equal(t, Script{Op1}, Script{}.AddInt(1))
+		assert.Equal(t, Script{Op16}, Script{}.AddInt(16))
+		assert.Equal(t, Script{1, 17}, Script{}.AddInt(17))
+	})
+
+	t.Run("途中で切れたスクリプトはエラー", func(t *testing.T) {
+		_, err := Script{5, 1, 2}.parse()
+		assert.Error(t, err)
+		_, err = Script{OpPushData1}.parse()
+		assert.Error(t, err)
+		_, err = Script{OpPushData2, 1}.parse()
+		assert.Error(t, err)
+	})
+
+	t.Run("逆アセンブルして表示", func(t *testing.T) {
+		hash := bytes.Repeat([]byte{0xab}, common.PubKeyHashLen)
+
+		assert.Equal(t, "OP_DUP OP_HASH160 "+common.BytesToHex(hash)+" OP_EQUALVERIFY OP_CHECKSIG", NewP2PKHScript(hash).String())
+		assert.Equal(t, "OP_0 OP_2 OP_UNKNOWN(0xff)", Script{Op0, Op1 + 1, 0xff}.String())
+		assert.Contains(t, Script{5}.String(), "invalid script")
+	})
+}
+
+func TestScriptNum(t *testing.T) {
+	t.Run("エンコードして戻せる", func(t *testing.T) {
+		for _, n := range []int64{0, 1, -1, 127, 128, -128, 255, 256, 1 << 20, -(1 << 20), 1<<32 - 1} {
+			decoded, err := decodeScriptNum(encodeScriptNum(n), maxLockTimeNumLen)
+			require.NoError(t, err)
+			assert.Equal(t, n, decoded)
+		}
+		assert.Equal(t, []byte{0x80, 0x00}, encodeScriptNum(128))
+		assert.Equal(t, []byte{0x81}, encodeScriptNum(-1))
+	})
+
+	t.Run("長すぎる数値はエラー", func(t *testing.T) {
+		_, err := decodeScriptNum([]byte{1, 2, 3, 4, 5}, maxScriptNumLen)
+		assert.Error(t, err)
+	})
+
+	t.Run("真偽値として解釈", func(t *testing.T) {
+		assert.False(t, castToBool(nil))
+		assert.False(t, castToBool([]byte{0, 0}))
+		assert.False(t, castToBool([]byte{0, 0x80}))
+		assert.True(t, castToBool([]byte{0, 1}))
+		assert.True(t, castToBool([]byte{0x80, 0}))
+	})
+}
+
+func TestScriptClassify(t *testing.T) {
+	hash := bytes.Repeat([]byte{1}, common.PubKeyHashLen)
+
+	t.Run("標準のロックスクリプトからアドレスを得る", func(t *testing.T) {
+		assert.Equal(t, common.PubKeyHashToAddress(hash), NewP2PKHScript(hash).Address())
+		assert.Equal(t, common.PubKeyHashToAddress(hash), NewLegacyP2PKHScript(hash).Address())
+		assert.Equal(t, common.ScriptHashToAddress(hash), NewP2SHScript(hash).Address())
+	})
+
+	t.Run("標準でないスクリプトはアドレスなし", func(t *testing.T) {
+		assert.Empty(t, Script{}.AddOp(Op1).Address())
+		assert.Empty(t, Script{}.AddOp(OpHash160).AddData([]byte{1}).AddOp(OpEqual).Address())
+	})
+}
+
+func TestVerifyScript(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+	pubKey := publicKeyToBytes(wallet.PublicKey)
+	lock := NewP2PKHScript(common.PublicKeyHash(pubKey))
+
+	t.Run("P2PKH: 署名と公開鍵でロックを解除できる", func(t *testing.T) {
+		scriptSig := Script{}.AddData(fakeSig(pubKey)).AddData(pubKey)
+		assert.NoError(t, VerifyScript(scriptSig, lock, fakeChecker{}))
+	})
+
+	t.Run("P2PKH: 公開鍵ハッシュが違うと失敗", func(t *testing.T) {
+		other := testPubKeys(t, 1)[0]
+		scriptSig := Script{}.AddData(fakeSig(other)).AddData(other)
+		assert.ErrorIs(t, VerifyScript(scriptSig, lock, fakeChecker{}), ErrScriptFailed)
+	})
+
+	t.Run("P2PKH: 署名が違うと失敗", func(t *testing.T) {
+		scriptSig := Script{}.AddData([]byte("forged")).AddData(pubKey)
+		assert.ErrorIs(t, VerifyScript(scriptSig, lock, fakeChecker{}), ErrScriptFailed)
+	})
+
+	t.Run("データを積むだけでないscriptSigは拒否", func(t *testing.T) {
+		scriptSig := Script{}.AddData(fakeSig(pubKey)).AddData(pubKey).AddOp(OpDup).AddOp(OpDrop)
+		err := VerifyScript(scriptSig, lock, fakeChecker{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "push-only")
+	})
+
+	t.Run("空のスクリプトは失敗", func(t *testing.T) {
+		assert.Error(t, VerifyScript(nil, lock, fakeChecker{}))
+		assert.ErrorIs(t, VerifyScript(nil, nil, fakeChecker{}), ErrScriptFailed)
+	})
+
+	t.Run("P2SH: 償還スクリプトまで実行する", func(t *testing.T) {
+		pubKeys := testPubKeys(t, 3)
+		redeem, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		lock := NewP2SHScript(redeem.Hash())
+
+		scriptSig := scriptSigFor(redeem, [][]byte{fakeSig(pubKeys[0]), nil, fakeSig(pubKeys[2])})
+		assert.NoError(t, VerifyScript(scriptSig, lock, fakeChecker{}))
+
+		// 償還スクリプトのハッシュは一致するが、署名が足りない
+		scriptSig = scriptSigFor(redeem, [][]byte{fakeSig(pubKeys[0])})
+		assert.Error(t, VerifyScript(scriptSig, lock, fakeChecker{}))
+
+		// 署名の順序が公開鍵の順と異なる
+		scriptSig = Script{}.AddOp(Op0).AddData(fakeSig(pubKeys[2])).AddData(fakeSig(pubKeys[0])).AddData(redeem.Serialize())
+		assert.ErrorIs(t, VerifyScript(scriptSig, lock, fakeChecker{}), ErrScriptFailed)
+	})
+
+	t.Run("OP_CHECKMULTISIG: 空でないダミーは拒否（NULLDUMMY）", func(t *testing.T) {
+		pubKeys := testPubKeys(t, 3)
+		redeem, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		lock := NewP2SHScript(redeem.Hash())
+
+		// 署名は正しいが、ダミーを任意のバイト列に差し替えている
+		scriptSig := Script{}.AddData([]byte{0x01}).AddData(fakeSig(pubKeys[0])).AddData(fakeSig(pubKeys[2])).AddData(redeem.Serialize())
+		err = VerifyScript(scriptSig, lock, fakeChecker{})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrScriptFailed)
+		assert.Contains(t, err.Error(), "dummy element must be empty")
+	})
+
+	t.Run("OP_CHECKLOCKTIMEVERIFY はロック時刻を比較する", func(t *testing.T) {
+		redeem, err := NewTimelockScript(pubKey, 300)
+		require.NoError(t, err)
+		lock := NewP2SHScript(redeem.Hash())
+		scriptSig := scriptSigFor(redeem, [][]byte{fakeSig(pubKey)})
+
+		assert.NoError(t, VerifyScript(scriptSig, lock, fakeChecker{lockTime: 300}))
+		err = VerifyScript(scriptSig, lock, fakeChecker{lockTime: 299})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "OP_CHECKLOCKTIMEVERIFY")
+	})
+}
+
+func TestVerifyScriptSteps(t *testing.T) {
+	pubKey := testPubKeys(t, 1)[0]
+	lock := NewP2PKHScript(common.PublicKeyHash(pubKey))
+
+	t.Run("命令ごとに実行後のスタックを渡す", func(t *testing.T) {
+		var steps []ScriptStep
+		scriptSig := Script{}.AddData(fakeSig(pubKey)).AddData(pubKey)
+
+		require.NoError(t, verifyScript(scriptSig, lock, fakeChecker{}, func(step ScriptStep) {
+			steps = append(steps, step)
+		}))
+
+		require.Len(t, steps, 7)
+		assert.Equal(t, "scriptSig", steps[0].Phase)
+		assert.Equal(t, [][]byte{fakeSig(pubKey)}, steps[0].Stack)
+		assert.Equal(t, "scriptPubKey", steps[2].Phase)
+		assert.Equal(t, 0, steps[2].Index)
+		assert.Equal(t, "OP_DUP", steps[2].Op)
+		assert.Len(t, steps[2].Stack, 3)
+		assert.Equal(t, "OP_CHECKSIG", steps[6].Op)
+		assert.Equal(t, [][]byte{{1}}, steps[6].Stack)
+	})
+
+	t.Run("失敗した命令でエラーを渡して止まる", func(t *testing.T) {
+		var steps []ScriptStep
+		other := testPubKeys(t, 1)[0]
+		scriptSig := Script{}.AddData(fakeSig(other)).AddData(other)
+
+		err := verifyScript(scriptSig, lock, fakeChecker{}, func(step ScriptStep) {
+			steps = append(steps, step)
+		})
+
+		require.Error(t, err)
+		last := steps[len(steps)-1]
+		assert.Equal(t, "OP_EQUALVERIFY", last.Op)
+		assert.Error(t, last.Err)
+	})
+
+	t.Run("P2SHは償還スクリプトの命令も渡す", func(t *testing.T) {
+		pubKeys := testPubKeys(t, 2)
+		redeem, err := NewMultisigScript(1, pubKeys)
+		require.NoError(t, err)
+		var phases []string
+
+		require.NoError(t, verifyScript(scriptSigFor(redeem, [][]byte{fakeSig(pubKeys[1])}), NewP2SHScript(redeem.Hash()), fakeChecker{}, func(step ScriptStep) {
+			phases = append(phases, step.Phase)
+		}))
+
+		assert.Equal(t, "redeem script", phases[len(phases)-1])
+	})
+}
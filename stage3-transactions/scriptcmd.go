@@ -0,0 +1,203 @@
+// Package main implements the script debugger subcommand for Stage 3.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const scriptUsage = `❌ Usage:
+  script debug --tx <txid> --input <n> [--break <step|opcode>]...
+  (--break は命令の通し番号か OP_CHECKSIG のような命令名。何度でも指定できます)`
+
+// maxDebugItemBytes はデバッガーがスタックの要素を表示する最大のバイト数です（超えた分は省略します）
+const maxDebugItemBytes = 32
+
+// runScriptCommand は script サブコマンドを実行します
+func runScriptCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(scriptUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "debug":
+		return runScriptDebug(args[1:])
+	default:
+		fmt.Println(scriptUsage)
+		return 2
+	}
+}
+
+// runScriptDebug は chain.db のトランザクションの入力のスクリプトを1命令ずつ実行し、命令ごとのスタックと検証の結果を表示します
+// ブレークポイントに達すると一時停止し、Enter で1命令ずつ、c で次のブレークポイントまで進めます
+func runScriptDebug(args []string) int {
+	fs := flag.NewFlagSet("script debug", flag.ContinueOnError)
+	txFlag := fs.String("tx", "", "トランザクションID（16進数。先頭の一部でもよい）")
+	inputFlag := fs.Int("input", 0, "デバッグする入力の位置")
+	var breakFlag stringsFlag
+	fs.Var(&breakFlag, "break", "一時停止する命令の通し番号、または命令名（何度でも指定できる）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *txFlag == "" || *inputFlag < 0 {
+		fmt.Println(scriptUsage)
+		return 2
+	}
+	debugger, err := newScriptDebugger(os.Stdout, os.Stdin, breakFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	var tx *Transaction
+	for i := len(blocks) - 1; i >= 0 && tx == nil; i-- {
+		tx, _ = findBlockTransaction(blocks[i], *txFlag)
+	}
+	if tx == nil {
+		fmt.Printf("❌ Transaction %s not found\n", *txFlag)
+		return 1
+	}
+	if tx.IsCoinbase() {
+		fmt.Println("❌ Coinbase transactions have no scripts to debug")
+		return 1
+	}
+	if *inputFlag >= len(tx.Inputs) {
+		fmt.Printf("❌ Input %d out of range (%d input(s))\n", *inputFlag, len(tx.Inputs))
+		return 2
+	}
+	prevTxs, err := (&Blockchain{Blocks: blocks}).previousTransactions(tx)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	input := tx.Inputs[*inputFlag]
+	prevOutput, _ := previousOutput(input, prevTxs)
+	fmt.Println("\n🐞 Script Debugger")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("TxID:         %x\n", tx.ID)
+	fmt.Printf("Input:        %d (spends %s:%d)\n", *inputFlag, truncateHash(hex.EncodeToString(input.TxID)), input.OutIndex)
+	fmt.Printf("scriptSig:    %s\n", input.ScriptSig)
+	fmt.Printf("scriptPubKey: %s\n", prevOutput.ScriptPubKey)
+	fmt.Println("────────────────────────────────────────────────────────")
+
+	err = tx.DebugScript(*inputFlag, prevTxs, debugger.step)
+
+	fmt.Println("────────────────────────────────────────────────────────")
+	if err != nil {
+		fmt.Printf("❌ Verdict: input %d does not unlock its output after %d step(s): %v\n", *inputFlag, debugger.steps, err)
+		return 1
+	}
+	fmt.Printf("✅ Verdict: input %d unlocks its output (%d step(s))\n", *inputFlag, debugger.steps)
+	return 0
+}
+
+// scriptDebugger は命令ごとの実行結果を表示し、ブレークポイントで一時停止します
+type scriptDebugger struct {
+	out         io.Writer
+	in          *bufio.Reader
+	breakSteps  map[int]bool    // 一時停止する命令の通し番号（1から）
+	breakOps    map[string]bool // 一時停止する命令名
+	steps       int             // 実行した命令の数
+	stepping    bool            // ブレークポイントの後、1命令ずつ一時停止している
+	interactive bool            // 入力を読めるあいだは一時停止する
+}
+
+// newScriptDebugger はブレークポイント（命令の通し番号か命令名）を解析してデバッガーを作成します
+func newScriptDebugger(out io.Writer, in io.Reader, breakpoints []string) (*scriptDebugger, error) {
+	d := &scriptDebugger{
+		out:         out,
+		in:          bufio.NewReader(in),
+		breakSteps:  make(map[int]bool),
+		breakOps:    make(map[string]bool),
+		interactive: true,
+	}
+	for _, bp := range breakpoints {
+		if n, err := strconv.Atoi(bp); err == nil {
+			if n < 1 {
+				return nil, fmt.Errorf("breakpoint step must be 1 or greater: %d", n)
+			}
+			d.breakSteps[n] = true
+			continue
+		}
+		name := strings.ToUpper(bp)
+		if !strings.HasPrefix(name, "OP_") {
+			return nil, fmt.Errorf("invalid breakpoint %q: use a step number or an opcode name such as OP_CHECKSIG", bp)
+		}
+		d.breakOps[name] = true
+	}
+	return d, nil
+}
+
+// step は実行した命令と、実行後のスタックを表示します（scriptStepHook）
+func (d *scriptDebugger) step(step ScriptStep) {
+	d.steps++
+	op := step.Op
+	if len(op) > 2*maxDebugItemBytes {
+		op = op[:2*maxDebugItemBytes] + "…" // データを積む命令（16進数）
+	}
+	_, _ = fmt.Fprintf(d.out, "#%-3d %-13s %s\n", d.steps, fmt.Sprintf("%s[%d]", step.Phase, step.Index), op)
+	if step.Err != nil {
+		_, _ = fmt.Fprintf(d.out, "     ❌ %v\n", step.Err)
+	}
+	writeDebugStack(d.out, step.Stack)
+
+	if !d.stepping && !d.breakSteps[d.steps] && !d.breakOps[step.Op] {
+		return
+	}
+	if !d.interactive {
+		return
+	}
+
+	_, _ = fmt.Fprint(d.out, "⏸  Enter: 次の命令 / c: 次のブレークポイントまで続行 > ")
+	answer, err := d.in.ReadString('\n')
+	if err != nil {
+		// 入力がなくなれば最後まで止まらずに実行する
+		_, _ = fmt.Fprintln(d.out)
+		d.interactive = false
+		return
+	}
+	d.stepping = strings.TrimSpace(answer) != "c"
+}
+
+// writeDebugStack はスタックを上から順に表示します
+func writeDebugStack(out io.Writer, stack [][]byte) {
+	if len(stack) == 0 {
+		_, _ = fmt.Fprintln(out, "     stack: (empty)")
+		return
+	}
+	_, _ = fmt.Fprintf(out, "     stack: %d item(s), top first\n", len(stack))
+	for i := len(stack) - 1; i >= 0; i-- {
+		_, _ = fmt.Fprintf(out, "       [%d] %s\n", i, formatStackItem(stack[i]))
+	}
+}
+
+// formatStackItem はスタックの要素を16進数で表示します（長い要素は先頭だけ）
+func formatStackItem(item []byte) string {
+	if len(item) == 0 {
+		return "(empty)"
+	}
+	if len(item) > maxDebugItemBytes {
+		return fmt.Sprintf("%x… (%d bytes)", item[:maxDebugItemBytes], len(item))
+	}
+	return hex.EncodeToString(item)
+}
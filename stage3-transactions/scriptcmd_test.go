@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptDebugger(t *testing.T) {
+	pubKey := testPubKeys(t, 1)[0]
+	lock := NewP2PKHScript(common.PublicKeyHash(pubKey))
+	scriptSig := Script{}.AddData(fakeSig(pubKey)).AddData(pubKey)
+
+	t.Run("命令ごとにスタックを表示する", func(t *testing.T) {
+		var out bytes.Buffer
+		debugger, err := newScriptDebugger(&out, strings.NewReader(""), nil)
+		require.NoError(t, err)
+
+		require.NoError(t, verifyScript(scriptSig, lock, fakeChecker{}, debugger.step))
+
+		assert.Equal(t, 7, debugger.steps)
+		assert.Contains(t, out.String(), "scriptPubKey[1] OP_HASH160")
+		assert.Contains(t, out.String(), "stack: 3 item(s), top first")
+		assert.NotContains(t, out.String(), "⏸")
+	})
+
+	t.Run("ブレークポイントで止まり、Enterで1命令ずつ進む", func(t *testing.T) {
+		var out bytes.Buffer
+		// OP_HASH160 で止まり、Enter で次の命令、c で最後まで続行
+		debugger, err := newScriptDebugger(&out, strings.NewReader("\nc\n"), []string{"op_hash160"})
+		require.NoError(t, err)
+
+		require.NoError(t, verifyScript(scriptSig, lock, fakeChecker{}, debugger.step))
+
+		assert.Equal(t, 2, strings.Count(out.String(), "⏸"))
+		assert.Equal(t, 7, debugger.steps)
+	})
+
+	t.Run("命令の通し番号で止まる", func(t *testing.T) {
+		var out bytes.Buffer
+		debugger, err := newScriptDebugger(&out, strings.NewReader("c\n"), []string{"1", "7"})
+		require.NoError(t, err)
+
+		require.NoError(t, verifyScript(scriptSig, lock, fakeChecker{}, debugger.step))
+
+		assert.Equal(t, 2, strings.Count(out.String(), "⏸"))
+	})
+
+	t.Run("入力がなくなれば止まらずに最後まで実行する", func(t *testing.T) {
+		var out bytes.Buffer
+		debugger, err := newScriptDebugger(&out, strings.NewReader(""), []string{"1", "2"})
+		require.NoError(t, err)
+
+		require.NoError(t, verifyScript(scriptSig, lock, fakeChecker{}, debugger.step))
+
+		assert.Equal(t, 1, strings.Count(out.String(), "⏸"))
+		assert.Equal(t, 7, debugger.steps)
+	})
+
+	t.Run("不正なブレークポイントはエラー", func(t *testing.T) {
+		for _, bp := range []string{"0", "-1", "CHECKSIG"} {
+			_, err := newScriptDebugger(&bytes.Buffer{}, strings.NewReader(""), []string{bp})
+			assert.Error(t, err, bp)
+		}
+	})
+
+	t.Run("長い要素は先頭だけ表示する", func(t *testing.T) {
+		assert.Equal(t, "(empty)", formatStackItem(nil))
+		assert.Equal(t, "0102", formatStackItem([]byte{1, 2}))
+		assert.Contains(t, formatStackItem(make([]byte, 100)), "(100 bytes)")
+	})
+}
+
+func TestTransactionDebugScript(t *testing.T) {
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+	bc := NewBlockchain(1, wallet.GetAddress())
+	utxoSet := NewUTXOSet(bc)
+	tx, err := NewTransaction(wallet, testAddressA, 10, 0, utxoSet, bc)
+	require.NoError(t, err)
+	prevTxs, err := bc.previousTransactions(tx)
+	require.NoError(t, err)
+
+	t.Run("署名した入力はロックを解除できる", func(t *testing.T) {
+		var steps []ScriptStep
+		require.NoError(t, tx.DebugScript(0, prevTxs, func(step ScriptStep) { steps = append(steps, step) }))
+		assert.Equal(t, "OP_CHECKSIG", steps[len(steps)-1].Op)
+	})
+
+	t.Run("範囲外の入力はエラー", func(t *testing.T) {
+		assert.Error(t, tx.DebugScript(len(tx.Inputs), prevTxs, nil))
+	})
+}
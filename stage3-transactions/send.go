@@ -0,0 +1,479 @@
+// Package main implements the send-coins flow for Stage 3.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// SubmitTransaction は送金トランザクションを作成してメモリプールに追加します
+// メモリプール内の他の送金が使用していない出力を選ぶため、ブロックを待たずに続けて送金できます
+func SubmitTransaction(mempool *Mempool, signer Signer, to string, amount, fee int) (*Transaction, error) {
+	tx, _, err := SubmitTransactionWithStrategy(mempool, signer, to, amount, fee, DefaultCoinSelection)
+	return tx, err
+}
+
+// SubmitTransactionWithStrategy は指定した方式でUTXOを選んで送金トランザクションを作成し、メモリプールに追加します
+func SubmitTransactionWithStrategy(mempool *Mempool, signer Signer, to string, amount, fee int, strategy CoinSelectionStrategy) (*Transaction, *CoinSelection, error) {
+	tx, selection, err := NewTransactionWithStrategy(signer, to, amount, fee, strategy, mempool, mempool.blockchain)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := mempool.Add(tx); err != nil {
+		return nil, nil, err
+	}
+	return tx, selection, nil
+}
+
+// BumpFee はメモリプールで取り込まれずにいる送金を、手数料を fee に上げて作り直し、元の送金と置き換えます（RBF）
+// 入力と送金先は元のトランザクションと同じで、増えた手数料はおつりから払います
+func BumpFee(mempool *Mempool, wallets *Wallets, txID []byte, fee int) (*Transaction, error) {
+	entry, ok := mempool.Entry(txID)
+	if !ok {
+		return nil, fmt.Errorf("transaction %x is not in the mempool", txID)
+	}
+	if fee <= entry.Fee {
+		return nil, fmt.Errorf("new fee %d must be higher than the current fee %d", fee, entry.Fee)
+	}
+
+	wallet, from, err := localSender(mempool.blockchain, wallets, entry.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot bump fee: %w", err)
+	}
+
+	// 増えた手数料をおつり（送金元への最後の出力）から差し引く
+	outputs := append([]TxOutput(nil), entry.Tx.Outputs...)
+	change := -1
+	for i, output := range outputs {
+		if output.Address() == from {
+			change = i
+		}
+	}
+	extra := fee - entry.Fee
+	if change < 0 || outputs[change].Value-extra < mempool.DustLimit {
+		return nil, fmt.Errorf("cannot bump fee: change is too small to pay %d more", extra)
+	}
+	outputs[change].Value -= extra
+
+	inputs := make([]TxInput, len(entry.Tx.Inputs))
+	for i, input := range entry.Tx.Inputs {
+		inputs[i] = TxInput{TxID: input.TxID, OutIndex: input.OutIndex}
+	}
+	tx := &Transaction{
+		Version:   entry.Tx.Version,
+		Inputs:    inputs,
+		Outputs:   outputs,
+		Timestamp: time.Now().Unix(),
+		LockTime:  entry.Tx.LockTime,
+	}
+	tx.ID = tx.Hash()
+	if err := mempool.blockchain.SignTransaction(tx, wallet); err != nil {
+		return nil, err
+	}
+
+	if err := mempool.Add(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// localSender はトランザクションの送金元（最初の入力が使う出力の受取先）のアドレスと、そのローカルのウォレットを返します
+func localSender(bc *Blockchain, wallets *Wallets, tx *Transaction) (*Wallet, string, error) {
+	prevTx, err := bc.FindTransaction(tx.Inputs[0].TxID)
+	if err != nil {
+		return nil, "", fmt.Errorf("prev transaction not found: %w", err)
+	}
+	from := prevTx.Outputs[tx.Inputs[0].OutIndex].Address()
+	for _, address := range wallets.GetAddresses() {
+		// 旧形式（16進数）のアドレスのウォレットも同じ公開鍵ハッシュで見つける
+		if utxoKey(address) == from {
+			return wallets.Wallets[address], from, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%s is not a local wallet", from)
+}
+
+// SendCoins は送金トランザクションをメモリプールに追加し、直ちにブロックにしてUTXOセットを更新します
+// マイニングするのは送金元のウォレットなので、報酬と手数料も送金元が受け取ります
+func SendCoins(mempool *Mempool, wallet *Wallet, to string, amount, fee int) (*Block, *MiningMetrics, error) {
+	if _, err := SubmitTransaction(mempool, wallet, to, amount, fee); err != nil {
+		return nil, nil, err
+	}
+	return mempool.MineBlock(wallet.GetAddress())
+}
+
+// ResendTransaction は期限切れで取り除いた送金を、現在のUTXOセットから入力を選び直して作り直し、メモリプールに追加します
+// 送金先・送金額・手数料は元のトランザクションと同じで、送金元はローカルのウォレットである必要があります
+func ResendTransaction(mempool *Mempool, wallets *Wallets, txID []byte) (*Transaction, error) {
+	var entry *MempoolEntry
+	for _, expired := range mempool.Expired() {
+		if bytes.Equal(expired.Tx.ID, txID) {
+			entry = &expired
+			break
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("transaction %x is not an expired transaction", txID)
+	}
+
+	wallet, from, err := localSender(mempool.blockchain, wallets, entry.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resend: %w", err)
+	}
+
+	// おつり（送金元への出力）以外が送金先。自分宛ての送金なら最初の出力を使う
+	payment := entry.Tx.Outputs[0]
+	payments := 0
+	for _, output := range entry.Tx.Outputs {
+		if output.Address() != from {
+			payment = output
+			payments++
+		}
+	}
+	if payments > 1 {
+		return nil, fmt.Errorf("cannot resend a transaction with %d recipients", payments)
+	}
+
+	tx, err := SubmitTransaction(mempool, wallet, payment.Address(), payment.Value, entry.Fee)
+	if err != nil {
+		return nil, err
+	}
+	mempool.forgetExpired(txID, tx)
+	return tx, nil
+}
+
+func sendCoins(mempool *Mempool, wallets *Wallets, accounts *AccountView, scanner *bufio.Scanner) {
+	// 使用中のウォレットと keypool のおつりのアドレスのUTXOから送金する
+	utxos, err := wallets.SpendableUTXOs(mempool)
+	if err != nil {
+		fmt.Printf("❌ Send failed: %v\n", err)
+		return
+	}
+	spendable := 0
+	for _, utxo := range utxos {
+		spendable += utxo.Output.Value
+	}
+	fmt.Printf("\n💰 Balance: %d coins (including keypool change)\n", spendable)
+
+	fmt.Print("送金先アドレス（ローカルのウォレットは一覧の番号、アドレス帳のラベルも可）: ")
+	if !scanner.Scan() {
+		return
+	}
+	to, err := wallets.ResolveRecipient(scanner.Text())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return
+	}
+	if err := common.ValidateAddress(to); err != nil {
+		printAddressError(err)
+		return
+	}
+
+	fmt.Print("送金額: ")
+	if !scanner.Scan() {
+		return
+	}
+	amount, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		fmt.Println("❌ Invalid amount. Please enter a whole number.")
+		return
+	}
+
+	fmt.Printf("手数料 (空なら %d): ", DefaultTransactionFee)
+	if !scanner.Scan() {
+		return
+	}
+	fee := DefaultTransactionFee
+	if input := strings.TrimSpace(scanner.Text()); input != "" {
+		if fee, err = strconv.Atoi(input); err != nil {
+			fmt.Println("❌ Invalid fee. Please enter a whole number.")
+			return
+		}
+	}
+
+	// 方式ごとに選ばれる入力の数とおつりを比べてから選ぶ
+	selections, err := CompareCoinSelection(utxos, amount+fee)
+	if err != nil {
+		fmt.Printf("❌ Send failed: %v\n", err)
+		return
+	}
+	printCoinSelections(selections)
+	fmt.Printf("コイン選択 (番号、空なら %s): ", DefaultCoinSelection)
+	if !scanner.Scan() {
+		return
+	}
+	strategy := DefaultCoinSelection
+	if input := strings.TrimSpace(scanner.Text()); input != "" {
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 1 || n > len(selections) {
+			fmt.Printf("❌ Coin selection must be 1 to %d\n", len(selections))
+			return
+		}
+		strategy = selections[n-1].Strategy
+	}
+
+	tx, _, err := wallets.SubmitPayments(mempool, []Payment{{To: to, Amount: amount}}, fee, strategy)
+	if err != nil {
+		printSendError(err)
+		return
+	}
+	if err := wallets.SaveToFile(walletsFile); err != nil {
+		fmt.Printf("⚠️  Warning: Could not save the keypool: %v\n", err)
+	}
+
+	fmt.Println("\n📥 Transaction added to mempool!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	printSentTransaction(tx, wallets.DisplayAddress(to), amount, fee)
+	fmt.Printf("Selection:  %s\n", strategy)
+	fmt.Printf("Mempool:    %d pending transaction(s)\n", mempool.Size())
+	fmt.Println("────────────────────────────────────────────────────────")
+	if accounts != nil {
+		printAccountView(accounts, tx, mempool)
+	}
+	fmt.Println("Mine a block (5) to confirm it.")
+}
+
+// resendExpired は期限切れの送金を一覧し、選んだもの（またはすべて）を作り直して再送信します
+func resendExpired(mempool *Mempool, wallets *Wallets, scanner *bufio.Scanner) {
+	mempool.Expire(time.Now())
+	expired := mempool.Expired()
+	if len(expired) == 0 {
+		fmt.Println("✅ No expired transactions.")
+		return
+	}
+
+	fmt.Println("\n⌛ Expired transactions")
+	for i, entry := range expired {
+		fmt.Printf("%2d. %s  %d output(s), fee %d coins\n", i+1, truncateHash(fmt.Sprintf("%x", entry.Tx.ID)), len(entry.Tx.Outputs), entry.Fee)
+	}
+	fmt.Print("再送信する番号（allですべて、空で中止）: ")
+	if !scanner.Scan() {
+		return
+	}
+
+	var targets []MempoolEntry
+	switch input := strings.TrimSpace(scanner.Text()); input {
+	case "":
+		return
+	case "all":
+		targets = expired
+	default:
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 1 || n > len(expired) {
+			fmt.Printf("❌ Transaction number must be 1 to %d\n", len(expired))
+			return
+		}
+		targets = expired[n-1 : n]
+	}
+
+	for _, entry := range targets {
+		tx, err := ResendTransaction(mempool, wallets, entry.Tx.ID)
+		if err != nil {
+			fmt.Printf("❌ Resend of %s failed: %v\n", truncateHash(fmt.Sprintf("%x", entry.Tx.ID)), err)
+			continue
+		}
+		fmt.Printf("🔁 Resent %s as %s\n", truncateHash(fmt.Sprintf("%x", entry.Tx.ID)), truncateHash(fmt.Sprintf("%x", tx.ID)))
+	}
+	fmt.Printf("Mempool:    %d pending transaction(s)\n", mempool.Size())
+}
+
+// bumpFee はメモリプールの送金を一覧し、選んだものを手数料を上げて作り直して置き換えます（RBF）
+func bumpFee(mempool *Mempool, wallets *Wallets, scanner *bufio.Scanner) {
+	entries := mempool.Entries()
+	if len(entries) == 0 {
+		fmt.Println("✅ No pending transactions.")
+		return
+	}
+
+	fmt.Println("\n⏫ Pending transactions")
+	for i, entry := range entries {
+		fmt.Printf("%2d. %s  fee %d coins (%.4f/byte)\n", i+1, truncateHash(fmt.Sprintf("%x", entry.Tx.ID)), entry.Fee, entry.FeeRate())
+	}
+	fmt.Print("手数料を上げる番号（空で中止）: ")
+	if !scanner.Scan() {
+		return
+	}
+	input := strings.TrimSpace(scanner.Text())
+	if input == "" {
+		return
+	}
+	n, err := strconv.Atoi(input)
+	if err != nil || n < 1 || n > len(entries) {
+		fmt.Printf("❌ Transaction number must be 1 to %d\n", len(entries))
+		return
+	}
+	entry := entries[n-1]
+
+	fee := entry.Fee + MinReplacementFeeBump
+	fmt.Printf("新しい手数料 (空なら %d): ", fee)
+	if !scanner.Scan() {
+		return
+	}
+	if input := strings.TrimSpace(scanner.Text()); input != "" {
+		if fee, err = strconv.Atoi(input); err != nil {
+			fmt.Println("❌ Invalid fee. Please enter a whole number.")
+			return
+		}
+	}
+
+	tx, err := BumpFee(mempool, wallets, entry.Tx.ID, fee)
+	if err != nil {
+		printSendError(err)
+		return
+	}
+	fmt.Printf("🔁 Replaced %s (fee %d) with %s (fee %d)\n",
+		truncateHash(fmt.Sprintf("%x", entry.Tx.ID)), entry.Fee, truncateHash(fmt.Sprintf("%x", tx.ID)), fee)
+	fmt.Printf("Mempool:    %d pending transaction(s)\n", mempool.Size())
+}
+
+func printSentTransaction(tx *Transaction, to string, amount, fee int) {
+	fmt.Printf("To:         %s\n", to)
+	fmt.Printf("Amount:     %d coins\n", amount)
+	fmt.Printf("Fee:        %d coins (%d bytes)\n", fee, tx.Size())
+	fmt.Printf("TxID:       %s\n", truncateHash(fmt.Sprintf("%x", tx.ID)))
+	fmt.Printf("Inputs:     %d UTXO(s)\n", len(tx.Inputs))
+	if len(tx.Outputs) > 1 {
+		fmt.Printf("Change:     %d coins\n", tx.Outputs[1].Value)
+	}
+}
+
+// printCoinSelections はコイン選択の方式ごとの入力の数とおつりを表示します
+func printCoinSelections(selections []*CoinSelection) {
+	fmt.Printf("\n🪙 Coin selection (need %d coins)\n", selections[0].Target)
+	fmt.Printf("   %-20s %8s %10s\n", "Strategy", "Inputs", "Change")
+	for i, selection := range selections {
+		fmt.Printf("%d. %-20s %8d %10d\n", i+1, selection.Strategy, len(selection.UTXOs), selection.Change())
+	}
+}
+
+// printAddressError はアドレスの検証エラーを原因と詳細に分けて表示します
+func printAddressError(err error) {
+	var addrErr *common.AddressError
+	if !errors.As(err, &addrErr) {
+		fmt.Printf("❌ Invalid address: %v\n", err)
+		return
+	}
+
+	fmt.Printf("❌ Invalid address: %v\n", addrErr.Err)
+	fmt.Printf("   Address: %q\n", addrErr.Address)
+	fmt.Printf("   Detail:  %s\n", addrErr.Detail)
+}
+
+// runSendCommand は send サブコマンドを実行します
+func runSendCommand(args []string) int {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	toFlag := fs.String("to", "", "送金先アドレス（アドレス帳のラベルも可）")
+	amountFlag := fs.Int("amount", 0, "送金額")
+	feeFlag := fs.Int("fee", DefaultTransactionFee, "手数料（マイナーが受け取る）")
+	selectionFlag := fs.String("coin-selection", string(DefaultCoinSelection), "UTXOの選び方（in-order, largest-first, smallest-first, branch-and-bound）")
+	accountViewFlag := fs.Bool("account-view", false, "送金をUTXOモデルとアカウントモデルで並べて表示する")
+	deviceFlag := fs.String("device", "", "この鍵ファイルを持つ署名デバイス（device serve）を起動して署名を頼む（送金元はデバイスのアドレス）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	strategy, err := ParseCoinSelectionStrategy(*selectionFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	if *toFlag == "" || *amountFlag <= 0 {
+		fmt.Println("❌ Usage: send --to <address|label> --amount <coins> [--device device.dat]")
+		return 2
+	}
+
+	// 送金先はウォレットを作成する前に解決・検証する（ファイルがなければアドレス帳は空）
+	book, err := LoadWalletsFromFile(walletsFile)
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+	to, err := book.ResolveRecipient(*toFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+	if err := common.ValidateAddress(to); err != nil {
+		printAddressError(err)
+		return 2
+	}
+
+	// 署名は使用中のウォレットか、秘密鍵を外に出さない署名デバイスが行う
+	var signer Signer
+	var wallets *Wallets
+	if *deviceFlag != "" {
+		device, err := StartDeviceSigner(*deviceFlag)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		defer func() { _ = device.Close() }()
+		device.Description = fmt.Sprintf("Send %d coins to %s (fee %d)", *amountFlag, to, *feeFlag)
+		signer = device
+	} else {
+		if wallets, err = loadOrCreateWallets(); err != nil {
+			fmt.Printf("❌ Failed to load wallet: %v\n", err)
+			return 1
+		}
+		wallet, err := wallets.ActiveWallet()
+		if err != nil {
+			fmt.Printf("❌ Failed to load wallet: %v\n", err)
+			return 1
+		}
+		signer = wallet
+	}
+
+	// 対話モードと同じ chain.db のチェーンに追加する
+	store, bc, utxoSet, err := openChain(signer.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+	mempool := NewMempool(bc, utxoSet)
+
+	fmt.Printf("\n⛏️  Sending %d coins and mining the transaction...\n", *amountFlag)
+	var tx *Transaction
+	if wallets != nil {
+		// おつりは keypool の新しいアドレスに送る
+		tx, _, err = wallets.SubmitPayments(mempool, []Payment{{To: to, Amount: *amountFlag}}, *feeFlag, strategy)
+		if err == nil {
+			err = wallets.SaveToFile(walletsFile)
+		}
+	} else {
+		tx, _, err = SubmitTransactionWithStrategy(mempool, signer, to, *amountFlag, *feeFlag, strategy)
+	}
+	if err != nil {
+		printSendError(err)
+		return 1
+	}
+	if *accountViewFlag {
+		// 残高と nonce は送金を取り込む前のものを表示する
+		printAccountView(NewAccountView(bc), tx, mempool)
+	}
+	block, metrics, err := mempool.MineBlock(signer.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ Send failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("\n✅ Coins sent!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	printSentTransaction(block.Transactions[len(block.Transactions)-1], book.DisplayAddress(to), *amountFlag, *feeFlag)
+	fmt.Printf("Selection:  %s\n", strategy)
+	fmt.Printf("Block #%d:  %s (%d attempts)\n", block.Index, truncateHash(block.Hash), metrics.Attempts)
+	if wallets != nil {
+		fmt.Printf("Balance:    %s\n", wallets.GetTotalBalance(mempool))
+	} else {
+		fmt.Printf("Balance:    %d coins (mining reward and fee included)\n", utxoSet.GetBalance(signer.GetAddress()))
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
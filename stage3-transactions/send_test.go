@@ -0,0 +1,249 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendCoins(t *testing.T) {
+	t.Run("送金をブロックにしてUTXOセットを更新", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+		recipient, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		block, metrics, err := SendCoins(NewMempool(bc, utxoSet), sender, recipient.GetAddress(), 30, 0)
+		require.NoError(t, err)
+		require.NotNil(t, metrics)
+
+		require.Len(t, block.Transactions, 2)
+		assert.True(t, block.Transactions[0].IsCoinbase())
+		assert.Equal(t, 2, bc.GetChainLength())
+		assert.True(t, bc.IsValid())
+
+		// 送金元: おつり20 + マイニング報酬50
+		assert.Equal(t, 70, utxoSet.GetBalance(sender.GetAddress()))
+		assert.Equal(t, 30, utxoSet.GetBalance(recipient.GetAddress()))
+	})
+
+	t.Run("受け取ったコインをさらに送金できる", func(t *testing.T) {
+		alice, err := NewWallet()
+		require.NoError(t, err)
+		bob, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, alice.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), alice, bob.GetAddress(), 40, 0)
+		require.NoError(t, err)
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), bob, alice.GetAddress(), 10, 0)
+		require.NoError(t, err)
+
+		// Bob: 40 - 10 + 報酬50
+		assert.Equal(t, 80, utxoSet.GetBalance(bob.GetAddress()))
+		// Alice: おつり10 + 報酬50 + 受け取り10
+		assert.Equal(t, 70, utxoSet.GetBalance(alice.GetAddress()))
+
+		// 差分更新の結果はチェーン全体からの再構築と一致する
+		rebuilt := NewUTXOSet(bc)
+		assert.Equal(t, rebuilt.GetBalance(alice.GetAddress()), utxoSet.GetBalance(alice.GetAddress()))
+		assert.Equal(t, rebuilt.GetBalance(bob.GetAddress()), utxoSet.GetBalance(bob.GetAddress()))
+	})
+
+	t.Run("残高不足ならチェーンは変わらない", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), sender, testAddressA, 100, 0)
+		assert.Error(t, err)
+		assert.Equal(t, 1, bc.GetChainLength())
+		assert.Equal(t, 50, utxoSet.GetBalance(sender.GetAddress()))
+	})
+
+	t.Run("不正なアドレスならトランザクションを作らない", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+		mempool := NewMempool(bc, utxoSet)
+
+		_, _, err = SendCoins(mempool, sender, "1BoatSLRHtKNngkdXEeobR76b53LETtpyX", 10, 0)
+		assert.ErrorIs(t, err, common.ErrChecksumMismatch)
+		assert.Equal(t, 0, mempool.Size())
+		assert.Equal(t, 1, bc.GetChainLength())
+	})
+}
+
+func TestRunSendCommand(t *testing.T) {
+	t.Run("不正なアドレスはウォレットを読み込む前に拒否", func(t *testing.T) {
+		assert.Equal(t, 2, runSendCommand([]string{"-to", "not-an-address", "-amount", "10"}))
+	})
+}
+
+func TestSubmitTransaction(t *testing.T) {
+	t.Run("マイニングを待たずに続けて送金できる", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(sender.GetAddress(), "second reward")})
+		require.NoError(t, err)
+		utxoSet := NewUTXOSet(bc)
+		mempool := NewMempool(bc, utxoSet)
+
+		// 2つのUTXOをそれぞれ別の送金で使う
+		_, err = SubmitTransaction(mempool, sender, testAddressA, 40, 0)
+		require.NoError(t, err)
+		_, err = SubmitTransaction(mempool, sender, testAddressB, 40, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, mempool.Size())
+
+		// 使える出力が残っていなければエラー
+		_, err = SubmitTransaction(mempool, sender, testAddressA, 10, 0)
+		assert.Error(t, err)
+
+		block, _, err := mempool.MineBlock(sender.GetAddress())
+		require.NoError(t, err)
+		assert.Len(t, block.Transactions, 3)
+		assert.Equal(t, 0, mempool.Size())
+		assert.Equal(t, 40, utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, 40, utxoSet.GetBalance(testAddressB))
+		// おつり10 + 10 + 報酬50
+		assert.Equal(t, 70, utxoSet.GetBalance(sender.GetAddress()))
+	})
+}
+
+func TestResendTransaction(t *testing.T) {
+	// newExpiredFixture はローカルのウォレットから送金し、取り込まれないまま期限切れにします
+	newExpiredFixture := func(t *testing.T) (*Wallets, *Wallet, *Mempool, *Transaction) {
+		t.Helper()
+
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+		mempool.ExpiryBlocks = 1
+
+		tx, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 3)
+		require.NoError(t, err)
+		fundWallet(t, bc, utxoSet, wallet, 1)
+		require.Len(t, mempool.Expire(time.Now()), 1)
+
+		return wallets, wallet, mempool, tx
+	}
+
+	t.Run("同じ送金先・金額・手数料で作り直す", func(t *testing.T) {
+		wallets, wallet, mempool, expired := newExpiredFixture(t)
+
+		tx, err := ResendTransaction(mempool, wallets, expired.ID)
+		require.NoError(t, err)
+		assert.True(t, mempool.Contains(tx.ID))
+		assert.Empty(t, mempool.Expired())
+
+		entries := mempool.Entries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, 3, entries[0].Fee)
+		assert.Equal(t, testAddressA, tx.Outputs[0].Address())
+		assert.Equal(t, 20, tx.Outputs[0].Value)
+
+		events := mempool.Events()
+		assert.Equal(t, EventTxResent, events[len(events)-1].Type)
+
+		_, _, err = mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, 20, mempool.utxoSet.GetBalance(testAddressA))
+	})
+
+	t.Run("期限切れでないトランザクションはエラー", func(t *testing.T) {
+		wallets, wallet, mempool, _ := newExpiredFixture(t)
+
+		pending, err := SubmitTransaction(mempool, wallet, testAddressB, 5, 0)
+		require.NoError(t, err)
+		_, err = ResendTransaction(mempool, wallets, pending.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("送金元がローカルのウォレットでなければエラー", func(t *testing.T) {
+		_, _, mempool, expired := newExpiredFixture(t)
+
+		_, err := ResendTransaction(mempool, NewWallets(), expired.ID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not a local wallet")
+		assert.Len(t, mempool.Expired(), 1)
+	})
+}
+
+func TestBumpFee(t *testing.T) {
+	// newPendingFixture はローカルのウォレットから手数料1で送金し、メモリプールに残します
+	newPendingFixture := func(t *testing.T) (*Wallets, *Wallet, *Mempool, *Transaction) {
+		t.Helper()
+
+		wallet, _, _, mempool := newTestChain(t)
+		wallets := NewWallets()
+		wallets.AddWallet(wallet)
+
+		tx, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		return wallets, wallet, mempool, tx
+	}
+
+	t.Run("手数料を上げた送金で置き換え、増えた分はおつりから払う", func(t *testing.T) {
+		wallets, wallet, mempool, stuck := newPendingFixture(t)
+
+		tx, err := BumpFee(mempool, wallets, stuck.ID, 4)
+		require.NoError(t, err)
+		assert.False(t, mempool.Contains(stuck.ID))
+		assert.True(t, mempool.Contains(tx.ID))
+
+		entry, ok := mempool.Entry(tx.ID)
+		require.True(t, ok)
+		assert.Equal(t, 4, entry.Fee)
+		assert.Equal(t, stuck.Inputs[0].TxID, tx.Inputs[0].TxID)
+		assert.Equal(t, 20, tx.Outputs[0].Value)
+		assert.Equal(t, 26, tx.Outputs[1].Value)
+
+		block, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, tx.ID, block.Transactions[1].ID)
+		assert.Equal(t, 20, mempool.utxoSet.GetBalance(testAddressA))
+	})
+
+	t.Run("手数料が上がらなければエラー", func(t *testing.T) {
+		wallets, _, mempool, stuck := newPendingFixture(t)
+
+		_, err := BumpFee(mempool, wallets, stuck.ID, 1)
+		assert.Error(t, err)
+		assert.True(t, mempool.Contains(stuck.ID))
+	})
+
+	t.Run("おつりで払えなければエラー", func(t *testing.T) {
+		wallets, _, mempool, stuck := newPendingFixture(t)
+
+		_, err := BumpFee(mempool, wallets, stuck.ID, 40)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "change is too small")
+		assert.True(t, mempool.Contains(stuck.ID))
+	})
+
+	t.Run("メモリプールにない送金や他人の送金は置き換えられない", func(t *testing.T) {
+		wallets, _, mempool, stuck := newPendingFixture(t)
+
+		_, err := BumpFee(mempool, wallets, []byte("missing"), 5)
+		assert.Error(t, err)
+
+		_, err = BumpFee(mempool, NewWallets(), stuck.ID, 5)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not a local wallet")
+	})
+}
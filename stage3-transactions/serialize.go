@@ -0,0 +1,236 @@
+// Package main implements the canonical binary serialization of transactions for Stage 3.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// coinbaseOutIndex はコインベースの入力の出力番号（-1）をシリアライズした値です
+const coinbaseOutIndex = math.MaxUint32
+
+// Serialize はトランザクションを正規のバイナリ形式にします（IDは含めません）
+// gobと違ってGoのバージョンや型の登録順に依存せず、同じトランザクションは常に同じバイト列になります
+//
+//	バージョン      uint32（トランザクションの Version。以降の形式もバージョンで決まる）
+//	入力の数        varint
+//	  TxID          varint長 + バイト列
+//	  出力番号      uint32（コインベースは 0xffffffff）
+//	  scriptSig     varint長 + バイト列
+//	出力の数        varint
+//	  金額          int64
+//	  scriptPubKey  varint長 + バイト列
+//	タイムスタンプ  int64
+//	ロック時刻      int64
+//
+// 整数はすべてリトルエンディアン、varint はBitcoinと同じCompactSizeです
+func (tx *Transaction) Serialize() []byte {
+	return tx.serialize(true)
+}
+
+// serialize はトランザクションをシリアライズします
+// withScriptSigs が false なら、コインベース以外の scriptSig を空にします（トランザクションIDの計算用）
+func (tx *Transaction) serialize(withScriptSigs bool) []byte {
+	var buf bytes.Buffer
+	coinbase := tx.IsCoinbase()
+
+	writeUint32(&buf, tx.Version)
+	writeVarInt(&buf, uint64(len(tx.Inputs)))
+	for _, input := range tx.Inputs {
+		writeVarBytes(&buf, input.TxID)
+		outIndex := uint32(coinbaseOutIndex)
+		if input.OutIndex >= 0 {
+			outIndex = uint32(input.OutIndex) // #nosec G115 -- 出力番号は32ビットに収まる
+		}
+		writeUint32(&buf, outIndex)
+		if withScriptSigs || coinbase {
+			writeVarBytes(&buf, input.ScriptSig)
+		} else {
+			writeVarBytes(&buf, nil)
+		}
+	}
+	writeVarInt(&buf, uint64(len(tx.Outputs)))
+	for _, output := range tx.Outputs {
+		writeUint64(&buf, uint64(output.Value)) // #nosec G115 -- 負の金額も同じビット列で往復する
+		writeVarBytes(&buf, output.ScriptPubKey)
+	}
+	writeUint64(&buf, uint64(tx.Timestamp)) // #nosec G115 -- int64 をそのままのビット列で書く
+	writeUint64(&buf, uint64(tx.LockTime))  // #nosec G115 -- int64 をそのままのビット列で書く
+	return buf.Bytes()
+}
+
+// DeserializeTransaction は Serialize の形式からトランザクションを復元し、IDを計算し直します
+// 未知のバージョン、最短でないvarint、余分な末尾のバイトは拒否します
+func DeserializeTransaction(data []byte) (*Transaction, error) {
+	r := &txReader{data: data}
+	tx := &Transaction{Version: r.uint32()}
+	if r.err == nil {
+		if _, err := VersionRules(tx.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	inputs := r.count(1 + 4 + 1)
+	for i := 0; i < inputs && r.err == nil; i++ {
+		input := TxInput{TxID: r.varBytes()}
+		if outIndex := r.uint32(); outIndex == coinbaseOutIndex {
+			input.OutIndex = -1
+		} else {
+			input.OutIndex = int(outIndex)
+		}
+		if script := r.varBytes(); len(script) > 0 {
+			input.ScriptSig = script
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+
+	outputs := r.count(8 + 1)
+	for i := 0; i < outputs && r.err == nil; i++ {
+		value := int64(r.uint64()) // #nosec G115 -- Serialize と同じビット列を戻す
+		output := TxOutput{Value: int(value)}
+		if script := r.varBytes(); len(script) > 0 {
+			output.ScriptPubKey = script
+		}
+		tx.Outputs = append(tx.Outputs, output)
+	}
+
+	tx.Timestamp = int64(r.uint64()) // #nosec G115 -- Serialize と同じビット列を戻す
+	tx.LockTime = int64(r.uint64())  // #nosec G115 -- Serialize と同じビット列を戻す
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid transaction encoding: %w", r.err)
+	}
+	if len(r.data) > 0 {
+		return nil, fmt.Errorf("invalid transaction encoding: %d trailing byte(s)", len(r.data))
+	}
+
+	tx.ID = tx.Hash()
+	return tx, nil
+}
+
+// GobEncode はgobで保存するトランザクション（ブロックや取り消し用データの一部）を正規の形式にします
+func (tx *Transaction) GobEncode() ([]byte, error) {
+	return tx.Serialize(), nil
+}
+
+// GobDecode は正規の形式からトランザクションを復元します
+func (tx *Transaction) GobDecode(data []byte) error {
+	decoded, err := DeserializeTransaction(data)
+	if err != nil {
+		return err
+	}
+	*tx = *decoded
+	return nil
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	buf.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	buf.Write(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+// writeVarInt はBitcoinのCompactSize（0xfc以下は1バイト、それ以上は 0xfd/0xfe/0xff に続けて2/4/8バイト）で書きます
+func writeVarInt(buf *bytes.Buffer, v uint64) {
+	switch {
+	case v < 0xfd:
+		buf.WriteByte(byte(v))
+	case v <= math.MaxUint16:
+		buf.WriteByte(0xfd)
+		buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(v)))
+	case v <= math.MaxUint32:
+		buf.WriteByte(0xfe)
+		writeUint32(buf, uint32(v))
+	default:
+		buf.WriteByte(0xff)
+		writeUint64(buf, v)
+	}
+}
+
+func writeVarBytes(buf *bytes.Buffer, data []byte) {
+	writeVarInt(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+// txReader はバイト列を先頭から読みます。最初のエラーを err に残し、以降の読み込みはゼロ値を返します
+type txReader struct {
+	data []byte
+	err  error
+}
+
+func (r *txReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = fmt.Errorf("unexpected end of data (need %d byte(s), have %d)", n, len(r.data))
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *txReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *txReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// varInt はCompactSizeを読みます。同じ値をより短く書ける場合は正規の形式でないため拒否します
+func (r *txReader) varInt() uint64 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+
+	var v, min uint64
+	switch b[0] {
+	case 0xfd:
+		if b := r.next(2); b != nil {
+			v, min = uint64(binary.LittleEndian.Uint16(b)), 0xfd
+		}
+	case 0xfe:
+		v, min = uint64(r.uint32()), math.MaxUint16+1
+	case 0xff:
+		v, min = r.uint64(), math.MaxUint32+1
+	default:
+		return uint64(b[0])
+	}
+	if r.err == nil && v < min {
+		r.err = fmt.Errorf("non-canonical varint %d", v)
+	}
+	return v
+}
+
+// count は要素の数を読みます。1要素に最低 minSize バイト必要なので、残りのデータより多い数は拒否します
+func (r *txReader) count(minSize int) int {
+	n := r.varInt()
+	if r.err == nil && n > uint64(len(r.data)/minSize) {
+		r.err = fmt.Errorf("count %d exceeds the remaining data", n)
+	}
+	return int(n) // #nosec G115 -- 残りのデータの長さ以下
+}
+
+func (r *txReader) varBytes() []byte {
+	n := r.varInt()
+	if r.err == nil && n > uint64(len(r.data)) {
+		r.err = fmt.Errorf("length %d exceeds the remaining data", n)
+		return nil
+	}
+	b := r.next(int(n)) // #nosec G115 -- 残りのデータの長さ以下
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
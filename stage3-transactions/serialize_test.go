@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateFixtures は現在のバージョンのフィクスチャを再生成するためのフラグ（go test -run TestSerializationFixtures -update）
+// 過去のバージョンのフィクスチャは書き換えず、新しいバージョンでも読み込めることを確認し続けます
+var updateFixtures = flag.Bool("update", false, "フィクスチャを更新する")
+
+// serializationFixtureFile は過去のバージョンでシリアライズしたトランザクションのフィクスチャ
+const serializationFixtureFile = "testdata/serialization/transactions.json"
+
+// serializationFixture はシリアライズしたトランザクションとそのIDです
+type serializationFixture struct {
+	Name    string `json:"name"`
+	Version uint32 `json:"version"`
+	Hex     string `json:"hex"`
+	TxID    string `json:"txid"`
+}
+
+// fixtureTransactions はフィクスチャの元になる、時刻や鍵に依存しないトランザクションを指定したバージョンで作ります
+func fixtureTransactions(version uint32) map[string]*Transaction {
+	pubKeyHash := bytes.Repeat([]byte{0x11}, 20)
+	scriptHash := bytes.Repeat([]byte{0x22}, 20)
+	prevID := bytes.Repeat([]byte{0xaa}, 32)
+	signature := Script{}.AddData(bytes.Repeat([]byte{0x30}, 71)).AddData(bytes.Repeat([]byte{0x04}, 64))
+
+	txs := map[string]*Transaction{
+		"coinbase": {
+			Inputs:    []TxInput{{TxID: nil, OutIndex: -1, ScriptSig: Script("Reward to fixture")}},
+			Outputs:   []TxOutput{{Value: 50, ScriptPubKey: NewP2PKHScript(pubKeyHash)}},
+			Timestamp: 1700000000,
+		},
+		"p2pkh": {
+			Inputs: []TxInput{{TxID: prevID, OutIndex: 1, ScriptSig: signature}},
+			Outputs: []TxOutput{
+				{Value: 20, ScriptPubKey: NewP2SHScript(scriptHash)},
+				{Value: 29, ScriptPubKey: NewLegacyP2PKHScript(pubKeyHash)},
+			},
+			Timestamp: 1700000600,
+		},
+		"p2sh-locktime": {
+			Inputs: []TxInput{
+				{TxID: prevID, OutIndex: 0, ScriptSig: Script{}.AddOp(Op0).AddData(bytes.Repeat([]byte{0x52}, 300))},
+				{TxID: bytes.Repeat([]byte{0xbb}, 32), OutIndex: 70000},
+			},
+			Outputs:   []TxOutput{{Value: 1 << 40, ScriptPubKey: NewP2PKHScript(pubKeyHash)}},
+			Timestamp: 1700001200,
+			LockTime:  120,
+		},
+	}
+	for _, tx := range txs {
+		tx.Version = version
+		tx.ID = tx.Hash()
+	}
+	return txs
+}
+
+func loadSerializationFixtures(t *testing.T) []serializationFixture {
+	t.Helper()
+
+	data, err := os.ReadFile(serializationFixtureFile)
+	require.NoError(t, err)
+	var fixtures []serializationFixture
+	require.NoError(t, json.Unmarshal(data, &fixtures))
+	return fixtures
+}
+
+func TestSerializationFixtures(t *testing.T) {
+	if *updateFixtures {
+		txs := fixtureTransactions(CurrentTxVersion)
+		var fixtures []serializationFixture
+		if _, err := os.Stat(serializationFixtureFile); err == nil {
+			for _, fixture := range loadSerializationFixtures(t) {
+				if fixture.Version != CurrentTxVersion {
+					fixtures = append(fixtures, fixture)
+				}
+			}
+		}
+		for _, name := range []string{"coinbase", "p2pkh", "p2sh-locktime"} {
+			tx := txs[name]
+			fixtures = append(fixtures, serializationFixture{
+				Name:    name,
+				Version: CurrentTxVersion,
+				Hex:     hex.EncodeToString(tx.Serialize()),
+				TxID:    hex.EncodeToString(tx.ID),
+			})
+		}
+		data, err := json.MarshalIndent(fixtures, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(serializationFixtureFile), 0750))
+		require.NoError(t, os.WriteFile(serializationFixtureFile, append(data, '\n'), 0600))
+	}
+
+	for _, fixture := range loadSerializationFixtures(t) {
+		t.Run(fixture.Name, func(t *testing.T) {
+			data, err := hex.DecodeString(fixture.Hex)
+			require.NoError(t, err)
+
+			// 過去のバージョンのバイト列も読み込め、同じIDになる
+			tx, err := DeserializeTransaction(data)
+			require.NoError(t, err)
+			assert.Equal(t, fixture.Version, tx.Version)
+			assert.Equal(t, fixture.TxID, hex.EncodeToString(tx.ID))
+
+			// 同じトランザクションは今でも同じバイト列になる
+			assert.Equal(t, fixture.Hex, hex.EncodeToString(fixtureTransactions(fixture.Version)[fixture.Name].Serialize()))
+			assert.Equal(t, fixture.Hex, hex.EncodeToString(tx.Serialize()))
+		})
+	}
+}
+
+func TestDeserializeTransaction(t *testing.T) {
+	t.Run("シリアライズして復元すると同じトランザクションになる", func(t *testing.T) {
+		for name, tx := range fixtureTransactions(CurrentTxVersion) {
+			decoded, err := DeserializeTransaction(tx.Serialize())
+			require.NoError(t, err, name)
+			assert.Equal(t, tx, decoded, name)
+		}
+	})
+
+	t.Run("署名してもIDは変わらない", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		tx, _, err := NewUnsignedTransaction(wallet.GetAddress(), testAddressA, 10, 1, DefaultCoinSelection, utxoSet)
+		require.NoError(t, err)
+		unsigned := tx.Serialize()
+
+		require.NoError(t, bc.SignTransaction(tx, wallet))
+		assert.NotEqual(t, unsigned, tx.Serialize())
+		assert.Equal(t, tx.ID, tx.Hash())
+
+		decoded, err := DeserializeTransaction(tx.Serialize())
+		require.NoError(t, err)
+		assert.Equal(t, tx.ID, decoded.ID)
+		assert.True(t, bc.VerifyTransaction(decoded))
+	})
+
+	t.Run("コインベースのデータはIDに含める", func(t *testing.T) {
+		a := fixtureTransactions(CurrentTxVersion)["coinbase"]
+		b := fixtureTransactions(CurrentTxVersion)["coinbase"]
+		b.Inputs[0].ScriptSig = Script("another reward")
+		assert.NotEqual(t, a.Hash(), b.Hash())
+	})
+
+	t.Run("不正なバイト列は拒否する", func(t *testing.T) {
+		data := fixtureTransactions(TxVersion1)["p2pkh"].Serialize()
+
+		tests := []struct {
+			name string
+			data []byte
+		}{
+			{name: "空", data: nil},
+			{name: "途中で切れている", data: data[:len(data)-1]},
+			{name: "末尾に余分なバイト", data: append(append([]byte(nil), data...), 0)},
+			{name: "未知のバージョン", data: append([]byte{99, 0, 0, 0}, data[4:]...)},
+			{name: "最短でないvarint", data: append(append([]byte{1, 0, 0, 0}, 0xfd, 1, 0), data[5:]...)},
+			{name: "データより多い入力の数", data: append([]byte{1, 0, 0, 0}, 0xfe, 0xff, 0xff, 0xff, 0x7f)},
+		}
+		for _, tt := range tests {
+			_, err := DeserializeTransaction(tt.data)
+			assert.Error(t, err, tt.name)
+		}
+	})
+
+	t.Run("varintは値に応じて1・3・5・9バイトになる", func(t *testing.T) {
+		for _, tt := range []struct {
+			value uint64
+			size  int
+		}{{0xfc, 1}, {0xfd, 3}, {0xffff, 3}, {0x10000, 5}, {0xffffffff, 5}, {0x100000000, 9}} {
+			var buf bytes.Buffer
+			writeVarInt(&buf, tt.value)
+			assert.Len(t, buf.Bytes(), tt.size, "%#x", tt.value)
+
+			r := &txReader{data: buf.Bytes()}
+			assert.Equal(t, tt.value, r.varInt())
+			assert.NoError(t, r.err)
+		}
+	})
+}
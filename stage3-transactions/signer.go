@@ -0,0 +1,296 @@
+// Package main implements the external signer interface and a mock hardware wallet for Stage 3.
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Signer は送金に署名する鍵の持ち主です
+// Wallet のようにプロセス内に秘密鍵を持つものだけでなく、ハードウェアウォレットのように秘密鍵を外に出さないものも実装できます
+type Signer interface {
+	GetAddress() string               // おつりを戻し、使うUTXOを探すアドレス
+	Public() *ecdsa.PublicKey         // scriptSig に入れる公開鍵
+	Sign(data []byte) ([]byte, error) // 署名対象のハッシュへの署名
+}
+
+// Public はウォレットの公開鍵を返します（Signer インターフェースを実装します）
+func (w *Wallet) Public() *ecdsa.PublicKey {
+	return w.PublicKey
+}
+
+// deviceKeyFile は署名デバイスの秘密鍵の既定の保存先です
+const deviceKeyFile = "device.dat"
+
+// deviceRequest は署名デバイスへの要求です（1行に1つのJSON）
+type deviceRequest struct {
+	Method      string `json:"method"`                // "public_key" または "sign"
+	Data        string `json:"data,omitempty"`        // 署名するハッシュ（16進数）
+	Description string `json:"description,omitempty"` // 確認のためにデバイスに表示する説明
+}
+
+// deviceResponse は署名デバイスからの応答です（失敗したときは Error だけが入ります）
+type deviceResponse struct {
+	Address   string `json:"address,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DeviceConfirmFunc は署名の前にデバイスの持ち主に確認し、承認されたかを返します
+type DeviceConfirmFunc func(description string, hash []byte) bool
+
+// ServeDevice は in から要求を読み、wallet の鍵で署名した応答を out に書く署名デバイスとして動きます
+// 署名の要求は confirm で承認されたときだけ応じます。in が閉じられると nil を返します
+func ServeDevice(wallet *Wallet, in io.Reader, out io.Writer, confirm DeviceConfirmFunc) error {
+	decoder := json.NewDecoder(in)
+	encoder := json.NewEncoder(out)
+	for {
+		var request deviceRequest
+		if err := decoder.Decode(&request); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("invalid device request: %w", err)
+		}
+
+		var response deviceResponse
+		switch request.Method {
+		case "public_key":
+			response.Address = wallet.GetAddress()
+			response.PublicKey = hex.EncodeToString(publicKeyToBytes(wallet.PublicKey))
+		case "sign":
+			hash, err := hex.DecodeString(request.Data)
+			switch {
+			case err != nil || len(hash) == 0:
+				response.Error = "data must be a hex-encoded hash"
+			case !confirm(request.Description, hash):
+				response.Error = "signing rejected on the device"
+			default:
+				signature, err := wallet.Sign(hash)
+				if err != nil {
+					response.Error = err.Error()
+				} else {
+					response.Signature = hex.EncodeToString(signature)
+				}
+			}
+		default:
+			response.Error = fmt.Sprintf("unknown method %q", request.Method)
+		}
+		if err := encoder.Encode(response); err != nil {
+			return fmt.Errorf("failed to write device response: %w", err)
+		}
+	}
+}
+
+// DeviceSigner は別のプロセス（署名デバイス）にパイプ越しに署名を頼む Signer です
+// 秘密鍵はデバイスの中だけにあり、このプロセスには公開鍵と署名しか渡りません
+type DeviceSigner struct {
+	Description string // 署名を頼むときにデバイスに表示する説明
+
+	address   string
+	publicKey *ecdsa.PublicKey
+	encoder   *json.Encoder
+	decoder   *json.Decoder
+	mutex     sync.Mutex
+
+	cmd   *exec.Cmd // StartDeviceSigner で起動したデバイスのプロセス
+	stdin io.Closer
+}
+
+// NewDeviceSigner は r と w でつながった署名デバイスから公開鍵を受け取り、Signer を作ります
+func NewDeviceSigner(r io.Reader, w io.Writer) (*DeviceSigner, error) {
+	s := &DeviceSigner{encoder: json.NewEncoder(w), decoder: json.NewDecoder(r)}
+	response, err := s.call(deviceRequest{Method: "public_key"})
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := hex.DecodeString(response.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("device returned an invalid public key: %w", err)
+	}
+	if s.publicKey, err = bytesToPublicKey(pubKey); err != nil {
+		return nil, fmt.Errorf("device returned an invalid public key: %w", err)
+	}
+	s.address = response.Address
+	return s, nil
+}
+
+// StartDeviceSigner は keyFile の鍵を持つ署名デバイスを子プロセスとして起動し、標準入出力のパイプでつなぎます
+// デバイスは署名のたびに端末で確認を求めます。使い終わったら Close で終了させます
+func StartDeviceSigner(keyFile string) (*DeviceSigner, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the device program: %w", err)
+	}
+	// #nosec G204 -- 自分自身の実行ファイルを署名デバイスとして起動する
+	cmd := exec.Command(executable, "device", "serve", "--file", keyFile)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the device: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the device: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the device: %w", err)
+	}
+
+	signer, err := NewDeviceSigner(stdout, stdin)
+	if err != nil {
+		_ = stdin.Close()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	signer.cmd, signer.stdin = cmd, stdin
+	return signer, nil
+}
+
+// call は要求を1つ送り、応答を待ちます
+func (s *DeviceSigner) call(request deviceRequest) (deviceResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var response deviceResponse
+	if err := s.encoder.Encode(request); err != nil {
+		return response, fmt.Errorf("failed to send to the device: %w", err)
+	}
+	if err := s.decoder.Decode(&response); err != nil {
+		return response, fmt.Errorf("no response from the device: %w", err)
+	}
+	if response.Error != "" {
+		return response, fmt.Errorf("device: %s", response.Error)
+	}
+	return response, nil
+}
+
+// GetAddress はデバイスの鍵のアドレスを返します
+func (s *DeviceSigner) GetAddress() string {
+	return s.address
+}
+
+// Public はデバイスの鍵の公開鍵を返します
+func (s *DeviceSigner) Public() *ecdsa.PublicKey {
+	return s.publicKey
+}
+
+// Sign はデバイスにハッシュへの署名を頼みます。デバイスで承認されなければエラーを返します
+// 返ってきた署名はデバイスの公開鍵で検証してから使います
+func (s *DeviceSigner) Sign(data []byte) ([]byte, error) {
+	response, err := s.call(deviceRequest{Method: "sign", Data: hex.EncodeToString(data), Description: s.Description})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := hex.DecodeString(response.Signature)
+	if err != nil || !VerifySignature(s.publicKey, data, signature) {
+		return nil, fmt.Errorf("device returned an invalid signature")
+	}
+	return signature, nil
+}
+
+// Close はデバイスへの入力を閉じ、StartDeviceSigner で起動したプロセスの終了を待ちます
+func (s *DeviceSigner) Close() error {
+	if s.cmd == nil {
+		return nil
+	}
+	_ = s.stdin.Close()
+	return s.cmd.Wait()
+}
+
+// terminalConfirm は署名デバイスの端末（/dev/tty）に署名の内容を表示し、y で承認されたかを返します
+// 標準入出力はパイプとして使っているため、確認は端末で直接行います
+func terminalConfirm(description string, hash []byte) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "🔒 [device] No terminal to confirm on, rejecting the signature")
+		return false
+	}
+	defer func() { _ = tty.Close() }()
+
+	fmt.Fprintln(tty, "\n🔐 [device] Signature requested")
+	if description != "" {
+		fmt.Fprintf(tty, "   %s\n", description)
+	}
+	fmt.Fprintf(tty, "   Hash: %x\n", hash)
+	fmt.Fprint(tty, "   署名しますか？ [y/N]: ")
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		fmt.Fprintln(tty, "   ✋ Rejected")
+		return false
+	}
+}
+
+// loadOrCreateDeviceWallet は署名デバイスの鍵を読み込み、なければ作成して保存します
+func loadOrCreateDeviceWallet(filename string) (*Wallet, error) {
+	if _, err := os.Stat(filename); err == nil {
+		return LoadWalletFromFile(filename)
+	}
+	wallet, err := NewWallet()
+	if err != nil {
+		return nil, err
+	}
+	if err := wallet.SaveToFile(filename); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "🆕 [device] Created a new key in %s\n", filename)
+	return wallet, nil
+}
+
+const deviceUsage = `❌ Usage:
+  device address [--file device.dat]
+  device serve [--file device.dat]   (started by send --device; speaks JSON over stdin/stdout)`
+
+// runDeviceCommand は device サブコマンド（ハードウェアウォレットを模した署名デバイス）を実行します
+func runDeviceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(deviceUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("device "+args[0], flag.ContinueOnError)
+	fileFlag := fs.String("file", deviceKeyFile, "署名デバイスの秘密鍵のファイル")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "address":
+		wallet, err := loadOrCreateDeviceWallet(*fileFlag)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		fmt.Println(wallet.GetAddress())
+		return 0
+	case "serve":
+		// 標準出力は応答に使うため、メッセージは標準エラー出力に書く
+		wallet, err := loadOrCreateDeviceWallet(*fileFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ [device] %v\n", err)
+			return 1
+		}
+		if err := ServeDevice(wallet, os.Stdin, os.Stdout, terminalConfirm); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ [device] %v\n", err)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Println(deviceUsage)
+		return 2
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDevice はパイプでつないだ署名デバイスを goroutine で動かし、そのデバイスに署名を頼む DeviceSigner を返します
+// approve が false ならデバイスは署名を拒否します。確認を求められた説明は prompts に記録します
+func newTestDevice(t *testing.T, wallet *Wallet, approve bool) (*DeviceSigner, *[]string) {
+	t.Helper()
+
+	hostReader, deviceWriter := io.Pipe()
+	deviceReader, hostWriter := io.Pipe()
+	prompts := &[]string{}
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeDevice(wallet, deviceReader, deviceWriter, func(description string, hash []byte) bool {
+			*prompts = append(*prompts, description)
+			return approve
+		})
+	}()
+	t.Cleanup(func() {
+		_ = hostWriter.Close()
+		assert.NoError(t, <-done)
+	})
+
+	signer, err := NewDeviceSigner(hostReader, hostWriter)
+	require.NoError(t, err)
+	return signer, prompts
+}
+
+func TestDeviceSigner(t *testing.T) {
+	t.Run("秘密鍵を持たずにデバイスの鍵で送金に署名する", func(t *testing.T) {
+		device, _, utxoSet, mempool := newTestChain(t)
+		signer, prompts := newTestDevice(t, device, true)
+		signer.Description = "Send 20 coins"
+
+		assert.Equal(t, device.GetAddress(), signer.GetAddress())
+		assert.True(t, device.PublicKey.Equal(signer.Public()))
+
+		tx, err := SubmitTransaction(mempool, signer, testAddressA, 20, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Send 20 coins"}, *prompts)
+
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+		assert.Equal(t, 20, utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, 29, utxoSet.GetBalance(device.GetAddress()))
+		assert.NotEmpty(t, tx.Inputs[0].ScriptSig)
+	})
+
+	t.Run("デバイスで拒否されたら署名しない", func(t *testing.T) {
+		device, _, _, mempool := newTestChain(t)
+		signer, prompts := newTestDevice(t, device, false)
+
+		_, err := SubmitTransaction(mempool, signer, testAddressA, 20, 1)
+		assert.ErrorContains(t, err, "signing rejected on the device")
+		assert.Len(t, *prompts, 1)
+		assert.Equal(t, 0, mempool.Size())
+	})
+
+	t.Run("PSBTにもデバイスで署名する", func(t *testing.T) {
+		device, _, utxoSet, _ := newTestChain(t)
+		signer, _ := newTestDevice(t, device, true)
+
+		tx, _, err := NewUnsignedTransaction(device.GetAddress(), testAddressA, 10, 1, DefaultCoinSelection, utxoSet)
+		require.NoError(t, err)
+		psbt, err := NewPSBT(tx, utxoSet)
+		require.NoError(t, err)
+
+		added, err := psbt.Sign(signer)
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+	})
+}
+
+func TestServeDevice(t *testing.T) {
+	t.Run("不正な要求にはエラーを返して動き続ける", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		signer, _ := newTestDevice(t, wallet, true)
+
+		_, err = signer.call(deviceRequest{Method: "export_key"})
+		assert.ErrorContains(t, err, `unknown method "export_key"`)
+		_, err = signer.call(deviceRequest{Method: "sign", Data: "zz"})
+		assert.ErrorContains(t, err, "hex-encoded hash")
+
+		signature, err := signer.Sign([]byte("0123456789abcdef0123456789abcdef"))
+		require.NoError(t, err)
+		assert.True(t, VerifySignature(wallet.PublicKey, []byte("0123456789abcdef0123456789abcdef"), signature))
+	})
+}
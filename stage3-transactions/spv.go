@@ -0,0 +1,227 @@
+// Package main implements SPV (Simplified Payment Verification) for Stage 3.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// TxProof はトランザクションがブロックに含まれることの証明です
+type TxProof struct {
+	TxID      []byte
+	Height    int64               // トランザクションを含むブロックの高さ
+	BlockHash string              // トランザクションを含むブロックのハッシュ
+	Steps     []common.MerkleStep // マークルルートまでの兄弟のハッシュ
+}
+
+// ProveTransaction はトランザクションを含むブロックを探し、マークル証明を作ります（フルノード側の処理）
+func (bc *Blockchain) ProveTransaction(txID []byte) (*TxProof, error) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	for _, block := range bc.Blocks {
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, txID) {
+				return newTxProof(block, tx.ID)
+			}
+		}
+	}
+	return nil, fmt.Errorf("transaction %x not found", txID)
+}
+
+// ProofsForAddresses はアドレスに関係するトランザクション（アドレスへの支払いと、その出力を使った支払い）の証明を
+// チェーンの順に返します（フルノード側の処理）
+func (bc *Blockchain) ProofsForAddresses(addresses []string) ([]*TxProof, error) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	watched := make(map[string]bool)
+	for _, address := range addresses {
+		watched[utxoKey(address)] = true
+	}
+
+	owned := make(map[string]bool) // アドレスが受け取った出力（outpointKey）
+	var proofs []*TxProof
+	for _, block := range bc.Blocks {
+		for _, tx := range block.Transactions {
+			relevant := false
+			if !tx.IsCoinbase() {
+				for _, input := range tx.Inputs {
+					if owned[outpointKey(input.TxID, input.OutIndex)] {
+						relevant = true
+					}
+				}
+			}
+			for index, output := range tx.Outputs {
+				if watched[output.Address()] {
+					owned[outpointKey(tx.ID, index)] = true
+					relevant = true
+				}
+			}
+			if !relevant {
+				continue
+			}
+
+			proof, err := newTxProof(block, tx.ID)
+			if err != nil {
+				return nil, err
+			}
+			proofs = append(proofs, proof)
+		}
+	}
+	return proofs, nil
+}
+
+// Headers は高さ from 以降のブロックヘッダーを返します
+func (bc *Blockchain) Headers(from int64) []BlockHeader {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	var headers []BlockHeader
+	for _, block := range bc.Blocks {
+		if block.Index >= from {
+			headers = append(headers, block.Header())
+		}
+	}
+	return headers
+}
+
+// newTxProof はブロック内のトランザクションの証明を作ります
+func newTxProof(block *Block, txID []byte) (*TxProof, error) {
+	steps, err := block.GenerateMerkleProof(txID)
+	if err != nil {
+		return nil, err
+	}
+	return &TxProof{TxID: txID, Height: block.Index, BlockHash: block.Hash, Steps: steps}, nil
+}
+
+// SPVClient はブロック本体を持たず、ヘッダーと関係するトランザクションのマークル証明だけで
+// トランザクションの承認を確認する軽量クライアントです
+// ヘッダーのPoWとつながりを検証するので、証明が正しくても偽のブロックに含まれたトランザクションは受け付けません
+type SPVClient struct {
+	headers []BlockHeader       // 高さ順のヘッダー
+	proofs  map[string]*TxProof // TxID(hex) -> 検証済みの証明
+	mutex   sync.RWMutex
+}
+
+// NewSPVClient はヘッダーを持たないSPVクライアントを作成します
+func NewSPVClient() *SPVClient {
+	return &SPVClient{proofs: make(map[string]*TxProof)}
+}
+
+// Height は保持している最新のヘッダーの高さを返します（ヘッダーがなければ -1）
+func (c *SPVClient) Height() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return int64(len(c.headers)) - 1
+}
+
+// AddHeader はヘッダーのハッシュとPoW、前のヘッダーとのつながりを検証して追加します
+func (c *SPVClient) AddHeader(header BlockHeader) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !header.Validate() {
+		return fmt.Errorf("header %d: invalid hash or proof of work", header.Index)
+	}
+
+	if len(c.headers) == 0 {
+		if header.Index != 0 || header.PreviousHash != "" {
+			return fmt.Errorf("header %d: first header must be the genesis block", header.Index)
+		}
+	} else {
+		tip := c.headers[len(c.headers)-1]
+		if header.Index != tip.Index+1 {
+			return fmt.Errorf("header %d: does not follow header %d", header.Index, tip.Index)
+		}
+		if header.PreviousHash != tip.Hash {
+			return fmt.Errorf("header %d: previous hash does not match header %d", header.Index, tip.Index)
+		}
+	}
+
+	c.headers = append(c.headers, header)
+	return nil
+}
+
+// SyncHeaders はフルノードのチェーンから、まだ持っていないヘッダーを取り込みます
+func (c *SPVClient) SyncHeaders(bc *Blockchain) (int, error) {
+	added := 0
+	for _, header := range bc.Headers(c.Height() + 1) {
+		if err := c.AddHeader(header); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+// AddProof は証明を保持しているヘッダーのマークルルートに対して検証し、通れば記録します
+func (c *SPVClient) AddProof(proof *TxProof) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if proof.Height < 0 || proof.Height >= int64(len(c.headers)) {
+		return fmt.Errorf("no header at height %d", proof.Height)
+	}
+	header := c.headers[proof.Height]
+	if proof.BlockHash != header.Hash {
+		return fmt.Errorf("proof is for block %s, header %d is %s", truncateHash(proof.BlockHash), header.Index, truncateHash(header.Hash))
+	}
+	if !common.VerifyMerkleProof(header.MerkleRoot, proof.TxID, proof.Steps) {
+		return fmt.Errorf("merkle proof for %x does not match header %d", proof.TxID, header.Index)
+	}
+
+	c.proofs[hex.EncodeToString(proof.TxID)] = proof
+	return nil
+}
+
+// Confirmations は検証済みのトランザクションを含むブロックの高さと承認数（そのブロックを含む以降のヘッダー数）を返します
+func (c *SPVClient) Confirmations(txID []byte) (int64, int64, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	proof, ok := c.proofs[hex.EncodeToString(txID)]
+	if !ok {
+		return 0, 0, fmt.Errorf("no verified proof for transaction %x", txID)
+	}
+	return proof.Height, int64(len(c.headers)) - proof.Height, nil
+}
+
+// VerifyConfirmed はトランザクションが高さ height のブロックに含まれ、minConfirmations 以上承認されているか確認します
+func (c *SPVClient) VerifyConfirmed(txID []byte, height, minConfirmations int64) error {
+	actualHeight, confirmations, err := c.Confirmations(txID)
+	if err != nil {
+		return err
+	}
+	if actualHeight != height {
+		return fmt.Errorf("transaction %x is at height %d, not %d", txID, actualHeight, height)
+	}
+	if confirmations < minConfirmations {
+		return fmt.Errorf("transaction %x has %d confirmation(s), need %d", txID, confirmations, minConfirmations)
+	}
+	return nil
+}
+
+// Proofs は検証済みの証明を高さ順に返します
+func (c *SPVClient) Proofs() []*TxProof {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	proofs := make([]*TxProof, 0, len(c.proofs))
+	for _, proof := range c.proofs {
+		proofs = append(proofs, proof)
+	}
+	sort.Slice(proofs, func(i, j int) bool {
+		if proofs[i].Height != proofs[j].Height {
+			return proofs[i].Height < proofs[j].Height
+		}
+		return bytes.Compare(proofs[i].TxID, proofs[j].TxID) < 0
+	})
+	return proofs
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSPVClient(t *testing.T) {
+	// 送金を2回含むチェーン（サブテストは読むだけ）
+	wallet, bc, _, mempool := newTestChain(t)
+	var blocks []*Block
+	for _, amount := range []int{5, 7} {
+		block, _, err := SendCoins(mempool, wallet, testAddressA, amount, 1)
+		require.NoError(t, err)
+		blocks = append(blocks, block)
+	}
+
+	t.Run("ヘッダーと証明だけで承認数を答える", func(t *testing.T) {
+		client := NewSPVClient()
+		added, err := client.SyncHeaders(bc)
+		require.NoError(t, err)
+		require.Equal(t, 3, added)
+		payment := blocks[0].Transactions[1]
+
+		proof, err := bc.ProveTransaction(payment.ID)
+		require.NoError(t, err)
+		require.NoError(t, client.AddProof(proof))
+
+		height, confirmations, err := client.Confirmations(payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), height)
+		assert.Equal(t, int64(2), confirmations)
+
+		assert.NoError(t, client.VerifyConfirmed(payment.ID, 1, 2))
+		assert.Error(t, client.VerifyConfirmed(payment.ID, 1, 3))
+		assert.Error(t, client.VerifyConfirmed(payment.ID, 2, 1))
+	})
+
+	t.Run("ヘッダーが増えると承認数も増える", func(t *testing.T) {
+		client := NewSPVClient()
+		for _, header := range bc.Headers(0)[:2] {
+			require.NoError(t, client.AddHeader(header))
+		}
+		payment := blocks[0].Transactions[1]
+		proof, err := bc.ProveTransaction(payment.ID)
+		require.NoError(t, err)
+		require.NoError(t, client.AddProof(proof))
+
+		_, confirmations, err := client.Confirmations(payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), confirmations)
+
+		added, err := client.SyncHeaders(bc)
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+
+		_, confirmations, err = client.Confirmations(payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), confirmations)
+	})
+
+	t.Run("証明のないトランザクションは答えない", func(t *testing.T) {
+		client := NewSPVClient()
+		_, err := client.SyncHeaders(bc)
+		require.NoError(t, err)
+		_, _, err = client.Confirmations(blocks[0].Transactions[1].ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("改ざんした証明や別のブロックの証明は拒否する", func(t *testing.T) {
+		client := NewSPVClient()
+		_, err := client.SyncHeaders(bc)
+		require.NoError(t, err)
+		payment := blocks[0].Transactions[1]
+
+		proof, err := bc.ProveTransaction(payment.ID)
+		require.NoError(t, err)
+		forged := *proof
+		forged.TxID = blocks[1].Transactions[1].ID
+		assert.Error(t, client.AddProof(&forged))
+
+		moved := *proof
+		moved.Height = 2
+		assert.Error(t, client.AddProof(&moved))
+
+		moved.Height = 10
+		assert.Error(t, client.AddProof(&moved))
+	})
+}
+
+func TestSPVHeaders(t *testing.T) {
+	wallet, bc, _, mempool := newTestChain(t)
+	for _, amount := range []int{5, 7} {
+		_, _, err := SendCoins(mempool, wallet, testAddressA, amount, 1)
+		require.NoError(t, err)
+	}
+	headers := bc.Headers(0)
+	require.Len(t, headers, 3)
+
+	t.Run("ヘッダーのハッシュはブロックと同じ", func(t *testing.T) {
+		for i, header := range headers {
+			assert.Equal(t, bc.Blocks[i].Hash, header.CalculateHash())
+			assert.True(t, header.Validate())
+		}
+	})
+
+	t.Run("ジェネシスから順につながるヘッダーだけ受け付ける", func(t *testing.T) {
+		client := NewSPVClient()
+		assert.Error(t, client.AddHeader(headers[1]), "ジェネシスがない")
+		require.NoError(t, client.AddHeader(headers[0]))
+		assert.Error(t, client.AddHeader(headers[2]), "間のヘッダーがない")
+		assert.Equal(t, int64(0), client.Height())
+	})
+
+	t.Run("マークルルートを書き換えたヘッダーはPoWが合わない", func(t *testing.T) {
+		client := NewSPVClient()
+		require.NoError(t, client.AddHeader(headers[0]))
+
+		tampered := headers[1]
+		tampered.MerkleRoot = headers[2].MerkleRoot
+		assert.Error(t, client.AddHeader(tampered))
+	})
+}
+
+func TestProofsForAddresses(t *testing.T) {
+	// 送金を2回含むチェーン（サブテストは読むだけ）
+	wallet, bc, _, mempool := newTestChain(t)
+	var blocks []*Block
+	for _, amount := range []int{5, 7} {
+		block, _, err := SendCoins(mempool, wallet, testAddressA, amount, 1)
+		require.NoError(t, err)
+		blocks = append(blocks, block)
+	}
+
+	t.Run("受け取りとその出力を使った送金を返す", func(t *testing.T) {
+		// 送金元: ジェネシスのコインベース、2回の送金、2つのブロックの報酬
+		proofs, err := bc.ProofsForAddresses([]string{wallet.GetAddress()})
+		require.NoError(t, err)
+		assert.Len(t, proofs, 5)
+
+		// 送金先: 2回の受け取り
+		proofs, err = bc.ProofsForAddresses([]string{testAddressA})
+		require.NoError(t, err)
+		require.Len(t, proofs, 2)
+		assert.Equal(t, blocks[0].Transactions[1].ID, proofs[0].TxID)
+		assert.Equal(t, int64(2), proofs[1].Height)
+	})
+}
@@ -0,0 +1,378 @@
+// Package main implements on-disk storage of blocks and the UTXO set for Stage 3.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltDBのバケットとキー
+var (
+	blocksBucket  = []byte("blocks")      // 高さ（8バイト） -> ブロック
+	utxoBucket    = []byte("utxo")        // アウトポイント（TxID + 出力番号4バイト） -> 出力
+	undoBucket    = []byte("undo")        // ブロックハッシュ -> 取り消し用データ
+	txIndexBucket = []byte("txindex")     // TxID -> 高さ（8バイト） + ブロック内の位置（4バイト）
+	metaBucket    = []byte("meta")        // 付随する情報
+	utxoTipKey    = []byte("utxo-tip")    // UTXOセットが反映している最新ブロックのハッシュ
+	txIndexTipKey = []byte("txindex-tip") // トランザクションインデックスが反映している最新ブロックのハッシュ
+	formatKey     = []byte("format")      // 保存形式のバージョン
+	halvingKey    = []byte("halving")     // 報酬が半減するブロック間隔（ビッグエンディアン8バイト）
+)
+
+// chainStoreFormat は保存形式のバージョンです
+// 2: トランザクションを gob ではなく Serialize の形式で保存し、IDは署名を除いて計算する
+const chainStoreFormat = 2
+
+// ChainStore はブロックとUTXOセットをBoltDBのファイルに保存します
+// 起動のたびにジェネシスからUTXOセットを再構築せずに済むよう、ブロックの追加ごとに差分だけを書き込みます
+type ChainStore struct {
+	db *bolt.DB
+}
+
+// OpenChainStore はファイルを開き（なければ作成し）、必要なバケットを用意します
+func OpenChainStore(path string) (*ChainStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, utxoBucket, undoBucket, txIndexBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return checkStoreFormat(tx)
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to prepare %s: %w", path, err)
+	}
+
+	return &ChainStore{db: db}, nil
+}
+
+// checkStoreFormat は保存形式のバージョンを確認します。新しいファイルには現在のバージョンを記録します
+// 古い形式のブロックはトランザクションIDの計算方法が異なり読み込めないため、作り直すよう案内します
+func checkStoreFormat(tx *bolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	format := meta.Get(formatKey)
+	if format == nil {
+		if key, _ := tx.Bucket(blocksBucket).Cursor().First(); key != nil {
+			return fmt.Errorf("blocks were saved in an older format; remove the file to start a new chain")
+		}
+		return meta.Put(formatKey, []byte{chainStoreFormat})
+	}
+	if len(format) != 1 || format[0] != chainStoreFormat {
+		return fmt.Errorf("unsupported storage format %x (expected %d)", format, chainStoreFormat)
+	}
+	return nil
+}
+
+// Close はファイルを閉じます
+func (s *ChainStore) Close() error {
+	return s.db.Close()
+}
+
+// heightKey はブロックの高さをキー（ビッグエンディアン8バイト。高さ順に並ぶ）にします
+func heightKey(height int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(height)) // #nosec G115 -- ブロックの高さは負にならない
+	return key
+}
+
+// outpointBytes はUTXOバケットのキー（TxID + ビッグエンディアン4バイトの出力番号）を返します
+func outpointBytes(txID []byte, outIndex int) []byte {
+	key := make([]byte, len(txID)+4)
+	copy(key, txID)
+	binary.BigEndian.PutUint32(key[len(txID):], uint32(outIndex)) // #nosec G115 -- 出力番号は負にならない
+	return key
+}
+
+// encodeGob は値をgobでエンコードします
+func encodeGob(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// SaveBlock はブロックを保存し、そのトランザクションをトランザクションインデックスに追加します
+func (s *ChainStore) SaveBlock(block *Block) error {
+	data, err := encodeGob(block)
+	if err != nil {
+		return fmt.Errorf("failed to encode block %d: %w", block.Index, err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(blocksBucket).Put(heightKey(block.Index), data); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(txIndexBucket)
+		for offset, transaction := range block.Transactions {
+			location := TxLocation{Height: block.Index, Offset: offset}
+			if err := bucket.Put(transaction.ID, location.bytes()); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(txIndexTipKey, []byte(block.Hash))
+	})
+}
+
+// LoadBlocks は保存されたブロックを高さ順に返します
+func (s *ChainStore) LoadBlocks() ([]*Block, error) {
+	var blocks []*Block
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(blocksBucket).ForEach(func(_, data []byte) error {
+			var block Block
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&block); err != nil {
+				return fmt.Errorf("failed to decode block: %w", err)
+			}
+			blocks = append(blocks, &block)
+			return nil
+		})
+	})
+	return blocks, err
+}
+
+// LatestBlock は保存された最新のブロックを返します（ブロックがなければ nil）
+// すべてのブロックを読み込まずに済むため、起動時の確認に使います
+func (s *ChainStore) LatestBlock() (*Block, error) {
+	var block *Block
+	err := s.db.View(func(tx *bolt.Tx) error {
+		_, data := tx.Bucket(blocksBucket).Cursor().Last()
+		if data == nil {
+			return nil
+		}
+		block = &Block{}
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(block); err != nil {
+			return fmt.Errorf("failed to decode block: %w", err)
+		}
+		return nil
+	})
+	return block, err
+}
+
+// UTXOTip は保存されたUTXOセットが反映している最新ブロックのハッシュを返します（未保存なら空文字列）
+func (s *ChainStore) UTXOTip() (string, error) {
+	return s.metaString(utxoTipKey)
+}
+
+// TxIndexTip は保存されたトランザクションインデックスが反映している最新ブロックのハッシュを返します（未保存なら空文字列）
+func (s *ChainStore) TxIndexTip() (string, error) {
+	return s.metaString(txIndexTipKey)
+}
+
+// metaString は付随する情報を文字列として返します（未保存なら空文字列）
+func (s *ChainStore) metaString(key []byte) (string, error) {
+	var value string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket(metaBucket).Get(key))
+		return nil
+	})
+	return value, err
+}
+
+// HalvingInterval はチェーンとともに保存された、報酬が半減するブロック間隔を返します（未保存なら0）
+// 半減の間隔はコンセンサスのルールのため、チェーンを作ったときの値をファイルに残して開くたびに使います
+func (s *ChainStore) HalvingInterval() (int64, error) {
+	var interval int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(halvingKey)
+		if data == nil {
+			return nil
+		}
+		if len(data) != 8 {
+			return fmt.Errorf("invalid halving interval %x", data)
+		}
+		interval = int64(binary.BigEndian.Uint64(data)) // #nosec G115 -- SaveHalvingInterval で書いた正の値
+		return nil
+	})
+	return interval, err
+}
+
+// SaveHalvingInterval は報酬が半減するブロック間隔を保存します
+func (s *ChainStore) SaveHalvingInterval(interval int64) error {
+	if interval <= 0 {
+		return fmt.Errorf("halving interval must be positive, got %d", interval)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(halvingKey, heightKey(interval))
+	})
+}
+
+// ConnectBlock はブロックが使用した出力をUTXOセットから削除して新しい出力を追加し、取り消し用データと最新ブロックを記録します
+// 1つのトランザクションで書き込むため、途中で失敗しても保存された内容は一貫しています
+func (s *ChainStore) ConnectBlock(block *Block, undo BlockUndo) error {
+	data, err := encodeGob(undo)
+	if err != nil {
+		return fmt.Errorf("failed to encode undo data: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(utxoBucket)
+		for _, transaction := range block.Transactions {
+			if !transaction.IsCoinbase() {
+				for _, input := range transaction.Inputs {
+					if err := bucket.Delete(outpointBytes(input.TxID, input.OutIndex)); err != nil {
+						return err
+					}
+				}
+			}
+			for index, output := range transaction.Outputs {
+				if err := putOutput(bucket, transaction.ID, index, output); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Bucket(undoBucket).Put([]byte(block.Hash), data); err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Put(utxoTipKey, []byte(block.Hash))
+	})
+}
+
+// DisconnectBlock はブロックが作った出力を削除して使用した出力を戻し、最新ブロックを1つ前に戻します
+func (s *ChainStore) DisconnectBlock(block *Block, undo BlockUndo) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(utxoBucket)
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			transaction := block.Transactions[i]
+			for index := range transaction.Outputs {
+				if err := bucket.Delete(outpointBytes(transaction.ID, index)); err != nil {
+					return err
+				}
+			}
+			for _, utxo := range undo.Spent[i] {
+				if err := putOutput(bucket, utxo.TxID, utxo.OutIndex, utxo.Output); err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Bucket(undoBucket).Delete([]byte(block.Hash)); err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Put(utxoTipKey, []byte(block.PreviousHash))
+	})
+}
+
+// LoadUndo はブロックの取り消し用データを返します（保存されていなければ false）
+func (s *ChainStore) LoadUndo(blockHash string) (BlockUndo, bool, error) {
+	var undo BlockUndo
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(undoBucket).Get([]byte(blockHash))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(&undo)
+	})
+	return undo, found, err
+}
+
+// ReplaceUTXOs は保存されたUTXOセットを置き換えます（再構築の結果を書き込むときに使います）
+func (s *ChainStore) ReplaceUTXOs(utxos []UTXO, tip string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(utxoBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(utxoBucket)
+		if err != nil {
+			return err
+		}
+		for _, utxo := range utxos {
+			if err := putOutput(bucket, utxo.TxID, utxo.OutIndex, utxo.Output); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(utxoTipKey, []byte(tip))
+	})
+}
+
+// ReplaceTxIndex は保存されたトランザクションインデックスを置き換え、反映している最新ブロックを記録します
+func (s *ChainStore) ReplaceTxIndex(index map[string]TxLocation, tip string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(txIndexBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(txIndexBucket)
+		if err != nil {
+			return err
+		}
+		for txID, location := range index {
+			key, err := hex.DecodeString(txID)
+			if err != nil {
+				return fmt.Errorf("invalid txindex key %q: %w", txID, err)
+			}
+			if err := bucket.Put(key, location.bytes()); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(txIndexTipKey, []byte(tip))
+	})
+}
+
+// LoadTxIndex は保存されたトランザクションインデックスを返します
+func (s *ChainStore) LoadTxIndex() (map[string]TxLocation, error) {
+	index := make(map[string]TxLocation)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(txIndexBucket).ForEach(func(key, data []byte) error {
+			if len(data) != 12 {
+				return fmt.Errorf("invalid txindex entry for %x", key)
+			}
+			index[hex.EncodeToString(key)] = TxLocation{
+				Height: int64(binary.BigEndian.Uint64(data[:8])), // #nosec G115 -- heightKey で書き込んだ値
+				Offset: int(binary.BigEndian.Uint32(data[8:])),
+			}
+			return nil
+		})
+	})
+	return index, err
+}
+
+// bytes はトランザクションインデックスの値（高さ8バイト + 位置4バイト）を返します
+func (l TxLocation) bytes() []byte {
+	data := make([]byte, 12)
+	copy(data, heightKey(l.Height))
+	binary.BigEndian.PutUint32(data[8:], uint32(l.Offset)) // #nosec G115 -- ブロック内の位置は負にならない
+	return data
+}
+
+// putOutput は出力を1件書き込みます
+func putOutput(bucket *bolt.Bucket, txID []byte, outIndex int, output TxOutput) error {
+	data, err := encodeGob(output)
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return bucket.Put(outpointBytes(txID, outIndex), data)
+}
+
+// LoadUTXOs は保存されたUTXOセットをアウトポイント順に返します
+func (s *ChainStore) LoadUTXOs() ([]UTXO, error) {
+	var utxos []UTXO
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(utxoBucket).ForEach(func(key, data []byte) error {
+			if len(key) < 4 {
+				return fmt.Errorf("invalid utxo key %x", key)
+			}
+			var output TxOutput
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&output); err != nil {
+				return fmt.Errorf("failed to decode output %x: %w", key, err)
+			}
+			split := len(key) - 4
+			utxos = append(utxos, UTXO{
+				TxID:     append([]byte(nil), key[:split]...),
+				OutIndex: int(binary.BigEndian.Uint32(key[split:])),
+				Output:   output,
+			})
+			return nil
+		})
+	})
+	return utxos, err
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestChainStore(t *testing.T) {
+	t.Run("ブロックとUTXOセットを保存して再び開ける", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, reindexed, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		assert.True(t, reindexed, "新しいファイルはジェネシスから構築する")
+
+		mempool := NewMempool(bc, utxoSet)
+		_, _, err = SendCoins(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		assert.Empty(t, utxoSet.undo, "保存先があれば取り消し用データをメモリに持たない")
+		require.NoError(t, store.Close())
+
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		reopened, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		reopenedSet, reindexed, err := OpenUTXOSet(store, reopened)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+
+		assert.False(t, reindexed, "最新ブロックが一致すれば保存されたセットを使う")
+		assert.Equal(t, 2, reopened.GetChainLength())
+		assert.Equal(t, bc.GetLatestBlock().Hash, reopened.GetLatestBlock().Hash)
+		assert.True(t, reopened.IsValid())
+		assert.Equal(t, 20, reopenedSet.GetBalance(testAddressA))
+		assert.Equal(t, utxoSet.GetBalance(wallet.GetAddress()), reopenedSet.GetBalance(wallet.GetAddress()))
+		assert.NoError(t, reopenedSet.VerifyPersisted(reopened))
+	})
+
+	t.Run("ブロックの追加ごとに差分を書き込む", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		mempool := NewMempool(bc, utxoSet)
+
+		for i := 0; i < 3; i++ {
+			_, _, err = SendCoins(mempool, wallet, testAddressA, 5, 1)
+			require.NoError(t, err)
+
+			tip, err := store.UTXOTip()
+			require.NoError(t, err)
+			assert.Equal(t, bc.GetLatestBlock().Hash, tip)
+			assert.NoError(t, utxoSet.VerifyPersisted(bc))
+		}
+
+		utxos, err := store.LoadUTXOs()
+		require.NoError(t, err)
+		// チェーンから再構築したセットと同じ数の出力が保存されている
+		assert.Len(t, utxos, len(NewUTXOSet(bc).allLocked()))
+	})
+
+	t.Run("最新ブロックが食い違えば再構築する", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		_, _, err = OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		// UTXOセットを通さずにブロックを追加する
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(testAddressB, "unindexed")})
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		reopened, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, reindexed, err := OpenUTXOSet(store, reopened)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		assert.True(t, reindexed)
+		assert.Equal(t, InitialBlockReward, utxoSet.GetBalance(testAddressB))
+	})
+
+	t.Run("保存されたセットの不整合を検出する", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		require.NoError(t, utxoSet.VerifyPersisted(bc))
+
+		// ジェネシスの出力をファイルから直接消す
+		genesis := bc.Blocks[0].Transactions[0]
+		require.NoError(t, store.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(utxoBucket).Delete(outpointBytes(genesis.ID, 0))
+		}))
+
+		err = utxoSet.VerifyPersisted(bc)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 missing")
+	})
+
+	t.Run("半減の間隔をチェーンとともに保存して開き直しても使う", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		require.NoError(t, checkHalvingInterval(store, 2))
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), bc.Emission.HalvingInterval)
+
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		mempool := NewMempool(bc, utxoSet)
+		for i := 0; i < 3; i++ {
+			_, _, err := mempool.MineBlock(wallet.GetAddress())
+			require.NoError(t, err)
+		}
+		require.NoError(t, store.Close())
+
+		// 間隔を指定しないサブコマンドも、保存された間隔でチェーンを検証する
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		reopened, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		_, _, err = OpenUTXOSet(store, reopened)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		assert.Equal(t, int64(2), reopened.Emission.HalvingInterval)
+		assert.NoError(t, reopened.Validate())
+
+		require.NoError(t, checkHalvingInterval(store, 2))
+		err = checkHalvingInterval(store, DefaultHalvingInterval)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--halving-interval 2")
+	})
+
+	t.Run("ディスクから読み込んだブロックもマイニングしたときと同じハッシュになる", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		blocks, err := store.LoadBlocks()
+		require.NoError(t, err)
+		require.Len(t, blocks, 2)
+		for _, block := range blocks {
+			assert.Equal(t, block.Hash, block.CalculateHashWithNonce())
+		}
+	})
+
+	t.Run("古い形式で保存したファイルは開かない", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		// 形式のバージョンを記録する前のファイルを再現する
+		require.NoError(t, store.db.Update(func(tx *bolt.Tx) error {
+			if err := tx.Bucket(metaBucket).Delete(formatKey); err != nil {
+				return err
+			}
+			return tx.Bucket(blocksBucket).Put(heightKey(0), []byte("old block"))
+		}))
+		require.NoError(t, store.Close())
+
+		_, err = OpenChainStore(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "older format")
+	})
+
+	t.Run("保存しないUTXOセットは比較できない", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		bc := NewBlockchain(1, wallet.GetAddress())
+
+		assert.Error(t, NewUTXOSet(bc).VerifyPersisted(bc))
+	})
+}
+
+func TestChainStoreDisconnect(t *testing.T) {
+	t.Run("再び開いても保存された取り消し用データで巻き戻せる", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		mempool := NewMempool(bc, utxoSet)
+		_, _, err = SendCoins(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		reopened, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		reopenedSet, reindexed, err := OpenUTXOSet(store, reopened)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+		require.False(t, reindexed)
+
+		tip := reopened.GetLatestBlock()
+		require.NoError(t, reopenedSet.Disconnect(tip))
+		assert.Equal(t, 0, reopenedSet.GetBalance(testAddressA))
+
+		saved, err := store.UTXOTip()
+		require.NoError(t, err)
+		assert.Equal(t, tip.PreviousHash, saved)
+
+		_, found, err := store.LoadUndo(tip.Hash)
+		require.NoError(t, err)
+		assert.False(t, found, "取り消したブロックのデータは削除する")
+
+		// 保存されたセットは先端のブロックを除いたチェーンと一致する
+		previous := &Blockchain{Blocks: reopened.Blocks[:len(reopened.Blocks)-1]}
+		assert.NoError(t, reopenedSet.VerifyPersisted(previous))
+	})
+}
@@ -0,0 +1,172 @@
+// Package main implements the supply audit for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// SupplyAudit はチェーンの発行量の監査結果です
+// 正しいチェーンでは Unspent + Burned + Unclaimed が Scheduled と一致します
+type SupplyAudit struct {
+	Height    int64 // 監査した最新ブロックの高さ
+	Scheduled int   // 発行スケジュールによるブロック報酬の合計
+	Unspent   int   // 使用できる未使用の出力の合計
+	Burned    int   // 使用できないことが確実な出力（焼却された額）の合計
+	Unclaimed int   // マイナーがコインベースで受け取らなかった報酬と手数料の合計
+}
+
+// Expected は使用できる未使用の出力の合計としてあるべき額（スケジュール - 焼却 - 未請求）を返します
+func (a SupplyAudit) Expected() int {
+	return a.Scheduled - a.Burned - a.Unclaimed
+}
+
+// IsUnspendable はロックスクリプトを解除できる scriptSig が存在しないことが確実かを返します
+// スクリプトには分岐がなくすべての命令が実行されるため、解析できないものや OP_RETURN、未対応の命令を
+// 含むものは必ず失敗します
+func (s Script) IsUnspendable() bool {
+	ops, err := s.parse()
+	if err != nil {
+		return true
+	}
+	for _, op := range ops {
+		if _, known := opcodeNames[op.opcode]; op.opcode == OpReturn || (!op.isPush() && !known) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifySupply はチェーンを先頭からたどり、ブロックごとに未使用の出力の合計が
+// 発行スケジュールから焼却された額と未請求の額を引いたものと一致するか確認します
+// 出力が入力を超えるトランザクションや、報酬と手数料より多く受け取るコインベース（インフレーション）があれば、
+// 最初に見つかったブロックと超過額をエラーで返します。ブロックの検証とは独立に計算します
+func (bc *Blockchain) VerifySupply() (SupplyAudit, error) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	var audit SupplyAudit
+	unspent := make(map[string]TxOutput)
+	// addOutput は未使用の出力を追加（sign=1）または使用（sign=-1）し、使用できる額と焼却された額の合計を更新する
+	addOutput := func(key string, output TxOutput, sign int) {
+		if sign > 0 {
+			unspent[key] = output
+		} else {
+			delete(unspent, key)
+		}
+		if output.ScriptPubKey.IsUnspendable() {
+			audit.Burned += sign * output.Value
+		} else {
+			audit.Unspent += sign * output.Value
+		}
+	}
+
+	for _, block := range bc.Blocks {
+		if len(block.Transactions) == 0 {
+			return audit, fmt.Errorf("block %d has no transactions", block.Index)
+		}
+
+		fees, inflation := 0, 0
+		for _, tx := range block.Transactions[1:] {
+			in := 0
+			for _, input := range tx.Inputs {
+				key := outpointKey(input.TxID, input.OutIndex)
+				output, ok := unspent[key]
+				if !ok {
+					return audit, fmt.Errorf("block %d: transaction %s spends missing output %s", block.Index, truncateHash(hex.EncodeToString(tx.ID)), key)
+				}
+				addOutput(key, output, -1)
+				in += output.Value
+			}
+			out := 0
+			for index, output := range tx.Outputs {
+				addOutput(outpointKey(tx.ID, index), output, 1)
+				out += output.Value
+			}
+			// 入力を超える出力は手数料から差し引かず、インフレーションとして数える
+			if out > in {
+				inflation += out - in
+			} else {
+				fees += in - out
+			}
+		}
+
+		coinbase := block.Transactions[0]
+		minted := 0
+		for index, output := range coinbase.Outputs {
+			addOutput(outpointKey(coinbase.ID, index), output, 1)
+			minted += output.Value
+		}
+		if limit := bc.Emission.RewardAt(block.Index) + fees; minted > limit {
+			inflation += minted - limit
+		} else {
+			audit.Unclaimed += limit - minted
+		}
+
+		audit.Height = block.Index
+		audit.Scheduled = bc.Emission.TotalEmitted(block.Index)
+		if audit.Unspent != audit.Expected() {
+			return audit, fmt.Errorf("block %d: unspent outputs total %d, expected %d (inflation of %d coins)", block.Index, audit.Unspent, audit.Expected(), inflation)
+		}
+	}
+	return audit, nil
+}
+
+// runVerifySupplyCommand は verifysupply サブコマンドを実行します
+// chain.db のチェーンの発行量を監査し、UTXOセットの合計とも突き合わせます
+func runVerifySupplyCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Println("❌ Usage: verifysupply")
+		return 2
+	}
+
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(blocks) == 0 {
+		fmt.Printf("❌ No blocks in %s\n", chainFile)
+		return 1
+	}
+	bc, err := OpenBlockchain(store, 2, "")
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	utxoSet, _, err := OpenUTXOSet(store, bc)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	if !printSupplyAudit(bc, utxoSet) {
+		return 1
+	}
+	return 0
+}
+
+// printSupplyAudit は発行量の監査結果を表示し、問題がなければ true を返します
+// UTXOセットの合計（焼却された出力を含む）がチェーンから計算した額と一致するかも確認します
+func printSupplyAudit(bc *Blockchain, utxoSet *UTXOSet) bool {
+	audit, err := bc.VerifySupply()
+	if err != nil {
+		fmt.Printf("❌ Supply audit FAILED: %v\n", err)
+		return false
+	}
+	if supply := utxoSet.Stats().Supply; supply != audit.Unspent+audit.Burned {
+		fmt.Printf("❌ Supply audit FAILED: the UTXO set holds %d coins, but the chain leaves %d unspent\n", supply, audit.Unspent+audit.Burned)
+		return false
+	}
+
+	fmt.Printf("✅ Supply matches the emission schedule at block #%d.\n", audit.Height)
+	fmt.Printf("   Scheduled %d = unspent %d + burned %d + unclaimed %d\n", audit.Scheduled, audit.Unspent, audit.Burned, audit.Unclaimed)
+	return true
+}
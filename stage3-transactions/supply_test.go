@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySupply(t *testing.T) {
+	t.Run("未使用の出力の合計は発行スケジュールと一致する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		fundWallet(t, bc, utxoSet, wallet, 2)
+		_, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 3)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+
+		audit, err := bc.VerifySupply()
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), audit.Height)
+		assert.Equal(t, 200, audit.Scheduled)
+		assert.Equal(t, 200, audit.Unspent)
+		assert.Zero(t, audit.Burned)
+		assert.Zero(t, audit.Unclaimed)
+		assert.Equal(t, utxoSet.Stats().Supply, audit.Unspent)
+	})
+
+	t.Run("マイナーが受け取らなかった報酬は未請求として数える", func(t *testing.T) {
+		_, bc, _, _ := newTestChain(t)
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTxWithReward(testAddressA, "modest", 30)})
+		require.NoError(t, err)
+
+		audit, err := bc.VerifySupply()
+		require.NoError(t, err)
+		assert.Equal(t, 100, audit.Scheduled)
+		assert.Equal(t, 80, audit.Unspent)
+		assert.Equal(t, 20, audit.Unclaimed)
+	})
+
+	t.Run("使用できない出力は焼却された額として数える", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		genesis := utxoSet.FindUTXO(wallet.GetAddress())[0]
+		prevTx, err := bc.FindTransaction(genesis.TxID)
+		require.NoError(t, err)
+
+		change, err := newOutput(wallet.GetAddress(), 40)
+		require.NoError(t, err)
+		burn := &Transaction{
+			Version:   CurrentTxVersion,
+			Inputs:    []TxInput{{TxID: genesis.TxID, OutIndex: genesis.OutIndex}},
+			Outputs:   []TxOutput{{Value: 10, ScriptPubKey: Script{0x6a}.AddData([]byte("burn"))}, change},
+			Timestamp: time.Now().Unix(),
+		}
+		burn.ID = burn.Hash()
+		require.NoError(t, burn.Sign(wallet, map[string]*Transaction{fmt.Sprintf("%x", prevTx.ID): prevTx}))
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "burn"), burn})
+		require.NoError(t, err)
+
+		audit, err := bc.VerifySupply()
+		require.NoError(t, err)
+		assert.Equal(t, 10, audit.Burned)
+		assert.Equal(t, 90, audit.Unspent)
+		assert.Equal(t, audit.Expected(), audit.Unspent)
+	})
+
+	t.Run("報酬より多く受け取るコインベースを検出する", func(t *testing.T) {
+		_, bc, _, _ := newTestChain(t)
+		appendUnchecked(t, bc, []*Transaction{NewCoinbaseTxWithReward(testAddressA, "greedy", 60)})
+
+		_, err := bc.VerifySupply()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "block 1")
+		assert.Contains(t, err.Error(), "inflation of 10 coins")
+	})
+
+	t.Run("入力より多く出力するトランザクションを検出する", func(t *testing.T) {
+		wallet, bc, utxoSet, _ := newTestChain(t)
+		genesis := utxoSet.FindUTXO(wallet.GetAddress())[0]
+		output, err := newOutput(testAddressA, 70)
+		require.NoError(t, err)
+		tx := &Transaction{
+			Version: CurrentTxVersion,
+			Inputs:  []TxInput{{TxID: genesis.TxID, OutIndex: genesis.OutIndex}},
+			Outputs: []TxOutput{output},
+		}
+		tx.ID = tx.Hash()
+		appendUnchecked(t, bc, []*Transaction{NewCoinbaseTx(wallet.GetAddress(), "inflate"), tx})
+
+		_, err = bc.VerifySupply()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "inflation of 20 coins")
+	})
+}
+
+func TestIsUnspendable(t *testing.T) {
+	t.Run("標準のロックスクリプトは使用できる", func(t *testing.T) {
+		assert.False(t, NewP2PKHScript(make([]byte, 20)).IsUnspendable())
+		assert.False(t, NewP2SHScript(make([]byte, 20)).IsUnspendable())
+	})
+
+	t.Run("未対応の命令や解析できないスクリプトは使用できない", func(t *testing.T) {
+		assert.True(t, Script{0x6a}.AddData([]byte("data")).IsUnspendable(), "OP_RETURN")
+		assert.True(t, Script{0x05, 0x01}.IsUnspendable(), "データが足りない")
+	})
+}
@@ -0,0 +1,712 @@
+// Package main implements colored-coin token tagging on UTXOs for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nyasuto/minicoin/common"
+)
+
+// トークン（colored coins）は、出力に資産IDと数量のタグを付けてネイティブのコインとは別の資産を表します
+// タグはトランザクションのマーカー出力 OP_RETURN <"MCT"> (<出力番号> <資産ID> <数量>)... に書き、
+// タグを付けた出力自体は TokenOutputValue のコインを送る通常の出力です（Open Assets と同じ考え方の簡略版）
+const (
+	TokenMarkerTag   = "MCT"
+	TokenOutputValue = 1                 // タグを付けた出力に載せるコイン（ダストの下限）
+	MaxTokenQuantity = 1_000_000_000_000 // 1つの出力に付けられる数量の上限
+	AssetIDSize      = 20                // 資産IDのバイト数
+)
+
+// AssetID は資産のIDです（発行したトランザクションの最初の入力が参照するアウトポイントのハッシュ）
+type AssetID [AssetIDSize]byte
+
+// String は資産IDを16進数で返します
+func (id AssetID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// ParseAssetID は16進数の資産IDを読み取ります
+func ParseAssetID(s string) (AssetID, error) {
+	var id AssetID
+	data, err := hex.DecodeString(s)
+	if err != nil || len(data) != AssetIDSize {
+		return id, fmt.Errorf("invalid asset id %q: expected %d bytes of hex", s, AssetIDSize)
+	}
+	copy(id[:], data)
+	return id, nil
+}
+
+// TokenAmount は出力に付いたトークンのタグ（資産と数量）です
+type TokenAmount struct {
+	Asset    AssetID
+	Quantity int
+}
+
+// IssuanceAssetID はトランザクションが新しく発行できる資産のIDを返します（コインベースは発行できません）
+// アウトポイントは一度しか使えないため、発行のたびに異なるIDになります
+func (tx *Transaction) IssuanceAssetID() (AssetID, bool) {
+	var id AssetID
+	if tx.IsCoinbase() || len(tx.Inputs) == 0 {
+		return id, false
+	}
+	copy(id[:], common.PublicKeyHash(outpointBytes(tx.Inputs[0].TxID, tx.Inputs[0].OutIndex)))
+	return id, true
+}
+
+// NewTokenMarkerScript は出力番号ごとのタグを書いたマーカーのロックスクリプトを作ります
+func NewTokenMarkerScript(tags map[int]TokenAmount) Script {
+	indexes := make([]int, 0, len(tags))
+	for index := range tags {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	script := Script{OpReturn}.AddData([]byte(TokenMarkerTag))
+	for _, index := range indexes {
+		tag := tags[index]
+		script = script.AddInt(int64(index)).AddData(tag.Asset[:]).AddInt(int64(tag.Quantity))
+	}
+	return script
+}
+
+// TokenTags はマーカー出力を読み、出力番号ごとのタグを返します（マーカーがなければ nil）
+// マーカーは1つまでで、タグを付けられるのはマーカー以外の解除できる出力だけです
+func (tx *Transaction) TokenTags() (map[int]TokenAmount, error) {
+	marker := -1
+	var fields [][]byte
+	for index, output := range tx.Outputs {
+		ops, err := output.ScriptPubKey.parse()
+		if err != nil || len(ops) < 2 || ops[0].opcode != OpReturn || !ops[1].isPush() || string(ops[1].pushValue()) != TokenMarkerTag {
+			continue
+		}
+		if marker >= 0 {
+			return nil, fmt.Errorf("outputs %d and %d are both token markers", marker, index)
+		}
+		marker = index
+		for _, op := range ops[2:] {
+			if !op.isPush() {
+				return nil, fmt.Errorf("token marker: unexpected %s", opcodeName(op.opcode))
+			}
+			fields = append(fields, op.pushValue())
+		}
+	}
+	if marker < 0 {
+		return nil, nil
+	}
+	if len(fields)%3 != 0 {
+		return nil, fmt.Errorf("token marker: expected (output, asset, quantity) triples")
+	}
+
+	tags := make(map[int]TokenAmount)
+	for i := 0; i < len(fields); i += 3 {
+		index, err := decodeScriptNum(fields[i], maxScriptNumLen)
+		if err != nil || index < 0 || index >= int64(len(tx.Outputs)) {
+			return nil, fmt.Errorf("token marker: invalid output index %x", fields[i])
+		}
+		outIndex := int(index)
+		if outIndex == marker || tx.Outputs[outIndex].ScriptPubKey.IsUnspendable() {
+			return nil, fmt.Errorf("token marker: output %d cannot carry tokens", outIndex)
+		}
+		if _, dup := tags[outIndex]; dup {
+			return nil, fmt.Errorf("token marker: output %d is tagged twice", outIndex)
+		}
+		if len(fields[i+1]) != AssetIDSize {
+			return nil, fmt.Errorf("token marker: output %d: asset id must be %d bytes", outIndex, AssetIDSize)
+		}
+		quantity, err := decodeScriptNum(fields[i+2], 8)
+		if err != nil || quantity <= 0 || quantity > MaxTokenQuantity {
+			return nil, fmt.Errorf("token marker: output %d: quantity must be between 1 and %d", outIndex, MaxTokenQuantity)
+		}
+
+		var asset AssetID
+		copy(asset[:], fields[i+1])
+		tags[outIndex] = TokenAmount{Asset: asset, Quantity: int(quantity)}
+	}
+	return tags, nil
+}
+
+// CheckTokens はトークンの数量が資産ごとに保存されているか検証します
+// 入力が運ぶ数量と出力に付けた数量は資産ごとに一致する必要があり、増やせるのは IssuanceAssetID の資産（発行）だけです
+// マーカーのない送金でトークンを載せた出力を使うと、トークンが失われるため無効です
+// prevTxs は入力が参照するトランザクションです（コインベースでは使いません）
+func (tx *Transaction) CheckTokens(prevTxs map[string]*Transaction) error {
+	tags, err := tx.TokenTags()
+	if err != nil {
+		return err
+	}
+	if tx.IsCoinbase() {
+		if tags != nil {
+			return fmt.Errorf("coinbase cannot carry tokens")
+		}
+		return nil
+	}
+
+	in := make(map[AssetID]int)
+	for _, input := range tx.Inputs {
+		prevTx, ok := prevTxs[hex.EncodeToString(input.TxID)]
+		if !ok || prevTx == nil {
+			return fmt.Errorf("input %s: previous transaction not found", outpointKey(input.TxID, input.OutIndex))
+		}
+		prevTags, err := prevTx.TokenTags()
+		if err != nil {
+			return fmt.Errorf("input %s: %w", outpointKey(input.TxID, input.OutIndex), err)
+		}
+		if tag, ok := prevTags[input.OutIndex]; ok {
+			in[tag.Asset] += tag.Quantity
+		}
+	}
+	out := make(map[AssetID]int)
+	for _, tag := range tags {
+		out[tag.Asset] += tag.Quantity
+	}
+
+	issued, _ := tx.IssuanceAssetID()
+	for _, asset := range sortedAssets(in, out) {
+		if asset == issued {
+			continue
+		}
+		if in[asset] != out[asset] {
+			return fmt.Errorf("asset %s: outputs carry %d, inputs carry %d", truncateHash(asset.String()), out[asset], in[asset])
+		}
+	}
+	return nil
+}
+
+// sortedAssets は表に現れる資産IDを順に並べて返します
+func sortedAssets(tables ...map[AssetID]int) []AssetID {
+	seen := make(map[AssetID]bool)
+	var assets []AssetID
+	for _, table := range tables {
+		for asset := range table {
+			if !seen[asset] {
+				seen[asset] = true
+				assets = append(assets, asset)
+			}
+		}
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].String() < assets[j].String() })
+	return assets
+}
+
+// TokenIndex は出力に付いたトークンのタグの表です（アウトポイント -> タグ）
+// タグは出力を作ったトランザクションのマーカーだけで決まるため使用済みになっても消さず、残高はUTXOセットと突き合わせて求めます
+type TokenIndex struct {
+	tags  map[string]TokenAmount // outpointKey -> タグ
+	mutex sync.RWMutex
+}
+
+// NewTokenIndex はブロックチェーン全体をたどってタグの表を作ります
+func NewTokenIndex(blockchain *Blockchain) *TokenIndex {
+	ti := &TokenIndex{}
+	ti.Rebuild(blockchain)
+	return ti
+}
+
+// Rebuild はブロックチェーン全体をたどってタグの表を作り直します
+func (ti *TokenIndex) Rebuild(blockchain *Blockchain) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	ti.tags = make(map[string]TokenAmount)
+	for _, tx := range blockchain.GetAllTransactions() {
+		ti.addLocked(tx)
+	}
+}
+
+// ConnectBlock はブロックのトランザクションが作った出力のタグを追加します
+func (ti *TokenIndex) ConnectBlock(block *Block) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	for _, tx := range block.Transactions {
+		ti.addLocked(tx)
+	}
+}
+
+// DisconnectBlock はブロックのトランザクションが作った出力のタグを取り除きます
+func (ti *TokenIndex) DisconnectBlock(block *Block) {
+	ti.mutex.Lock()
+	defer ti.mutex.Unlock()
+
+	for _, tx := range block.Transactions {
+		for index := range tx.Outputs {
+			delete(ti.tags, outpointKey(tx.ID, index))
+		}
+	}
+}
+
+// addLocked はトランザクションのタグを表に追加します（チェーンのトランザクションは検証済みのため、読めないマーカーは無視します）
+// 呼び出し側でロックを取得していることを前提とします
+func (ti *TokenIndex) addLocked(tx *Transaction) {
+	tags, err := tx.TokenTags()
+	if err != nil {
+		return
+	}
+	for index, tag := range tags {
+		ti.tags[outpointKey(tx.ID, index)] = tag
+	}
+}
+
+// Tag は出力に付いたタグを返します
+func (ti *TokenIndex) Tag(txID []byte, outIndex int) (TokenAmount, bool) {
+	if ti == nil {
+		return TokenAmount{}, false
+	}
+	ti.mutex.RLock()
+	defer ti.mutex.RUnlock()
+
+	tag, ok := ti.tags[outpointKey(txID, outIndex)]
+	return tag, ok
+}
+
+// TokenUTXO はトークンを載せたUTXOです
+type TokenUTXO struct {
+	UTXO
+	Token TokenAmount
+}
+
+// TokenUTXOs は指定アドレスのUTXOのうち、指定した資産を載せたものを返します
+func (us *UTXOSet) TokenUTXOs(address string, asset AssetID) []TokenUTXO {
+	var utxos []TokenUTXO
+	for _, utxo := range us.FindUTXO(address) {
+		if tag, ok := us.tokens.Tag(utxo.TxID, utxo.OutIndex); ok && tag.Asset == asset {
+			utxos = append(utxos, TokenUTXO{UTXO: utxo, Token: tag})
+		}
+	}
+	return utxos
+}
+
+// TokenBalances は指定アドレスが持つトークンの数量を資産ごとに返します（ネイティブのコインの残高は GetBalance）
+func (us *UTXOSet) TokenBalances(address string) map[AssetID]int {
+	balances := make(map[AssetID]int)
+	for _, utxo := range us.FindUTXO(address) {
+		if tag, ok := us.tokens.Tag(utxo.TxID, utxo.OutIndex); ok {
+			balances[tag.Asset] += tag.Quantity
+		}
+	}
+	return balances
+}
+
+// TokenSupply はUTXOセット全体にあるトークンの数量を資産ごとに返します
+func (us *UTXOSet) TokenSupply() map[AssetID]int {
+	us.mutex.RLock()
+	defer us.mutex.RUnlock()
+
+	supply := make(map[AssetID]int)
+	for _, utxo := range us.UTXOs {
+		if tag, ok := us.tokens.Tag(utxo.TxID, utxo.OutIndex); ok {
+			supply[tag.Asset] += tag.Quantity
+		}
+	}
+	return supply
+}
+
+// TokenUTXOs は指定アドレスのトークンを載せたUTXOのうち、メモリプール内のトランザクションがまだ使用していないものを返します
+func (mp *Mempool) TokenUTXOs(address string, asset AssetID) []TokenUTXO {
+	mp.mutex.RLock()
+	defer mp.mutex.RUnlock()
+
+	var utxos []TokenUTXO
+	for _, utxo := range mp.utxoSet.TokenUTXOs(address, asset) {
+		if _, pending := mp.spent[outpointKey(utxo.TxID, utxo.OutIndex)]; !pending {
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos
+}
+
+// TokenOutputFinder はトークンの送金に使えるUTXOを探します（UTXOSet と Mempool が実装します）
+type TokenOutputFinder interface {
+	SpendableOutputFinder
+	TokenUTXOs(address string, asset AssetID) []TokenUTXO
+}
+
+// tokenPayment はトークンを送る出力です
+type tokenPayment struct {
+	to       string
+	quantity int
+}
+
+// NewTokenIssue は新しい資産を quantity 個発行して to に送るトランザクションを作成し、ウォレットで署名します
+// 発行した資産のIDは、選んだUTXOで決まる最初の入力から導きます
+func NewTokenIssue(wallet *Wallet, to string, quantity, fee int, utxoSet SpendableOutputFinder, bc *Blockchain) (*Transaction, AssetID, error) {
+	tx, err := newTokenTransaction(wallet, nil, nil, []tokenPayment{{to: to, quantity: quantity}}, fee, utxoSet, bc)
+	if err != nil {
+		return nil, AssetID{}, err
+	}
+	asset, _ := tx.IssuanceAssetID()
+	return tx, asset, nil
+}
+
+// NewTokenTransfer は asset のトークンを quantity 個 to に送るトランザクションを作成し、ウォレットで署名します
+// トークンの余りは送金元に、手数料とタグを付けた出力のコインは通常のUTXOから払います
+func NewTokenTransfer(wallet *Wallet, to string, asset AssetID, quantity, fee int, utxoSet TokenOutputFinder, bc *Blockchain) (*Transaction, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("quantity must be positive")
+	}
+
+	from := wallet.GetAddress()
+	var selected []TokenUTXO
+	have := 0
+	for _, utxo := range utxoSet.TokenUTXOs(from, asset) {
+		if have >= quantity {
+			break
+		}
+		selected = append(selected, utxo)
+		have += utxo.Token.Quantity
+	}
+	if have < quantity {
+		return nil, fmt.Errorf("insufficient tokens of asset %s: have %d, need %d", truncateHash(asset.String()), have, quantity)
+	}
+
+	payments := []tokenPayment{{to: to, quantity: quantity}}
+	if have > quantity {
+		payments = append(payments, tokenPayment{to: from, quantity: have - quantity})
+	}
+	return newTokenTransaction(wallet, selected, &asset, payments, fee, utxoSet, bc)
+}
+
+// newTokenTransaction はトークンを載せたUTXOと通常のUTXOを使い、payments のタグを付けた出力とマーカーを作って署名します
+// asset が nil なら新しい資産の発行で、タグの資産IDは最初の入力から決めます
+func newTokenTransaction(wallet *Wallet, tokenInputs []TokenUTXO, asset *AssetID, payments []tokenPayment, fee int, utxoSet SpendableOutputFinder, bc *Blockchain) (*Transaction, error) {
+	if fee < 0 {
+		return nil, fmt.Errorf("fee must not be negative")
+	}
+	from := wallet.GetAddress()
+
+	var inputs []TxInput
+	var outputs []TxOutput
+	need := fee
+	for _, utxo := range tokenInputs {
+		inputs = append(inputs, TxInput{TxID: utxo.TxID, OutIndex: utxo.OutIndex})
+		need -= utxo.Output.Value
+	}
+	for _, payment := range payments {
+		if payment.quantity <= 0 || payment.quantity > MaxTokenQuantity {
+			return nil, fmt.Errorf("quantity must be between 1 and %d", MaxTokenQuantity)
+		}
+		output, err := newOutput(payment.to, TokenOutputValue)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+		need += TokenOutputValue
+	}
+
+	// 手数料とタグを付けた出力のコインは、トークンを載せていないUTXOから払う
+	change := -need
+	if need > 0 {
+		selection, err := SelectCoins(utxoSet.SpendableUTXOs(from), need, DefaultCoinSelection)
+		if err != nil {
+			return nil, err
+		}
+		coinInputs, err := inputsFromSpendable(selection.spendable())
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, coinInputs...)
+		change = selection.Change()
+	}
+	if change > 0 {
+		output, err := newOutput(from, change)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from address: %w", err)
+		}
+		outputs = append(outputs, output)
+	}
+
+	tx := &Transaction{
+		Version:   CurrentTxVersion,
+		Inputs:    inputs,
+		Timestamp: time.Now().Unix(),
+	}
+	id := asset
+	if id == nil {
+		issued, _ := tx.IssuanceAssetID()
+		id = &issued
+	}
+	tags := make(map[int]TokenAmount)
+	for i, payment := range payments {
+		tags[i] = TokenAmount{Asset: *id, Quantity: payment.quantity}
+	}
+	tx.Outputs = append(outputs, TxOutput{Value: 0, ScriptPubKey: NewTokenMarkerScript(tags)})
+	tx.ID = tx.Hash()
+
+	if err := bc.SignTransaction(tx, wallet); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// SubmitTokenIssue は新しい資産を発行するトランザクションを作成し、メモリプールに追加します
+func SubmitTokenIssue(mempool *Mempool, wallet *Wallet, to string, quantity, fee int) (*Transaction, AssetID, error) {
+	tx, asset, err := NewTokenIssue(wallet, to, quantity, fee, mempool, mempool.blockchain)
+	if err != nil {
+		return nil, AssetID{}, err
+	}
+	if err := mempool.Add(tx); err != nil {
+		return nil, AssetID{}, err
+	}
+	return tx, asset, nil
+}
+
+// SubmitTokenTransfer はトークンを送るトランザクションを作成し、メモリプールに追加します
+func SubmitTokenTransfer(mempool *Mempool, wallet *Wallet, to string, asset AssetID, quantity, fee int) (*Transaction, error) {
+	tx, err := NewTokenTransfer(wallet, to, asset, quantity, fee, mempool, mempool.blockchain)
+	if err != nil {
+		return nil, err
+	}
+	if err := mempool.Add(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+const tokensUsage = `❌ Usage:
+  tokens issue --quantity <n> [--to <address|label>] [--fee <coins>]
+  tokens send --asset <id> --to <address|label> --quantity <n> [--fee <coins>]
+  tokens balance [--address <address>]
+  tokens list`
+
+// runTokensCommand は tokens サブコマンドを実行します
+func runTokensCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(tokensUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "issue":
+		return runTokensIssue(args[1:])
+	case "send":
+		return runTokensSend(args[1:])
+	case "balance":
+		return runTokensBalance(args[1:])
+	case "list":
+		return runTokensList(args[1:])
+	default:
+		fmt.Println(tokensUsage)
+		return 2
+	}
+}
+
+// runTokensIssue は新しい資産を発行し、ブロックをマイニングして取り込みます
+func runTokensIssue(args []string) int {
+	fs := flag.NewFlagSet("tokens issue", flag.ContinueOnError)
+	quantityFlag := fs.Int("quantity", 0, "発行する数量")
+	toFlag := fs.String("to", "", "受取先アドレス（省略時は使用中のウォレット）")
+	feeFlag := fs.Int("fee", DefaultTransactionFee, "手数料（マイナーが受け取る）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *quantityFlag <= 0 {
+		fmt.Println(tokensUsage)
+		return 2
+	}
+
+	return runTokenTransaction(*toFlag, func(mempool *Mempool, wallet *Wallet, to string) (*Transaction, error) {
+		tx, asset, err := SubmitTokenIssue(mempool, wallet, to, *quantityFlag, *feeFlag)
+		if err == nil {
+			fmt.Printf("\n🪙 Issuing %d tokens of asset %s\n", *quantityFlag, asset)
+		}
+		return tx, err
+	})
+}
+
+// runTokensSend はトークンを送り、ブロックをマイニングして取り込みます
+func runTokensSend(args []string) int {
+	fs := flag.NewFlagSet("tokens send", flag.ContinueOnError)
+	assetFlag := fs.String("asset", "", "送る資産のID（16進数）")
+	toFlag := fs.String("to", "", "送金先アドレス（アドレス帳のラベルも可）")
+	quantityFlag := fs.Int("quantity", 0, "送る数量")
+	feeFlag := fs.Int("fee", DefaultTransactionFee, "手数料（マイナーが受け取る）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *assetFlag == "" || *toFlag == "" || *quantityFlag <= 0 {
+		fmt.Println(tokensUsage)
+		return 2
+	}
+	asset, err := ParseAssetID(*assetFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 2
+	}
+
+	return runTokenTransaction(*toFlag, func(mempool *Mempool, wallet *Wallet, to string) (*Transaction, error) {
+		fmt.Printf("\n🪙 Sending %d tokens of asset %s\n", *quantityFlag, asset)
+		return SubmitTokenTransfer(mempool, wallet, to, asset, *quantityFlag, *feeFlag)
+	})
+}
+
+// runTokenTransaction は使用中のウォレットでトークンのトランザクションを作ってメモリプールに追加し、マイニングして結果を表示します
+// recipient が空なら使用中のウォレットに送ります
+func runTokenTransaction(recipient string, submit func(mempool *Mempool, wallet *Wallet, to string) (*Transaction, error)) int {
+	wallets, err := loadOrCreateWallets()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+	wallet, err := wallets.ActiveWallet()
+	if err != nil {
+		fmt.Printf("❌ Failed to load wallet: %v\n", err)
+		return 1
+	}
+	to := wallet.GetAddress()
+	if recipient != "" {
+		if to, err = wallets.ResolveRecipient(recipient); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 2
+		}
+		if err := common.ValidateAddress(to); err != nil {
+			printAddressError(err)
+			return 2
+		}
+	}
+
+	store, bc, utxoSet, err := openChain(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+	mempool := NewMempool(bc, utxoSet)
+
+	tx, err := submit(mempool, wallet, to)
+	if err != nil {
+		printSendError(err)
+		return 1
+	}
+	block, metrics, err := mempool.MineBlock(wallet.GetAddress())
+	if err != nil {
+		fmt.Printf("❌ Send failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("\n✅ Token transaction mined!")
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("To:         %s\n", wallets.DisplayAddress(to))
+	fmt.Printf("TxID:       %s\n", truncateHash(fmt.Sprintf("%x", tx.ID)))
+	fmt.Printf("Block #%d:  %s (%d attempts)\n", block.Index, truncateHash(block.Hash), metrics.Attempts)
+	printTokenBalances(utxoSet.TokenBalances(wallet.GetAddress()))
+	fmt.Printf("Balance:    %d coins (tagged outputs included)\n", utxoSet.GetBalance(wallet.GetAddress()))
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
+
+// runTokensBalance はアドレスのトークンの残高を資産ごとに、ネイティブのコインの残高と分けて表示します
+func runTokensBalance(args []string) int {
+	fs := flag.NewFlagSet("tokens balance", flag.ContinueOnError)
+	addressFlag := fs.String("address", "", "残高を表示するアドレス（省略時は使用中のウォレット）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	address := *addressFlag
+	if address == "" {
+		wallets, err := loadOrCreateWallets()
+		if err != nil {
+			fmt.Printf("❌ Failed to load wallet: %v\n", err)
+			return 1
+		}
+		wallet, err := wallets.ActiveWallet()
+		if err != nil {
+			fmt.Printf("❌ Failed to load wallet: %v\n", err)
+			return 1
+		}
+		address = wallet.GetAddress()
+	}
+
+	store, utxoSet, err := openSavedUTXOSet()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	spendable := 0
+	for _, utxo := range utxoSet.SpendableUTXOs(address) {
+		spendable += utxo.Output.Value
+	}
+	fmt.Printf("\n🪙 Balances of %s\n", address)
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("Coins:      %d (%d spendable, the rest carries tokens)\n", utxoSet.GetBalance(address), spendable)
+	printTokenBalances(utxoSet.TokenBalances(address))
+	fmt.Println("────────────────────────────────────────────────────────")
+	return 0
+}
+
+// runTokensList はUTXOセットにある資産と、それぞれの流通量を表示します
+func runTokensList(args []string) int {
+	fs := flag.NewFlagSet("tokens list", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	store, utxoSet, err := openSavedUTXOSet()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	supply := utxoSet.TokenSupply()
+	fmt.Println("\n🪙 Assets in the UTXO set")
+	fmt.Println("────────────────────────────────────────────────────────")
+	for _, asset := range sortedAssets(supply) {
+		fmt.Printf("%s  %d\n", asset, supply[asset])
+	}
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Printf("%d asset(s)\n", len(supply))
+	return 0
+}
+
+// openSavedUTXOSet は保存されたチェーンのUTXOセットを開きます（チェーンがなければ作らずにエラーを返します）
+func openSavedUTXOSet() (*ChainStore, *UTXOSet, error) {
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	utxoSet, err := loadSavedUTXOSet(store)
+	if err != nil {
+		_ = store.Close()
+		return nil, nil, err
+	}
+	return store, utxoSet, nil
+}
+
+// loadSavedUTXOSet は保存先のブロックからチェーンを読み込み、UTXOセットを開きます
+func loadSavedUTXOSet(store *ChainStore) (*UTXOSet, error) {
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no blocks in %s", chainFile)
+	}
+	bc, err := OpenBlockchain(store, 2, "")
+	if err != nil {
+		return nil, err
+	}
+	utxoSet, _, err := OpenUTXOSet(store, bc)
+	return utxoSet, err
+}
+
+// printTokenBalances はトークンの残高を資産ごとに表示します
+func printTokenBalances(balances map[AssetID]int) {
+	if len(balances) == 0 {
+		fmt.Println("Tokens:     none")
+		return
+	}
+	lines := make([]string, 0, len(balances))
+	for _, asset := range sortedAssets(balances) {
+		lines = append(lines, fmt.Sprintf("%s  %d", asset, balances[asset]))
+	}
+	fmt.Printf("Tokens:     %s\n", strings.Join(lines, "\n            "))
+}
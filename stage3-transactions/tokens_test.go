@@ -0,0 +1,229 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issueTokens はウォレットに quantity 個の新しい資産を発行し、ブロックに取り込みます
+func issueTokens(t *testing.T, mempool *Mempool, wallet *Wallet, quantity int) AssetID {
+	t.Helper()
+
+	_, asset, err := SubmitTokenIssue(mempool, wallet, wallet.GetAddress(), quantity, 1)
+	require.NoError(t, err)
+	_, _, err = mempool.MineBlock(wallet.GetAddress())
+	require.NoError(t, err)
+	return asset
+}
+
+// newSignedTokenTx は入力と出力を指定したトランザクションを作り、ウォレットで署名します
+func newSignedTokenTx(t *testing.T, bc *Blockchain, wallet *Wallet, inputs []UTXO, outputs []TxOutput) *Transaction {
+	t.Helper()
+
+	tx := &Transaction{Version: CurrentTxVersion, Outputs: outputs, Timestamp: time.Now().Unix()}
+	for _, utxo := range inputs {
+		tx.Inputs = append(tx.Inputs, TxInput{TxID: utxo.TxID, OutIndex: utxo.OutIndex})
+	}
+	tx.ID = tx.Hash()
+	require.NoError(t, bc.SignTransaction(tx, wallet))
+	return tx
+}
+
+func TestTokenTags(t *testing.T) {
+	asset := AssetID{1, 2, 3}
+	recipient, err := newOutput(testAddressA, TokenOutputValue)
+	require.NoError(t, err)
+
+	t.Run("マーカーに書いたタグを出力番号ごとに読み取る", func(t *testing.T) {
+		marker := NewTokenMarkerScript(map[int]TokenAmount{0: {Asset: asset, Quantity: 300}, 2: {Asset: asset, Quantity: 1_000_000}})
+		tx := &Transaction{Outputs: []TxOutput{recipient, {Value: 0, ScriptPubKey: marker}, recipient}}
+
+		tags, err := tx.TokenTags()
+		require.NoError(t, err)
+		assert.Equal(t, map[int]TokenAmount{0: {Asset: asset, Quantity: 300}, 2: {Asset: asset, Quantity: 1_000_000}}, tags)
+		assert.True(t, marker.IsUnspendable())
+		assert.Contains(t, marker.String(), "OP_RETURN")
+	})
+
+	t.Run("マーカーがなければタグもない", func(t *testing.T) {
+		burn := TxOutput{Value: 0, ScriptPubKey: Script{OpReturn}.AddData([]byte("hello"))}
+		tags, err := (&Transaction{Outputs: []TxOutput{recipient, burn}}).TokenTags()
+		assert.NoError(t, err)
+		assert.Nil(t, tags)
+	})
+
+	t.Run("不正なマーカーを拒否する", func(t *testing.T) {
+		marker := func(tags map[int]TokenAmount) TxOutput {
+			return TxOutput{Value: 0, ScriptPubKey: NewTokenMarkerScript(tags)}
+		}
+		tagged := map[int]TokenAmount{0: {Asset: asset, Quantity: 1}}
+		cases := map[string][]TxOutput{
+			"マーカーが2つ":       {recipient, marker(tagged), marker(tagged)},
+			"マーカー自体にタグを付ける": {recipient, marker(map[int]TokenAmount{1: {Asset: asset, Quantity: 1}})},
+			"存在しない出力":       {recipient, marker(map[int]TokenAmount{5: {Asset: asset, Quantity: 1}})},
+			"数量が0":          {recipient, marker(map[int]TokenAmount{0: {Asset: asset, Quantity: 0}})},
+			"数量が上限を超える":     {recipient, marker(map[int]TokenAmount{0: {Asset: asset, Quantity: MaxTokenQuantity + 1}})},
+			"組が欠けている":       {recipient, {Value: 0, ScriptPubKey: Script{OpReturn}.AddData([]byte(TokenMarkerTag)).AddInt(0)}},
+			"同じ出力に2回":       {recipient, {Value: 0, ScriptPubKey: NewTokenMarkerScript(tagged).AddInt(0).AddData(asset[:]).AddInt(1)}},
+		}
+		for name, outputs := range cases {
+			_, err := (&Transaction{Outputs: outputs}).TokenTags()
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestParseAssetID(t *testing.T) {
+	t.Run("16進数の資産IDを読み取る", func(t *testing.T) {
+		asset := AssetID{0xab, 0xcd}
+		parsed, err := ParseAssetID(asset.String())
+		require.NoError(t, err)
+		assert.Equal(t, asset, parsed)
+
+		_, err = ParseAssetID("abcd")
+		assert.Error(t, err)
+		_, err = ParseAssetID("zz")
+		assert.Error(t, err)
+	})
+}
+
+func TestTokenIssueAndTransfer(t *testing.T) {
+	t.Run("発行した資産の残高をネイティブのコインと別に追跡する", func(t *testing.T) {
+		wallet, _, utxoSet, mempool := newTestChain(t)
+		asset := issueTokens(t, mempool, wallet, 1000)
+
+		assert.Equal(t, map[AssetID]int{asset: 1000}, utxoSet.TokenBalances(wallet.GetAddress()))
+		assert.Equal(t, map[AssetID]int{asset: 1000}, utxoSet.TokenSupply())
+		tokenUTXOs := utxoSet.TokenUTXOs(wallet.GetAddress(), asset)
+		require.Len(t, tokenUTXOs, 1)
+		assert.Equal(t, TokenOutputValue, tokenUTXOs[0].Output.Value)
+
+		// トークンを載せた出力は通常の送金には使わない
+		spendable := 0
+		for _, utxo := range utxoSet.SpendableUTXOs(wallet.GetAddress()) {
+			spendable += utxo.Output.Value
+		}
+		assert.Equal(t, utxoSet.GetBalance(wallet.GetAddress())-TokenOutputValue, spendable)
+	})
+
+	t.Run("トークンを送ると余りが送金元に戻る", func(t *testing.T) {
+		wallet, _, utxoSet, mempool := newTestChain(t)
+		asset := issueTokens(t, mempool, wallet, 1000)
+
+		tx, err := SubmitTokenTransfer(mempool, wallet, testAddressA, asset, 300, 1)
+		require.NoError(t, err)
+		tags, err := tx.TokenTags()
+		require.NoError(t, err)
+		assert.Equal(t, TokenAmount{Asset: asset, Quantity: 300}, tags[0])
+		assert.Equal(t, TokenAmount{Asset: asset, Quantity: 700}, tags[1])
+		_, _, err = mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+
+		assert.Equal(t, map[AssetID]int{asset: 700}, utxoSet.TokenBalances(wallet.GetAddress()))
+		assert.Equal(t, map[AssetID]int{asset: 300}, utxoSet.TokenBalances(testAddressA))
+		assert.Equal(t, map[AssetID]int{asset: 1000}, utxoSet.TokenSupply())
+
+		_, err = SubmitTokenTransfer(mempool, wallet, testAddressA, asset, 701, 1)
+		assert.ErrorContains(t, err, "insufficient tokens")
+	})
+
+	t.Run("通常の送金はトークンを載せた出力を使わない", func(t *testing.T) {
+		wallet, _, utxoSet, mempool := newTestChain(t)
+		asset := issueTokens(t, mempool, wallet, 10)
+
+		spendable := utxoSet.GetBalance(wallet.GetAddress()) - TokenOutputValue
+		_, _, err := SendCoins(mempool, wallet, testAddressB, spendable-1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, map[AssetID]int{asset: 10}, utxoSet.TokenBalances(wallet.GetAddress()))
+	})
+
+	t.Run("発行のたびに異なる資産になる", func(t *testing.T) {
+		wallet, _, utxoSet, mempool := newTestChain(t)
+		first := issueTokens(t, mempool, wallet, 5)
+		second := issueTokens(t, mempool, wallet, 7)
+
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, map[AssetID]int{first: 5, second: 7}, utxoSet.TokenBalances(wallet.GetAddress()))
+	})
+
+	t.Run("ブロックの取り消しと再構築でタグも戻る", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		asset := issueTokens(t, mempool, wallet, 100)
+		_, err := SubmitTokenTransfer(mempool, wallet, testAddressA, asset, 40, 1)
+		require.NoError(t, err)
+		block, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+
+		require.NoError(t, utxoSet.Disconnect(block))
+		assert.Equal(t, map[AssetID]int{asset: 100}, utxoSet.TokenBalances(wallet.GetAddress()))
+		assert.Empty(t, utxoSet.TokenBalances(testAddressA))
+
+		bc.Blocks = bc.Blocks[:len(bc.Blocks)-1]
+		require.NoError(t, utxoSet.Reindex(bc))
+		assert.Equal(t, map[AssetID]int{asset: 100}, utxoSet.TokenSupply())
+	})
+}
+
+func TestTokenConservation(t *testing.T) {
+	t.Run("数量を増やす送金はメモリプールでもブロックでも無効", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		asset := issueTokens(t, mempool, wallet, 100)
+		tokenUTXO := utxoSet.TokenUTXOs(wallet.GetAddress(), asset)[0]
+
+		recipient, err := newOutput(testAddressA, TokenOutputValue)
+		require.NoError(t, err)
+		marker := TxOutput{Value: 0, ScriptPubKey: NewTokenMarkerScript(map[int]TokenAmount{0: {Asset: asset, Quantity: 101}})}
+		tx := newSignedTokenTx(t, bc, wallet, []UTXO{tokenUTXO.UTXO}, []TxOutput{recipient, marker})
+
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outputs carry 101, inputs carry 100")
+
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "inflate"), tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outputs carry 101, inputs carry 100")
+	})
+
+	t.Run("マーカーのない送金でトークンを載せた出力を使うと無効", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		asset := issueTokens(t, mempool, wallet, 100)
+		tokenUTXO := utxoSet.TokenUTXOs(wallet.GetAddress(), asset)[0]
+		coins := utxoSet.SpendableUTXOs(wallet.GetAddress())[0]
+
+		output, err := newOutput(testAddressA, coins.Output.Value)
+		require.NoError(t, err)
+		tx := newSignedTokenTx(t, bc, wallet, []UTXO{tokenUTXO.UTXO, coins}, []TxOutput{output})
+
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outputs carry 0, inputs carry 100")
+	})
+
+	t.Run("発行していない資産は作れない", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		coins := utxoSet.SpendableUTXOs(wallet.GetAddress())[0]
+
+		recipient, err := newOutput(testAddressA, TokenOutputValue)
+		require.NoError(t, err)
+		change, err := newOutput(wallet.GetAddress(), coins.Output.Value-TokenOutputValue-1)
+		require.NoError(t, err)
+		marker := TxOutput{Value: 0, ScriptPubKey: NewTokenMarkerScript(map[int]TokenAmount{0: {Asset: AssetID{9}, Quantity: 5}})}
+		tx := newSignedTokenTx(t, bc, wallet, []UTXO{coins}, []TxOutput{recipient, change, marker})
+
+		assert.ErrorContains(t, mempool.Add(tx), "outputs carry 5, inputs carry 0")
+	})
+
+	t.Run("コインベースはトークンを持てない", func(t *testing.T) {
+		wallet, bc, _, _ := newTestChain(t)
+		coinbase := NewCoinbaseTxAtHeight(wallet.GetAddress(), "tokens", InitialBlockReward, 1)
+		coinbase.Outputs = append(coinbase.Outputs, TxOutput{Value: 0, ScriptPubKey: NewTokenMarkerScript(map[int]TokenAmount{0: {Asset: AssetID{1}, Quantity: 5}})})
+		coinbase.ID = coinbase.Hash()
+
+		_, _, err := bc.MineBlock([]*Transaction{coinbase})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "coinbase cannot carry tokens")
+	})
+}
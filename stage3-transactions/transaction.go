@@ -6,138 +6,165 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"sort"
 	"time"
 
 	"github.com/nyasuto/minicoin/common"
 )
 
+// DefaultTransactionFee は送金時の既定の手数料です
+const DefaultTransactionFee = 1
+
 // Transaction はトランザクションを表します
 type Transaction struct {
 	ID        []byte     // トランザクションID（ハッシュ）
+	Version   uint32     // バージョン（使える機能が決まる。version.go を参照）
 	Inputs    []TxInput  // 入力
 	Outputs   []TxOutput // 出力
 	Timestamp int64      // タイムスタンプ
+	LockTime  int64      // このブロック高さ以降のブロックにしか含められない（0なら制限なし）
 }
 
 // TxInput はトランザクション入力を表します
 type TxInput struct {
 	TxID      []byte // 参照するトランザクションID
 	OutIndex  int    // 参照する出力のインデックス
-	Signature []byte // 署名
-	PubKey    []byte // 公開鍵
+	ScriptSig Script // ロック解除スクリプト（署名と公開鍵など。コインベースでは任意のデータ）
 }
 
 // TxOutput はトランザクション出力を表します
 type TxOutput struct {
-	Value      int    // 送金額
-	PubKeyHash []byte // 受取人の公開鍵ハッシュ
+	Value        int    // 送金額
+	ScriptPubKey Script // ロックスクリプト（この出力を使うための条件）
 }
 
-// NewCoinbaseTx はコインベーストランザクション（マイニング報酬）を作成します
-func NewCoinbaseTx(to string, data string) *Transaction {
-	if data == "" {
-		data = fmt.Sprintf("Reward to '%s'", to)
+// newOutput は送金先のアドレスの種類に応じたロックスクリプトの出力を作成します
+// P2SHのアドレス（3で始まる）ならP2SH、旧形式（16進数）のアドレスなら旧形式のハッシュ、それ以外はP2PKHになります
+func newOutput(to string, value int) (TxOutput, error) {
+	// 打ち間違えたアドレスはトランザクションを作る前に検出する
+	if err := common.ValidateAddress(to); err != nil {
+		return TxOutput{}, err
 	}
 
-	// コインベーストランザクションは入力なし
-	txIn := TxInput{
-		TxID:      []byte{},
-		OutIndex:  -1,
-		Signature: nil,
-		PubKey:    []byte(data),
+	if common.IsScriptAddress(to) {
+		scriptHash, err := common.AddressToScriptHash(to)
+		if err != nil {
+			return TxOutput{}, fmt.Errorf("invalid to address: %w", err)
+		}
+		return TxOutput{Value: value, ScriptPubKey: NewP2SHScript(scriptHash)}, nil
 	}
 
-	// アドレスを公開鍵ハッシュに変換
-	pubKeyHash, err := hex.DecodeString(to)
+	pubKeyHash, err := common.DecodeAddress(to)
 	if err != nil {
-		pubKeyHash = []byte(to)
+		return TxOutput{}, fmt.Errorf("invalid to address: %w", err)
 	}
-
-	txOut := TxOutput{
-		Value:      50, // マイニング報酬
-		PubKeyHash: pubKeyHash,
-	}
-
-	tx := &Transaction{
-		Inputs:    []TxInput{txIn},
-		Outputs:   []TxOutput{txOut},
-		Timestamp: time.Now().Unix(),
+	if common.IsLegacyAddress(to) {
+		return TxOutput{Value: value, ScriptPubKey: NewLegacyP2PKHScript(pubKeyHash)}, nil
 	}
+	return TxOutput{Value: value, ScriptPubKey: NewP2PKHScript(pubKeyHash)}, nil
+}
 
-	tx.ID = tx.Hash()
+// Address は出力の受取先のアドレスを返します（標準のロックスクリプトでなければ空文字列）
+func (out TxOutput) Address() string {
+	return out.ScriptPubKey.Address()
+}
 
-	return tx
+// NewCoinbaseTx は最初のブロック報酬を受け取るコインベーストランザクションを作成します
+func NewCoinbaseTx(to string, data string) *Transaction {
+	return NewCoinbaseTxWithReward(to, data, InitialBlockReward)
 }
 
-// NewTransaction は新しいトランザクションを作成します
-// 注意: この実装は簡略版です。Issue #11でUTXO検索機能を追加します
-func NewTransaction(from, to string, amount int, _ interface{}) (*Transaction, error) {
-	if amount <= 0 {
-		return nil, fmt.Errorf("amount must be positive")
+// NewCoinbaseTxWithReward は指定額（ブロック報酬 + 手数料）を受け取るコインベーストランザクションを作成します
+// ブロックの高さをコミットしない以外は NewCoinbaseTxAtHeight と同じで、scriptSig には乱数とデータを入れます
+func NewCoinbaseTxWithReward(to string, data string, reward int) *Transaction {
+	if data == "" {
+		data = fmt.Sprintf("Reward to '%s'", to)
 	}
+	return newCoinbaseTx(to, Script{}.AddData(newExtraNonce()).AddData([]byte(data)), reward)
+}
 
-	// 現時点では簡単な実装（Issue #11でUTXOロジックを追加）
-	// ここでは基本的な構造のみ作成
-	inputs := []TxInput{}
-	outputs := []TxOutput{}
+// SpendableOutputFinder は送金に使える出力を検索します
+// UTXOSetはすべての未使用出力を、Mempoolは保留中のトランザクションが使用していない出力を返します
+type SpendableOutputFinder interface {
+	SpendableUTXOs(address string) []UTXO
+}
 
-	// from の公開鍵ハッシュ
-	fromPubKeyHash, err := hex.DecodeString(from)
+// NewTransaction はUTXOを選んで送金トランザクションを作成し、ウォレットで署名します
+// 入力の合計と出力の合計の差が手数料になり、残りはおつりとして送金元に戻します
+func NewTransaction(signer Signer, to string, amount, fee int, utxoSet SpendableOutputFinder, bc *Blockchain) (*Transaction, error) {
+	tx, _, err := NewTransactionWithStrategy(signer, to, amount, fee, DefaultCoinSelection, utxoSet, bc)
+	return tx, err
+}
+
+// NewTransactionWithStrategy は指定した方式でUTXOを選んで送金トランザクションを作成し、選んだ結果とともに返します
+func NewTransactionWithStrategy(signer Signer, to string, amount, fee int, strategy CoinSelectionStrategy, utxoSet SpendableOutputFinder, bc *Blockchain) (*Transaction, *CoinSelection, error) {
+	tx, selection, err := NewUnsignedTransaction(signer.GetAddress(), to, amount, fee, strategy, utxoSet)
 	if err != nil {
-		return nil, fmt.Errorf("invalid from address: %w", err)
+		return nil, nil, err
 	}
 
-	// to の公開鍵ハッシュ
-	toPubKeyHash, err := hex.DecodeString(to)
-	if err != nil {
-		return nil, fmt.Errorf("invalid to address: %w", err)
+	if err := bc.SignTransaction(tx, signer); err != nil {
+		return nil, nil, err
 	}
 
-	// 出力を作成
-	outputs = append(outputs, TxOutput{
-		Value:      amount,
-		PubKeyHash: toPubKeyHash,
-	})
+	return tx, selection, nil
+}
 
-	// おつりの出力（今は簡略化のため省略、Issue #11で実装）
+// NewUnsignedTransaction は from のUTXOから送金する未署名のトランザクションを作成します
+// 署名は別のウォレットで行う場合（PSBT）に使います。おつりは from に戻します
+func NewUnsignedTransaction(from, to string, amount, fee int, strategy CoinSelectionStrategy, utxoSet SpendableOutputFinder) (*Transaction, *CoinSelection, error) {
+	return NewUnsignedBatchTransaction(from, []Payment{{To: to, Amount: amount}}, fee, strategy, utxoSet)
+}
 
-	tx := &Transaction{
-		Inputs:    inputs,
-		Outputs:   outputs,
-		Timestamp: time.Now().Unix(),
+// inputsFromSpendable は選んだUTXOから未署名の入力を作成します
+// マップの順序に依存しないようTxID順に並べます
+func inputsFromSpendable(spendable map[string][]int) ([]TxInput, error) {
+	txIDs := make([]string, 0, len(spendable))
+	for txID := range spendable {
+		txIDs = append(txIDs, txID)
 	}
+	sort.Strings(txIDs)
 
-	tx.ID = tx.Hash()
-
-	// 署名は Issue #11 で実装
-	_ = fromPubKeyHash
-
-	return tx, nil
+	var inputs []TxInput
+	for _, txID := range txIDs {
+		id, err := hex.DecodeString(txID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid utxo transaction id: %w", err)
+		}
+		for _, outIndex := range spendable[txID] {
+			inputs = append(inputs, TxInput{TxID: id, OutIndex: outIndex})
+		}
+	}
+	return inputs, nil
 }
 
-// Hash はトランザクションのハッシュを計算します
+// Hash はトランザクションIDとなるハッシュを計算します
+// 署名（コインベース以外の scriptSig）は含めないため、署名の前後や署名者の数によってIDは変わりません
 func (tx *Transaction) Hash() []byte {
-	txCopy := *tx
-	txCopy.ID = []byte{}
-
-	return common.Hash(tx.serialize())
+	return common.Hash(tx.serialize(false))
 }
 
-// serialize はトランザクションをバイト列にシリアライズします
-func (tx *Transaction) serialize() []byte {
-	var buffer bytes.Buffer
+// WitnessHash は署名を含めたトランザクション全体のハッシュを計算します（BitcoinのwtxidにあたるIDです）
+// ブロックはこのハッシュにもコミットするため、IDの変わらない署名の書き換えも検出できます
+func (tx *Transaction) WitnessHash() []byte {
+	return common.Hash(tx.Serialize())
+}
 
-	encoder := gob.NewEncoder(&buffer)
-	err := encoder.Encode(tx)
-	if err != nil {
-		return []byte{}
+// CheckID はトランザクションIDが内容から計算したハッシュと一致するかを検証します
+// ブロックハッシュはIDから計算するため、一致しなければブロックは実際の内容にコミットしていません
+func (tx *Transaction) CheckID() error {
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		return fmt.Errorf("transaction id %x does not match its contents", tx.ID)
 	}
+	return nil
+}
 
-	return buffer.Bytes()
+// Size はシリアライズしたトランザクションのバイト数を返します
+func (tx *Transaction) Size() int {
+	return len(tx.Serialize())
 }
 
 // IsCoinbase はコインベーストランザクションかどうかを判定します
@@ -145,81 +172,179 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Inputs) == 1 && len(tx.Inputs[0].TxID) == 0 && tx.Inputs[0].OutIndex == -1
 }
 
-// Sign はトランザクションに署名します
+// Sign はトランザクションの入力のうち、署名者（ウォレットや外部の署名デバイス）の鍵で解除できるものに署名します
+// P2PKHの入力は scriptSig を <署名> <公開鍵> にし、P2SHの入力は償還スクリプトの公開鍵の順に署名を追加します
 // prevTxs: 参照する前トランザクションのマップ（TxID(hex) -> Transaction）
-func (tx *Transaction) Sign(wallet *Wallet, prevTxs map[string]*Transaction) error {
+func (tx *Transaction) Sign(signer Signer, prevTxs map[string]*Transaction) error {
 	if tx.IsCoinbase() {
 		return nil // コインベーストランザクションは署名不要
 	}
 
-	// 各入力について前トランザクションが存在するか確認
+	signed, err := tx.signInputs(signer, prevTxs)
+	if err != nil {
+		return err
+	}
+	if !signed {
+		return fmt.Errorf("wallet %s cannot sign any input", signer.GetAddress())
+	}
+	return nil
+}
+
+// signInputs はウォレットの鍵で解除できる入力に署名し、1つでも署名したかを返します
+func (tx *Transaction) signInputs(signer Signer, prevTxs map[string]*Transaction) (bool, error) {
+	// 各入力について前トランザクションの出力が存在するか確認
 	for _, input := range tx.Inputs {
-		if prevTxs[hex.EncodeToString(input.TxID)] == nil {
-			return fmt.Errorf("previous transaction not found")
+		if _, ok := previousOutput(input, prevTxs); !ok {
+			return false, fmt.Errorf("previous transaction not found")
 		}
 	}
 
-	// トランザクションのコピーを作成
-	txCopy := tx.trimmedCopy()
+	pubKey := publicKeyToBytes(signer.Public())
+	signed := false
 
 	// 各入力に署名
-	for i, input := range txCopy.Inputs {
-		prevTx := prevTxs[hex.EncodeToString(input.TxID)]
-		txCopy.Inputs[i].Signature = nil
-		txCopy.Inputs[i].PubKey = prevTx.Outputs[input.OutIndex].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[i].PubKey = nil
-
-		// 署名を生成
-		signature, err := wallet.Sign(txCopy.ID)
-		if err != nil {
-			return fmt.Errorf("failed to sign transaction: %w", err)
+	for i, input := range tx.Inputs {
+		prevOutput, _ := previousOutput(input, prevTxs)
+		class, hash := prevOutput.ScriptPubKey.classify()
+
+		switch class {
+		case p2pkhScript, legacyP2PKHScript:
+			if !bytes.Equal(hash, walletHash(signer, class)) {
+				continue // このウォレット宛ての出力ではない
+			}
+			signature, err := signer.Sign(tx.sigHash(i, prevOutput))
+			if err != nil {
+				return false, fmt.Errorf("failed to sign transaction: %w", err)
+			}
+			tx.Inputs[i].ScriptSig = Script{}.AddData(signature).AddData(pubKey)
+			signed = true
+
+		case p2shScript:
+			ok, err := tx.signScriptInput(i, prevOutput, signer)
+			if err != nil {
+				return false, fmt.Errorf("input %d: %w", i, err)
+			}
+			signed = signed || ok
 		}
-
-		tx.Inputs[i].Signature = signature
-		tx.Inputs[i].PubKey = publicKeyToBytes(wallet.PublicKey)
 	}
+	return signed, nil
+}
 
-	return nil
+// walletHash はロックスクリプトの種類に応じたウォレットの公開鍵ハッシュを返します
+func walletHash(signer Signer, class scriptClass) []byte {
+	if class == legacyP2PKHScript {
+		hash, err := hex.DecodeString(common.LegacyPublicKeyToAddress(signer.Public()))
+		if err != nil {
+			return nil
+		}
+		return hash
+	}
+	return common.PublicKeyHash(publicKeyToBytes(signer.Public()))
 }
 
-// Verify はトランザクションの署名を検証します
+// Verify はすべての入力について scriptSig と参照する出力の scriptPubKey を実行し、ロックを解除できるかを検証します
+// 金額が範囲内で、入力の合計が出力の合計以上であることも検証します（参照先が未使用かは VerifyWithView で検証します）
+// ロック時刻に達しているか（次のブロックに含められるか）は Blockchain.VerifyTransaction で検証します
 func (tx *Transaction) Verify(prevTxs map[string]*Transaction) bool {
 	if tx.IsCoinbase() {
 		return true // コインベーストランザクションは常に有効
 	}
+	_, err := tx.VerifyWithView(prevTxsView(prevTxs))
+	return err == nil
+}
 
-	// 各入力について前トランザクションが存在するか確認
-	for _, input := range tx.Inputs {
-		if prevTxs[hex.EncodeToString(input.TxID)] == nil {
-			return false
+// VerifyScripts は Verify と同じ検証を行い、失敗した入力と理由をエラーで返します
+func (tx *Transaction) VerifyScripts(prevTxs map[string]*Transaction) error {
+	if tx.IsCoinbase() {
+		return nil // コインベーストランザクションは常に有効
+	}
+
+	prevOutputs := make([]TxOutput, len(tx.Inputs))
+	for i, input := range tx.Inputs {
+		prevOutput, ok := previousOutput(input, prevTxs)
+		if !ok {
+			return fmt.Errorf("input %d: previous output not found", i)
 		}
+		prevOutputs[i] = prevOutput
 	}
 
-	// トランザクションのコピーを作成
-	txCopy := tx.trimmedCopy()
+	return tx.verifyScriptsWith(prevOutputs)
+}
 
-	// 各入力の署名を検証
+// verifyScriptsWith は入力と同じ順の参照先の出力に対して、各入力の scriptSig を検証します
+func (tx *Transaction) verifyScriptsWith(prevOutputs []TxOutput) error {
 	for i, input := range tx.Inputs {
-		prevTx := prevTxs[hex.EncodeToString(input.TxID)]
-		txCopy.Inputs[i].Signature = nil
-		txCopy.Inputs[i].PubKey = prevTx.Outputs[input.OutIndex].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[i].PubKey = nil
-
-		// 公開鍵を復元
-		pubKey, err := bytesToPublicKey(input.PubKey)
-		if err != nil {
-			return false
+		checker := newTxSigChecker(tx, i, prevOutputs[i])
+		if err := VerifyScript(input.ScriptSig, prevOutputs[i].ScriptPubKey, checker); err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
 		}
+	}
 
-		// 署名を検証
-		if !VerifySignature(pubKey, txCopy.ID, input.Signature) {
-			return false
-		}
+	return nil
+}
+
+// DebugScript はi番目の入力の scriptSig を参照先の出力のロックに対して実行し、命令を1つ実行するごとに hook を呼びます
+// 戻り値は VerifyScripts がその入力について行う検証の結果です
+func (tx *Transaction) DebugScript(i int, prevTxs map[string]*Transaction, hook scriptStepHook) error {
+	if tx.IsCoinbase() {
+		return fmt.Errorf("coinbase transaction has no scripts to run")
 	}
+	if i < 0 || i >= len(tx.Inputs) {
+		return fmt.Errorf("input %d out of range (%d input(s))", i, len(tx.Inputs))
+	}
+	prevOutput, ok := previousOutput(tx.Inputs[i], prevTxs)
+	if !ok {
+		return fmt.Errorf("input %d: previous output not found", i)
+	}
+
+	checker := newTxSigChecker(tx, i, prevOutput)
+	return verifyScript(tx.Inputs[i].ScriptSig, prevOutput.ScriptPubKey, checker, hook)
+}
+
+// txSigChecker はトランザクションのi番目の入力について、スクリプトの署名とロック時刻を検証します
+type txSigChecker struct {
+	tx   *Transaction
+	hash []byte // 署名対象のハッシュ
+}
 
-	return true
+func newTxSigChecker(tx *Transaction, i int, prevOutput TxOutput) txSigChecker {
+	return txSigChecker{tx: tx, hash: tx.sigHash(i, prevOutput)}
+}
+
+// CheckSig は公開鍵で署名を検証します
+func (c txSigChecker) CheckSig(signature, pubKey []byte) bool {
+	key, err := bytesToPublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return VerifySignature(key, c.hash, signature)
+}
+
+// CheckSignatureEncoding は署名が正規形（固定長・low-S）かを検証します
+func (c txSigChecker) CheckSignatureEncoding(signature []byte) error {
+	return common.CheckSignatureEncoding(elliptic.P256(), signature)
+}
+
+// CheckLockTime はトランザクションのロック時刻がスクリプトの要求する高さ以上かを返します
+func (c txSigChecker) CheckLockTime(lockTime int64) bool {
+	return c.tx.LockTime >= lockTime
+}
+
+// previousOutput は入力が参照する前トランザクションの出力を返します
+func previousOutput(input TxInput, prevTxs map[string]*Transaction) (TxOutput, bool) {
+	prevTx := prevTxs[hex.EncodeToString(input.TxID)]
+	if prevTx == nil || input.OutIndex < 0 || input.OutIndex >= len(prevTx.Outputs) {
+		return TxOutput{}, false
+	}
+	return prevTx.Outputs[input.OutIndex], true
+}
+
+// sigHash はi番目の入力の署名対象となるハッシュを計算します
+// scriptSig を除いたコピーの該当入力に、使用する出力の scriptPubKey を入れてハッシュします
+func (tx *Transaction) sigHash(i int, prevOutput TxOutput) []byte {
+	txCopy := tx.trimmedCopy()
+	txCopy.Inputs[i].ScriptSig = prevOutput.ScriptPubKey
+	return common.Hash(txCopy.Serialize())
 }
 
 // trimmedCopy は署名用にトリムされたトランザクションのコピーを返します
@@ -231,23 +356,24 @@ func (tx *Transaction) trimmedCopy() Transaction {
 		inputs = append(inputs, TxInput{
 			TxID:      input.TxID,
 			OutIndex:  input.OutIndex,
-			Signature: nil,
-			PubKey:    nil,
+			ScriptSig: nil,
 		})
 	}
 
 	for _, output := range tx.Outputs {
 		outputs = append(outputs, TxOutput{
-			Value:      output.Value,
-			PubKeyHash: output.PubKeyHash,
+			Value:        output.Value,
+			ScriptPubKey: output.ScriptPubKey,
 		})
 	}
 
 	return Transaction{
 		ID:        tx.ID,
+		Version:   tx.Version,
 		Inputs:    inputs,
 		Outputs:   outputs,
 		Timestamp: tx.Timestamp,
+		LockTime:  tx.LockTime,
 	}
 }
 
@@ -256,16 +382,25 @@ func (tx *Transaction) String() string {
 	var lines []string
 
 	lines = append(lines, fmt.Sprintf("Transaction %s:", hex.EncodeToString(tx.ID)))
+	lines = append(lines, fmt.Sprintf("  Version: %d", tx.Version))
 	lines = append(lines, fmt.Sprintf("  Timestamp: %s", time.Unix(tx.Timestamp, 0).Format("2006-01-02 15:04:05")))
 
 	if tx.IsCoinbase() {
 		lines = append(lines, "  Type: Coinbase (Mining Reward)")
 	}
+	if tx.LockTime > 0 {
+		lines = append(lines, fmt.Sprintf("  LockTime: block %d", tx.LockTime))
+	}
 
 	lines = append(lines, fmt.Sprintf("  Inputs: %d", len(tx.Inputs)))
 	for i, input := range tx.Inputs {
 		if tx.IsCoinbase() {
-			lines = append(lines, fmt.Sprintf("    [%d] Coinbase data: %s", i, string(input.PubKey)))
+			data := tx.CoinbaseData()
+			if data.HasHeight {
+				lines = append(lines, fmt.Sprintf("    [%d] Coinbase height: %d, data: %s", i, data.Height, data.Message))
+			} else {
+				lines = append(lines, fmt.Sprintf("    [%d] Coinbase data: %s", i, data.Message))
+			}
 		} else {
 			lines = append(lines, fmt.Sprintf("    [%d] TxID: %s, OutIndex: %d", i, hex.EncodeToString(input.TxID), input.OutIndex))
 		}
@@ -273,7 +408,8 @@ func (tx *Transaction) String() string {
 
 	lines = append(lines, fmt.Sprintf("  Outputs: %d", len(tx.Outputs)))
 	for i, output := range tx.Outputs {
-		lines = append(lines, fmt.Sprintf("    [%d] Value: %d, To: %s", i, output.Value, hex.EncodeToString(output.PubKeyHash)))
+		lines = append(lines, fmt.Sprintf("    [%d] Value: %d, To: %s", i, output.Value, output.Address()))
+		lines = append(lines, fmt.Sprintf("        Script: %s", output.ScriptPubKey))
 	}
 
 	result := ""
@@ -285,8 +421,9 @@ func (tx *Transaction) String() string {
 }
 
 // publicKeyToBytes は公開鍵をバイト列に変換します
+// XとYを曲線の鍵長に揃えるため、bytesToPublicKey で半分に分割して復元できます
 func publicKeyToBytes(pubKey *ecdsa.PublicKey) []byte {
-	return append(pubKey.X.Bytes(), pubKey.Y.Bytes()...)
+	return common.PublicKeyBytes(pubKey)
 }
 
 // bytesToPublicKey はバイト列から公開鍵を復元します
@@ -1,13 +1,36 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"encoding/hex"
+	"math/big"
 	"testing"
 
+	"github.com/nyasuto/minicoin/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// テストで使う送金先アドレス（対応する秘密鍵は持たない）
+var (
+	testAddressA = common.PubKeyHashToAddress(bytes.Repeat([]byte{0xab}, common.PubKeyHashLen))
+	testAddressB = common.PubKeyHashToAddress(bytes.Repeat([]byte{0xef}, common.PubKeyHashLen))
+)
+
+// newTestChain はテストで共通に使う、送金元のウォレットに50コインを持つチェーンとメモリプールを作成します
+func newTestChain(t *testing.T) (*Wallet, *Blockchain, *UTXOSet, *Mempool) {
+	t.Helper()
+
+	wallet, err := NewWallet()
+	require.NoError(t, err)
+
+	bc := NewBlockchain(1, wallet.GetAddress())
+	utxoSet := NewUTXOSet(bc)
+	return wallet, bc, utxoSet, NewMempool(bc, utxoSet)
+}
+
 func TestNewCoinbaseTx(t *testing.T) {
 	t.Run("コインベーストランザクションの生成", func(t *testing.T) {
 		to := "address123"
@@ -30,7 +53,7 @@ func TestNewCoinbaseTx(t *testing.T) {
 		tx := NewCoinbaseTx(to, "")
 
 		require.NotNil(t, tx)
-		assert.Contains(t, string(tx.Inputs[0].PubKey), "Reward to")
+		assert.Contains(t, string(tx.Inputs[0].ScriptSig), "Reward to")
 	})
 
 	t.Run("異なるアドレスで異なるコインベース", func(t *testing.T) {
@@ -39,6 +62,18 @@ func TestNewCoinbaseTx(t *testing.T) {
 
 		assert.NotEqual(t, tx1.ID, tx2.ID)
 	})
+
+	t.Run("Base58Checkアドレスはデコードした公開鍵ハッシュでロック", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		tx := NewCoinbaseTx(wallet.GetAddress(), "data")
+
+		pubKeyHash, err := common.AddressToPubKeyHash(wallet.GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, NewP2PKHScript(pubKeyHash), tx.Outputs[0].ScriptPubKey)
+		assert.Equal(t, wallet.GetAddress(), tx.Outputs[0].Address())
+	})
 }
 
 func TestIsCoinbase(t *testing.T) {
@@ -58,8 +93,8 @@ func TestIsCoinbase(t *testing.T) {
 			},
 			Outputs: []TxOutput{
 				{
-					Value:      10,
-					PubKeyHash: []byte("address"),
+					Value:        10,
+					ScriptPubKey: NewP2PKHScript([]byte("address")),
 				},
 			},
 		}
@@ -93,10 +128,10 @@ func TestTransactionHash(t *testing.T) {
 	t.Run("同じトランザクションは同じハッシュ", func(t *testing.T) {
 		tx := &Transaction{
 			Inputs: []TxInput{
-				{TxID: []byte{}, OutIndex: -1, PubKey: []byte("data")},
+				{TxID: []byte{}, OutIndex: -1, ScriptSig: Script("data")},
 			},
 			Outputs: []TxOutput{
-				{Value: 50, PubKeyHash: []byte("address")},
+				{Value: 50, ScriptPubKey: NewP2PKHScript([]byte("address"))},
 			},
 			Timestamp: 1234567890,
 		}
@@ -119,7 +154,7 @@ func TestSerialize(t *testing.T) {
 	t.Run("シリアライズが正常に動作", func(t *testing.T) {
 		tx := NewCoinbaseTx("address", "data")
 
-		serialized := tx.serialize()
+		serialized := tx.Serialize()
 
 		assert.NotNil(t, serialized)
 		assert.NotEmpty(t, serialized)
@@ -131,72 +166,12 @@ func TestSerialize(t *testing.T) {
 			Outputs: []TxOutput{},
 		}
 
-		serialized := tx.serialize()
+		serialized := tx.Serialize()
 
 		assert.NotNil(t, serialized)
 	})
 }
 
-func TestNewTransaction(t *testing.T) {
-	t.Run("基本的なトランザクション作成", func(t *testing.T) {
-		from := "abcd1234"
-		to := "ef125678"
-		amount := 10
-
-		tx, err := NewTransaction(from, to, amount, nil)
-
-		require.NoError(t, err)
-		require.NotNil(t, tx)
-		assert.NotNil(t, tx.ID)
-		assert.NotEmpty(t, tx.Outputs)
-		assert.Equal(t, amount, tx.Outputs[0].Value)
-	})
-
-	t.Run("負の金額でエラー", func(t *testing.T) {
-		from := "abcd1234"
-		to := "ef125678"
-		amount := -10
-
-		tx, err := NewTransaction(from, to, amount, nil)
-
-		assert.Error(t, err)
-		assert.Nil(t, tx)
-	})
-
-	t.Run("ゼロ金額でエラー", func(t *testing.T) {
-		from := "abcd1234"
-		to := "ef125678"
-		amount := 0
-
-		tx, err := NewTransaction(from, to, amount, nil)
-
-		assert.Error(t, err)
-		assert.Nil(t, tx)
-	})
-
-	t.Run("無効なfromアドレスでエラー", func(t *testing.T) {
-		from := "invalid-hex-zzz"
-		to := "abcd1234"
-		amount := 10
-
-		tx, err := NewTransaction(from, to, amount, nil)
-
-		assert.Error(t, err)
-		assert.Nil(t, tx)
-	})
-
-	t.Run("無効なtoアドレスでエラー", func(t *testing.T) {
-		from := "abcd1234"
-		to := "invalid-hex-zzz"
-		amount := 10
-
-		tx, err := NewTransaction(from, to, amount, nil)
-
-		assert.Error(t, err)
-		assert.Nil(t, tx)
-	})
-}
-
 func TestSignAndVerify(t *testing.T) {
 	t.Run("トランザクションの署名と検証", func(t *testing.T) {
 		// ウォレット作成
@@ -216,8 +191,8 @@ func TestSignAndVerify(t *testing.T) {
 			},
 			Outputs: []TxOutput{
 				{
-					Value:      25,
-					PubKeyHash: []byte("recipient"),
+					Value:        25,
+					ScriptPubKey: NewP2PKHScript([]byte("recipient")),
 				},
 			},
 		}
@@ -262,7 +237,7 @@ func TestSignAndVerify(t *testing.T) {
 				},
 			},
 			Outputs: []TxOutput{
-				{Value: 10, PubKeyHash: []byte("address")},
+				{Value: 10, ScriptPubKey: NewP2PKHScript([]byte("address"))},
 			},
 		}
 
@@ -289,7 +264,7 @@ func TestSignAndVerify(t *testing.T) {
 				},
 			},
 			Outputs: []TxOutput{
-				{Value: 25, PubKeyHash: []byte("recipient")},
+				{Value: 25, ScriptPubKey: NewP2PKHScript([]byte("recipient"))},
 			},
 		}
 		tx.ID = tx.Hash()
@@ -302,8 +277,8 @@ func TestSignAndVerify(t *testing.T) {
 		err = tx.Sign(wallet, prevTxs)
 		require.NoError(t, err)
 
-		// 署名を改ざん
-		tx.Inputs[0].Signature[0] ^= 0xFF
+		// 署名を改ざん（scriptSig の先頭は署名の長さなので、署名の2バイト目を書き換える）
+		tx.Inputs[0].ScriptSig[2] ^= 0xFF
 
 		// 検証
 		valid := tx.Verify(prevTxs)
@@ -330,7 +305,7 @@ func TestSignAndVerify(t *testing.T) {
 				},
 			},
 			Outputs: []TxOutput{
-				{Value: 25, PubKeyHash: []byte("recipient")},
+				{Value: 25, ScriptPubKey: NewP2PKHScript([]byte("recipient"))},
 			},
 		}
 		tx.ID = tx.Hash()
@@ -339,13 +314,69 @@ func TestSignAndVerify(t *testing.T) {
 			hex.EncodeToString(prevTx.ID): prevTx,
 		}
 
-		// wallet2で署名
+		// wallet2は出力のロックを解除できないので署名しない
 		err = tx.Sign(wallet2, prevTxs)
+		assert.Error(t, err)
+		assert.Nil(t, tx.Inputs[0].ScriptSig)
+
+		// wallet2の署名と公開鍵を入れてもロックスクリプトの公開鍵ハッシュと一致しない
+		signature, err := wallet2.Sign(tx.sigHash(0, prevTx.Outputs[0]))
+		require.NoError(t, err)
+		tx.Inputs[0].ScriptSig = Script{}.AddData(signature).AddData(publicKeyToBytes(wallet2.PublicKey))
+		assert.False(t, tx.Verify(prevTxs))
+	})
+}
+
+func TestCanonicalSignatures(t *testing.T) {
+	// signedTx はウォレットで署名したトランザクションと、その署名・公開鍵を返す
+	signedTx := func(t *testing.T) (*Transaction, map[string]*Transaction, []byte, []byte) {
+		wallet, err := NewWallet()
 		require.NoError(t, err)
+		prevTx := NewCoinbaseTx(wallet.Address, "prev tx")
+		tx := &Transaction{
+			Version: CurrentTxVersion,
+			Inputs:  []TxInput{{TxID: prevTx.ID, OutIndex: 0}},
+			Outputs: []TxOutput{{Value: 25, ScriptPubKey: NewP2PKHScript([]byte("recipient"))}},
+		}
+		tx.ID = tx.Hash()
+		prevTxs := map[string]*Transaction{hex.EncodeToString(prevTx.ID): prevTx}
+		require.NoError(t, tx.Sign(wallet, prevTxs))
+
+		pushes, err := tx.Inputs[0].ScriptSig.pushes()
+		require.NoError(t, err)
+		return tx, prevTxs, pushes[0], pushes[1]
+	}
+
+	t.Run("署名はlow-Sの正規形", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			tx, prevTxs, signature, _ := signedTx(t)
+			assert.NoError(t, common.CheckSignatureEncoding(elliptic.P256(), signature))
+			assert.NoError(t, tx.VerifyScripts(prevTxs))
+		}
+	})
+
+	t.Run("sを書き換えた署名は検証を通らずIDも変わらない", func(t *testing.T) {
+		tx, prevTxs, signature, pubKey := signedTx(t)
+		id := tx.ID
+
+		n := elliptic.P256().Params().N
+		highS := new(big.Int).Sub(n, new(big.Int).SetBytes(signature[32:]))
+		mutated := append(append([]byte{}, signature[:32]...), highS.FillBytes(make([]byte, 32))...)
+		tx.Inputs[0].ScriptSig = Script{}.AddData(mutated).AddData(pubKey)
+
+		err := tx.VerifyScripts(prevTxs)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, common.ErrSignatureHighS)
+		assert.Contains(t, err.Error(), "non-canonical signature")
+		assert.Equal(t, id, tx.Hash())
+	})
+
+	t.Run("先頭に0を足した署名は検証を通らない", func(t *testing.T) {
+		tx, prevTxs, signature, pubKey := signedTx(t)
+		padded := append([]byte{0}, signature...)
+		tx.Inputs[0].ScriptSig = Script{}.AddData(padded).AddData(pubKey)
 
-		// 検証（wallet1の公開鍵ハッシュと一致しないため失敗する可能性）
-		// ただし、署名自体は有効なので、このテストは署名が作られたことを確認
-		assert.NotNil(t, tx.Inputs[0].Signature)
+		assert.ErrorIs(t, tx.VerifyScripts(prevTxs), common.ErrSignatureLength)
 	})
 }
 
@@ -373,8 +404,8 @@ func TestTransactionString(t *testing.T) {
 			},
 			Outputs: []TxOutput{
 				{
-					Value:      10,
-					PubKeyHash: []byte("address"),
+					Value:        10,
+					ScriptPubKey: NewP2PKHScript([]byte("address")),
 				},
 			},
 			Timestamp: 1234567890,
@@ -408,6 +439,18 @@ func TestPublicKeyConversion(t *testing.T) {
 		assert.Equal(t, wallet.PublicKey.Y, restoredPubKey.Y)
 	})
 
+	t.Run("座標の先頭バイトが0でも復元できる", func(t *testing.T) {
+		pubKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: big.NewInt(1), Y: big.NewInt(0xffff)}
+
+		pubKeyBytes := publicKeyToBytes(pubKey)
+		assert.Len(t, pubKeyBytes, 64)
+
+		restoredPubKey, err := bytesToPublicKey(pubKeyBytes)
+		require.NoError(t, err)
+		assert.Equal(t, pubKey.X, restoredPubKey.X)
+		assert.Equal(t, pubKey.Y, restoredPubKey.Y)
+	})
+
 	t.Run("空のバイト列でエラー", func(t *testing.T) {
 		_, err := bytesToPublicKey([]byte{})
 
@@ -429,14 +472,13 @@ func TestTrimmedCopy(t *testing.T) {
 				{
 					TxID:      []byte("prev-tx"),
 					OutIndex:  0,
-					Signature: []byte("signature"),
-					PubKey:    []byte("pubkey"),
+					ScriptSig: Script{}.AddData([]byte("signature")).AddData([]byte("pubkey")),
 				},
 			},
 			Outputs: []TxOutput{
 				{
-					Value:      10,
-					PubKeyHash: []byte("address"),
+					Value:        10,
+					ScriptPubKey: NewP2PKHScript([]byte("address")),
 				},
 			},
 			Timestamp: 1234567890,
@@ -449,12 +491,218 @@ func TestTrimmedCopy(t *testing.T) {
 		assert.Equal(t, len(tx.Inputs), len(txCopy.Inputs))
 		assert.Equal(t, len(tx.Outputs), len(txCopy.Outputs))
 
-		// 署名と公開鍵はnilであるべき
-		assert.Nil(t, txCopy.Inputs[0].Signature)
-		assert.Nil(t, txCopy.Inputs[0].PubKey)
+		// scriptSig はnilであるべき
+		assert.Nil(t, txCopy.Inputs[0].ScriptSig)
 
 		// TxIDとOutIndexは保持されているべき
 		assert.Equal(t, tx.Inputs[0].TxID, txCopy.Inputs[0].TxID)
 		assert.Equal(t, tx.Inputs[0].OutIndex, txCopy.Inputs[0].OutIndex)
 	})
 }
+
+func TestNewTransaction(t *testing.T) {
+	t.Run("UTXOを選んでおつり付きのトランザクションを作成", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+		recipient, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		tx, err := NewTransaction(sender, recipient.GetAddress(), 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		require.Len(t, tx.Inputs, 1)
+		assert.Equal(t, bc.Blocks[0].Transactions[0].ID, tx.Inputs[0].TxID)
+		require.Len(t, tx.Outputs, 2)
+		assert.Equal(t, 20, tx.Outputs[0].Value)
+		assert.Equal(t, recipient.GetAddress(), tx.Outputs[0].Address())
+		assert.Equal(t, 30, tx.Outputs[1].Value)
+		assert.Equal(t, sender.GetAddress(), tx.Outputs[1].Address())
+		assert.True(t, bc.VerifyTransaction(tx))
+	})
+
+	t.Run("旧形式のアドレスのウォレットからも送金できる", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+		sender.Address = common.LegacyPublicKeyToAddress(sender.PublicKey)
+		recipient, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		tx, err := NewTransaction(sender, recipient.GetAddress(), 20, 0, utxoSet, bc)
+		require.NoError(t, err)
+		assert.True(t, bc.VerifyTransaction(tx))
+
+		// おつりは旧形式のアドレスと同じハッシュでロックされる
+		require.Len(t, tx.Outputs, 2)
+		legacyHash, err := hex.DecodeString(sender.GetAddress())
+		require.NoError(t, err)
+		assert.Equal(t, NewLegacyP2PKHScript(legacyHash), tx.Outputs[1].ScriptPubKey)
+	})
+
+	t.Run("残高ちょうどならおつりの出力はない", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		tx, err := NewTransaction(sender, testAddressA, 50, 0, utxoSet, bc)
+		require.NoError(t, err)
+		assert.Len(t, tx.Outputs, 1)
+	})
+
+	t.Run("残高不足でエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		tx, err := NewTransaction(sender, testAddressA, 51, 0, utxoSet, bc)
+		assert.Error(t, err)
+		assert.Nil(t, tx)
+	})
+
+	t.Run("複数のUTXOを組み合わせて送金", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(sender.GetAddress(), "second reward")})
+		require.NoError(t, err)
+		utxoSet := NewUTXOSet(bc)
+		require.Equal(t, 100, utxoSet.GetBalance(sender.GetAddress()))
+
+		tx, err := NewTransaction(sender, testAddressA, 80, 0, utxoSet, bc)
+		require.NoError(t, err)
+
+		assert.Len(t, tx.Inputs, 2)
+		require.Len(t, tx.Outputs, 2)
+		assert.Equal(t, 20, tx.Outputs[1].Value)
+		assert.True(t, bc.VerifyTransaction(tx))
+	})
+
+	t.Run("負の金額でエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		tx, err := NewTransaction(sender, testAddressA, -10, 0, NewUTXOSet(bc), bc)
+
+		assert.Error(t, err)
+		assert.Nil(t, tx)
+	})
+
+	t.Run("ゼロ金額でエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		tx, err := NewTransaction(sender, testAddressA, 0, 0, NewUTXOSet(bc), bc)
+
+		assert.Error(t, err)
+		assert.Nil(t, tx)
+	})
+
+	t.Run("無効なfromアドレスでエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+		sender.Address = "invalid-hex-zzz"
+
+		tx, err := NewTransaction(sender, testAddressA, 10, 0, utxoSet, bc)
+
+		assert.Error(t, err)
+		assert.Nil(t, tx)
+	})
+
+	t.Run("無効なtoアドレスでエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		tx, err := NewTransaction(sender, "invalid-hex-zzz", 10, 0, NewUTXOSet(bc), bc)
+
+		assert.Error(t, err)
+		assert.Nil(t, tx)
+	})
+}
+
+func TestTransactionFee(t *testing.T) {
+	t.Run("手数料を差し引いたおつりを作る", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		tx, err := NewTransaction(sender, testAddressA, 20, 5, NewUTXOSet(bc), bc)
+		require.NoError(t, err)
+
+		require.Len(t, tx.Outputs, 2)
+		assert.Equal(t, 25, tx.Outputs[1].Value)
+
+		fee, err := bc.TransactionFee(tx)
+		require.NoError(t, err)
+		assert.Equal(t, 5, fee)
+	})
+
+	t.Run("送金額と手数料の合計が残高を超えるとエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		_, err = NewTransaction(sender, testAddressA, 50, 1, NewUTXOSet(bc), bc)
+		assert.Error(t, err)
+	})
+
+	t.Run("チェックサムが合わない送金先アドレスでエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		// 最後の文字を変えてチェックサムを壊す
+		to := testAddressA[:len(testAddressA)-1] + "1"
+		if to == testAddressA {
+			to = testAddressA[:len(testAddressA)-1] + "2"
+		}
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		_, err = NewTransaction(sender, to, 10, 0, NewUTXOSet(bc), bc)
+		assert.ErrorIs(t, err, common.ErrChecksumMismatch)
+
+		var addrErr *common.AddressError
+		require.ErrorAs(t, err, &addrErr)
+		assert.Equal(t, to, addrErr.Address)
+	})
+
+	t.Run("負の手数料でエラー", func(t *testing.T) {
+		sender, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, sender.GetAddress())
+		_, err = NewTransaction(sender, testAddressA, 10, -1, NewUTXOSet(bc), bc)
+		assert.Error(t, err)
+	})
+
+	t.Run("コインベースの手数料は0", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, wallet.GetAddress())
+		fee, err := bc.TransactionFee(bc.Blocks[0].Transactions[0])
+		require.NoError(t, err)
+		assert.Equal(t, 0, fee)
+	})
+
+	t.Run("コインベースは指定した報酬を受け取る", func(t *testing.T) {
+		tx := NewCoinbaseTxWithReward("address", "data", 57)
+
+		assert.True(t, tx.IsCoinbase())
+		assert.Equal(t, 57, tx.Outputs[0].Value)
+	})
+}
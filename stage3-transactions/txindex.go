@@ -0,0 +1,152 @@
+// Package main implements the transaction index for Stage 3.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+)
+
+// TxLocation はトランザクションがチェーンのどこにあるか（ブロックの高さとブロック内の位置）です
+type TxLocation struct {
+	Height int64
+	Offset int
+}
+
+// indexBlockLocked はブロックのトランザクションをインデックスに追加します
+// 呼び出し側でロックを取得していることを前提とします
+func (bc *Blockchain) indexBlockLocked(block *Block) {
+	if bc.txIndex == nil {
+		bc.txIndex = make(map[string]TxLocation)
+	}
+	for offset, tx := range block.Transactions {
+		bc.txIndex[hex.EncodeToString(tx.ID)] = TxLocation{Height: block.Index, Offset: offset}
+	}
+}
+
+// ReindexTransactions はすべてのブロックからトランザクションインデックスを作り直し、保存先があれば書き直します
+// 戻り値はインデックスしたトランザクションの数です
+func (bc *Blockchain) ReindexTransactions() (int, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	bc.txIndex = make(map[string]TxLocation)
+	for _, block := range bc.Blocks {
+		bc.indexBlockLocked(block)
+	}
+
+	if bc.store != nil {
+		if err := bc.store.ReplaceTxIndex(bc.txIndex, bc.Blocks[len(bc.Blocks)-1].Hash); err != nil {
+			return 0, fmt.Errorf("failed to persist txindex: %w", err)
+		}
+	}
+	return len(bc.txIndex), nil
+}
+
+// LocateTransaction はトランザクションの位置を返します（インデックスになければ false）
+func (bc *Blockchain) LocateTransaction(txID []byte) (TxLocation, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	location, ok := bc.txIndex[hex.EncodeToString(txID)]
+	return location, ok
+}
+
+// findTransactionLocked はトランザクションを検索します
+// インデックスがあれば位置から直接取り出し、ない（ブロックから直接組み立てたチェーン）ときはすべてのブロックを調べます
+// 呼び出し側でロックを取得していることを前提とします
+func (bc *Blockchain) findTransactionLocked(txID []byte) (*Transaction, bool) {
+	if bc.txIndex == nil {
+		for _, block := range bc.Blocks {
+			for _, tx := range block.Transactions {
+				if bytes.Equal(tx.ID, txID) {
+					return tx, true
+				}
+			}
+		}
+		return nil, false
+	}
+
+	location, ok := bc.txIndex[hex.EncodeToString(txID)]
+	if !ok || location.Height < 0 || location.Height >= int64(len(bc.Blocks)) {
+		return nil, false
+	}
+	transactions := bc.Blocks[location.Height].Transactions
+	if location.Offset < 0 || location.Offset >= len(transactions) || !bytes.Equal(transactions[location.Offset].ID, txID) {
+		return nil, false
+	}
+	return transactions[location.Offset], true
+}
+
+// transactionCount はチェーン内のトランザクションの数を返します
+func (bc *Blockchain) transactionCount() int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	count := 0
+	for _, block := range bc.Blocks {
+		count += len(block.Transactions)
+	}
+	return count
+}
+
+// runTxIndexCommand は txindex サブコマンドを実行します
+// chain.db のトランザクションインデックスを作り直したり、トランザクションの位置を引いたりします
+func runTxIndexCommand(args []string) int {
+	fs := flag.NewFlagSet("txindex", flag.ContinueOnError)
+	rebuildFlag := fs.Bool("rebuild", false, "すべてのブロックからインデックスを作り直す")
+	txFlag := fs.String("tx", "", "位置を調べるトランザクションID（16進数）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(blocks) == 0 {
+		fmt.Printf("❌ No blocks in %s\n", chainFile)
+		return 1
+	}
+
+	bc, err := OpenBlockchain(store, 2, "")
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if *rebuildFlag {
+		count, err := bc.ReindexTransactions()
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		fmt.Printf("✅ Rebuilt the txindex: %d transaction(s) in %d block(s)\n", count, bc.GetChainLength())
+	} else {
+		fmt.Printf("🗂️  txindex: %d transaction(s) in %d block(s)\n", len(bc.txIndex), bc.GetChainLength())
+	}
+
+	if *txFlag == "" {
+		return 0
+	}
+	txID, err := hex.DecodeString(*txFlag)
+	if err != nil {
+		fmt.Printf("❌ Invalid transaction ID: %v\n", err)
+		return 2
+	}
+	location, ok := bc.LocateTransaction(txID)
+	if !ok {
+		fmt.Printf("❌ Transaction %s not found\n", truncateHash(*txFlag))
+		return 1
+	}
+	fmt.Printf("📍 %s: block #%d, transaction %d\n", truncateHash(*txFlag), location.Height, location.Offset)
+	return 0
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxIndex(t *testing.T) {
+	// 送金を2回含むチェーン
+	wallet, bc, _, mempool := newTestChain(t)
+	var blocks []*Block
+	for _, amount := range []int{5, 7} {
+		block, _, err := SendCoins(mempool, wallet, testAddressA, amount, 1)
+		require.NoError(t, err)
+		blocks = append(blocks, block)
+	}
+
+	t.Run("マイニングしたブロックのトランザクションを位置から引ける", func(t *testing.T) {
+		payment := blocks[1].Transactions[1]
+
+		location, ok := bc.LocateTransaction(payment.ID)
+		require.True(t, ok)
+		assert.Equal(t, TxLocation{Height: 2, Offset: 1}, location)
+
+		found, err := bc.FindTransaction(payment.ID)
+		require.NoError(t, err)
+		assert.Same(t, payment, found)
+
+		_, err = bc.FindTransaction([]byte("missing"))
+		assert.Error(t, err)
+	})
+
+	t.Run("ブロックから直接組み立てたチェーンはすべてのブロックを調べる", func(t *testing.T) {
+		node := &Blockchain{Blocks: bc.Blocks}
+
+		found, err := node.FindTransaction(blocks[0].Transactions[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, blocks[0].Transactions[1].ID, found.ID)
+	})
+
+	t.Run("作り直すとすべてのトランザクションが入る", func(t *testing.T) {
+		bc.txIndex = map[string]TxLocation{}
+
+		count, err := bc.ReindexTransactions()
+		require.NoError(t, err)
+		assert.Equal(t, bc.transactionCount(), count)
+		for _, tx := range bc.GetAllTransactions() {
+			_, err := bc.FindTransaction(tx.ID)
+			assert.NoError(t, err)
+		}
+	})
+}
+
+func TestChainStoreTxIndex(t *testing.T) {
+	t.Run("保存したインデックスを再び開いて使う", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		block, _, err := SendCoins(NewMempool(bc, utxoSet), wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		reopened, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		_, _, err = OpenUTXOSet(store, reopened)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+
+		index, err := store.LoadTxIndex()
+		require.NoError(t, err)
+		assert.Len(t, index, reopened.transactionCount())
+
+		location, ok := reopened.LocateTransaction(block.Transactions[1].ID)
+		require.True(t, ok)
+		assert.Equal(t, TxLocation{Height: 1, Offset: 1}, location)
+	})
+
+	t.Run("足りないインデックスは開くときに作り直す", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "chain.db")
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		store, err := OpenChainStore(path)
+		require.NoError(t, err)
+		bc, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		utxoSet, _, err := OpenUTXOSet(store, bc)
+		require.NoError(t, err)
+		_, _, err = SendCoins(NewMempool(bc, utxoSet), wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.ReplaceTxIndex(map[string]TxLocation{}, bc.GetLatestBlock().Hash))
+		require.NoError(t, store.Close())
+
+		store, err = OpenChainStore(path)
+		require.NoError(t, err)
+		reopened, err := OpenBlockchain(store, 1, wallet.GetAddress())
+		require.NoError(t, err)
+		_, _, err = OpenUTXOSet(store, reopened)
+		require.NoError(t, err)
+		defer func() { _ = store.Close() }()
+
+		index, err := store.LoadTxIndex()
+		require.NoError(t, err)
+		assert.Len(t, index, reopened.transactionCount())
+		assert.True(t, reopened.IsValid())
+	})
+}
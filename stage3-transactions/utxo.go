@@ -3,9 +3,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+
+	"github.com/nyasuto/minicoin/common"
 )
 
 // UTXO represents an unspent transaction output
@@ -15,26 +20,199 @@ type UTXO struct {
 	Output   TxOutput // 出力データ
 }
 
+// BlockUndo はブロックを取り消すためのデータで、トランザクションごとに使用した出力を入力の順に保持します
+// コインベースの分は空です
+type BlockUndo struct {
+	Spent [][]UTXO
+}
+
+// maxUndoBlocks は保存先のないUTXOセットが取り消し用データを持っておく最新ブロックの数です
+// これより深い巻き戻しには再構築（Reindex）が必要です。保存先があれば取り消し用データは保存先から読みます
+const maxUndoBlocks = 100
+
+// blockUndoEntry はブロックハッシュと取り消し用データの組です
+type blockUndoEntry struct {
+	hash string
+	undo BlockUndo
+}
+
 // UTXOSet はUTXO集合を管理します
+// UTXOはアウトポイント（txid:index）をキーに持ち、アドレスごとの索引から引きます
+// 出力の追加も使用済みの出力の削除も、UTXOセットの大きさによらず一定の時間で済みます
 type UTXOSet struct {
-	UTXOs map[string][]UTXO // address -> UTXOs
-	mutex sync.RWMutex
+	UTXOs     map[string]UTXO             // アウトポイント（outpointKey） -> UTXO
+	byAddress map[string]map[string]int64 // 受取先（outputIndexKey） -> アウトポイント -> 追加した順番
+	owners    map[string]string           // アウトポイント -> 受取先（削除のたびにスクリプトを解析し直さないため）
+	sequence  int64                       // 次に追加するUTXOの順番
+	tip       string                      // 反映している最新ブロックのハッシュ
+	undo      []blockUndoEntry            // 最新の maxUndoBlocks ブロックの取り消し用データ（古い順。保存先があれば持たない）
+	store     *ChainStore                 // 保存先（nilならメモリ上のみ）
+	accounts  *AccountView                // 連動するアカウントの表（nilなら持たない）
+	tokens    *TokenIndex                 // 出力に付いたトークンのタグ
+	mutex     sync.RWMutex
 }
 
-// NewUTXOSet はブロックチェーンからUTXO集合を生成します
-func NewUTXOSet(blockchain *Blockchain) *UTXOSet {
-	us := &UTXOSet{
-		UTXOs: make(map[string][]UTXO),
+// newEmptyUTXOSet は空のUTXOセットを作成します
+func newEmptyUTXOSet(store *ChainStore) *UTXOSet {
+	return &UTXOSet{
+		UTXOs:     make(map[string]UTXO),
+		byAddress: make(map[string]map[string]int64),
+		owners:    make(map[string]string),
+		store:     store,
+		tokens:    &TokenIndex{},
 	}
+}
 
+// NewUTXOSet はブロックチェーンからUTXO集合を生成します
+func NewUTXOSet(blockchain *Blockchain) *UTXOSet {
+	us := newEmptyUTXOSet(nil)
 	if err := us.Reindex(blockchain); err != nil {
 		// 初期化時のエラーは通常発生しないが、念のため空のセットを返す
-		return &UTXOSet{UTXOs: make(map[string][]UTXO)}
+		return newEmptyUTXOSet(nil)
 	}
 
 	return us
 }
 
+// OpenUTXOSet は保存されたUTXOセットを読み込みます
+// 保存されたセットがチェーンの最新ブロックを反映していなければ、チェーンから再構築して保存し直します
+// 戻り値の bool は再構築したかどうかです
+func OpenUTXOSet(store *ChainStore, blockchain *Blockchain) (*UTXOSet, bool, error) {
+	us := newEmptyUTXOSet(store)
+
+	tip, err := store.UTXOTip()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read utxo tip: %w", err)
+	}
+	if tip == "" || tip != blockchain.GetLatestBlock().Hash {
+		if err := us.Reindex(blockchain); err != nil {
+			return nil, false, err
+		}
+		return us, true, nil
+	}
+
+	utxos, err := store.LoadUTXOs()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load utxo set: %w", err)
+	}
+	for _, utxo := range utxos {
+		us.addLocked(utxo)
+	}
+	us.tip = tip
+	// トークンのタグは保存していないため、チェーンから作る
+	us.tokens.Rebuild(blockchain)
+	return us, false, nil
+}
+
+// utxoKey はアドレスを比較するためのBase58Check形式のアドレスを返します
+// 旧形式（16進数）のアドレスでも同じ公開鍵ハッシュのUTXOを引けるようにします（addressIndexKey もこれを通します）
+func utxoKey(address string) string {
+	if common.IsScriptAddress(address) {
+		return address
+	}
+	normalized, err := common.NormalizeAddress(address)
+	if err != nil {
+		// アドレスとして解釈できない文字列はNewCoinbaseTxと同じくバイト列をそのまま公開鍵ハッシュとみなす
+		return common.PubKeyHashToAddress([]byte(address))
+	}
+	return normalized
+}
+
+// addLocked はUTXOを追加します（呼び出し側でロックを取得していることを前提とします）
+func (us *UTXOSet) addLocked(utxo UTXO) {
+	key := outpointKey(utxo.TxID, utxo.OutIndex)
+	address := outputIndexKey(utxo.Output)
+	if _, ok := us.UTXOs[key]; ok {
+		us.removeLocked(utxo.TxID, utxo.OutIndex)
+	}
+	us.UTXOs[key] = utxo
+	us.owners[key] = address
+
+	outpoints, ok := us.byAddress[address]
+	if !ok {
+		outpoints = make(map[string]int64)
+		us.byAddress[address] = outpoints
+	}
+	outpoints[key] = us.sequence
+	us.sequence++
+}
+
+// removeLocked はUTXOを削除して返します（呼び出し側でロックを取得していることを前提とします）
+func (us *UTXOSet) removeLocked(txID []byte, outIndex int) (UTXO, bool) {
+	key := outpointKey(txID, outIndex)
+	utxo, ok := us.UTXOs[key]
+	if !ok {
+		return UTXO{}, false
+	}
+	address := us.owners[key]
+	delete(us.UTXOs, key)
+	delete(us.owners, key)
+	delete(us.byAddress[address], key)
+	if len(us.byAddress[address]) == 0 {
+		delete(us.byAddress, address)
+	}
+	return utxo, true
+}
+
+// addressUTXOsLocked は受取先（outputIndexKey）のUTXOを追加した順に返します（呼び出し側でロックを取得していることを前提とします）
+// 送金で使うUTXOの選び方（in-order など）が毎回同じ結果になるよう、順番をそろえます
+func (us *UTXOSet) addressUTXOsLocked(address string) []UTXO {
+	outpoints := us.byAddress[address]
+	keys := make([]string, 0, len(outpoints))
+	for key := range outpoints {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return outpoints[keys[i]] < outpoints[keys[j]] })
+
+	utxos := make([]UTXO, len(keys))
+	for i, key := range keys {
+		utxos[i] = us.UTXOs[key]
+	}
+	return utxos
+}
+
+// outputIndexKey は出力の受取先を、アドレスの索引のキー（種類を表す1文字とハッシュ）にします
+// UTXOを追加するたびに Base58Check のアドレスを作らずに済むよう、ハッシュのまま索引を引きます。標準の形式でなければ空文字列です
+func outputIndexKey(output TxOutput) string {
+	switch class, hash := output.ScriptPubKey.classify(); class {
+	case p2pkhScript, legacyP2PKHScript:
+		return "k" + string(hash)
+	case p2shScript:
+		return "s" + string(hash)
+	default:
+		return ""
+	}
+}
+
+// addressIndexKey はアドレス（旧形式（16進数）やP2SHのアドレスを含む）を outputIndexKey と同じ形式のキーにします
+func addressIndexKey(address string) string {
+	key := utxoKey(address)
+	if common.IsScriptAddress(key) {
+		hash, err := common.AddressToScriptHash(key)
+		if err != nil {
+			return ""
+		}
+		return "s" + string(hash)
+	}
+	hash, err := common.AddressToPubKeyHash(key)
+	if err != nil {
+		return ""
+	}
+	return "k" + string(hash)
+}
+
+// indexKeyAddress はアドレスの索引のキーを Base58Check のアドレスに戻します（表示用）
+func indexKeyAddress(key string) string {
+	switch {
+	case strings.HasPrefix(key, "k"):
+		return common.PubKeyHashToAddress([]byte(key[1:]))
+	case strings.HasPrefix(key, "s"):
+		return common.ScriptHashToAddress([]byte(key[1:]))
+	default:
+		return ""
+	}
+}
+
 // FindSpendableOutputs は指定金額を満たす使用可能な出力を検索します
 // 戻り値: (実際の合計額, トランザクションID -> 出力インデックスのマップ)
 func (us *UTXOSet) FindSpendableOutputs(address string, amount int) (int, map[string][]int) {
@@ -44,8 +222,11 @@ func (us *UTXOSet) FindSpendableOutputs(address string, amount int) (int, map[st
 	unspentOutputs := make(map[string][]int)
 	accumulated := 0
 
-	utxos := us.UTXOs[address]
-	for _, utxo := range utxos {
+	for _, utxo := range us.addressUTXOsLocked(addressIndexKey(address)) {
+		if _, tagged := us.tokens.Tag(utxo.TxID, utxo.OutIndex); tagged {
+			continue
+		}
+
 		txID := hex.EncodeToString(utxo.TxID)
 		unspentOutputs[txID] = append(unspentOutputs[txID], utxo.OutIndex)
 		accumulated += utxo.Output.Value
@@ -58,13 +239,32 @@ func (us *UTXOSet) FindSpendableOutputs(address string, amount int) (int, map[st
 	return accumulated, unspentOutputs
 }
 
+// FindOutput は指定した出力（トランザクションIDと出力インデックス）が未使用なら返します
+func (us *UTXOSet) FindOutput(txID []byte, outIndex int) (TxOutput, bool) {
+	us.mutex.RLock()
+	defer us.mutex.RUnlock()
+
+	utxo, ok := us.UTXOs[outpointKey(txID, outIndex)]
+	return utxo.Output, ok
+}
+
 // FindUTXO は指定アドレスのすべてのUTXOを取得します
 func (us *UTXOSet) FindUTXO(address string) []UTXO {
 	us.mutex.RLock()
 	defer us.mutex.RUnlock()
 
-	utxos := make([]UTXO, len(us.UTXOs[address]))
-	copy(utxos, us.UTXOs[address])
+	return us.addressUTXOsLocked(addressIndexKey(address))
+}
+
+// SpendableUTXOs は送金に使えるUTXO（指定アドレスのUTXOのうち、トークンを載せていないもの）を返します
+// トークンを載せたUTXOを通常の送金で使うとトークンが失われるため、トークンの送金（NewTokenTransfer）でだけ使います
+func (us *UTXOSet) SpendableUTXOs(address string) []UTXO {
+	var utxos []UTXO
+	for _, utxo := range us.FindUTXO(address) {
+		if _, tagged := us.tokens.Tag(utxo.TxID, utxo.OutIndex); !tagged {
+			utxos = append(utxos, utxo)
+		}
+	}
 	return utxos
 }
 
@@ -74,100 +274,225 @@ func (us *UTXOSet) GetBalance(address string) int {
 	defer us.mutex.RUnlock()
 
 	balance := 0
-	for _, utxo := range us.UTXOs[address] {
-		balance += utxo.Output.Value
+	for key := range us.byAddress[addressIndexKey(address)] {
+		balance += us.UTXOs[key].Output.Value
 	}
 
 	return balance
 }
 
 // Update はブロック追加時にUTXOセットを更新します
+// 使用した出力はブロックの取り消し用データとして記録します
 func (us *UTXOSet) Update(block *Block) error {
 	us.mutex.Lock()
 	defer us.mutex.Unlock()
 
-	// まず、使用された出力（inputs）を削除
-	for _, tx := range block.Transactions {
+	undo := BlockUndo{Spent: make([][]UTXO, len(block.Transactions))}
+
+	for i, tx := range block.Transactions {
+		// 使用された出力（inputs）をアウトポイントで引いて削除
 		if !tx.IsCoinbase() {
 			for _, input := range tx.Inputs {
-				txID := hex.EncodeToString(input.TxID)
-
-				// すべてのアドレスのUTXOから該当する出力を削除
-				for address := range us.UTXOs {
-					newUTXOs := []UTXO{}
-					for _, utxo := range us.UTXOs[address] {
-						if hex.EncodeToString(utxo.TxID) != txID || utxo.OutIndex != input.OutIndex {
-							newUTXOs = append(newUTXOs, utxo)
-						}
-					}
-					us.UTXOs[address] = newUTXOs
+				if utxo, ok := us.removeLocked(input.TxID, input.OutIndex); ok {
+					undo.Spent[i] = append(undo.Spent[i], utxo)
 				}
 			}
 		}
 
 		// 新しい出力（outputs）を追加
 		for outIdx, output := range tx.Outputs {
-			address := hex.EncodeToString(output.PubKeyHash)
-			utxo := UTXO{
-				TxID:     tx.ID,
-				OutIndex: outIdx,
-				Output:   output,
-			}
-			us.UTXOs[address] = append(us.UTXOs[address], utxo)
+			us.addLocked(UTXO{TxID: tx.ID, OutIndex: outIdx, Output: output})
+		}
+	}
+
+	us.tip = block.Hash
+	if us.store == nil {
+		us.undo = append(us.undo, blockUndoEntry{hash: block.Hash, undo: undo})
+		if len(us.undo) > maxUndoBlocks {
+			us.undo = us.undo[len(us.undo)-maxUndoBlocks:]
+		}
+	}
+	us.tokens.ConnectBlock(block)
+	if us.accounts != nil {
+		us.accounts.ConnectBlock(block, undo)
+	}
+
+	// 保存先には差分だけを書き込む
+	if us.store != nil {
+		if err := us.store.ConnectBlock(block, undo); err != nil {
+			return fmt.Errorf("failed to persist utxo set: %w", err)
 		}
 	}
 
 	return nil
 }
 
-// Reindex はブロックチェーン全体からUTXOセットを再構築します
-func (us *UTXOSet) Reindex(blockchain *Blockchain) error {
+// Disconnect は最新のブロックをUTXOセットから取り消します
+// ブロックが作った出力を削除し、使用した出力を取り消し用データから戻します。再構築せずにチェーンの先端を巻き戻せます
+// 取り消せるのはUTXOセットが反映している最新のブロックだけです
+func (us *UTXOSet) Disconnect(block *Block) error {
 	us.mutex.Lock()
 	defer us.mutex.Unlock()
 
-	// UTXOセットをクリア
-	us.UTXOs = make(map[string][]UTXO)
+	if block.Hash != us.tip {
+		return fmt.Errorf("block %s is not the utxo set tip %s", truncateHash(block.Hash), truncateHash(us.tip))
+	}
 
-	// 使用済み出力を追跡
-	spentTXOs := make(map[string]map[int]bool)
+	var undo BlockUndo
+	ok := false
+	if us.store != nil {
+		var err error
+		if undo, ok, err = us.store.LoadUndo(block.Hash); err != nil {
+			return fmt.Errorf("failed to load undo data: %w", err)
+		}
+	} else if n := len(us.undo); n > 0 && us.undo[n-1].hash == block.Hash {
+		undo, ok = us.undo[n-1].undo, true
+	}
+	if !ok {
+		return fmt.Errorf("no undo data for block %s", truncateHash(block.Hash))
+	}
+	if len(undo.Spent) != len(block.Transactions) {
+		return fmt.Errorf("undo data for block %s has %d transactions, block has %d", truncateHash(block.Hash), len(undo.Spent), len(block.Transactions))
+	}
 
-	// ブロックチェーンを逆順に走査（最新ブロックから）
-	for i := len(blockchain.Blocks) - 1; i >= 0; i-- {
-		block := blockchain.Blocks[i]
+	// ブロック内で作られてすぐ使われた出力もあるため、トランザクションを逆順に取り消す
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		tx := block.Transactions[i]
+		for outIdx := range tx.Outputs {
+			us.removeLocked(tx.ID, outIdx)
+		}
 
-		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
+		for _, utxo := range undo.Spent[i] {
+			us.addLocked(utxo)
+		}
+	}
 
-			// 出力を処理
-			for outIdx, output := range tx.Outputs {
-				// すでに使用済みの出力はスキップ
-				if spentTXOs[txID] != nil && spentTXOs[txID][outIdx] {
-					continue
-				}
+	if us.store != nil {
+		if err := us.store.DisconnectBlock(block, undo); err != nil {
+			return fmt.Errorf("failed to persist utxo set: %w", err)
+		}
+	}
 
-				// UTXOとして登録
-				address := hex.EncodeToString(output.PubKeyHash)
-				utxo := UTXO{
-					TxID:     tx.ID,
-					OutIndex: outIdx,
-					Output:   output,
-				}
-				us.UTXOs[address] = append(us.UTXOs[address], utxo)
-			}
+	if n := len(us.undo); n > 0 && us.undo[n-1].hash == block.Hash {
+		us.undo = us.undo[:n-1]
+	}
+	us.tip = block.PreviousHash
+	us.tokens.DisconnectBlock(block)
+	if us.accounts != nil {
+		us.accounts.DisconnectBlock(block, undo)
+	}
+	return nil
+}
 
-			// 入力を処理（コインベース以外）
+// Reindex はブロックチェーン全体からUTXOセットを再構築します
+func (us *UTXOSet) Reindex(blockchain *Blockchain) error {
+	us.mutex.Lock()
+	defer us.mutex.Unlock()
+
+	// UTXOセットをクリア
+	us.byAddress = make(map[string]map[string]int64)
+	us.sequence = 0
+	us.undo = nil
+
+	// ブロックチェーンを先頭から走査し、出力をチェーンの順に並べて、使用された出力に印を付ける
+	// どちらもアウトポイントで引くため、全体でチェーンの入出力の数に比例する時間で済む
+	var outputs []UTXO
+	var spent []bool
+	position := make(map[string]int) // アウトポイント -> outputs の位置
+	for _, block := range blockchain.Blocks {
+		for _, tx := range block.Transactions {
 			if !tx.IsCoinbase() {
 				for _, input := range tx.Inputs {
-					inTxID := hex.EncodeToString(input.TxID)
-					if spentTXOs[inTxID] == nil {
-						spentTXOs[inTxID] = make(map[int]bool)
+					key := outpointKey(input.TxID, input.OutIndex)
+					if i, ok := position[key]; ok {
+						spent[i] = true
+						delete(position, key)
 					}
-					spentTXOs[inTxID][input.OutIndex] = true
 				}
 			}
+			for outIdx, output := range tx.Outputs {
+				position[outpointKey(tx.ID, outIdx)] = len(outputs)
+				outputs = append(outputs, UTXO{TxID: tx.ID, OutIndex: outIdx, Output: output})
+				spent = append(spent, false)
+			}
+		}
+	}
+
+	// 索引への登録（スクリプトの解析を含む）は、最後まで使われなかった出力についてだけ行う
+	us.UTXOs = make(map[string]UTXO, len(position))
+	us.owners = make(map[string]string, len(position))
+	for i, utxo := range outputs {
+		if !spent[i] {
+			us.addLocked(utxo)
+		}
+	}
+
+	us.tip = blockchain.GetLatestBlock().Hash
+	us.tokens.Rebuild(blockchain)
+	if us.accounts != nil {
+		us.accounts.Rebuild(blockchain)
+	}
+
+	if us.store != nil {
+		if err := us.store.ReplaceUTXOs(us.allLocked(), us.tip); err != nil {
+			return fmt.Errorf("failed to persist utxo set: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// allLocked はすべてのUTXOを返します
+// 呼び出し側でロックを取得していることを前提とします
+func (us *UTXOSet) allLocked() []UTXO {
+	utxos := make([]UTXO, 0, len(us.UTXOs))
+	for _, utxo := range us.UTXOs {
+		utxos = append(utxos, utxo)
+	}
+	return utxos
+}
+
+// VerifyPersisted は保存されたUTXOセットを、チェーン全体から再構築したセットと比較します
+// 一致しなければ、不足・余分・内容の異なる出力の数をエラーで返します
+func (us *UTXOSet) VerifyPersisted(blockchain *Blockchain) error {
+	if us.store == nil {
+		return fmt.Errorf("utxo set is not persisted")
+	}
+
+	tip, err := us.store.UTXOTip()
+	if err != nil {
+		return err
+	}
+	if latest := blockchain.GetLatestBlock().Hash; tip != latest {
+		return fmt.Errorf("persisted utxo set is at %s, chain tip is %s", truncateHash(tip), truncateHash(latest))
+	}
+
+	persisted, err := us.store.LoadUTXOs()
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]TxOutput, len(persisted))
+	for _, utxo := range persisted {
+		stored[outpointKey(utxo.TxID, utxo.OutIndex)] = utxo.Output
+	}
+
+	fresh := NewUTXOSet(blockchain)
+	missing, mismatched := 0, 0
+	for _, utxo := range fresh.allLocked() {
+		key := outpointKey(utxo.TxID, utxo.OutIndex)
+		output, ok := stored[key]
+		switch {
+		case !ok:
+			missing++
+		case output.Value != utxo.Output.Value || !bytes.Equal(output.ScriptPubKey, utxo.Output.ScriptPubKey):
+			mismatched++
 		}
+		delete(stored, key)
 	}
 
+	if extra := len(stored); missing > 0 || extra > 0 || mismatched > 0 {
+		return fmt.Errorf("persisted utxo set differs from reindex: %d missing, %d extra, %d mismatched", missing, extra, mismatched)
+	}
 	return nil
 }
 
@@ -177,8 +502,9 @@ func (us *UTXOSet) String() string {
 	defer us.mutex.RUnlock()
 
 	result := "UTXO Set:\n"
-	for address, utxos := range us.UTXOs {
-		result += fmt.Sprintf("  Address %s: %d UTXOs\n", address[:16]+"...", len(utxos))
+	for key := range us.byAddress {
+		utxos := us.addressUTXOsLocked(key)
+		result += fmt.Sprintf("  Address %s: %d UTXOs\n", truncateHash(indexKeyAddress(key)), len(utxos))
 		for _, utxo := range utxos {
 			result += fmt.Sprintf("    - TxID: %s, Index: %d, Value: %d\n",
 				hex.EncodeToString(utxo.TxID)[:16]+"...",
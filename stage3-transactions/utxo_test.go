@@ -1,9 +1,11 @@
 package main
 
 import (
-	"encoding/hex"
+	"encoding/binary"
+	"fmt"
 	"testing"
 
+	"github.com/nyasuto/minicoin/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -47,6 +49,21 @@ func TestGetBalance(t *testing.T) {
 		balance := utxoSet.GetBalance("nonexistent")
 		assert.Equal(t, 0, balance)
 	})
+
+	t.Run("旧形式のアドレスでも同じ残高を引ける", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		legacy := common.LegacyPublicKeyToAddress(wallet.PublicKey)
+
+		bc := NewBlockchain(1, legacy)
+		utxoSet := NewUTXOSet(bc)
+
+		normalized, err := common.NormalizeAddress(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, 50, utxoSet.GetBalance(legacy))
+		assert.Equal(t, 50, utxoSet.GetBalance(normalized))
+		assert.Len(t, utxoSet.FindUTXO(legacy), 1)
+	})
 }
 
 func TestFindSpendableOutputs(t *testing.T) {
@@ -217,14 +234,16 @@ func TestUTXOWithMultipleTransactions(t *testing.T) {
 		bc := NewBlockchain(1, wallet1.GetAddress())
 		utxoSet := NewUTXOSet(bc)
 
-		// ブロック1: wallet2とwallet3にコインベース
+		// ブロック1: wallet2、ブロック2: wallet3にコインベース
 		coinbase2 := NewCoinbaseTx(wallet2.GetAddress(), "Block 1 - wallet2")
-		coinbase3 := NewCoinbaseTx(wallet3.GetAddress(), "Block 1 - wallet3")
-		block1, _, err := bc.MineBlock([]*Transaction{coinbase2, coinbase3})
+		block1, _, err := bc.MineBlock([]*Transaction{coinbase2})
 		require.NoError(t, err)
+		require.NoError(t, utxoSet.Update(block1))
 
-		err = utxoSet.Update(block1)
+		coinbase3 := NewCoinbaseTx(wallet3.GetAddress(), "Block 2 - wallet3")
+		block2, _, err := bc.MineBlock([]*Transaction{coinbase3})
 		require.NoError(t, err)
+		require.NoError(t, utxoSet.Update(block2))
 
 		// 各ウォレットの残高確認
 		balance1 := utxoSet.GetBalance(wallet1.GetAddress())
@@ -256,40 +275,39 @@ func TestUTXOSpentOutputRemoval(t *testing.T) {
 		bc := NewBlockchain(1, wallet1.GetAddress())
 		utxoSet := NewUTXOSet(bc)
 
-		// wallet1から送金するトランザクションを作成（簡略版）
-		// 実際の送金ロジックは後で実装
+		// wallet1から送金するトランザクションを手で作成
 		coinbaseTx := bc.Blocks[0].Transactions[0]
 
 		// 入力: wallet1のコインベース出力を使用
 		txIn := TxInput{
-			TxID:      coinbaseTx.ID,
-			OutIndex:  0,
-			Signature: nil,
-			PubKey:    []byte(wallet1.GetAddress()),
+			TxID:     coinbaseTx.ID,
+			OutIndex: 0,
 		}
 
 		// 出力: wallet2に送金
-		wallet2PubKeyHash, _ := hex.DecodeString(wallet2.GetAddress())
+		wallet2PubKeyHash, _ := common.AddressToPubKeyHash(wallet2.GetAddress())
 		txOut := TxOutput{
-			Value:      30,
-			PubKeyHash: wallet2PubKeyHash,
+			Value:        30,
+			ScriptPubKey: NewP2PKHScript(wallet2PubKeyHash),
 		}
 
 		// おつり: wallet1に返す
-		wallet1PubKeyHash, _ := hex.DecodeString(wallet1.GetAddress())
+		wallet1PubKeyHash, _ := common.AddressToPubKeyHash(wallet1.GetAddress())
 		changeOut := TxOutput{
-			Value:      20,
-			PubKeyHash: wallet1PubKeyHash,
+			Value:        20,
+			ScriptPubKey: NewP2PKHScript(wallet1PubKeyHash),
 		}
 
 		tx := &Transaction{
+			Version: CurrentTxVersion,
 			Inputs:  []TxInput{txIn},
 			Outputs: []TxOutput{txOut, changeOut},
 		}
 		tx.ID = tx.Hash()
+		require.NoError(t, bc.SignTransaction(tx, wallet1))
 
-		// ブロックに追加
-		block, _, err := bc.MineBlock([]*Transaction{tx})
+		// ブロックに追加（マイニング報酬は別のアドレスへ）
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(testAddressA, "Block 1"), tx})
 		require.NoError(t, err)
 
 		// UTXO更新
@@ -305,3 +323,308 @@ func TestUTXOSpentOutputRemoval(t *testing.T) {
 		assert.Equal(t, 30, balance2)
 	})
 }
+
+func TestFindOutput(t *testing.T) {
+	t.Run("未使用の出力を取得", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, wallet.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+		coinbase := bc.Blocks[0].Transactions[0]
+
+		output, ok := utxoSet.FindOutput(coinbase.ID, 0)
+		require.True(t, ok)
+		assert.Equal(t, 50, output.Value)
+	})
+
+	t.Run("存在しない出力", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		bc := NewBlockchain(1, wallet.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+		coinbase := bc.Blocks[0].Transactions[0]
+
+		_, ok := utxoSet.FindOutput(coinbase.ID, 1)
+		assert.False(t, ok)
+		_, ok = utxoSet.FindOutput([]byte("unknown"), 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestDisconnect(t *testing.T) {
+	t.Run("最新ブロックを取り消すと追加前の残高に戻る", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		before := utxoSet.GetBalance(wallet.GetAddress())
+
+		block, _, err := SendCoins(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		require.Equal(t, 20, utxoSet.GetBalance(testAddressA))
+
+		require.NoError(t, utxoSet.Disconnect(block))
+		assert.Equal(t, before, utxoSet.GetBalance(wallet.GetAddress()))
+		assert.Equal(t, 0, utxoSet.GetBalance(testAddressA))
+
+		// ブロックを除いたチェーンから再構築した結果と一致する
+		previous := &Blockchain{Blocks: bc.Blocks[:len(bc.Blocks)-1]}
+		assert.ElementsMatch(t, NewUTXOSet(previous).allLocked(), utxoSet.allLocked())
+	})
+
+	t.Run("ブロック内で作られてすぐ使われた出力も取り消す", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		bc := NewBlockchain(1, wallet.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+		genesis := bc.Blocks[0]
+		toA, err := newOutput(testAddressA, 50)
+		require.NoError(t, err)
+		toB, err := newOutput(testAddressB, 50)
+		require.NoError(t, err)
+
+		first := &Transaction{
+			Inputs:  []TxInput{{TxID: genesis.Transactions[0].ID, OutIndex: 0}},
+			Outputs: []TxOutput{toA},
+		}
+		first.ID = first.Hash()
+		second := &Transaction{
+			Inputs:  []TxInput{{TxID: first.ID, OutIndex: 0}},
+			Outputs: []TxOutput{toB},
+		}
+		second.ID = second.Hash()
+		block := &Block{
+			Index:        1,
+			Transactions: []*Transaction{NewCoinbaseTx(testAddressB, "block 1"), first, second},
+			PreviousHash: genesis.Hash,
+			Hash:         "block-1",
+		}
+
+		require.NoError(t, utxoSet.Update(block))
+		require.Equal(t, 0, utxoSet.GetBalance(testAddressA))
+		require.Equal(t, 100, utxoSet.GetBalance(testAddressB))
+
+		require.NoError(t, utxoSet.Disconnect(block))
+		assert.Equal(t, 50, utxoSet.GetBalance(wallet.GetAddress()))
+		assert.Equal(t, 0, utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, 0, utxoSet.GetBalance(testAddressB))
+		assert.Len(t, utxoSet.allLocked(), 1)
+	})
+
+	t.Run("最新でないブロックは取り消せない", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		first, _, err := SendCoins(mempool, wallet, testAddressA, 5, 1)
+		require.NoError(t, err)
+		_, _, err = SendCoins(mempool, wallet, testAddressA, 5, 1)
+		require.NoError(t, err)
+
+		assert.Error(t, utxoSet.Disconnect(first))
+		assert.Equal(t, 10, utxoSet.GetBalance(testAddressA))
+		assert.Equal(t, 3, bc.GetChainLength())
+	})
+
+	t.Run("取り消し用データがなければエラー", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		bc := NewBlockchain(1, wallet.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		// 再構築したセットには取り消し用データがない
+		err = utxoSet.Disconnect(bc.Blocks[0])
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no undo data")
+		assert.Equal(t, 50, utxoSet.GetBalance(wallet.GetAddress()))
+	})
+
+	t.Run("取り消し用データは最新の maxUndoBlocks ブロックだけ持つ", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		bc := NewBlockchain(1, wallet.GetAddress())
+		utxoSet := NewUTXOSet(bc)
+
+		var blocks []*Block
+		previous := bc.Blocks[0].Hash
+		for i := 1; i <= maxUndoBlocks+5; i++ {
+			block := &Block{
+				Index:        int64(i),
+				Transactions: []*Transaction{NewCoinbaseTx(testAddressA, fmt.Sprintf("block %d", i))},
+				PreviousHash: previous,
+				Hash:         fmt.Sprintf("block-%d", i),
+			}
+			require.NoError(t, utxoSet.Update(block))
+			blocks = append(blocks, block)
+			previous = block.Hash
+		}
+		assert.Len(t, utxoSet.undo, maxUndoBlocks)
+
+		// 窓の中のブロックは取り消せるが、それより深いブロックには取り消し用データがない
+		for i := len(blocks) - 1; i >= len(blocks)-maxUndoBlocks; i-- {
+			require.NoError(t, utxoSet.Disconnect(blocks[i]))
+		}
+		err = utxoSet.Disconnect(blocks[len(blocks)-maxUndoBlocks-1])
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no undo data")
+		assert.Equal(t, 5*50, utxoSet.GetBalance(testAddressA))
+	})
+}
+
+func TestUTXOSetOutpointIndex(t *testing.T) {
+	t.Run("使用した出力をアウトポイントで削除し、空になったアドレスを索引から外す", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		genesis := bc.Blocks[0].Transactions[0]
+
+		tx, err := SubmitTransaction(mempool, wallet, testAddressA, 50, 0)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+
+		_, ok := utxoSet.FindOutput(genesis.ID, 0)
+		assert.False(t, ok)
+		assert.NotContains(t, utxoSet.byAddress, addressIndexKey(wallet.GetAddress()))
+		assert.Contains(t, utxoSet.UTXOs, outpointKey(tx.ID, 0))
+		assert.Len(t, utxoSet.UTXOs, 2)
+	})
+
+	t.Run("アドレスのUTXOはチェーンに追加された順に返す", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		for i := 0; i < 3; i++ {
+			_, _, err := mempool.MineBlock(wallet.GetAddress())
+			require.NoError(t, err)
+		}
+
+		var blockOrder [][]byte
+		for _, block := range bc.Blocks {
+			blockOrder = append(blockOrder, block.Transactions[0].ID)
+		}
+		for _, set := range []*UTXOSet{utxoSet, NewUTXOSet(bc)} {
+			var ids [][]byte
+			for _, utxo := range set.FindUTXO(wallet.GetAddress()) {
+				ids = append(ids, utxo.TxID)
+			}
+			assert.Equal(t, blockOrder, ids)
+		}
+	})
+
+	t.Run("取り消すと使用した出力が索引に戻る", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, err := SubmitTransaction(mempool, wallet, testAddressA, 20, 1)
+		require.NoError(t, err)
+		block, _, err := mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+
+		require.NoError(t, utxoSet.Disconnect(block))
+		assert.Equal(t, 50, utxoSet.GetBalance(wallet.GetAddress()))
+		assert.Empty(t, utxoSet.FindUTXO(testAddressA))
+		assert.NotContains(t, utxoSet.byAddress, addressIndexKey(testAddressB))
+		assert.Len(t, utxoSet.UTXOs, 1)
+		assert.Equal(t, bc.Blocks[0].Hash, utxoSet.tip)
+	})
+}
+
+// newSyntheticChain はベンチマーク用に、署名もマイニングもしていないブロックを並べたチェーンを作成します
+// 各トランザクションは古いUTXOを1つ使い、それぞれ新しいアドレスへの2つの出力を作るため、アドレスとUTXOが増え続けます
+func newSyntheticChain(blocks, txsPerBlock int) *Blockchain {
+	bc := NewBlockchain(0, testAddressA)
+	unspent := []UTXO{{TxID: bc.Blocks[0].Transactions[0].ID, OutIndex: 0}}
+	addresses := 0
+	nextAddress := func() string {
+		addresses++
+		pubKeyHash := make([]byte, common.PubKeyHashLen)
+		binary.BigEndian.PutUint64(pubKeyHash, uint64(addresses))
+		return common.PubKeyHashToAddress(pubKeyHash)
+	}
+
+	for height := int64(1); height <= int64(blocks); height++ {
+		coinbase := NewCoinbaseTxAtHeight(nextAddress(), "", InitialBlockReward, height)
+		transactions := []*Transaction{coinbase}
+		unspent = append(unspent, UTXO{TxID: coinbase.ID, OutIndex: 0})
+		for i := 0; i < txsPerBlock; i++ {
+			spent := unspent[0]
+			unspent = unspent[1:]
+			tx := &Transaction{Version: CurrentTxVersion, Inputs: []TxInput{{TxID: spent.TxID, OutIndex: spent.OutIndex}}}
+			for j := 0; j < 2; j++ {
+				output, _ := newOutput(nextAddress(), 1)
+				tx.Outputs = append(tx.Outputs, output)
+			}
+			tx.ID = tx.Hash()
+			transactions = append(transactions, tx)
+			unspent = append(unspent, UTXO{TxID: tx.ID, OutIndex: 0}, UTXO{TxID: tx.ID, OutIndex: 1})
+		}
+
+		last := bc.Blocks[len(bc.Blocks)-1]
+		block := NewBlock(height, transactions, last.Hash, 0)
+		block.Hash = block.CalculateHashWithNonce()
+		bc.Blocks = append(bc.Blocks, block)
+	}
+	return bc
+}
+
+func BenchmarkUTXOSetUpdate(b *testing.B) {
+	for _, blocks := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("blocks=%d", blocks), func(b *testing.B) {
+			bc := newSyntheticChain(blocks, 20)
+			tip := bc.Blocks[len(bc.Blocks)-1]
+			bc.Blocks = bc.Blocks[:len(bc.Blocks)-1]
+			utxoSet := NewUTXOSet(bc)
+
+			// 最新ブロックの接続と取り消しを繰り返す
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := utxoSet.Update(tip); err != nil {
+					b.Fatal(err)
+				}
+				if err := utxoSet.Disconnect(tip); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUTXOSetConnectChain(b *testing.B) {
+	for _, blocks := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("blocks=%d", blocks), func(b *testing.B) {
+			bc := newSyntheticChain(blocks, 20)
+			genesis := NewBlockchainFromGenesis(bc.Blocks[0], 0)
+
+			// ジェネシスブロックだけのセットに、ブロックを1つずつ接続する（ノードがチェーンに追いつくときと同じ）
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				utxoSet := NewUTXOSet(genesis)
+				for _, block := range bc.Blocks[1:] {
+					if err := utxoSet.Update(block); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUTXOSetReindex(b *testing.B) {
+	for _, blocks := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("blocks=%d", blocks), func(b *testing.B) {
+			bc := newSyntheticChain(blocks, 20)
+			utxoSet := NewUTXOSet(bc)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := utxoSet.Reindex(bc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUTXOSetFindOutput(b *testing.B) {
+	bc := newSyntheticChain(1000, 20)
+	utxoSet := NewUTXOSet(bc)
+	tx := bc.Blocks[len(bc.Blocks)-1].Transactions[1]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := utxoSet.FindOutput(tx.ID, 0); !ok {
+			b.Fatal("output not found")
+		}
+	}
+}
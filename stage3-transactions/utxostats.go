@@ -0,0 +1,164 @@
+// Package main implements UTXO set statistics for Stage 3.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+)
+
+// UTXOStats はUTXOセットの統計です（JSONでグラフ作成用に出力できます）
+type UTXOStats struct {
+	Height    int64             `json:"height"`     // 反映している最新ブロックの高さ
+	Supply    int               `json:"supply"`     // 流通量（すべてのUTXOの金額の合計）
+	Count     int               `json:"utxo_count"` // UTXOの数
+	Addresses int               `json:"addresses"`  // UTXOを持つアドレスの数
+	Mean      float64           `json:"mean"`       // 金額の平均
+	Median    float64           `json:"median"`     // 金額の中央値
+	Min       int               `json:"min"`        // 最小の金額
+	Max       int               `json:"max"`        // 最大の金額
+	Histogram []HistogramBucket `json:"histogram"`  // 金額の分布（2のべき乗ごと）
+}
+
+// HistogramBucket は金額が Min 以上 Max 未満のUTXOの数と合計額です
+type HistogramBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+	Value int `json:"value"`
+}
+
+// Stats はUTXOセットの統計を計算します（Height は呼び出し側で設定します）
+// 分布は [0,1), [1,2), [2,4), [4,8)... の区間で、最大の金額を含む区間まで空の区間も含めて返します
+func (us *UTXOSet) Stats() UTXOStats {
+	us.mutex.RLock()
+	defer us.mutex.RUnlock()
+
+	stats := UTXOStats{Addresses: len(us.byAddress)}
+	values := make([]int, 0, len(us.UTXOs))
+	for _, utxo := range us.UTXOs {
+		values = append(values, utxo.Output.Value)
+	}
+	if len(values) == 0 {
+		return stats
+	}
+
+	sort.Ints(values)
+	stats.Count = len(values)
+	stats.Min, stats.Max = values[0], values[len(values)-1]
+	for _, value := range values {
+		stats.Supply += value
+	}
+	stats.Mean = float64(stats.Supply) / float64(stats.Count)
+	if middle := stats.Count / 2; stats.Count%2 == 1 {
+		stats.Median = float64(values[middle])
+	} else {
+		stats.Median = float64(values[middle-1]+values[middle]) / 2
+	}
+
+	stats.Histogram = make([]HistogramBucket, histogramBucket(stats.Max)+1)
+	for i := range stats.Histogram {
+		if i > 0 {
+			stats.Histogram[i].Min = 1 << (i - 1)
+		}
+		stats.Histogram[i].Max = 1 << i
+	}
+	for _, value := range values {
+		bucket := &stats.Histogram[histogramBucket(value)]
+		bucket.Count++
+		bucket.Value += value
+	}
+	return stats
+}
+
+// histogramBucket は金額が入る分布の区間の番号を返します（0は [0,1)、i は [2^(i-1), 2^i)）
+func histogramBucket(value int) int {
+	if value <= 0 {
+		return 0
+	}
+	return bits.Len(uint(value))
+}
+
+// runUTXOStatsCommand は utxostats サブコマンドを実行します
+// chain.db のUTXOセットから流通量・UTXOの数・金額の平均と中央値・分布を表示します
+func runUTXOStatsCommand(args []string) int {
+	fs := flag.NewFlagSet("utxostats", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "JSONで出力する（グラフ作成用）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	store, err := OpenChainStore(chainFile)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	defer func() { _ = store.Close() }()
+
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(blocks) == 0 {
+		fmt.Printf("❌ No blocks in %s\n", chainFile)
+		return 1
+	}
+	bc, err := OpenBlockchain(store, 2, "")
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	utxoSet, _, err := OpenUTXOSet(store, bc)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	stats := utxoSet.Stats()
+	stats.Height = bc.GetLatestBlock().Index
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stats); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	printUTXOStats(stats)
+	return 0
+}
+
+// printUTXOStats はUTXOセットの統計と金額の分布を表示します
+func printUTXOStats(stats UTXOStats) {
+	fmt.Println("\n📊 UTXO Set Statistics")
+	fmt.Println("════════════════════════════════════════════════════════")
+	fmt.Printf("Height:     %d\n", stats.Height)
+	fmt.Printf("Supply:     %d coins\n", stats.Supply)
+	fmt.Printf("UTXOs:      %d (%d address(es))\n", stats.Count, stats.Addresses)
+	if stats.Count > 0 {
+		fmt.Printf("Mean:       %.2f coins\n", stats.Mean)
+		fmt.Printf("Median:     %.1f coins\n", stats.Median)
+		fmt.Printf("Range:      %d to %d coins\n", stats.Min, stats.Max)
+	}
+
+	if len(stats.Histogram) > 0 {
+		fmt.Println("────────────────────────────────────────────────────────")
+		fmt.Println("Value distribution:")
+		largest := 0
+		for _, bucket := range stats.Histogram {
+			largest = max(largest, bucket.Count)
+		}
+		for _, bucket := range stats.Histogram {
+			bar := strings.Repeat("█", (bucket.Count*30+largest-1)/largest)
+			fmt.Printf("  %5d-%-5d %4d %s\n", bucket.Min, bucket.Max-1, bucket.Count, bar)
+		}
+	}
+	fmt.Println("════════════════════════════════════════════════════════")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUTXOStats(t *testing.T) {
+	t.Run("流通量と金額の統計を計算する", func(t *testing.T) {
+		wallet, _, utxoSet, mempool := newTestChain(t)
+		// ジェネシスの50から 3 を送金し、手数料1を払っておつり46
+		_, err := SubmitTransaction(mempool, wallet, testAddressA, 3, 1)
+		require.NoError(t, err)
+		_, _, err = mempool.MineBlock(testAddressB)
+		require.NoError(t, err)
+
+		stats := utxoSet.Stats()
+		assert.Equal(t, 100, stats.Supply)
+		assert.Equal(t, 3, stats.Count)
+		assert.Equal(t, 3, stats.Addresses)
+		assert.InDelta(t, 100.0/3, stats.Mean, 1e-9)
+		assert.Equal(t, 46.0, stats.Median)
+		assert.Equal(t, 3, stats.Min)
+		assert.Equal(t, 51, stats.Max)
+
+		// 3 は [2,4)、46 と 51 は [32,64)
+		require.Len(t, stats.Histogram, 7)
+		assert.Equal(t, HistogramBucket{Min: 2, Max: 4, Count: 1, Value: 3}, stats.Histogram[2])
+		assert.Equal(t, HistogramBucket{Min: 32, Max: 64, Count: 2, Value: 97}, stats.Histogram[6])
+		total := 0
+		for _, bucket := range stats.Histogram {
+			total += bucket.Count
+		}
+		assert.Equal(t, stats.Count, total)
+	})
+
+	t.Run("偶数個の中央値は真ん中の2つの平均", func(t *testing.T) {
+		_, bc, utxoSet, _ := newTestChain(t)
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTxWithReward(testAddressA, "small", 10)})
+		require.NoError(t, err)
+		require.NoError(t, utxoSet.Update(block))
+
+		stats := utxoSet.Stats()
+		assert.Equal(t, 2, stats.Count)
+		assert.Equal(t, 30.0, stats.Median)
+	})
+
+	t.Run("JSONで出力できる", func(t *testing.T) {
+		_, _, utxoSet, _ := newTestChain(t)
+
+		data, err := json.Marshal(utxoSet.Stats())
+		require.NoError(t, err)
+		var decoded map[string]any
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, 50.0, decoded["supply"])
+		assert.Equal(t, 1.0, decoded["utxo_count"])
+		assert.Len(t, decoded["histogram"], 7)
+	})
+
+	t.Run("空のUTXOセット", func(t *testing.T) {
+		stats := newEmptyUTXOSet(nil).Stats()
+		assert.Zero(t, stats.Count)
+		assert.Empty(t, stats.Histogram)
+	})
+}
@@ -0,0 +1,200 @@
+// Package main implements block-level transaction validation for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// chainState はブロックを先頭から順に接続しながら、トランザクションと未使用の出力を追跡します
+// ブロックの検証はこの状態（それまでのチェーン）に対して行います
+type chainState struct {
+	txs     map[string]*Transaction // TxID(hex) -> Transaction
+	unspent map[string]TxOutput     // outpointKey -> 未使用の出力
+}
+
+func newChainState() *chainState {
+	return &chainState{
+		txs:     make(map[string]*Transaction),
+		unspent: make(map[string]TxOutput),
+	}
+}
+
+// connectBlock はブロックのトランザクションを検証し、問題がなければ状態に反映します
+// 検証内容:
+//   - トランザクションの数と重さの合計が上限以下
+//   - 先頭がコインベースで、コインベースはちょうど1つ（高さをコミットしていればブロックの高さと一致）
+//   - トランザクションIDが内容から計算したハッシュと一致し、それまでのチェーンとブロック内で重複しない
+//   - 各トランザクションのバージョンが既知で、そのバージョンで使える機能だけを使っている
+//   - 各トランザクションの入力が未使用の出力を参照し、ブロック内でも二重に使われていない
+//   - scriptSig で参照先のロックを解除でき、ロック時刻がブロックの高さ以下
+//   - 金額が0以上 MaxMoney 以下で、出力の合計が入力の合計を超えない
+//   - トークンの数量が資産ごとに保存されている（増やせるのは発行だけで、コインベースはトークンを持てない）
+//   - コインベースの合計額がその高さの報酬と手数料の合計以下
+//
+// エラーの場合、状態は途中まで更新されている可能性があります
+func (s *chainState) connectBlock(block *Block, emission EmissionSchedule) error {
+	if err := checkBlockLimits(block); err != nil {
+		return err
+	}
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinbase() {
+		return fmt.Errorf("block %d: first transaction must be a coinbase", block.Index)
+	}
+
+	if err := block.Transactions[0].CheckVersion(nil); err != nil {
+		return fmt.Errorf("block %d: coinbase: %w", block.Index, err)
+	}
+	if err := block.Transactions[0].CheckAmounts(); err != nil {
+		return fmt.Errorf("block %d: coinbase: %w", block.Index, err)
+	}
+	if err := block.Transactions[0].CheckTokens(nil); err != nil {
+		return fmt.Errorf("block %d: coinbase: %w", block.Index, err)
+	}
+	if err := checkCoinbaseHeight(block); err != nil {
+		return err
+	}
+
+	fees := 0
+	for i, tx := range block.Transactions {
+		id := hex.EncodeToString(tx.ID)
+		if err := tx.CheckID(); err != nil {
+			return fmt.Errorf("block %d: transaction %s: %w", block.Index, truncateHash(id), err)
+		}
+		// 同じIDのトランザクションがあると、出力が上書きされてUTXOが失われる
+		if _, exists := s.txs[id]; exists {
+			return fmt.Errorf("block %d: duplicate transaction %s", block.Index, truncateHash(id))
+		}
+		if i > 0 {
+			fee, err := s.connectTransaction(tx, block.Index)
+			if err != nil {
+				return fmt.Errorf("block %d: transaction %s: %w", block.Index, truncateHash(id), err)
+			}
+			fees += fee
+		}
+
+		s.txs[id] = tx
+		for index, output := range tx.Outputs {
+			s.unspent[outpointKey(tx.ID, index)] = output
+		}
+	}
+
+	minted := 0
+	for _, output := range block.Transactions[0].Outputs {
+		minted += output.Value
+	}
+	if limit := emission.RewardAt(block.Index) + fees; minted > limit {
+		return fmt.Errorf("block %d: coinbase pays %d, more than reward and fees %d", block.Index, minted, limit)
+	}
+	return nil
+}
+
+// connectTransaction はコインベース以外のトランザクションを検証し、参照した出力を使用済みにして手数料を返します
+func (s *chainState) connectTransaction(tx *Transaction, height int64) (int, error) {
+	if tx.IsCoinbase() || len(tx.Inputs) == 0 {
+		return 0, fmt.Errorf("only the first transaction may be a coinbase")
+	}
+	if tx.LockTime > height {
+		return 0, fmt.Errorf("timelocked until block %d", tx.LockTime)
+	}
+
+	// 入力がそれまでのチェーンの未使用の出力を参照し、価値が保存されているかを検証する
+	prevOutputs, fee, err := tx.CheckInputs(s)
+	if err != nil {
+		return 0, err
+	}
+	prevTxs := make(map[string]*Transaction)
+	for _, input := range tx.Inputs {
+		delete(s.unspent, outpointKey(input.TxID, input.OutIndex))
+		prevTxs[hex.EncodeToString(input.TxID)] = s.txs[hex.EncodeToString(input.TxID)]
+	}
+
+	if err := tx.CheckVersion(prevTxs); err != nil {
+		return 0, err
+	}
+	if err := tx.CheckTokens(prevTxs); err != nil {
+		return 0, err
+	}
+	if err := tx.verifyScriptsWith(prevOutputs); err != nil {
+		return 0, err
+	}
+	return fee, nil
+}
+
+// Validate はブロックのリンクとハッシュに加え、すべてのトランザクションをそれまでのチェーンに対して検証します
+// 最初に見つかった問題をエラーで返します
+func (bc *Blockchain) Validate() error {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if len(bc.Blocks) == 0 {
+		return fmt.Errorf("chain has no blocks")
+	}
+
+	// ジェネシスブロックの検証
+	if bc.Blocks[0].Index != 0 || bc.Blocks[0].PreviousHash != "" {
+		return fmt.Errorf("block 0 is not a genesis block")
+	}
+
+	state := newChainState()
+	for i, block := range bc.Blocks {
+		// ブロック自体の整合性
+		if !block.Validate() {
+			return fmt.Errorf("block %d: invalid hash or proof of work", block.Index)
+		}
+
+		// 前ブロックとのリンク検証（ジェネシス以外）
+		if i > 0 {
+			prevBlock := bc.Blocks[i-1]
+
+			// インデックスの連続性
+			if block.Index != prevBlock.Index+1 {
+				return fmt.Errorf("block %d: index does not follow block %d", block.Index, prevBlock.Index)
+			}
+
+			// 前ブロックのハッシュ
+			if block.PreviousHash != prevBlock.Hash {
+				return fmt.Errorf("block %d: previous hash does not match block %d", block.Index, prevBlock.Index)
+			}
+
+			// タイムスタンプの順序
+			if block.Timestamp < prevBlock.Timestamp {
+				return fmt.Errorf("block %d: timestamp is earlier than block %d", block.Index, prevBlock.Index)
+			}
+
+			// 難易度の自動調整が有効なら、それまでのブロックから求めた難易度でマイニングされているか
+			if bc.TargetBlockTime > 0 {
+				if expected := expectedDifficulty(bc.Blocks[:i], bc.TargetBlockTime); block.Difficulty != expected {
+					return fmt.Errorf("block %d: difficulty %d does not match the adjusted difficulty %d", block.Index, block.Difficulty, expected)
+				}
+			}
+		}
+
+		// トランザクションの検証
+		if err := state.connectBlock(block, bc.Emission); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stateLocked はチェーンのすべてのブロックを接続した状態を返します
+// チェーンのブロックは追加時に検証済みなので、ここでは検証せずに反映します
+// 呼び出し側でロックを取得していることを前提とします
+func (bc *Blockchain) stateLocked() *chainState {
+	state := newChainState()
+	for _, block := range bc.Blocks {
+		for _, tx := range block.Transactions {
+			if !tx.IsCoinbase() {
+				for _, input := range tx.Inputs {
+					delete(state.unspent, outpointKey(input.TxID, input.OutIndex))
+				}
+			}
+			state.txs[hex.EncodeToString(tx.ID)] = tx
+			for index, output := range tx.Outputs {
+				state.unspent[outpointKey(tx.ID, index)] = output
+			}
+		}
+	}
+	return state
+}
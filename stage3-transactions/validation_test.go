@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendUnchecked はトランザクションを検証せずにブロックをマイニングしてチェーンに追加します
+// 他のノードから受け取った不正なブロックを模擬するために使います
+func appendUnchecked(t *testing.T, bc *Blockchain, transactions []*Transaction) *Block {
+	t.Helper()
+
+	last := bc.GetLatestBlock()
+	block := NewBlock(last.Index+1, transactions, last.Hash, bc.Difficulty)
+	_, err := MineBlock(block)
+	require.NoError(t, err)
+	bc.Blocks = append(bc.Blocks, block)
+	return block
+}
+
+func TestBlockValidation(t *testing.T) {
+	newFixture := func(t *testing.T) (*Wallet, *Blockchain, *Transaction) {
+		t.Helper()
+
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		bc := NewBlockchain(1, wallet.GetAddress())
+		tx, err := NewTransaction(wallet, testAddressA, 20, 2, NewUTXOSet(bc), bc)
+		require.NoError(t, err)
+		return wallet, bc, tx
+	}
+
+	t.Run("正しいブロックは受け付ける", func(t *testing.T) {
+		wallet, bc, tx := newFixture(t)
+
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTxWithReward(wallet.GetAddress(), "ok", InitialBlockReward+2), tx})
+		require.NoError(t, err)
+		assert.NoError(t, bc.Validate())
+	})
+
+	t.Run("コインベースがないブロックは無効", func(t *testing.T) {
+		_, bc, tx := newFixture(t)
+
+		_, _, err := bc.MineBlock([]*Transaction{tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "first transaction must be a coinbase")
+
+		appendUnchecked(t, bc, []*Transaction{tx})
+		assert.False(t, bc.IsValid())
+	})
+
+	t.Run("コインベースが2つあるブロックは無効", func(t *testing.T) {
+		wallet, bc, _ := newFixture(t)
+
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "a"), NewCoinbaseTx(wallet.GetAddress(), "b")})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only the first transaction may be a coinbase")
+	})
+
+	t.Run("署名が不正なトランザクションを含むブロックは無効", func(t *testing.T) {
+		wallet, bc, tx := newFixture(t)
+		tx.Outputs[0].Value = 25 // 署名後に改ざん
+
+		txs := []*Transaction{NewCoinbaseTx(wallet.GetAddress(), "forged"), tx}
+		_, _, err := bc.MineBlock(txs)
+		assert.Error(t, err)
+
+		appendUnchecked(t, bc, txs)
+		err = bc.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "block 1")
+	})
+
+	t.Run("同じ出力を二重に使うブロックは無効", func(t *testing.T) {
+		wallet, bc, tx := newFixture(t)
+		other, err := NewTransaction(wallet, testAddressB, 10, 0, NewUTXOSet(bc), bc)
+		require.NoError(t, err)
+
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "double"), tx, other})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already spent")
+
+		// 別のブロックでも使えない
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "first"), tx})
+		require.NoError(t, err)
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "second"), other})
+		assert.Error(t, err)
+	})
+
+	t.Run("出力の合計が入力を超えるトランザクションは無効", func(t *testing.T) {
+		wallet, bc, tx := newFixture(t)
+		tx.Outputs[0].Value = 100
+		tx.ID = tx.Hash()
+		require.NoError(t, bc.SignTransaction(tx, wallet))
+
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "inflate"), tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outputs exceed inputs")
+	})
+
+	t.Run("ロック時刻に達していないトランザクションは無効", func(t *testing.T) {
+		wallet, bc, tx := newFixture(t)
+		tx.LockTime = 2
+		tx.ID = tx.Hash()
+		require.NoError(t, bc.SignTransaction(tx, wallet))
+
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "early"), tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timelocked")
+	})
+
+	t.Run("IDが内容と一致しないトランザクションを含むブロックは無効", func(t *testing.T) {
+		wallet, bc, tx := newFixture(t)
+		tx.ID = NewCoinbaseTx(wallet.GetAddress(), "other").ID
+
+		_, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "mismatch"), tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match its contents")
+	})
+
+	t.Run("マイニング後にコインベースの支払先を書き換えたブロックは無効", func(t *testing.T) {
+		wallet, bc, _ := newFixture(t)
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "reward")})
+		require.NoError(t, err)
+		require.NoError(t, bc.Validate())
+
+		output, err := newOutput(testAddressA, InitialBlockReward)
+		require.NoError(t, err)
+		block.Transactions[0].Outputs[0] = output
+
+		assert.False(t, block.Validate())
+		assert.Error(t, bc.Validate())
+	})
+
+	t.Run("マイニング後に署名を書き換えたブロックは無効", func(t *testing.T) {
+		wallet, bc, tx := newFixture(t)
+		block, _, err := bc.MineBlock([]*Transaction{NewCoinbaseTxWithReward(wallet.GetAddress(), "reward", InitialBlockReward+2), tx})
+		require.NoError(t, err)
+
+		// トランザクションIDは署名を含まないため変わらないが、ブロックハッシュが一致しなくなる
+		tx.Inputs[0].ScriptSig = Script{}.AddData([]byte("forged"))
+		require.NoError(t, tx.CheckID())
+
+		assert.False(t, block.Validate())
+		assert.Error(t, bc.Validate())
+	})
+
+	t.Run("無効なブロックはチェーンに追加しない", func(t *testing.T) {
+		_, bc, tx := newFixture(t)
+
+		_, _, err := bc.MineBlock([]*Transaction{tx})
+		require.Error(t, err)
+		assert.Equal(t, 1, bc.GetChainLength())
+	})
+}
@@ -0,0 +1,70 @@
+// Package main implements transaction versions and their validation rules for Stage 3.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// トランザクションのバージョン
+// 新しい機能は新しいバージョンでだけ使えるようにし、古いバージョンのトランザクションは当時のルールのまま有効にします
+const (
+	TxVersion1       uint32 = 1 // P2PKHの送金のみ
+	TxVersion2       uint32 = 2 // ロック時刻とP2SH（マルチシグ・タイムロック）を使える
+	CurrentTxVersion        = TxVersion2
+)
+
+// TxVersionRules はバージョンごとに使える機能です
+type TxVersionRules struct {
+	LockTime bool // ロック時刻（LockTime）を使える
+	Scripts  bool // P2SHの出力を作成・使用できる
+}
+
+// txVersionRules はバージョンごとのルールの表です（ここにないバージョンは無効）
+var txVersionRules = map[uint32]TxVersionRules{
+	TxVersion1: {},
+	TxVersion2: {LockTime: true, Scripts: true},
+}
+
+// VersionRules はバージョンのルールを返します。未知のバージョンならエラーを返します
+func VersionRules(version uint32) (TxVersionRules, error) {
+	rules, ok := txVersionRules[version]
+	if !ok {
+		return TxVersionRules{}, fmt.Errorf("unknown transaction version %d (supported: %d to %d)", version, TxVersion1, CurrentTxVersion)
+	}
+	return rules, nil
+}
+
+// CheckVersion はトランザクションがバージョンのルールで使えない機能を使っていないか検証します
+// prevTxs: 参照する前トランザクションのマップ（TxID(hex) -> Transaction）。コインベースでは使いません
+func (tx *Transaction) CheckVersion(prevTxs map[string]*Transaction) error {
+	rules, err := VersionRules(tx.Version)
+	if err != nil {
+		return err
+	}
+
+	if tx.LockTime != 0 && !rules.LockTime {
+		return fmt.Errorf("version %d transactions cannot use a lock time", tx.Version)
+	}
+	if rules.Scripts {
+		return nil
+	}
+	for i, output := range tx.Outputs {
+		if class, _ := output.ScriptPubKey.classify(); class == p2shScript {
+			return fmt.Errorf("output %d: version %d transactions cannot pay to a script hash", i, tx.Version)
+		}
+	}
+	if tx.IsCoinbase() {
+		return nil
+	}
+	for i, input := range tx.Inputs {
+		output, ok := previousOutput(input, prevTxs)
+		if !ok {
+			return fmt.Errorf("input %d: previous output %s not found", i, hex.EncodeToString(input.TxID))
+		}
+		if class, _ := output.ScriptPubKey.classify(); class == p2shScript {
+			return fmt.Errorf("input %d: version %d transactions cannot spend a script hash output", i, tx.Version)
+		}
+	}
+	return nil
+}
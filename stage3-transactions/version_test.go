@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVersionedSpend は wallet から to へ送金するトランザクションを指定したバージョンで作り、署名します
+func newVersionedSpend(t *testing.T, bc *Blockchain, utxoSet *UTXOSet, wallet *Wallet, to string, version uint32, lockTime int64) *Transaction {
+	t.Helper()
+
+	tx, _, err := NewUnsignedTransaction(wallet.GetAddress(), to, 10, 1, DefaultCoinSelection, utxoSet)
+	require.NoError(t, err)
+	tx.Version = version
+	tx.LockTime = lockTime
+	tx.ID = tx.Hash()
+	require.NoError(t, bc.SignTransaction(tx, wallet))
+	return tx
+}
+
+func TestTransactionVersion(t *testing.T) {
+	t.Run("新しく作るトランザクションは現在のバージョン", func(t *testing.T) {
+		assert.Equal(t, CurrentTxVersion, NewCoinbaseTx(testAddressA, "").Version)
+	})
+
+	t.Run("バージョン1のP2PKHの送金は引き続き有効", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		tx := newVersionedSpend(t, bc, utxoSet, wallet, testAddressA, TxVersion1, 0)
+
+		require.NoError(t, mempool.Add(tx))
+		_, _, err := mempool.MineBlock(wallet.GetAddress())
+		require.NoError(t, err)
+		assert.NoError(t, bc.Validate())
+	})
+
+	t.Run("バージョン1ではロック時刻を使えない", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		tx := newVersionedSpend(t, bc, utxoSet, wallet, testAddressA, TxVersion1, 1)
+
+		err := mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot use a lock time")
+
+		tx = newVersionedSpend(t, bc, utxoSet, wallet, testAddressA, TxVersion2, 1)
+		assert.NoError(t, mempool.Add(tx))
+	})
+
+	t.Run("バージョン1ではP2SHの出力を作成も使用もできない", func(t *testing.T) {
+		var signers []*Wallet
+		var pubKeys [][]byte
+		for i := 0; i < 3; i++ {
+			signer, err := NewWallet()
+			require.NoError(t, err)
+			signers = append(signers, signer)
+			pubKeys = append(pubKeys, publicKeyToBytes(signer.PublicKey))
+		}
+		script, err := NewMultisigScript(2, pubKeys)
+		require.NoError(t, err)
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		_, _, err = SendCoins(mempool, wallet, script.Address(), 30, 0)
+		require.NoError(t, err)
+
+		tx := newVersionedSpend(t, bc, utxoSet, wallet, script.Address(), TxVersion1, 0)
+		err = mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot pay to a script hash")
+
+		spend, err := NewScriptSpend(script, testAddressA, 29, 1, mempool) // おつりなし
+		require.NoError(t, err)
+		spend.Version = TxVersion1
+		spend.ID = spend.Hash()
+		require.NoError(t, bc.SignTransaction(spend, signers[0]))
+		require.NoError(t, bc.SignTransaction(spend, signers[1]))
+		err = mempool.Add(spend)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot spend a script hash output")
+	})
+
+	t.Run("未知のバージョンは理由とともに拒否する", func(t *testing.T) {
+		wallet, bc, utxoSet, mempool := newTestChain(t)
+		tx := newVersionedSpend(t, bc, utxoSet, wallet, testAddressA, 99, 0)
+
+		err := mempool.Add(tx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown transaction version 99")
+		assert.False(t, bc.VerifyTransaction(tx))
+
+		_, _, err = bc.MineBlock([]*Transaction{NewCoinbaseTx(wallet.GetAddress(), "v99"), tx})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown transaction version 99")
+
+		coinbase := NewCoinbaseTx(wallet.GetAddress(), "v0")
+		coinbase.Version = 0
+		_, _, err = bc.MineBlock([]*Transaction{coinbase})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "coinbase: unknown transaction version 0")
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 
 	"github.com/nyasuto/minicoin/common"
 )
@@ -42,12 +43,40 @@ func NewWallet() (*Wallet, error) {
 	return wallet, nil
 }
 
+// NewWalletFromMnemonic は Mnemonic で作成した24語からウォレットを復元します
+func NewWalletFromMnemonic(mnemonic string) (*Wallet, error) {
+	entropy, err := MnemonicToEntropy(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	if len(entropy) != 32 {
+		return nil, fmt.Errorf("wallet backup must be 24 words, got %d", len(strings.Fields(mnemonic)))
+	}
+
+	privateKey, ok := privateKeyFromBytes(entropy)
+	if !ok {
+		return nil, fmt.Errorf("mnemonic does not encode a valid private key")
+	}
+
+	return &Wallet{
+		PrivateKey: privateKey,
+		PublicKey:  &privateKey.PublicKey,
+		Address:    common.PublicKeyToAddress(&privateKey.PublicKey),
+	}, nil
+}
+
 // GetAddress はウォレットのアドレスを返します
 func (w *Wallet) GetAddress() string {
 	return w.Address
 }
 
-// Sign はデータに署名します
+// Mnemonic は秘密鍵をそのままエントロピーにしたBIP39の24語を返します
+// バイナリのウォレットファイルの代わりに、紙に書いてバックアップできます
+func (w *Wallet) Mnemonic() (string, error) {
+	return NewMnemonic(w.PrivateKey.D.FillBytes(make([]byte, 32)))
+}
+
+// Sign はデータに署名します（RFC 6979 の決定的なナンスを使うため、同じデータには常に同じ署名を返します）
 func (w *Wallet) Sign(data []byte) ([]byte, error) {
 	signature, err := common.Sign(w.PrivateKey, data)
 	if err != nil {
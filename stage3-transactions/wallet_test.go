@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -55,6 +57,17 @@ func TestWalletSign(t *testing.T) {
 		assert.NotNil(t, signature)
 		assert.NotEmpty(t, signature)
 	})
+
+	t.Run("同じトランザクションには同じ署名をする", func(t *testing.T) {
+		wallet, bc, _, mempool := newTestChain(t)
+		first, err := NewTransaction(wallet, testAddressA, 10, 1, mempool, bc)
+		require.NoError(t, err)
+
+		second, err := DeserializeTransaction(first.Serialize())
+		require.NoError(t, err)
+		require.NoError(t, bc.SignTransaction(second, wallet))
+		assert.Equal(t, first.Inputs[0].ScriptSig, second.Inputs[0].ScriptSig)
+	})
 }
 
 func TestVerifySignature(t *testing.T) {
@@ -322,3 +335,100 @@ func TestWalletsSaveAndLoad(t *testing.T) {
 		assert.Equal(t, 0, len(loadedWallets.Wallets))
 	})
 }
+
+func TestWalletsActive(t *testing.T) {
+	t.Run("最初のウォレットが使用中になる", func(t *testing.T) {
+		wallets := NewWallets()
+		first, err := wallets.CreateWallet()
+		require.NoError(t, err)
+		_, err = wallets.CreateWallet()
+		require.NoError(t, err)
+
+		active, err := wallets.ActiveWallet()
+		require.NoError(t, err)
+		assert.Equal(t, first, active.GetAddress())
+	})
+
+	t.Run("使用中のウォレットを切り替えて保存", func(t *testing.T) {
+		wallets := NewWallets()
+		_, err := wallets.CreateWallet()
+		require.NoError(t, err)
+		second, err := wallets.CreateWallet()
+		require.NoError(t, err)
+
+		require.NoError(t, wallets.SetActive(second))
+		assert.Error(t, wallets.SetActive("nonexistent"))
+
+		filename := filepath.Join(t.TempDir(), "wallets.dat")
+		require.NoError(t, wallets.SaveToFile(filename))
+		loaded, err := LoadWalletsFromFile(filename)
+		require.NoError(t, err)
+
+		active, err := loaded.ActiveWallet()
+		require.NoError(t, err)
+		assert.Equal(t, second, active.GetAddress())
+	})
+
+	t.Run("空のコレクションには使用中のウォレットがない", func(t *testing.T) {
+		_, err := NewWallets().ActiveWallet()
+		assert.Error(t, err)
+	})
+}
+
+func TestWalletsResolve(t *testing.T) {
+	wallets := NewWallets()
+	for i := 0; i < 3; i++ {
+		_, err := wallets.CreateWallet()
+		require.NoError(t, err)
+	}
+	addresses := wallets.GetAddresses()
+
+	t.Run("一覧の番号で解決", func(t *testing.T) {
+		address, err := wallets.Resolve("2")
+		require.NoError(t, err)
+		assert.Equal(t, addresses[1], address)
+	})
+
+	t.Run("アドレスで解決", func(t *testing.T) {
+		address, err := wallets.Resolve(addresses[2])
+		require.NoError(t, err)
+		assert.Equal(t, addresses[2], address)
+	})
+
+	t.Run("範囲外の番号や知らないアドレスはエラー", func(t *testing.T) {
+		for _, input := range []string{"0", "4", testAddressA} {
+			_, err := wallets.Resolve(input)
+			assert.Error(t, err, input)
+		}
+	})
+}
+
+func TestWalletMnemonic(t *testing.T) {
+	t.Run("24語から同じウォレットを復元", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+
+		mnemonic, err := wallet.Mnemonic()
+		require.NoError(t, err)
+		assert.Len(t, strings.Fields(mnemonic), 24)
+
+		restored, err := NewWalletFromMnemonic(mnemonic)
+		require.NoError(t, err)
+		assert.Equal(t, wallet.PrivateKey.D, restored.PrivateKey.D)
+		assert.Equal(t, wallet.PublicKey.X, restored.PublicKey.X)
+		assert.Equal(t, wallet.GetAddress(), restored.GetAddress())
+	})
+
+	t.Run("12語は秘密鍵にならない", func(t *testing.T) {
+		_, err := NewWalletFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about")
+		assert.Error(t, err)
+	})
+
+	t.Run("すべて0のエントロピーは秘密鍵として無効", func(t *testing.T) {
+		mnemonic, err := NewMnemonic(make([]byte, 32))
+		require.NoError(t, err)
+
+		_, err = NewWalletFromMnemonic(mnemonic)
+		assert.Error(t, err)
+	})
+}
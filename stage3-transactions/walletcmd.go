@@ -0,0 +1,411 @@
+// Package main implements the wallet subcommands for Stage 3.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const walletUsage = `❌ Usage:
+  wallet list [--file wallets.dat]
+  wallet use [--file wallets.dat] <number|address>
+  wallet migrate [wallet files...]
+  wallet backup [--file wallet.dat]
+  wallet restore --mnemonic "..." [--file wallet.dat] [--force]
+  wallet label [--file wallets.dat] <address> <label>
+  wallet unlabel [--file wallets.dat] <label|address>
+  wallet labels [--file wallets.dat]
+  wallet keypool [--file wallets.dat] [--size <addresses>]
+  wallet export --qr [--file wallets.dat] [--key --passphrase <passphrase>] [--png paper-wallet.png] [number|address]
+  wallet import --key <encrypted key> --passphrase <passphrase> [--file wallets.dat]`
+
+// runWalletCommand は wallet サブコマンドを実行します
+func runWalletCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(walletUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		return runWalletList(args[1:])
+	case "use":
+		return runWalletUse(args[1:])
+	case "migrate":
+		return runWalletMigrate(args[1:])
+	case "backup":
+		return runWalletBackup(args[1:])
+	case "restore":
+		return runWalletRestore(args[1:])
+	case "label":
+		return runWalletLabel(args[1:])
+	case "unlabel":
+		return runWalletUnlabel(args[1:])
+	case "labels":
+		return runWalletLabels(args[1:])
+	case "keypool":
+		return runWalletKeyPool(args[1:])
+	case "export":
+		return runWalletExport(args[1:])
+	case "import":
+		return runWalletImport(args[1:])
+	default:
+		fmt.Println(walletUsage)
+		return 2
+	}
+}
+
+// runWalletList はローカルのウォレットを一覧表示します
+func runWalletList(args []string) int {
+	fs := flag.NewFlagSet("wallet list", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(wallets.Wallets) == 0 {
+		fmt.Printf("No wallets in %s yet. Start the CLI to create one.\n", *fileFlag)
+		return 0
+	}
+
+	for i, address := range wallets.GetAddresses() {
+		marker := " "
+		if address == wallets.Active {
+			marker = "*"
+		}
+		fmt.Printf("%s %2d. %s\n", marker, i+1, address)
+	}
+	return 0
+}
+
+// runWalletUse は使用するウォレットを切り替えて保存します
+func runWalletUse(args []string) int {
+	fs := flag.NewFlagSet("wallet use", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Println(walletUsage)
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	address, err := wallets.Resolve(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if err := wallets.SetActive(address); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if err := wallets.SaveToFile(*fileFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("✅ Now using %s\n", address)
+	return 0
+}
+
+// runWalletMigrate は指定したウォレットファイルのアドレスをBase58Checkに移行します
+func runWalletMigrate(args []string) int {
+	fs := flag.NewFlagSet("wallet migrate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{walletFile}
+	}
+
+	status := 0
+	for _, file := range files {
+		oldAddress, newAddress, err := MigrateWalletFile(file)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", file, err)
+			status = 1
+			continue
+		}
+
+		if oldAddress == newAddress {
+			fmt.Printf("✅ %s: already up to date (%s)\n", file, newAddress)
+			continue
+		}
+		fmt.Printf("🔁 %s: migrated\n", file)
+		fmt.Printf("   Old: %s\n", oldAddress)
+		fmt.Printf("   New: %s\n", newAddress)
+	}
+	return status
+}
+
+// runWalletBackup はウォレットの秘密鍵をニーモニックとして表示します
+func runWalletBackup(args []string) int {
+	fs := flag.NewFlagSet("wallet backup", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletFile, "バックアップするウォレットファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	wallet, err := LoadWalletFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	mnemonic, err := wallet.Mnemonic()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("🔑 Recovery phrase for %s\n", wallet.GetAddress())
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Println(mnemonic)
+	fmt.Println("────────────────────────────────────────────────────────")
+	fmt.Println("⚠️  Anyone with these words can spend your coins. Write them down and keep them offline.")
+	return 0
+}
+
+// runWalletRestore はニーモニックからウォレットを復元して保存します
+func runWalletRestore(args []string) int {
+	fs := flag.NewFlagSet("wallet restore", flag.ContinueOnError)
+	mnemonicFlag := fs.String("mnemonic", "", "wallet backup で表示した24語（引用符で囲む）")
+	fileFlag := fs.String("file", walletFile, "保存先のウォレットファイル")
+	forceFlag := fs.Bool("force", false, "既存のウォレットファイルを上書きする")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *mnemonicFlag == "" {
+		fmt.Println(walletUsage)
+		return 2
+	}
+
+	wallet, err := NewWalletFromMnemonic(*mnemonicFlag)
+	if err != nil {
+		fmt.Printf("❌ Restore failed: %v\n", err)
+		return 1
+	}
+
+	if _, err := os.Stat(*fileFlag); err == nil && !*forceFlag {
+		fmt.Printf("❌ %s already exists. Use --force to overwrite it.\n", *fileFlag)
+		return 1
+	}
+	if err := wallet.SaveToFile(*fileFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("✅ Wallet restored to %s\n", *fileFlag)
+	fmt.Printf("   Address: %s\n", wallet.GetAddress())
+	return 0
+}
+
+// runWalletLabel はアドレスにラベルを付けてアドレス帳に保存します
+func runWalletLabel(args []string) int {
+	fs := flag.NewFlagSet("wallet label", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Println(walletUsage)
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	address, label := fs.Arg(0), fs.Arg(1)
+	if err := wallets.SetLabel(address, label); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if err := wallets.SaveToFile(*fileFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("📇 %s\n", wallets.DisplayAddress(address))
+	return 0
+}
+
+// runWalletUnlabel はアドレス帳からラベルを削除します
+func runWalletUnlabel(args []string) int {
+	fs := flag.NewFlagSet("wallet unlabel", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Println(walletUsage)
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	address, err := wallets.RemoveLabel(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if err := wallets.SaveToFile(*fileFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("🗑️  Removed %s from the address book\n", address)
+	return 0
+}
+
+// runWalletLabels はアドレス帳をラベル順に表示します
+func runWalletLabels(args []string) int {
+	fs := flag.NewFlagSet("wallet labels", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if len(wallets.Labels) == 0 {
+		fmt.Println("The address book is empty. Add an entry with `wallet label <address> <label>`.")
+		return 0
+	}
+
+	for _, address := range wallets.LabeledAddresses() {
+		fmt.Printf("%-16s %s\n", wallets.Label(address), address)
+	}
+	return 0
+}
+
+// runWalletExport はウォレットのアドレス（と暗号化した秘密鍵）をQRコードとして端末に表示し、紙のウォレットとしてPNGに書き出します
+func runWalletExport(args []string) int {
+	fs := flag.NewFlagSet("wallet export", flag.ContinueOnError)
+	fileFlag := fs.String("file", walletsFile, "ウォレットの一覧ファイル")
+	qrFlag := fs.Bool("qr", false, "QRコードとして表示する")
+	keyFlag := fs.Bool("key", false, "passphrase で暗号化した秘密鍵も含める")
+	passphraseFlag := fs.String("passphrase", "", "秘密鍵を暗号化する passphrase")
+	pngFlag := fs.String("png", "", "紙のウォレットを書き出すPNGファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !*qrFlag || fs.NArg() > 1 {
+		fmt.Println(walletUsage)
+		return 2
+	}
+	if *keyFlag && *passphraseFlag == "" {
+		fmt.Println("❌ --key requires --passphrase to encrypt the private key")
+		return 2
+	}
+
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	var wallet *Wallet
+	if fs.NArg() == 0 {
+		wallet, err = wallets.ActiveWallet()
+	} else {
+		var address string
+		if address, err = wallets.Resolve(fs.Arg(0)); err == nil {
+			wallet, err = wallets.GetWallet(address)
+		}
+	}
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	addressQR, err := EncodeQR([]byte(wallet.GetAddress()))
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	fmt.Printf("📬 Address %s\n", wallet.GetAddress())
+	fmt.Print(addressQR.Terminal())
+
+	encryptedKey := ""
+	if *keyFlag {
+		fmt.Println("🔐 Encrypting the private key...")
+		if encryptedKey, err = EncryptPrivateKey(wallet, *passphraseFlag); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		keyQR, err := EncodeQR([]byte(encryptedKey))
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		fmt.Printf("🔑 Encrypted private key %s\n", encryptedKey)
+		fmt.Print(keyQR.Terminal())
+		fmt.Println("⚠️  Anyone with this key and the passphrase can spend your coins. Keep the passphrase separate from the paper.")
+	}
+
+	if *pngFlag != "" {
+		if err := WritePaperWallet(*pngFlag, wallet.GetAddress(), encryptedKey); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+		fmt.Printf("✅ Paper wallet written to %s\n", *pngFlag)
+	}
+	return 0
+}
+
+// runWalletImport は wallet export --key で印刷した暗号化済みの秘密鍵を復号し、ウォレットの一覧に追加します
+func runWalletImport(args []string) int {
+	fs := flag.NewFlagSet("wallet import", flag.ContinueOnError)
+	keyFlag := fs.String("key", "", "wallet export --key で表示した暗号化済みの秘密鍵")
+	passphraseFlag := fs.String("passphrase", "", "秘密鍵を暗号化したときの passphrase")
+	fileFlag := fs.String("file", walletsFile, "追加先のウォレットの一覧ファイル")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *keyFlag == "" || *passphraseFlag == "" || fs.NArg() != 0 {
+		fmt.Println(walletUsage)
+		return 2
+	}
+
+	wallet, err := DecryptPrivateKey(*keyFlag, *passphraseFlag)
+	if err != nil {
+		fmt.Printf("❌ Import failed: %v\n", err)
+		return 1
+	}
+	wallets, err := LoadWalletsFromFile(*fileFlag)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if _, err := wallets.GetWallet(wallet.GetAddress()); err == nil {
+		fmt.Printf("✅ %s is already in %s\n", wallet.GetAddress(), *fileFlag)
+		return 0
+	}
+	wallets.AddWallet(wallet)
+	if err := wallets.SaveToFile(*fileFlag); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("✅ Wallet imported to %s\n", *fileFlag)
+	fmt.Printf("   Address: %s\n", wallet.GetAddress())
+	return 0
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nyasuto/minicoin/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWalletCommand(t *testing.T) {
+	t.Run("不明なサブコマンドはエラー", func(t *testing.T) {
+		assert.Equal(t, 2, runWalletCommand(nil))
+		assert.Equal(t, 2, runWalletCommand([]string{"unknown"}))
+	})
+
+	t.Run("指定したファイルを移行する", func(t *testing.T) {
+		wallet, filename := saveLegacyWallet(t)
+
+		assert.Equal(t, 0, runWalletCommand([]string{"migrate", filename}))
+
+		loaded, err := LoadWalletFromFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, common.PublicKeyToAddress(wallet.PublicKey), loaded.GetAddress())
+	})
+
+	t.Run("バックアップした24語から同じ鍵を復元する", func(t *testing.T) {
+		wallet, err := NewWallet()
+		require.NoError(t, err)
+		mnemonic, err := wallet.Mnemonic()
+		require.NoError(t, err)
+
+		filename := filepath.Join(t.TempDir(), "restored.dat")
+		assert.Equal(t, 0, runWalletCommand([]string{"restore", "--mnemonic", mnemonic, "--file", filename}))
+		assert.Equal(t, 0, runWalletCommand([]string{"backup", "--file", filename}))
+
+		restored, err := LoadWalletFromFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, wallet.PrivateKey.D, restored.PrivateKey.D)
+		assert.Equal(t, wallet.GetAddress(), restored.GetAddress())
+
+		// 既存のファイルは --force なしでは上書きしない
+		assert.Equal(t, 1, runWalletCommand([]string{"restore", "--mnemonic", mnemonic, "--file", filename}))
+		assert.Equal(t, 0, runWalletCommand([]string{"restore", "--mnemonic", mnemonic, "--file", filename, "--force"}))
+	})
+
+	t.Run("アドレス帳にラベルを追加・削除する", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "wallets.dat")
+		assert.Equal(t, 0, runWalletCommand([]string{"label", "--file", filename, testAddressA, "Alice"}))
+		assert.Equal(t, 1, runWalletCommand([]string{"label", "--file", filename, testAddressB, "alice"}))
+		assert.Equal(t, 0, runWalletCommand([]string{"labels", "--file", filename}))
+
+		wallets, err := LoadWalletsFromFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, "Alice", wallets.Label(testAddressA))
+
+		assert.Equal(t, 0, runWalletCommand([]string{"unlabel", "--file", filename, "Alice"}))
+		assert.Equal(t, 1, runWalletCommand([]string{"unlabel", "--file", filename, "Alice"}))
+		assert.Equal(t, 2, runWalletCommand([]string{"label", "--file", filename, testAddressA}))
+	})
+
+	t.Run("ニーモニックなしや不正なニーモニックはエラー", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "wallet.dat")
+		assert.Equal(t, 2, runWalletCommand([]string{"restore", "--file", filename}))
+		assert.Equal(t, 1, runWalletCommand([]string{"restore", "--mnemonic", "zoo zoo zoo", "--file", filename}))
+		assert.NoFileExists(t, filename)
+	})
+}
+
+func TestRunWalletListAndUse(t *testing.T) {
+	wallets := NewWallets()
+	first, err := wallets.CreateWallet()
+	require.NoError(t, err)
+	second, err := wallets.CreateWallet()
+	require.NoError(t, err)
+
+	filename := filepath.Join(t.TempDir(), "wallets.dat")
+	require.NoError(t, wallets.SaveToFile(filename))
+
+	t.Run("一覧を表示", func(t *testing.T) {
+		assert.Equal(t, 0, runWalletCommand([]string{"list", "--file", filename}))
+	})
+
+	t.Run("アドレスで切り替えて保存", func(t *testing.T) {
+		assert.Equal(t, 0, runWalletCommand([]string{"use", "--file", filename, second}))
+
+		loaded, err := LoadWalletsFromFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, second, loaded.Active)
+	})
+
+	t.Run("番号で切り替えて保存", func(t *testing.T) {
+		number := "1"
+		if wallets.GetAddresses()[1] == first {
+			number = "2"
+		}
+		assert.Equal(t, 0, runWalletCommand([]string{"use", "--file", filename, number}))
+
+		loaded, err := LoadWalletsFromFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, first, loaded.Active)
+	})
+
+	t.Run("知らないウォレットはエラー", func(t *testing.T) {
+		assert.Equal(t, 1, runWalletCommand([]string{"use", "--file", filename, "3"}))
+		assert.Equal(t, 2, runWalletCommand([]string{"use", "--file", filename}))
+	})
+}
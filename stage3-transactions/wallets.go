@@ -8,20 +8,88 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"sort"
+	"strconv"
 )
 
 // Wallets は複数のウォレットを管理します
 type Wallets struct {
-	Wallets map[string]*Wallet // address -> Wallet
+	Wallets map[string]*Wallet       // address -> Wallet
+	Active  string                   // マイニングと送金に使うウォレットのアドレス
+	Scripts map[string]*RedeemScript // P2SHのアドレス -> 償還スクリプト
+	Labels  map[string]string        // アドレス帳: アドレス -> ラベル
+	KeyPool *KeyPool                 // おつり用のアドレス（最初におつりを受け取るときに作成）
 }
 
 // NewWallets は新しいウォレットコレクションを作成します
 func NewWallets() *Wallets {
 	return &Wallets{
 		Wallets: make(map[string]*Wallet),
+		Scripts: make(map[string]*RedeemScript),
+		Labels:  make(map[string]string),
 	}
 }
 
+// CreateScript はコレクション内のウォレットの公開鍵からP2SHの償還スクリプトを作成し、アドレスを返します
+// required 個の署名を要求し、lockHeight が正ならそのブロック高さまで使えないスクリプトになります
+// addresses は一覧の番号またはアドレスで指定できます
+func (ws *Wallets) CreateScript(required int, addresses []string, lockHeight int) (string, error) {
+	pubKeys := make([][]byte, 0, len(addresses))
+	for _, input := range addresses {
+		address, err := ws.Resolve(input)
+		if err != nil {
+			return "", err
+		}
+		pubKeys = append(pubKeys, publicKeyToBytes(ws.Wallets[address].PublicKey))
+	}
+
+	script, err := NewMultisigScript(required, pubKeys)
+	if err != nil {
+		return "", err
+	}
+	if script, err = script.WithLockHeight(lockHeight); err != nil {
+		return "", err
+	}
+
+	address := script.Address()
+	ws.Scripts[address] = script
+	return address, nil
+}
+
+// GetScript は指定されたP2SHアドレスの償還スクリプトを取得します
+func (ws *Wallets) GetScript(address string) (*RedeemScript, error) {
+	script, exists := ws.Scripts[address]
+	if !exists {
+		return nil, fmt.Errorf("script not found: %s", address)
+	}
+	return script, nil
+}
+
+// GetScriptAddresses は全てのP2SHアドレスを返します（アドレス順）
+func (ws *Wallets) GetScriptAddresses() []string {
+	addresses := make([]string, 0, len(ws.Scripts))
+	for address := range ws.Scripts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// Signers は償還スクリプトに署名できるローカルのウォレットを、スクリプトの公開鍵の順に返します
+func (ws *Wallets) Signers(script *RedeemScript) []*Wallet {
+	var signers []*Wallet
+	for _, pubKey := range script.PubKeys {
+		for _, address := range ws.GetAddresses() {
+			wallet := ws.Wallets[address]
+			if bytes.Equal(publicKeyToBytes(wallet.PublicKey), pubKey) {
+				signers = append(signers, wallet)
+				break
+			}
+		}
+	}
+	return signers
+}
+
 // CreateWallet は新しいウォレットを作成し、アドレスを返します
 func (ws *Wallets) CreateWallet() (string, error) {
 	wallet, err := NewWallet()
@@ -29,10 +97,50 @@ func (ws *Wallets) CreateWallet() (string, error) {
 		return "", fmt.Errorf("failed to create wallet: %w", err)
 	}
 
-	address := wallet.GetAddress()
-	ws.Wallets[address] = wallet
+	ws.AddWallet(wallet)
+	return wallet.GetAddress(), nil
+}
 
-	return address, nil
+// AddWallet はウォレットをコレクションに追加します
+// 最初に追加したウォレットが使用中のウォレットになります
+func (ws *Wallets) AddWallet(wallet *Wallet) {
+	ws.Wallets[wallet.GetAddress()] = wallet
+	if ws.Active == "" {
+		ws.Active = wallet.GetAddress()
+	}
+}
+
+// SetActive は使用中のウォレットを切り替えます
+func (ws *Wallets) SetActive(address string) error {
+	if _, exists := ws.Wallets[address]; !exists {
+		return fmt.Errorf("wallet not found: %s", address)
+	}
+	ws.Active = address
+	return nil
+}
+
+// ActiveWallet は使用中のウォレットを返します
+func (ws *Wallets) ActiveWallet() (*Wallet, error) {
+	if ws.Active == "" {
+		return nil, fmt.Errorf("no active wallet")
+	}
+	return ws.GetWallet(ws.Active)
+}
+
+// Resolve は一覧の番号（1から）またはアドレスを、コレクション内のウォレットのアドレスに解決します
+func (ws *Wallets) Resolve(input string) (string, error) {
+	if n, err := strconv.Atoi(input); err == nil {
+		addresses := ws.GetAddresses()
+		if n < 1 || n > len(addresses) {
+			return "", fmt.Errorf("wallet number must be 1 to %d, got %d", len(addresses), n)
+		}
+		return addresses[n-1], nil
+	}
+
+	if _, exists := ws.Wallets[input]; !exists {
+		return "", fmt.Errorf("wallet not found: %s", input)
+	}
+	return input, nil
 }
 
 // GetWallet は指定されたアドレスのウォレットを取得します
@@ -44,18 +152,23 @@ func (ws *Wallets) GetWallet(address string) (*Wallet, error) {
 	return wallet, nil
 }
 
-// GetAddresses は全てのウォレットアドレスを返します
+// GetAddresses は全てのウォレットアドレスを返します（一覧の番号が変わらないようアドレス順）
 func (ws *Wallets) GetAddresses() []string {
 	addresses := make([]string, 0, len(ws.Wallets))
 	for address := range ws.Wallets {
 		addresses = append(addresses, address)
 	}
+	sort.Strings(addresses)
 	return addresses
 }
 
 // walletsData はウォレット保存用の構造体
 type walletsData struct {
 	Wallets map[string]*walletData
+	Active  string
+	Scripts map[string]*RedeemScript
+	Labels  map[string]string
+	KeyPool *keyPoolData
 }
 
 // SaveToFile は全てのウォレットをファイルに保存します
@@ -63,6 +176,12 @@ func (ws *Wallets) SaveToFile(filename string) error {
 	// ウォレットデータを変換
 	data := walletsData{
 		Wallets: make(map[string]*walletData),
+		Active:  ws.Active,
+		Scripts: ws.Scripts,
+		Labels:  ws.Labels,
+	}
+	if ws.KeyPool != nil {
+		data.KeyPool = ws.KeyPool.data()
 	}
 
 	for address, wallet := range ws.Wallets {
@@ -121,6 +240,18 @@ func LoadWalletsFromFile(filename string) (*Wallets, error) {
 		}
 		wallets.Wallets[address] = wallet
 	}
+	wallets.Active = data.Active
+	for address, script := range data.Scripts {
+		wallets.Scripts[address] = script
+	}
+	for address, label := range data.Labels {
+		wallets.Labels[address] = label
+	}
+	if data.KeyPool != nil {
+		if wallets.KeyPool, err = restoreKeyPool(data.KeyPool); err != nil {
+			return nil, fmt.Errorf("failed to restore keypool: %w", err)
+		}
+	}
 
 	return wallets, nil
 }